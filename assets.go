@@ -0,0 +1,34 @@
+// Package ncoe holds the templates and static files that are built into the
+// server binary, so it runs from any working directory.
+package ncoe
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:generate go run ./cmd/vendor
+
+// Fragment templates start with an underscore, which embed skips unless a
+// pattern names them
+//
+//go:embed templates static templates/*/_*.html
+var files embed.FS
+
+// Templates returns the embedded templates directory
+func Templates() fs.FS {
+	return sub("templates")
+}
+
+// Static returns the embedded static directory
+func Static() fs.FS {
+	return sub("static")
+}
+
+func sub(dir string) fs.FS {
+	fsys, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}
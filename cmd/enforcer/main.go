@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 )
 
@@ -24,6 +25,9 @@ func main() {
 	// R5: Check request_id middleware
 	checkRequestIDMiddleware(report)
 
+	// R6: Check templates load no third-party assets
+	checkThirdPartyAssets(report)
+
 	// Print report
 	report.Print()
 
@@ -220,3 +224,33 @@ func checkRequestIDMiddleware(report *Report) {
 		)
 	}
 }
+
+// R6: Templates must not load assets from third-party hosts
+func checkThirdPartyAssets(report *Report) {
+	files, err := GlobFiles(TemplatePaths)
+	if err != nil {
+		fmt.Printf("Warning: could not glob template files: %v\n", err)
+		return
+	}
+
+	var patterns []*regexp.Regexp
+	for _, p := range ThirdPartyAssetPatterns {
+		patterns = append(patterns, regexp.MustCompile(p))
+	}
+	for _, file := range files {
+		matches, err := ScanFileForPatterns(file, patterns)
+		if err != nil {
+			fmt.Printf("Warning: could not scan %s: %v\n", file, err)
+			continue
+		}
+		for _, m := range matches {
+			report.AddFailure(
+				"R6",
+				m.Path,
+				m.Line,
+				fmt.Sprintf("Template loads a third-party asset: %s", m.Content),
+				"Vendor the file in assets.Vendor and use {{stylesheet}} or {{script}}",
+			)
+		}
+	}
+}
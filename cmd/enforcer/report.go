@@ -80,6 +80,7 @@ func (r *Report) Print() {
 		fmt.Println("   ✓ R2: Handlers have no forbidden imports")
 		fmt.Println("   ✓ R3: Repositories have no forbidden imports")
 		fmt.Println("   ✓ R5: request_id middleware exists and is wired correctly")
+		fmt.Println("   ✓ R6: Templates load no third-party assets")
 	} else {
 		fmt.Printf("❌ FAILED: %d error(s), %d warning(s)\n", len(r.Failures), len(r.Warnings))
 		fmt.Println("   Fix all failures before committing.")
@@ -66,6 +66,15 @@ var RequestIDRequirements = struct {
 	HeaderName:     "X-Request-Id",
 }
 
+// R6: Third-party Asset Rule
+// Templates load scripts, stylesheets and fonts from /static/ only, through
+// {{stylesheet}} and {{script}}; some agency networks block CDNs
+var ThirdPartyAssetPatterns = []string{
+	`<script[^>]+src="https?://`,
+	`<link[^>]+href="https?://[^"]*\.(css|js|woff2?)`,
+	`\b(cdn\.jsdelivr\.net|unpkg\.com|cdnjs\.cloudflare\.com)\b`,
+}
+
 // Fragment route patterns in main.go that should use "/_"
 // These suffixes indicate HTMX fragments (not full pages)
 var FragmentRouteSuffixes = []string{
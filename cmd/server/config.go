@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"ncoe/internal/config"
+)
+
+// configCommand runs `ncoe config check [flags]`, which loads the
+// configuration exactly as the server would and prints it with secrets
+// redacted, or every problem that would stop the server from starting.
+// It returns the process exit code.
+func configCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(stderr, "usage: ncoe config check [flags]")
+		return 2
+	}
+	cfg, err := config.Load(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Configuration is invalid:\n%v\n", err)
+		return 1
+	}
+	if err := cfg.WriteEffective(stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -1,25 +1,66 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"ncoe"
+	"ncoe/internal/assets"
 	"ncoe/internal/config"
 	"ncoe/internal/handler"
+	"ncoe/internal/logging"
+	"ncoe/internal/mail"
+	"ncoe/internal/metrics"
 	"ncoe/internal/middleware"
+	"ncoe/internal/oidc"
 	"ncoe/internal/repository/mock"
 	"ncoe/internal/service"
 	"ncoe/internal/templates"
+	"ncoe/internal/tracing"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.Load()
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	// Load configuration: defaults, branding file, environment, then flags
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	// Log JSON through slog; the standard log package, used for startup
+	// messages, writes through it too
+	slog.SetDefault(logging.New(os.Stderr, logging.Options{Level: cfg.Level(), Format: cfg.LogFormat}))
+
+	// Export spans to an OpenTelemetry collector or stdout when configured;
+	// with no exporter spans are still created, so trace IDs reach the logs
+	var exporter tracing.Exporter
+	switch cfg.TracesExporter {
+	case "otlp":
+		exporter = tracing.NewOTLPExporter(cfg.OTLPEndpoint, nil, cfg.ServiceName)
+		log.Printf("Exporting traces to %s", cfg.OTLPEndpoint)
+	case "console":
+		exporter = tracing.NewWriterExporter(os.Stdout)
+	}
+	tracing.SetDefault(tracing.New(tracing.Options{Exporter: exporter}))
 
 	// Initialize repositories (mock for demo, postgres for production)
 	var repos *mock.Repositories
-	if os.Getenv("DATABASE_URL") == "" {
+	demo := cfg.DatabaseURL == ""
+	if demo {
 		log.Println("DATABASE_URL not set, using mock repositories (demo mode)")
 		repos = mock.NewRepositories()
 	} else {
@@ -27,29 +68,131 @@ func main() {
 	}
 
 	// Initialize services
-	authService := service.NewAuthService(repos.User, repos.Session)
-	caseService := service.NewCaseService(repos.Case)
+	webhookService := service.NewWebhookService(repos.Webhook, service.DefaultRetryPolicy)
+	auditService := service.NewAuditService(repos.Audit)
+	mfaService, err := service.NewMFAService(repos.MFA, repos.User, repos.Session, auditService, cfg.Branding.ShortName+" Staff Portal", cfg.MFARequiredRoles)
+	if err != nil {
+		log.Fatalf("Invalid MFA_REQUIRED_ROLES: %v", err)
+	}
+	authService := service.NewAuthService(repos.User, repos.Session, mfaService, service.SessionPolicy{
+		IdleTimeout:     cfg.SessionIdleTimeout,
+		AbsoluteTimeout: cfg.SessionAbsoluteTimeout,
+	}, demo)
+	userService := service.NewUserService(repos.User, repos.Session, auditService)
+	caseService := service.NewCaseService(repos.Case, repos.User, webhookService, service.CasePolicy{
+		Prefixes:     cfg.Policy.Prefixes(),
+		ResponseDays: cfg.Policy.ResponseDays(),
+		ReminderDays: cfg.Policy.Deadlines.ReminderDays,
+	})
 	dashboardService := service.NewDashboardService(repos.Case)
+	reportService := service.NewReportService(repos.Case, repos.User)
+	ackService := service.NewAcknowledgmentService(repos.Acknowledgment)
+	annualReportService := service.NewAnnualReportService(repos.Case, repos.Opinion, repos.Acknowledgment, repos.AnnualReport)
+	// No challenge is configured; a CAPTCHA service can be added by
+	// implementing service.Challenge
+	intakeService := service.NewIntakeService(caseService, repos.HeldSubmission, nil, service.DefaultIntakePolicy)
+	opinionService := service.NewOpinionService(repos.Case, repos.Opinion, webhookService)
+	redactionService := service.NewRedactionService(repos.Case, repos.Redaction)
+	tokenService := service.NewAPITokenService(repos.APIToken, repos.User)
 
-	// Load templates
-	tmpl := templates.NewRenderer(cfg.TemplateDir)
+	// Single sign-on is optional; the provider is contacted on first login
+	var ssoService *service.SSOService
+	if cfg.OIDC.Enabled() {
+		provider := oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			Scopes:       []string{"email", "profile"},
+		})
+		ssoService, err = service.NewSSOService(provider, repos.User, service.SSOConfig{
+			ProviderName: cfg.OIDC.ProviderName,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			GroupsClaim:  cfg.OIDC.GroupsClaim,
+			RoleMap:      cfg.OIDC.RoleMap,
+			Exclusive:    cfg.OIDC.DisablePasswordLogin,
+		})
+		if err != nil {
+			log.Fatalf("Invalid single sign-on configuration: %v", err)
+		}
+	}
+
+	var mailService *service.MailService
+	if cfg.SMTPAddress != "" {
+		from := cfg.MailFrom
+		if from == "" {
+			from = cfg.Branding.ContactEmail
+		}
+		mailService = service.NewMailService(&mail.SMTP{Addr: cfg.SMTPAddress, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword}, from)
+	}
+
+	// Announce deadline reminders and newly overdue deadlines to webhook subscribers
+	go func() {
+		for {
+			caseService.NotifyDeadlineReminders(context.Background())
+			caseService.NotifyOverdueDeadlines(context.Background())
+			time.Sleep(15 * time.Minute)
+		}
+	}()
+
+	// Remove sessions that timed out without logging out
+	go func() {
+		for {
+			time.Sleep(5 * time.Minute)
+			authService.SweepExpiredSessions()
+		}
+	}()
+
+	// Templates and static files are built into the binary. In development
+	// they are read from disk instead, and templates re-parsed when edited.
+	templateFS, staticFS := ncoe.Templates(), ncoe.Static()
+	dev := cfg.Environment == "development"
+	if dev {
+		if isDir(cfg.TemplateDir) && isDir(cfg.StaticDir) {
+			templateFS, staticFS = os.DirFS(cfg.TemplateDir), os.DirFS(cfg.StaticDir)
+			log.Printf("Development mode: serving %s and %s from disk with template reload", cfg.TemplateDir, cfg.StaticDir)
+		} else {
+			dev = false
+			log.Printf("Development mode: %s or %s not found, using the embedded copies", cfg.TemplateDir, cfg.StaticDir)
+		}
+	}
+	static, err := assets.New(staticFS, dev)
+	if err != nil {
+		log.Fatalf("Failed to load static files: %v", err)
+	}
+	if err := static.Require(assets.VendorNames()); err != nil {
+		if cfg.Environment == "production" {
+			log.Fatalf("%v; run go generate ./... to fetch them", err)
+		}
+		log.Printf("Warning: %v; pages render without Bootstrap and HTMX until go generate ./... fetches them", err)
+	}
+	tmpl, err := templates.NewRenderer(templateFS, templates.Options{Static: static, Reload: dev})
+	if err != nil {
+		log.Fatalf("Failed to load templates: %v", err)
+	}
 
 	// Initialize handlers
-	authHandler := handler.NewAuthHandler(authService, tmpl, cfg.Branding)
-	staffHandler := handler.NewStaffHandler(caseService, dashboardService, tmpl, cfg.Branding)
-	publicHandler := handler.NewPublicHandler(caseService, tmpl, cfg.Branding)
+	authHandler := handler.NewAuthHandler(authService, mfaService, ssoService, userService, tmpl, cfg.Branding, cfg.BaseURL())
+	staffHandler := handler.NewStaffHandler(caseService, dashboardService, opinionService, redactionService, tokenService, webhookService, mfaService, authService, userService, auditService, reportService, ackService, annualReportService, intakeService, mailService, tmpl, cfg.Branding, cfg.BaseURL())
+	publicHandler := handler.NewPublicHandler(caseService, opinionService, intakeService, tmpl, cfg.Branding, cfg.BaseURL())
+	apiHandler := handler.NewAPIHandler(caseService, dashboardService, opinionService)
 
 	// Setup routes
 	mux := http.NewServeMux()
 
-	// Static files
-	fs := http.FileServer(http.Dir(cfg.StaticDir))
-	mux.Handle("/static/", http.StripPrefix("/static/", fs))
+	// Static files, with content-hashed URLs from {{asset}}
+	mux.Handle(assets.Prefix, static)
+
+	// Content-Security-Policy violation reports from browsers
+	mux.Handle(middleware.CSPReportPath, middleware.CSPReport(middleware.CSPReportRate))
 
 	// Auth routes
 	mux.HandleFunc("/", publicHandler.Home)
 	mux.HandleFunc("/staff/login", authHandler.StaffLogin)
+	mux.HandleFunc("/staff/login/mfa", authHandler.StaffLoginMFA)
+	mux.HandleFunc("/staff/login/sso", authHandler.SSOLogin)
+	mux.HandleFunc("/staff/login/sso/callback", authHandler.SSOCallback)
 	mux.HandleFunc("/staff/logout", authHandler.Logout)
+	mux.HandleFunc("/staff/password", authHandler.SetPassword) // Invitation and password reset links
 
 	// Public submission forms (no login required)
 	mux.HandleFunc("/submit/advisory-opinion", publicHandler.SubmitAdvisoryOpinion)
@@ -61,38 +204,117 @@ func main() {
 	// Public search
 	mux.HandleFunc("/search", publicHandler.Search)
 	mux.HandleFunc("/opinions/", publicHandler.ViewOpinion)
+	mux.HandleFunc("/documents/", publicHandler.PublicDocument)
+	mux.HandleFunc("/statutes", publicHandler.Statutes)
+	mux.HandleFunc("/statutes/", publicHandler.Statutes)
+	mux.HandleFunc("/feeds/", publicHandler.OpinionFeed)
+	mux.HandleFunc("/sitemap.xml", publicHandler.Sitemap)
+	mux.HandleFunc("/robots.txt", publicHandler.Robots)
+
+	// JSON API (the handler answers 401 itself for routes that need a session)
+	authMiddleware := middleware.NewAuthMiddleware(authService, tokenService)
+	mux.Handle("/api/v1/", authMiddleware.Authenticate(apiHandler))
 
 	// Staff routes (protected)
 	staffMux := http.NewServeMux()
 	staffMux.HandleFunc("/staff/dashboard", staffHandler.Dashboard)
 	staffMux.HandleFunc("/staff/cases", staffHandler.CaseList)
-	staffMux.HandleFunc("/staff/cases/", staffHandler.CaseDetail)          // Handles /{id} and /{id}/_panel, /{id}/_status
+	staffMux.HandleFunc("/staff/cases/", staffHandler.CaseDetail)         // Handles /_table, /{id} and /{id}/_panel, /{id}/_status, /{id}/_disposition
+	staffMux.HandleFunc("/staff/documents/", staffHandler.DocumentDetail) // Handles /{id}, /download and redaction actions
 	staffMux.HandleFunc("/staff/acknowledgments", staffHandler.Acknowledgments)
 	staffMux.HandleFunc("/staff/acknowledgments/", staffHandler.AcknowledgmentsDetail) // Handles /{id}/_panel
 	staffMux.HandleFunc("/staff/deadlines", staffHandler.Deadlines)
 	staffMux.HandleFunc("/staff/reports", staffHandler.Reports)
+	staffMux.HandleFunc("/staff/reports/export", staffHandler.ReportExport) // ?format=csv or xlsx
+	staffMux.HandleFunc("/staff/reports/annual", staffHandler.AnnualReport)
+	staffMux.HandleFunc("/staff/reports/annual/", staffHandler.AnnualReport) // Handles /narrative and /download
 	staffMux.HandleFunc("/staff/users", staffHandler.Users)
+	staffMux.HandleFunc("/staff/users/", staffHandler.UserDetail) // Handles /{id}, account actions, /mfa/policy and /{id}/sessions/revoke
+	staffMux.HandleFunc("/staff/audit", staffHandler.Audit)
 	staffMux.HandleFunc("/staff/settings", staffHandler.Settings)
+	staffMux.HandleFunc("/staff/settings/tokens", staffHandler.APITokens)
+	staffMux.HandleFunc("/staff/settings/tokens/", staffHandler.APITokens)  // Handles /{id}/revoke
+	staffMux.HandleFunc("/staff/settings/mfa/", staffHandler.MFA)           // Handles /enroll, /confirm, /recovery-codes and /disable
+	staffMux.HandleFunc("/staff/settings/sessions/", staffHandler.Sessions) // Handles /{id}/revoke and /revoke-others
+	staffMux.HandleFunc("/staff/webhooks", staffHandler.Webhooks)
+	staffMux.HandleFunc("/staff/webhooks/", staffHandler.WebhookDetail) // Handles /{id}, actions and redelivery
+	staffMux.HandleFunc("/staff/triage", staffHandler.Triage)
+	staffMux.HandleFunc("/staff/triage/", staffHandler.TriageAction) // Handles /{id}/accept and /{id}/reject
 
 	// Wrap staff routes with auth middleware
-	authMiddleware := middleware.NewAuthMiddleware(authService)
-	mux.Handle("/staff/", authMiddleware.RequireAuth(staffMux))
+	mux.Handle("/staff/", authMiddleware.RequireAuth(middleware.Route(staffMux)))
+
+	// Prometheus metrics, on their own listener or behind a bearer token
+	metricsHandler := metrics.Handler(metrics.Default, dashboardService.Gauges)
+	if cfg.MetricsToken != "" {
+		mux.Handle("/metrics", middleware.RequireBearerToken(cfg.MetricsToken, metricsHandler))
+	}
+	if cfg.MetricsAddress != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metricsHandler)
+		go func() {
+			log.Printf("Serving metrics on %s/metrics", cfg.MetricsAddress)
+			log.Fatal(http.ListenAndServe(cfg.MetricsAddress, metricsMux))
+		}()
+	}
 
 	// Apply global middleware (order: outermost first)
-	// Recovery -> RequestID -> Logging -> mux
-	// RequestID runs before Logging so request_id is available for log output
-	var h http.Handler = mux
+	// RequestID -> ClientIP -> Tracing -> SecurityHeaders -> Logging -> Metrics -> Recovery -> Route -> mux
+	// RequestID runs first so every log line and span about the request
+	// carries request_id; ClientIP finds the address behind any trusted
+	// proxies for rate limits and sessions; Tracing wraps the rest so its
+	// span covers them;
+	// Recovery runs inside Logging and Metrics so a panic is logged and
+	// counted as a 500
+	var h http.Handler = middleware.Route(mux)
+	h = middleware.Recovery(h)
+	h = middleware.Metrics(h)
 	h = middleware.Logging(h)
+	h = middleware.SecurityHeaders(middleware.SecurityOptions{
+		ReportOnly: cfg.CSPReportOnly,
+		HSTS:       cfg.Environment != "development",
+	})(h)
+	h = middleware.Tracing(h)
+	h = middleware.ClientIP(cfg.Proxies())(h)
 	h = middleware.RequestID(h)
-	h = middleware.Recovery(h)
 
 	// Start server
 	addr := cfg.ServerAddress
-	if addr == "" {
-		addr = ":8080"
+	log.Printf("Starting NCOE Case Management System on %s (%s)", addr, cfg.Environment)
+	if ssoService != nil {
+		log.Printf("Single sign-on enabled via %s", cfg.OIDC.Issuer)
+	}
+	if demo && (ssoService == nil || !ssoService.Exclusive()) {
+		log.Printf("Demo mode: any credentials accepted for staff login")
+	}
+	srv := &http.Server{Addr: addr, Handler: h}
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// On SIGINT or SIGTERM finish the requests and webhook attempts under
+	// way, then export the spans still waiting
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	<-stop.Done()
+	log.Printf("Shutting down")
+	ctx, done := context.WithTimeout(context.Background(), 30*time.Second)
+	defer done()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Stopping the server: %v", err)
+	}
+	if err := webhookService.Shutdown(ctx); err != nil {
+		log.Printf("Webhook deliveries still under way: %v", err)
+	}
+	if err := tracing.Default().Shutdown(ctx); err != nil {
+		log.Printf("Exporting spans: %v", err)
 	}
-	log.Printf("Starting NCOE Case Management System on %s", addr)
-	log.Printf("Demo mode: any credentials accepted for staff login")
-	log.Fatal(http.ListenAndServe(addr, h))
 }
 
+// isDir returns true if path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
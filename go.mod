@@ -6,4 +6,4 @@ toolchain go1.24.11
 
 require gopkg.in/yaml.v3 v3.0.1
 
-require golang.org/x/net v0.48.0 // indirect
+require golang.org/x/net v0.48.0
@@ -0,0 +1,169 @@
+// Package assets serves the static files under /static/. Each file's URL
+// carries a hash of its content, so browsers can cache it forever and still
+// fetch a new copy the moment the file changes.
+package assets
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Prefix is the URL path static files are served under
+const Prefix = "/static/"
+
+// hashLength is the number of hex digits of the content hash in a URL
+const hashLength = 10
+
+// hashedName matches a name with a content hash, e.g. css/custom.3f2a9c1d0b.css
+var hashedName = regexp.MustCompile(`^(.+)\.[0-9a-f]{10}(\.[^./]+)$`)
+
+// Static resolves and serves static files
+type Static struct {
+	fsys   fs.FS
+	dev    bool
+	hashed map[string]string // Name -> hashed name
+	names  map[string]string // Hashed name -> name
+	sri    map[string]string // Name -> Subresource Integrity hash
+}
+
+// New fingerprints every file in fsys and computes its integrity hash. In
+// dev mode nothing is fingerprinted: URLs are plain, every response must be
+// revalidated and integrity is computed per page, so edits on disk show on
+// the next reload.
+func New(fsys fs.FS, dev bool) (*Static, error) {
+	s := &Static{fsys: fsys, dev: dev, hashed: make(map[string]string), names: make(map[string]string), sri: make(map[string]string)}
+	if dev {
+		return s, nil
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:])[:hashLength] + ext
+		s.hashed[name] = hashed
+		s.names[hashed] = name
+		s.sri[name] = Integrity(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path returns the URL of a static file, given as "css/custom.css" or
+// "/static/css/custom.css". Other URLs, such as a logo hosted elsewhere, are
+// returned unchanged. A nil Static gives plain URLs.
+func (s *Static) Path(name string) string {
+	if strings.Contains(name, "://") || (strings.HasPrefix(name, "/") && !strings.HasPrefix(name, Prefix)) {
+		return name
+	}
+	name = strings.TrimPrefix(name, Prefix)
+	if s != nil {
+		if hashed, ok := s.hashed[name]; ok {
+			return Prefix + hashed
+		}
+	}
+	return Prefix + name
+}
+
+// Stylesheet returns a <link> tag for a static stylesheet, with its
+// integrity hash so the browser refuses a file altered on the way
+func (s *Static) Stylesheet(name string) template.HTML {
+	return template.HTML(fmt.Sprintf(`<link rel="stylesheet" href="%s"%s>`,
+		template.HTMLEscapeString(s.Path(name)), s.integrityAttr(name)))
+}
+
+// Script returns a <script> tag for a static script, with its integrity hash
+func (s *Static) Script(name string) template.HTML {
+	return template.HTML(fmt.Sprintf(`<script src="%s"%s></script>`,
+		template.HTMLEscapeString(s.Path(name)), s.integrityAttr(name)))
+}
+
+// integrityAttr returns the integrity attribute for a static file, or
+// nothing if the file is missing
+func (s *Static) integrityAttr(name string) string {
+	if s == nil {
+		return ""
+	}
+	name = strings.TrimPrefix(name, Prefix)
+	sri, ok := s.sri[name]
+	if s.dev {
+		data, err := fs.ReadFile(s.fsys, name)
+		sri, ok = Integrity(data), err == nil
+	}
+	if !ok {
+		return ""
+	}
+	return ` integrity="` + sri + `"`
+}
+
+// Require returns an error listing any of the named files that are missing
+func (s *Static) Require(names []string) error {
+	var missing []string
+	for _, name := range names {
+		if info, err := fs.Stat(s.fsys, name); err != nil || info.IsDir() {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("assets: missing static files %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Integrity returns the Subresource Integrity hash of data, as the tags
+// carry it
+func Integrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ServeHTTP serves a static file. A URL with the current content hash is
+// cached as immutable; anything else must be revalidated, including a URL
+// with an old hash, which gets the current file rather than a 404.
+func (s *Static) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, Prefix)
+	if original, ok := s.names[name]; ok {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("ETag", `"`+name+`"`)
+		s.serve(w, r, original)
+		return
+	}
+	if m := hashedName.FindStringSubmatch(name); m != nil {
+		if _, err := fs.Stat(s.fsys, name); err != nil {
+			name = m[1] + m[2]
+		}
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	if hashed, ok := s.hashed[name]; ok {
+		w.Header().Set("ETag", `"`+hashed+`"`)
+	}
+	s.serve(w, r, name)
+}
+
+func (s *Static) serve(w http.ResponseWriter, r *http.Request, name string) {
+	info, err := fs.Stat(s.fsys, name)
+	if !fs.ValidPath(name) || err != nil || info.IsDir() {
+		w.Header().Del("Cache-Control")
+		w.Header().Del("ETag")
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFileFS(w, r, s.fsys, name)
+}
@@ -0,0 +1,222 @@
+package assets
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"ncoe"
+)
+
+func testFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"css/custom.css":   {Data: []byte("body { color: #003366; }")},
+		"js/app.js":        {Data: []byte("console.log('ncoe');")},
+		"img/coe-seal.png": {Data: []byte("\x89PNG")},
+	}
+}
+
+func get(t *testing.T, s *Static, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestPath(t *testing.T) {
+	s, err := New(testFiles(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := regexp.MustCompile(`^/static/css/custom\.[0-9a-f]{10}\.css$`)
+	for _, name := range []string{"css/custom.css", "/static/css/custom.css"} {
+		if got := s.Path(name); !hashed.MatchString(got) {
+			t.Errorf("Path(%q) = %q, want a hashed URL", name, got)
+		}
+	}
+	tests := map[string]string{
+		"css/missing.css":                  "/static/css/missing.css",
+		"https://cdn.example.gov/logo.png": "https://cdn.example.gov/logo.png",
+		"/favicon.ico":                     "/favicon.ico",
+	}
+	for name, want := range tests {
+		if got := s.Path(name); got != want {
+			t.Errorf("Path(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	// The hash follows the content
+	files := testFiles()
+	files["css/custom.css"] = &fstest.MapFile{Data: []byte("body { color: #C4A000; }")}
+	changed, err := New(files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Path("css/custom.css") == s.Path("css/custom.css") {
+		t.Error("changing a file did not change its URL")
+	}
+	if changed.Path("js/app.js") != s.Path("js/app.js") {
+		t.Error("an unchanged file got a new URL")
+	}
+
+	var none *Static
+	if got := none.Path("js/app.js"); got != "/static/js/app.js" {
+		t.Errorf("nil Path = %q", got)
+	}
+}
+
+func TestServe(t *testing.T) {
+	s, err := New(testFiles(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := s.Path("css/custom.css")
+	w := get(t, s, url)
+	if w.Code != http.StatusOK || w.Body.String() != "body { color: #003366; }" {
+		t.Fatalf("GET %s: %d %q", url, w.Code, w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") || !strings.Contains(cc, "max-age=31536000") {
+		t.Errorf("hashed URL Cache-Control = %q", cc)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// Revalidation with the ETag needs no body
+	req := httptest.NewRequest("GET", url, nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("conditional GET: %d, want 304", w.Code)
+	}
+
+	// Plain and stale URLs still work but must be revalidated
+	for _, path := range []string{"/static/css/custom.css", "/static/css/custom.0123456789.css"} {
+		w := get(t, s, path)
+		if w.Code != http.StatusOK || w.Body.String() != "body { color: #003366; }" {
+			t.Errorf("GET %s: %d %q", path, w.Code, w.Body.String())
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+			t.Errorf("GET %s Cache-Control = %q, want no-cache", path, cc)
+		}
+	}
+
+	for _, path := range []string{"/static/", "/static/css", "/static/css/", "/static/missing.js", "/static/../static.go"} {
+		w := get(t, s, path)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want 404", path, w.Code)
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "" {
+			t.Errorf("GET %s: a 404 is cached with %q", path, cc)
+		}
+	}
+}
+
+func TestDevMode(t *testing.T) {
+	files := testFiles()
+	s, err := New(files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Path("css/custom.css"); got != "/static/css/custom.css" {
+		t.Errorf("dev Path = %q, want the plain URL", got)
+	}
+
+	// Files are read on every request, so edits show without a restart
+	files["css/custom.css"] = &fstest.MapFile{Data: []byte("body { color: red; }")}
+	w := get(t, s, "/static/css/custom.css")
+	if w.Body.String() != "body { color: red; }" {
+		t.Errorf("dev GET = %q, want the edited file", w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("dev Cache-Control = %q, want no-cache", cc)
+	}
+}
+
+func TestTags(t *testing.T) {
+	s, err := New(testFiles(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sri := regexp.MustCompile(` integrity="sha384-[A-Za-z0-9+/]{64}"`)
+
+	link := string(s.Stylesheet("css/custom.css"))
+	if !strings.HasPrefix(link, `<link rel="stylesheet" href="`+s.Path("css/custom.css")+`"`) || !sri.MatchString(link) {
+		t.Errorf("Stylesheet = %s", link)
+	}
+	script := string(s.Script("js/app.js"))
+	if !strings.HasPrefix(script, `<script src="`+s.Path("js/app.js")+`"`) || !sri.MatchString(script) || !strings.HasSuffix(script, "></script>") {
+		t.Errorf("Script = %s", script)
+	}
+	if sri.FindString(link) == sri.FindString(script) {
+		t.Error("different files got the same integrity hash")
+	}
+
+	// A missing file still gets a tag, but no hash to fail against
+	if got := string(s.Script("js/missing.js")); got != `<script src="/static/js/missing.js"></script>` {
+		t.Errorf("Script(missing) = %s", got)
+	}
+
+	// In dev mode the hash follows edits on disk
+	files := testFiles()
+	dev, err := New(files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := string(dev.Stylesheet("css/custom.css"))
+	files["css/custom.css"] = &fstest.MapFile{Data: []byte("body { color: red; }")}
+	if after := string(dev.Stylesheet("css/custom.css")); after == before || !sri.MatchString(after) {
+		t.Errorf("dev Stylesheet after edit = %s, before %s", after, before)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	s, err := New(testFiles(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Require([]string{"css/custom.css", "js/app.js"}); err != nil {
+		t.Errorf("Require present files: %v", err)
+	}
+	err = s.Require([]string{"css/custom.css", "vendor/htmx/htmx.min.js", "css"})
+	if err == nil || !strings.Contains(err.Error(), "vendor/htmx/htmx.min.js, css") {
+		t.Errorf("Require = %v, want the missing file and the directory listed", err)
+	}
+}
+
+// TestVendoredIntegrity checks the vendored files are committed and built
+// into the binary, that they are the pinned releases byte for byte, and
+// that their tags carry the releases' hashes
+func TestVendoredIntegrity(t *testing.T) {
+	fsys := ncoe.Static()
+	s, err := New(fsys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var missing []string
+	for _, v := range Vendor {
+		data, err := fs.ReadFile(fsys, v.Name)
+		if err != nil {
+			missing = append(missing, v.Name)
+			continue
+		}
+		if v.Integrity == "" {
+			continue
+		}
+		if sri := Integrity(data); sri != v.Integrity {
+			t.Errorf("%s: integrity %s, want %s", v.Name, sri, v.Integrity)
+		}
+		if attr := s.integrityAttr(v.Name); attr != ` integrity="`+v.Integrity+`"` {
+			t.Errorf("%s: tag integrity%s, want %s", v.Name, attr, v.Integrity)
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("vendored files missing: %v; run go generate ./... and commit static/vendor", missing)
+	}
+}
@@ -0,0 +1,37 @@
+package assets
+
+// Vendored is a third-party file served from our own static directory
+// rather than a CDN, which some agency networks block
+type Vendored struct {
+	Name string // Path under static/, e.g. "vendor/htmx/htmx.min.js"
+	URL  string // Pinned upstream release the file is fetched from
+	// Integrity is the release's published sha384 Subresource Integrity
+	// hash, checked when the file is fetched and against the embedded copy.
+	// Fonts, which stylesheets load without an integrity check, have none.
+	Integrity string
+}
+
+// Vendor lists the vendored files. They are fetched into static/vendor by
+// go generate before a build; without them pages render unstyled and
+// without HTMX, so the server refuses to start in production.
+var Vendor = []Vendored{
+	{Name: "vendor/bootstrap/bootstrap.min.css", URL: "https://cdn.jsdelivr.net/npm/bootstrap@5.3.3/dist/css/bootstrap.min.css",
+		Integrity: "sha384-QWTKZyjpPEjISv5WaRU9OFeRpok6YctnYmDr5pNlyT2bRjXh0JMhjY6hW+ALEwIH"},
+	{Name: "vendor/bootstrap/bootstrap.bundle.min.js", URL: "https://cdn.jsdelivr.net/npm/bootstrap@5.3.3/dist/js/bootstrap.bundle.min.js",
+		Integrity: "sha384-YvpcrYf0tY3lHB60NNkmXc5s9fDVZLESaAA55NDzOxhy9GkcIdslK1eN7N6jIeHz"},
+	{Name: "vendor/bootstrap-icons/bootstrap-icons.min.css", URL: "https://cdn.jsdelivr.net/npm/bootstrap-icons@1.11.3/font/bootstrap-icons.min.css",
+		Integrity: "sha384-XGjxtQfXaH2tnPFa9x+ruJTuLE3Aa6LhHSWRr1XeTyhezb4abCG4ccI5AkVDxqC+"},
+	{Name: "vendor/bootstrap-icons/fonts/bootstrap-icons.woff2", URL: "https://cdn.jsdelivr.net/npm/bootstrap-icons@1.11.3/font/fonts/bootstrap-icons.woff2"},
+	{Name: "vendor/bootstrap-icons/fonts/bootstrap-icons.woff", URL: "https://cdn.jsdelivr.net/npm/bootstrap-icons@1.11.3/font/fonts/bootstrap-icons.woff"},
+	{Name: "vendor/htmx/htmx.min.js", URL: "https://cdn.jsdelivr.net/npm/htmx.org@2.0.4/dist/htmx.min.js",
+		Integrity: "sha384-HGfztofotfshcF7+8n44JQL2oJmowVChPTg48S+jvZoztPfvwD79OC/LTtG6dMp+"},
+}
+
+// VendorNames returns the static paths of the vendored files
+func VendorNames() []string {
+	names := make([]string, len(Vendor))
+	for i, v := range Vendor {
+		names[i] = v.Name
+	}
+	return names
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in the effective configuration
+const redacted = "REDACTED"
+
+// WriteEffective writes the configuration the server would run with, one
+// setting per line with where it came from, then the branding and policy in
+// branding file form. Secrets are redacted, so the output can be shared.
+func (c *Config) WriteEffective(w io.Writer) error {
+	fmt.Fprintln(w, "# Settings (source in comments)")
+	for _, s := range settings {
+		value := s.get(c)
+		if s.secret {
+			value = redact(s.key, value)
+		}
+		fmt.Fprintf(w, "%s: %q # %s\n", s.key, value, c.sources[s.key])
+	}
+
+	fmt.Fprintf(w, "\n# Branding and agency policy from %s\n", c.sources["branding"])
+	out, err := yaml.Marshal(brandingFile{Branding: c.Branding, Policy: c.Policy})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// redact hides a secret value. A database URL keeps everything but its
+// password, so the host and database can still be checked.
+func redact(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	if key != "database_url" {
+		return redacted
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
@@ -1,18 +1,149 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	ServerAddress string
+	PublicURL     string // Address the public reaches the site at, for absolute links; see BaseURL
 	DatabaseURL   string
 	Environment   string
-	TemplateDir   string // Absolute path to templates directory
-	StaticDir     string // Absolute path to static directory
+	TemplateDir   string // Templates on disk, used in development
+	StaticDir     string // Static files on disk, used in development
+	BrandingFile  string // YAML file with the branding, agency policy and any settings
 	Branding      Branding
+	Policy        Policy
+	OIDC          OIDC
+
+	// MFARequiredRoles must sign in with a second factor until an admin
+	// changes the policy
+	MFARequiredRoles []string
+
+	// SessionIdleTimeout signs staff out after this long without a request;
+	// SessionAbsoluteTimeout signs them out this long after login regardless
+	SessionIdleTimeout     time.Duration
+	SessionAbsoluteTimeout time.Duration
+
+	// TrustedProxies are the addresses or CIDR ranges of the reverse proxies
+	// in front of the server; only their X-Forwarded-For and X-Real-IP
+	// headers are believed when finding the client address
+	TrustedProxies []string
+
+	// CSPReportOnly reports Content-Security-Policy violations to
+	// /csp-report without blocking anything, for trying out a policy change
+	CSPReportOnly bool
+
+	// LogLevel is debug, info, warn or error; when empty it follows the
+	// environment (see Level). LogFormat is json or text.
+	LogLevel  string
+	LogFormat string
+
+	// MetricsAddress serves /metrics on a separate listener, e.g. one only
+	// reachable from the monitoring network. MetricsToken serves it on the
+	// main listener to scrapers presenting the token as a bearer token.
+	// Without either /metrics is not served.
+	MetricsAddress string
+	MetricsToken   string
+
+	// SMTPAddress is the relay, host:port, that invitation and password reset
+	// emails go through, signing in as SMTPUsername when set. They come from
+	// MailFrom, or the branding contact email when that is empty. Without a
+	// relay the links are only shown to the admin.
+	SMTPAddress  string
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+
+	// TracesExporter is where spans go: none, otlp to the collector at
+	// OTLPEndpoint, or console to print them on stdout. The standard
+	// OpenTelemetry variables set them.
+	TracesExporter string
+	OTLPEndpoint   string
+	ServiceName    string
+
+	// sources records where each setting came from, for `config check`
+	sources map[string]string
+}
+
+// Level returns the minimum level to log: LogLevel if set, otherwise
+// debug in development and info elsewhere
+func (c *Config) Level() slog.Level {
+	var level slog.Level
+	if c.LogLevel != "" && level.UnmarshalText([]byte(c.LogLevel)) == nil {
+		return level
+	}
+	if c.Environment == "development" {
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// BaseURL returns the scheme and host absolute links are built from: the
+// public URL, or in development the server address on localhost. Links
+// never come from the request's Host header, which the client controls.
+func (c *Config) BaseURL() string {
+	if c.PublicURL != "" {
+		return strings.TrimSuffix(c.PublicURL, "/")
+	}
+	_, port, _ := net.SplitHostPort(c.ServerAddress)
+	return "http://localhost:" + port
+}
+
+// Proxies returns TrustedProxies as address ranges, a single address
+// becoming a range of one. Entries Validate rejects are left out.
+func (c *Config) Proxies() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, p := range c.TrustedProxies {
+		if prefix, err := parseProxy(p); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+func parseProxy(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// OIDC configures single sign-on through an OpenID Connect provider.
+// Single sign-on is enabled when Issuer is set.
+type OIDC struct {
+	Issuer               string
+	ClientID             string
+	ClientSecret         string
+	RedirectURL          string            // Callback registered with the provider; derived from the request when empty
+	ProviderName         string            // Shown on the login button
+	GroupsClaim          string            // ID token claim listing the user's groups
+	RoleMap              map[string]string // Provider group -> role
+	DisablePasswordLogin bool              // Require single sign-on for every staff login
+}
+
+// Enabled returns true if an identity provider is configured
+func (o OIDC) Enabled() bool {
+	return o.Issuer != ""
 }
 
 type Branding struct {
@@ -30,39 +161,333 @@ type Branding struct {
 	Address        string `yaml:"address"`
 }
 
-func Load() *Config {
+// DefaultBranding is the Nevada Commission on Ethics branding, used for
+// anything the branding file leaves out
+func DefaultBranding() Branding {
+	return Branding{
+		AgencyName:     "Nevada Commission on Ethics",
+		ShortName:      "NCOE",
+		Tagline:        "Integrity and Trust",
+		Logo:           "/static/img/logo.svg",
+		Favicon:        "/static/img/favicon.ico",
+		PrimaryColor:   "#003366",
+		SecondaryColor: "#C4A000",
+		AccentColor:    "#1a5f7a",
+		ContactEmail:   "ncoe@ethics.nv.gov",
+		ContactPhone:   "(775) 687-5469",
+		Website:        "https://ethics.nv.gov",
+		Address:        "704 W. Nye Lane, Suite 204, Carson City, NV 89703",
+	}
+}
+
+// brandingFile is the layout of the branding file: branding, policy and
+// setting keys side by side at the top level. Settings are collected raw
+// and parsed like their environment variables (see setNode).
+type brandingFile struct {
+	Branding `yaml:",inline"`
+	Policy   `yaml:",inline"`
+	Settings map[string]yaml.Node `yaml:",inline"`
+}
+
+// defaultBrandingFile is read when no other file is named; unlike a named
+// file it may be missing
+const defaultBrandingFile = "config/branding.yaml"
+
+// setting is a value that can be set by an environment variable or by a
+// command-line flag named after its key, e.g. -server-address
+type setting struct {
+	key    string
+	env    string
+	usage  string
+	secret bool
+	field  func(*Config) interface{} // Pointer to the Config field
+}
+
+var settings = []setting{
+	{key: "server_address", env: "SERVER_ADDRESS", usage: "address to listen on, host:port", field: func(c *Config) interface{} { return &c.ServerAddress }},
+	{key: "public_url", env: "PUBLIC_URL", usage: "address the public reaches the site at, e.g. https://ethics.nv.gov; required outside development", field: func(c *Config) interface{} { return &c.PublicURL }},
+	{key: "database_url", env: "DATABASE_URL", usage: "PostgreSQL connection URL; mock data when empty", secret: true, field: func(c *Config) interface{} { return &c.DatabaseURL }},
+	{key: "environment", env: "ENVIRONMENT", usage: "development, staging or production", field: func(c *Config) interface{} { return &c.Environment }},
+	{key: "template_dir", env: "TEMPLATE_DIR", usage: "templates directory, read in development instead of the built-in copy", field: func(c *Config) interface{} { return &c.TemplateDir }},
+	{key: "static_dir", env: "STATIC_DIR", usage: "static files directory, read in development instead of the built-in copy", field: func(c *Config) interface{} { return &c.StaticDir }},
+	{key: "branding_config", env: "BRANDING_CONFIG", usage: "YAML file with the branding, agency policy and any other settings", field: func(c *Config) interface{} { return &c.BrandingFile }},
+	{key: "oidc_issuer", env: "OIDC_ISSUER", usage: "OpenID Connect issuer URL; enables single sign-on", field: func(c *Config) interface{} { return &c.OIDC.Issuer }},
+	{key: "oidc_client_id", env: "OIDC_CLIENT_ID", usage: "OpenID Connect client ID", field: func(c *Config) interface{} { return &c.OIDC.ClientID }},
+	{key: "oidc_client_secret", env: "OIDC_CLIENT_SECRET", usage: "OpenID Connect client secret", secret: true, field: func(c *Config) interface{} { return &c.OIDC.ClientSecret }},
+	{key: "oidc_redirect_url", env: "OIDC_REDIRECT_URL", usage: "callback URL registered with the provider", field: func(c *Config) interface{} { return &c.OIDC.RedirectURL }},
+	{key: "oidc_provider_name", env: "OIDC_PROVIDER_NAME", usage: "name on the single sign-on button", field: func(c *Config) interface{} { return &c.OIDC.ProviderName }},
+	{key: "oidc_groups_claim", env: "OIDC_GROUPS_CLAIM", usage: "ID token claim listing the user's groups", field: func(c *Config) interface{} { return &c.OIDC.GroupsClaim }},
+	{key: "oidc_role_map", env: "OIDC_ROLE_MAP", usage: "provider groups to roles, group=role,group=role", field: func(c *Config) interface{} { return &c.OIDC.RoleMap }},
+	{key: "oidc_disable_password_login", env: "OIDC_DISABLE_PASSWORD_LOGIN", usage: "require single sign-on for every staff login", field: func(c *Config) interface{} { return &c.OIDC.DisablePasswordLogin }},
+	{key: "mfa_required_roles", env: "MFA_REQUIRED_ROLES", usage: "roles that must use a second factor, comma-separated", field: func(c *Config) interface{} { return &c.MFARequiredRoles }},
+	{key: "session_idle_timeout", env: "SESSION_IDLE_TIMEOUT", usage: "sign-out after this long without a request", field: func(c *Config) interface{} { return &c.SessionIdleTimeout }},
+	{key: "session_absolute_timeout", env: "SESSION_ABSOLUTE_TIMEOUT", usage: "sign-out this long after login", field: func(c *Config) interface{} { return &c.SessionAbsoluteTimeout }},
+	{key: "log_level", env: "LOG_LEVEL", usage: "debug, info, warn or error; debug in development and info elsewhere when empty", field: func(c *Config) interface{} { return &c.LogLevel }},
+	{key: "log_format", env: "LOG_FORMAT", usage: "json or text", field: func(c *Config) interface{} { return &c.LogFormat }},
+	{key: "metrics_address", env: "METRICS_ADDRESS", usage: "separate address to serve /metrics on, host:port", field: func(c *Config) interface{} { return &c.MetricsAddress }},
+	{key: "metrics_token", env: "METRICS_TOKEN", usage: "bearer token for /metrics on the main address", secret: true, field: func(c *Config) interface{} { return &c.MetricsToken }},
+	{key: "smtp_address", env: "SMTP_ADDRESS", usage: "SMTP relay for invitation and password reset emails, host:port; links are only shown when empty", field: func(c *Config) interface{} { return &c.SMTPAddress }},
+	{key: "smtp_username", env: "SMTP_USERNAME", usage: "user to sign in to the SMTP relay as", field: func(c *Config) interface{} { return &c.SMTPUsername }},
+	{key: "smtp_password", env: "SMTP_PASSWORD", usage: "SMTP relay password", secret: true, field: func(c *Config) interface{} { return &c.SMTPPassword }},
+	{key: "mail_from", env: "MAIL_FROM", usage: "address emails come from; the branding contact email when empty", field: func(c *Config) interface{} { return &c.MailFrom }},
+	{key: "traces_exporter", env: "OTEL_TRACES_EXPORTER", usage: "none, otlp or console", field: func(c *Config) interface{} { return &c.TracesExporter }},
+	{key: "otlp_endpoint", env: "OTEL_EXPORTER_OTLP_ENDPOINT", usage: "OpenTelemetry collector URL for OTLP/HTTP", field: func(c *Config) interface{} { return &c.OTLPEndpoint }},
+	{key: "service_name", env: "OTEL_SERVICE_NAME", usage: "service name reported with traces", field: func(c *Config) interface{} { return &c.ServiceName }},
+	{key: "trusted_proxies", env: "TRUSTED_PROXIES", usage: "reverse proxy addresses or CIDR ranges whose X-Forwarded-For is believed, comma-separated", field: func(c *Config) interface{} { return &c.TrustedProxies }},
+	{key: "csp_report_only", env: "CSP_REPORT_ONLY", usage: "report Content-Security-Policy violations without blocking", field: func(c *Config) interface{} { return &c.CSPReportOnly }},
+}
+
+func (s setting) flag() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// defaults returns the configuration before any file, variable or flag
+func defaults() *Config {
 	cfg := &Config{
-		ServerAddress: getEnv("SERVER_ADDRESS", ":8081"),
-		DatabaseURL:   os.Getenv("DATABASE_URL"),
-		Environment:   getEnv("ENVIRONMENT", "development"),
-		TemplateDir:   getEnv("TEMPLATE_DIR", "templates"),
-		StaticDir:     getEnv("STATIC_DIR", "static"),
-	}
-
-	// Load branding from YAML
-	brandingFile := getEnv("BRANDING_CONFIG", "config/branding.yaml")
-	if data, err := os.ReadFile(brandingFile); err == nil {
-		yaml.Unmarshal(data, &cfg.Branding)
-	} else {
-		// Default branding
-		cfg.Branding = Branding{
-			AgencyName:     "Nevada Commission on Ethics",
-			ShortName:      "NCOE",
-			Tagline:        "Integrity and Trust",
-			PrimaryColor:   "#003366",
-			SecondaryColor: "#C4A000",
-			ContactEmail:   "ncoe@ethics.nv.gov",
-			ContactPhone:   "(775) 687-5469",
-			Website:        "https://ethics.nv.gov",
+		ServerAddress: ":8081",
+		Environment:   "development",
+		TemplateDir:   "templates",
+		StaticDir:     "static",
+		BrandingFile:  defaultBrandingFile,
+		Branding:      DefaultBranding(),
+		Policy:        DefaultPolicy(),
+		OIDC: OIDC{
+			ProviderName: "Single Sign-On",
+			GroupsClaim:  "groups",
+			RoleMap:      map[string]string{},
+		},
+		SessionIdleTimeout:     30 * time.Minute,
+		SessionAbsoluteTimeout: 12 * time.Hour,
+		LogFormat:              "json",
+		TracesExporter:         "none",
+		OTLPEndpoint:           "http://localhost:4318",
+		ServiceName:            "ncoe",
+		sources:                make(map[string]string),
+	}
+	for _, s := range settings {
+		cfg.sources[s.key] = "default"
+	}
+	return cfg
+}
+
+// Load builds the configuration in layers, each overriding the one before:
+// defaults, the branding file, environment variables, then command-line
+// flags. It fails on anything it cannot parse and on values Validate
+// rejects, reporting every problem at once.
+func Load(args []string) (*Config, error) {
+	cfg := defaults()
+
+	fs := flag.NewFlagSet("ncoe", flag.ContinueOnError)
+	flags := make(map[string]*string)
+	for _, s := range settings {
+		flags[s.key] = fs.String(s.flag(), "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var errs []error
+
+	// The file's name comes from the later layers, so find it first
+	path, named := cfg.BrandingFile, false
+	if v := os.Getenv("BRANDING_CONFIG"); v != "" {
+		path, named = v, true
+	}
+	if explicit["branding-config"] {
+		path, named = *flags["branding_config"], true
+	}
+	if err := cfg.loadBrandingFile(path, named); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := s.set(cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+			cfg.sources[s.key] = "env " + s.env
+		}
+	}
+	for _, s := range settings {
+		if explicit[s.flag()] {
+			if err := s.set(cfg, *flags[s.key]); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.flag(), err))
+			}
+			cfg.sources[s.key] = "flag -" + s.flag()
 		}
 	}
 
-	return cfg
+	errs = append(errs, cfg.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadBrandingFile overlays the branding file on the defaults, so keys it
+// leaves out keep their default values. Besides branding and policy it may
+// set any setting but branding_config, under the setting's key. Unknown
+// keys are errors. A missing file is only an error if it was named
+// explicitly.
+func (c *Config) loadBrandingFile(path string, named bool) error {
+	c.BrandingFile = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !named {
+		c.sources["branding"] = "defaults (" + path + " not found)"
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("branding file: %w", err)
+	}
+
+	file := brandingFile{Branding: c.Branding, Policy: c.Policy}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	c.Branding, c.Policy = file.Branding, file.Policy
+	c.sources["branding"] = path
+
+	var errs []error
+	for _, s := range settings {
+		node, ok := file.Settings[s.key]
+		if !ok {
+			continue
+		}
+		delete(file.Settings, s.key)
+		if s.key == "branding_config" {
+			errs = append(errs, fmt.Errorf("%s: line %d: branding_config names this file and cannot be set in it", path, node.Line))
+			continue
+		}
+		if err := s.setNode(c, &node); err != nil {
+			errs = append(errs, fmt.Errorf("%s: line %d: %s: %w", path, node.Line, s.key, err))
+			continue
+		}
+		c.sources[s.key] = path
+	}
+	unknown := make([]string, 0, len(file.Settings))
+	for key := range file.Settings {
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		node := file.Settings[key]
+		errs = append(errs, fmt.Errorf("%s: line %d: field %s not found", path, node.Line, key))
+	}
+	return errors.Join(errs...)
+}
+
+// setNode sets the setting from a value in the branding file. Lists may be
+// written as YAML sequences and the role map as a mapping; anything else is
+// a single value, parsed as it would be from the environment.
+func (s setting) setNode(c *Config, node *yaml.Node) error {
+	switch field := s.field(c).(type) {
+	case *[]string:
+		if node.Kind == yaml.SequenceNode {
+			var list []string
+			if err := node.Decode(&list); err != nil {
+				return err
+			}
+			*field = list
+			return nil
+		}
+	case *map[string]string:
+		if node.Kind == yaml.MappingNode {
+			var roles map[string]string
+			if err := node.Decode(&roles); err != nil {
+				return err
+			}
+			*field = roles
+			return nil
+		}
+	}
+	if node.Kind != yaml.ScalarNode {
+		return errors.New("must be a single value")
+	}
+	return s.set(c, node.Value)
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// set parses a value for the setting's field
+func (s setting) set(c *Config, value string) error {
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = value
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*field = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30m or 12h", value)
+		}
+		*field = d
+	case *[]string:
+		*field = splitList(value)
+	case *map[string]string:
+		roles, err := parseRoleMap(value)
+		if err != nil {
+			return err
+		}
+		*field = roles
+	default:
+		panic("config: unsupported setting type for " + s.key)
+	}
+	return nil
+}
+
+// get formats the setting's current value as it would be written
+func (s setting) get(c *Config) string {
+	switch field := s.field(c).(type) {
+	case *string:
+		return *field
+	case *bool:
+		return strconv.FormatBool(*field)
+	case *time.Duration:
+		return field.String()
+	case *[]string:
+		return strings.Join(*field, ",")
+	case *map[string]string:
+		pairs := make([]string, 0, len(*field))
+		for group, role := range *field {
+			pairs = append(pairs, group+"="+role)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	}
+	return ""
+}
+
+// parseRoleMap parses "group=role,group=role" pairs. Group names may contain
+// spaces.
+func parseRoleMap(value string) (map[string]string, error) {
+	roles := make(map[string]string)
+	for _, pair := range splitList(value) {
+		group, role, ok := strings.Cut(pair, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || role == "" {
+			return nil, fmt.Errorf("%q is not group=role", pair)
+		}
+		roles[group] = role
+	}
+	return roles, nil
+}
+
+// splitList splits a comma-separated value into trimmed, non-empty entries
+func splitList(value string) []string {
+	var result []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
 	}
-	return defaultValue
+	return result
 }
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"ncoe/internal/domain"
+)
+
+// Policy is the agency's case-handling policy, read from the case_prefixes
+// and deadlines sections of the branding file. Another commission adopts
+// the system by changing these rather than the code.
+type Policy struct {
+	CasePrefixes CasePrefixes `yaml:"case_prefixes"`
+	Deadlines    Deadlines    `yaml:"deadlines"`
+}
+
+// CasePrefixes start each case type's case numbers, e.g. "AO" in AO-2024-042
+type CasePrefixes struct {
+	AdvisoryOpinion      string `yaml:"advisory_opinion"`
+	EthicsComplaint      string `yaml:"ethics_complaint"`
+	EthicsAcknowledgment string `yaml:"ethics_acknowledgment"`
+	PublicRecordsRequest string `yaml:"public_records_request"`
+}
+
+// Deadlines are the business days allowed to respond to each case type and
+// the reminder schedule in days before a deadline. A type with no response
+// time gets no deadline.
+type Deadlines struct {
+	AdvisoryOpinionResponse      int   `yaml:"advisory_opinion_response"`
+	EthicsComplaintResponse      int   `yaml:"ethics_complaint_response"`
+	EthicsAcknowledgmentResponse int   `yaml:"ethics_acknowledgment_response"`
+	PRRResponse                  int   `yaml:"prr_response"`
+	ReminderDays                 []int `yaml:"reminder_days"`
+}
+
+// maxPolicyDays bounds response times and reminders, so a typo such as 450
+// for 45 is caught at startup
+const maxPolicyDays = 365
+
+var prefixPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{0,7}$`)
+
+// DefaultPolicy is the Nevada Commission on Ethics policy, used for anything
+// the branding file leaves out
+func DefaultPolicy() Policy {
+	return Policy{
+		CasePrefixes: CasePrefixes{
+			AdvisoryOpinion:      "AO",
+			EthicsComplaint:      "EC",
+			EthicsAcknowledgment: "EA",
+			PublicRecordsRequest: "PRR",
+		},
+		Deadlines: Deadlines{
+			AdvisoryOpinionResponse: 45,
+			PRRResponse:             5,
+			ReminderDays:            []int{30, 14, 7, 3, 1},
+		},
+	}
+}
+
+// Prefixes returns the case number prefix for each case type
+func (p Policy) Prefixes() map[domain.CaseType]string {
+	return map[domain.CaseType]string{
+		domain.CaseTypeAdvisoryOpinion:      p.CasePrefixes.AdvisoryOpinion,
+		domain.CaseTypeEthicsComplaint:      p.CasePrefixes.EthicsComplaint,
+		domain.CaseTypeEthicsAcknowledgment: p.CasePrefixes.EthicsAcknowledgment,
+		domain.CaseTypePublicRecordsRequest: p.CasePrefixes.PublicRecordsRequest,
+	}
+}
+
+// ResponseDays returns the business days allowed to respond to each case
+// type that has a deadline
+func (p Policy) ResponseDays() map[domain.CaseType]int {
+	days := make(map[domain.CaseType]int)
+	for t, n := range map[domain.CaseType]int{
+		domain.CaseTypeAdvisoryOpinion:      p.Deadlines.AdvisoryOpinionResponse,
+		domain.CaseTypeEthicsComplaint:      p.Deadlines.EthicsComplaintResponse,
+		domain.CaseTypeEthicsAcknowledgment: p.Deadlines.EthicsAcknowledgmentResponse,
+		domain.CaseTypePublicRecordsRequest: p.Deadlines.PRRResponse,
+	} {
+		if n > 0 {
+			days[t] = n
+		}
+	}
+	return days
+}
+
+// Validate reports every problem with the policy, naming the branding file
+// keys to fix
+func (p Policy) Validate() error {
+	var errs []error
+
+	prefixes := []struct{ key, value string }{
+		{"advisory_opinion", p.CasePrefixes.AdvisoryOpinion},
+		{"ethics_complaint", p.CasePrefixes.EthicsComplaint},
+		{"ethics_acknowledgment", p.CasePrefixes.EthicsAcknowledgment},
+		{"public_records_request", p.CasePrefixes.PublicRecordsRequest},
+	}
+	usedBy := make(map[string]string)
+	for _, prefix := range prefixes {
+		if !prefixPattern.MatchString(prefix.value) {
+			errs = append(errs, fmt.Errorf("case_prefixes.%s: %q must be 1 to 8 capital letters or digits, starting with a letter", prefix.key, prefix.value))
+			continue
+		}
+		if other, ok := usedBy[prefix.value]; ok {
+			errs = append(errs, fmt.Errorf("case_prefixes.%s: %q is already the prefix for %s", prefix.key, prefix.value, other))
+			continue
+		}
+		usedBy[prefix.value] = prefix.key
+	}
+
+	responses := []struct {
+		key  string
+		days int
+	}{
+		{"advisory_opinion_response", p.Deadlines.AdvisoryOpinionResponse},
+		{"ethics_complaint_response", p.Deadlines.EthicsComplaintResponse},
+		{"ethics_acknowledgment_response", p.Deadlines.EthicsAcknowledgmentResponse},
+		{"prr_response", p.Deadlines.PRRResponse},
+	}
+	for _, r := range responses {
+		if r.days < 0 || r.days > maxPolicyDays {
+			errs = append(errs, fmt.Errorf("deadlines.%s: %d must be between 0 (no deadline) and %d business days", r.key, r.days, maxPolicyDays))
+		}
+	}
+
+	seen := make(map[int]bool)
+	for _, days := range p.Deadlines.ReminderDays {
+		if days < 1 || days > maxPolicyDays {
+			errs = append(errs, fmt.Errorf("deadlines.reminder_days: %d must be between 1 and %d days", days, maxPolicyDays))
+		} else if seen[days] {
+			errs = append(errs, fmt.Errorf("deadlines.reminder_days: %d is listed more than once", days))
+		}
+		seen[days] = true
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ncoe/internal/domain"
+)
+
+func TestDefaultPolicyIsValid(t *testing.T) {
+	if err := DefaultPolicy().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Policy)
+		want   []string
+	}{
+		{
+			name:   "empty prefix",
+			change: func(p *Policy) { p.CasePrefixes.EthicsComplaint = "" },
+			want:   []string{`case_prefixes.ethics_complaint: ""`},
+		},
+		{
+			name:   "lowercase prefix",
+			change: func(p *Policy) { p.CasePrefixes.AdvisoryOpinion = "ao" },
+			want:   []string{`case_prefixes.advisory_opinion: "ao" must be 1 to 8 capital letters`},
+		},
+		{
+			name:   "prefix with a separator",
+			change: func(p *Policy) { p.CasePrefixes.PublicRecordsRequest = "PR-R" },
+			want:   []string{"case_prefixes.public_records_request"},
+		},
+		{
+			name:   "shared prefix",
+			change: func(p *Policy) { p.CasePrefixes.EthicsAcknowledgment = "EC" },
+			want:   []string{`case_prefixes.ethics_acknowledgment: "EC" is already the prefix for ethics_complaint`},
+		},
+		{
+			name:   "negative response time",
+			change: func(p *Policy) { p.Deadlines.PRRResponse = -5 },
+			want:   []string{"deadlines.prr_response: -5"},
+		},
+		{
+			name:   "response time over a year",
+			change: func(p *Policy) { p.Deadlines.AdvisoryOpinionResponse = 450 },
+			want:   []string{"deadlines.advisory_opinion_response: 450"},
+		},
+		{
+			name:   "reminder on the due date",
+			change: func(p *Policy) { p.Deadlines.ReminderDays = []int{7, 0} },
+			want:   []string{"deadlines.reminder_days: 0"},
+		},
+		{
+			name:   "repeated reminder",
+			change: func(p *Policy) { p.Deadlines.ReminderDays = []int{7, 3, 7} },
+			want:   []string{"deadlines.reminder_days: 7 is listed more than once"},
+		},
+		{
+			name: "every problem at once",
+			change: func(p *Policy) {
+				p.CasePrefixes.AdvisoryOpinion = ""
+				p.Deadlines.PRRResponse = -1
+				p.Deadlines.ReminderDays = []int{400}
+			},
+			want: []string{"case_prefixes.advisory_opinion", "deadlines.prr_response", "deadlines.reminder_days: 400"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultPolicy()
+			tt.change(&p)
+			err := p.Validate()
+			if err == nil {
+				t.Fatal("Validate succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "branding.yaml")
+	yaml := `agency_name: "Example Ethics Board"
+case_prefixes:
+  advisory_opinion: "RFO"
+deadlines:
+  ethics_complaint_response: 60
+  reminder_days: [10, 2]
+`
+	if err := os.WriteFile(file, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	clearEnv(t)
+	t.Setenv("BRANDING_CONFIG", file)
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Branding.AgencyName != "Example Ethics Board" {
+		t.Errorf("agency name %q", cfg.Branding.AgencyName)
+	}
+	if err := cfg.Policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys the file leaves out keep their defaults
+	wantPrefixes := map[domain.CaseType]string{
+		domain.CaseTypeAdvisoryOpinion:      "RFO",
+		domain.CaseTypeEthicsComplaint:      "EC",
+		domain.CaseTypeEthicsAcknowledgment: "EA",
+		domain.CaseTypePublicRecordsRequest: "PRR",
+	}
+	if got := cfg.Policy.Prefixes(); !reflect.DeepEqual(got, wantPrefixes) {
+		t.Errorf("prefixes %v, want %v", got, wantPrefixes)
+	}
+	wantDays := map[domain.CaseType]int{
+		domain.CaseTypeAdvisoryOpinion:      45,
+		domain.CaseTypeEthicsComplaint:      60,
+		domain.CaseTypePublicRecordsRequest: 5,
+	}
+	if got := cfg.Policy.ResponseDays(); !reflect.DeepEqual(got, wantDays) {
+		t.Errorf("response days %v, want %v", got, wantDays)
+	}
+	if got := cfg.Policy.Deadlines.ReminderDays; !reflect.DeepEqual(got, []int{10, 2}) {
+		t.Errorf("reminder days %v, want [10 2]", got)
+	}
+}
@@ -0,0 +1,24 @@
+// Package csp reads the Content-Security-Policy nonce back from a response,
+// for code that writes pages but does not set the policy, such as the
+// template renderer
+package csp
+
+import (
+	"net/http"
+	"regexp"
+)
+
+var nonceSource = regexp.MustCompile(`'nonce-([A-Za-z0-9+/=_-]+)'`)
+
+// Nonce returns the nonce of the policy already set on a response, enforced
+// or report-only. Returns empty string if there is none.
+func Nonce(h http.Header) string {
+	policy := h.Get("Content-Security-Policy")
+	if policy == "" {
+		policy = h.Get("Content-Security-Policy-Report-Only")
+	}
+	if m := nonceSource.FindStringSubmatch(policy); m != nil {
+		return m[1]
+	}
+	return ""
+}
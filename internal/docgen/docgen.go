@@ -0,0 +1,135 @@
+// Package docgen lays out simple reports (a cover, headings, paragraphs and
+// tables) and writes them as PDF or as Word (DOCX) documents. Output is
+// reproducible: the same document always produces the same bytes, so a
+// report regenerated from unchanged data can be compared with the original.
+package docgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PDFContentType is the media type of PDF output
+	PDFContentType = "application/pdf"
+	// DOCXContentType is the media type of Word output
+	DOCXContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+// defaultAccent is used when a document has no valid accent color
+const defaultAccent = "#003366"
+
+// Document is a report to be written as PDF or DOCX
+type Document struct {
+	Title       string
+	Subtitle    string
+	Author      string   // Shown in page footers and the document properties
+	CoverLines  []string // Printed under the title, e.g. the agency's address
+	AccentColor string   // "#RRGGBB", for the cover band, headings and table headers
+	Blocks      []Block
+}
+
+// Block is one element of a document's body: a Heading, Paragraph or Table
+type Block interface {
+	block()
+}
+
+// Heading starts a section
+type Heading struct {
+	Text string
+}
+
+// Paragraph is body text. Blank lines separate paragraphs and single
+// newlines break lines.
+type Paragraph struct {
+	Text string
+}
+
+// Table is a grid with a header row. Columns of numbers are right-aligned.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// columns returns the number of columns in the widest row
+func (t Table) columns() int {
+	cols := len(t.Header)
+	for _, row := range t.Rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	return cols
+}
+
+func (Heading) block()   {}
+func (Paragraph) block() {}
+func (Table) block()     {}
+
+// rgb is a color with components from 0 to 255
+type rgb struct {
+	r, g, b uint8
+}
+
+// accent parses the document's accent color, falling back to the default
+func (d *Document) accent() rgb {
+	if c, err := parseColor(d.AccentColor); err == nil {
+		return c
+	}
+	c, _ := parseColor(defaultAccent)
+	return c
+}
+
+func parseColor(s string) (rgb, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return rgb{}, fmt.Errorf("docgen: color %q is not #RRGGBB", s)
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return rgb{}, fmt.Errorf("docgen: color %q is not #RRGGBB", s)
+	}
+	return rgb{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+func (c rgb) hex() string {
+	return fmt.Sprintf("%02X%02X%02X", c.r, c.g, c.b)
+}
+
+// paragraphs splits paragraph text on blank lines, dropping empty ones
+func paragraphs(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var result []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// numericColumns reports, for each column, whether every non-empty body
+// cell in it is a number
+func numericColumns(t Table, cols int) []bool {
+	numeric := make([]bool, cols)
+	for i := range numeric {
+		for _, row := range t.Rows {
+			if i < len(row) && row[i] != "" {
+				if !isNumber(row[i]) {
+					numeric[i] = false
+					break
+				}
+				numeric[i] = true
+			}
+		}
+	}
+	return numeric
+}
+
+// isNumber reports whether a table cell holds a number, such as "12",
+// "-3", "4.5" or "85%"
+func isNumber(s string) bool {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, ",", ""), "%")
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
@@ -0,0 +1,208 @@
+package docgen
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func sampleDocument() *Document {
+	return &Document{
+		Title:       "Annual Report — Fiscal Year 2025",
+		Subtitle:    "July 1, 2024 to June 30, 2025",
+		Author:      "Nevada Commission on Ethics",
+		CoverLines:  []string{"704 W Nye Ln, Carson City, NV 89703"},
+		AccentColor: "#1B4D89",
+		Blocks: []Block{
+			Heading{Text: "Introduction"},
+			Paragraph{Text: "The Commission (the \"NCOE\") interprets NRS 281A.\n\nA second paragraph."},
+			Table{
+				Header: []string{"Disposition", "Complaints"},
+				Rows:   [][]string{{"Dismissed", "4"}, {"R&D <stipulated>", "1"}},
+			},
+		},
+	}
+}
+
+func TestWrap(t *testing.T) {
+	// "Hello world" is 52.8 points wide at 11 points
+	if got := wrap(regular, 11, "Hello world", 60); len(got) != 1 {
+		t.Errorf("short text wrapped into %d lines", len(got))
+	}
+	got := wrap(regular, 11, "Hello world", 40)
+	if len(got) != 2 || string(got[0]) != "Hello" || string(got[1]) != "world" {
+		t.Errorf("wrap at 40pt = %q, want Hello / world", got)
+	}
+	if got := wrap(regular, 11, "one\ntwo", 400); len(got) != 2 {
+		t.Errorf("newline did not break: %q", got)
+	}
+	// A word longer than the line is split rather than overflowing
+	for _, ln := range wrap(regular, 11, strings.Repeat("W", 40), 100) {
+		if w := regular.width(ln, 11); w > 100 {
+			t.Errorf("line %q is %.1fpt wide, over 100", ln, w)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	if got := encode("A–B “é” ✓"); !bytes.Equal(got, []byte{'A', 0x96, 'B', ' ', 0x93, 0xe9, 0x94, ' ', '?'}) {
+		t.Errorf("encode = % x", got)
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, sampleDocument()); err != nil {
+		t.Fatalf("WritePDF: %v", err)
+	}
+	pdf := buf.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("output is not framed as a PDF")
+	}
+	checkXref(t, pdf)
+
+	for _, want := range []string{
+		"(Introduction) Tj",
+		`(The Commission \(the "NCOE"\) interprets NRS 281A.) Tj`,
+		"(R&D <stipulated>) Tj",
+		"(Page 1 of 1) Tj",
+		"/BaseFont /Helvetica-Bold",
+		"0.106 0.302 0.537 rg", // The accent color
+	} {
+		if !strings.Contains(pdf, want) {
+			t.Errorf("PDF does not contain %q", want)
+		}
+	}
+	if strings.Contains(pdf, "CreationDate") {
+		t.Error("PDF records its creation date")
+	}
+}
+
+// checkXref verifies that every cross-reference entry points at its object
+func checkXref(t *testing.T, pdf string) {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	start, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(pdf[start:], "xref\n") {
+		t.Fatalf("startxref %d does not point at the xref table", start)
+	}
+	lines := strings.Split(pdf[start:], "\n")
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	for i := 1; i < count; i++ {
+		off, _ := strconv.Atoi(lines[2+i][:10])
+		if want := fmt.Sprintf("%d 0 obj", i); !strings.HasPrefix(pdf[off:], want) {
+			t.Errorf("xref entry %d points at %q", i, pdf[off:off+10])
+		}
+	}
+}
+
+func TestWritePDFPages(t *testing.T) {
+	doc := sampleDocument()
+	rows := make([][]string, 120)
+	for i := range rows {
+		rows[i] = []string{fmt.Sprintf("Row %d", i+1), strconv.Itoa(i)}
+	}
+	doc.Blocks = append(doc.Blocks, Table{Header: []string{"Row", "Value"}, Rows: rows})
+
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, doc); err != nil {
+		t.Fatalf("WritePDF: %v", err)
+	}
+	pdf := buf.String()
+	pages := strings.Count(pdf, "/Type /Page ")
+	if pages < 3 {
+		t.Fatalf("120 rows fit on %d pages", pages)
+	}
+	if !strings.Contains(pdf, fmt.Sprintf("/Count %d", pages)) || !strings.Contains(pdf, fmt.Sprintf("(Page %d of %d) Tj", pages, pages)) {
+		t.Error("page tree or footers do not match the page count")
+	}
+	// The header row repeats on every page the table runs onto
+	if n := strings.Count(pdf, "(Value) Tj"); n < pages-1 {
+		t.Errorf("table header drawn %d times over %d pages", n, pages)
+	}
+	checkXref(t, pdf)
+}
+
+func TestWriteDOCX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDOCX(&buf, sampleDocument()); err != nil {
+		t.Fatalf("WriteDOCX: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("not a zip archive: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(body)
+
+		// Every part must be well-formed XML
+		dec := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("%s is not well-formed: %v", f.Name, err)
+				break
+			}
+		}
+	}
+
+	doc := parts["word/document.xml"]
+	for _, want := range []string{
+		`<w:pStyle w:val="Title"/></w:pPr><w:r><w:t xml:space="preserve">Annual Report — Fiscal Year 2025</w:t>`,
+		`<w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t xml:space="preserve">Introduction</w:t>`,
+		`A second paragraph.`,
+		`R&amp;D &lt;stipulated&gt;`,
+		`<w:tblHeader/>`,
+		`<w:shd w:val="clear" w:color="auto" w:fill="1B4D89"/>`,
+		`<w:jc w:val="right"/>`,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document.xml does not contain %q", want)
+		}
+	}
+	if !strings.Contains(parts["word/footer1.xml"], "Nevada Commission on Ethics") {
+		t.Error("footer does not name the author")
+	}
+	if !strings.Contains(parts["docProps/core.xml"], "<dc:creator>Nevada Commission on Ethics</dc:creator>") {
+		t.Error("core properties do not name the author")
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	for name, write := range map[string]func(io.Writer, *Document) error{"pdf": WritePDF, "docx": WriteDOCX} {
+		var a, b bytes.Buffer
+		if err := write(&a, sampleDocument()); err != nil {
+			t.Fatal(err)
+		}
+		if err := write(&b, sampleDocument()); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a.Bytes(), b.Bytes()) {
+			t.Errorf("%s output differs between runs", name)
+		}
+	}
+}
+
+func TestAccentFallback(t *testing.T) {
+	for _, color := range []string{"", "navy", "#12345", "#GGGGGG"} {
+		doc := &Document{AccentColor: color}
+		if got := doc.accent().hex(); got != "003366" {
+			t.Errorf("accent for %q = %s, want the default", color, got)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package docgen
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"ncoe/internal/ooxml"
+)
+
+const wordNS = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+
+// WriteDOCX writes the document as a Word document with the same content and
+// styling as the PDF: built-in Title and Heading 1 styles, tables with a
+// repeating header row, and a footer with the author and page numbers.
+func WriteDOCX(w io.Writer, doc *Document) error {
+	body, err := docxBody(doc)
+	if err != nil {
+		return err
+	}
+	parts := []ooxml.Part{
+		{Name: "[Content_Types].xml", Body: []byte(docxContentTypes)},
+		{Name: "_rels/.rels", Body: []byte(docxRootRels)},
+		{Name: "docProps/core.xml", Body: docxCore(doc)},
+		{Name: "word/document.xml", Body: body},
+		{Name: "word/_rels/document.xml.rels", Body: []byte(docxDocumentRels)},
+		{Name: "word/styles.xml", Body: docxStyles(doc.accent())},
+		{Name: "word/footer1.xml", Body: docxFooter(doc)},
+	}
+	return ooxml.Write(w, parts)
+}
+
+func docxBody(doc *Document) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<w:document %s><w:body>`, wordNS)
+	docxParagraph(&b, "Title", doc.Title)
+	if doc.Subtitle != "" {
+		docxParagraph(&b, "Subtitle", doc.Subtitle)
+	}
+	for _, s := range doc.CoverLines {
+		docxParagraph(&b, "CoverLine", s)
+	}
+	accent := doc.accent()
+	for _, block := range doc.Blocks {
+		switch block := block.(type) {
+		case Heading:
+			docxParagraph(&b, "Heading1", block.Text)
+		case Paragraph:
+			for _, p := range paragraphs(block.Text) {
+				docxParagraph(&b, "", p)
+			}
+		case Table:
+			docxTable(&b, block, accent)
+		default:
+			return nil, fmt.Errorf("docgen: unsupported block %T", block)
+		}
+	}
+	b.WriteString(`<w:sectPr><w:footerReference w:type="default" r:id="rId2"/>`)
+	b.WriteString(`<w:pgSz w:w="12240" w:h="15840"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="720" w:footer="576" w:gutter="0"/></w:sectPr>`)
+	b.WriteString(`</w:body></w:document>`)
+	return b.Bytes(), nil
+}
+
+// docxParagraph writes a paragraph in the given style; newlines in the text
+// become line breaks
+func docxParagraph(b *bytes.Buffer, style, text string) {
+	b.WriteString(`<w:p>`)
+	if style != "" {
+		fmt.Fprintf(b, `<w:pPr><w:pStyle w:val="%s"/></w:pPr>`, style)
+	}
+	docxRun(b, "", text)
+	b.WriteString(`</w:p>`)
+}
+
+// docxRun writes text with optional run properties
+func docxRun(b *bytes.Buffer, props, text string) {
+	b.WriteString(`<w:r>`)
+	if props != "" {
+		fmt.Fprintf(b, `<w:rPr>%s</w:rPr>`, props)
+	}
+	for i, ln := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if i > 0 {
+			b.WriteString(`<w:br/>`)
+		}
+		b.WriteString(`<w:t xml:space="preserve">`)
+		xml.EscapeText(b, []byte(ln))
+		b.WriteString(`</w:t>`)
+	}
+	b.WriteString(`</w:r>`)
+}
+
+func docxTable(b *bytes.Buffer, t Table, accent rgb) {
+	b.WriteString(`<w:tbl><w:tblPr><w:tblW w:w="5000" w:type="pct"/>`)
+	fmt.Fprintf(b, `<w:tblBorders><w:bottom w:val="single" w:sz="4" w:color="%s"/><w:insideH w:val="single" w:sz="4" w:color="%s"/></w:tblBorders>`, rule.hex(), rule.hex())
+	b.WriteString(`<w:tblCellMar><w:top w:w="60" w:type="dxa"/><w:left w:w="100" w:type="dxa"/><w:bottom w:w="60" w:type="dxa"/><w:right w:w="100" w:type="dxa"/></w:tblCellMar>`)
+	b.WriteString(`</w:tblPr>`)
+	numeric := numericColumns(t, t.columns())
+	align := func(i int) string {
+		if numeric[i] {
+			return `<w:jc w:val="right"/>`
+		}
+		return ""
+	}
+	if len(t.Header) > 0 {
+		b.WriteString(`<w:tr><w:trPr><w:tblHeader/></w:trPr>`)
+		for i, h := range t.Header {
+			fmt.Fprintf(b, `<w:tc><w:tcPr><w:shd w:val="clear" w:color="auto" w:fill="%s"/></w:tcPr><w:p><w:pPr><w:spacing w:after="0"/>%s</w:pPr>`, accent.hex(), align(i))
+			docxRun(b, `<w:b/><w:color w:val="FFFFFF"/><w:sz w:val="20"/>`, h)
+			b.WriteString(`</w:p></w:tc>`)
+		}
+		b.WriteString(`</w:tr>`)
+	}
+	for _, row := range t.Rows {
+		b.WriteString(`<w:tr><w:trPr><w:cantSplit/></w:trPr>`)
+		for i, c := range row {
+			fmt.Fprintf(b, `<w:tc><w:p><w:pPr><w:spacing w:after="0"/>%s</w:pPr>`, align(i))
+			docxRun(b, `<w:sz w:val="20"/>`, c)
+			b.WriteString(`</w:p></w:tc>`)
+		}
+		b.WriteString(`</w:tr>`)
+	}
+	// Word requires a paragraph between a table and what follows it
+	b.WriteString(`</w:tbl><w:p/>`)
+}
+
+func docxStyles(accent rgb) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<w:styles %s>`, wordNS)
+	fmt.Fprintf(&b, `<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Arial" w:hAnsi="Arial" w:cs="Arial"/><w:color w:val="%s"/><w:sz w:val="22"/></w:rPr></w:rPrDefault>`, black.hex())
+	b.WriteString(`<w:pPrDefault><w:pPr><w:spacing w:after="160" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>`)
+	b.WriteString(`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>`)
+	fmt.Fprintf(&b, `<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:spacing w:after="80"/></w:pPr><w:rPr><w:b/><w:color w:val="%s"/><w:sz w:val="44"/></w:rPr></w:style>`, accent.hex())
+	fmt.Fprintf(&b, `<w:style w:type="paragraph" w:styleId="Subtitle"><w:name w:val="Subtitle"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:spacing w:after="240"/></w:pPr><w:rPr><w:color w:val="%s"/><w:sz w:val="26"/></w:rPr></w:style>`, gray.hex())
+	fmt.Fprintf(&b, `<w:style w:type="paragraph" w:customStyle="1" w:styleId="CoverLine"><w:name w:val="Cover Line"/><w:basedOn w:val="Normal"/><w:pPr><w:spacing w:after="0"/></w:pPr><w:rPr><w:color w:val="%s"/><w:sz w:val="20"/></w:rPr></w:style>`, gray.hex())
+	fmt.Fprintf(&b, `<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="%s"/></w:pBdr><w:spacing w:before="360" w:after="120"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:color w:val="%s"/><w:sz w:val="28"/></w:rPr></w:style>`, accent.hex(), accent.hex())
+	fmt.Fprintf(&b, `<w:style w:type="paragraph" w:styleId="Footer"><w:name w:val="footer"/><w:basedOn w:val="Normal"/><w:pPr><w:tabs><w:tab w:val="right" w:pos="9360"/></w:tabs><w:spacing w:after="0"/></w:pPr><w:rPr><w:color w:val="%s"/><w:sz w:val="18"/></w:rPr></w:style>`, gray.hex())
+	b.WriteString(`</w:styles>`)
+	return b.Bytes()
+}
+
+func docxFooter(doc *Document) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<w:ftr %s><w:p><w:pPr><w:pStyle w:val="Footer"/></w:pPr>`, wordNS)
+	docxRun(&b, "", doc.Author)
+	b.WriteString(`<w:r><w:tab/><w:t xml:space="preserve">Page </w:t></w:r>`)
+	docxField(&b, "PAGE")
+	b.WriteString(`<w:r><w:t xml:space="preserve"> of </w:t></w:r>`)
+	docxField(&b, "NUMPAGES")
+	b.WriteString(`</w:p></w:ftr>`)
+	return b.Bytes()
+}
+
+func docxField(b *bytes.Buffer, instr string) {
+	fmt.Fprintf(b, `<w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText xml:space="preserve"> %s </w:instrText></w:r><w:r><w:fldChar w:fldCharType="separate"/></w:r><w:r><w:t>1</w:t></w:r><w:r><w:fldChar w:fldCharType="end"/></w:r>`, instr)
+}
+
+// docxCore holds the title and author; creation and modification dates are
+// left out so the output does not depend on when it was written
+func docxCore(doc *Document) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">`)
+	b.WriteString(`<dc:title>`)
+	xml.EscapeText(&b, []byte(doc.Title))
+	b.WriteString(`</dc:title><dc:creator>`)
+	xml.EscapeText(&b, []byte(doc.Author))
+	b.WriteString(`</dc:creator></cp:coreProperties>`)
+	return b.Bytes()
+}
+
+const docxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/word/footer1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.footer+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`</Types>`
+
+const docxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`</Relationships>`
+
+const docxDocumentRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/footer" Target="footer1.xml"/>` +
+	`</Relationships>`
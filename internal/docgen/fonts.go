@@ -0,0 +1,88 @@
+package docgen
+
+// PDF output uses the standard Helvetica fonts, which every reader has, with
+// the WinAnsi encoding. The widths below are from Adobe's font metrics, in
+// thousandths of the font size, for the printable ASCII characters.
+
+type font struct {
+	name   string // Resource name in the page content
+	base   string // PostScript name
+	widths [95]int
+}
+
+var (
+	regular = &font{name: "F1", base: "Helvetica", widths: [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 to 9
+		278, 278, 584, 584, 584, 556, 1015, // : to @
+		667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A to M
+		722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N to Z
+		278, 278, 278, 469, 556, 333, // [ to `
+		556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a to m
+		556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n to z
+		334, 260, 334, 584, // { to ~
+	}}
+	bold = &font{name: "F2", base: "Helvetica-Bold", widths: [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556,
+		333, 333, 584, 584, 584, 611, 975,
+		722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833,
+		722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611,
+		333, 278, 333, 584, 556, 333,
+		556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889,
+		611, 611, 611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500,
+		389, 280, 389, 584,
+	}}
+)
+
+// winAnsi maps the punctuation that report text commonly contains to its
+// WinAnsi code; Latin-1 characters keep their own codes
+var winAnsi = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// widthOf gives the width of the non-ASCII WinAnsi characters
+var widthOf = map[byte]int{
+	0x80: 556, 0x85: 1000, 0x91: 222, 0x92: 222, 0x93: 333, 0x94: 333,
+	0x95: 350, 0x96: 556, 0x97: 1000, 0x99: 1000,
+}
+
+// encode converts text to WinAnsi bytes, replacing characters the encoding
+// lacks with "?"
+func encode(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == ' ':
+			b = append(b, ' ')
+		case r >= 0x20 && r < 0x7f:
+			b = append(b, byte(r))
+		case r >= 0xa1 && r <= 0xff:
+			b = append(b, byte(r))
+		default:
+			if c, ok := winAnsi[r]; ok {
+				b = append(b, c)
+			} else {
+				b = append(b, '?')
+			}
+		}
+	}
+	return b
+}
+
+// width returns the width of encoded text in points
+func (f *font) width(text []byte, size float64) float64 {
+	total := 0
+	for _, c := range text {
+		switch {
+		case c >= 0x20 && c < 0x7f:
+			total += f.widths[c-0x20]
+		case widthOf[c] > 0:
+			total += widthOf[c]
+		default:
+			total += 556 // Latin-1 letters are close to the digit width
+		}
+	}
+	return float64(total) * size / 1000
+}
@@ -0,0 +1,357 @@
+package docgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// Page geometry, in points, for US Letter with one-inch margins
+const (
+	pageWidth    = 612.0
+	pageHeight   = 792.0
+	margin       = 72.0
+	contentWidth = pageWidth - 2*margin
+	bottom       = 64.0 // Lowest line of body text, above the footer
+	footerY      = 40.0
+	coverHeight  = 140.0
+
+	bodySize     = 11.0
+	bodyLeading  = 15.0
+	headingSize  = 14.0
+	tableSize    = 10.0
+	tableLeading = 13.0
+	cellPad      = 5.0
+)
+
+var (
+	black = rgb{0x21, 0x25, 0x29}
+	gray  = rgb{0x6C, 0x75, 0x7D}
+	rule  = rgb{0xCE, 0xD4, 0xDA}
+	white = rgb{0xFF, 0xFF, 0xFF}
+)
+
+// WritePDF writes the document as a PDF. Pages are US Letter with a footer
+// naming the author and the page number.
+func WritePDF(w io.Writer, doc *Document) error {
+	l := &pdfLayout{doc: doc, accent: doc.accent()}
+	l.cover()
+	for _, b := range doc.Blocks {
+		switch b := b.(type) {
+		case Heading:
+			l.heading(b.Text)
+		case Paragraph:
+			l.paragraph(b.Text)
+		case Table:
+			l.table(b)
+		default:
+			return fmt.Errorf("docgen: unsupported block %T", b)
+		}
+	}
+	l.footers()
+	return l.write(w)
+}
+
+// pdfLayout flows blocks onto pages. y is the top of the next line,
+// measured up from the bottom of the page as PDF does.
+type pdfLayout struct {
+	doc    *Document
+	accent rgb
+	pages  []*bytes.Buffer
+	page   *bytes.Buffer
+	y      float64
+}
+
+func (l *pdfLayout) newPage() {
+	l.page = &bytes.Buffer{}
+	l.pages = append(l.pages, l.page)
+	l.y = pageHeight - margin
+}
+
+// fits starts a new page unless h points remain above the footer
+func (l *pdfLayout) fits(h float64) {
+	if l.y-h < bottom {
+		l.newPage()
+	}
+}
+
+// atTop reports whether nothing has been placed on the current page yet
+func (l *pdfLayout) atTop() bool {
+	return l.y == pageHeight-margin
+}
+
+func (l *pdfLayout) fill(c rgb, x, y, w, h float64) {
+	fmt.Fprintf(l.page, "%s rg %.2f %.2f %.2f %.2f re f\n", c.pdf(), x, y, w, h)
+}
+
+func (l *pdfLayout) line(c rgb, x1, y1, x2, y2 float64) {
+	fmt.Fprintf(l.page, "%s RG 0.75 w %.2f %.2f m %.2f %.2f l S\n", c.pdf(), x1, y1, x2, y2)
+}
+
+func (l *pdfLayout) text(f *font, size float64, c rgb, x, y float64, s []byte) {
+	fmt.Fprintf(l.page, "BT /%s %.1f Tf %s rg %.2f %.2f Td %s Tj ET\n", f.name, size, c.pdf(), x, y, pdfString(s))
+}
+
+// lines writes wrapped lines from the top of the next line downwards
+func (l *pdfLayout) lines(f *font, size, leading float64, c rgb, text string) {
+	for _, ln := range wrap(f, size, text, contentWidth) {
+		l.fits(leading)
+		l.text(f, size, c, margin, l.y-size, ln)
+		l.y -= leading
+	}
+}
+
+func (l *pdfLayout) cover() {
+	l.newPage()
+	l.fill(l.accent, 0, pageHeight-coverHeight, pageWidth, coverHeight)
+	y := pageHeight - 62
+	for _, ln := range wrap(bold, 22, l.doc.Title, contentWidth) {
+		l.text(bold, 22, white, margin, y, ln)
+		y -= 26
+	}
+	if l.doc.Subtitle != "" {
+		l.text(regular, 13, white, margin, y-2, encode(l.doc.Subtitle))
+	}
+	l.y = pageHeight - coverHeight - 24
+	for _, s := range l.doc.CoverLines {
+		l.lines(regular, 10, 13, gray, s)
+	}
+	l.y -= 12
+}
+
+func (l *pdfLayout) heading(text string) {
+	// Keep a heading with at least two lines of what follows it
+	need := 24 + headingSize + 2*bodyLeading
+	if l.y-need < bottom {
+		l.newPage()
+	} else if !l.atTop() {
+		l.y -= 12
+	}
+	l.lines(bold, headingSize, 18, l.accent, text)
+	l.line(l.accent, margin, l.y+2, margin+contentWidth, l.y+2)
+	l.y -= 8
+}
+
+func (l *pdfLayout) paragraph(text string) {
+	for _, p := range paragraphs(text) {
+		l.lines(regular, bodySize, bodyLeading, black, p)
+		l.y -= 8
+	}
+}
+
+func (l *pdfLayout) table(t Table) {
+	cols := t.columns()
+	if cols == 0 {
+		return
+	}
+	widths := columnWidths(t, cols)
+	numeric := numericColumns(t, cols)
+
+	header := func() {
+		l.row(t.Header, widths, numeric, true)
+	}
+	if len(t.Header) > 0 {
+		// Keep the header with the first row
+		l.fits(l.rowHeight(t.Header, widths, true) + tableLeading + 2*cellPad)
+		header()
+	}
+	for _, row := range t.Rows {
+		if h := l.rowHeight(row, widths, false); l.y-h < bottom {
+			l.newPage()
+			if len(t.Header) > 0 {
+				header()
+			}
+		}
+		l.row(row, widths, numeric, false)
+	}
+	l.y -= 16
+}
+
+func (l *pdfLayout) rowHeight(cells []string, widths []float64, head bool) float64 {
+	f := regular
+	if head {
+		f = bold
+	}
+	n := 1
+	for i, c := range cells {
+		if i < len(widths) {
+			if k := len(wrap(f, tableSize, c, widths[i]-2*cellPad)); k > n {
+				n = k
+			}
+		}
+	}
+	return float64(n)*tableLeading + 2*cellPad
+}
+
+func (l *pdfLayout) row(cells []string, widths []float64, numeric []bool, head bool) {
+	h := l.rowHeight(cells, widths, head)
+	f, c := regular, black
+	if head {
+		f, c = bold, white
+		l.fill(l.accent, margin, l.y-h, contentWidth, h)
+	}
+	x := margin
+	for i, w := range widths {
+		if i < len(cells) {
+			y := l.y - cellPad - tableSize
+			for _, ln := range wrap(f, tableSize, cells[i], w-2*cellPad) {
+				tx := x + cellPad
+				if numeric[i] {
+					tx = x + w - cellPad - f.width(ln, tableSize)
+				}
+				l.text(f, tableSize, c, tx, y, ln)
+				y -= tableLeading
+			}
+		}
+		x += w
+	}
+	l.y -= h
+	if !head {
+		l.line(rule, margin, l.y, margin+contentWidth, l.y)
+	}
+}
+
+// columnWidths shares the page width between columns in proportion to
+// their widest cells, so no single long cell takes most of the row
+func columnWidths(t Table, cols int) []float64 {
+	natural := make([]float64, cols)
+	measure := func(f *font, cells []string) {
+		for i, c := range cells {
+			if w := f.width(encode(c), tableSize) + 2*cellPad; w > natural[i] {
+				natural[i] = w
+			}
+		}
+	}
+	measure(bold, t.Header)
+	for _, row := range t.Rows {
+		measure(regular, row)
+	}
+	total := 0.0
+	for i := range natural {
+		natural[i] = min(max(natural[i], 36), contentWidth*0.6)
+		total += natural[i]
+	}
+	for i := range natural {
+		natural[i] *= contentWidth / total
+	}
+	return natural
+}
+
+func (l *pdfLayout) footers() {
+	for i, p := range l.pages {
+		l.page = p
+		l.line(rule, margin, footerY+12, margin+contentWidth, footerY+12)
+		l.text(regular, 9, gray, margin, footerY, encode(l.doc.Author))
+		num := encode(fmt.Sprintf("Page %d of %d", i+1, len(l.pages)))
+		l.text(regular, 9, gray, pageWidth-margin-regular.width(num, 9), footerY, num)
+	}
+}
+
+// write serializes the pages: catalog, page tree, the two fonts and the
+// document information, then a page object and content stream per page
+func (l *pdfLayout) write(w io.Writer) error {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // Page tree, below
+		fontObject(regular),
+		fontObject(bold),
+		fmt.Sprintf("<< /Title %s /Author %s >>", pdfText(l.doc.Title), pdfText(l.doc.Author)),
+	}
+	kids := make([]string, len(l.pages))
+	for i, p := range l.pages {
+		pageObj := len(objects) + 1
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, pageObj+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(l.pages))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func fontObject(f *font) string {
+	return fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.base)
+}
+
+func (c rgb) pdf() string {
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(c.r)/255, float64(c.g)/255, float64(c.b)/255)
+}
+
+// pdfString writes encoded text as a literal string
+func pdfString(s []byte) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range s {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// pdfText writes metadata text as UTF-16 so any character survives
+func pdfText(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// wrap breaks text into lines no wider than width, at spaces where it can.
+// Newlines always break.
+func wrap(f *font, size float64, text string, width float64) [][]byte {
+	var lines [][]byte
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		var cur []byte
+		for _, word := range strings.Fields(para) {
+			w := encode(word)
+			candidate := w
+			if len(cur) > 0 {
+				candidate = append(append(append([]byte{}, cur...), ' '), w...)
+			}
+			if f.width(candidate, size) <= width {
+				cur = candidate
+				continue
+			}
+			if len(cur) > 0 {
+				lines = append(lines, cur)
+				cur = nil
+			}
+			// A word wider than the line is broken between characters
+			for f.width(w, size) > width && len(w) > 1 {
+				n := len(w) - 1
+				for n > 1 && f.width(w[:n], size) > width {
+					n--
+				}
+				lines = append(lines, w[:n])
+				w = w[n:]
+			}
+			cur = w
+		}
+		lines = append(lines, cur)
+	}
+	return lines
+}
@@ -35,16 +35,24 @@ type EthicsAcknowledgment struct {
 
 // PublishedOpinion represents a published advisory opinion or order
 type PublishedOpinion struct {
-	ID           string
-	CaseNumber   string
-	Type         CaseType // AO or EC
-	Title        string
-	Summary      string
-	Topics       []string // "conflicts of interest", "gifts", "voting", etc.
-	Statutes     []string // NRS 281A.xxx citations
-	DocumentURL  string
-	PublishedAt  time.Time
-	Year         int
+	ID          string
+	CaseID      string
+	CaseNumber  string
+	Type        CaseType // AO or EC
+	Title       string
+	Summary     string
+	Topics      []string          // "conflicts of interest", "gifts", "voting", etc.
+	Statutes    []string          // NRS 281A.xxx citations
+	Citations   []StatuteCitation // Normalized citations backing the /statutes index
+	DocumentID  string            // Final document served at DocumentURL
+	DocumentURL string
+	PublishedAt time.Time
+	PublishedBy string // Staff user ID
+	Year        int
+
+	// Redaction review sign-off (required before publication)
+	RedactionReviewedBy string
+	RedactionReviewedAt time.Time
 }
 
 // SearchResult represents a search result for public search
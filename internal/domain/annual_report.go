@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// ReportNarrative is staff-written text that replaces a generated section of
+// the annual report for one fiscal year
+type ReportNarrative struct {
+	FiscalYear int
+	Section    string // e.g. "introduction"
+	Body       string
+	UpdatedBy  string // Name of the staff member who last edited it
+	UpdatedAt  time.Time
+}
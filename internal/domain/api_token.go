@@ -0,0 +1,86 @@
+package domain
+
+import "time"
+
+// TokenScope grants an API token access to a group of API operations
+type TokenScope string
+
+const (
+	ScopeCasesRead       TokenScope = "cases:read"       // List and view cases, deadlines and stats
+	ScopeCasesWrite      TokenScope = "cases:write"      // Change case status and assignment
+	ScopeOpinionsPublish TokenScope = "opinions:publish" // Publish opinions and orders
+)
+
+// TokenScopes lists scopes with descriptions, in display order
+var TokenScopes = []struct {
+	Scope       TokenScope
+	Description string
+}{
+	{ScopeCasesRead, "Read cases, deadlines and dashboard stats"},
+	{ScopeCasesWrite, "Change case status and assignment"},
+	{ScopeOpinionsPublish, "Publish opinions and orders"},
+}
+
+// IsValid returns true if the scope is a known scope
+func (s TokenScope) IsValid() bool {
+	for _, scope := range TokenScopes {
+		if scope.Scope == s {
+			return true
+		}
+	}
+	return false
+}
+
+// PermittedFor returns true if the user's role allows the scope. A token can
+// never do more than its owner could do in the staff portal.
+func (s TokenScope) PermittedFor(u *User) bool {
+	if u == nil {
+		return false
+	}
+	switch s {
+	case ScopeCasesRead:
+		return true
+	case ScopeCasesWrite:
+		return u.CanManageCases()
+	case ScopeOpinionsPublish:
+		return u.CanPublish()
+	}
+	return false
+}
+
+// APIToken is a named personal access token for machine clients. Only a
+// SHA-256 hash of the secret is stored; the secret is shown once when minted.
+type APIToken struct {
+	ID     string
+	UserID string
+	Name   string
+	Prefix string // Leading characters of the secret, to recognize it in lists
+	Hash   string // Hex SHA-256 of the secret
+	Scopes []TokenScope
+
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// HasScope returns true if the token was granted the scope
+func (t *APIToken) HasScope(s TokenScope) bool {
+	for _, scope := range t.Scopes {
+		if scope == s {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired returns true if the token's expiry has passed
+func (t *APIToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// IsActive returns true if the token can still authenticate
+func (t *APIToken) IsActive() bool {
+	return t.RevokedAt == nil && !t.IsExpired()
+}
@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// AuditAction names a change recorded in the audit log
+type AuditAction string
+
+const (
+	AuditUserInvited       AuditAction = "user.invited"
+	AuditUserUpdated       AuditAction = "user.updated"
+	AuditUserDeactivated   AuditAction = "user.deactivated"
+	AuditUserReactivated   AuditAction = "user.reactivated"
+	AuditUserPasswordReset AuditAction = "user.password_reset"
+	AuditUserPasswordSet   AuditAction = "user.password_set"
+	AuditUserMFAReset      AuditAction = "user.mfa_reset"
+)
+
+// AuditEntry records who changed what and when. Entries are never modified.
+type AuditEntry struct {
+	ID         string
+	Action     AuditAction
+	ActorID    string
+	ActorName  string
+	TargetType string // e.g. "user"
+	TargetID   string
+	TargetName string
+	Details    string // Human-readable summary of the change, e.g. "role: investigator → staff_attorney"
+	CreatedAt  time.Time
+}
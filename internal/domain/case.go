@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"time"
+	_ "time/tzdata" // The agency's zone must load on hosts without zoneinfo
+)
 
 // CaseType represents the type of ethics case
 type CaseType string
@@ -12,6 +15,29 @@ const (
 	CaseTypePublicRecordsRequest CaseType = "PRR" // Public Records Request
 )
 
+// CaseTypes lists every case type
+var CaseTypes = []CaseType{
+	CaseTypeAdvisoryOpinion,
+	CaseTypeEthicsComplaint,
+	CaseTypeEthicsAcknowledgment,
+	CaseTypePublicRecordsRequest,
+}
+
+// Label returns the display name of the case type
+func (t CaseType) Label() string {
+	switch t {
+	case CaseTypeAdvisoryOpinion:
+		return "Advisory Opinion"
+	case CaseTypeEthicsComplaint:
+		return "Ethics Complaint"
+	case CaseTypeEthicsAcknowledgment:
+		return "Ethics Acknowledgment"
+	case CaseTypePublicRecordsRequest:
+		return "Public Records Request"
+	}
+	return string(t)
+}
+
 // CaseStatus represents the current status of a case
 type CaseStatus string
 
@@ -26,6 +52,106 @@ const (
 	StatusWithdrawn    CaseStatus = "withdrawn"
 )
 
+// CaseStatuses lists every case status in workflow order
+var CaseStatuses = []CaseStatus{
+	StatusSubmitted,
+	StatusUnderReview,
+	StatusInvestigation,
+	StatusPendingHearing,
+	StatusDraftPrepared,
+	StatusPublished,
+	StatusClosed,
+	StatusWithdrawn,
+}
+
+// IsValid returns true if s is a known case status
+func (s CaseStatus) IsValid() bool {
+	for _, status := range CaseStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Label returns the display name of the case status
+func (s CaseStatus) Label() string {
+	switch s {
+	case StatusSubmitted:
+		return "New"
+	case StatusUnderReview:
+		return "Under Review"
+	case StatusInvestigation:
+		return "Investigation"
+	case StatusPendingHearing:
+		return "Pending Hearing"
+	case StatusDraftPrepared:
+		return "Draft Prepared"
+	case StatusPublished:
+		return "Published"
+	case StatusClosed:
+		return "Closed"
+	case StatusWithdrawn:
+		return "Withdrawn"
+	}
+	return string(s)
+}
+
+// Disposition records how an ethics complaint was resolved
+type Disposition string
+
+const (
+	DispositionDismissedJurisdiction Disposition = "dismissed_jurisdiction" // Outside the Commission's jurisdiction
+	DispositionDismissedInsufficient Disposition = "dismissed_insufficient" // Insufficient evidence to proceed
+	DispositionDeferred              Disposition = "deferred"               // Deferral agreement
+	DispositionStipulated            Disposition = "stipulated"             // Stipulated agreement
+	DispositionViolation             Disposition = "violation"              // Violation found after hearing
+	DispositionNoViolation           Disposition = "no_violation"           // No violation found after hearing
+	DispositionWithdrawn             Disposition = "withdrawn"              // Withdrawn by the requester
+)
+
+// Dispositions lists every complaint disposition
+var Dispositions = []Disposition{
+	DispositionDismissedJurisdiction,
+	DispositionDismissedInsufficient,
+	DispositionDeferred,
+	DispositionStipulated,
+	DispositionViolation,
+	DispositionNoViolation,
+	DispositionWithdrawn,
+}
+
+// Label returns the display name of the disposition
+func (d Disposition) Label() string {
+	switch d {
+	case DispositionDismissedJurisdiction:
+		return "Dismissed: no jurisdiction"
+	case DispositionDismissedInsufficient:
+		return "Dismissed: insufficient evidence"
+	case DispositionDeferred:
+		return "Deferral agreement"
+	case DispositionStipulated:
+		return "Stipulated agreement"
+	case DispositionViolation:
+		return "Violation found"
+	case DispositionNoViolation:
+		return "No violation found"
+	case DispositionWithdrawn:
+		return "Withdrawn"
+	}
+	return string(d)
+}
+
+// IsValid returns true if d is a known disposition
+func (d Disposition) IsValid() bool {
+	for _, disposition := range Dispositions {
+		if d == disposition {
+			return true
+		}
+	}
+	return false
+}
+
 // Case represents an ethics case in the system
 type Case struct {
 	ID              string
@@ -46,15 +172,17 @@ type Case struct {
 	SubjectAgency   string
 
 	// Case Content
-	Summary         string
-	Description     string
-	StatuteCitations string // NRS 281A references
+	Summary          string
+	Description      string
+	StatuteCitations string            // NRS 281A references as entered
+	Citations        []StatuteCitation // Normalized citations parsed from the case text
+	Disposition      Disposition       // How an ethics complaint was resolved
 
 	// Dates
-	SubmittedAt     time.Time
-	DueDate         time.Time
-	ClosedAt        *time.Time
-	PublishedAt     *time.Time
+	SubmittedAt time.Time
+	DueDate     time.Time
+	ClosedAt    *time.Time // Set when the case is closed or withdrawn
+	PublishedAt *time.Time
 
 	// Assignment
 	AssignedTo      string // Staff user ID
@@ -81,6 +209,17 @@ type Document struct {
 	IsPublic    bool
 	UploadedBy  string
 	UploadedAt  time.Time
+	Content     []byte // File contents (held in memory by the mock repository)
+
+	// Redacted derivatives point back at the original they were produced from
+	DerivedFromID string
+	IsRedacted    bool
+}
+
+// CanBePublic returns true if the document may be served publicly.
+// Only redacted derivatives are eligible; originals never leave the staff portal.
+func (d *Document) CanBePublic() bool {
+	return d.IsRedacted && d.DerivedFromID != ""
 }
 
 // CaseNote represents an internal note on a case
@@ -97,6 +236,7 @@ type CaseNote struct {
 type CaseActivity struct {
 	ID          string
 	CaseID      string
+	CaseNumber  string
 	Action      string // "created", "status_changed", "assigned", "document_added", "note_added"
 	Description string
 	UserID      string
@@ -144,15 +284,69 @@ func (c *Case) IsOverdue() bool {
 	return time.Now().After(c.DueDate) && c.Status != StatusClosed && c.Status != StatusPublished
 }
 
+// ResolvedAt returns when the case was published, closed or withdrawn, or
+// nil while it is open. Publication resolves a case even if it is closed later.
+func (c *Case) ResolvedAt() *time.Time {
+	switch c.Status {
+	case StatusPublished, StatusClosed, StatusWithdrawn:
+		if c.PublishedAt != nil {
+			return c.PublishedAt
+		}
+		return c.ClosedAt
+	}
+	return nil
+}
+
+// CaseCounts are aggregate case counts, computed by the repository
+type CaseCounts struct {
+	ByType       map[CaseType]int
+	ByStatus     map[CaseStatus]int
+	ByTypeStatus map[CaseType]map[CaseStatus]int
+	Overdue      int // Open cases past their due date
+}
+
 // CaseStats holds dashboard statistics
 type CaseStats struct {
-	TotalOpen         int
-	TotalPending      int
+	TotalOpen         int // Cases not yet published, closed or withdrawn
+	TotalPending      int // Submitted cases awaiting review
 	TotalOverdue      int
-	TotalClosed       int
+	TotalClosed       int // Cases published, closed or withdrawn, ever
+	OpenedYTD         int // Cases submitted since January 1
+	ClosedYTD         int // Cases resolved since January 1
+	FiscalYear        int // Current fiscal year, e.g. 2026 for July 2025 to June 2026
+	OpenedFiscalYear  int
+	ClosedFiscalYear  int
 	ByType            map[string]int // Use string keys for template compatibility
 	ByStatus          map[string]int // Use string keys for template compatibility
 	RecentCases       []Case
 	RecentActivity    []CaseActivity
 	UpcomingDeadlines []Deadline
 }
+
+// Location is the agency's time zone. Years, fiscal years and dates are
+// reckoned in it, whatever zone the server runs in.
+var Location = mustLoadLocation("America/Los_Angeles")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// FiscalYear returns the state fiscal year containing t in the agency's
+// zone. Fiscal years run July to June and are named for the year they end:
+// FY2026 starts July 1, 2025.
+func FiscalYear(t time.Time) int {
+	t = t.In(Location)
+	if t.Month() >= time.July {
+		return t.Year() + 1
+	}
+	return t.Year()
+}
+
+// FiscalYearStart returns midnight on July 1 starting fiscal year fy, in loc
+func FiscalYearStart(fy int, loc *time.Location) time.Time {
+	return time.Date(fy-1, time.July, 1, 0, 0, 0, 0, loc)
+}
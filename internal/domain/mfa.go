@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// MFAEnrollment is a user's TOTP authenticator registration. The secret is
+// kept until an admin resets it or the user turns two-factor sign-in off.
+type MFAEnrollment struct {
+	UserID        string
+	Secret        string     // Base32 TOTP secret
+	RecoveryCodes []string   // Hex SHA-256 hashes of the unused recovery codes
+	LastUsedStep  int64      // TOTP time step of the last accepted code, so a code cannot be replayed
+	ConfirmedAt   *time.Time // Nil until the user proves their authenticator works
+	CreatedAt     time.Time
+	LastUsedAt    *time.Time
+}
+
+// IsConfirmed returns true if the enrollment is in force at login
+func (e *MFAEnrollment) IsConfirmed() bool {
+	return e.ConfirmedAt != nil
+}
+
+// MFAPolicy lists the roles that must sign in with a second factor
+type MFAPolicy struct {
+	RequiredRoles []Role
+	UpdatedBy     string
+	UpdatedAt     time.Time
+}
+
+// Requires returns true if users with the role must use a second factor
+func (p MFAPolicy) Requires(role Role) bool {
+	for _, r := range p.RequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
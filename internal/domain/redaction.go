@@ -0,0 +1,79 @@
+package domain
+
+import "time"
+
+// RedactionKind distinguishes text spans from PDF page regions
+type RedactionKind string
+
+const (
+	RedactionText   RedactionKind = "text"   // Byte span in a text/plain or text/html document
+	RedactionRegion RedactionKind = "region" // Rectangle on a PDF page
+)
+
+// RedactionReason is the reason code recorded for each redaction
+type RedactionReason string
+
+const (
+	ReasonRequesterIdentity   RedactionReason = "requester_identity"   // Confidential requester identity (NRS 281A)
+	ReasonComplainantIdentity RedactionReason = "complainant_identity" // Confidential complainant or witness identity
+	ReasonPersonalInformation RedactionReason = "personal_information" // Home address, phone, email, SSN, etc.
+	ReasonPrivileged          RedactionReason = "privileged"           // Attorney-client or deliberative material
+)
+
+// RedactionReasons lists reason codes with display labels, in display order
+var RedactionReasons = []struct {
+	Code  RedactionReason
+	Label string
+}{
+	{ReasonRequesterIdentity, "Requester identity"},
+	{ReasonComplainantIdentity, "Complainant / witness identity"},
+	{ReasonPersonalInformation, "Personal information"},
+	{ReasonPrivileged, "Privileged material"},
+}
+
+// IsValid returns true if the reason is a known code
+func (r RedactionReason) IsValid() bool {
+	for _, reason := range RedactionReasons {
+		if reason.Code == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Redaction is a pending redaction defined on an original document
+type Redaction struct {
+	ID         string
+	DocumentID string
+	Kind       RedactionKind
+	Reason     RedactionReason
+
+	// Text spans: byte offsets [Start, End) into the original content
+	Start int
+	End   int
+
+	// PDF regions: 1-based page number and rectangle in PDF user-space points
+	// (origin at the bottom-left corner of the page)
+	Page   int
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// RedactionLog records one application of redactions to a document
+type RedactionLog struct {
+	ID                string
+	CaseID            string
+	SourceDocumentID  string // Original (never modified)
+	DerivedDocumentID string // Redacted derivative
+	SourceSHA256      string
+	DerivedSHA256     string
+	Redactions        []Redaction
+	AppliedBy         string
+	AppliedByName     string
+	AppliedAt         time.Time
+}
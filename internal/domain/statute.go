@@ -0,0 +1,41 @@
+package domain
+
+import "strings"
+
+// StatuteCitation is a normalized reference to a section of the Nevada
+// Revised Statutes, e.g. NRS 281A.400(2)(a)
+type StatuteCitation struct {
+	Chapter     string   // "281A"
+	Section     string   // "400"
+	Subsections []string // "2", "a"
+}
+
+// SectionKey returns the chapter and section without subsections, e.g. "281A.400"
+func (c StatuteCitation) SectionKey() string {
+	return c.Chapter + "." + c.Section
+}
+
+// SectionString returns the section citation without subsections, e.g. "NRS 281A.400"
+func (c StatuteCitation) SectionString() string {
+	return "NRS " + c.SectionKey()
+}
+
+// String returns the full citation, e.g. "NRS 281A.400(2)(a)"
+func (c StatuteCitation) String() string {
+	var b strings.Builder
+	b.WriteString(c.SectionString())
+	for _, sub := range c.Subsections {
+		b.WriteString("(" + sub + ")")
+	}
+	return b.String()
+}
+
+// CitesSection returns true if any citation refers to the given section key
+func CitesSection(citations []StatuteCitation, sectionKey string) bool {
+	for _, c := range citations {
+		if c.SectionKey() == sectionKey {
+			return true
+		}
+	}
+	return false
+}
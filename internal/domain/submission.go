@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// TriageStatus is where a held submission stands in staff review
+type TriageStatus string
+
+const (
+	TriagePending  TriageStatus = "pending"
+	TriageAccepted TriageStatus = "accepted" // Opened as a case
+	TriageRejected TriageStatus = "rejected" // Judged to be spam
+)
+
+// HeldSubmission is a public submission that looked automated, e.g. its
+// hidden honeypot field was filled in. It gets no case number unless staff
+// accept it from the triage queue.
+type HeldSubmission struct {
+	ID         string
+	Case       Case     // As submitted, without a case number
+	Reasons    []string // Why it was held, e.g. "submitted 1s after the form loaded"
+	IP         string
+	ReceivedAt time.Time
+
+	Status         TriageStatus
+	ReviewedBy     string // Staff user ID
+	ReviewedByName string
+	ReviewedAt     time.Time
+	CaseID         string // Set when accepted
+	CaseNumber     string
+}
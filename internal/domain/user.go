@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Role represents a user's role in the system
 type Role string
@@ -15,6 +18,27 @@ const (
 	RoleAuditor        Role = "auditor"         // Audit logs only
 )
 
+// Roles lists every role, most privileged first
+var Roles = []Role{
+	RoleAdmin,
+	RoleCommissionCounsel,
+	RoleStaffAttorney,
+	RoleInvestigator,
+	RoleAdminStaff,
+	RoleAuditor,
+	RoleReadOnly,
+}
+
+// IsValid returns true if r is a known role
+func (r Role) IsValid() bool {
+	for _, role := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 // User represents a staff user in the system
 type User struct {
 	ID           string
@@ -26,9 +50,27 @@ type User struct {
 	Title        string
 	Phone        string
 	IsActive     bool
+	ExternalID   string // Identity provider issuer and subject, for single sign-on accounts
 	LastLoginAt  *time.Time
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+
+	// An outstanding invitation or password reset link. Password login is
+	// refused until the link is used to choose a new password.
+	PasswordTokenHash      string // Hex SHA-256 of the link token
+	PasswordTokenExpiresAt time.Time
+	InvitedAt              *time.Time // Nil once the user has accepted their invitation
+}
+
+// Status describes the account for the user list: "active", "invited" or "deactivated"
+func (u *User) Status() string {
+	switch {
+	case !u.IsActive:
+		return "deactivated"
+	case u.InvitedAt != nil:
+		return "invited"
+	}
+	return "active"
 }
 
 // FullName returns the user's full name
@@ -60,16 +102,57 @@ func (u *User) CanManageUsers() bool {
 	return u.Role == RoleAdmin
 }
 
+// CanManageWebhooks returns true if the user can register outbound webhooks
+func (u *User) CanManageWebhooks() bool {
+	return u.Role == RoleAdmin
+}
+
 // Session represents a user session
 type Session struct {
-	ID        string
-	UserID    string
-	Token     string
-	ExpiresAt time.Time
-	CreatedAt time.Time
+	ID            string
+	UserID        string
+	Token         string
+	ExpiresAt     time.Time // Absolute limit, fixed at login
+	IdleExpiresAt time.Time // Pushed back by activity, never past ExpiresAt
+	LastSeenAt    time.Time
+	IP            string
+	UserAgent     string
+	CreatedAt     time.Time
 }
 
-// IsExpired returns true if the session has expired
-func (s *Session) IsExpired() bool {
-	return time.Now().After(s.ExpiresAt)
+// ExpiredAt returns true if at now the session has passed its absolute or
+// idle limit
+func (s *Session) ExpiredAt(now time.Time) bool {
+	return now.After(s.ExpiresAt) || (!s.IdleExpiresAt.IsZero() && now.After(s.IdleExpiresAt))
+}
+
+// Device describes the session's browser and operating system for the
+// signed-in sessions list, e.g. "Firefox on Windows"
+func (s *Session) Device() string {
+	ua := s.UserAgent
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range []struct{ token, name string }{
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			return browser + " on " + o.name
+		}
+	}
+	return browser
 }
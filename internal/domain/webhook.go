@@ -0,0 +1,111 @@
+package domain
+
+import "time"
+
+// WebhookEvent names a case lifecycle event that webhooks can subscribe to
+type WebhookEvent string
+
+const (
+	EventCaseCreated       WebhookEvent = "case.created"
+	EventCaseStatusChanged WebhookEvent = "case.status_changed"
+	EventCaseAssigned      WebhookEvent = "case.assigned"
+	EventOpinionPublished  WebhookEvent = "opinion.published"
+	EventDeadlineReminder  WebhookEvent = "deadline.reminder"
+	EventDeadlineOverdue   WebhookEvent = "deadline.overdue"
+)
+
+// WebhookEvents lists events with descriptions, in display order
+var WebhookEvents = []struct {
+	Event       WebhookEvent
+	Description string
+}{
+	{EventCaseCreated, "A case is filed or submitted"},
+	{EventCaseStatusChanged, "A case moves to a new status"},
+	{EventCaseAssigned, "A case is assigned to a staff member"},
+	{EventOpinionPublished, "An opinion or order is published"},
+	{EventDeadlineReminder, "A case deadline reaches a day on the reminder schedule"},
+	{EventDeadlineOverdue, "A case deadline passes without a response"},
+}
+
+// IsValid returns true if the event is a known event
+func (e WebhookEvent) IsValid() bool {
+	for _, event := range WebhookEvents {
+		if event.Event == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook is an external endpoint that receives signed event notifications
+type Webhook struct {
+	ID          string
+	URL         string
+	Description string
+	Events      []WebhookEvent
+	Secret      string // Shared HMAC-SHA256 signing secret
+	IsActive    bool
+	CreatedBy   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Subscribes returns true if the webhook is active and subscribed to the event
+func (w *Webhook) Subscribes(e WebhookEvent) bool {
+	if !w.IsActive {
+		return false
+	}
+	for _, event := range w.Events {
+		if event == e {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is the state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // Queued or waiting to retry
+	DeliverySucceeded DeliveryStatus = "succeeded" // Receiver answered 2xx
+	DeliveryFailed    DeliveryStatus = "failed"    // Retries exhausted
+)
+
+// WebhookDelivery is one event sent to one webhook, with every attempt made
+type WebhookDelivery struct {
+	ID            string
+	WebhookID     string
+	EventID       string // Shared by redeliveries so receivers can deduplicate
+	Event         WebhookEvent
+	Payload       []byte
+	Status        DeliveryStatus
+	Attempts      []DeliveryAttempt
+	NextAttemptAt *time.Time
+	RedeliveryOf  string // Delivery this one was manually redelivered from
+	CreatedAt     time.Time
+	CompletedAt   *time.Time
+}
+
+// DeliveryAttempt records a single HTTP request for a delivery
+type DeliveryAttempt struct {
+	Number       int
+	At           time.Time
+	StatusCode   int    // 0 if no response was received
+	ResponseBody string // Truncated
+	Error        string
+	Duration     time.Duration
+}
+
+// Succeeded returns true if the receiver answered 2xx
+func (a DeliveryAttempt) Succeeded() bool {
+	return a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// LastAttempt returns the most recent attempt, or nil before the first
+func (d *WebhookDelivery) LastAttempt() *DeliveryAttempt {
+	if len(d.Attempts) == 0 {
+		return nil
+	}
+	return &d.Attempts[len(d.Attempts)-1]
+}
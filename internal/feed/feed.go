@@ -0,0 +1,181 @@
+// Package feed renders published opinions as an Atom feed and an XML sitemap.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"ncoe/internal/domain"
+)
+
+// MaxEntries caps the number of entries in a feed; subscribers only need recent items
+const MaxEntries = 50
+
+// Atom content type served for feeds
+const AtomContentType = "application/atom+xml; charset=utf-8"
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   atomPerson  `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Links      []atomLink     `xml:"link"`
+	Summary    string         `xml:"summary"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// Options describes the feed being rendered
+type Options struct {
+	BaseURL  string // Absolute site root, e.g. "https://portal.ethics.nv.gov"
+	SelfPath string // Path and query of the feed itself
+	Title    string
+	Subtitle string
+	Author   string
+}
+
+// Atom renders opinions (newest first) as an Atom 1.0 feed. It returns the
+// document and the time of the most recent entry, for Last-Modified.
+func Atom(opts Options, opinions []domain.PublishedOpinion) ([]byte, time.Time, error) {
+	if len(opinions) > MaxEntries {
+		opinions = opinions[:MaxEntries]
+	}
+	base := strings.TrimRight(opts.BaseURL, "/")
+	updated := LastModified(opinions)
+
+	f := atomFeed{
+		ID:       base + opts.SelfPath,
+		Title:    opts.Title,
+		Subtitle: opts.Subtitle,
+		Updated:  timestamp(updated),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: base + opts.SelfPath},
+			{Rel: "alternate", Type: "text/html", Href: base + "/search"},
+		},
+		Author: atomPerson{Name: opts.Author, URI: base + "/"},
+	}
+
+	for _, o := range opinions {
+		url := base + "/opinions/" + o.CaseNumber
+		e := atomEntry{
+			ID:        url,
+			Title:     o.CaseNumber + ": " + o.Title,
+			Updated:   timestamp(o.PublishedAt),
+			Published: timestamp(o.PublishedAt),
+			Links:     []atomLink{{Rel: "alternate", Type: "text/html", Href: url}},
+			Summary:   o.Summary,
+		}
+		e.Categories = append(e.Categories, atomCategory{Term: string(o.Type), Label: typeLabel(o.Type)})
+		for _, topic := range o.Topics {
+			e.Categories = append(e.Categories, atomCategory{Term: topic})
+		}
+		for _, c := range o.Citations {
+			e.Categories = append(e.Categories, atomCategory{Term: c.String()})
+		}
+		f.Entries = append(f.Entries, e)
+	}
+
+	body, err := marshal(f)
+	return body, updated, err
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap renders a sitemap listing the given static pages and every
+// published opinion. It returns the document and the most recent lastmod.
+func Sitemap(baseURL string, pages []string, opinions []domain.PublishedOpinion) ([]byte, time.Time, error) {
+	base := strings.TrimRight(baseURL, "/")
+	updated := LastModified(opinions)
+
+	var set urlSet
+	for _, p := range pages {
+		set.URLs = append(set.URLs, sitemapURL{Loc: base + p})
+	}
+	for _, o := range opinions {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     base + "/opinions/" + o.CaseNumber,
+			LastMod: o.PublishedAt.UTC().Format("2006-01-02"),
+		})
+	}
+
+	body, err := marshal(set)
+	return body, updated, err
+}
+
+// LastModified returns the latest publication time, or the zero time if there are no opinions
+func LastModified(opinions []domain.PublishedOpinion) time.Time {
+	var latest time.Time
+	for _, o := range opinions {
+		if o.PublishedAt.After(latest) {
+			latest = o.PublishedAt
+		}
+	}
+	return latest
+}
+
+func typeLabel(t domain.CaseType) string {
+	switch t {
+	case domain.CaseTypeAdvisoryOpinion:
+		return "Advisory Opinion"
+	case domain.CaseTypeEthicsComplaint:
+		return "Final Order"
+	}
+	return string(t)
+}
+
+// timestamp formats t as RFC 3339; an empty feed reports the Unix epoch
+// rather than an invalid empty <updated>
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
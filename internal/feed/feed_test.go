@@ -0,0 +1,139 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+)
+
+func testOpinions() []domain.PublishedOpinion {
+	return []domain.PublishedOpinion{
+		{
+			CaseNumber:  "EC-2024-005",
+			Type:        domain.CaseTypeEthicsComplaint,
+			Title:       "Gifts & Tickets",
+			Summary:     "Accepted gifts <over> $50.",
+			Topics:      []string{"Gifts"},
+			Citations:   []domain.StatuteCitation{{Chapter: "281A", Section: "400", Subsections: []string{"1"}}},
+			PublishedAt: time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			CaseNumber:  "AO-2024-010",
+			Type:        domain.CaseTypeAdvisoryOpinion,
+			Title:       "Contractor Relationships",
+			PublishedAt: time.Date(2024, 2, 1, 17, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	body, modified, err := Atom(Options{
+		BaseURL:  "https://example.gov/",
+		SelfPath: "/feeds/opinions.atom?type=EC",
+		Title:    "Final Orders",
+		Author:   "Commission",
+	}, testOpinions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !modified.Equal(time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("modified: got %v", modified)
+	}
+
+	var f atomFeed
+	if err := xml.Unmarshal(body, &f); err != nil {
+		t.Fatalf("feed is not valid XML: %v\n%s", err, body)
+	}
+	if f.ID != "https://example.gov/feeds/opinions.atom?type=EC" || f.Updated != "2024-03-01T17:00:00Z" {
+		t.Errorf("unexpected feed header: id=%s updated=%s", f.ID, f.Updated)
+	}
+	if len(f.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(f.Entries))
+	}
+
+	e := f.Entries[0]
+	if e.ID != "https://example.gov/opinions/EC-2024-005" || e.Title != "EC-2024-005: Gifts & Tickets" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e.Summary != "Accepted gifts <over> $50." {
+		t.Errorf("summary not round-tripped: %q", e.Summary)
+	}
+	var terms []string
+	for _, c := range e.Categories {
+		terms = append(terms, c.Term)
+	}
+	if got := strings.Join(terms, "|"); got != "EC|Gifts|NRS 281A.400(1)" {
+		t.Errorf("categories: got %s", got)
+	}
+}
+
+func TestAtomIsDeterministic(t *testing.T) {
+	opts := Options{BaseURL: "https://example.gov", SelfPath: "/feeds/opinions.atom", Title: "All"}
+	a, _, _ := Atom(opts, testOpinions())
+	b, _, _ := Atom(opts, testOpinions())
+	if string(a) != string(b) {
+		t.Error("the same opinions should render identical feeds (ETags depend on it)")
+	}
+}
+
+func TestAtomLimitsEntries(t *testing.T) {
+	var many []domain.PublishedOpinion
+	for i := 0; i < MaxEntries+10; i++ {
+		many = append(many, testOpinions()[0])
+	}
+	body, _, err := Atom(Options{BaseURL: "https://example.gov"}, many)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(body), "<entry>"); n != MaxEntries {
+		t.Errorf("expected %d entries, got %d", MaxEntries, n)
+	}
+}
+
+func TestAtomEmpty(t *testing.T) {
+	body, modified, err := Atom(Options{BaseURL: "https://example.gov"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !modified.IsZero() {
+		t.Errorf("empty feed should have no modification time, got %v", modified)
+	}
+	if !strings.Contains(string(body), "<updated>1970-01-01T00:00:00Z</updated>") {
+		t.Errorf("empty feed needs a valid updated element:\n%s", body)
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	body, modified, err := Sitemap("https://example.gov", []string{"/", "/search"}, testOpinions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !modified.Equal(time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("modified: got %v", modified)
+	}
+
+	var set urlSet
+	if err := xml.Unmarshal(body, &set); err != nil {
+		t.Fatalf("sitemap is not valid XML: %v", err)
+	}
+	if set.XMLName.Space != "http://www.sitemaps.org/schemas/sitemap/0.9" {
+		t.Errorf("wrong namespace: %s", set.XMLName.Space)
+	}
+	want := []sitemapURL{
+		{Loc: "https://example.gov/"},
+		{Loc: "https://example.gov/search"},
+		{Loc: "https://example.gov/opinions/EC-2024-005", LastMod: "2024-03-01"},
+		{Loc: "https://example.gov/opinions/AO-2024-010", LastMod: "2024-02-01"},
+	}
+	if len(set.URLs) != len(want) {
+		t.Fatalf("expected %d URLs, got %d", len(want), len(set.URLs))
+	}
+	for i := range want {
+		if set.URLs[i] != want[i] {
+			t.Errorf("url %d: got %+v, want %+v", i, set.URLs[i], want[i])
+		}
+	}
+}
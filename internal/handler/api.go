@@ -0,0 +1,699 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/middleware"
+	"ncoe/internal/service"
+)
+
+const (
+	apiPrefix = "/api/v1"
+
+	defaultPageSize = 25
+	maxPageSize     = 100
+	maxBodyBytes    = 1 << 20
+)
+
+// APIHandler serves the versioned JSON API under /api/v1. Every route is
+// declared once in the route table, which drives both dispatch and the
+// generated OpenAPI document at /api/v1/openapi.json.
+type APIHandler struct {
+	caseService      *service.CaseService
+	dashboardService *service.DashboardService
+	opinionService   *service.OpinionService
+	routes           []apiRoute
+	spec             []byte
+}
+
+// apiRoute describes one API operation
+type apiRoute struct {
+	ID       string // OpenAPI operationId
+	Method   string
+	Path     string // Relative to /api/v1, with {name} path parameters
+	Summary  string
+	Tag      string
+	Public   bool              // No credentials required
+	Scope    domain.TokenScope // Scope an API token needs; sessions are limited only by role
+	List     bool              // Cursor-paginated; Response is the item type
+	Query    []apiParam        // Filters, in addition to cursor/limit on list routes
+	Body     interface{}       // Zero value of the JSON request body type, if any
+	Response interface{}       // Zero value of the "data" type
+	Errors   []int             // Error statuses besides 400 (filters) and 401 (auth)
+	handle   func(w http.ResponseWriter, r *http.Request, params map[string]string)
+}
+
+// apiParam is a query string parameter
+type apiParam struct {
+	Name        string
+	Description string
+	Enum        []string
+	Integer     bool
+}
+
+func (p apiParam) openAPI() openAPIParameter {
+	schema := &openAPISchema{Type: "string", Enum: p.Enum}
+	if p.Integer {
+		schema = &openAPISchema{Type: "integer"}
+	}
+	return openAPIParameter{Name: p.Name, In: "query", Description: p.Description, Schema: schema}
+}
+
+var cursorParams = []openAPIParameter{
+	{Name: "cursor", In: "query", Description: "next_cursor from the previous page", Schema: &openAPISchema{Type: "string"}},
+	{Name: "limit", In: "query", Description: "Page size (default 25)", Schema: &openAPISchema{Type: "integer", Minimum: intPtr(1), Maximum: intPtr(maxPageSize)}},
+}
+
+func intPtr(n int) *int { return &n }
+
+// API response types. Field names are the public contract; changing them is a
+// breaking change that needs a new API version.
+
+type apiCase struct {
+	ID              string            `json:"id"`
+	CaseNumber      string            `json:"case_number"`
+	Type            domain.CaseType   `json:"type"`
+	Status          domain.CaseStatus `json:"status"`
+	Priority        string            `json:"priority"`
+	Summary         string            `json:"summary"`
+	Description     string            `json:"description"`
+	SubmitterName   string            `json:"submitter_name"`
+	SubmitterAgency string            `json:"submitter_agency"`
+	SubjectName     string            `json:"subject_name"`
+	SubjectAgency   string            `json:"subject_agency"`
+	Statutes        []string          `json:"statutes" doc:"Normalized NRS citations, e.g. NRS 281A.400(2)"`
+	AssignedTo      string            `json:"assigned_to"`
+	AssignedToName  string            `json:"assigned_to_name"`
+	IsConfidential  bool              `json:"is_confidential"`
+	IsOverdue       bool              `json:"is_overdue"`
+	SubmittedAt     time.Time         `json:"submitted_at"`
+	DueDate         *time.Time        `json:"due_date"`
+	ClosedAt        *time.Time        `json:"closed_at"`
+	PublishedAt     *time.Time        `json:"published_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+type apiDeadline struct {
+	ID           string          `json:"id"`
+	CaseID       string          `json:"case_id"`
+	CaseNumber   string          `json:"case_number"`
+	CaseType     domain.CaseType `json:"case_type"`
+	Summary      string          `json:"summary"`
+	Type         string          `json:"type" doc:"response_due, hearing or extension"`
+	Status       string          `json:"status" doc:"upcoming, due_soon or overdue"`
+	DueDate      time.Time       `json:"due_date"`
+	DaysUntilDue int             `json:"days_until_due"`
+}
+
+type apiStats struct {
+	TotalOpen        int            `json:"total_open"`
+	TotalPending     int            `json:"total_pending"`
+	TotalOverdue     int            `json:"total_overdue"`
+	TotalClosed      int            `json:"total_closed" doc:"Cases published, closed or withdrawn"`
+	OpenedYTD        int            `json:"opened_ytd" doc:"Cases submitted since January 1"`
+	ClosedYTD        int            `json:"closed_ytd" doc:"Cases resolved since January 1"`
+	FiscalYear       int            `json:"fiscal_year" doc:"Current fiscal year, named for the June it ends in"`
+	OpenedFiscalYear int            `json:"opened_fiscal_year" doc:"Cases submitted since July 1"`
+	ClosedFiscalYear int            `json:"closed_fiscal_year" doc:"Cases resolved since July 1"`
+	ByType           map[string]int `json:"by_type"`
+	ByStatus         map[string]int `json:"by_status"`
+}
+
+type apiOpinion struct {
+	CaseNumber  string          `json:"case_number"`
+	Type        domain.CaseType `json:"type"`
+	Title       string          `json:"title"`
+	Summary     string          `json:"summary"`
+	Topics      []string        `json:"topics"`
+	Statutes    []string        `json:"statutes"`
+	Year        int             `json:"year"`
+	PublishedAt time.Time       `json:"published_at"`
+	URL         string          `json:"url" doc:"Public page for the opinion"`
+	DocumentURL string          `json:"document_url,omitempty" doc:"Redacted final document, when one is released"`
+}
+
+type apiStatusUpdate struct {
+	Status domain.CaseStatus `json:"status"`
+}
+
+type apiAssignment struct {
+	UserID string `json:"user_id" doc:"Staff user to make responsible for the case"`
+}
+
+type apiPublishRequest struct {
+	Title             string   `json:"title"`
+	Summary           string   `json:"summary"`
+	Topics            []string `json:"topics,omitempty"`
+	Statutes          []string `json:"statutes,omitempty" doc:"NRS citations, e.g. NRS 281A.400(2)"`
+	DocumentID        string   `json:"document_id" doc:"Redacted copy of the final document"`
+	RedactionReviewed bool     `json:"redaction_reviewed" doc:"Attests the redacted copy was checked for confidential information"`
+}
+
+type apiErrorEnvelope struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Code      string `json:"code" doc:"Machine-readable error code, e.g. not_found"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id" doc:"Matches the X-Request-Id response header"`
+}
+
+func NewAPIHandler(cs *service.CaseService, ds *service.DashboardService, os *service.OpinionService) *APIHandler {
+	h := &APIHandler{
+		caseService:      cs,
+		dashboardService: ds,
+		opinionService:   os,
+	}
+
+	caseFilters := []apiParam{
+		{Name: "type", Enum: caseTypeValues()},
+		{Name: "status", Enum: caseStatusValues()},
+		{Name: "q", Description: "Matches case number, summary or submitter"},
+		{Name: "statute", Description: "NRS 281A section, e.g. 281A.400; matches any subsection"},
+	}
+	h.routes = []apiRoute{
+		{ID: "listCases", Method: "GET", Path: "/cases", Summary: "List cases, newest submission first", Tag: "Cases",
+			Scope: domain.ScopeCasesRead, List: true, Query: caseFilters, Response: apiCase{}, handle: h.listCases},
+		{ID: "getCase", Method: "GET", Path: "/cases/{id}", Summary: "Get a case", Tag: "Cases",
+			Scope: domain.ScopeCasesRead, Response: apiCase{}, Errors: []int{http.StatusNotFound}, handle: h.getCase},
+		{ID: "updateCaseStatus", Method: "PUT", Path: "/cases/{id}/status", Summary: "Change a case's status", Tag: "Cases",
+			Scope: domain.ScopeCasesWrite, Body: apiStatusUpdate{}, Response: apiCase{},
+			Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity},
+			handle: h.updateCaseStatus},
+		{ID: "assignCase", Method: "PUT", Path: "/cases/{id}/assignee", Summary: "Assign a case to a staff member", Tag: "Cases",
+			Scope: domain.ScopeCasesWrite, Body: apiAssignment{}, Response: apiCase{},
+			Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity},
+			handle: h.assignCase},
+		{ID: "publishCase", Method: "POST", Path: "/cases/{id}/publish", Summary: "Publish a draft-prepared case as an opinion or order", Tag: "Opinions",
+			Scope: domain.ScopeOpinionsPublish, Body: apiPublishRequest{}, Response: apiOpinion{},
+			Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity},
+			handle: h.publishCase},
+		{ID: "listDeadlines", Method: "GET", Path: "/deadlines", Summary: "List open deadlines, soonest first", Tag: "Deadlines",
+			Scope: domain.ScopeCasesRead, List: true, Query: []apiParam{{Name: "status", Enum: []string{"upcoming", "due_soon", "overdue"}}}, Response: apiDeadline{}, handle: h.listDeadlines},
+		{ID: "getStats", Method: "GET", Path: "/stats", Summary: "Dashboard case counts", Tag: "Dashboard",
+			Scope: domain.ScopeCasesRead, Response: apiStats{}, handle: h.getStats},
+		{ID: "searchOpinions", Method: "GET", Path: "/opinions", Summary: "Search published opinions and orders, newest first", Tag: "Opinions",
+			Public: true, List: true, Response: apiOpinion{},
+			Query: []apiParam{
+				{Name: "q", Description: "Full-text query"},
+				{Name: "type", Enum: []string{string(domain.CaseTypeAdvisoryOpinion), string(domain.CaseTypeEthicsComplaint)}},
+				{Name: "year", Integer: true},
+				{Name: "topic"},
+			},
+			handle: h.searchOpinions},
+		{ID: "getOpinion", Method: "GET", Path: "/opinions/{case_number}", Summary: "Get a published opinion", Tag: "Opinions",
+			Public: true, Response: apiOpinion{}, Errors: []int{http.StatusNotFound}, handle: h.getOpinion},
+	}
+
+	spec, err := json.MarshalIndent(buildOpenAPI(h.routes), "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to build OpenAPI document: %v", err)
+	}
+	h.spec = spec
+	return h
+}
+
+// ServeHTTP dispatches /api/v1/ requests through the route table
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	if path == "/openapi.json" && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(h.spec)
+		return
+	}
+
+	var allowed []string
+	for _, rt := range h.routes {
+		params, ok := matchRoute(rt.Path, path)
+		if !ok {
+			continue
+		}
+		if rt.Method != r.Method {
+			allowed = append(allowed, rt.Method)
+			continue
+		}
+		middleware.SetRoute(r.Context(), rt.Method+" "+apiPrefix+rt.Path)
+		if !rt.Public && !h.authorize(w, r, rt.Scope) {
+			return
+		}
+		rt.handle(w, r, params)
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeAPIError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not supported on "+r.URL.Path)
+		return
+	}
+	writeAPIError(w, r, http.StatusNotFound, "not_found", "no API endpoint at "+r.URL.Path)
+}
+
+// authorize checks the caller may use a route needing scope, writing a 401 or
+// 403 if not. Token callers need the scope and a role that still permits it;
+// session callers are limited by their role in the handlers.
+func (h *APIHandler) authorize(w http.ResponseWriter, r *http.Request, scope domain.TokenScope) bool {
+	user := getUserFromContext(r)
+	if user == nil {
+		if err := middleware.GetAuthError(r.Context()); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeAPIError(w, r, http.StatusUnauthorized, "unauthorized", err.Error())
+		} else {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, r, http.StatusUnauthorized, "unauthorized", "send an API token as a Bearer credential or sign in to the staff portal")
+		}
+		return false
+	}
+	if token := middleware.GetAPIToken(r.Context()); token != nil && (!token.HasScope(scope) || !scope.PermittedFor(user)) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+string(scope)+`"`)
+		writeAPIError(w, r, http.StatusForbidden, "insufficient_scope", "this API token does not grant the "+string(scope)+" scope")
+		return false
+	}
+	return true
+}
+
+func (h *APIHandler) listCases(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	q := r.URL.Query()
+	if !checkEnum(w, r, "type", caseTypeValues()) || !checkEnum(w, r, "status", caseStatusValues()) {
+		return
+	}
+	cases := h.caseService.List(r.Context(), q.Get("type"), q.Get("status"), q.Get("q"), q.Get("statute"))
+
+	page, next, ok := paginate(w, r, cases, true, func(c *domain.Case) pageKey {
+		return pageKey{At: c.SubmittedAt, ID: c.ID}
+	})
+	if !ok {
+		return
+	}
+	data := make([]apiCase, 0, len(page))
+	for _, c := range page {
+		data = append(data, toAPICase(c))
+	}
+	writeAPIList(w, data, next)
+}
+
+func (h *APIHandler) getCase(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	c := h.caseService.GetByID(r.Context(), params["id"])
+	if c == nil {
+		writeAPIError(w, r, http.StatusNotFound, "not_found", "case not found: "+params["id"])
+		return
+	}
+	writeAPIData(w, toAPICase(c))
+}
+
+func (h *APIHandler) updateCaseStatus(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body apiStatusUpdate
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+
+	err := h.caseService.UpdateStatus(r.Context(), getUserFromContext(r), params["id"], body.Status)
+	switch {
+	case errors.Is(err, service.ErrCaseForbidden):
+		writeAPIError(w, r, http.StatusForbidden, "forbidden", err.Error())
+		return
+	case errors.Is(err, service.ErrCaseNotFound):
+		writeAPIError(w, r, http.StatusNotFound, "not_found", err.Error())
+		return
+	case errors.Is(err, service.ErrInvalidStatus):
+		writeAPIError(w, r, http.StatusUnprocessableEntity, "invalid_status", err.Error())
+		return
+	case errors.Is(err, service.ErrPublishRequired):
+		writeAPIError(w, r, http.StatusConflict, "publish_required", err.Error())
+		return
+	case errors.Is(err, service.ErrCasePublished):
+		writeAPIError(w, r, http.StatusConflict, "case_published", err.Error())
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Warn("status update failed", "case_id", params["id"], "error", err)
+		writeAPIError(w, r, http.StatusInternalServerError, "internal_error", "failed to update status")
+		return
+	}
+
+	writeAPIData(w, toAPICase(h.caseService.GetByID(r.Context(), params["id"])))
+}
+
+func (h *APIHandler) assignCase(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body apiAssignment
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+
+	err := h.caseService.Assign(r.Context(), getUserFromContext(r), params["id"], body.UserID)
+	switch {
+	case errors.Is(err, service.ErrCaseForbidden):
+		writeAPIError(w, r, http.StatusForbidden, "forbidden", err.Error())
+		return
+	case errors.Is(err, service.ErrCaseNotFound):
+		writeAPIError(w, r, http.StatusNotFound, "not_found", err.Error())
+		return
+	case errors.Is(err, service.ErrInvalidAssignee):
+		writeAPIError(w, r, http.StatusUnprocessableEntity, "invalid_assignee", err.Error())
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Warn("assignment failed", "case_id", params["id"], "error", err)
+		writeAPIError(w, r, http.StatusInternalServerError, "internal_error", "failed to assign case")
+		return
+	}
+	writeAPIData(w, toAPICase(h.caseService.GetByID(r.Context(), params["id"])))
+}
+
+func (h *APIHandler) publishCase(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body apiPublishRequest
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+
+	opinion, err := h.opinionService.Publish(r.Context(), getUserFromContext(r), params["id"], service.PublishRequest{
+		Title:             body.Title,
+		Summary:           body.Summary,
+		Topics:            body.Topics,
+		Statutes:          body.Statutes,
+		DocumentID:        body.DocumentID,
+		RedactionReviewed: body.RedactionReviewed,
+	})
+	switch {
+	case errors.Is(err, service.ErrPublishForbidden):
+		writeAPIError(w, r, http.StatusForbidden, "forbidden", err.Error())
+		return
+	case errors.Is(err, service.ErrCaseNotFound):
+		writeAPIError(w, r, http.StatusNotFound, "not_found", err.Error())
+		return
+	case err != nil:
+		writeAPIError(w, r, http.StatusUnprocessableEntity, "invalid_request", err.Error())
+		return
+	}
+	writeAPIData(w, toAPIOpinion(opinion))
+}
+
+func (h *APIHandler) listDeadlines(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	status := r.URL.Query().Get("status")
+	if !checkEnum(w, r, "status", []string{"upcoming", "due_soon", "overdue"}) {
+		return
+	}
+	var deadlines []*domain.Deadline
+	for _, d := range h.caseService.GetAllDeadlines(r.Context()) {
+		if status == "" || d.Status == status {
+			deadlines = append(deadlines, d)
+		}
+	}
+
+	page, next, ok := paginate(w, r, deadlines, false, func(d *domain.Deadline) pageKey {
+		return pageKey{At: d.DueDate, ID: d.ID}
+	})
+	if !ok {
+		return
+	}
+	data := make([]apiDeadline, 0, len(page))
+	for _, d := range page {
+		data = append(data, apiDeadline{
+			ID:           d.ID,
+			CaseID:       d.CaseID,
+			CaseNumber:   d.CaseNumber,
+			CaseType:     d.CaseType,
+			Summary:      d.Summary,
+			Type:         d.Type,
+			Status:       d.Status,
+			DueDate:      d.DueDate,
+			DaysUntilDue: d.DaysUntilDue(),
+		})
+	}
+	writeAPIList(w, data, next)
+}
+
+func (h *APIHandler) getStats(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	stats := h.dashboardService.GetStats()
+	writeAPIData(w, apiStats{
+		TotalOpen:        stats.TotalOpen,
+		TotalPending:     stats.TotalPending,
+		TotalOverdue:     stats.TotalOverdue,
+		TotalClosed:      stats.TotalClosed,
+		OpenedYTD:        stats.OpenedYTD,
+		ClosedYTD:        stats.ClosedYTD,
+		FiscalYear:       stats.FiscalYear,
+		OpenedFiscalYear: stats.OpenedFiscalYear,
+		ClosedFiscalYear: stats.ClosedFiscalYear,
+		ByType:           stats.ByType,
+		ByStatus:         stats.ByStatus,
+	})
+}
+
+func (h *APIHandler) searchOpinions(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	q := r.URL.Query()
+	if !checkEnum(w, r, "type", []string{string(domain.CaseTypeAdvisoryOpinion), string(domain.CaseTypeEthicsComplaint)}) {
+		return
+	}
+	if year := q.Get("year"); year != "" {
+		if _, err := strconv.Atoi(year); err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, "invalid_parameter", "year must be a number")
+			return
+		}
+	}
+	opinions := h.opinionService.Search(q.Get("q"), q.Get("type"), q.Get("year"), q.Get("topic"))
+
+	page, next, ok := paginate(w, r, opinions, true, func(o domain.PublishedOpinion) pageKey {
+		return pageKey{At: o.PublishedAt, ID: o.CaseNumber}
+	})
+	if !ok {
+		return
+	}
+	data := make([]apiOpinion, 0, len(page))
+	for i := range page {
+		data = append(data, toAPIOpinion(&page[i]))
+	}
+	writeAPIList(w, data, next)
+}
+
+func (h *APIHandler) getOpinion(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	o := h.opinionService.Get(params["case_number"])
+	if o == nil {
+		writeAPIError(w, r, http.StatusNotFound, "not_found", "no published opinion for "+params["case_number"])
+		return
+	}
+	writeAPIData(w, toAPIOpinion(o))
+}
+
+func toAPICase(c *domain.Case) apiCase {
+	return apiCase{
+		ID:              c.ID,
+		CaseNumber:      c.CaseNumber,
+		Type:            c.Type,
+		Status:          c.Status,
+		Priority:        c.Priority,
+		Summary:         c.Summary,
+		Description:     c.Description,
+		SubmitterName:   c.SubmitterName,
+		SubmitterAgency: c.SubmitterAgency,
+		SubjectName:     c.SubjectName,
+		SubjectAgency:   c.SubjectAgency,
+		Statutes:        citationStrings(c.Citations),
+		AssignedTo:      c.AssignedTo,
+		AssignedToName:  c.AssignedToName,
+		IsConfidential:  c.IsConfidential,
+		IsOverdue:       c.IsOverdue(),
+		SubmittedAt:     c.SubmittedAt,
+		DueDate:         optionalTime(c.DueDate),
+		ClosedAt:        c.ClosedAt,
+		PublishedAt:     c.PublishedAt,
+		UpdatedAt:       c.UpdatedAt,
+	}
+}
+
+func toAPIOpinion(o *domain.PublishedOpinion) apiOpinion {
+	topics := make([]string, 0, len(o.Topics))
+	topics = append(topics, o.Topics...)
+	return apiOpinion{
+		CaseNumber:  o.CaseNumber,
+		Type:        o.Type,
+		Title:       o.Title,
+		Summary:     o.Summary,
+		Topics:      topics,
+		Statutes:    citationStrings(o.Citations),
+		Year:        o.Year,
+		PublishedAt: o.PublishedAt,
+		URL:         "/opinions/" + o.CaseNumber,
+		DocumentURL: o.DocumentURL,
+	}
+}
+
+func citationStrings(citations []domain.StatuteCitation) []string {
+	result := make([]string, 0, len(citations))
+	for _, c := range citations {
+		result = append(result, c.String())
+	}
+	return result
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// pageKey is the sort position of an item: its timestamp, then its ID to
+// break ties. Cursors encode the key of the last item on a page, so paging
+// stays stable when items are added or removed between requests.
+type pageKey struct {
+	At time.Time `json:"t"`
+	ID string    `json:"id"`
+}
+
+func (k pageKey) before(other pageKey, desc bool) bool {
+	if !k.At.Equal(other.At) {
+		return k.At.After(other.At) == desc
+	}
+	return k.ID < other.ID
+}
+
+// paginate orders items by key (newest first when desc) and returns the page
+// selected by the cursor and limit query parameters, with the cursor for the
+// next page (nil on the last page). It writes a 400 and returns ok=false if
+// either parameter is invalid.
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T, desc bool, key func(T) pageKey) (page []T, next *string, ok bool) {
+	limit := defaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			writeAPIError(w, r, http.StatusBadRequest, "invalid_parameter", "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+			return nil, nil, false
+		}
+		limit = n
+	}
+
+	sorted := make([]T, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return key(sorted[i]).before(key(sorted[j]), desc)
+	})
+
+	start := 0
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		after, err := decodeCursor(v)
+		if err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, "invalid_cursor", "cursor is not valid; restart from the first page")
+			return nil, nil, false
+		}
+		start = sort.Search(len(sorted), func(i int) bool {
+			return after.before(key(sorted[i]), desc)
+		})
+	}
+
+	end := start + limit
+	if end >= len(sorted) {
+		return sorted[start:], nil, true
+	}
+	cursor := encodeCursor(key(sorted[end-1]))
+	return sorted[start:end], &cursor, true
+}
+
+func encodeCursor(k pageKey) string {
+	b, _ := json.Marshal(k)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (pageKey, error) {
+	var k pageKey
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return k, err
+	}
+	if err := json.Unmarshal(b, &k); err != nil {
+		return k, err
+	}
+	if k.ID == "" {
+		return k, errors.New("cursor has no position")
+	}
+	return k, nil
+}
+
+// matchRoute matches a request path against a route path, returning the
+// values of its {name} segments
+func matchRoute(pattern, path string) (map[string]string, bool) {
+	want := strings.Split(pattern, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, seg := range want {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if got[i] == "" {
+				return nil, false
+			}
+			params[seg[1:len(seg)-1]] = got[i]
+		} else if seg != got[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// checkEnum writes a 400 and returns false if a query parameter is set to a value outside values
+func checkEnum(w http.ResponseWriter, r *http.Request, name string, values []string) bool {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return true
+	}
+	for _, allowed := range values {
+		if v == allowed {
+			return true
+		}
+	}
+	writeAPIError(w, r, http.StatusBadRequest, "invalid_parameter", name+" must be one of "+strings.Join(values, ", "))
+	return false
+}
+
+// decodeAPIBody decodes a JSON request body into v. Requiring the JSON content
+// type keeps cookie-authenticated writes out of reach of cross-site forms.
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		writeAPIError(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type", "request body must be application/json")
+		return false
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, "invalid_body", "request body is not valid: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeAPIData(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func writeAPIList(w http.ResponseWriter, data interface{}, next *string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data, "next_cursor": next})
+}
+
+// writeAPIError writes the error envelope shared by every API error response
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeJSON(w, status, apiErrorEnvelope{Error: apiError{
+		Code:      code,
+		Message:   message,
+		RequestID: middleware.GetRequestID(r.Context()),
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("encoding API response", "error", err)
+	}
+}
@@ -1,24 +1,44 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"ncoe/internal/config"
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/middleware"
 	"ncoe/internal/service"
 	"ncoe/internal/templates"
 )
 
+const (
+	// ssoStateCookie binds a single sign-on callback to the browser that started it
+	ssoStateCookie = "sso_state"
+	// mfaLoginCookie carries a login waiting for its second factor
+	mfaLoginCookie = "mfa_login"
+)
+
 type AuthHandler struct {
 	authService *service.AuthService
+	mfaService  *service.MFAService
+	ssoService  *service.SSOService // nil when single sign-on is not configured
+	userService *service.UserService
 	tmpl        *templates.Renderer
 	branding    config.Branding
+	baseURL     string // Scheme and host for absolute links, from the configuration
 }
 
-func NewAuthHandler(as *service.AuthService, tmpl *templates.Renderer, b config.Branding) *AuthHandler {
+func NewAuthHandler(as *service.AuthService, ms *service.MFAService, sso *service.SSOService, us *service.UserService, tmpl *templates.Renderer, b config.Branding, baseURL string) *AuthHandler {
 	return &AuthHandler{
 		authService: as,
+		mfaService:  ms,
+		ssoService:  sso,
+		userService: us,
 		tmpl:        tmpl,
 		branding:    b,
+		baseURL:     baseURL,
 	}
 }
 
@@ -29,46 +49,249 @@ func (h *AuthHandler) StaffLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := map[string]interface{}{
-		"Title":    "Staff Login",
-		"Branding": h.branding,
+	if r.URL.Query().Get("password") == "set" {
+		h.renderLoginNotice(w, "Your password is set. Sign in to continue.")
+		return
 	}
-
-	h.render(w, "auth/staff_login", data)
+	h.renderLogin(w, "", http.StatusOK)
 }
 
 func (h *AuthHandler) handleStaffLogin(w http.ResponseWriter, r *http.Request) {
+	if h.ssoService != nil && h.ssoService.Exclusive() {
+		h.renderLogin(w, "Password login is disabled. Sign in with "+h.ssoService.ProviderName()+".", http.StatusForbidden)
+		return
+	}
+
 	r.ParseForm()
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 
-	session, err := h.authService.LoginStaff(email, password)
+	session, challenge, err := h.authService.LoginStaff(r.Context(), email, password, clientInfo(r))
+	if errors.Is(err, service.ErrUserInactive) {
+		h.renderLogin(w, "Your account is deactivated. Contact an administrator.", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		h.renderLogin(w, "Invalid credentials", http.StatusOK)
+		return
+	}
+
+	// The session only starts once the second factor is checked
+	if challenge != nil {
+		setMFALoginCookie(w, r, challenge, http.SameSiteStrictMode)
+		http.Redirect(w, r, "/staff/login/mfa", http.StatusSeeOther)
+		return
+	}
+
+	setSessionCookie(w, r, session.Token, http.SameSiteStrictMode)
+	http.Redirect(w, r, "/staff/dashboard", http.StatusSeeOther)
+}
+
+// StaffLoginMFA handles /staff/login/mfa, the second login step. Users who
+// must enroll are shown their new authenticator secret here first.
+func (h *AuthHandler) StaffLoginMFA(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(mfaLoginCookie)
+	if err != nil {
+		http.Redirect(w, r, "/staff/login", http.StatusSeeOther)
+		return
+	}
+	challenge, err := h.authService.Challenge(cookie.Value)
+	if err != nil {
+		clearMFALoginCookie(w)
+		h.renderLogin(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.renderMFA(w, challenge, "", http.StatusOK)
+		return
+	}
+
+	r.ParseForm()
+	session, recoveryCodes, err := h.authService.CompleteLogin(r.Context(), challenge.Token, r.FormValue("code"))
+	if errors.Is(err, service.ErrMFAChallengeExpired) {
+		clearMFALoginCookie(w)
+		h.renderLogin(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
-		data := map[string]interface{}{
-			"Title":    "Staff Login",
-			"Branding": h.branding,
-			"Error":    "Invalid credentials",
+		h.renderMFA(w, challenge, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	clearMFALoginCookie(w)
+	setSessionCookie(w, r, session.Token, http.SameSiteStrictMode)
+	if len(recoveryCodes) > 0 {
+		// A login that enrolled the user shows their recovery codes once
+		h.render(w, "auth/staff_mfa", map[string]interface{}{
+			"Title":         "Save Your Recovery Codes",
+			"Branding":      h.branding,
+			"RecoveryCodes": recoveryCodes,
+		})
+		return
+	}
+	http.Redirect(w, r, "/staff/dashboard", http.StatusSeeOther)
+}
+
+func (h *AuthHandler) renderMFA(w http.ResponseWriter, challenge *service.MFAChallenge, errMsg string, status int) {
+	data := map[string]interface{}{
+		"Title":    "Two-Factor Authentication",
+		"Branding": h.branding,
+		"Enroll":   challenge.Enroll,
+		"Error":    errMsg,
+	}
+	if challenge.Enroll {
+		secret, uri, err := h.mfaService.BeginEnrollment(challenge.User)
+		if err != nil {
+			h.renderLogin(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
-		h.render(w, "auth/staff_login", data)
+		data["Secret"] = secret
+		data["ProvisioningURI"] = uri
+	}
+
+	w.WriteHeader(status)
+	h.render(w, "auth/staff_mfa", data)
+}
+
+// SSOLogin handles /staff/login/sso, sending the user to the identity provider
+func (h *AuthHandler) SSOLogin(w http.ResponseWriter, r *http.Request) {
+	if h.ssoService == nil {
+		http.NotFound(w, r)
 		return
 	}
 
-	// Set session cookie
+	authURL, state, err := h.ssoService.Begin(r.Context(), h.baseURL+"/staff/login/sso/callback")
+	if err != nil {
+		logging.FromContext(r.Context()).Error("starting single sign-on", "error", err)
+		h.renderLogin(w, h.ssoService.ProviderName()+" is unavailable. Please try again later.", http.StatusBadGateway)
+		return
+	}
+
+	// Lax, not Strict: the cookie must come back on the provider's redirect
 	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    session.Token,
-		Path:     "/",
+		Name:     ssoStateCookie,
+		Value:    state,
+		Path:     "/staff/login/sso",
+		MaxAge:   int((10 * time.Minute).Seconds()),
 		HttpOnly: true,
 		Secure:   r.TLS != nil,
-		SameSite: http.SameSiteStrictMode,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// SSOCallback handles /staff/login/sso/callback, where the identity provider
+// returns the user with an authorization code
+func (h *AuthHandler) SSOCallback(w http.ResponseWriter, r *http.Request) {
+	if h.ssoService == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    "",
+		Path:     "/staff/login/sso",
+		MaxAge:   -1,
+		HttpOnly: true,
 	})
 
+	q := r.URL.Query()
+	if providerErr := q.Get("error"); providerErr != "" {
+		logging.FromContext(r.Context()).Warn("single sign-on refused by provider", "error", providerErr, "description", q.Get("error_description"))
+		h.renderLogin(w, "Sign-in was cancelled or refused by "+h.ssoService.ProviderName()+".", http.StatusUnauthorized)
+		return
+	}
+	cookie, err := r.Cookie(ssoStateCookie)
+	if err != nil || q.Get("state") == "" || cookie.Value != q.Get("state") {
+		h.renderLogin(w, "Your sign-in request expired. Please try again.", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.ssoService.Complete(r.Context(), q.Get("state"), q.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSSOState):
+			h.renderLogin(w, "Your sign-in request expired. Please try again.", http.StatusBadRequest)
+		case errors.Is(err, service.ErrNoRoleMapping):
+			h.renderLogin(w, "Your account is not authorized for the staff portal. Contact an administrator.", http.StatusForbidden)
+		case errors.Is(err, service.ErrUserInactive):
+			h.renderLogin(w, "Your account is deactivated. Contact an administrator.", http.StatusForbidden)
+		default:
+			// Token validation failures and provider outages look the same to the user
+			logging.FromContext(r.Context()).Error("completing single sign-on", "error", err)
+			h.renderLogin(w, "Single sign-on failed. Please try again.", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	session, challenge, err := h.authService.LoginSSO(r.Context(), user, clientInfo(r))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("starting session", "user_id", user.ID, "error", err)
+		h.renderLogin(w, "Single sign-on failed. Please try again.", http.StatusInternalServerError)
+		return
+	}
+
+	// Lax, not Strict: browsers treat the redirect from here as part of the
+	// cross-site navigation from the provider and would withhold a Strict cookie
+	if challenge != nil {
+		setMFALoginCookie(w, r, challenge, http.SameSiteLaxMode)
+		http.Redirect(w, r, "/staff/login/mfa", http.StatusSeeOther)
+		return
+	}
+	setSessionCookie(w, r, session.Token, http.SameSiteLaxMode)
+
 	http.Redirect(w, r, "/staff/dashboard", http.StatusSeeOther)
 }
 
-// Logout handles user logout
+// SetPassword handles /staff/password, where invitation and password reset
+// links let a user choose a password
+func (h *AuthHandler) SetPassword(w http.ResponseWriter, r *http.Request) {
+	// The token is in the URL; keep it out of Referer headers
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	r.ParseForm()
+	token := r.FormValue("token")
+
+	user, err := h.userService.PasswordLinkUser(token)
+	if err != nil {
+		h.renderLogin(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.renderSetPassword(w, user, token, "", http.StatusOK)
+		return
+	}
+
+	if _, err := h.userService.SetPassword(r.Context(), token, r.FormValue("password"), r.FormValue("confirm")); err != nil {
+		h.renderSetPassword(w, user, token, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/staff/login?password=set", http.StatusSeeOther)
+}
+
+func (h *AuthHandler) renderSetPassword(w http.ResponseWriter, user *domain.User, token, errMsg string, status int) {
+	title := "Reset Your Password"
+	if user.InvitedAt != nil {
+		title = "Set Up Your Account"
+	}
+	w.WriteHeader(status)
+	h.render(w, "auth/set_password", map[string]interface{}{
+		"Title":             title,
+		"Branding":          h.branding,
+		"Email":             user.Email,
+		"Token":             token,
+		"MinPasswordLength": service.MinPasswordLength,
+		"Error":             errMsg,
+	})
+}
+
+// Logout handles user logout, ending the session on the server as well as
+// clearing the cookie, so a copied cookie cannot be replayed
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	// Clear session cookie
+	if token := sessionToken(r); token != "" {
+		h.authService.Logout(token)
+	}
+
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session",
 		Value:    "",
@@ -80,6 +303,66 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/staff/login", http.StatusSeeOther)
 }
 
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, sameSite http.SameSite) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: sameSite,
+	})
+}
+
+// clientInfo describes the browser making the request, for the signed-in list
+func clientInfo(r *http.Request) service.ClientInfo {
+	return service.ClientInfo{IP: middleware.RemoteIP(r), UserAgent: r.UserAgent()}
+}
+
+// setMFALoginCookie keeps the pending login for the second step at
+// /staff/login/mfa until the challenge expires
+func setMFALoginCookie(w http.ResponseWriter, r *http.Request, challenge *service.MFAChallenge, sameSite http.SameSite) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     mfaLoginCookie,
+		Value:    challenge.Token,
+		Path:     "/staff/login",
+		MaxAge:   int(time.Until(challenge.ExpiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: sameSite,
+	})
+}
+
+func clearMFALoginCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     mfaLoginCookie,
+		Value:    "",
+		Path:     "/staff/login",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func (h *AuthHandler) renderLogin(w http.ResponseWriter, errMsg string, status int) {
+	h.renderLoginPage(w, map[string]interface{}{"Error": errMsg}, status)
+}
+
+func (h *AuthHandler) renderLoginNotice(w http.ResponseWriter, notice string) {
+	h.renderLoginPage(w, map[string]interface{}{"Notice": notice}, http.StatusOK)
+}
+
+func (h *AuthHandler) renderLoginPage(w http.ResponseWriter, data map[string]interface{}, status int) {
+	data["Title"] = "Staff Login"
+	data["Branding"] = h.branding
+	if h.ssoService != nil {
+		data["SSOName"] = h.ssoService.ProviderName()
+		data["PasswordLoginDisabled"] = h.ssoService.Exclusive()
+	}
+
+	w.WriteHeader(status)
+	h.render(w, "auth/staff_login", data)
+}
+
 func (h *AuthHandler) render(w http.ResponseWriter, name string, data interface{}) {
 	err := h.tmpl.ExecuteTemplate(w, name, data)
 	if err != nil {
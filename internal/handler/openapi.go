@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ncoe/internal/domain"
+)
+
+// The OpenAPI document is generated from the API route table and the
+// response types, so the spec cannot drift from what the handlers return.
+// Only the subset of OpenAPI 3.0 the API uses is modelled here.
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers"`
+	Security   []map[string][]string                   `json:"security"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Scope       string                      `json:"x-required-scope,omitempty"` // Scope an API token needs
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    *[]map[string][]string      `json:"security,omitempty"` // Empty for public operations
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Minimum              *int                      `json:"minimum,omitempty"`
+	Maximum              *int                      `json:"maximum,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties interface{}               `json:"additionalProperties,omitempty"` // false, or a schema for map values
+}
+
+// enumValues lists the allowed values of string types exposed by the API
+var enumValues = map[reflect.Type][]string{
+	reflect.TypeOf(domain.CaseType("")):   caseTypeValues(),
+	reflect.TypeOf(domain.CaseStatus("")): caseStatusValues(),
+}
+
+func caseTypeValues() []string {
+	var values []string
+	for _, t := range domain.CaseTypes {
+		values = append(values, string(t))
+	}
+	return values
+}
+
+func caseStatusValues() []string {
+	var values []string
+	for _, s := range domain.CaseStatuses {
+		values = append(values, string(s))
+	}
+	return values
+}
+
+// buildOpenAPI describes the routes as an OpenAPI 3.0 document
+func buildOpenAPI(routes []apiRoute) *openAPIDocument {
+	schemas := map[string]*openAPISchema{}
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "NCOE Case Management API",
+			Version:     "1.0.0",
+			Description: "Cases, deadlines and published opinions. List endpoints use cursor pagination: pass next_cursor from one page as cursor to fetch the next. Errors share one envelope carrying the request ID.",
+		},
+		Servers:  []openAPIServer{{URL: apiPrefix}},
+		Security: []map[string][]string{{"bearerToken": {}}, {"session": {}}},
+		Paths:    map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: schemas,
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"bearerToken": {Type: "http", Scheme: "bearer", Description: "Personal API token minted on the staff Settings page. Each operation states the scope it needs."},
+				"session":     {Type: "apiKey", In: "cookie", Name: "session", Description: "Staff portal session cookie"},
+			},
+		},
+	}
+	errorRef := schemaFor(reflect.TypeOf(apiErrorEnvelope{}), schemas)
+
+	for _, rt := range routes {
+		op := &openAPIOperation{
+			OperationID: rt.ID,
+			Summary:     rt.Summary,
+			Tags:        []string{rt.Tag},
+			Responses:   map[string]*openAPIResponse{},
+		}
+		if rt.Public {
+			op.Security = &[]map[string][]string{}
+		} else {
+			op.Description = "API tokens need the " + string(rt.Scope) + " scope."
+			op.Scope = string(rt.Scope)
+		}
+
+		for _, name := range pathParams(rt.Path) {
+			op.Parameters = append(op.Parameters, openAPIParameter{Name: name, In: "path", Required: true, Schema: &openAPISchema{Type: "string"}})
+		}
+		for _, p := range rt.Query {
+			op.Parameters = append(op.Parameters, p.openAPI())
+		}
+		if rt.List {
+			op.Parameters = append(op.Parameters, cursorParams...)
+		}
+
+		if rt.Body != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content:  jsonContent(schemaFor(reflect.TypeOf(rt.Body), schemas)),
+			}
+		}
+
+		data := schemaFor(reflect.TypeOf(rt.Response), schemas)
+		envelope := &openAPISchema{
+			Type:                 "object",
+			Properties:           map[string]*openAPISchema{"data": data},
+			Required:             []string{"data"},
+			AdditionalProperties: false,
+		}
+		if rt.List {
+			envelope.Properties["data"] = &openAPISchema{Type: "array", Items: data}
+			envelope.Properties["next_cursor"] = &openAPISchema{Type: "string", Nullable: true, Description: "Cursor for the next page; null on the last page"}
+			envelope.Required = append(envelope.Required, "next_cursor")
+		}
+		op.Responses["200"] = &openAPIResponse{Description: "OK", Content: jsonContent(envelope)}
+
+		errs := append([]int{}, rt.Errors...)
+		if rt.List || len(rt.Query) > 0 {
+			errs = append(errs, http.StatusBadRequest)
+		}
+		if !rt.Public {
+			errs = append(errs, http.StatusUnauthorized)
+			if !containsStatus(errs, http.StatusForbidden) {
+				errs = append(errs, http.StatusForbidden) // Token without the scope
+			}
+		}
+		for _, status := range errs {
+			op.Responses[strconv.Itoa(status)] = &openAPIResponse{Description: http.StatusText(status), Content: jsonContent(errorRef)}
+		}
+
+		if doc.Paths[rt.Path] == nil {
+			doc.Paths[rt.Path] = map[string]*openAPIOperation{}
+		}
+		doc.Paths[rt.Path][strings.ToLower(rt.Method)] = op
+	}
+	return doc
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonContent(schema *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: schema}}
+}
+
+// schemaFor returns the schema for a Go type. Structs are registered as named
+// components (apiCase becomes "Case") and referenced; fields are described by
+// their json tags, and fields without omitempty are required.
+func schemaFor(t reflect.Type, components map[string]*openAPISchema) *openAPISchema {
+	if values, ok := enumValues[t]; ok {
+		return &openAPISchema{Type: "string", Enum: values}
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := *schemaFor(t.Elem(), components)
+		s.Nullable = true
+		return &s
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice:
+		return &openAPISchema{Type: "array", Items: schemaFor(t.Elem(), components)}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), components)}
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "api")
+		if _, ok := components[name]; !ok {
+			s := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}, AdditionalProperties: false}
+			components[name] = s // Registered before the fields so recursive types terminate
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				tag := f.Tag.Get("json")
+				if tag == "-" || !f.IsExported() {
+					continue
+				}
+				fieldName, opts, _ := strings.Cut(tag, ",")
+				if fieldName == "" {
+					fieldName = f.Name
+				}
+				fs := schemaFor(f.Type, components)
+				if doc := f.Tag.Get("doc"); doc != "" {
+					fs.Description = doc
+				}
+				s.Properties[fieldName] = fs
+				if opts != "omitempty" {
+					s.Required = append(s.Required, fieldName)
+				}
+			}
+			sort.Strings(s.Required)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	}
+	return &openAPISchema{}
+}
+
+// pathParams returns the {name} segments of a route path
+func pathParams(path string) []string {
+	var names []string
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			names = append(names, seg[1:len(seg)-1])
+		}
+	}
+	return names
+}
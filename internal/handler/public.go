@@ -1,27 +1,44 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"ncoe/internal/config"
 	"ncoe/internal/domain"
+	"ncoe/internal/feed"
+	"ncoe/internal/middleware"
 	"ncoe/internal/service"
 	"ncoe/internal/templates"
 )
 
 type PublicHandler struct {
-	caseService *service.CaseService
-	tmpl        *templates.Renderer
-	branding    config.Branding
+	caseService    *service.CaseService
+	opinionService *service.OpinionService
+	intakeService  *service.IntakeService
+	tmpl           *templates.Renderer
+	branding       config.Branding
+	baseURL        string // Scheme and host for absolute links, from the configuration
 }
 
-func NewPublicHandler(cs *service.CaseService, tmpl *templates.Renderer, b config.Branding) *PublicHandler {
+func NewPublicHandler(cs *service.CaseService, os *service.OpinionService, is *service.IntakeService, tmpl *templates.Renderer, b config.Branding, baseURL string) *PublicHandler {
 	return &PublicHandler{
-		caseService: cs,
-		tmpl:        tmpl,
-		branding:    b,
+		caseService:    cs,
+		opinionService: os,
+		intakeService:  is,
+		tmpl:           tmpl,
+		branding:       b,
+		baseURL:        baseURL,
 	}
 }
 
@@ -47,12 +64,7 @@ func (h *PublicHandler) SubmitAdvisoryOpinion(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	data := map[string]interface{}{
-		"Title":    "Request Advisory Opinion",
-		"Branding": h.branding,
-	}
-
-	h.render(w, "public/submit_advisory", data)
+	h.render(w, "public/submit_advisory", h.formData("Request Advisory Opinion", ""))
 }
 
 func (h *PublicHandler) handleAdvisoryOpinionSubmission(w http.ResponseWriter, r *http.Request) {
@@ -71,13 +83,7 @@ func (h *PublicHandler) handleAdvisoryOpinionSubmission(w http.ResponseWriter, r
 		SubmittedAt:     time.Now(),
 	}
 
-	caseNumber, err := h.caseService.Create(c)
-	if err != nil {
-		http.Error(w, "Failed to submit request", http.StatusInternalServerError)
-		return
-	}
-
-	http.Redirect(w, r, "/submit/confirmation?case="+caseNumber+"&type=advisory", http.StatusSeeOther)
+	h.submit(w, r, c, "advisory", "submit_advisory", "Request Advisory Opinion")
 }
 
 // SubmitEthicsComplaint handles ethics complaint submissions
@@ -87,12 +93,7 @@ func (h *PublicHandler) SubmitEthicsComplaint(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	data := map[string]interface{}{
-		"Title":    "File Ethics Complaint",
-		"Branding": h.branding,
-	}
-
-	h.render(w, "public/submit_complaint", data)
+	h.render(w, "public/submit_complaint", h.formData("File Ethics Complaint", ""))
 }
 
 func (h *PublicHandler) handleComplaintSubmission(w http.ResponseWriter, r *http.Request) {
@@ -109,17 +110,11 @@ func (h *PublicHandler) handleComplaintSubmission(w http.ResponseWriter, r *http
 		SubjectAgency:    r.FormValue("subject_agency"),
 		Summary:          r.FormValue("allegation_summary"),
 		Description:      r.FormValue("allegation_detail"),
-		StatuteCitations: r.FormValue("statutes"),
+		StatuteCitations: r.FormValue("provisions"),
 		SubmittedAt:      time.Now(),
 	}
 
-	caseNumber, err := h.caseService.Create(c)
-	if err != nil {
-		http.Error(w, "Failed to submit complaint", http.StatusInternalServerError)
-		return
-	}
-
-	http.Redirect(w, r, "/submit/confirmation?case="+caseNumber+"&type=complaint", http.StatusSeeOther)
+	h.submit(w, r, c, "complaint", "submit_complaint", "File Ethics Complaint")
 }
 
 // SubmitAcknowledgment handles ethics acknowledgment submissions
@@ -129,12 +124,7 @@ func (h *PublicHandler) SubmitAcknowledgment(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	data := map[string]interface{}{
-		"Title":    "File Ethics Acknowledgment",
-		"Branding": h.branding,
-	}
-
-	h.render(w, "public/submit_acknowledgment", data)
+	h.render(w, "public/submit_acknowledgment", h.formData("File Ethics Acknowledgment", ""))
 }
 
 func (h *PublicHandler) handleAcknowledgmentSubmission(w http.ResponseWriter, r *http.Request) {
@@ -152,13 +142,7 @@ func (h *PublicHandler) handleAcknowledgmentSubmission(w http.ResponseWriter, r
 		SubmittedAt:     time.Now(),
 	}
 
-	caseNumber, err := h.caseService.Create(c)
-	if err != nil {
-		http.Error(w, "Failed to submit acknowledgment", http.StatusInternalServerError)
-		return
-	}
-
-	http.Redirect(w, r, "/submit/confirmation?case="+caseNumber+"&type=acknowledgment", http.StatusSeeOther)
+	h.submit(w, r, c, "acknowledgment", "submit_acknowledgment", "File Ethics Acknowledgment")
 }
 
 // SubmitRecordsRequest handles public records request submissions
@@ -168,12 +152,7 @@ func (h *PublicHandler) SubmitRecordsRequest(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	data := map[string]interface{}{
-		"Title":    "Public Records Request",
-		"Branding": h.branding,
-	}
-
-	h.render(w, "public/submit_records", data)
+	h.render(w, "public/submit_records", h.formData("Public Records Request", ""))
 }
 
 func (h *PublicHandler) handleRecordsRequestSubmission(w http.ResponseWriter, r *http.Request) {
@@ -190,13 +169,48 @@ func (h *PublicHandler) handleRecordsRequestSubmission(w http.ResponseWriter, r
 		SubmittedAt:     time.Now(),
 	}
 
-	caseNumber, err := h.caseService.Create(c)
+	h.submit(w, r, c, "records", "submit_records", "Public Records Request")
+}
+
+// formData returns the data for a submission form, with the intake
+// checks' hidden fields
+func (h *PublicHandler) formData(title, errMsg string) map[string]interface{} {
+	return map[string]interface{}{
+		"Title":     title,
+		"Branding":  h.branding,
+		"FormToken": h.intakeService.FormToken(),
+		"Challenge": h.intakeService.ChallengeWidget(),
+		"Error":     errMsg,
+	}
+}
+
+// submit passes a submission through the intake checks and confirms it. A
+// submission held for triage is confirmed without a case number and
+// without saying why it was held.
+func (h *PublicHandler) submit(w http.ResponseWriter, r *http.Request, c *domain.Case, kind, page, title string) {
+	result, err := h.intakeService.Submit(r.Context(), service.Submission{
+		Case:              c,
+		IP:                middleware.RemoteIP(r),
+		FormToken:         r.FormValue(service.FormTokenField),
+		Honeypot:          r.FormValue(service.HoneypotField),
+		ChallengeResponse: r.FormValue(service.ChallengeField),
+	})
+	if errors.Is(err, service.ErrRateLimited) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		h.render(w, "public/"+page, h.formData(title, err.Error()))
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to submit request", http.StatusInternalServerError)
+		http.Error(w, "Failed to submit", http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, "/submit/confirmation?case="+caseNumber+"&type=records", http.StatusSeeOther)
+	query := url.Values{"type": {kind}}
+	if !result.Held {
+		query.Set("case", result.CaseNumber)
+	}
+	http.Redirect(w, r, "/submit/confirmation?"+query.Encode(), http.StatusSeeOther)
 }
 
 // Confirmation shows submission confirmation
@@ -223,7 +237,19 @@ func (h *PublicHandler) Search(w http.ResponseWriter, r *http.Request) {
 
 	var results []domain.PublishedOpinion
 	if query != "" || docType != "" || year != "" || topic != "" {
-		results = h.caseService.SearchPublished(query, docType, year, topic)
+		results = h.opinionService.Search(query, docType, year, topic)
+	}
+
+	// Atom feed with the same filters, so a search can be subscribed to
+	feedParams := url.Values{}
+	for key, value := range map[string]string{"q": query, "type": docType, "year": year, "topic": topic} {
+		if value != "" {
+			feedParams.Set(key, value)
+		}
+	}
+	feedURL := "/feeds/opinions.atom"
+	if len(feedParams) > 0 {
+		feedURL += "?" + feedParams.Encode()
 	}
 
 	data := map[string]interface{}{
@@ -234,6 +260,7 @@ func (h *PublicHandler) Search(w http.ResponseWriter, r *http.Request) {
 		"Year":     year,
 		"Topic":    topic,
 		"Results":  results,
+		"FeedURL":  feedURL,
 		"Topics":   []string{"Conflicts of Interest", "Gifts", "Voting", "Employment", "Financial Disclosure"},
 		"Years":    []string{"2024", "2023", "2022", "2021", "2020"},
 	}
@@ -241,11 +268,22 @@ func (h *PublicHandler) Search(w http.ResponseWriter, r *http.Request) {
 	h.render(w, "public/search", data)
 }
 
-// ViewOpinion shows a single published opinion
+// ViewOpinion shows a single published opinion (or routes to /{caseNumber}/document)
 func (h *PublicHandler) ViewOpinion(w http.ResponseWriter, r *http.Request) {
-	caseNumber := strings.TrimPrefix(r.URL.Path, "/opinions/")
+	path := strings.TrimPrefix(r.URL.Path, "/opinions/")
+	parts := strings.Split(path, "/")
+	caseNumber := parts[0]
 
-	opinion := h.caseService.GetPublishedOpinion(caseNumber)
+	if len(parts) == 2 && parts[1] == "document" {
+		h.OpinionDocument(w, r, caseNumber)
+		return
+	}
+	if len(parts) > 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	opinion := h.opinionService.Get(caseNumber)
 	if opinion == nil {
 		http.NotFound(w, r)
 		return
@@ -260,6 +298,170 @@ func (h *PublicHandler) ViewOpinion(w http.ResponseWriter, r *http.Request) {
 	h.render(w, "public/opinion", data)
 }
 
+// Statutes shows the NRS 281A cross-reference index (/statutes) or the
+// opinions interpreting one section (/statutes/{section})
+func (h *PublicHandler) Statutes(w http.ResponseWriter, r *http.Request) {
+	section := strings.Trim(strings.TrimPrefix(r.URL.Path, "/statutes"), "/")
+
+	if section == "" {
+		data := map[string]interface{}{
+			"Title":    "NRS 281A Index",
+			"Branding": h.branding,
+			"Entries":  h.opinionService.StatuteIndex(),
+		}
+		h.render(w, "public/statute", data)
+		return
+	}
+
+	key, opinions, ok := h.opinionService.BySection(section)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if key != section {
+		http.Redirect(w, r, "/statutes/"+key, http.StatusMovedPermanently)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":    "NRS " + key,
+		"Branding": h.branding,
+		"Section":  key,
+		"Citation": "NRS " + key,
+		"Opinions": opinions,
+	}
+	h.render(w, "public/statute", data)
+}
+
+// OpinionFeed serves an Atom feed of published opinions (/feeds/opinions.atom).
+// It accepts the same q, type, year and topic filters as Search; with no
+// filters it lists every opinion.
+func (h *PublicHandler) OpinionFeed(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/feeds/opinions.atom" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Canonical self link: only recognized filters, in a stable order
+	params := url.Values{}
+	for _, key := range []string{"q", "type", "year", "topic"} {
+		if v := strings.TrimSpace(r.URL.Query().Get(key)); v != "" {
+			params.Set(key, v)
+		}
+	}
+	opinions := h.opinionService.Search(params.Get("q"), params.Get("type"), params.Get("year"), params.Get("topic"))
+
+	self := "/feeds/opinions.atom"
+	if len(params) > 0 {
+		self += "?" + params.Encode()
+	}
+
+	title := h.branding.ShortName + " Published Opinions & Orders"
+	switch domain.CaseType(params.Get("type")) {
+	case domain.CaseTypeAdvisoryOpinion:
+		title = h.branding.ShortName + " Advisory Opinions"
+	case domain.CaseTypeEthicsComplaint:
+		title = h.branding.ShortName + " Final Orders"
+	}
+	var qualifiers []string
+	if topic := params.Get("topic"); topic != "" {
+		qualifiers = append(qualifiers, "Topic: "+topic)
+	}
+	if year := params.Get("year"); year != "" {
+		qualifiers = append(qualifiers, "Year: "+year)
+	}
+	if q := params.Get("q"); q != "" {
+		qualifiers = append(qualifiers, "Matching: "+q)
+	}
+	if len(qualifiers) > 0 {
+		title += " (" + strings.Join(qualifiers, ", ") + ")"
+	}
+
+	body, modified, err := feed.Atom(feed.Options{
+		BaseURL:  h.baseURL,
+		SelfPath: self,
+		Title:    title,
+		Subtitle: "Opinions and orders published by the " + h.branding.AgencyName,
+		Author:   h.branding.AgencyName,
+	}, opinions)
+	if err != nil {
+		http.Error(w, "Failed to build feed", http.StatusInternalServerError)
+		return
+	}
+	serveCacheable(w, r, feed.AtomContentType, body, modified)
+}
+
+// Sitemap serves /sitemap.xml covering public pages, statute index pages and every published opinion
+func (h *PublicHandler) Sitemap(w http.ResponseWriter, r *http.Request) {
+	pages := []string{"/", "/search", "/statutes"}
+	for _, entry := range h.opinionService.StatuteIndex() {
+		pages = append(pages, "/statutes/"+entry.Section)
+	}
+
+	body, modified, err := feed.Sitemap(h.baseURL, pages, h.opinionService.Search("", "", "", ""))
+	if err != nil {
+		http.Error(w, "Failed to build sitemap", http.StatusInternalServerError)
+		return
+	}
+	serveCacheable(w, r, "application/xml; charset=utf-8", body, modified)
+}
+
+// Robots serves /robots.txt, keeping crawlers out of the staff portal and pointing them at the sitemap
+func (h *PublicHandler) Robots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "User-agent: *\nDisallow: /staff/\n\nSitemap: %s/sitemap.xml\n", h.baseURL)
+}
+
+// serveCacheable writes a generated document with Last-Modified and a
+// content-hash ETag; http.ServeContent answers conditional requests with 304
+func serveCacheable(w http.ResponseWriter, r *http.Request, contentType string, body []byte, modified time.Time) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
+// OpinionDocument serves the public final document of a published opinion
+func (h *PublicHandler) OpinionDocument(w http.ResponseWriter, r *http.Request, caseNumber string) {
+	doc := h.opinionService.GetDocument(caseNumber)
+	if doc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	serveDocument(w, r, doc, "inline")
+}
+
+// PublicDocument serves a released redacted document (/documents/{id})
+func (h *PublicHandler) PublicDocument(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/documents/")
+	doc := h.caseService.GetPublicDocument(r.Context(), id)
+	if doc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	serveDocument(w, r, doc, "inline")
+}
+
+// serveDocument writes a stored document with its original filename. Only
+// PDFs are shown inline; anything else, such as HTML or SVG an uploader
+// supplied, is downloaded and sandboxed so it never runs as this site.
+func serveDocument(w http.ResponseWriter, r *http.Request, doc *domain.Document, disposition string) {
+	contentType := doc.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/pdf" {
+		disposition = "attachment"
+		w.Header().Set("Content-Security-Policy", "sandbox")
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": doc.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, doc.Filename, doc.UploadedAt, bytes.NewReader(doc.Content))
+}
+
 func (h *PublicHandler) render(w http.ResponseWriter, name string, data interface{}) {
 	err := h.tmpl.ExecuteTemplate(w, name, data)
 	if err != nil {
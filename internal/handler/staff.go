@@ -1,37 +1,77 @@
 package handler
 
 import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"ncoe/internal/config"
+	"ncoe/internal/docgen"
 	"ncoe/internal/domain"
 	"ncoe/internal/service"
 	"ncoe/internal/templates"
+	"ncoe/internal/xlsx"
 )
 
 type StaffHandler struct {
 	caseService      *service.CaseService
 	dashboardService *service.DashboardService
+	opinionService   *service.OpinionService
+	redactionService *service.RedactionService
+	tokenService     *service.APITokenService
+	webhookService   *service.WebhookService
+	mfaService       *service.MFAService
+	authService      *service.AuthService
+	userService      *service.UserService
+	auditService     *service.AuditService
+	reportService    *service.ReportService
+	ackService       *service.AcknowledgmentService
+	annualService    *service.AnnualReportService
+	intakeService    *service.IntakeService
+	mailService      *service.MailService // nil when no SMTP relay is configured
 	tmpl             *templates.Renderer
 	branding         config.Branding
+	baseURL          string // Scheme and host for absolute links, from the configuration
 }
 
-func NewStaffHandler(cs *service.CaseService, ds *service.DashboardService, tmpl *templates.Renderer, b config.Branding) *StaffHandler {
+func NewStaffHandler(cs *service.CaseService, ds *service.DashboardService, os *service.OpinionService, rs *service.RedactionService, ts *service.APITokenService, ws *service.WebhookService, ms *service.MFAService, as *service.AuthService, us *service.UserService, aus *service.AuditService, rps *service.ReportService, acs *service.AcknowledgmentService, ans *service.AnnualReportService, is *service.IntakeService, mls *service.MailService, tmpl *templates.Renderer, b config.Branding, baseURL string) *StaffHandler {
 	return &StaffHandler{
 		caseService:      cs,
 		dashboardService: ds,
+		opinionService:   os,
+		redactionService: rs,
+		tokenService:     ts,
+		webhookService:   ws,
+		mfaService:       ms,
+		authService:      as,
+		userService:      us,
+		auditService:     aus,
+		reportService:    rps,
+		ackService:       acs,
+		annualService:    ans,
+		intakeService:    is,
+		mailService:      mls,
 		tmpl:             tmpl,
 		branding:         b,
+		baseURL:          baseURL,
 	}
 }
 
 // Dashboard shows the staff dashboard with KPIs
 func (h *StaffHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 	stats := h.dashboardService.GetStats()
-	recentCases := h.caseService.GetRecent(10)
-	deadlines := h.caseService.GetUpcomingDeadlines(5)
+	recentCases := h.caseService.GetRecent(r.Context(), 10)
+	deadlines := h.caseService.GetUpcomingDeadlines(r.Context(), 5)
 
 	data := map[string]interface{}{
 		"Title":     "Dashboard",
@@ -44,23 +84,34 @@ func (h *StaffHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		"ActiveNav": "dashboard",
 	}
 
-	h.render(w, "staff/dashboard", data)
+	h.page(w, "dashboard", data)
 }
 
 // CaseList shows all cases with filtering
 func (h *StaffHandler) CaseList(w http.ResponseWriter, r *http.Request) {
+	h.page(w, "cases", h.caseListData(r))
+}
+
+// CaseTable returns the filtered case table (HTMX fragment: /_table)
+func (h *StaffHandler) CaseTable(w http.ResponseWriter, r *http.Request) {
+	h.fragment(w, "cases_table", h.caseListData(r))
+}
+
+func (h *StaffHandler) caseListData(r *http.Request) map[string]interface{} {
 	// Parse filter parameters
 	typeFilter := r.URL.Query().Get("type")
 	statusFilter := r.URL.Query().Get("status")
 	searchQuery := r.URL.Query().Get("q")
+	statuteFilter := r.URL.Query().Get("statute")
 
-	cases := h.caseService.List(typeFilter, statusFilter, searchQuery)
+	cases := h.caseService.List(r.Context(), typeFilter, statusFilter, searchQuery, statuteFilter)
 
 	// Build filter object for template
 	filter := map[string]string{
-		"Type":   typeFilter,
-		"Status": statusFilter,
-		"Query":  searchQuery,
+		"Type":    typeFilter,
+		"Status":  statusFilter,
+		"Query":   searchQuery,
+		"Statute": statuteFilter,
 	}
 
 	// Calculate counts (simplified - in production these would come from service)
@@ -80,7 +131,7 @@ func (h *StaffHandler) CaseList(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	data := map[string]interface{}{
+	return map[string]interface{}{
 		"Title":            "Cases",
 		"Branding":         h.branding,
 		"Cases":            cases,
@@ -95,8 +146,6 @@ func (h *StaffHandler) CaseList(w http.ResponseWriter, r *http.Request) {
 		"User":             getUserFromContext(r),
 		"ActiveNav":        "cases",
 	}
-
-	h.render(w, "staff/cases", data)
 }
 
 // CaseDetail shows a single case (or routes to panel/fragments)
@@ -106,6 +155,11 @@ func (h *StaffHandler) CaseDetail(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 	caseID := parts[0]
 
+	if path == "_table" {
+		h.CaseTable(w, r)
+		return
+	}
+
 	// Check if this is a fragment request (HTMX partials use /_prefix)
 	if len(parts) > 1 && parts[1] == "_panel" {
 		h.CasePanel(w, r, caseID)
@@ -115,50 +169,65 @@ func (h *StaffHandler) CaseDetail(w http.ResponseWriter, r *http.Request) {
 		h.CaseStatusUpdate(w, r, caseID)
 		return
 	}
+	if len(parts) > 1 && parts[1] == "_disposition" {
+		h.CaseDispositionUpdate(w, r, caseID)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "publish" {
+		h.PublishOpinion(w, r, caseID)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "documents" {
+		h.UploadDocument(w, r, caseID)
+		return
+	}
 
-	c := h.caseService.GetByID(caseID)
+	c := h.caseService.GetByID(r.Context(), caseID)
 	if c == nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	documents := h.caseService.GetDocuments(caseID)
-	notes := h.caseService.GetNotes(caseID)
-	activity := h.caseService.GetActivity(caseID)
+	documents := h.caseService.GetDocuments(r.Context(), caseID)
+	notes := h.caseService.GetNotes(r.Context(), caseID)
+	activity := h.caseService.GetActivity(r.Context(), caseID)
+	user := getUserFromContext(r)
 
 	data := map[string]interface{}{
-		"Title":     c.CaseNumber,
-		"Branding":  h.branding,
-		"Case":      c,
-		"Documents": documents,
-		"Notes":     notes,
-		"Activity":  activity,
-		"User":      getUserFromContext(r),
+		"Title":      c.CaseNumber,
+		"Branding":   h.branding,
+		"Case":       c,
+		"Documents":  documents,
+		"Notes":      notes,
+		"Activity":   activity,
+		"CanPublish": user != nil && user.CanPublish() && service.CanPublish(c),
+		"User":       user,
 	}
 
-	h.render(w, "staff/case_detail", data)
+	h.page(w, "case_detail", data)
 }
 
 // CasePanel returns the case detail panel (for HTMX offcanvas)
 func (h *StaffHandler) CasePanel(w http.ResponseWriter, r *http.Request, caseID string) {
-	c := h.caseService.GetByID(caseID)
+	c := h.caseService.GetByID(r.Context(), caseID)
 	if c == nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	documents := h.caseService.GetDocuments(caseID)
-	activity := h.caseService.GetActivity(caseID)
+	documents := h.caseService.GetDocuments(r.Context(), caseID)
+	activity := h.caseService.GetActivity(r.Context(), caseID)
 
 	data := map[string]interface{}{
-		"Branding":  h.branding,
-		"Case":      c,
-		"Documents": documents,
-		"Activity":  activity,
-		"User":      getUserFromContext(r),
+		"Branding":     h.branding,
+		"Case":         c,
+		"Documents":    documents,
+		"Activity":     activity,
+		"Dispositions": domain.Dispositions,
+		"User":         getUserFromContext(r),
 	}
 
-	h.render(w, "staff/case_panel", data)
+	h.fragment(w, "case_panel", data)
 }
 
 // CaseStatusUpdate handles case status changes (HTMX fragment: /_status)
@@ -173,8 +242,21 @@ func (h *StaffHandler) CaseStatusUpdate(w http.ResponseWriter, r *http.Request,
 	newStatus := domain.CaseStatus(r.FormValue("status"))
 
 	// Update the case status in the repository
-	err := h.caseService.UpdateStatus(caseID, newStatus)
-	if err != nil {
+	err := h.caseService.UpdateStatus(r.Context(), getUserFromContext(r), caseID, newStatus)
+	switch {
+	case errors.Is(err, service.ErrCaseForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrCaseNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, service.ErrInvalidStatus):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrPublishRequired), errors.Is(err, service.ErrCasePublished):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
 		http.Error(w, "Failed to update status", http.StatusInternalServerError)
 		return
 	}
@@ -184,9 +266,292 @@ func (h *StaffHandler) CaseStatusUpdate(w http.ResponseWriter, r *http.Request,
 	w.WriteHeader(http.StatusOK)
 }
 
+// CaseDispositionUpdate records how a complaint was resolved (HTMX fragment: /_disposition)
+func (h *StaffHandler) CaseDispositionUpdate(w http.ResponseWriter, r *http.Request, caseID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.ParseForm()
+	err := h.caseService.SetDisposition(r.Context(), getUserFromContext(r), caseID, domain.Disposition(r.FormValue("disposition")))
+	switch {
+	case errors.Is(err, service.ErrCaseForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrCaseNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, service.ErrInvalidDisposition):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update disposition", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", "caseUpdated")
+	w.WriteHeader(http.StatusOK)
+}
+
+// PublishOpinion shows and handles the publish form for a draft-prepared case
+func (h *StaffHandler) PublishOpinion(w http.ResponseWriter, r *http.Request, caseID string) {
+	user := getUserFromContext(r)
+	if user == nil || !user.CanPublish() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	c := h.caseService.GetByID(r.Context(), caseID)
+	if c == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":     "Publish " + c.CaseNumber,
+		"Branding":  h.branding,
+		"Case":      c,
+		"Form":      map[string]string{"Title": c.Summary, "Summary": c.Summary, "Statutes": c.StatuteCitations},
+		"Documents": h.opinionService.PublishableDocuments(caseID),
+		"User":      user,
+		"ActiveNav": "cases",
+	}
+
+	if !service.CanPublish(c) {
+		w.WriteHeader(http.StatusConflict)
+		data["Error"] = "Only advisory opinions and ethics complaints in Draft Prepared status can be published."
+		h.page(w, "publish", data)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.page(w, "publish", data)
+		return
+	}
+
+	r.ParseForm()
+	data["Form"] = map[string]string{
+		"Title":      r.FormValue("title"),
+		"Summary":    r.FormValue("summary"),
+		"Topics":     r.FormValue("topics"),
+		"Statutes":   r.FormValue("statutes"),
+		"DocumentID": r.FormValue("document_id"),
+	}
+
+	req := service.PublishRequest{
+		Title:             r.FormValue("title"),
+		Summary:           r.FormValue("summary"),
+		Topics:            splitList(r.FormValue("topics")),
+		Statutes:          splitList(r.FormValue("statutes")),
+		DocumentID:        r.FormValue("document_id"),
+		RedactionReviewed: r.FormValue("redaction_reviewed") == "on",
+	}
+
+	opinion, err := h.opinionService.Publish(r.Context(), user, caseID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrPublishForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		data["Error"] = err.Error()
+		h.page(w, "publish", data)
+		return
+	}
+
+	http.Redirect(w, r, "/opinions/"+opinion.CaseNumber, http.StatusSeeOther)
+}
+
+// UploadDocument attaches a file to a case (POST /staff/cases/{id}/documents)
+func (h *StaffHandler) UploadDocument(w http.ResponseWriter, r *http.Request, caseID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.ParseMultipartForm(32 << 20) // 32MB max
+	file, header, err := r.FormFile("document")
+	if err != nil {
+		http.Error(w, "Document is required", http.StatusBadRequest)
+		return
+	}
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		http.Error(w, "Failed to read document", http.StatusBadRequest)
+		return
+	}
+
+	doc := &domain.Document{
+		Filename:    filepath.Base(header.Filename),
+		ContentType: header.Header.Get("Content-Type"),
+		Category:    r.FormValue("category"),
+		Content:     content,
+	}
+	if err := h.caseService.AddDocument(r.Context(), getUserFromContext(r), caseID, doc); err != nil {
+		if errors.Is(err, service.ErrCaseForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/staff/cases/"+caseID, http.StatusSeeOther)
+}
+
+// DocumentDetail handles /staff/documents/{id} and its redaction actions:
+// /download, /redactions, /redactions/{rid}/delete, /apply and /release
+func (h *StaffHandler) DocumentDetail(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/staff/documents/")
+	parts := strings.Split(path, "/")
+	docID := parts[0]
+
+	doc := h.caseService.GetDocument(r.Context(), docID)
+	if doc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+	if action == "download" {
+		serveDocument(w, r, doc, "attachment")
+		return
+	}
+	if action == "" {
+		h.renderDocument(w, r, doc, "", http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := getUserFromContext(r)
+	r.ParseForm()
+	var err error
+	redirect := "/staff/documents/" + doc.ID
+
+	switch {
+	case action == "redactions" && len(parts) == 2:
+		err = h.addRedaction(user, doc, r)
+	case action == "redactions" && len(parts) == 4 && parts[3] == "delete":
+		err = h.redactionService.RemoveRedaction(user, doc.ID, parts[2])
+	case action == "apply" && len(parts) == 2:
+		var derived *domain.Document
+		if derived, err = h.redactionService.Apply(r.Context(), user, doc.ID); err == nil {
+			redirect = "/staff/documents/" + derived.ID
+		}
+	case action == "release" && len(parts) == 2:
+		err = h.redactionService.Release(r.Context(), user, doc.ID)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		if errors.Is(err, service.ErrCaseForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		h.renderDocument(w, r, doc, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// addRedaction creates redactions from the document page form. Text
+// documents are redacted by phrase or byte span, PDFs by page region.
+func (h *StaffHandler) addRedaction(user *domain.User, doc *domain.Document, r *http.Request) error {
+	reason := domain.RedactionReason(r.FormValue("reason"))
+
+	if phrase := r.FormValue("phrase"); phrase != "" {
+		_, err := h.redactionService.AddPhraseRedactions(user, doc.ID, phrase, reason)
+		return err
+	}
+	if r.FormValue("page") != "" {
+		page, _ := strconv.Atoi(r.FormValue("page"))
+		var rect [4]float64
+		for i, field := range []string{"x", "y", "width", "height"} {
+			v, err := strconv.ParseFloat(r.FormValue(field), 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q", field, r.FormValue(field))
+			}
+			rect[i] = v
+		}
+		_, err := h.redactionService.AddRegionRedaction(user, doc.ID, page, rect[0], rect[1], rect[2], rect[3], reason)
+		return err
+	}
+
+	start, errStart := strconv.Atoi(r.FormValue("start"))
+	end, errEnd := strconv.Atoi(r.FormValue("end"))
+	if errStart != nil || errEnd != nil {
+		return errors.New("enter a phrase, a byte span or a page region to redact")
+	}
+	_, err := h.redactionService.AddTextRedaction(user, doc.ID, start, end, reason)
+	return err
+}
+
+func (h *StaffHandler) renderDocument(w http.ResponseWriter, r *http.Request, doc *domain.Document, errMsg string, status int) {
+	c := h.caseService.GetByID(r.Context(), doc.CaseID)
+	if c == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Show the log entries involving this document, and the copies made from it
+	var logs []domain.RedactionLog
+	for _, l := range h.redactionService.ListLogs(doc.CaseID) {
+		if l.SourceDocumentID == doc.ID || l.DerivedDocumentID == doc.ID {
+			logs = append(logs, l)
+		}
+	}
+	var source *domain.Document
+	if doc.DerivedFromID != "" {
+		source = h.caseService.GetDocument(r.Context(), doc.DerivedFromID)
+	}
+
+	preview := ""
+	kind := h.redactionService.Kind(doc)
+	if kind == domain.RedactionText && utf8.Valid(doc.Content) {
+		preview = string(doc.Content)
+	}
+	unredactable := ""
+	if kind != "" && !doc.IsRedacted {
+		if err := h.redactionService.Check(doc); err != nil {
+			unredactable = err.Error()
+		}
+	}
+
+	data := map[string]interface{}{
+		"Title":         doc.Filename,
+		"Branding":      h.branding,
+		"Case":          c,
+		"Document":      doc,
+		"Source":        source,
+		"Redactions":    h.redactionService.ListRedactions(doc.ID),
+		"RedactionKind": string(kind),
+		"Unredactable":  unredactable,
+		"Reasons":       domain.RedactionReasons,
+		"Logs":          logs,
+		"Preview":       preview,
+		"Error":         errMsg,
+		"User":          getUserFromContext(r),
+		"ActiveNav":     "cases",
+	}
+
+	w.WriteHeader(status)
+	h.page(w, "document", data)
+}
+
 // Deadlines shows all upcoming deadlines
 func (h *StaffHandler) Deadlines(w http.ResponseWriter, r *http.Request) {
-	deadlines := h.caseService.GetAllDeadlines()
+	deadlines := h.caseService.GetAllDeadlines(r.Context())
 
 	data := map[string]interface{}{
 		"Title":     "Deadlines",
@@ -196,46 +561,872 @@ func (h *StaffHandler) Deadlines(w http.ResponseWriter, r *http.Request) {
 		"ActiveNav": "deadlines",
 	}
 
-	h.render(w, "staff/deadlines", data)
+	h.page(w, "deadlines", data)
 }
 
-// Reports shows reporting interface
+// Reports shows case metrics for a date range, case type and assignee
 func (h *StaffHandler) Reports(w http.ResponseWriter, r *http.Request) {
-	stats := h.dashboardService.GetStats()
+	filter, err := reportFilter(r)
+	var report *service.Report
+	if err == nil {
+		report, err = h.reportService.Generate(filter)
+	}
+
+	// Echo what was entered, so a mistyped date can be corrected
+	q := r.URL.Query()
+	form := map[string]string{
+		"From":     filter.From.Format("2006-01-02"),
+		"To":       filter.To.Format("2006-01-02"),
+		"Type":     string(filter.Type),
+		"Assignee": filter.AssigneeID,
+	}
+	for key, param := range map[string]string{"From": "from", "To": "to"} {
+		if v := q.Get(param); v != "" {
+			form[key] = v
+		}
+	}
 
 	data := map[string]interface{}{
 		"Title":     "Reports",
 		"Branding":  h.branding,
-		"Stats":     stats,
+		"Report":    report,
+		"Form":      form,
+		"CaseTypes": domain.CaseTypes,
+		"Assignees": h.reportService.Assignees(),
 		"User":      getUserFromContext(r),
 		"ActiveNav": "reports",
 	}
+	status := http.StatusOK
+	if err != nil {
+		data["Error"] = err.Error()
+		status = http.StatusBadRequest
+	} else {
+		export := url.Values{
+			"from":     {form["From"]},
+			"to":       {form["To"]},
+			"type":     {form["Type"]},
+			"assignee": {form["Assignee"]},
+		}
+		for _, format := range []string{"csv", "xlsx"} {
+			export.Set("format", format)
+			data["Export"+strings.ToUpper(format)] = "/staff/reports/export?" + export.Encode()
+		}
+	}
+
+	w.WriteHeader(status)
+	h.page(w, "reports", data)
+}
+
+// ReportExport downloads the report with the same filters as the page
+// (GET /staff/reports/export?format=csv or format=xlsx)
+func (h *StaffHandler) ReportExport(w http.ResponseWriter, r *http.Request) {
+	filter, err := reportFilter(r)
+	var report *service.Report
+	if err == nil {
+		report, err = h.reportService.Generate(filter)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := fmt.Sprintf("case-report-%s-to-%s", filter.From.Format("2006-01-02"), filter.To.Format("2006-01-02"))
+	var buf bytes.Buffer
+	var contentType string
+	switch format := r.URL.Query().Get("format"); format {
+	case "csv":
+		err = writeReportCSV(&buf, report.Tables())
+		contentType, name = "text/csv; charset=utf-8", name+".csv"
+	case "xlsx":
+		var sheets []xlsx.Sheet
+		for _, t := range report.Tables() {
+			sheets = append(sheets, xlsx.Sheet{Name: t.Name, Header: t.Header, Rows: t.Rows})
+		}
+		err = xlsx.Write(&buf, sheets)
+		contentType, name = xlsx.ContentType, name+".xlsx"
+	default:
+		http.Error(w, fmt.Sprintf("Unsupported export format: %q", format), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Export failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+// reportFilter reads the report filters from the query string. Missing
+// dates default to the last twelve months.
+func reportFilter(r *http.Request) (service.ReportFilter, error) {
+	q := r.URL.Query()
+	filter := service.DefaultReportFilter(time.Now())
+	filter.Type = domain.CaseType(q.Get("type"))
+	filter.AssigneeID = q.Get("assignee")
+	for param, date := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s date %q: use YYYY-MM-DD", param, v)
+		}
+		*date = d
+	}
+	return filter, nil
+}
+
+// writeReportCSV writes the tables one after another, each under a row
+// with its name and separated by a blank row
+func writeReportCSV(w io.Writer, tables []service.ReportTable) error {
+	cw := csv.NewWriter(w)
+	for i, t := range tables {
+		if i > 0 {
+			cw.Write([]string{})
+		}
+		cw.Write([]string{t.Name})
+		cw.Write(t.Header)
+		for _, row := range t.Rows {
+			record := make([]string, len(row))
+			for j, v := range row {
+				if v != nil {
+					record[j] = csvCell(fmt.Sprint(v))
+				}
+			}
+			cw.Write(record)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell stops spreadsheet programs from treating text as a formula
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return "'" + s
+		}
+	}
+	return s
+}
+
+// AnnualReport handles the fiscal-year annual report: the page
+// (GET /staff/reports/annual?fy=2025), narrative overrides
+// (POST /staff/reports/annual/narrative) and the document
+// (GET /staff/reports/annual/download?fy=2025&format=pdf or format=docx)
+func (h *StaffHandler) AnnualReport(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/staff/reports/annual") {
+	case "", "/":
+		h.annualReportPage(w, r, "", http.StatusOK)
+	case "/narrative":
+		h.AnnualReportNarrative(w, r)
+	case "/download":
+		h.AnnualReportDownload(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *StaffHandler) annualReportPage(w http.ResponseWriter, r *http.Request, errMsg string, status int) {
+	user := getUserFromContext(r)
+	fy, err := annualReportYear(r)
+	var report *service.AnnualReport
+	if err == nil {
+		report, err = h.annualService.Generate(fy)
+	}
+	if err != nil && errMsg == "" {
+		errMsg, status = err.Error(), http.StatusBadRequest
+	}
+
+	data := map[string]interface{}{
+		"Title":      "Annual Report",
+		"Branding":   h.branding,
+		"Report":     report,
+		"FiscalYear": fy,
+		"Years":      h.annualService.Years(),
+		"CanEdit":    user != nil && user.CanManageCases(),
+		"Saved":      r.URL.Query().Get("saved"),
+		"User":       user,
+		"ActiveNav":  "reports",
+	}
+	if report != nil {
+		for _, format := range []string{"pdf", "docx"} {
+			data["Download"+strings.ToUpper(format)] = fmt.Sprintf("/staff/reports/annual/download?fy=%d&format=%s", fy, format)
+		}
+	}
+	if errMsg != "" {
+		data["Error"] = errMsg
+	}
+
+	w.WriteHeader(status)
+	h.page(w, "annual_report", data)
+}
+
+// AnnualReportNarrative saves or restores one section's narrative and
+// returns to the report
+func (h *StaffHandler) AnnualReportNarrative(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	fy, err := annualReportYear(r)
+	section, body := r.FormValue("section"), r.FormValue("body")
+	if r.FormValue("reset") != "" {
+		body = ""
+	}
+	if err == nil {
+		err = h.annualService.SaveNarrative(r.Context(), getUserFromContext(r), fy, section, body)
+	}
+	switch {
+	case errors.Is(err, service.ErrCaseForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case err != nil:
+		h.annualReportPage(w, r, err.Error(), http.StatusBadRequest)
+	default:
+		http.Redirect(w, r, fmt.Sprintf("/staff/reports/annual?fy=%d&saved=%s#section-%s", fy, url.QueryEscape(section), section), http.StatusSeeOther)
+	}
+}
+
+// AnnualReportDownload writes the report as a PDF or Word document using
+// the agency's branding
+func (h *StaffHandler) AnnualReportDownload(w http.ResponseWriter, r *http.Request) {
+	fy, err := annualReportYear(r)
+	var report *service.AnnualReport
+	if err == nil {
+		report, err = h.annualService.Generate(fy)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doc := report.Document(service.ReportLetterhead{
+		AgencyName: h.branding.AgencyName,
+		Address:    h.branding.Address,
+		Website:    h.branding.Website,
+		Email:      h.branding.ContactEmail,
+		Phone:      h.branding.ContactPhone,
+		Color:      h.branding.PrimaryColor,
+	})
+	name := fmt.Sprintf("annual-report-fy%d", fy)
+	var buf bytes.Buffer
+	var contentType string
+	switch format := r.URL.Query().Get("format"); format {
+	case "pdf":
+		err = docgen.WritePDF(&buf, doc)
+		contentType, name = docgen.PDFContentType, name+".pdf"
+	case "docx":
+		err = docgen.WriteDOCX(&buf, doc)
+		contentType, name = docgen.DOCXContentType, name+".docx"
+	default:
+		http.Error(w, fmt.Sprintf("Unsupported document format: %q", format), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Report generation failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
 
-	h.render(w, "staff/reports", data)
+// annualReportYear reads the fiscal year from the fy parameter, defaulting
+// to the last year that has ended
+func annualReportYear(r *http.Request) (int, error) {
+	v := r.FormValue("fy")
+	if v == "" {
+		return domain.FiscalYear(time.Now()) - 1, nil
+	}
+	fy, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(v), "FY"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid fiscal year %q", v)
+	}
+	return fy, nil
 }
 
-// Users shows user management (admin only)
+// Users lists staff accounts (GET /staff/users) and invites new users
+// (POST). Admin only.
 func (h *StaffHandler) Users(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user == nil || !user.CanManageUsers() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.renderUsers(w, r, nil, "", http.StatusOK)
+		return
+	}
+
+	r.ParseForm()
+	in := userInput(r)
+	in.Email = r.FormValue("email")
+	invited, token, err := h.userService.Invite(r.Context(), user, in)
+	if err != nil {
+		h.renderUsers(w, r, map[string]interface{}{"InviteError": err.Error(), "InviteForm": in}, "", http.StatusBadRequest)
+		return
+	}
+	// The link is only ever shown in this response, and emailed when a
+	// relay is configured
+	link := h.passwordLink(token)
+	subject := "Your " + h.branding.ShortName + " staff portal account"
+	data := map[string]interface{}{
+		"Invited":    invited,
+		"InviteLink": link,
+		"InviteMail": passwordMailto(invited, link, subject),
+	}
+	if h.mailService != nil {
+		err := h.mailService.SendPasswordLink(r.Context(), service.EmailInvitation, invited, subject, link)
+		data["Emailed"], data["EmailFailed"] = err == nil, err != nil
+	}
+	h.renderUsers(w, r, data, "", http.StatusOK)
+}
+
+// UserDetail handles /staff/users/{id} (GET shows the account, POST saves
+// changes) and admin actions under /staff/users/: /{id}/deactivate,
+// /{id}/reactivate, /{id}/password-reset, the two-factor policy
+// (/mfa/policy), enrollment resets (/{id}/mfa/reset) and signing a user out
+// everywhere (/{id}/sessions/revoke). Admin only.
+func (h *StaffHandler) UserDetail(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user == nil || !user.CanManageUsers() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/staff/users/"), "/")
+	parts := strings.Split(path, "/")
+	if r.Method != http.MethodPost {
+		if path != "" && len(parts) == 1 {
+			h.renderUser(w, r, parts[0], nil, "", http.StatusOK)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+
+	switch {
+	case path == "mfa/policy":
+		var roles []domain.Role
+		for _, role := range r.Form["roles"] {
+			roles = append(roles, domain.Role(role))
+		}
+		h.finishMFAAction(w, r, h.mfaService.SetRequiredRoles(r.Context(), user, roles))
+	case len(parts) == 3 && parts[1] == "mfa" && parts[2] == "reset":
+		h.finishMFAAction(w, r, h.mfaService.Reset(r.Context(), user, parts[0]))
+	case len(parts) == 3 && parts[1] == "sessions" && parts[2] == "revoke":
+		if _, err := h.authService.ForceLogout(r.Context(), user, parts[0]); err != nil {
+			h.renderUsers(w, r, nil, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, "/staff/users#active-users", http.StatusSeeOther)
+	case len(parts) == 1:
+		_, err := h.userService.Update(r.Context(), user, parts[0], userInput(r))
+		h.finishUserAction(w, r, parts[0], err)
+	case len(parts) == 2 && parts[1] == "deactivate":
+		h.finishUserAction(w, r, parts[0], h.userService.SetActive(r.Context(), user, parts[0], false))
+	case len(parts) == 2 && parts[1] == "reactivate":
+		h.finishUserAction(w, r, parts[0], h.userService.SetActive(r.Context(), user, parts[0], true))
+	case len(parts) == 2 && parts[1] == "password-reset":
+		target, token, err := h.userService.ResetPassword(r.Context(), user, parts[0])
+		if err != nil {
+			h.finishUserAction(w, r, parts[0], err)
+			return
+		}
+		// The link is only ever shown in this response, and emailed when a
+		// relay is configured
+		link := h.passwordLink(token)
+		subject := "Reset your " + h.branding.ShortName + " staff portal password"
+		data := map[string]interface{}{
+			"ResetLink": link,
+			"ResetMail": passwordMailto(target, link, subject),
+		}
+		if h.mailService != nil {
+			err := h.mailService.SendPasswordLink(r.Context(), service.EmailPasswordReset, target, subject, link)
+			data["Emailed"], data["EmailFailed"] = err == nil, err != nil
+		}
+		h.renderUser(w, r, target.ID, data, "", http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// finishUserAction returns to the user's page, showing err if there was one
+func (h *StaffHandler) finishUserAction(w http.ResponseWriter, r *http.Request, id string, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		http.NotFound(w, r)
+	case err != nil:
+		h.renderUser(w, r, id, nil, err.Error(), http.StatusBadRequest)
+	default:
+		http.Redirect(w, r, "/staff/users/"+id, http.StatusSeeOther)
+	}
+}
+
+// finishMFAAction returns to the two-factor card on the users page
+func (h *StaffHandler) finishMFAAction(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrMFAForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case err != nil:
+		h.renderUsers(w, r, nil, err.Error(), http.StatusBadRequest)
+	default:
+		http.Redirect(w, r, "/staff/users#mfa", http.StatusSeeOther)
+	}
+}
+
+func (h *StaffHandler) renderUsers(w http.ResponseWriter, r *http.Request, extra map[string]interface{}, errMsg string, status int) {
+	user := getUserFromContext(r)
+	users, _ := h.userService.List(user)
+	accounts, _ := h.mfaService.Accounts(user)
+	activeUsers, _ := h.authService.ActiveUsers(user)
+	data := map[string]interface{}{
+		"Title":       "User Management",
+		"Branding":    h.branding,
+		"User":        user,
+		"Users":       users,
+		"Roles":       domain.Roles,
+		"MFAAccounts": accounts,
+		"MFAPolicy":   h.mfaService.Policy(),
+		"ActiveUsers": activeUsers,
+		"Error":       errMsg,
+		"ActiveNav":   "users",
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+
+	w.WriteHeader(status)
+	h.page(w, "users", data)
+}
+
+// renderUser shows one account with its edit form and audit history
+func (h *StaffHandler) renderUser(w http.ResponseWriter, r *http.Request, id string, extra map[string]interface{}, errMsg string, status int) {
+	user := getUserFromContext(r)
+	account, err := h.userService.Get(user, id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	history, _ := h.auditService.ForUser(user, account.ID)
 	data := map[string]interface{}{
-		"Title":     "User Management",
+		"Title":     account.FullName(),
 		"Branding":  h.branding,
-		"User":      getUserFromContext(r),
+		"User":      user,
+		"Account":   account,
+		"Roles":     domain.Roles,
+		"Sessions":  len(h.authService.Sessions(account)),
+		"MFA":       h.mfaService.Enrollment(account.ID) != nil,
+		"History":   history,
+		"Error":     errMsg,
 		"ActiveNav": "users",
 	}
+	for k, v := range extra {
+		data[k] = v
+	}
+
+	w.WriteHeader(status)
+	h.page(w, "user", data)
+}
+
+// Audit shows the audit log (admins and auditors)
+func (h *StaffHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	entries, err := h.auditService.List(user)
+	if err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	h.page(w, "audit", map[string]interface{}{
+		"Title":     "Audit Log",
+		"Branding":  h.branding,
+		"User":      user,
+		"Entries":   entries,
+		"ActiveNav": "audit",
+	})
+}
+
+// userInput reads the account fields shared by the invite and edit forms
+func userInput(r *http.Request) service.UserInput {
+	return service.UserInput{
+		FirstName: r.FormValue("first_name"),
+		LastName:  r.FormValue("last_name"),
+		Role:      domain.Role(r.FormValue("role")),
+		Title:     r.FormValue("title"),
+		Phone:     r.FormValue("phone"),
+	}
+}
+
+// passwordLink is the invitation or reset link for token
+func (h *StaffHandler) passwordLink(token string) string {
+	return h.baseURL + "/staff/password?token=" + url.QueryEscape(token)
+}
 
-	h.render(w, "staff/users", data)
+// passwordMailto opens the admin's mail client with the link addressed to the user
+func passwordMailto(user *domain.User, link, subject string) string {
+	q := url.Values{"subject": {subject}, "body": {service.PasswordLinkBody(user, link)}}
+	// mailto bodies take %20 for spaces, not +
+	return "mailto:" + user.Email + "?" + strings.ReplaceAll(q.Encode(), "+", "%20")
 }
 
 // Settings shows system settings
 func (h *StaffHandler) Settings(w http.ResponseWriter, r *http.Request) {
+	h.renderSettings(w, r, nil, "", http.StatusOK)
+}
+
+// APITokens mints (POST /staff/settings/tokens) and revokes
+// (POST /staff/settings/tokens/{id}/revoke) the user's API tokens
+func (h *StaffHandler) APITokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := getUserFromContext(r)
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/staff/settings/tokens"), "/")
+	r.ParseForm()
+
+	if path == "" {
+		var scopes []domain.TokenScope
+		for _, s := range r.Form["scopes"] {
+			scopes = append(scopes, domain.TokenScope(s))
+		}
+		days, _ := strconv.Atoi(r.FormValue("expires_days"))
+		token, secret, err := h.tokenService.Create(r.Context(), user, r.FormValue("name"), scopes, days)
+		if err != nil {
+			h.renderSettings(w, r, nil, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// The secret is only ever shown in this response
+		h.renderSettings(w, r, map[string]interface{}{"NewToken": token, "NewSecret": secret}, "", http.StatusOK)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[1] != "revoke" {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.tokenService.Revoke(r.Context(), user, parts[0]); err != nil {
+		h.renderSettings(w, r, nil, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, "/staff/settings#api-tokens", http.StatusSeeOther)
+}
+
+// MFA manages the user's own authenticator: POST /staff/settings/mfa/enroll
+// shows a new secret, /confirm turns two-factor sign-in on, /recovery-codes
+// replaces the recovery codes and /disable turns it off
+func (h *StaffHandler) MFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := getUserFromContext(r)
+	r.ParseForm()
+	code := r.FormValue("code")
+
+	switch strings.Trim(strings.TrimPrefix(r.URL.Path, "/staff/settings/mfa"), "/") {
+	case "enroll":
+		h.renderMFASetup(w, r, "", http.StatusOK)
+	case "confirm":
+		codes, err := h.mfaService.ConfirmEnrollment(r.Context(), user, code)
+		if err != nil {
+			h.renderMFASetup(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.renderSettings(w, r, map[string]interface{}{"RecoveryCodes": codes}, "", http.StatusOK)
+	case "recovery-codes":
+		codes, err := h.mfaService.RegenerateRecoveryCodes(r.Context(), user, code)
+		if err != nil {
+			h.renderSettings(w, r, map[string]interface{}{"MFAError": err.Error()}, "", http.StatusBadRequest)
+			return
+		}
+		h.renderSettings(w, r, map[string]interface{}{"RecoveryCodes": codes}, "", http.StatusOK)
+	case "disable":
+		if err := h.mfaService.Disable(r.Context(), user, code); err != nil {
+			h.renderSettings(w, r, map[string]interface{}{"MFAError": err.Error()}, "", http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, "/staff/settings#mfa", http.StatusSeeOther)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Sessions signs the user out of their other browsers:
+// POST /staff/settings/sessions/{id}/revoke ends one session and
+// /revoke-others ends every session but the current one
+func (h *StaffHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := getUserFromContext(r)
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/staff/settings/sessions"), "/")
+
+	if path == "revoke-others" {
+		h.authService.RevokeOtherSessions(r.Context(), user, sessionToken(r))
+		http.Redirect(w, r, "/staff/settings#sessions", http.StatusSeeOther)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[1] != "revoke" {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.authService.RevokeSession(r.Context(), user, parts[0]); err != nil {
+		h.renderSettings(w, r, map[string]interface{}{"SessionError": err.Error()}, "", http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, "/staff/settings#sessions", http.StatusSeeOther)
+}
+
+// sessionToken returns the token of the session making the request
+func sessionToken(r *http.Request) string {
+	if cookie, err := r.Cookie("session"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// renderMFASetup shows the settings page with the secret for a new authenticator
+func (h *StaffHandler) renderMFASetup(w http.ResponseWriter, r *http.Request, errMsg string, status int) {
+	secret, uri, err := h.mfaService.BeginEnrollment(getUserFromContext(r))
+	if err != nil {
+		h.renderSettings(w, r, map[string]interface{}{"MFAError": err.Error()}, "", http.StatusConflict)
+		return
+	}
+	h.renderSettings(w, r, map[string]interface{}{
+		"MFASecret":          secret,
+		"MFAProvisioningURI": uri,
+		"MFAError":           errMsg,
+	}, "", status)
+}
+
+func (h *StaffHandler) renderSettings(w http.ResponseWriter, r *http.Request, extra map[string]interface{}, errMsg string, status int) {
+	user := getUserFromContext(r)
+
+	// Only offer the scopes the user's role permits
+	var scopes []map[string]string
+	for _, s := range domain.TokenScopes {
+		if s.Scope.PermittedFor(user) {
+			scopes = append(scopes, map[string]string{"Scope": string(s.Scope), "Description": s.Description})
+		}
+	}
+
+	data := map[string]interface{}{
+		"Title":          "Settings",
+		"Branding":       h.branding,
+		"User":           user,
+		"APITokens":      h.tokenService.List(user),
+		"TokenScopes":    scopes,
+		"TokenLifetimes": service.TokenLifetimes,
+		"TokenError":     errMsg,
+		"MFAEnrollment":  h.mfaService.Enrollment(user.ID),
+		"MFARequired":    h.mfaService.Required(user),
+		"Sessions":       h.authService.Sessions(user),
+		"CurrentSession": sessionToken(r),
+		"ActiveNav":      "settings",
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+
+	w.WriteHeader(status)
+	h.page(w, "settings", data)
+}
+
+// Webhooks lists webhook endpoints (GET /staff/webhooks) and registers new
+// ones (POST). Admin only.
+func (h *StaffHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user == nil || !user.CanManageWebhooks() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.renderWebhooks(w, r, nil, "", http.StatusOK)
+		return
+	}
+
+	r.ParseForm()
+	var events []domain.WebhookEvent
+	for _, e := range r.Form["events"] {
+		events = append(events, domain.WebhookEvent(e))
+	}
+	hook, err := h.webhookService.Create(r.Context(), user, r.FormValue("url"), r.FormValue("description"), events)
+	if err != nil {
+		form := map[string]string{"URL": r.FormValue("url"), "Description": r.FormValue("description")}
+		h.renderWebhooks(w, r, form, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/staff/webhooks/"+hook.ID, http.StatusSeeOther)
+}
+
+// WebhookDetail handles /staff/webhooks/{id} (settings and delivery log) and
+// its actions: /enable, /disable, /delete and /deliveries/{did}/redeliver
+func (h *StaffHandler) WebhookDetail(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user == nil || !user.CanManageWebhooks() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/staff/webhooks/"), "/"), "/")
+	hook := h.webhookService.Get(parts[0])
+	if hook == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		h.renderWebhook(w, r, hook, "", http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var err error
+	redirect := "/staff/webhooks/" + hook.ID
+	switch {
+	case len(parts) == 2 && parts[1] == "enable":
+		err = h.webhookService.SetActive(r.Context(), user, hook.ID, true)
+	case len(parts) == 2 && parts[1] == "disable":
+		err = h.webhookService.SetActive(r.Context(), user, hook.ID, false)
+	case len(parts) == 2 && parts[1] == "delete":
+		err = h.webhookService.Delete(r.Context(), user, hook.ID)
+		redirect = "/staff/webhooks"
+	case len(parts) == 4 && parts[1] == "deliveries" && parts[3] == "redeliver":
+		var d *domain.WebhookDelivery
+		if d = h.webhookService.GetDelivery(parts[2]); d == nil || d.WebhookID != hook.ID {
+			http.NotFound(w, r)
+			return
+		}
+		_, err = h.webhookService.Redeliver(r.Context(), user, d.ID)
+		redirect += "#deliveries"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		h.renderWebhook(w, r, hook, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+func (h *StaffHandler) renderWebhooks(w http.ResponseWriter, r *http.Request, form map[string]string, errMsg string, status int) {
 	data := map[string]interface{}{
-		"Title":     "Settings",
+		"Title":     "Webhooks",
 		"Branding":  h.branding,
+		"Webhooks":  h.webhookService.List(),
+		"Events":    domain.WebhookEvents,
+		"Form":      form,
+		"Error":     errMsg,
 		"User":      getUserFromContext(r),
-		"ActiveNav": "settings",
+		"ActiveNav": "webhooks",
 	}
 
-	h.render(w, "staff/settings", data)
+	w.WriteHeader(status)
+	h.page(w, "webhooks", data)
+}
+
+func (h *StaffHandler) renderWebhook(w http.ResponseWriter, r *http.Request, hook *domain.Webhook, errMsg string, status int) {
+	data := map[string]interface{}{
+		"Title":      "Webhook " + hook.URL,
+		"Branding":   h.branding,
+		"Webhook":    hook,
+		"Deliveries": h.webhookService.Deliveries(hook.ID),
+		"Error":      errMsg,
+		"User":       getUserFromContext(r),
+		"ActiveNav":  "webhooks",
+	}
+
+	w.WriteHeader(status)
+	h.page(w, "webhook", data)
+}
+
+// Triage lists public submissions held as possible spam; ?status=accepted,
+// rejected or all shows reviewed ones
+func (h *StaffHandler) Triage(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user == nil || !user.CanManageCases() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	h.renderTriage(w, r, "", http.StatusOK)
+}
+
+// TriageAction handles /staff/triage/{id}/accept and /reject
+func (h *StaffHandler) TriageAction(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if user == nil || !user.CanManageCases() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/staff/triage/"), "/"), "/")
+	if len(parts) != 2 || h.intakeService.GetHeld(parts[0]) == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch parts[1] {
+	case "accept":
+		if _, err := h.intakeService.Accept(r.Context(), user, parts[0]); err != nil {
+			h.renderTriage(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, "/staff/cases/"+h.intakeService.GetHeld(parts[0]).CaseID, http.StatusSeeOther)
+	case "reject":
+		if err := h.intakeService.Reject(r.Context(), user, parts[0]); err != nil {
+			h.renderTriage(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, "/staff/triage", http.StatusSeeOther)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *StaffHandler) renderTriage(w http.ResponseWriter, r *http.Request, errMsg string, status int) {
+	filter := r.URL.Query().Get("status")
+	var held []*domain.HeldSubmission
+	switch filter {
+	case "all":
+		held = h.intakeService.Held("")
+	case string(domain.TriageAccepted), string(domain.TriageRejected):
+		held = h.intakeService.Held(domain.TriageStatus(filter))
+	default:
+		filter = string(domain.TriagePending)
+		held = h.intakeService.Held(domain.TriagePending)
+	}
+
+	data := map[string]interface{}{
+		"Title":     "Triage",
+		"Branding":  h.branding,
+		"Held":      held,
+		"Filter":    filter,
+		"Pending":   h.intakeService.PendingCount(),
+		"Error":     errMsg,
+		"User":      getUserFromContext(r),
+		"ActiveNav": "triage",
+	}
+
+	w.WriteHeader(status)
+	h.page(w, "triage", data)
 }
 
 // AcknowledgmentsDetail handles /staff/acknowledgments/{id} and fragments
@@ -256,15 +1447,7 @@ func (h *StaffHandler) AcknowledgmentsDetail(w http.ResponseWriter, r *http.Requ
 
 // AcknowledgmentPanel returns acknowledgment detail panel (HTMX fragment: /_panel)
 func (h *StaffHandler) AcknowledgmentPanel(w http.ResponseWriter, r *http.Request, ackID string) {
-	// Get mock acknowledgment by ID
-	acks := getMockAcknowledgments("", "", "")
-	var ack *domain.EthicsAcknowledgment
-	for _, a := range acks {
-		if a.ID == ackID {
-			ack = a
-			break
-		}
-	}
+	ack := h.ackService.GetByID(ackID)
 	if ack == nil {
 		http.NotFound(w, r)
 		return
@@ -276,7 +1459,7 @@ func (h *StaffHandler) AcknowledgmentPanel(w http.ResponseWriter, r *http.Reques
 		"User":           getUserFromContext(r),
 	}
 
-	h.render(w, "staff/acknowledgment_panel", data)
+	h.fragment(w, "acknowledgment_panel", data)
 }
 
 // Acknowledgments shows filed ethics acknowledgments
@@ -286,8 +1469,8 @@ func (h *StaffHandler) Acknowledgments(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	year := r.URL.Query().Get("year")
 
-	// Get mock acknowledgments (in production, this would come from a service)
-	acknowledgments := getMockAcknowledgments(agencyType, query, year)
+	yearNum, _ := strconv.Atoi(year)
+	acknowledgments := h.ackService.List(agencyType, query, yearNum)
 
 	// Build filter object for template
 	filter := map[string]string{
@@ -301,6 +1484,7 @@ func (h *StaffHandler) Acknowledgments(w http.ResponseWriter, r *http.Request) {
 		"Branding":        h.branding,
 		"Acknowledgments": acknowledgments,
 		"Filter":          filter,
+		"Years":           h.ackService.Years(),
 		"TotalCount":      len(acknowledgments),
 		"ActiveCount":     countActive(acknowledgments),
 		"ThisMonthCount":  3, // Mock data
@@ -312,103 +1496,7 @@ func (h *StaffHandler) Acknowledgments(w http.ResponseWriter, r *http.Request) {
 		"ActiveNav":       "acknowledgments",
 	}
 
-	h.render(w, "staff/acknowledgments", data)
-}
-
-// Mock acknowledgments data
-func getMockAcknowledgments(agencyType, query, year string) []*domain.EthicsAcknowledgment {
-	now := time.Now()
-	termEnd1 := now.AddDate(2, 0, 0)
-	termEnd2 := now.AddDate(1, 6, 0)
-
-	acknowledgments := []*domain.EthicsAcknowledgment{
-		{
-			ID:              "ack_1",
-			CaseNumber:      "EA-2024-089",
-			OfficialName:    "Maria Garcia",
-			OfficialTitle:   "Board Member",
-			Agency:          "Nevada State Board of Education",
-			AgencyType:      "state",
-			TermStartDate:   now.AddDate(-1, 0, 0),
-			TermEndDate:     &termEnd1,
-			AcknowledgedAt:  now.AddDate(0, 0, -1),
-			SignatureOnFile: true,
-			Email:           "mgarcia@doe.nv.gov",
-			IsActive:        true,
-		},
-		{
-			ID:              "ack_2",
-			CaseNumber:      "EA-2024-088",
-			OfficialName:    "James Wilson",
-			OfficialTitle:   "County Commissioner",
-			Agency:          "Clark County",
-			AgencyType:      "county",
-			TermStartDate:   now.AddDate(-2, 0, 0),
-			TermEndDate:     &termEnd2,
-			AcknowledgedAt:  now.AddDate(0, 0, -5),
-			SignatureOnFile: true,
-			Email:           "jwilson@clarkcounty.gov",
-			IsActive:        true,
-		},
-		{
-			ID:              "ack_3",
-			CaseNumber:      "EA-2024-087",
-			OfficialName:    "Patricia Chen",
-			OfficialTitle:   "City Councilwoman",
-			Agency:          "City of Las Vegas",
-			AgencyType:      "city",
-			TermStartDate:   now.AddDate(-1, 6, 0),
-			AcknowledgedAt:  now.AddDate(0, 0, -10),
-			SignatureOnFile: true,
-			Email:           "pchen@lasvegasnevada.gov",
-			IsActive:        true,
-		},
-		{
-			ID:              "ack_4",
-			CaseNumber:      "EA-2024-086",
-			OfficialName:    "Robert Thompson",
-			OfficialTitle:   "Board Trustee",
-			Agency:          "Las Vegas Valley Water District",
-			AgencyType:      "district",
-			TermStartDate:   now.AddDate(-3, 0, 0),
-			AcknowledgedAt:  now.AddDate(0, 0, -15),
-			SignatureOnFile: true,
-			Email:           "rthompson@lvvwd.com",
-			IsActive:        true,
-		},
-		{
-			ID:              "ack_5",
-			CaseNumber:      "EA-2024-085",
-			OfficialName:    "Sarah Martinez",
-			OfficialTitle:   "Director",
-			Agency:          "Nevada Department of Motor Vehicles",
-			AgencyType:      "state",
-			TermStartDate:   now.AddDate(-1, 0, 0),
-			AcknowledgedAt:  now.AddDate(0, 0, -20),
-			SignatureOnFile: true,
-			Email:           "smartinez@dmv.nv.gov",
-			IsActive:        true,
-		},
-	}
-
-	// Apply filters
-	var filtered []*domain.EthicsAcknowledgment
-	for _, a := range acknowledgments {
-		if agencyType != "" && a.AgencyType != agencyType {
-			continue
-		}
-		if query != "" {
-			queryLower := strings.ToLower(query)
-			if !strings.Contains(strings.ToLower(a.OfficialName), queryLower) &&
-				!strings.Contains(strings.ToLower(a.Agency), queryLower) &&
-				!strings.Contains(strings.ToLower(a.CaseNumber), queryLower) {
-				continue
-			}
-		}
-		filtered = append(filtered, a)
-	}
-
-	return filtered
+	h.page(w, "acknowledgments", data)
 }
 
 func countActive(acks []*domain.EthicsAcknowledgment) int {
@@ -421,13 +1509,34 @@ func countActive(acks []*domain.EthicsAcknowledgment) int {
 	return count
 }
 
-func (h *StaffHandler) render(w http.ResponseWriter, name string, data interface{}) {
-	err := h.tmpl.ExecuteTemplate(w, name, data)
-	if err != nil {
+// page renders a full staff page inside the staff layout
+func (h *StaffHandler) page(w http.ResponseWriter, name string, data interface{}) {
+	if err := h.tmpl.Page(w, name, data); err != nil {
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// fragment renders an HTMX fragment without the layout
+func (h *StaffHandler) fragment(w http.ResponseWriter, name string, data interface{}) {
+	if err := h.tmpl.Fragment(w, name, data); err != nil {
 		http.Error(w, "Template error", http.StatusInternalServerError)
 	}
 }
 
+// splitList splits a comma- or newline-separated form value into trimmed entries
+func splitList(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n' || r == ';'
+	})
+	var result []string
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
 func getUserFromContext(r *http.Request) *domain.User {
 	// Get user from context (set by auth middleware)
 	if u := r.Context().Value("user"); u != nil {
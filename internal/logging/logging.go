@@ -0,0 +1,111 @@
+// Package logging sets up structured logging with log/slog: JSON or text
+// output, redaction of personal details about the public, and a logger
+// scoped to each request.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures New
+type Options struct {
+	Level  slog.Leveler // Minimum level; Info when nil
+	Format string       // "json" (the default) or "text"
+}
+
+// Redacted replaces the values of personal attributes
+const Redacted = "[REDACTED]"
+
+// personalPrefixes mark attributes holding details of members of the
+// public: whoever submitted a case and the official it concerns. Their
+// values never reach the log, whatever the caller passes.
+var personalPrefixes = []string{"submitter_", "subject_"}
+
+// New returns a logger writing to w that redacts personal attributes
+func New(w io.Writer, opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level, ReplaceAttr: redact}
+	if opts.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, handlerOpts))
+	}
+	return slog.New(slog.NewJSONHandler(w, handlerOpts))
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 || a.Key == "" {
+		return a
+	}
+	for _, prefix := range personalPrefixes {
+		if strings.HasPrefix(a.Key, prefix) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	return a
+}
+
+type ctxKey struct{}
+
+// scope is a request's logger. It is shared by every context derived from
+// the request's, so attributes added deep in the handler chain, such as
+// the signed-in user, also appear in the access log written further out.
+type scope struct {
+	mu     sync.Mutex
+	base   *slog.Logger
+	attrs  []slog.Attr
+	logger *slog.Logger // base with attrs, rebuilt when they change
+}
+
+// NewContext returns a context carrying logger as the request's logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &scope{base: logger, logger: logger})
+}
+
+// FromContext returns the request's logger, or the default logger outside
+// a request
+func FromContext(ctx context.Context) *slog.Logger {
+	if s, ok := ctx.Value(ctxKey{}).(*scope); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.logger
+	}
+	return slog.Default()
+}
+
+// With adds attributes to the request's logger, for everything logged
+// about the request from then on. An attribute replaces one with the same
+// key, so nested routers can each record the route. It does nothing
+// outside a request.
+func With(ctx context.Context, args ...any) {
+	s, ok := ctx.Value(ctxKey{}).(*scope)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A record turns key-value pairs into attributes just as loggers do
+	record := slog.NewRecord(time.Time{}, 0, "", 0)
+	record.Add(args...)
+	record.Attrs(func(a slog.Attr) bool {
+		for i := range s.attrs {
+			if s.attrs[i].Key == a.Key {
+				s.attrs[i] = a
+				return true
+			}
+		}
+		s.attrs = append(s.attrs, a)
+		return true
+	})
+	s.logger = s.base.With(attrsToArgs(s.attrs)...)
+}
+
+func attrsToArgs(attrs []slog.Attr) []any {
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	return args
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log line is not JSON: %q", buf.String())
+	}
+	buf.Reset()
+	return record
+}
+
+func TestRedactsPersonalAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Options{})
+
+	logger.Info("case created", "case_number", "EC-2026-001", "submitter_name", "Jane Doe",
+		"submitter_email", "jane@example.com", "subject_name", "Bob Official")
+	record := decode(t, &buf)
+	for _, key := range []string{"submitter_name", "submitter_email", "subject_name"} {
+		if record[key] != Redacted {
+			t.Errorf("%s = %v, want it redacted", key, record[key])
+		}
+	}
+	if record["case_number"] != "EC-2026-001" {
+		t.Errorf("case_number = %v", record["case_number"])
+	}
+
+	// Attributes added up front are redacted too
+	logger.With("submitter_phone", "775-555-0100").Info("x")
+	if record := decode(t, &buf); record["submitter_phone"] != Redacted {
+		t.Errorf("submitter_phone = %v, want it redacted", record["submitter_phone"])
+	}
+}
+
+func TestLevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Options{Level: slog.LevelWarn, Format: "text"})
+	logger.Info("quiet")
+	logger.Warn("loud", "submitter_name", "Jane Doe")
+	out := buf.String()
+	if strings.Contains(out, "quiet") || !strings.Contains(out, "level=WARN msg=loud") {
+		t.Errorf("output %q, want only the warning as text", out)
+	}
+	if strings.Contains(out, "Jane") {
+		t.Errorf("text output %q is not redacted", out)
+	}
+}
+
+func TestRequestScope(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := NewContext(context.Background(), New(&buf, Options{}).With("request_id", "r1"))
+	inner := context.WithValue(ctx, struct{}{}, "derived")
+
+	// Attributes added through a derived context reach the outer one
+	With(inner, "route", "/staff/", "user_id", "user_1")
+	With(inner, "route", "/staff/cases")
+	FromContext(ctx).Info("request")
+
+	if n := strings.Count(buf.String(), `"route"`); n != 1 {
+		t.Errorf("route logged %d times: %s", n, buf.String())
+	}
+	record := decode(t, &buf)
+	want := map[string]interface{}{"request_id": "r1", "route": "/staff/cases", "user_id": "user_1"}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("%s = %v, want %v", key, record[key], value)
+		}
+	}
+
+	// Outside a request the default logger is used and With does nothing
+	With(context.Background(), "user_id", "x")
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("FromContext without a request is not the default logger")
+	}
+}
@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// relay accepts one message over SMTP, without TLS or sign-in, and sends
+// the commands and data it received on the returned channel
+func relay(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var received strings.Builder
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 relay ready")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			received.WriteString(line)
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					received.WriteString(line)
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				got <- received.String()
+				return
+			default:
+				reply("502 unknown")
+			}
+		}
+		got <- received.String()
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestSMTPSend(t *testing.T) {
+	addr, got := relay(t)
+	s := &SMTP{Addr: addr}
+	msg := Message{From: "ncoe@ethics.nv.gov", To: "ida@ncoe.nv.gov", Subject: "Your staff portal account", Body: "Hello Ida,\n\nUse this link.\n"}
+	if err := s.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	received := <-got
+	for _, want := range []string{
+		"MAIL FROM:<ncoe@ethics.nv.gov>",
+		"RCPT TO:<ida@ncoe.nv.gov>",
+		"To: ida@ncoe.nv.gov\r\n",
+		"Subject: Your staff portal account\r\n",
+		"\r\n\r\nHello Ida,\r\n\r\nUse this link.\r\n",
+	} {
+		if !strings.Contains(received, want) {
+			t.Errorf("relay received %q, want %q", received, want)
+		}
+	}
+}
+
+func TestMessageRejectsHeaderInjection(t *testing.T) {
+	msg := Message{From: "ncoe@ethics.nv.gov", To: "ida@ncoe.nv.gov\r\nBcc: all@example.com", Subject: "x"}
+	if _, err := msg.Bytes(); err == nil {
+		t.Error("a recipient with a line break was accepted")
+	}
+}
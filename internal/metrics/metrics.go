@@ -0,0 +1,291 @@
+// Package metrics keeps counters and histograms and serves them, with
+// gauges read at scrape time, in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram upper bounds in seconds, suited to request
+// and render latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds the counters and histograms served together
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// family is a counter or histogram with all its labelled series
+type family interface {
+	write(w io.Writer)
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// Default is the registry NewCounter and NewHistogram register with and the
+// server's /metrics serves
+var Default = NewRegistry()
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[name]; ok {
+		panic("metrics: " + name + " registered twice")
+	}
+	r.families[name] = f
+}
+
+// Counter is a count that only goes up, such as requests served, kept per
+// combination of label values
+type Counter struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	series     map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, series: make(map[string]*counterSeries)}
+	r.register(name, c)
+	return c
+}
+
+// NewCounter registers a counter with the default registry
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.Counter(name, help, labels...)
+}
+
+// Inc adds one to the series with the label values, given in the order the
+// labels were registered
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the series with the label values
+func (c *Counter) Add(v float64, values ...string) {
+	checkValues(c.name, c.labels, values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := seriesKey(values)
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+// Value returns the count for the label values
+func (c *Counter) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[seriesKey(values)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelPairs(c.labels, s.values), formatValue(s.value))
+	}
+}
+
+// Histogram counts observations, such as request durations, into buckets
+// by upper bound, kept per combination of label values
+type Histogram struct {
+	name, help string
+	buckets    []float64
+	labels     []string
+	mu         sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // Per bucket, not cumulative; the last is +Inf
+	count  uint64
+	sum    float64
+}
+
+// Histogram registers a histogram with the given bucket upper bounds, in
+// increasing order, and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, buckets: buckets, labels: labels, series: make(map[string]*histogramSeries)}
+	r.register(name, h)
+	return h
+}
+
+// NewHistogram registers a histogram with the default registry
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.Histogram(name, help, buckets, labels...)
+}
+
+// Observe records v in the series with the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	checkValues(h.name, h.labels, values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := seriesKey(values)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[sort.SearchFloat64s(h.buckets, v)]++
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations for the label values
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[seriesKey(values)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	labels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			values := append(append([]string(nil), s.values...), formatValue(le))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(labels, values), cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelPairs(h.labels, s.values), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelPairs(h.labels, s.values), s.count)
+	}
+}
+
+// Gauge is a value read at scrape time, such as a count kept in the
+// database, with a sample per combination of label values
+type Gauge struct {
+	Name    string
+	Help    string
+	Labels  []string
+	Samples []Sample
+}
+
+// Sample is one series of a gauge
+type Sample struct {
+	Values []string // In the order of the gauge's labels
+	Value  float64
+}
+
+func (g Gauge) write(w io.Writer) {
+	writeHeader(w, g.Name, g.Help, "gauge")
+	for _, s := range g.Samples {
+		fmt.Fprintf(w, "%s%s %s\n", g.Name, labelPairs(g.Labels, s.Values), formatValue(s.Value))
+	}
+}
+
+// Write writes the registry's metrics, and the gauges, in the text
+// exposition format, sorted by name
+func (r *Registry) Write(w io.Writer, gauges ...Gauge) {
+	r.mu.Lock()
+	families := make(map[string]family, len(r.families)+len(gauges))
+	for name, f := range r.families {
+		families[name] = f
+	}
+	r.mu.Unlock()
+	for _, g := range gauges {
+		families[g.Name] = g
+	}
+	for _, name := range sortedKeys(families) {
+		families[name].write(w)
+	}
+}
+
+// Handler serves the registry's metrics along with the gauges each collect
+// function returns, called on every scrape
+func Handler(r *Registry, collect ...func() []Gauge) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var gauges []Gauge
+		for _, c := range collect {
+			gauges = append(gauges, c()...)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w, gauges...)
+	})
+}
+
+func checkValues(name string, labels, values []string) {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v", name, labels, values))
+	}
+}
+
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelPairs formats labels as {name="value",...}, or nothing without labels
+func labelPairs(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = label + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
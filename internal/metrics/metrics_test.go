@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("http_requests_total", "Requests served.", "route", "status")
+	latency := r.Histogram("http_request_duration_seconds", "Time to serve requests.", []float64{0.1, 1}, "route")
+
+	requests.Inc("/search", "200")
+	requests.Inc("/search", "200")
+	requests.Add(3, `/say "hi"`, "404")
+	latency.Observe(0.05, "/search")
+	latency.Observe(0.1, "/search")
+	latency.Observe(2, "/search")
+
+	cases := func() []Gauge {
+		return []Gauge{{Name: "cases", Help: "Cases\nby status.", Labels: []string{"status"}, Samples: []Sample{{Values: []string{"open"}, Value: 4}}}}
+	}
+	rec := httptest.NewRecorder()
+	Handler(r, cases).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	want := `# HELP cases Cases\nby status.
+# TYPE cases gauge
+cases{status="open"} 4
+# HELP http_request_duration_seconds Time to serve requests.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{route="/search",le="0.1"} 2
+http_request_duration_seconds_bucket{route="/search",le="1"} 2
+http_request_duration_seconds_bucket{route="/search",le="+Inf"} 3
+http_request_duration_seconds_sum{route="/search"} 2.15
+http_request_duration_seconds_count{route="/search"} 3
+# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{route="/say \"hi\"",status="404"} 3
+http_requests_total{route="/search",status="200"} 2
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+
+	if v := requests.Value("/search", "200"); v != 2 {
+		t.Errorf("Value = %v, want 2", v)
+	}
+	if n := latency.Count("/search"); n != 3 {
+		t.Errorf("Count = %d, want 3", n)
+	}
+}
+
+func TestRegistrationMistakesPanic(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("logins_total", "Logins.")
+	for name, f := range map[string]func(){
+		"duplicate name":   func() { r.Counter("logins_total", "Logins again.") },
+		"missing label":    func() { r.Counter("failures_total", "Failures.", "reason").Inc() },
+		"unexpected label": func() { c.Inc("password") },
+		"histogram labels": func() { r.Histogram("render_seconds", "Renders.", DefaultBuckets, "page").Observe(1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -2,20 +2,32 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strings"
 
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
 	"ncoe/internal/service"
 )
 
 type AuthMiddleware struct {
-	authService *service.AuthService
+	authService  *service.AuthService
+	tokenService *service.APITokenService
 }
 
-func NewAuthMiddleware(as *service.AuthService) *AuthMiddleware {
-	return &AuthMiddleware{authService: as}
+func NewAuthMiddleware(as *service.AuthService, ts *service.APITokenService) *AuthMiddleware {
+	return &AuthMiddleware{authService: as, tokenService: ts}
 }
 
-// RequireAuth wraps a handler to require authentication
+type (
+	ctxKeyAPIToken  struct{}
+	ctxKeyAuthError struct{}
+)
+
+// RequireAuth wraps a handler to require authentication. Only session cookies
+// are accepted: API token scopes are enforced by the JSON API, so tokens are
+// not valid for the staff portal pages.
 func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip auth for login page
@@ -24,20 +36,69 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			http.Redirect(w, r, "/staff/login", http.StatusSeeOther)
-			return
-		}
-
-		user, err := m.authService.ValidateSession(cookie.Value)
-		if err != nil {
+		user := m.authenticate(r)
+		if user == nil {
 			http.Redirect(w, r, "/staff/login", http.StatusSeeOther)
 			return
 		}
 
 		// Add user to context
 		ctx := context.WithValue(r.Context(), "user", user)
+		logging.With(ctx, "user_id", user.ID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Authenticate adds the caller to the context when the request carries a
+// valid session or an "Authorization: Bearer" API token, and passes anonymous
+// requests through unchanged. Handlers behind it decide which routes need a
+// user (e.g. the JSON API answers 401 itself rather than redirecting to the
+// login page). A rejected token is recorded for GetAuthError; the session
+// cookie is not consulted when an Authorization header is present.
+func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if header := r.Header.Get("Authorization"); header != "" {
+			scheme, secret, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") {
+				ctx = context.WithValue(ctx, ctxKeyAuthError{}, errors.New("unsupported authorization scheme; use Bearer"))
+			} else if user, token, err := m.tokenService.Authenticate(ctx, strings.TrimSpace(secret), RemoteIP(r)); err != nil {
+				ctx = context.WithValue(ctx, ctxKeyAuthError{}, err)
+			} else {
+				ctx = context.WithValue(ctx, "user", user)
+				ctx = context.WithValue(ctx, ctxKeyAPIToken{}, token)
+				logging.With(ctx, "user_id", user.ID, "api_token_id", token.ID)
+			}
+		} else if user := m.authenticate(r); user != nil {
+			ctx = context.WithValue(ctx, "user", user)
+			logging.With(ctx, "user_id", user.ID)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// GetAPIToken returns the API token that authenticated the request, or nil
+// for session-authenticated and anonymous requests
+func GetAPIToken(ctx context.Context) *domain.APIToken {
+	t, _ := ctx.Value(ctxKeyAPIToken{}).(*domain.APIToken)
+	return t
+}
+
+// GetAuthError returns why the request's credentials were rejected, or nil
+func GetAuthError(ctx context.Context) error {
+	err, _ := ctx.Value(ctxKeyAuthError{}).(error)
+	return err
+}
+
+// authenticate returns the user for the request's session cookie, or nil
+func (m *AuthMiddleware) authenticate(r *http.Request) *domain.User {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return nil
+	}
+	user, err := m.authService.ValidateSession(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	return user
+}
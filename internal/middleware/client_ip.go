@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ctxKeyClientIP is the context key for the resolved client address
+type ctxKeyClientIP struct{}
+
+// ClientIP middleware resolves the address of the client behind any
+// trusted proxies, for RemoteIP. The connection's address is used unless
+// it is one of trusted; then X-Forwarded-For is read from the right,
+// skipping trusted hops, and the first other address is the client. A
+// trusted proxy that sends X-Real-IP instead is believed. Headers from
+// untrusted peers are ignored, since any client can set them.
+func ClientIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyClientIP{}, ip)))
+		})
+	}
+}
+
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	ip := peerIP(r)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !isTrusted(addr, trusted) {
+		return ip
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) == 0 {
+		if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return realIP.Unmap().String()
+		}
+		return ip
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Garbage from further out; the last good hop is as far as
+			// the chain can be followed
+			return ip
+		}
+		ip = hop.Unmap().String()
+		if !isTrusted(hop, trusted) {
+			return ip
+		}
+	}
+	return ip
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIP returns the connection's address without the port
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RemoteIP returns the client address: the one ClientIP resolved, or the
+// connection's address when the request did not pass through it
+func RemoteIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ctxKeyClientIP{}).(string); ok {
+		return ip
+	}
+	return peerIP(r)
+}
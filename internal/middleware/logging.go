@@ -1,13 +1,18 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
 	"time"
+
+	"ncoe/internal/logging"
 )
 
-// Logging middleware logs all requests with structured output.
-// Format: REQ=request_id METHOD path STATUS duration [user_email]
+// Logging middleware writes an access log record for each request through
+// the request's logger, so it carries request_id, and user_id and route
+// when they are known. Server errors are logged at error level.
 // Requires RequestID middleware to run first in the chain.
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -18,35 +23,47 @@ func Logging(next http.Handler) http.Handler {
 
 		next.ServeHTTP(wrapped, r)
 
-		// Get request ID from context (set by RequestID middleware)
-		requestID := GetRequestID(r.Context())
-		if requestID == "" {
-			requestID = "-"
+		level := slog.LevelInfo
+		if wrapped.status >= 500 {
+			level = slog.LevelError
 		}
+		logging.FromContext(r.Context()).LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", wrapped.status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000.0),
+		)
+	})
+}
 
-		// Get user email from context if available
-		userEmail := "-"
-		if u := r.Context().Value("user"); u != nil {
-			if user, ok := u.(interface{ GetEmail() string }); ok {
-				userEmail = user.GetEmail()
-			}
+// Route records which of mux's patterns matched with SetRoute, so logs and
+// metrics group requests by route rather than by path. Nested routers may
+// each be wrapped; the innermost pattern wins.
+func Route(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			SetRoute(r.Context(), pattern)
 		}
-
-		// Log in structured format
-		log.Printf("REQ=%s %s %s %d %.1fms %s",
-			requestID,
-			r.Method,
-			r.URL.Path,
-			wrapped.status,
-			float64(time.Since(start).Microseconds())/1000.0,
-			userEmail,
-		)
+		mux.ServeHTTP(w, r)
 	})
 }
 
 type responseWriter struct {
 	http.ResponseWriter
 	status int
+	ctx    context.Context // Set by Tracing; see Context
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Context returns the context of the request being answered, for code
+// given the response but not the request, such as the template renderer.
+// Only the Tracing middleware's writer has one; others return nil.
+func (w *responseWriter) Context() context.Context {
+	return w.ctx
 }
 
 func (w *responseWriter) WriteHeader(status int) {
@@ -59,7 +76,7 @@ func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
+				logging.FromContext(r.Context()).Error("panic recovered", "error", err, "stack", string(debug.Stack()))
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			}
 		}()
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ncoe/internal/logging"
+	"ncoe/internal/metrics"
+	"ncoe/internal/tracing"
+)
+
+var (
+	httpRequests = metrics.NewCounter("ncoe_http_requests_total",
+		"HTTP requests served, by route, method and status.", "route", "method", "status")
+	httpDuration = metrics.NewHistogram("ncoe_http_request_duration_seconds",
+		"Time to serve HTTP requests, by route, method and status.", metrics.DefaultBuckets, "route", "method", "status")
+)
+
+// unmatchedRoute labels requests no route matched, so paths probed by
+// scanners do not each become a series
+const unmatchedRoute = "unmatched"
+
+// routeLabel is where the innermost router records the matched route for
+// the Tracing and Metrics middleware further out
+type routeLabel struct {
+	mu    sync.Mutex
+	route string
+}
+
+type routeKey struct{}
+
+// withRouteLabel returns the request's route label, adding one to its
+// context if no middleware further out has
+func withRouteLabel(r *http.Request) (*http.Request, *routeLabel) {
+	if label, ok := r.Context().Value(routeKey{}).(*routeLabel); ok {
+		return r, label
+	}
+	label := &routeLabel{route: unmatchedRoute}
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, label)), label
+}
+
+func (l *routeLabel) get() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.route
+}
+
+// Metrics middleware counts requests and records how long they took, by
+// the route that matched, method and status
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, label := withRouteLabel(r)
+		wrapped := &responseWriter{ResponseWriter: w, status: 200}
+
+		next.ServeHTTP(wrapped, r)
+
+		route := label.get()
+		status := strconv.Itoa(wrapped.status)
+		httpRequests.Inc(route, r.Method, status)
+		httpDuration.Observe(time.Since(start).Seconds(), route, r.Method, status)
+	})
+}
+
+// SetRoute records the route pattern that matched the request, e.g.
+// "/staff/cases/", in its logger, metrics and trace. Nested routers may
+// each set it; the innermost wins.
+func SetRoute(ctx context.Context, route string) {
+	logging.With(ctx, "route", route)
+	tracing.SpanFromContext(ctx).SetAttributes("http.route", route)
+	if label, ok := ctx.Value(routeKey{}).(*routeLabel); ok {
+		label.mu.Lock()
+		label.route = route
+		label.mu.Unlock()
+	}
+}
+
+// RequireBearerToken refuses requests that do not present token as
+// "Authorization: Bearer <token>"
+func RequireBearerToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
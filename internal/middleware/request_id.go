@@ -4,8 +4,11 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"ncoe/internal/logging"
 )
 
 // ctxKeyRequestID is the context key for request IDs
@@ -17,6 +20,7 @@ type ctxKeyRequestID struct{}
 // The request ID is:
 // - Stored in context (use GetRequestID to retrieve)
 // - Added to response header X-Request-Id
+// - Attached to the request's logger (see logging.FromContext)
 func RequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Check for existing request ID in header
@@ -32,6 +36,7 @@ func RequestID(next http.Handler) http.Handler {
 
 		// Store in context
 		ctx := context.WithValue(r.Context(), ctxKeyRequestID{}, requestID)
+		ctx = logging.NewContext(ctx, slog.Default().With("request_id", requestID))
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
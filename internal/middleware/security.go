@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ncoe/internal/logging"
+	"ncoe/internal/ratelimit"
+)
+
+// CSPReportPath receives Content-Security-Policy violation reports
+const CSPReportPath = "/csp-report"
+
+const (
+	// maxCSPReport bounds a violation report body; real ones are a few hundred bytes
+	maxCSPReport = 64 << 10
+	// maxCSPViolations bounds the violations logged from one report; the
+	// rest are counted in a single line
+	maxCSPViolations = 10
+)
+
+// CSPReportRate bounds the reports accepted from one address. A page with
+// a violation sends a few; a client sending many more is flooding the log.
+var CSPReportRate = ratelimit.Rate{Count: 60, Per: time.Minute}
+
+// SecurityOptions configure SecurityHeaders
+type SecurityOptions struct {
+	// ReportOnly sends the policy as Content-Security-Policy-Report-Only,
+	// so violations are reported but nothing is blocked
+	ReportOnly bool
+	// HSTS tells browsers to use HTTPS only, for a year. Leave it off when
+	// the server is reached over plain HTTP, as in development.
+	HSTS bool
+}
+
+// SecurityHeaders sets a Content-Security-Policy with a fresh nonce on every
+// response, along with HSTS, X-Frame-Options, Referrer-Policy and
+// Permissions-Policy. Inline <script> and <style> elements run only when
+// they carry the nonce, which templates read back from the response (see
+// csp.Nonce); inline event handlers such as onclick never run.
+func SecurityHeaders(opts SecurityOptions) func(http.Handler) http.Handler {
+	header := "Content-Security-Policy"
+	if opts.ReportOnly {
+		header = "Content-Security-Policy-Report-Only"
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set(header, cspPolicy(generateNonce()))
+			h.Set("Reporting-Endpoints", `csp="`+CSPReportPath+`"`)
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+			h.Set("Permissions-Policy", "camera=(), microphone=(), geolocation=(), payment=(), usb=()")
+			if opts.HSTS {
+				h.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// cspPolicy returns the policy for a response. Style attributes stay
+// allowed, since Bootstrap markup relies on them and they cannot run code;
+// images may come from other hosts for a logo hosted elsewhere.
+func cspPolicy(nonce string) string {
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'nonce-" + nonce + "'",
+		"style-src 'self' 'nonce-" + nonce + "'",
+		"style-src-attr 'unsafe-inline'",
+		"img-src 'self' data: https:",
+		"font-src 'self'",
+		"connect-src 'self'",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+		"report-uri " + CSPReportPath,
+		"report-to csp",
+	}, "; ")
+}
+
+// generateNonce returns 128 random bits, base64-encoded
+func generateNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// cspViolation is the part of a violation report worth logging. Browsers
+// send either the older report-uri format, {"csp-report": {...}} with
+// hyphenated keys, or Reporting API batches, [{"type": "csp-violation",
+// "body": {...}}] with camelCase keys.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	Disposition        string `json:"disposition"`
+}
+
+type cspReportBody struct {
+	DocumentURL        string `json:"documentURL"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+	Disposition        string `json:"disposition"`
+}
+
+// CSPReport returns a handler that logs Content-Security-Policy violation
+// reports sent by browsers, tagged with the request ID, and answers 204.
+// Reports from an address beyond perIP are refused with 429 unread.
+func CSPReport(perIP ratelimit.Rate) http.Handler {
+	limiter := ratelimit.New(perIP)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if ok, wait := limiter.Allow(RemoteIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many reports", http.StatusTooManyRequests)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReport))
+		if err != nil {
+			http.Error(w, "Report too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		violations, err := parseCSPReport(body)
+		if err != nil {
+			http.Error(w, "Invalid report", http.StatusBadRequest)
+			return
+		}
+		logCSPViolations(logging.FromContext(r.Context()), violations)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// logCSPViolations logs up to maxCSPViolations violations, and how many
+// more were dropped
+func logCSPViolations(logger *slog.Logger, violations []cspViolation) {
+	for i, v := range violations {
+		if i == maxCSPViolations {
+			logger.Warn("CSP violations dropped", "count", len(violations)-i)
+			return
+		}
+		directive := v.EffectiveDirective
+		if directive == "" {
+			directive = v.ViolatedDirective
+		}
+		logger.Warn("CSP violation",
+			"disposition", logValue(v.Disposition),
+			"directive", logValue(directive),
+			"blocked_uri", logValue(v.BlockedURI),
+			"source_file", logValue(v.SourceFile),
+			"line", v.LineNumber,
+			"document_uri", logValue(v.DocumentURI))
+	}
+}
+
+// parseCSPReport reads either report format
+func parseCSPReport(body []byte) ([]cspViolation, error) {
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		var batch []struct {
+			Type string        `json:"type"`
+			Body cspReportBody `json:"body"`
+		}
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, err
+		}
+		var violations []cspViolation
+		for _, report := range batch {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			b := report.Body
+			violations = append(violations, cspViolation{
+				DocumentURI:        b.DocumentURL,
+				BlockedURI:         b.BlockedURL,
+				EffectiveDirective: b.EffectiveDirective,
+				SourceFile:         b.SourceFile,
+				LineNumber:         b.LineNumber,
+				Disposition:        b.Disposition,
+			})
+		}
+		return violations, nil
+	}
+
+	var legacy struct {
+		Report cspViolation `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return nil, err
+	}
+	return []cspViolation{legacy.Report}, nil
+}
+
+// logValue trims a browser-supplied value for the log: control characters
+// are dropped and long values cut short, so a report cannot flood the log
+func logValue(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > 200 {
+		s = s[:200] + "..."
+	}
+	return s
+}
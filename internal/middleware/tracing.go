@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"ncoe/internal/logging"
+	"ncoe/internal/tracing"
+)
+
+// TraceIDHeader returns the request's trace ID, to find its spans in the
+// tracing backend
+const TraceIDHeader = "X-Trace-Id"
+
+// Tracing middleware starts a span for each request, continuing the
+// caller's trace when the request has a W3C traceparent header. The span
+// is named for the method and the route that matched, and carries the
+// request ID and status. The trace ID is returned in X-Trace-Id and added
+// to the request's logger. Requires RequestID middleware to run first.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Default().StartRequest(r.Context(), r.Method, r.Header.Get("traceparent"))
+		defer span.End()
+		traceID := span.SpanContext().TraceID.String()
+		span.SetAttributes(
+			"http.request.method", r.Method,
+			"url.path", r.URL.Path,
+			"request_id", GetRequestID(ctx),
+		)
+		w.Header().Set(TraceIDHeader, traceID)
+		logging.With(ctx, "trace_id", traceID)
+
+		r, label := withRouteLabel(r.WithContext(ctx))
+		wrapped := &responseWriter{ResponseWriter: w, status: 200, ctx: r.Context()}
+
+		next.ServeHTTP(wrapped, r)
+
+		// API routes name their method already, e.g. "GET /api/v1/cases"
+		switch route := label.get(); {
+		case route == unmatchedRoute:
+		case strings.HasPrefix(route, r.Method+" "):
+			span.SetName(route)
+		default:
+			span.SetName(r.Method + " " + route)
+		}
+		span.SetAttributes("http.response.status_code", wrapped.status)
+		if wrapped.status >= 500 {
+			span.RecordError(httpError(wrapped.status))
+		}
+	})
+}
+
+type httpError int
+
+func (e httpError) Error() string { return http.StatusText(int(e)) }
@@ -0,0 +1,171 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// splitJWT decodes a compact JWS into its header, payload, signing input and signature
+func splitJWT(token string) (header jwtHeader, payload []byte, signed string, sig []byte, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return header, nil, "", nil, fmt.Errorf("%w: not a compact JWS", ErrInvalidToken)
+	}
+	rawHeader, err1 := base64.RawURLEncoding.DecodeString(parts[0])
+	payload, err2 := base64.RawURLEncoding.DecodeString(parts[1])
+	sig, err3 := base64.RawURLEncoding.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return header, nil, "", nil, fmt.Errorf("%w: bad base64url encoding", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return header, nil, "", nil, fmt.Errorf("%w: bad header", ErrInvalidToken)
+	}
+	return header, payload, parts[0] + "." + parts[1], sig, nil
+}
+
+// verifySignature checks an RS256 or ES256 signature. Other algorithms,
+// including "none" and the HMAC family, are rejected.
+func verifySignature(alg string, key interface{}, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: RS256 token with a non-RSA key", ErrInvalidToken)
+		}
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+		return nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return fmt.Errorf("%w: malformed ES256 signature", ErrInvalidToken)
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+}
+
+func parseClaims(payload []byte) (*Claims, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("%w: bad payload", ErrInvalidToken)
+	}
+	var std struct {
+		Issuer        string          `json:"iss"`
+		Subject       string          `json:"sub"`
+		Expiry        float64         `json:"exp"`
+		IssuedAt      float64         `json:"iat"`
+		Nonce         string          `json:"nonce"`
+		Email         string          `json:"email"`
+		EmailVerified json.RawMessage `json:"email_verified"`
+		Name          string          `json:"name"`
+		GivenName     string          `json:"given_name"`
+		FamilyName    string          `json:"family_name"`
+	}
+	if err := json.Unmarshal(payload, &std); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	c := &Claims{
+		Issuer:     std.Issuer,
+		Subject:    std.Subject,
+		Nonce:      std.Nonce,
+		Email:      std.Email,
+		Name:       std.Name,
+		GivenName:  std.GivenName,
+		FamilyName: std.FamilyName,
+		raw:        raw,
+	}
+	c.Audience = c.Strings("aud")
+	if std.Expiry > 0 {
+		c.Expiry = time.Unix(int64(std.Expiry), 0)
+	}
+	if std.IssuedAt > 0 {
+		c.IssuedAt = time.Unix(int64(std.IssuedAt), 0)
+	}
+	// Some providers send email_verified as the string "true"
+	switch string(std.EmailVerified) {
+	case "true", `"true"`:
+		c.EmailVerified = true
+	}
+	return c, nil
+}
+
+func (c *Claims) authorizedParty() string {
+	if azp := c.Strings("azp"); len(azp) == 1 {
+		return azp[0]
+	}
+	return ""
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// publicKeys returns the set's signing keys by key ID, skipping keys that
+// are for encryption or cannot be decoded
+func (s jsonWebKeySet) publicKeys() map[string]interface{} {
+	keys := make(map[string]interface{})
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.KeyType {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+			keys[k.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			if k.Curve != "P-256" {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+				continue
+			}
+			// Rejects points that are not on the curve
+			if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+				continue
+			}
+			keys[k.KeyID] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys
+}
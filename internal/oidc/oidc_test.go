@@ -0,0 +1,316 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testProvider is a minimal provider serving discovery, JWKS and a token endpoint
+type testProvider struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mu         sync.Mutex
+	issuer     string // Overrides the issuer in the discovery document
+	jwksHits   int
+	extraKeys  []map[string]string
+	tokenForm  url.Values
+	tokenAuth  [2]string
+	tokenReply string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := &testProvider{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := tp.URL
+		if tp.issuer != "" {
+			issuer = tp.issuer
+		}
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                issuer,
+			AuthorizationEndpoint: tp.URL + "/authorize",
+			TokenEndpoint:         tp.URL + "/token",
+			JWKSURI:               tp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+		tp.jwksHits++
+		keys := []map[string]string{
+			{"kty": "RSA", "kid": "rsa1", "use": "sig", "alg": "RS256",
+				"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec1", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+			{"kty": "RSA", "kid": "enc1", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": append(keys, tp.extraKeys...)})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		user, pass, _ := r.BasicAuth()
+		tp.mu.Lock()
+		tp.tokenForm = r.PostForm
+		tp.tokenAuth = [2]string{user, pass}
+		reply := tp.tokenReply
+		tp.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if reply == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"code expired"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": reply, "token_type": "Bearer", "access_token": "at"})
+	})
+	tp.Server = httptest.NewServer(mux)
+	t.Cleanup(tp.Close)
+	return tp
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// claims returns valid claims for the test client
+func (tp *testProvider) claims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":            tp.URL,
+		"sub":            "248289761001",
+		"aud":            "ncoe",
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          "n-0S6_WzA2Mj",
+		"email":          "jdoe@ethics.nv.gov",
+		"email_verified": true,
+		"name":           "Jane Doe",
+		"groups":         []string{"NCOE-Staff", "All-Employees"},
+	}
+}
+
+func (tp *testProvider) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, tp.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, tp.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	default:
+		sig = []byte("signature")
+	}
+	return signed + "." + b64(sig)
+}
+
+func (tp *testProvider) provider(secret string) *Provider {
+	return NewProvider(Config{Issuer: tp.URL + "/", ClientID: "ncoe", ClientSecret: secret, Scopes: []string{"email", "profile"}})
+}
+
+func TestVerify(t *testing.T) {
+	tp := newTestProvider(t)
+	p := tp.provider("")
+	ctx := context.Background()
+
+	for _, alg := range []struct{ alg, kid string }{{"RS256", "rsa1"}, {"ES256", "ec1"}} {
+		claims, err := p.Verify(ctx, tp.sign(t, alg.alg, alg.kid, tp.claims()), "n-0S6_WzA2Mj")
+		if err != nil {
+			t.Fatalf("%s: valid token rejected: %v", alg.alg, err)
+		}
+		if claims.Subject != "248289761001" || claims.Email != "jdoe@ethics.nv.gov" || !claims.EmailVerified || claims.Name != "Jane Doe" {
+			t.Errorf("%s: unexpected claims: %+v", alg.alg, claims)
+		}
+		if groups := claims.Strings("groups"); len(groups) != 2 || groups[0] != "NCOE-Staff" {
+			t.Errorf("%s: groups = %v", alg.alg, groups)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(map[string]interface{})
+		alg    string
+		kid    string
+		nonce  string
+	}{
+		{name: "WrongNonce", nonce: "other"},
+		{name: "EmptyNonce", nonce: "-"},
+		{name: "WrongIssuer", modify: func(c map[string]interface{}) { c["iss"] = "https://evil.example" }},
+		{name: "WrongAudience", modify: func(c map[string]interface{}) { c["aud"] = "someone-else" }},
+		{name: "MultipleAudiencesNoAzp", modify: func(c map[string]interface{}) { c["aud"] = []string{"ncoe", "other"} }},
+		{name: "Expired", modify: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "NoExpiry", modify: func(c map[string]interface{}) { delete(c, "exp") }},
+		{name: "IssuedInFuture", modify: func(c map[string]interface{}) { c["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{name: "NoSubject", modify: func(c map[string]interface{}) { delete(c, "sub") }},
+		{name: "AlgNone", alg: "none", kid: "rsa1"},
+		{name: "HS256", alg: "HS256", kid: "rsa1"},
+		{name: "RS256WithECKey", alg: "RS256", kid: "ec1"},
+		{name: "EncryptionKey", alg: "RS256", kid: "enc1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := tp.claims()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			alg, kid, nonce := "RS256", "rsa1", "n-0S6_WzA2Mj"
+			if tt.alg != "" {
+				alg, kid = tt.alg, tt.kid
+			}
+			if tt.nonce == "-" {
+				nonce = ""
+			} else if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			_, err := p.Verify(ctx, tp.sign(t, alg, kid, claims), nonce)
+			if !errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("expected rejection, got %v", err)
+			}
+		})
+	}
+
+	t.Run("MultipleAudiencesWithAzp", func(t *testing.T) {
+		claims := tp.claims()
+		claims["aud"] = []string{"ncoe", "other"}
+		claims["azp"] = "ncoe"
+		if _, err := p.Verify(ctx, tp.sign(t, "RS256", "rsa1", claims), "n-0S6_WzA2Mj"); err != nil {
+			t.Errorf("azp token rejected: %v", err)
+		}
+	})
+
+	t.Run("TamperedPayload", func(t *testing.T) {
+		token := tp.sign(t, "RS256", "rsa1", tp.claims())
+		parts := strings.Split(token, ".")
+		claims := tp.claims()
+		claims["groups"] = []string{"NCOE-Admins"}
+		payload, _ := json.Marshal(claims)
+		parts[1] = b64(payload)
+		if _, err := p.Verify(ctx, strings.Join(parts, "."), "n-0S6_WzA2Mj"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("expected bad signature, got %v", err)
+		}
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	tp := newTestProvider(t)
+	p := tp.provider("")
+	ctx := context.Background()
+
+	if _, err := p.Verify(ctx, tp.sign(t, "RS256", "rsa1", tp.claims()), "n-0S6_WzA2Mj"); err != nil {
+		t.Fatal(err)
+	}
+	// Known keys are served from cache
+	p.Verify(ctx, tp.sign(t, "RS256", "rsa1", tp.claims()), "n-0S6_WzA2Mj")
+	if tp.jwksHits != 1 {
+		t.Errorf("JWKS fetched %d times, expected 1", tp.jwksHits)
+	}
+
+	// An unknown kid refetches, at most once a minute
+	_, err := p.Verify(ctx, tp.sign(t, "RS256", "rsa2", tp.claims()), "n-0S6_WzA2Mj")
+	if !errors.Is(err, ErrUnknownKey) || tp.jwksHits != 1 {
+		t.Errorf("expected cached rejection, got %v after %d fetches", err, tp.jwksHits)
+	}
+
+	p.fetched = time.Now().Add(-2 * time.Minute)
+	tp.mu.Lock()
+	tp.extraKeys = []map[string]string{{"kty": "RSA", "kid": "rsa2", "n": b64(tp.rsaKey.N.Bytes()), "e": "AQAB"}}
+	tp.mu.Unlock()
+	if _, err := p.Verify(ctx, tp.sign(t, "RS256", "rsa2", tp.claims()), "n-0S6_WzA2Mj"); err != nil {
+		t.Errorf("rotated key rejected: %v", err)
+	}
+	if tp.jwksHits != 2 {
+		t.Errorf("JWKS fetched %d times, expected 2", tp.jwksHits)
+	}
+}
+
+func TestAuthCodeFlow(t *testing.T) {
+	tp := newTestProvider(t)
+	ctx := context.Background()
+	verifier, challenge := NewPKCE()
+	sum := sha256.Sum256([]byte(verifier))
+	if challenge != b64(sum[:]) || len(verifier) < 43 {
+		t.Fatalf("bad PKCE pair: %s %s", verifier, challenge)
+	}
+
+	p := tp.provider("s3cret&")
+	authURL, err := p.AuthCodeURL(ctx, "https://ncoe.example/staff/login/sso/callback", "st", "nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("code_challenge") != challenge || q.Get("code_challenge_method") != "S256" ||
+		q.Get("scope") != "openid email profile" || q.Get("state") != "st" || q.Get("nonce") != "nonce" || q.Get("client_id") != "ncoe" {
+		t.Errorf("unexpected authorization URL: %s", authURL)
+	}
+
+	tp.tokenReply = "header.payload.sig"
+	token, err := p.Exchange(ctx, "code123", verifier, "https://ncoe.example/staff/login/sso/callback")
+	if err != nil || token != "header.payload.sig" {
+		t.Fatalf("exchange: %q %v", token, err)
+	}
+	if tp.tokenForm.Get("code_verifier") != verifier || tp.tokenForm.Get("code") != "code123" || tp.tokenForm.Get("grant_type") != "authorization_code" {
+		t.Errorf("unexpected token request: %v", tp.tokenForm)
+	}
+	if tp.tokenAuth != [2]string{"ncoe", "s3cret%26"} || tp.tokenForm.Get("client_secret") != "" {
+		t.Errorf("confidential clients should use form-encoded basic auth, got %v", tp.tokenAuth)
+	}
+
+	// Public clients identify themselves in the form
+	tp.provider("").Exchange(ctx, "code123", verifier, "https://ncoe.example/cb")
+	if tp.tokenForm.Get("client_id") != "ncoe" || tp.tokenAuth[0] != "" {
+		t.Errorf("public client should send client_id in the form: %v", tp.tokenForm)
+	}
+
+	tp.tokenReply = ""
+	if _, err := p.Exchange(ctx, "code123", verifier, "https://ncoe.example/cb"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("expected provider error to be reported, got %v", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	tp := newTestProvider(t)
+	tp.issuer = "https://login.example/tenant"
+	_, err := tp.provider("").Metadata(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected discovery to fail for a mismatched issuer, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+// Package ooxml writes the zip packages Office Open XML files are stored
+// in, such as .xlsx workbooks and .docx documents. The output is
+// byte-for-byte reproducible for the same parts.
+package ooxml
+
+import (
+	"archive/zip"
+	"io"
+	"time"
+)
+
+// zipTime is the modification time stamped on every part, so the same
+// parts always produce the same bytes
+var zipTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Part is one file in the package, named by its path, e.g. "word/document.xml"
+type Part struct {
+	Name string
+	Body []byte
+}
+
+// Write writes the parts, compressed and in order, as a zip package
+func Write(w io.Writer, parts []Part) error {
+	zw := zip.NewWriter(w)
+	for _, p := range parts {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: p.Name, Method: zip.Deflate, Modified: zipTime})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(p.Body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
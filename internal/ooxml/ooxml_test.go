@@ -0,0 +1,44 @@
+package ooxml
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	parts := []Part{
+		{Name: "[Content_Types].xml", Body: []byte("<Types/>")},
+		{Name: "word/document.xml", Body: []byte("<w:document/>")},
+	}
+	var first, second bytes.Buffer
+	if err := Write(&first, parts); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&second, parts); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("the same parts produced different bytes")
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(first.Bytes()), int64(first.Len()))
+	if err != nil {
+		t.Fatalf("not a zip archive: %v", err)
+	}
+	if len(zr.File) != len(parts) {
+		t.Fatalf("%d files, want %d", len(zr.File), len(parts))
+	}
+	for i, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		if f.Name != parts[i].Name || string(body) != string(parts[i].Body) {
+			t.Errorf("file %d is %s %q, want %s %q", i, f.Name, body, parts[i].Name, parts[i].Body)
+		}
+	}
+}
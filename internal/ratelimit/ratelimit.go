@@ -0,0 +1,117 @@
+// Package ratelimit implements token-bucket rate limiting keyed by an
+// arbitrary string, such as a client IP address or an email address.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Rate allows Count events per Per, in bursts of up to Count. The zero Rate
+// allows everything.
+type Rate struct {
+	Count int
+	Per   time.Duration
+}
+
+// Unlimited reports whether r allows everything
+func (r Rate) Unlimited() bool {
+	return r.Count <= 0 || r.Per <= 0
+}
+
+// sweepEvery is how often buckets that have refilled are forgotten
+const sweepEvery = time.Minute
+
+// Limiter holds one token bucket per key. A bucket starts full, refills
+// continuously at the rate, and is forgotten once full again, so memory
+// grows only with recently active keys.
+//
+// Each bucket is kept as the time it will next be full, which gives exact
+// waits with integer arithmetic: taking a token pushes that time back by
+// one interval, and the bucket is empty when it is a whole burst ahead.
+type Limiter struct {
+	rate Rate
+	now  func() time.Time
+
+	mu        sync.Mutex
+	full      map[string]time.Time // Key -> when its bucket is full again
+	lastSweep time.Time
+}
+
+// New creates a limiter for the rate
+func New(rate Rate) *Limiter {
+	return NewWithClock(rate, time.Now)
+}
+
+// NewWithClock creates a limiter that reads the time from now, for tests
+func NewWithClock(rate Rate, now func() time.Time) *Limiter {
+	return &Limiter{rate: rate, now: now, full: make(map[string]time.Time)}
+}
+
+// Allow takes a token from key's bucket. If the bucket is empty it returns
+// false and how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.rate.Unlimited() {
+		return true, 0
+	}
+	interval := l.rate.Per / time.Duration(l.rate.Count)
+	burst := interval * time.Duration(l.rate.Count)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepEvery {
+		l.sweep(now)
+	}
+
+	full := l.full[key]
+	if full.Before(now) {
+		full = now
+	}
+	next := full.Add(interval)
+	if ahead := next.Sub(now); ahead > burst {
+		return false, ahead - burst
+	}
+	l.full[key] = next
+	return true, 0
+}
+
+// Blocked reports whether key's bucket is empty, and if so how long until a
+// token is available, without taking a token. It suits limits charged only
+// for failures: check Blocked first and call Allow when an attempt fails.
+func (l *Limiter) Blocked(key string) (bool, time.Duration) {
+	if l.rate.Unlimited() {
+		return false, 0
+	}
+	interval := l.rate.Per / time.Duration(l.rate.Count)
+	burst := interval * time.Duration(l.rate.Count)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	full, ok := l.full[key]
+	if !ok || !full.After(now) {
+		return false, 0
+	}
+	if ahead := full.Add(interval).Sub(now); ahead > burst {
+		return true, ahead - burst
+	}
+	return false, 0
+}
+
+// sweep forgets buckets that have refilled, which behave like new ones
+func (l *Limiter) sweep(now time.Time) {
+	for key, full := range l.full {
+		if !full.After(now) {
+			delete(l.full, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Len returns the number of keys being tracked
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.full)
+}
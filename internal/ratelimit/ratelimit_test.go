@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a settable time source
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestBurstThenRefill(t *testing.T) {
+	c := &clock{t: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)}
+	l := NewWithClock(Rate{Count: 3, Per: time.Hour}, c.now)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("203.0.113.7"); !ok {
+			t.Fatalf("request %d of the burst refused", i+1)
+		}
+	}
+	ok, wait := l.Allow("203.0.113.7")
+	if ok {
+		t.Fatal("fourth request in the burst allowed")
+	}
+	if wait != 20*time.Minute {
+		t.Errorf("wait = %v, want 20m for one token at 3/h", wait)
+	}
+
+	// Keys have separate buckets
+	if ok, _ := l.Allow("198.51.100.4"); !ok {
+		t.Error("another key was refused")
+	}
+
+	c.advance(19 * time.Minute)
+	if ok, wait := l.Allow("203.0.113.7"); ok || wait != time.Minute {
+		t.Errorf("after 19m: ok=%v wait=%v, want refused with 1m to wait", ok, wait)
+	}
+	c.advance(time.Minute)
+	if ok, _ := l.Allow("203.0.113.7"); !ok {
+		t.Error("refused after a token refilled")
+	}
+	if ok, _ := l.Allow("203.0.113.7"); ok {
+		t.Error("allowed a second request on one refilled token")
+	}
+
+	// Refilling stops at the burst size
+	c.advance(24 * time.Hour)
+	for i := 0; i < 3; i++ {
+		l.Allow("203.0.113.7")
+	}
+	if ok, _ := l.Allow("203.0.113.7"); ok {
+		t.Error("a long idle period allowed more than the burst")
+	}
+}
+
+func TestBlockedDoesNotTakeTokens(t *testing.T) {
+	c := &clock{t: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)}
+	l := NewWithClock(Rate{Count: 2, Per: time.Hour}, c.now)
+
+	for i := 0; i < 5; i++ {
+		if blocked, _ := l.Blocked("user_1"); blocked {
+			t.Fatal("an unused key is blocked")
+		}
+	}
+	l.Allow("user_1")
+	if blocked, _ := l.Blocked("user_1"); blocked {
+		t.Fatal("blocked with a token left")
+	}
+	l.Allow("user_1")
+	if blocked, wait := l.Blocked("user_1"); !blocked || wait != 30*time.Minute {
+		t.Errorf("after the burst: blocked=%v wait=%v, want blocked for 30m", blocked, wait)
+	}
+	c.advance(30 * time.Minute)
+	if blocked, _ := l.Blocked("user_1"); blocked {
+		t.Error("still blocked after a token refilled")
+	}
+	if ok, _ := l.Allow("user_1"); !ok {
+		t.Error("Blocked used up the refilled token")
+	}
+}
+
+func TestSweepForgetsRefilledKeys(t *testing.T) {
+	c := &clock{t: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)}
+	l := NewWithClock(Rate{Count: 2, Per: time.Minute}, c.now)
+
+	l.Allow("a")
+	l.Allow("b")
+	l.Allow("b")
+	if l.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", l.Len())
+	}
+
+	c.advance(2 * time.Minute)
+	l.Allow("c")
+	if l.Len() != 1 {
+		t.Errorf("Len after sweep = %d, want only the new key", l.Len())
+	}
+}
+
+func TestZeroRateAllowsEverything(t *testing.T) {
+	l := New(Rate{})
+	for i := 0; i < 1000; i++ {
+		if ok, _ := l.Allow("x"); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	if l.Len() != 0 {
+		t.Errorf("an unlimited limiter tracked %d keys", l.Len())
+	}
+}
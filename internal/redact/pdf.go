@@ -0,0 +1,865 @@
+package redact
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"ncoe/internal/domain"
+)
+
+// ErrUnsupportedPDF is returned for PDFs whose structure the redactor cannot
+// rewrite safely. Redaction fails closed: staff must flatten or re-export
+// the file rather than publish something that may still contain the text.
+var ErrUnsupportedPDF = errors.New("unsupported PDF")
+
+// PDF removes text under each region and paints an opaque box over it.
+//
+// The document is fully rewritten (not incrementally updated), so earlier
+// revisions of modified objects, the /Info dictionary and annotations on
+// redacted pages are dropped. Text-showing operators are removed when the
+// band their glyphs occupy, after the text matrix, rise and CTM are applied,
+// crosses a region; glyph widths are not known, so whole runs are removed
+// rather than individual characters, and a run is assumed to reach the edge
+// of the page. Marked-content property lists (/ActualText, /Alt) on redacted
+// pages are dropped, as are the structure tree and XMP metadata, which
+// repeat the text; documents that keep such properties elsewhere, and pages
+// that draw images or form XObjects, are rejected because their contents
+// cannot be inspected.
+func PDF(content []byte, redactions []domain.Redaction) ([]byte, error) {
+	byPage := map[int][]domain.Redaction{}
+	for _, r := range redactions {
+		if r.Kind != domain.RedactionRegion {
+			return nil, fmt.Errorf("redaction %s: %s redactions do not apply to PDF documents", r.ID, r.Kind)
+		}
+		if r.Page < 1 || r.Width <= 0 || r.Height <= 0 {
+			return nil, fmt.Errorf("redaction %s: invalid region (page %d, %gx%g)", r.ID, r.Page, r.Width, r.Height)
+		}
+		byPage[r.Page] = append(byPage[r.Page], r)
+	}
+
+	doc, err := parsePDF(content)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := doc.pageOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	owners := map[int]int{} // content stream object -> page number
+	for pageNum, pageObj := range pages {
+		for _, ref := range doc.contentRefs(pageObj) {
+			owners[ref] = pageNum + 1
+		}
+	}
+
+	for pageNum, regions := range byPage {
+		if pageNum > len(pages) {
+			return nil, fmt.Errorf("redaction on page %d, but document has %d pages", pageNum, len(pages))
+		}
+		page := doc.objects[pages[pageNum-1]]
+		refs := doc.contentRefs(pages[pageNum-1])
+		if len(refs) == 0 {
+			return nil, fmt.Errorf("%w: page %d has no content stream", ErrUnsupportedPDF, pageNum)
+		}
+
+		var combined bytes.Buffer
+		for _, ref := range refs {
+			if owners[ref] != pageNum {
+				return nil, fmt.Errorf("%w: page %d shares a content stream with page %d", ErrUnsupportedPDF, pageNum, owners[ref])
+			}
+			obj := doc.objects[ref]
+			if obj == nil || obj.stream == nil {
+				return nil, fmt.Errorf("%w: page %d content object %d is missing", ErrUnsupportedPDF, pageNum, ref)
+			}
+			data, err := decodeStream(obj)
+			if err != nil {
+				return nil, fmt.Errorf("page %d: %w", pageNum, err)
+			}
+			combined.Write(data)
+			combined.WriteByte('\n')
+		}
+
+		rewritten, err := redactContent(combined.Bytes(), regions)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+
+		// Combined content goes into the first stream; the rest become empty
+		for i, ref := range refs {
+			data := rewritten
+			if i > 0 {
+				data = nil
+			}
+			doc.objects[ref].setStream(data)
+		}
+		page.dict = annotsPattern.ReplaceAll(page.dict, nil)
+		if page.dict, err = removeKeys(page.dict, "/Metadata", "/PieceInfo", "/StructParents"); err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+	}
+
+	if len(byPage) > 0 {
+		if err := doc.dropTextCopies(); err != nil {
+			return nil, err
+		}
+	}
+	return doc.write(), nil
+}
+
+// CheckPDF reports whether PDF can redact a document: every page is scanned
+// as if a region were drawn on it. Only PDFs whose pages are all text and
+// vector graphics qualify. Scanned pages and pages carrying a logo or
+// signature are images, and documents saved with compressed object streams
+// (the default in Word and most PDF 1.5+ producers), encryption or
+// predictor-encoded streams cannot be rewritten either; such documents must
+// be exported as text and redacted in that form.
+func CheckPDF(content []byte) error {
+	doc, err := parsePDF(content)
+	if err != nil {
+		return err
+	}
+	pages, err := doc.pageOrder()
+	if err != nil {
+		return err
+	}
+	for i, pageObj := range pages {
+		for _, ref := range doc.contentRefs(pageObj) {
+			obj := doc.objects[ref]
+			if obj == nil || obj.stream == nil {
+				return fmt.Errorf("%w: page %d content object %d is missing", ErrUnsupportedPDF, i+1, ref)
+			}
+			data, err := decodeStream(obj)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", i+1, err)
+			}
+			if _, err := redactContent(data, nil); err != nil {
+				return fmt.Errorf("page %d: %w", i+1, err)
+			}
+		}
+	}
+	return doc.dropTextCopies()
+}
+
+// dropTextCopies removes the parts of the catalog that repeat page text: the
+// structure tree (whose /Alt and /ActualText entries hold alternate text) and
+// the XMP metadata stream. Any /ActualText or /Alt left in a reachable
+// object, such as a shared /Properties resource, fails the redaction.
+func (d *pdfDoc) dropTextCopies() error {
+	catalog := d.objects[d.root]
+	var err error
+	if catalog.dict, err = removeKeys(catalog.dict, "/StructTreeRoot", "/MarkInfo", "/Metadata", "/PieceInfo"); err != nil {
+		return fmt.Errorf("catalog: %w", err)
+	}
+	for _, n := range d.reachable() {
+		if alternateTextPattern.Match(d.objects[n].dict) {
+			return fmt.Errorf("%w: object %d carries alternate text for marked content", ErrUnsupportedPDF, n)
+		}
+	}
+	return nil
+}
+
+// removeKeys deletes dictionary entries whose value is a reference, number,
+// boolean or flat dictionary. Any other value fails closed.
+func removeKeys(dict []byte, keys ...string) ([]byte, error) {
+	for _, key := range keys {
+		q := regexp.QuoteMeta(key)
+		dict = regexp.MustCompile(q+`\s*(\d+\s+\d+\s+R|<<[^<>]*>>|-?[\d.]+|true|false)`).ReplaceAll(dict, nil)
+		if regexp.MustCompile(q + `\b`).Match(dict) {
+			return nil, fmt.Errorf("%w: cannot remove %s", ErrUnsupportedPDF, key)
+		}
+	}
+	return dict, nil
+}
+
+var (
+	objPattern           = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	refPattern           = regexp.MustCompile(`(\d+)\s+\d+\s+R`)
+	rootPattern          = regexp.MustCompile(`/Root\s+(\d+)\s+\d+\s+R`)
+	kidsPattern          = regexp.MustCompile(`/Kids\s*\[([^\]]*)\]`)
+	contentsPattern      = regexp.MustCompile(`/Contents\s*(\[[^\]]*\]|\d+\s+\d+\s+R)`)
+	lengthPattern        = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+	filterPattern        = regexp.MustCompile(`/Filter\s*(\[[^\]]*\]|/\w+)`)
+	annotsPattern        = regexp.MustCompile(`/Annots\s*(\[[^\]]*\]|\d+\s+\d+\s+R)`)
+	typePagePattern      = regexp.MustCompile(`/Type\s*/Page\b`)
+	alternateTextPattern = regexp.MustCompile(`/(ActualText|Alt)\b`)
+	versionPattern       = regexp.MustCompile(`^%PDF-(\d\.\d)`)
+)
+
+type pdfObject struct {
+	num, gen int
+	dict     []byte // object body before the stream keyword (or the whole body)
+	stream   []byte // raw (still encoded) stream data, nil if not a stream
+}
+
+func (o *pdfObject) setStream(data []byte) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	o.dict = []byte(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", buf.Len()))
+	o.stream = buf.Bytes()
+}
+
+type pdfDoc struct {
+	version string
+	objects map[int]*pdfObject
+	root    int
+}
+
+// parsePDF scans every "n g obj" definition in file order, so objects from
+// later incremental updates replace earlier revisions.
+func parsePDF(content []byte) (*pdfDoc, error) {
+	m := versionPattern.FindSubmatch(content)
+	if m == nil {
+		return nil, fmt.Errorf("%w: missing %%PDF header", ErrUnsupportedPDF)
+	}
+	doc := &pdfDoc{version: string(m[1]), objects: map[int]*pdfObject{}}
+
+	// First pass: object boundaries, so indirect /Length values can be resolved
+	matches := objPattern.FindAllSubmatchIndex(content, -1)
+	type span struct{ num, gen, start int }
+	var spans []span
+	direct := map[int][]byte{}
+	for _, loc := range matches {
+		num, _ := strconv.Atoi(string(content[loc[2]:loc[3]]))
+		gen, _ := strconv.Atoi(string(content[loc[4]:loc[5]]))
+		spans = append(spans, span{num, gen, loc[1]})
+		if end := bytes.Index(content[loc[1]:], []byte("endobj")); end >= 0 {
+			direct[num] = bytes.TrimSpace(content[loc[1] : loc[1]+end])
+		}
+	}
+
+	pos := 0
+	for _, sp := range spans {
+		if sp.start < pos {
+			continue // match inside a previous object's stream data
+		}
+		obj, end, err := parseObject(content, sp.start, direct)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", sp.num, err)
+		}
+		obj.num, obj.gen = sp.num, sp.gen
+		doc.objects[sp.num] = obj
+		pos = end
+	}
+
+	if bytes.Contains(content, []byte("/Encrypt")) {
+		return nil, fmt.Errorf("%w: encrypted documents are not supported", ErrUnsupportedPDF)
+	}
+	for _, obj := range doc.objects {
+		if bytes.Contains(obj.dict, []byte("/ObjStm")) {
+			return nil, fmt.Errorf("%w: compressed object streams are not supported; re-save as PDF 1.4", ErrUnsupportedPDF)
+		}
+	}
+
+	// Trailer (classic) or cross-reference stream dictionary gives the catalog
+	roots := rootPattern.FindAllSubmatch(content, -1)
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("%w: document catalog not found", ErrUnsupportedPDF)
+	}
+	doc.root, _ = strconv.Atoi(string(roots[len(roots)-1][1]))
+	return doc, nil
+}
+
+func parseObject(content []byte, start int, direct map[int][]byte) (*pdfObject, int, error) {
+	endObj := bytes.Index(content[start:], []byte("endobj"))
+	streamIdx := bytes.Index(content[start:], []byte("stream"))
+	if endObj < 0 {
+		return nil, 0, errors.New("missing endobj")
+	}
+	if streamIdx < 0 || streamIdx > endObj {
+		return &pdfObject{dict: bytes.TrimSpace(content[start : start+endObj])}, start + endObj + len("endobj"), nil
+	}
+
+	obj := &pdfObject{dict: bytes.TrimSpace(content[start : start+streamIdx])}
+	dataStart := start + streamIdx + len("stream")
+	if bytes.HasPrefix(content[dataStart:], []byte("\r\n")) {
+		dataStart += 2
+	} else if dataStart < len(content) && content[dataStart] == '\n' {
+		dataStart++
+	}
+
+	length := -1
+	if m := lengthPattern.FindSubmatch(obj.dict); m != nil {
+		n, _ := strconv.Atoi(string(m[1]))
+		if len(m[2]) == 0 {
+			length = n
+		} else if v, err := strconv.Atoi(string(direct[n])); err == nil {
+			length = v
+		}
+	}
+	if length < 0 || dataStart+length > len(content) {
+		// Fall back to the endstream keyword
+		idx := bytes.Index(content[dataStart:], []byte("endstream"))
+		if idx < 0 {
+			return nil, 0, errors.New("missing endstream")
+		}
+		length = len(bytes.TrimRight(content[dataStart:dataStart+idx], "\r\n"))
+	}
+	obj.stream = content[dataStart : dataStart+length]
+
+	rest := dataStart + length
+	end := bytes.Index(content[rest:], []byte("endobj"))
+	if end < 0 {
+		return nil, 0, errors.New("missing endobj after stream")
+	}
+	return obj, rest + end + len("endobj"), nil
+}
+
+// pageOrder walks the page tree from the catalog, returning page object numbers in order.
+func (d *pdfDoc) pageOrder() ([]int, error) {
+	catalog := d.objects[d.root]
+	if catalog == nil {
+		return nil, fmt.Errorf("%w: catalog object %d missing", ErrUnsupportedPDF, d.root)
+	}
+	m := regexp.MustCompile(`/Pages\s+(\d+)\s+\d+\s+R`).FindSubmatch(catalog.dict)
+	if m == nil {
+		return nil, fmt.Errorf("%w: catalog has no page tree", ErrUnsupportedPDF)
+	}
+	root, _ := strconv.Atoi(string(m[1]))
+
+	var pages []int
+	seen := map[int]bool{}
+	var walk func(num int) error
+	walk = func(num int) error {
+		if seen[num] {
+			return fmt.Errorf("%w: page tree cycle at object %d", ErrUnsupportedPDF, num)
+		}
+		seen[num] = true
+		obj := d.objects[num]
+		if obj == nil {
+			return fmt.Errorf("%w: page tree object %d missing", ErrUnsupportedPDF, num)
+		}
+		if typePagePattern.Match(obj.dict) {
+			pages = append(pages, num)
+			return nil
+		}
+		kids := kidsPattern.FindSubmatch(obj.dict)
+		if kids == nil {
+			return fmt.Errorf("%w: page tree node %d has no kids", ErrUnsupportedPDF, num)
+		}
+		for _, ref := range refPattern.FindAllSubmatch(kids[1], -1) {
+			kid, _ := strconv.Atoi(string(ref[1]))
+			if err := walk(kid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// contentRefs returns the content stream object numbers of a page.
+func (d *pdfDoc) contentRefs(pageNum int) []int {
+	page := d.objects[pageNum]
+	if page == nil {
+		return nil
+	}
+	m := contentsPattern.FindSubmatch(page.dict)
+	if m == nil {
+		return nil
+	}
+	var refs []int
+	for _, ref := range refPattern.FindAllSubmatch(m[1], -1) {
+		n, _ := strconv.Atoi(string(ref[1]))
+		refs = append(refs, n)
+	}
+	return refs
+}
+
+func decodeStream(obj *pdfObject) ([]byte, error) {
+	if bytes.Contains(obj.dict, []byte("/DecodeParms")) {
+		return nil, fmt.Errorf("%w: content stream uses decode parameters", ErrUnsupportedPDF)
+	}
+	m := filterPattern.FindSubmatch(obj.dict)
+	if m == nil {
+		return obj.stream, nil
+	}
+	filter := string(bytes.Trim(m[1], "[] \r\n\t"))
+	if filter != "/FlateDecode" {
+		return nil, fmt.Errorf("%w: content stream filter %s", ErrUnsupportedPDF, filter)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(obj.stream))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedPDF, err)
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// reachable returns the numbers of the objects reachable from the catalog,
+// in order
+func (d *pdfDoc) reachable() []int {
+	reachable := map[int]bool{}
+	queue := []int{d.root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		obj := d.objects[n]
+		if reachable[n] || obj == nil {
+			continue
+		}
+		reachable[n] = true
+		for _, ref := range refPattern.FindAllSubmatch(obj.dict, -1) {
+			num, _ := strconv.Atoi(string(ref[1]))
+			queue = append(queue, num)
+		}
+	}
+	var nums []int
+	for n := range reachable {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// write serializes the objects reachable from the catalog with a fresh
+// cross-reference table. Unreachable objects (the /Info dictionary, removed
+// annotations, superseded cross-reference streams) are dropped.
+func (d *pdfDoc) write() []byte {
+	nums := d.reachable()
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", d.version)
+	offsets := map[int]int{}
+	for _, n := range nums {
+		obj := d.objects[n]
+		offsets[n] = out.Len()
+		fmt.Fprintf(&out, "%d %d obj\n", n, obj.gen)
+		out.Write(obj.dict)
+		if obj.stream != nil {
+			out.WriteString("\nstream\n")
+			out.Write(obj.stream)
+			out.WriteString("\nendstream")
+		}
+		out.WriteString("\nendobj\n")
+	}
+
+	size := 1
+	if len(nums) > 0 {
+		size = nums[len(nums)-1] + 1
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", size)
+	for n := 1; n < size; n++ {
+		if off, ok := offsets[n]; ok {
+			fmt.Fprintf(&out, "%010d %05d n \n", off, d.objects[n].gen)
+		} else {
+			out.WriteString("0000000000 65535 f \n")
+		}
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, d.root, xref)
+	return out.Bytes()
+}
+
+// --- Content stream rewriting ---
+
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m matrix) apply(x, y float64) [2]float64 {
+	return [2]float64{x*m[0] + y*m[2] + m[4], x*m[1] + y*m[3] + m[5]}
+}
+
+// graphicsState is the part of the graphics state that places text; q and
+// Q save and restore it
+type graphicsState struct {
+	ctm       matrix
+	fontSize  float64
+	leading   float64
+	rise      float64 // Ts
+	scale     float64 // Tz, as a fraction
+	charSpace float64 // Tc
+	wordSpace float64 // Tw
+}
+
+type textState struct {
+	graphicsState
+	stack   []graphicsState
+	tm, tlm matrix
+}
+
+// redactContent drops text-showing operators that fall in a region and
+// appends opaque boxes, wrapping the original content in q/Q so the boxes
+// are drawn in default user space.
+func redactContent(content []byte, regions []domain.Redaction) ([]byte, error) {
+	toks, err := tokenize(content)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &textState{graphicsState: graphicsState{ctm: identity, scale: 1}, tm: identity, tlm: identity}
+	var out bytes.Buffer
+	out.WriteString("q\n")
+
+	var operands []token
+	for _, tok := range toks {
+		if tok.kind != tokOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		op := string(tok.raw)
+		nums := numbers(operands)
+		drop := false
+
+		switch op {
+		case "BI", "Do":
+			return nil, fmt.Errorf("%w: page draws images or form XObjects that cannot be inspected", ErrUnsupportedPDF)
+		case "q":
+			st.stack = append(st.stack, st.graphicsState)
+		case "Q":
+			if n := len(st.stack); n > 0 {
+				st.graphicsState = st.stack[n-1]
+				st.stack = st.stack[:n-1]
+			}
+		case "cm":
+			if len(nums) == 6 {
+				st.ctm = matrix{nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]}.mul(st.ctm)
+			}
+		case "BT":
+			st.tm, st.tlm = identity, identity
+		case "Tf":
+			if len(nums) == 1 {
+				st.fontSize = nums[0]
+			}
+		case "TL":
+			if len(nums) == 1 {
+				st.leading = nums[0]
+			}
+		case "Ts":
+			if len(nums) == 1 {
+				st.rise = nums[0]
+			}
+		case "Tz":
+			if len(nums) == 1 {
+				st.scale = nums[0] / 100
+			}
+		case "Tc":
+			if len(nums) == 1 {
+				st.charSpace = nums[0]
+			}
+		case "Tw":
+			if len(nums) == 1 {
+				st.wordSpace = nums[0]
+			}
+		case "BDC", "DP":
+			// Property lists can hold the text as /ActualText or /Alt; only
+			// the tag is kept
+			if len(operands) == 0 {
+				break
+			}
+			tag := operands[0]
+			operands = append(operands[:0], tag)
+			op = map[string]string{"BDC": "BMC", "DP": "MP"}[op]
+		case "Td", "TD":
+			if len(nums) == 2 {
+				if op == "TD" {
+					st.leading = -nums[1]
+				}
+				st.tlm = matrix{1, 0, 0, 1, nums[0], nums[1]}.mul(st.tlm)
+				st.tm = st.tlm
+			}
+		case "Tm":
+			if len(nums) == 6 {
+				st.tlm = matrix{nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]}
+				st.tm = st.tlm
+			}
+		case "T*":
+			st.nextLine()
+		case "Tj", "TJ", "'", "\"":
+			if op == "'" || op == "\"" {
+				st.nextLine()
+			}
+			if op == "\"" && len(nums) >= 2 {
+				st.wordSpace, st.charSpace = nums[0], nums[1]
+			}
+			var back float64
+			if op == "TJ" && len(operands) == 1 {
+				back = backwardAdjustment(operands[0].raw) / 1000 * math.Abs(st.fontSize*st.scale)
+			}
+			if st.charSpace < 0 || st.wordSpace < 0 {
+				back = runLength
+			}
+			drop = st.hits(regions, back)
+		}
+
+		if !drop {
+			for _, o := range operands {
+				out.Write(o.raw)
+				out.WriteByte(' ')
+			}
+			out.WriteString(op)
+			out.WriteByte('\n')
+		} else if op == "\"" && len(nums) >= 2 {
+			// Keep the spacing side effects of " without showing the string
+			fmt.Fprintf(&out, "%s Tw %s Tc\n", fmtNum(nums[0]), fmtNum(nums[1]))
+		}
+		operands = operands[:0]
+	}
+
+	out.WriteString("\nQ\nq 0 0 0 rg\n")
+	for _, r := range regions {
+		fmt.Fprintf(&out, "%s %s %s %s re f\n", fmtNum(r.X), fmtNum(r.Y), fmtNum(r.Width), fmtNum(r.Height))
+	}
+	out.WriteString("Q\n")
+	return out.Bytes(), nil
+}
+
+func (st *textState) nextLine() {
+	st.tlm = matrix{1, 0, 0, 1, 0, -st.leading}.mul(st.tlm)
+	st.tm = st.tlm
+}
+
+// hits reports whether a text run starting at the current text position
+// overlaps any region. Width is unknown, so the run is taken to extend along
+// the baseline to the edge of the page, and back behind its start by back
+// (in text space) where TJ adjustments or negative spacing move glyphs that
+// way. Its height runs from a quarter of the font size below the (risen)
+// baseline to the font size above it.
+func (st *textState) hits(regions []domain.Redaction, back float64) bool {
+	size := st.fontSize
+	if size == 0 {
+		size = 1
+	}
+	bottom, top := st.rise-0.25*size, st.rise+size
+	start, end := -back, runLength
+	if size*st.scale < 0 {
+		// Glyphs advance towards negative x
+		start, end = -runLength, back
+	}
+
+	trm := st.tm.mul(st.ctm)
+	run := [][2]float64{
+		trm.apply(start, bottom), trm.apply(end, bottom),
+		trm.apply(end, top), trm.apply(start, top),
+	}
+	for _, r := range regions {
+		box := [][2]float64{{r.X, r.Y}, {r.X + r.Width, r.Y}, {r.X + r.Width, r.Y + r.Height}, {r.X, r.Y + r.Height}}
+		if overlaps(run, box) {
+			return true
+		}
+	}
+	return false
+}
+
+// runLength is how far, in text space, a run of unknown width is assumed to
+// reach: beyond the edge of any page at any practical text matrix scale
+const runLength = 1e9
+
+// overlaps reports whether two convex quadrilaterals intersect, by looking
+// for an edge normal along which their projections are separate
+func overlaps(a, b [][2]float64) bool {
+	for _, poly := range [][][2]float64{a, b} {
+		for i := range poly {
+			p, q := poly[i], poly[(i+1)%len(poly)]
+			axis := [2]float64{q[1] - p[1], p[0] - q[0]}
+			minA, maxA := project(a, axis)
+			minB, maxB := project(b, axis)
+			if maxA < minB || maxB < minA {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func project(poly [][2]float64, axis [2]float64) (lo, hi float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, p := range poly {
+		v := p[0]*axis[0] + p[1]*axis[1]
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return lo, hi
+}
+
+// backwardAdjustment sums the positive adjustments in a TJ array, in
+// thousandths of a text space unit per unit of font size. Each moves the
+// next glyph back towards the start of the run, so together they bound how
+// far behind its start the run can reach.
+func backwardAdjustment(array []byte) float64 {
+	toks, err := tokenize(bytes.Trim(array, "[]"))
+	if err != nil {
+		return runLength
+	}
+	var sum float64
+	for _, n := range numbers(toks) {
+		if n > 0 {
+			sum += n
+		}
+	}
+	return sum
+}
+
+func fmtNum(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func numbers(operands []token) []float64 {
+	var nums []float64
+	for _, o := range operands {
+		if o.kind != tokNumber {
+			continue
+		}
+		f, err := strconv.ParseFloat(string(o.raw), 64)
+		if err == nil {
+			nums = append(nums, f)
+		}
+	}
+	return nums
+}
+
+type tokenKind int
+
+const (
+	tokNumber tokenKind = iota
+	tokOperand
+	tokOperator
+)
+
+type token struct {
+	kind tokenKind
+	raw  []byte
+}
+
+func isWhite(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// tokenize splits a content stream into operands and operators. Arrays and
+// dictionaries are kept as single operand tokens.
+func tokenize(data []byte) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case isWhite(c):
+			i++
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			end, err := scanString(data, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{tokOperand, data[i:end]})
+			i = end
+		case c == '<' && i+1 < len(data) && data[i+1] == '<', c == '[':
+			end, err := scanNested(data, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{tokOperand, data[i:end]})
+			i = end
+		case c == '<':
+			end := bytes.IndexByte(data[i:], '>')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated hex string", ErrUnsupportedPDF)
+			}
+			toks = append(toks, token{tokOperand, data[i : i+end+1]})
+			i += end + 1
+		case c == '/':
+			j := i + 1
+			for j < len(data) && !isWhite(data[j]) && !isDelim(data[j]) {
+				j++
+			}
+			toks = append(toks, token{tokOperand, data[i:j]})
+			i = j
+		case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+			return nil, fmt.Errorf("%w: unexpected %q in content stream", ErrUnsupportedPDF, c)
+		default:
+			j := i
+			for j < len(data) && !isWhite(data[j]) && !isDelim(data[j]) {
+				j++
+			}
+			raw := data[i:j]
+			kind := tokOperator
+			if _, err := strconv.ParseFloat(string(raw), 64); err == nil {
+				kind = tokNumber
+			} else if string(raw) == "true" || string(raw) == "false" || string(raw) == "null" {
+				kind = tokOperand
+			}
+			toks = append(toks, token{kind, raw})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+// scanString returns the index just past a literal string starting at data[i] == '('.
+func scanString(data []byte, i int) (int, error) {
+	depth := 0
+	for j := i; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return j + 1, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated string", ErrUnsupportedPDF)
+}
+
+// scanNested returns the index just past an array or dictionary starting at data[i].
+func scanNested(data []byte, i int) (int, error) {
+	depth := 0
+	for j := i; j < len(data); j++ {
+		switch c := data[j]; {
+		case c == '(':
+			end, err := scanString(data, j)
+			if err != nil {
+				return 0, err
+			}
+			j = end - 1
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '<' && j+1 < len(data) && data[j+1] == '<':
+			depth++
+			j++
+		case c == '>' && j+1 < len(data) && data[j+1] == '>':
+			depth--
+			j++
+		}
+		if depth == 0 {
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated array or dictionary", ErrUnsupportedPDF)
+}
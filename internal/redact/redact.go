@@ -0,0 +1,167 @@
+// Package redact produces redacted copies of case documents.
+//
+// Redaction never modifies the original bytes: every function returns a new
+// slice. Text and HTML documents are redacted by byte span; PDFs are redacted
+// by page region (see PDF).
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"ncoe/internal/domain"
+)
+
+// Marker replaces each redacted text span.
+const Marker = "[REDACTED]"
+
+// ErrUnsupportedType is returned for content types that cannot be redacted.
+var ErrUnsupportedType = errors.New("document type cannot be redacted")
+
+// KindFor returns the redaction kind used for a content type, or "" if unsupported.
+func KindFor(contentType string) domain.RedactionKind {
+	switch mediaType(contentType) {
+	case "text/plain", "text/html":
+		return domain.RedactionText
+	case "application/pdf":
+		return domain.RedactionRegion
+	}
+	return ""
+}
+
+// Apply returns a redacted copy of content according to its content type.
+func Apply(contentType string, content []byte, redactions []domain.Redaction) ([]byte, error) {
+	switch mediaType(contentType) {
+	case "text/plain":
+		return Text(content, redactions)
+	case "text/html":
+		return HTML(content, redactions)
+	case "application/pdf":
+		return PDF(content, redactions)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+}
+
+// Check reports whether content can be redacted at all, so a document that
+// cannot be is turned away before any redactions are drawn on it.
+func Check(contentType string, content []byte) error {
+	switch mediaType(contentType) {
+	case "text/plain", "text/html":
+		return nil
+	case "application/pdf":
+		return CheckPDF(content)
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+}
+
+// Text replaces each span with Marker. Overlapping spans are merged.
+func Text(content []byte, redactions []domain.Redaction) ([]byte, error) {
+	spans, err := textSpans(content, redactions)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	pos := 0
+	for _, s := range spans {
+		out.Write(content[pos:s[0]])
+		out.WriteString(Marker)
+		pos = s[1]
+	}
+	out.Write(content[pos:])
+	return out.Bytes(), nil
+}
+
+// HTML redacts spans in an HTML document. Spans must fall within text
+// content; a span that touches markup is rejected rather than risk leaving
+// the value in an attribute or breaking the document structure.
+func HTML(content []byte, redactions []domain.Redaction) ([]byte, error) {
+	spans, err := textSpans(content, redactions)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range spans {
+		if bytes.ContainsAny(content[s[0]:s[1]], "<>") {
+			return nil, fmt.Errorf("redaction [%d,%d) crosses HTML markup", s[0], s[1])
+		}
+		if insideTag(content, s[0]) {
+			return nil, fmt.Errorf("redaction [%d,%d) is inside an HTML tag", s[0], s[1])
+		}
+	}
+	return Text(content, redactions)
+}
+
+// FindText returns the byte spans of every case-insensitive occurrence of phrase.
+func FindText(content []byte, phrase string) [][2]int {
+	if phrase == "" {
+		return nil
+	}
+	haystack := bytes.ToLower(content)
+	needle := bytes.ToLower([]byte(phrase))
+	// Lowercasing can change byte lengths for some runes; fall back to exact match
+	if len(haystack) != len(content) {
+		haystack, needle = content, []byte(phrase)
+	}
+
+	var spans [][2]int
+	for offset := 0; ; {
+		i := bytes.Index(haystack[offset:], needle)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		spans = append(spans, [2]int{start, start + len(needle)})
+		offset = start + len(needle)
+	}
+	return spans
+}
+
+// textSpans validates text redactions and returns sorted, merged [start, end) spans.
+func textSpans(content []byte, redactions []domain.Redaction) ([][2]int, error) {
+	var spans [][2]int
+	for _, r := range redactions {
+		if r.Kind != domain.RedactionText {
+			return nil, fmt.Errorf("redaction %s: %s redactions do not apply to text documents", r.ID, r.Kind)
+		}
+		if r.Start < 0 || r.End > len(content) || r.Start >= r.End {
+			return nil, fmt.Errorf("redaction %s: span [%d,%d) is outside the document (%d bytes)", r.ID, r.Start, r.End, len(content))
+		}
+		if !utf8.RuneStart(content[r.Start]) || (r.End < len(content) && !utf8.RuneStart(content[r.End])) {
+			return nil, fmt.Errorf("redaction %s: span [%d,%d) splits a character", r.ID, r.Start, r.End)
+		}
+		spans = append(spans, [2]int{r.Start, r.End})
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var merged [][2]int
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s[0] <= merged[n-1][1] {
+			if s[1] > merged[n-1][1] {
+				merged[n-1][1] = s[1]
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged, nil
+}
+
+// insideTag reports whether pos falls between a '<' and its closing '>'.
+func insideTag(content []byte, pos int) bool {
+	openIdx := bytes.LastIndexByte(content[:pos], '<')
+	closeIdx := bytes.LastIndexByte(content[:pos], '>')
+	return openIdx > closeIdx
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
@@ -0,0 +1,317 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"ncoe/internal/domain"
+)
+
+func textRedaction(start, end int) domain.Redaction {
+	return domain.Redaction{ID: fmt.Sprintf("r%d", start), Kind: domain.RedactionText, Start: start, End: end}
+}
+
+func TestText(t *testing.T) {
+	content := []byte("Complainant Jane Roe of Reno")
+
+	t.Run("ReplacesSpan", func(t *testing.T) {
+		out, err := Text(content, []domain.Redaction{textRedaction(12, 20)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(out), "Complainant [REDACTED] of Reno"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("MergesOverlaps", func(t *testing.T) {
+		out, err := Text(content, []domain.Redaction{textRedaction(17, 20), textRedaction(12, 18)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(out), "Complainant [REDACTED] of Reno"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("DoesNotModifyInput", func(t *testing.T) {
+		before := string(content)
+		Text(content, []domain.Redaction{textRedaction(0, 5)})
+		if string(content) != before {
+			t.Errorf("input modified: %q", content)
+		}
+	})
+
+	t.Run("RejectsOutOfRange", func(t *testing.T) {
+		for _, r := range []domain.Redaction{textRedaction(-1, 3), textRedaction(5, 5), textRedaction(20, 99)} {
+			if _, err := Text(content, []domain.Redaction{r}); err == nil {
+				t.Errorf("span [%d,%d) should be rejected", r.Start, r.End)
+			}
+		}
+	})
+
+	t.Run("RejectsSplitRune", func(t *testing.T) {
+		if _, err := Text([]byte("café"), []domain.Redaction{textRedaction(0, 4)}); err == nil {
+			t.Error("span ending inside a multi-byte character should be rejected")
+		}
+	})
+}
+
+func TestHTML(t *testing.T) {
+	content := []byte(`<p title="Jane Roe">Filed by Jane Roe</p>`)
+
+	t.Run("RedactsText", func(t *testing.T) {
+		start := strings.LastIndex(string(content), "Jane")
+		out, err := HTML(content, []domain.Redaction{textRedaction(start, start+8)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(out), `<p title="Jane Roe">Filed by [REDACTED]</p>`; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("RejectsSpanInsideTag", func(t *testing.T) {
+		start := strings.Index(string(content), "Jane")
+		if _, err := HTML(content, []domain.Redaction{textRedaction(start, start+8)}); err == nil {
+			t.Error("span inside an attribute should be rejected")
+		}
+	})
+
+	t.Run("RejectsSpanCrossingMarkup", func(t *testing.T) {
+		start := strings.LastIndex(string(content), "Roe")
+		if _, err := HTML(content, []domain.Redaction{textRedaction(start, len(content))}); err == nil {
+			t.Error("span crossing a closing tag should be rejected")
+		}
+	})
+}
+
+func TestFindText(t *testing.T) {
+	spans := FindText([]byte("Jane Roe wrote to JANE ROE"), "jane roe")
+	if len(spans) != 2 || spans[0] != [2]int{0, 8} || spans[1] != [2]int{18, 26} {
+		t.Errorf("unexpected spans: %v", spans)
+	}
+	if FindText([]byte("anything"), "") != nil {
+		t.Error("empty phrase should match nothing")
+	}
+}
+
+func TestApplyUnsupportedType(t *testing.T) {
+	_, err := Apply("application/msword", []byte("x"), nil)
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+// buildPDF assembles a minimal uncompressed PDF whose pages use the given content streams.
+func buildPDF(pages ...string) []byte {
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+	for i, content := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R /Annots [] >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+	objects = append(objects, "<< /Title (Complaint of Jane Roe) >>")
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+	return buf.Bytes()
+}
+
+// pageContent parses a PDF and returns the decoded content of the given page.
+func pageContent(t *testing.T, pdf []byte, page int) string {
+	t.Helper()
+	doc, err := parsePDF(pdf)
+	if err != nil {
+		t.Fatalf("output does not parse: %v", err)
+	}
+	pages, err := doc.pageOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	for _, ref := range doc.contentRefs(pages[page-1]) {
+		data, err := decodeStream(doc.objects[ref])
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write(data)
+	}
+	return out.String()
+}
+
+func region(page int, x, y, w, h float64) domain.Redaction {
+	return domain.Redaction{ID: "r", Kind: domain.RedactionRegion, Page: page, X: x, Y: y, Width: w, Height: h}
+}
+
+func TestPDF(t *testing.T) {
+	input := buildPDF(
+		"BT /F1 12 Tf 72 700 Td (Complainant: Jane Roe) Tj 0 -100 Td (Public finding) Tj ET",
+		"BT /F1 12 Tf 72 700 Td (Jane Roe appears on page two) Tj ET",
+	)
+	original := string(input)
+
+	out, err := PDF(input, []domain.Redaction{region(1, 60, 690, 300, 30)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(input) != original {
+		t.Error("input modified")
+	}
+	if bytes.Contains(out, []byte("Complaint of Jane Roe")) {
+		t.Error("document info dictionary should be dropped")
+	}
+
+	first := pageContent(t, out, 1)
+	if strings.Contains(first, "Jane Roe") {
+		t.Errorf("redacted text still present on page 1: %q", first)
+	}
+	if !strings.Contains(first, "(Public finding) Tj") {
+		t.Errorf("text outside the region was removed: %q", first)
+	}
+	if !strings.Contains(first, "60 690 300 30 re f") {
+		t.Errorf("redaction box not drawn: %q", first)
+	}
+
+	// Pages without regions are left as they were
+	if second := pageContent(t, out, 2); !strings.Contains(second, "(Jane Roe appears on page two) Tj") {
+		t.Errorf("page 2 should be unchanged: %q", second)
+	}
+}
+
+func TestPDFTransformedText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		region  domain.Redaction
+	}{
+		// Text placed at (0,0) in text space, moved to (100,400) by the CTM
+		{"CTM", "q 1 0 0 1 100 400 cm BT /F1 10 Tf (Secret) Tj ET Q BT /F1 10 Tf 100 100 Td (Visible) Tj ET", region(1, 90, 390, 100, 20)},
+		// Raised 50 points above its baseline at y=400
+		{"Rise", "BT /F1 10 Tf 100 400 Td (Visible) Tj 50 Ts (Secret) Tj ET", region(1, 90, 445, 100, 20)},
+		// Running up the page from (300,100)
+		{"Rotated", "BT /F1 10 Tf 0 1 -1 0 300 100 Tm (Secret) Tj ET BT /F1 10 Tf 100 100 Td (Visible) Tj ET", region(1, 280, 400, 30, 30)},
+		// Glyph tops lean left of the run's start at x=100
+		{"Skewed", "BT /F1 10 Tf 1 0 -3 1 100 400 Tm (Secret) Tj ET BT /F1 10 Tf 100 300 Td (Visible) Tj ET", region(1, 80, 405, 10, 4)},
+		// A kerning adjustment moves the second string 150 points left
+		{"TJBackwards", "BT /F1 10 Tf 200 400 Td [(x) 15000 (Secret)] TJ ET BT /F1 10 Tf 200 300 Td (Visible) Tj ET", region(1, 90, 395, 50, 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := PDF(buildPDF(tt.content), []domain.Redaction{tt.region})
+			if err != nil {
+				t.Fatal(err)
+			}
+			content := pageContent(t, out, 1)
+			if strings.Contains(content, "Secret") || !strings.Contains(content, "Visible") {
+				t.Errorf("unexpected content: %q", content)
+			}
+		})
+	}
+}
+
+// withObjects adds entries to the catalog and appends objects after the
+// last one, numbered from 100
+func withObjects(pdf []byte, catalog string, objects ...string) []byte {
+	pdf = bytes.Replace(pdf, []byte("/Type /Catalog"), []byte("/Type /Catalog "+catalog), 1)
+	var extra bytes.Buffer
+	for i, obj := range objects {
+		fmt.Fprintf(&extra, "%d 0 obj\n%s\nendobj\n", 100+i, obj)
+	}
+	i := bytes.Index(pdf, []byte("xref\n"))
+	return append(append(append([]byte{}, pdf[:i]...), extra.Bytes()...), pdf[i:]...)
+}
+
+func TestPDFMarkedContent(t *testing.T) {
+	xmp := "<x:xmpmeta><dc:title>Complaint of Jane Roe</dc:title></x:xmpmeta>"
+	input := withObjects(
+		buildPDF("BT /F1 12 Tf 72 700 Td /Span << /MCID 0 /ActualText (Jane Roe) >> BDC (J. R.) Tj EMC /P << /MCID 1 >> BDC 0 -100 Td (Public finding) Tj EMC ET"),
+		"/StructTreeRoot 100 0 R /MarkInfo << /Marked true >> /Metadata 102 0 R",
+		"<< /Type /StructTreeRoot /K 101 0 R >>",
+		"<< /Type /StructElem /S /Span /Alt (Jane Roe) /Pg 4 0 R /K 0 >>",
+		fmt.Sprintf("<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream", len(xmp), xmp),
+	)
+
+	out, err := PDF(input, []domain.Redaction{region(1, 60, 690, 300, 30)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("Jane Roe")) {
+		t.Errorf("redacted text survives in marked content, structure or metadata:\n%s", out)
+	}
+	if bytes.Contains(out, []byte("/StructTreeRoot")) || bytes.Contains(out, []byte("/Metadata")) {
+		t.Error("structure tree or metadata kept")
+	}
+	content := pageContent(t, out, 1)
+	if !strings.Contains(content, "/Span BMC") || !strings.Contains(content, "(Public finding) Tj") {
+		t.Errorf("marked content should keep its tags and unredacted text: %q", content)
+	}
+}
+
+func TestPDFFailsClosed(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []byte
+		redactions []domain.Redaction
+	}{
+		{"ImageOnPage", buildPDF("q 100 0 0 100 0 0 cm /Im1 Do Q"), []domain.Redaction{region(1, 0, 0, 50, 50)}},
+		{"InlineImage", buildPDF("BI /W 1 /H 1 ID x EI"), []domain.Redaction{region(1, 0, 0, 50, 50)}},
+		{"Encrypted", bytes.Replace(buildPDF("BT ET"), []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt 9 0 R"), 1), []domain.Redaction{region(1, 0, 0, 50, 50)}},
+		{"PageOutOfRange", buildPDF("BT ET"), []domain.Redaction{region(2, 0, 0, 50, 50)}},
+		{"TextRedaction", buildPDF("BT ET"), []domain.Redaction{textRedaction(0, 1)}},
+		{"NotPDF", []byte("hello"), []domain.Redaction{region(1, 0, 0, 50, 50)}},
+		{"ActualTextResource", bytes.Replace(buildPDF("BT /Span /MC0 BDC EMC ET"), []byte("/Resources <<"), []byte("/Resources << /Properties << /MC0 << /ActualText (Jane Roe) >> >>"), 1), []domain.Redaction{region(1, 0, 0, 50, 50)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out, err := PDF(tt.input, tt.redactions); err == nil {
+				t.Errorf("expected error, got %d bytes", len(out))
+			}
+		})
+	}
+}
+
+func TestCheckPDF(t *testing.T) {
+	if err := CheckPDF(buildPDF("BT /F1 12 Tf 72 700 Td (Jane Roe) Tj ET", "BT ET")); err != nil {
+		t.Errorf("text-only PDF: %v", err)
+	}
+
+	tests := map[string][]byte{
+		"ImageOnSecondPage": buildPDF("BT ET", "q 100 0 0 100 0 0 cm /Im1 Do Q"),
+		"ObjectStreams":     withObjects(buildPDF("BT ET"), "", "<< /Type /ObjStm /N 0 /First 0 /Length 0 >>\nstream\n\nendstream"),
+		"Predictor":         bytes.Replace(buildPDF("BT ET"), []byte("<< /Length 5 >>"), []byte("<< /Length 5 /Filter /FlateDecode /DecodeParms << /Predictor 12 >> >>"), 1),
+	}
+	for name, input := range tests {
+		if err := CheckPDF(input); !errors.Is(err, ErrUnsupportedPDF) {
+			t.Errorf("%s: CheckPDF = %v, want ErrUnsupportedPDF", name, err)
+		}
+	}
+	if err := Check("text/html", []byte("<p>x</p>")); err != nil {
+		t.Errorf("Check(text/html) = %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ncoe/internal/domain"
+)
+
+// AcknowledgmentRepository is an in-memory store of filed ethics acknowledgments
+type AcknowledgmentRepository struct {
+	mu   sync.RWMutex
+	acks map[string]*domain.EthicsAcknowledgment
+}
+
+func NewAcknowledgmentRepository() *AcknowledgmentRepository {
+	r := &AcknowledgmentRepository{acks: make(map[string]*domain.EthicsAcknowledgment)}
+	r.seedDemoData()
+	return r
+}
+
+func (r *AcknowledgmentRepository) seedDemoData() {
+	now := time.Now()
+	termEnd1 := now.AddDate(2, 0, 0)
+	termEnd2 := now.AddDate(1, 6, 0)
+
+	demoAcks := []*domain.EthicsAcknowledgment{
+		{
+			ID:              "ack_1",
+			CaseNumber:      "EA-2024-089",
+			OfficialName:    "Maria Garcia",
+			OfficialTitle:   "Board Member",
+			Agency:          "Nevada State Board of Education",
+			AgencyType:      "state",
+			TermStartDate:   now.AddDate(-1, 0, 0),
+			TermEndDate:     &termEnd1,
+			AcknowledgedAt:  now.AddDate(0, 0, -1),
+			SignatureOnFile: true,
+			Email:           "mgarcia@doe.nv.gov",
+			IsActive:        true,
+		},
+		{
+			ID:              "ack_2",
+			CaseNumber:      "EA-2024-088",
+			OfficialName:    "James Wilson",
+			OfficialTitle:   "County Commissioner",
+			Agency:          "Clark County",
+			AgencyType:      "county",
+			TermStartDate:   now.AddDate(-2, 0, 0),
+			TermEndDate:     &termEnd2,
+			AcknowledgedAt:  now.AddDate(0, 0, -5),
+			SignatureOnFile: true,
+			Email:           "jwilson@clarkcounty.gov",
+			IsActive:        true,
+		},
+		{
+			ID:              "ack_3",
+			CaseNumber:      "EA-2024-087",
+			OfficialName:    "Patricia Chen",
+			OfficialTitle:   "City Councilwoman",
+			Agency:          "City of Las Vegas",
+			AgencyType:      "city",
+			TermStartDate:   now.AddDate(-1, 6, 0),
+			AcknowledgedAt:  now.AddDate(0, 0, -10),
+			SignatureOnFile: true,
+			Email:           "pchen@lasvegasnevada.gov",
+			IsActive:        true,
+		},
+		{
+			ID:              "ack_4",
+			CaseNumber:      "EA-2024-086",
+			OfficialName:    "Robert Thompson",
+			OfficialTitle:   "Board Trustee",
+			Agency:          "Las Vegas Valley Water District",
+			AgencyType:      "district",
+			TermStartDate:   now.AddDate(-3, 0, 0),
+			AcknowledgedAt:  now.AddDate(0, 0, -15),
+			SignatureOnFile: true,
+			Email:           "rthompson@lvvwd.com",
+			IsActive:        true,
+		},
+		{
+			ID:              "ack_5",
+			CaseNumber:      "EA-2024-085",
+			OfficialName:    "Sarah Martinez",
+			OfficialTitle:   "Director",
+			Agency:          "Nevada Department of Motor Vehicles",
+			AgencyType:      "state",
+			TermStartDate:   now.AddDate(-1, 0, 0),
+			AcknowledgedAt:  now.AddDate(0, 0, -20),
+			SignatureOnFile: true,
+			Email:           "smartinez@dmv.nv.gov",
+			IsActive:        true,
+		},
+	}
+	for _, a := range demoAcks {
+		r.acks[a.ID] = a
+	}
+}
+
+func (r *AcknowledgmentRepository) Create(a *domain.EthicsAcknowledgment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.acks[a.ID]; exists {
+		return fmt.Errorf("acknowledgment %s already exists", a.ID)
+	}
+	r.acks[a.ID] = a
+	return nil
+}
+
+func (r *AcknowledgmentRepository) GetByID(id string) *domain.EthicsAcknowledgment {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.acks[id]
+}
+
+// List returns acknowledgments matching the filters, newest first. A zero
+// year matches every year; the query matches the official, agency or case
+// number.
+func (r *AcknowledgmentRepository) List(agencyType, query string, year int) []*domain.EthicsAcknowledgment {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query = strings.ToLower(query)
+	result := []*domain.EthicsAcknowledgment{}
+	for _, a := range r.acks {
+		if agencyType != "" && a.AgencyType != agencyType {
+			continue
+		}
+		if year != 0 && a.AcknowledgedAt.Year() != year {
+			continue
+		}
+		if query != "" &&
+			!strings.Contains(strings.ToLower(a.OfficialName), query) &&
+			!strings.Contains(strings.ToLower(a.Agency), query) &&
+			!strings.Contains(strings.ToLower(a.CaseNumber), query) {
+			continue
+		}
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AcknowledgedAt.After(result[j].AcknowledgedAt)
+	})
+	return result
+}
+
+// CountFiled counts acknowledgments filed in [from, to) by agency type
+func (r *AcknowledgmentRepository) CountFiled(from, to time.Time) map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, a := range r.acks {
+		if !a.AcknowledgedAt.Before(from) && a.AcknowledgedAt.Before(to) {
+			counts[a.AgencyType]++
+		}
+	}
+	return counts
+}
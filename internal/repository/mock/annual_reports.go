@@ -0,0 +1,46 @@
+package mock
+
+import (
+	"sync"
+
+	"ncoe/internal/domain"
+)
+
+// AnnualReportRepository is an in-memory store of annual report narrative overrides
+type AnnualReportRepository struct {
+	mu         sync.RWMutex
+	narratives map[int]map[string]domain.ReportNarrative // fiscal year → section → narrative
+}
+
+func NewAnnualReportRepository() *AnnualReportRepository {
+	return &AnnualReportRepository{narratives: make(map[int]map[string]domain.ReportNarrative)}
+}
+
+// Narratives returns copies of the overrides for a fiscal year, keyed by section
+func (r *AnnualReportRepository) Narratives(fiscalYear int) map[string]*domain.ReportNarrative {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make(map[string]*domain.ReportNarrative, len(r.narratives[fiscalYear]))
+	for section, n := range r.narratives[fiscalYear] {
+		n := n
+		result[section] = &n
+	}
+	return result
+}
+
+func (r *AnnualReportRepository) SaveNarrative(n *domain.ReportNarrative) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.narratives[n.FiscalYear] == nil {
+		r.narratives[n.FiscalYear] = make(map[string]domain.ReportNarrative)
+	}
+	r.narratives[n.FiscalYear][n.Section] = *n
+	return nil
+}
+
+func (r *AnnualReportRepository) DeleteNarrative(fiscalYear int, section string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.narratives[fiscalYear], section)
+	return nil
+}
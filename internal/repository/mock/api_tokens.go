@@ -0,0 +1,85 @@
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"ncoe/internal/domain"
+)
+
+// APITokenRepository is an in-memory store of hashed API tokens
+type APITokenRepository struct {
+	mu     sync.RWMutex
+	tokens map[string]*domain.APIToken // keyed by ID
+}
+
+func NewAPITokenRepository() *APITokenRepository {
+	return &APITokenRepository{tokens: make(map[string]*domain.APIToken)}
+}
+
+func (r *APITokenRepository) Create(t *domain.APIToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tokens[t.ID]; exists {
+		return fmt.Errorf("api token already exists: %s", t.ID)
+	}
+	stored := *t
+	r.tokens[t.ID] = &stored
+	return nil
+}
+
+func (r *APITokenRepository) Update(t *domain.APIToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tokens[t.ID]; !exists {
+		return fmt.Errorf("api token not found: %s", t.ID)
+	}
+	stored := *t
+	r.tokens[t.ID] = &stored
+	return nil
+}
+
+// GetByID returns a copy of the token, or nil
+func (r *APITokenRepository) GetByID(id string) *domain.APIToken {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if t, ok := r.tokens[id]; ok {
+		copied := *t
+		return &copied
+	}
+	return nil
+}
+
+// GetByHash returns a copy of the token with the given secret hash, or nil
+func (r *APITokenRepository) GetByHash(hash string) *domain.APIToken {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, t := range r.tokens {
+		if t.Hash == hash {
+			copied := *t
+			return &copied
+		}
+	}
+	return nil
+}
+
+// ListByUser returns a user's tokens, newest first
+func (r *APITokenRepository) ListByUser(userID string) []domain.APIToken {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []domain.APIToken
+	for _, t := range r.tokens {
+		if t.UserID == userID {
+			result = append(result, *t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
@@ -0,0 +1,39 @@
+package mock
+
+import (
+	"sync"
+
+	"ncoe/internal/domain"
+)
+
+// AuditRepository is an in-memory, append-only audit log
+type AuditRepository struct {
+	mu      sync.RWMutex
+	entries []domain.AuditEntry
+}
+
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+func (r *AuditRepository) Create(e *domain.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, *e)
+	return nil
+}
+
+// List returns entries newest first, optionally only those about one target
+func (r *AuditRepository) List(targetType, targetID string) []domain.AuditEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []domain.AuditEntry
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		e := r.entries[i]
+		if targetType != "" && (e.TargetType != targetType || e.TargetID != targetID) {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"ncoe/internal/domain"
+)
+
+// MFARepository is an in-memory store of TOTP enrollments and the MFA policy
+type MFARepository struct {
+	mu          sync.RWMutex
+	enrollments map[string]*domain.MFAEnrollment // keyed by user ID
+	policy      *domain.MFAPolicy
+}
+
+func NewMFARepository() *MFARepository {
+	return &MFARepository{enrollments: make(map[string]*domain.MFAEnrollment)}
+}
+
+// Get returns a copy of the user's enrollment, or nil
+func (r *MFARepository) Get(userID string) *domain.MFAEnrollment {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.enrollments[userID]; ok {
+		return copyEnrollment(e)
+	}
+	return nil
+}
+
+// Save creates or replaces the user's enrollment
+func (r *MFARepository) Save(e *domain.MFAEnrollment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enrollments[e.UserID] = copyEnrollment(e)
+	return nil
+}
+
+func (r *MFARepository) Delete(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.enrollments[userID]; !ok {
+		return fmt.Errorf("mfa enrollment not found: %s", userID)
+	}
+	delete(r.enrollments, userID)
+	return nil
+}
+
+// List returns copies of all enrollments, oldest first
+func (r *MFARepository) List() []*domain.MFAEnrollment {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]*domain.MFAEnrollment, 0, len(r.enrollments))
+	for _, e := range r.enrollments {
+		result = append(result, copyEnrollment(e))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// GetPolicy returns the saved policy, or nil if none has been saved
+func (r *MFARepository) GetPolicy() *domain.MFAPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.policy == nil {
+		return nil
+	}
+	p := *r.policy
+	p.RequiredRoles = append([]domain.Role(nil), r.policy.RequiredRoles...)
+	return &p
+}
+
+func (r *MFARepository) SavePolicy(p *domain.MFAPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *p
+	stored.RequiredRoles = append([]domain.Role(nil), p.RequiredRoles...)
+	r.policy = &stored
+	return nil
+}
+
+func copyEnrollment(e *domain.MFAEnrollment) *domain.MFAEnrollment {
+	c := *e
+	c.RecoveryCodes = append([]string(nil), e.RecoveryCodes...)
+	return &c
+}
@@ -0,0 +1,163 @@
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/statute"
+)
+
+// OpinionRepository is an in-memory store of published opinions and orders
+type OpinionRepository struct {
+	mu       sync.RWMutex
+	opinions map[string]*domain.PublishedOpinion // keyed by case number
+}
+
+func NewOpinionRepository() *OpinionRepository {
+	r := &OpinionRepository{opinions: make(map[string]*domain.PublishedOpinion)}
+	r.seedDemoData()
+	return r
+}
+
+func (r *OpinionRepository) seedDemoData() {
+	now := time.Now()
+
+	// Historical opinions published before the demo case data
+	demoOpinions := []*domain.PublishedOpinion{
+		{
+			ID:          "op_1",
+			CaseNumber:  "AO-2024-010",
+			Type:        domain.CaseTypeAdvisoryOpinion,
+			Title:       "Advisory Opinion: Contractor Relationships",
+			Summary:     "A public officer may not use their position to secure unwarranted privileges for a family member's business.",
+			Topics:      []string{"Conflicts of Interest", "Family Members"},
+			Statutes:    []string{"NRS 281A.400(2)", "NRS 281A.420(1)"},
+			PublishedAt: now.AddDate(0, -1, 0),
+			Year:        2024,
+		},
+		{
+			ID:          "op_2",
+			CaseNumber:  "EC-2024-005",
+			Type:        domain.CaseTypeEthicsComplaint,
+			Title:       "Final Order: Gift Violations",
+			Summary:     "The Commission finds a willful violation of the Ethics in Government Law occurred when the subject accepted gifts exceeding $50.",
+			Topics:      []string{"Gifts", "NRS 281A.400"},
+			Statutes:    []string{"NRS 281A.400(1)", "NRS 281A.480"},
+			PublishedAt: now.AddDate(0, -2, 0),
+			Year:        2024,
+		},
+	}
+
+	for _, o := range demoOpinions {
+		o.Citations = statute.ParseAll(o.Statutes...)
+		r.opinions[o.CaseNumber] = o
+	}
+}
+
+func (r *OpinionRepository) Create(o *domain.PublishedOpinion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.opinions[o.CaseNumber]; exists {
+		return fmt.Errorf("opinion already published: %s", o.CaseNumber)
+	}
+	r.opinions[o.CaseNumber] = o
+	return nil
+}
+
+func (r *OpinionRepository) Delete(caseNumber string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.opinions[caseNumber]; !exists {
+		return fmt.Errorf("opinion not found: %s", caseNumber)
+	}
+	delete(r.opinions, caseNumber)
+	return nil
+}
+
+func (r *OpinionRepository) GetByCaseNumber(caseNumber string) *domain.PublishedOpinion {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.opinions[caseNumber]
+}
+
+// Search returns published opinions matching all non-empty filters, newest first
+func (r *OpinionRepository) Search(query, docType, year, topic string) []domain.PublishedOpinion {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	queryLower := strings.ToLower(query)
+	var result []domain.PublishedOpinion
+	for _, o := range r.opinions {
+		if docType != "" && string(o.Type) != docType {
+			continue
+		}
+		if year != "" && strconv.Itoa(o.Year) != year {
+			continue
+		}
+		if topic != "" && !containsFold(o.Topics, topic) {
+			continue
+		}
+		if query != "" {
+			text := strings.ToLower(o.CaseNumber + " " + o.Title + " " + o.Summary + " " +
+				strings.Join(o.Topics, " ") + " " + strings.Join(o.Statutes, " "))
+			if !strings.Contains(text, queryLower) {
+				continue
+			}
+		}
+		result = append(result, *o)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PublishedAt.After(result[j].PublishedAt)
+	})
+	return result
+}
+
+// ListByStatute returns published opinions citing a section (e.g. "281A.400"), newest first
+func (r *OpinionRepository) ListByStatute(sectionKey string) []domain.PublishedOpinion {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []domain.PublishedOpinion
+	for _, o := range r.opinions {
+		if domain.CitesSection(o.Citations, sectionKey) {
+			result = append(result, *o)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PublishedAt.After(result[j].PublishedAt)
+	})
+	return result
+}
+
+// StatuteCounts returns the number of published opinions citing each section
+func (r *OpinionRepository) StatuteCounts() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, o := range r.opinions {
+		seen := map[string]bool{}
+		for _, c := range o.Citations {
+			if key := c.SectionKey(); !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+	return counts
+}
+
+func containsFold(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
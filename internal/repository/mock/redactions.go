@@ -0,0 +1,99 @@
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"ncoe/internal/domain"
+)
+
+// RedactionRepository is an in-memory store of pending redactions and redaction logs
+type RedactionRepository struct {
+	mu         sync.RWMutex
+	redactions map[string]*domain.Redaction
+	logs       []*domain.RedactionLog
+}
+
+func NewRedactionRepository() *RedactionRepository {
+	return &RedactionRepository{redactions: make(map[string]*domain.Redaction)}
+}
+
+// ListByDocument returns the redactions defined on a document, oldest first
+func (r *RedactionRepository) ListByDocument(documentID string) []domain.Redaction {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []domain.Redaction
+	for _, red := range r.redactions {
+		if red.DocumentID == documentID {
+			result = append(result, *red)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+func (r *RedactionRepository) Get(id string) *domain.Redaction {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.redactions[id]
+}
+
+func (r *RedactionRepository) Create(red *domain.Redaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.redactions[red.ID]; exists {
+		return fmt.Errorf("redaction already exists: %s", red.ID)
+	}
+	r.redactions[red.ID] = red
+	return nil
+}
+
+func (r *RedactionRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.redactions[id]; !exists {
+		return fmt.Errorf("redaction not found: %s", id)
+	}
+	delete(r.redactions, id)
+	return nil
+}
+
+func (r *RedactionRepository) CreateLog(l *domain.RedactionLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, l)
+	return nil
+}
+
+// ListLogs returns redaction logs for a case, newest first
+func (r *RedactionRepository) ListLogs(caseID string) []domain.RedactionLog {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []domain.RedactionLog
+	for i := len(r.logs) - 1; i >= 0; i-- {
+		if r.logs[i].CaseID == caseID {
+			result = append(result, *r.logs[i])
+		}
+	}
+	return result
+}
+
+// GetLogByDerived returns the log entry that produced a redacted derivative, or nil
+func (r *RedactionRepository) GetLogByDerived(documentID string) *domain.RedactionLog {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, l := range r.logs {
+		if l.DerivedDocumentID == documentID {
+			return l
+		}
+	}
+	return nil
+}
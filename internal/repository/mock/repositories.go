@@ -2,24 +2,44 @@ package mock
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"ncoe/internal/domain"
+	"ncoe/internal/statute"
 )
 
 type Repositories struct {
-	User    *UserRepository
-	Session *SessionRepository
-	Case    *CaseRepository
+	User           *UserRepository
+	Session        *SessionRepository
+	Case           *CaseRepository
+	Opinion        *OpinionRepository
+	Redaction      *RedactionRepository
+	APIToken       *APITokenRepository
+	Webhook        *WebhookRepository
+	MFA            *MFARepository
+	Audit          *AuditRepository
+	Acknowledgment *AcknowledgmentRepository
+	AnnualReport   *AnnualReportRepository
+	HeldSubmission *HeldSubmissionRepository
 }
 
 func NewRepositories() *Repositories {
 	return &Repositories{
-		User:    NewUserRepository(),
-		Session: NewSessionRepository(),
-		Case:    NewCaseRepository(),
+		User:           NewUserRepository(),
+		Session:        NewSessionRepository(),
+		Case:           NewCaseRepository(),
+		Opinion:        NewOpinionRepository(),
+		Redaction:      NewRedactionRepository(),
+		APIToken:       NewAPITokenRepository(),
+		Webhook:        NewWebhookRepository(),
+		MFA:            NewMFARepository(),
+		Audit:          NewAuditRepository(),
+		Acknowledgment: NewAcknowledgmentRepository(),
+		AnnualReport:   NewAnnualReportRepository(),
+		HeldSubmission: NewHeldSubmissionRepository(),
 	}
 }
 
@@ -44,29 +64,94 @@ func NewUserRepository() *UserRepository {
 	return r
 }
 
+// GetByEmail returns a copy of the user with the email, or nil
 func (r *UserRepository) GetByEmail(email string) *domain.User {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.users[email]
+	if u, ok := r.users[email]; ok {
+		c := *u
+		return &c
+	}
+	return nil
 }
 
+// GetByID returns a copy of the user, or nil
 func (r *UserRepository) GetByID(id string) *domain.User {
+	return r.find(func(u *domain.User) bool { return u.ID == id })
+}
+
+func (r *UserRepository) GetByExternalID(externalID string) *domain.User {
+	return r.find(func(u *domain.User) bool { return u.ExternalID == externalID })
+}
+
+// GetByPasswordToken returns the user with an outstanding invitation or
+// reset link whose token hashes to hash, or nil
+func (r *UserRepository) GetByPasswordToken(hash string) *domain.User {
+	return r.find(func(u *domain.User) bool { return u.PasswordTokenHash != "" && u.PasswordTokenHash == hash })
+}
+
+// List returns copies of all users, by last then first name
+func (r *UserRepository) List() []*domain.User {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]*domain.User, 0, len(r.users))
+	for _, u := range r.users {
+		c := *u
+		result = append(result, &c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.LastName != b.LastName {
+			return a.LastName < b.LastName
+		}
+		if a.FirstName != b.FirstName {
+			return a.FirstName < b.FirstName
+		}
+		return a.Email < b.Email
+	})
+	return result
+}
+
+func (r *UserRepository) find(match func(*domain.User) bool) *domain.User {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	for _, u := range r.users {
-		if u.ID == id {
-			return u
+		if match(u) {
+			c := *u
+			return &c
 		}
 	}
-	// Demo mode: return mock user
-	return &domain.User{
-		ID:        id,
-		Email:     "demo@ncoe.nv.gov",
-		FirstName: "Demo",
-		LastName:  "User",
-		Role:      domain.RoleAdmin,
-		IsActive:  true,
+	return nil
+}
+
+func (r *UserRepository) Create(u *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.users[u.Email]; exists {
+		return fmt.Errorf("user already exists: %s", u.Email)
+	}
+	for _, existing := range r.users {
+		if existing.ID == u.ID {
+			return fmt.Errorf("user already exists: %s", u.ID)
+		}
+	}
+	c := *u
+	r.users[u.Email] = &c
+	return nil
+}
+
+func (r *UserRepository) Update(u *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for email, existing := range r.users {
+		if existing.ID == u.ID {
+			c := *u
+			delete(r.users, email)
+			r.users[u.Email] = &c
+			return nil
+		}
 	}
+	return fmt.Errorf("user not found: %s", u.ID)
 }
 
 // SessionRepository is an in-memory session store
@@ -82,14 +167,31 @@ func NewSessionRepository() *SessionRepository {
 func (r *SessionRepository) Create(s *domain.Session) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.sessions[s.Token] = s
+	stored := *s
+	r.sessions[s.Token] = &stored
 	return nil
 }
 
+// GetByToken returns a copy of the session, or nil
 func (r *SessionRepository) GetByToken(token string) *domain.Session {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.sessions[token]
+	if s, ok := r.sessions[token]; ok {
+		copied := *s
+		return &copied
+	}
+	return nil
+}
+
+func (r *SessionRepository) Update(s *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.sessions[s.Token]; !ok {
+		return fmt.Errorf("session not found: %s", s.ID)
+	}
+	stored := *s
+	r.sessions[s.Token] = &stored
+	return nil
 }
 
 func (r *SessionRepository) Delete(token string) error {
@@ -99,17 +201,61 @@ func (r *SessionRepository) Delete(token string) error {
 	return nil
 }
 
+// List returns copies of all sessions, most recently active first
+func (r *SessionRepository) List() []*domain.Session {
+	return r.filter(func(*domain.Session) bool { return true })
+}
+
+// ListByUser returns copies of the user's sessions, most recently active first
+func (r *SessionRepository) ListByUser(userID string) []*domain.Session {
+	return r.filter(func(s *domain.Session) bool { return s.UserID == userID })
+}
+
+// DeleteExpired removes sessions past their absolute or idle limit at now
+// and returns how many were removed
+func (r *SessionRepository) DeleteExpired(now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	removed := 0
+	for token, s := range r.sessions {
+		if s.ExpiredAt(now) {
+			delete(r.sessions, token)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (r *SessionRepository) filter(match func(*domain.Session) bool) []*domain.Session {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*domain.Session
+	for _, s := range r.sessions {
+		if match(s) {
+			copied := *s
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastSeenAt.After(result[j].LastSeenAt)
+	})
+	return result
+}
+
 // CaseRepository is an in-memory case store
 type CaseRepository struct {
-	mu       sync.RWMutex
-	cases    map[string]*domain.Case
-	counters map[domain.CaseType]int
+	mu         sync.RWMutex
+	cases      map[string]*domain.Case
+	documents  map[string]*domain.Document
+	activities []*domain.CaseActivity // In the order recorded
+	counters   map[domain.CaseType]int
 }
 
 func NewCaseRepository() *CaseRepository {
 	r := &CaseRepository{
-		cases:    make(map[string]*domain.Case),
-		counters: make(map[domain.CaseType]int),
+		cases:     make(map[string]*domain.Case),
+		documents: make(map[string]*domain.Document),
+		counters:  make(map[domain.CaseType]int),
 	}
 	r.seedDemoData()
 	return r
@@ -123,39 +269,41 @@ func (r *CaseRepository) seedDemoData() {
 	demoCases := []*domain.Case{
 		// Recent submissions shown on dashboard
 		{
-			ID:              "1",
-			CaseNumber:      "AO-2024-042",
-			Type:            domain.CaseTypeAdvisoryOpinion,
-			Status:          domain.StatusSubmitted,
-			SubmitterName:   "John Smith",
-			SubmitterTitle:  "City Manager",
-			SubmitterAgency: "City of Henderson",
-			SubmitterEmail:  "jsmith@henderson.gov",
-			Summary:         "Question regarding contractor relationships",
-			Description:     "May I participate in discussions regarding a contract with a company where my brother-in-law is employed?",
-			SubmittedAt:     now.AddDate(0, 0, -1),
-			DueDate:         now.AddDate(0, 0, 3), // Upcoming deadline
-			AssignedTo:      "user_1",
-			AssignedToName:  "Ross Armstrong",
-			Priority:        "normal",
+			ID:               "1",
+			CaseNumber:       "AO-2024-042",
+			Type:             domain.CaseTypeAdvisoryOpinion,
+			Status:           domain.StatusSubmitted,
+			SubmitterName:    "John Smith",
+			SubmitterTitle:   "City Manager",
+			SubmitterAgency:  "City of Henderson",
+			SubmitterEmail:   "jsmith@henderson.gov",
+			Summary:          "Question regarding contractor relationships",
+			Description:      "May I participate in discussions regarding a contract with a company where my brother-in-law is employed?",
+			StatuteCitations: "NRS 281A.420(1)",
+			SubmittedAt:      now.AddDate(0, 0, -1),
+			DueDate:          now.AddDate(0, 0, 3), // Upcoming deadline
+			AssignedTo:       "user_1",
+			AssignedToName:   "Ross Armstrong",
+			Priority:         "normal",
 		},
 		{
-			ID:              "2",
-			CaseNumber:      "EC-2024-018",
-			Type:            domain.CaseTypeEthicsComplaint,
-			Status:          domain.StatusUnderReview,
-			SubmitterName:   "Jane Doe",
-			SubmitterEmail:  "concerned@example.com",
-			SubjectName:     "Robert Johnson",
-			SubjectTitle:    "County Commissioner",
-			SubjectAgency:   "Clark County",
-			Summary:         "Alleged gift violation",
-			Description:     "Commissioner Johnson allegedly accepted tickets to a Las Vegas show from a vendor seeking county contracts.",
-			SubmittedAt:     now.AddDate(0, 0, -2),
-			DueDate:         now.AddDate(0, 0, 5), // Investigation deadline
-			AssignedTo:      "user_1",
-			AssignedToName:  "Ross Armstrong",
-			Priority:        "high",
+			ID:               "2",
+			CaseNumber:       "EC-2024-018",
+			Type:             domain.CaseTypeEthicsComplaint,
+			Status:           domain.StatusUnderReview,
+			SubmitterName:    "Jane Doe",
+			SubmitterEmail:   "concerned@example.com",
+			SubjectName:      "Robert Johnson",
+			SubjectTitle:     "County Commissioner",
+			SubjectAgency:    "Clark County",
+			Summary:          "Alleged gift violation",
+			Description:      "Commissioner Johnson allegedly accepted tickets to a Las Vegas show from a vendor seeking county contracts.",
+			StatuteCitations: "NRS 281A.400(1)",
+			SubmittedAt:      now.AddDate(0, 0, -2),
+			DueDate:          now.AddDate(0, 0, 5), // Investigation deadline
+			AssignedTo:       "user_1",
+			AssignedToName:   "Ross Armstrong",
+			Priority:         "high",
 		},
 		{
 			ID:              "3",
@@ -184,21 +332,22 @@ func (r *CaseRepository) seedDemoData() {
 			Priority:        "normal",
 		},
 		{
-			ID:              "5",
-			CaseNumber:      "AO-2024-041",
-			Type:            domain.CaseTypeAdvisoryOpinion,
-			Status:          domain.StatusInvestigation,
-			SubmitterName:   "Maria Garcia",
-			SubmitterTitle:  "State Employee",
-			SubmitterAgency: "Department of Motor Vehicles",
-			SubmitterEmail:  "mgarcia@dmv.nv.gov",
-			Summary:         "Outside employment with DMV vendor",
-			Description:     "I have been offered a weekend consulting position with an IT firm that has contracts with DMV. Is this permissible?",
-			SubmittedAt:     now.AddDate(0, 0, -5),
-			DueDate:         now.AddDate(0, 0, 40),
-			AssignedTo:      "user_1",
-			AssignedToName:  "Ross Armstrong",
-			Priority:        "normal",
+			ID:               "5",
+			CaseNumber:       "AO-2024-041",
+			Type:             domain.CaseTypeAdvisoryOpinion,
+			Status:           domain.StatusInvestigation,
+			SubmitterName:    "Maria Garcia",
+			SubmitterTitle:   "State Employee",
+			SubmitterAgency:  "Department of Motor Vehicles",
+			SubmitterEmail:   "mgarcia@dmv.nv.gov",
+			Summary:          "Outside employment with DMV vendor",
+			Description:      "I have been offered a weekend consulting position with an IT firm that has contracts with DMV. Is this permissible?",
+			StatuteCitations: "NRS 281A.400(1), NRS 281A.430",
+			SubmittedAt:      now.AddDate(0, 0, -5),
+			DueDate:          now.AddDate(0, 0, 40),
+			AssignedTo:       "user_1",
+			AssignedToName:   "Ross Armstrong",
+			Priority:         "normal",
 		},
 		// Additional cases for deadlines display
 		{
@@ -217,40 +366,42 @@ func (r *CaseRepository) seedDemoData() {
 			Priority:        "high",
 		},
 		{
-			ID:              "7",
-			CaseNumber:      "AO-2024-039",
-			Type:            domain.CaseTypeAdvisoryOpinion,
-			Status:          domain.StatusUnderReview,
-			SubmitterName:   "David Chen",
-			SubmitterTitle:  "Director",
-			SubmitterAgency: "Department of Transportation",
-			SubmitterEmail:  "dchen@dot.nv.gov",
-			Summary:         "Family member employment at vendor",
-			Description:     "My daughter has been offered employment at a firm that frequently bids on NDOT contracts. What are my obligations?",
-			SubmittedAt:     now.AddDate(0, 0, -14),
-			DueDate:         now.AddDate(0, 0, 7), // Hearing scheduled
-			AssignedTo:      "user_1",
-			AssignedToName:  "Ross Armstrong",
-			Priority:        "normal",
+			ID:               "7",
+			CaseNumber:       "AO-2024-039",
+			Type:             domain.CaseTypeAdvisoryOpinion,
+			Status:           domain.StatusUnderReview,
+			SubmitterName:    "David Chen",
+			SubmitterTitle:   "Director",
+			SubmitterAgency:  "Department of Transportation",
+			SubmitterEmail:   "dchen@dot.nv.gov",
+			Summary:          "Family member employment at vendor",
+			Description:      "My daughter has been offered employment at a firm that frequently bids on NDOT contracts. What are my obligations?",
+			StatuteCitations: "NRS 281A.400(2), NRS 281A.420",
+			SubmittedAt:      now.AddDate(0, 0, -14),
+			DueDate:          now.AddDate(0, 0, 7), // Hearing scheduled
+			AssignedTo:       "user_1",
+			AssignedToName:   "Ross Armstrong",
+			Priority:         "normal",
 		},
 		// More cases for realistic case list
 		{
-			ID:              "8",
-			CaseNumber:      "EC-2024-017",
-			Type:            domain.CaseTypeEthicsComplaint,
-			Status:          domain.StatusDraftPrepared,
-			SubmitterName:   "Anonymous",
-			SubmitterEmail:  "anonymous@protonmail.com",
-			SubjectName:     "Lisa Wong",
-			SubjectTitle:    "City Councilwoman",
-			SubjectAgency:   "City of Reno",
-			Summary:         "Misuse of public resources",
-			Description:     "Councilwoman Wong allegedly used city staff to plan her daughter's wedding.",
-			SubmittedAt:     now.AddDate(0, 0, -21),
-			DueDate:         now.AddDate(0, 0, 14),
-			AssignedTo:      "user_1",
-			AssignedToName:  "Ross Armstrong",
-			Priority:        "high",
+			ID:               "8",
+			CaseNumber:       "EC-2024-017",
+			Type:             domain.CaseTypeEthicsComplaint,
+			Status:           domain.StatusDraftPrepared,
+			SubmitterName:    "Anonymous",
+			SubmitterEmail:   "anonymous@protonmail.com",
+			SubjectName:      "Lisa Wong",
+			SubjectTitle:     "City Councilwoman",
+			SubjectAgency:    "City of Reno",
+			Summary:          "Misuse of public resources",
+			Description:      "Councilwoman Wong allegedly used city staff to plan her daughter's wedding.",
+			StatuteCitations: "NRS 281A.400(2), NRS 281A.400(7)",
+			SubmittedAt:      now.AddDate(0, 0, -21),
+			DueDate:          now.AddDate(0, 0, 14),
+			AssignedTo:       "user_1",
+			AssignedToName:   "Ross Armstrong",
+			Priority:         "high",
 		},
 		{
 			ID:              "9",
@@ -265,6 +416,7 @@ func (r *CaseRepository) seedDemoData() {
 			Description:     "May I serve on the board of a charitable organization that occasionally applies for county grants?",
 			SubmittedAt:     now.AddDate(0, -1, 0),
 			DueDate:         now.AddDate(0, 0, -15),
+			ClosedAt:        timePtr(now.AddDate(0, 0, -16)),
 			AssignedTo:      "user_1",
 			AssignedToName:  "Ross Armstrong",
 			Priority:        "normal",
@@ -280,14 +432,43 @@ func (r *CaseRepository) seedDemoData() {
 			SubmitterEmail:  "smiller@puc.nv.gov",
 			Summary:         "Annual Ethics Acknowledgment",
 			SubmittedAt:     now.AddDate(0, 0, -10),
+			ClosedAt:        timePtr(now.AddDate(0, 0, -8)),
 			Priority:        "normal",
 		},
 	}
 
 	for _, c := range demoCases {
+		c.Citations = statute.Parse(c.StatuteCitations)
 		r.cases[c.ID] = c
 	}
 
+	// Each demo case's timeline starts with its submission
+	for _, c := range demoCases {
+		r.activities = append(r.activities, &domain.CaseActivity{
+			ID:          "act_" + c.ID,
+			CaseID:      c.ID,
+			CaseNumber:  c.CaseNumber,
+			Action:      "created",
+			Description: "Case created from public submission",
+			CreatedAt:   c.SubmittedAt,
+		})
+		if c.ClosedAt != nil {
+			r.activities = append(r.activities, &domain.CaseActivity{
+				ID:          "act_" + c.ID + "_closed",
+				CaseID:      c.ID,
+				CaseNumber:  c.CaseNumber,
+				Action:      "status_changed",
+				Description: "Status changed to closed",
+				OldValue:    string(domain.StatusUnderReview),
+				NewValue:    string(domain.StatusClosed),
+				CreatedAt:   *c.ClosedAt,
+			})
+		}
+	}
+	sort.Slice(r.activities, func(i, j int) bool {
+		return r.activities[i].CreatedAt.Before(r.activities[j].CreatedAt)
+	})
+
 	// Set counters to continue numbering correctly
 	r.counters[domain.CaseTypeAdvisoryOpinion] = 42
 	r.counters[domain.CaseTypeEthicsComplaint] = 18
@@ -349,38 +530,159 @@ func (r *CaseRepository) List(typeFilter, statusFilter, query string) []*domain.
 	return result
 }
 
+// GetRecent returns the most recently submitted cases, newest first
 func (r *CaseRepository) GetRecent(limit int) []*domain.Case {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	var result []*domain.Case
+	result := make([]*domain.Case, 0, len(r.cases))
 	for _, c := range r.cases {
 		result = append(result, c)
-		if len(result) >= limit {
-			break
-		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SubmittedAt.After(result[j].SubmittedAt)
+	})
+	if len(result) > limit {
+		result = result[:limit]
 	}
 	return result
 }
 
+// CountCases counts cases by type and status, and open cases past due
+func (r *CaseRepository) CountCases() domain.CaseCounts {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := domain.CaseCounts{
+		ByType:       make(map[domain.CaseType]int),
+		ByStatus:     make(map[domain.CaseStatus]int),
+		ByTypeStatus: make(map[domain.CaseType]map[domain.CaseStatus]int),
+	}
+	for _, c := range r.cases {
+		counts.ByType[c.Type]++
+		counts.ByStatus[c.Status]++
+		if counts.ByTypeStatus[c.Type] == nil {
+			counts.ByTypeStatus[c.Type] = make(map[domain.CaseStatus]int)
+		}
+		counts.ByTypeStatus[c.Type][c.Status]++
+		if c.IsOverdue() {
+			counts.Overdue++
+		}
+	}
+	return counts
+}
+
+// CountSubmitted counts cases submitted in [from, to)
+func (r *CaseRepository) CountSubmitted(from, to time.Time) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, c := range r.cases {
+		if !c.SubmittedAt.Before(from) && c.SubmittedAt.Before(to) {
+			count++
+		}
+	}
+	return count
+}
+
+// CountResolved counts cases published, closed or withdrawn in [from, to)
+func (r *CaseRepository) CountResolved(from, to time.Time) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, c := range r.cases {
+		if at := c.ResolvedAt(); at != nil && !at.Before(from) && at.Before(to) {
+			count++
+		}
+	}
+	return count
+}
+
 func (r *CaseRepository) GetDocuments(caseID string) []*domain.Document {
-	return []*domain.Document{} // Demo: no documents
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := []*domain.Document{}
+	for _, d := range r.documents {
+		if d.CaseID == caseID {
+			result = append(result, d)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UploadedAt.Before(result[j].UploadedAt)
+	})
+	return result
+}
+
+func (r *CaseRepository) GetDocument(id string) *domain.Document {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.documents[id]
+}
+
+func (r *CaseRepository) AddDocument(d *domain.Document) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.cases[d.CaseID]; !exists {
+		return fmt.Errorf("case not found: %s", d.CaseID)
+	}
+	r.documents[d.ID] = d
+	return nil
+}
+
+func (r *CaseRepository) UpdateDocument(d *domain.Document) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.documents[d.ID]; !exists {
+		return fmt.Errorf("document not found: %s", d.ID)
+	}
+	r.documents[d.ID] = d
+	return nil
 }
 
 func (r *CaseRepository) GetNotes(caseID string) []*domain.CaseNote {
 	return []*domain.CaseNote{} // Demo: no notes
 }
 
+// GetActivity returns the case's timeline, newest first
 func (r *CaseRepository) GetActivity(caseID string) []*domain.CaseActivity {
-	return []*domain.CaseActivity{
-		{
-			ID:          "act_1",
-			CaseID:      caseID,
-			Action:      "created",
-			Description: "Case created from public submission",
-			CreatedAt:   time.Now().AddDate(0, 0, -5),
-		},
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := []*domain.CaseActivity{}
+	for i := len(r.activities) - 1; i >= 0; i-- {
+		if a := r.activities[i]; a.CaseID == caseID {
+			c := *a
+			result = append(result, &c)
+		}
+	}
+	return result
+}
+
+func (r *CaseRepository) AddActivity(a *domain.CaseActivity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.cases[a.CaseID]; !exists {
+		return fmt.Errorf("case not found: %s", a.CaseID)
 	}
+	c := *a
+	r.activities = append(r.activities, &c)
+	return nil
+}
+
+// RecentActivity returns the latest timeline entries across all cases, newest first
+func (r *CaseRepository) RecentActivity(limit int) []*domain.CaseActivity {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []*domain.CaseActivity
+	for i := len(r.activities) - 1; i >= 0 && len(result) < limit; i-- {
+		c := *r.activities[i]
+		result = append(result, &c)
+	}
+	return result
 }
 
 func (r *CaseRepository) GetDeadlines(limit int) []*domain.Deadline {
@@ -407,9 +709,12 @@ func (r *CaseRepository) GetDeadlines(limit int) []*domain.Deadline {
 				Status:     status,
 			})
 		}
-		if len(deadlines) >= limit {
-			break
-		}
+	}
+	sort.Slice(deadlines, func(i, j int) bool {
+		return deadlines[i].DueDate.Before(deadlines[j].DueDate)
+	})
+	if len(deadlines) > limit {
+		deadlines = deadlines[:limit]
 	}
 	return deadlines
 }
@@ -418,50 +723,15 @@ func (r *CaseRepository) GetAllDeadlines() []*domain.Deadline {
 	return r.GetDeadlines(100)
 }
 
-func (r *CaseRepository) SearchPublished(query, docType, year, topic string) []domain.PublishedOpinion {
-	// Demo: return sample published opinions
-	return []domain.PublishedOpinion{
-		{
-			CaseNumber:  "AO-2024-010",
-			Type:        domain.CaseTypeAdvisoryOpinion,
-			Title:       "Advisory Opinion: Contractor Relationships",
-			Summary:     "A public officer may not use their position to secure unwarranted privileges for a family member's business.",
-			Topics:      []string{"Conflicts of Interest", "Family Members"},
-			Statutes:    []string{"NRS 281A.400"},
-			PublishedAt: time.Now().AddDate(0, -1, 0),
-			Year:        2024,
-		},
-		{
-			CaseNumber:  "EC-2024-005",
-			Type:        domain.CaseTypeEthicsComplaint,
-			Title:       "Final Order: Gift Violations",
-			Summary:     "The Commission finds a willful violation of the Ethics in Government Law occurred when the subject accepted gifts exceeding $50.",
-			Topics:      []string{"Gifts", "NRS 281A.400"},
-			Statutes:    []string{"NRS 281A.400", "NRS 281A.480"},
-			PublishedAt: time.Now().AddDate(0, -2, 0),
-			Year:        2024,
-		},
-	}
-}
-
-func (r *CaseRepository) GetPublishedOpinion(caseNumber string) *domain.PublishedOpinion {
-	// Demo: return sample
-	return &domain.PublishedOpinion{
-		CaseNumber:  caseNumber,
-		Type:        domain.CaseTypeAdvisoryOpinion,
-		Title:       "Advisory Opinion: " + caseNumber,
-		Summary:     "Sample published opinion text.",
-		Topics:      []string{"Conflicts of Interest"},
-		PublishedAt: time.Now().AddDate(0, -1, 0),
-		Year:        2024,
-	}
-}
-
-func (r *CaseRepository) NextCaseNumber(caseType domain.CaseType) string {
+func (r *CaseRepository) NextCaseNumber(caseType domain.CaseType, prefix string) string {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	r.counters[caseType]++
 	year := time.Now().Year()
-	return fmt.Sprintf("%s-%d-%03d", caseType, year, r.counters[caseType])
+	return fmt.Sprintf("%s-%d-%03d", prefix, year, r.counters[caseType])
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
 }
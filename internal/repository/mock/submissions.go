@@ -0,0 +1,71 @@
+package mock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"ncoe/internal/domain"
+)
+
+// HeldSubmissionRepository is an in-memory triage queue
+type HeldSubmissionRepository struct {
+	mu          sync.RWMutex
+	submissions []*domain.HeldSubmission
+}
+
+func NewHeldSubmissionRepository() *HeldSubmissionRepository {
+	return &HeldSubmissionRepository{}
+}
+
+func (r *HeldSubmissionRepository) Create(s *domain.HeldSubmission) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.ID == "" {
+		s.ID = fmt.Sprintf("held_%d", time.Now().UnixNano())
+	}
+	c := *s
+	r.submissions = append(r.submissions, &c)
+	return nil
+}
+
+// GetByID returns a copy of the submission, or nil
+func (r *HeldSubmissionRepository) GetByID(id string) *domain.HeldSubmission {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.submissions {
+		if s.ID == id {
+			c := *s
+			return &c
+		}
+	}
+	return nil
+}
+
+func (r *HeldSubmissionRepository) Update(s *domain.HeldSubmission) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.submissions {
+		if existing.ID == s.ID {
+			c := *s
+			r.submissions[i] = &c
+			return nil
+		}
+	}
+	return fmt.Errorf("held submission %s not found", s.ID)
+}
+
+// List returns submissions with the status, newest first; an empty status
+// matches every submission
+func (r *HeldSubmissionRepository) List(status domain.TriageStatus) []*domain.HeldSubmission {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []*domain.HeldSubmission
+	for i := len(r.submissions) - 1; i >= 0; i-- {
+		if s := r.submissions[i]; status == "" || s.Status == status {
+			c := *s
+			result = append(result, &c)
+		}
+	}
+	return result
+}
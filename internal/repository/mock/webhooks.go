@@ -0,0 +1,149 @@
+package mock
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"ncoe/internal/domain"
+)
+
+// WebhookRepository is an in-memory store of webhooks and their delivery log
+type WebhookRepository struct {
+	mu         sync.RWMutex
+	webhooks   map[string]*domain.Webhook
+	deliveries map[string]*domain.WebhookDelivery
+}
+
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{
+		webhooks:   make(map[string]*domain.Webhook),
+		deliveries: make(map[string]*domain.WebhookDelivery),
+	}
+}
+
+func (r *WebhookRepository) Create(w *domain.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.webhooks[w.ID]; exists {
+		return fmt.Errorf("webhook already exists: %s", w.ID)
+	}
+	stored := *w
+	stored.Events = append([]domain.WebhookEvent(nil), w.Events...)
+	r.webhooks[w.ID] = &stored
+	return nil
+}
+
+func (r *WebhookRepository) Update(w *domain.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.webhooks[w.ID]; !exists {
+		return fmt.Errorf("webhook not found: %s", w.ID)
+	}
+	stored := *w
+	stored.Events = append([]domain.WebhookEvent(nil), w.Events...)
+	r.webhooks[w.ID] = &stored
+	return nil
+}
+
+// Delete removes a webhook and its delivery log
+func (r *WebhookRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.webhooks[id]; !exists {
+		return fmt.Errorf("webhook not found: %s", id)
+	}
+	delete(r.webhooks, id)
+	for did, d := range r.deliveries {
+		if d.WebhookID == id {
+			delete(r.deliveries, did)
+		}
+	}
+	return nil
+}
+
+// GetByID returns a copy of the webhook, or nil
+func (r *WebhookRepository) GetByID(id string) *domain.Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if w, ok := r.webhooks[id]; ok {
+		copied := *w
+		return &copied
+	}
+	return nil
+}
+
+// List returns all webhooks, oldest first
+func (r *WebhookRepository) List() []domain.Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []domain.Webhook
+	for _, w := range r.webhooks {
+		result = append(result, *w)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+func (r *WebhookRepository) CreateDelivery(d *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.deliveries[d.ID]; exists {
+		return fmt.Errorf("webhook delivery already exists: %s", d.ID)
+	}
+	r.deliveries[d.ID] = copyDelivery(d)
+	return nil
+}
+
+func (r *WebhookRepository) UpdateDelivery(d *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.deliveries[d.ID]; !exists {
+		return fmt.Errorf("webhook delivery not found: %s", d.ID)
+	}
+	r.deliveries[d.ID] = copyDelivery(d)
+	return nil
+}
+
+// GetDelivery returns a copy of the delivery, or nil
+func (r *WebhookRepository) GetDelivery(id string) *domain.WebhookDelivery {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if d, ok := r.deliveries[id]; ok {
+		return copyDelivery(d)
+	}
+	return nil
+}
+
+// ListDeliveries returns a webhook's deliveries, newest first
+func (r *WebhookRepository) ListDeliveries(webhookID string) []domain.WebhookDelivery {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []domain.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID {
+			result = append(result, *copyDelivery(d))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// copyDelivery copies d so callers never share the stored attempt slice
+func copyDelivery(d *domain.WebhookDelivery) *domain.WebhookDelivery {
+	copied := *d
+	copied.Attempts = append([]domain.DeliveryAttempt(nil), d.Attempts...)
+	return &copied
+}
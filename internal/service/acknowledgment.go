@@ -0,0 +1,67 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"ncoe/internal/domain"
+)
+
+// AcknowledgmentRepository stores the ethics acknowledgments filed by public officers
+type AcknowledgmentRepository interface {
+	Create(a *domain.EthicsAcknowledgment) error
+	GetByID(id string) *domain.EthicsAcknowledgment
+	List(agencyType, query string, year int) []*domain.EthicsAcknowledgment
+	CountFiled(from, to time.Time) map[string]int
+}
+
+// AgencyTypes lists the kinds of agency an acknowledging officer may serve
+var AgencyTypes = []string{"state", "county", "city", "district"}
+
+// AgencyTypeLabel returns the display name of an agency type
+func AgencyTypeLabel(agencyType string) string {
+	switch agencyType {
+	case "state":
+		return "State"
+	case "county":
+		return "County"
+	case "city":
+		return "City"
+	case "district":
+		return "Special District"
+	}
+	return agencyType
+}
+
+type AcknowledgmentService struct {
+	repo AcknowledgmentRepository
+}
+
+func NewAcknowledgmentService(repo AcknowledgmentRepository) *AcknowledgmentService {
+	return &AcknowledgmentService{repo: repo}
+}
+
+// List returns acknowledgments matching the filters, newest first; a zero
+// year matches every year
+func (s *AcknowledgmentService) List(agencyType, query string, year int) []*domain.EthicsAcknowledgment {
+	return s.repo.List(agencyType, query, year)
+}
+
+func (s *AcknowledgmentService) GetByID(id string) *domain.EthicsAcknowledgment {
+	return s.repo.GetByID(id)
+}
+
+// Years returns the calendar years with filed acknowledgments, newest first,
+// always including the current year
+func (s *AcknowledgmentService) Years() []int {
+	seen := map[int]bool{time.Now().Year(): true}
+	for _, a := range s.repo.List("", "", 0) {
+		seen[a.AcknowledgedAt.Year()] = true
+	}
+	years := make([]int, 0, len(seen))
+	for y := range seen {
+		years = append(years, y)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(years)))
+	return years
+}
@@ -0,0 +1,450 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ncoe/internal/docgen"
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+)
+
+// AnnualReportRepository stores the narrative sections staff have rewritten
+type AnnualReportRepository interface {
+	Narratives(fiscalYear int) map[string]*domain.ReportNarrative
+	SaveNarrative(n *domain.ReportNarrative) error
+	DeleteNarrative(fiscalYear int, section string) error
+}
+
+var (
+	// ErrReportYear is returned for a fiscal year that has not begun or predates the records
+	ErrReportYear = errors.New("no annual report for that fiscal year")
+	// ErrReportSection is returned when a narrative names an unknown section
+	ErrReportSection = errors.New("unknown annual report section")
+)
+
+// firstReportYear is the earliest fiscal year a report can be generated for
+const firstReportYear = 2000
+
+// annualSections lists the report's sections in the order they are printed
+var annualSections = []struct{ key, title string }{
+	{"introduction", "Introduction"},
+	{"advisory_opinions", "Advisory Opinions"},
+	{"complaints", "Ethics Complaints"},
+	{"acknowledgments", "Ethics Acknowledgments"},
+	{"records_requests", "Public Records Requests"},
+	{"publications", "Published Opinions and Orders"},
+	{"outlook", "Looking Ahead"},
+}
+
+// LabelCount is one row of a breakdown
+type LabelCount struct {
+	Label string
+	Count int
+}
+
+// AnnualCaseCounts summarizes one case type over a fiscal year
+type AnnualCaseCounts struct {
+	Received  int          // Submitted during the year
+	Resolved  int          // Published, closed or withdrawn during the year
+	Published int          // Resolved by publishing an opinion or order
+	Pending   int          // Open at the end of the year
+	Deadlines DeadlineRate // Resolved during the year on or before the due date, or after it
+}
+
+// AnnualReportSection is one section of the report: a narrative, which staff
+// may rewrite, and the table of figures that goes with it
+type AnnualReportSection struct {
+	Key      string
+	Title    string
+	Default  string                  // Generated narrative
+	Override *domain.ReportNarrative // Staff's text, if they have replaced the default
+	Table    *docgen.Table
+}
+
+// Body returns the narrative printed in the report
+func (s AnnualReportSection) Body() string {
+	if s.Override != nil {
+		return s.Override.Body
+	}
+	return s.Default
+}
+
+// AnnualReport is the statutory report on a fiscal year's work
+type AnnualReport struct {
+	FiscalYear int
+	Start      time.Time // July 1
+	End        time.Time // The following July 1; the report covers [Start, End)
+	AsOf       time.Time // End, or the time of generation while the year is under way
+
+	AdvisoryOpinions AnnualCaseCounts
+	Complaints       AnnualCaseCounts
+	RecordsRequests  AnnualCaseCounts
+	Dispositions     []LabelCount // Complaints resolved during the year, by disposition
+	Acknowledgments  []LabelCount // Filed during the year, by agency type
+	Filed            int          // Acknowledgments filed during the year
+	Opinions         []domain.PublishedOpinion
+
+	Sections []AnnualReportSection
+}
+
+// Complete reports whether the fiscal year has ended
+func (r *AnnualReport) Complete() bool {
+	return r.AsOf.Equal(r.End)
+}
+
+// LastDay returns the last day the report covers: June 30, or today while
+// the year is under way
+func (r *AnnualReport) LastDay() time.Time {
+	if r.Complete() {
+		return r.End.AddDate(0, 0, -1)
+	}
+	return r.AsOf
+}
+
+// Period describes the dates covered, e.g. "July 1, 2024 to June 30, 2025"
+func (r *AnnualReport) Period() string {
+	return r.Start.Format("January 2, 2006") + " to " + r.LastDay().Format("January 2, 2006")
+}
+
+// ReportLetterhead is the agency identity printed on the report's cover
+type ReportLetterhead struct {
+	AgencyName string
+	Address    string
+	Website    string
+	Email      string
+	Phone      string
+	Color      string // "#RRGGBB"
+}
+
+type AnnualReportService struct {
+	caseRepo    CaseRepository
+	opinionRepo OpinionRepository
+	ackRepo     AcknowledgmentRepository
+	repo        AnnualReportRepository
+}
+
+func NewAnnualReportService(caseRepo CaseRepository, opinionRepo OpinionRepository, ackRepo AcknowledgmentRepository, repo AnnualReportRepository) *AnnualReportService {
+	return &AnnualReportService{caseRepo: caseRepo, opinionRepo: opinionRepo, ackRepo: ackRepo, repo: repo}
+}
+
+// Years returns the fiscal years a report can be generated for, newest
+// first: from the year of the earliest case to the current year
+func (s *AnnualReportService) Years() []int {
+	current := domain.FiscalYear(time.Now())
+	first := current
+	for _, c := range s.caseRepo.List("", "", "") {
+		if fy := domain.FiscalYear(c.SubmittedAt); fy < first && fy >= firstReportYear {
+			first = fy
+		}
+	}
+	years := make([]int, 0, current-first+1)
+	for fy := current; fy >= first; fy-- {
+		years = append(years, fy)
+	}
+	return years
+}
+
+// Generate computes the report for a fiscal year. Once the year has ended
+// the report depends only on the records and the narrative overrides, so
+// regenerating it gives the same figures and the same document.
+//
+// A case is received when it is submitted and resolved when it is
+// published, closed or withdrawn; it is pending at year end if it was
+// received but not yet resolved.
+func (s *AnnualReportService) Generate(fiscalYear int) (*AnnualReport, error) {
+	now := time.Now()
+	if fiscalYear < firstReportYear || fiscalYear > domain.FiscalYear(now) {
+		return nil, fmt.Errorf("%w: FY%d", ErrReportYear, fiscalYear)
+	}
+	r := &AnnualReport{
+		FiscalYear: fiscalYear,
+		Start:      domain.FiscalYearStart(fiscalYear, domain.Location),
+		End:        domain.FiscalYearStart(fiscalYear+1, domain.Location),
+	}
+	r.AsOf = r.End
+	if now.Before(r.End) {
+		r.AsOf = now.In(domain.Location)
+	}
+	during := func(t time.Time) bool {
+		return !t.Before(r.Start) && t.Before(r.AsOf)
+	}
+
+	counts := map[domain.CaseType]*AnnualCaseCounts{
+		domain.CaseTypeAdvisoryOpinion:      &r.AdvisoryOpinions,
+		domain.CaseTypeEthicsComplaint:      &r.Complaints,
+		domain.CaseTypePublicRecordsRequest: &r.RecordsRequests,
+	}
+	dispositions := map[domain.Disposition]int{}
+	for _, c := range s.caseRepo.List("", "", "") {
+		n := counts[c.Type]
+		if n == nil {
+			continue
+		}
+		resolved := c.ResolvedAt()
+		if during(c.SubmittedAt) {
+			n.Received++
+		}
+		if c.SubmittedAt.Before(r.AsOf) && (resolved == nil || !resolved.Before(r.AsOf)) {
+			n.Pending++
+		}
+		if resolved == nil || !during(*resolved) {
+			continue
+		}
+		n.Resolved++
+		if c.Status == domain.StatusPublished {
+			n.Published++
+		}
+		if !c.DueDate.IsZero() {
+			if resolved.After(c.DueDate) {
+				n.Deadlines.Missed++
+			} else {
+				n.Deadlines.Met++
+			}
+		}
+		if c.Type == domain.CaseTypeEthicsComplaint {
+			d := c.Disposition
+			if d == "" && c.Status == domain.StatusWithdrawn {
+				d = domain.DispositionWithdrawn
+			}
+			dispositions[d]++
+		}
+	}
+	for _, d := range domain.Dispositions {
+		r.Dispositions = append(r.Dispositions, LabelCount{d.Label(), dispositions[d]})
+	}
+	if n := dispositions[""]; n > 0 {
+		r.Dispositions = append(r.Dispositions, LabelCount{"Not recorded", n})
+	}
+
+	filed := s.ackRepo.CountFiled(r.Start, r.AsOf)
+	agencyTypes := append([]string{}, AgencyTypes...)
+	var others []string
+	for t := range filed {
+		if !contains(AgencyTypes, t) {
+			others = append(others, t)
+		}
+	}
+	sort.Strings(others)
+	for _, t := range append(agencyTypes, others...) {
+		r.Acknowledgments = append(r.Acknowledgments, LabelCount{AgencyTypeLabel(t), filed[t]})
+		r.Filed += filed[t]
+	}
+
+	for _, o := range s.opinionRepo.Search("", "", "", "") {
+		if during(o.PublishedAt) {
+			r.Opinions = append(r.Opinions, o)
+		}
+	}
+	sort.Slice(r.Opinions, func(i, j int) bool {
+		if !r.Opinions[i].PublishedAt.Equal(r.Opinions[j].PublishedAt) {
+			return r.Opinions[i].PublishedAt.Before(r.Opinions[j].PublishedAt)
+		}
+		return r.Opinions[i].CaseNumber < r.Opinions[j].CaseNumber
+	})
+
+	r.Sections = r.sections(s.repo.Narratives(fiscalYear))
+	return r, nil
+}
+
+// SaveNarrative replaces a section's generated narrative with staff's text.
+// An empty body restores the generated narrative.
+func (s *AnnualReportService) SaveNarrative(ctx context.Context, user *domain.User, fiscalYear int, section, body string) error {
+	if user == nil || !user.CanManageCases() {
+		return ErrCaseForbidden
+	}
+	if fiscalYear < firstReportYear || fiscalYear > domain.FiscalYear(time.Now()) {
+		return fmt.Errorf("%w: FY%d", ErrReportYear, fiscalYear)
+	}
+	known := false
+	for _, s := range annualSections {
+		known = known || s.key == section
+	}
+	if !known {
+		return fmt.Errorf("%w: %q", ErrReportSection, section)
+	}
+
+	body = strings.TrimSpace(strings.ReplaceAll(body, "\r\n", "\n"))
+	if body == "" {
+		logging.FromContext(ctx).Info("annual report section restored", "fiscal_year", fiscalYear, "section", section, "actor_id", user.ID)
+		return s.repo.DeleteNarrative(fiscalYear, section)
+	}
+	logging.FromContext(ctx).Info("annual report section edited", "fiscal_year", fiscalYear, "section", section, "actor_id", user.ID)
+	return s.repo.SaveNarrative(&domain.ReportNarrative{
+		FiscalYear: fiscalYear,
+		Section:    section,
+		Body:       body,
+		UpdatedBy:  user.FullName(),
+		UpdatedAt:  time.Now(),
+	})
+}
+
+// sections builds each section's default narrative and table and applies
+// the overrides
+func (r *AnnualReport) sections(overrides map[string]*domain.ReportNarrative) []AnnualReportSection {
+	ao, ec, prr := r.AdvisoryOpinions, r.Complaints, r.RecordsRequests
+	pendingLabel := "Pending on " + r.LastDay().Format("January 2, 2006")
+	yearEnd := "at the end of the year"
+	if !r.Complete() {
+		yearEnd = "as of " + r.LastDay().Format("January 2, 2006")
+	}
+	counted := func(rows ...LabelCount) *docgen.Table {
+		t := &docgen.Table{Header: []string{"", "Count"}}
+		for _, row := range rows {
+			t.Rows = append(t.Rows, []string{row.Label, fmt.Sprint(row.Count)})
+		}
+		return t
+	}
+
+	defaults := map[string]string{}
+	tables := map[string]*docgen.Table{}
+
+	intro := fmt.Sprintf("This report covers fiscal year %d, from %s.", r.FiscalYear, r.Period())
+	if !r.Complete() {
+		intro = fmt.Sprintf("This interim report covers fiscal year %d from %s; the year is still under way.", r.FiscalYear, r.Period())
+	}
+	defaults["introduction"] = fmt.Sprintf("%s During the year the Commission received %s and resolved %s. %s pending %s.",
+		intro,
+		plural(ao.Received+ec.Received+prr.Received, "new matter", "new matters"),
+		plural(ao.Resolved+ec.Resolved+prr.Resolved, "matter", "matters"),
+		plural(ao.Pending+ec.Pending+prr.Pending, "matter remained", "matters remained"), yearEnd)
+	tables["introduction"] = &docgen.Table{Header: []string{"Case type", "Received", "Resolved", "Pending"}}
+	for _, row := range []struct {
+		t domain.CaseType
+		n AnnualCaseCounts
+	}{{domain.CaseTypeAdvisoryOpinion, ao}, {domain.CaseTypeEthicsComplaint, ec}, {domain.CaseTypePublicRecordsRequest, prr}} {
+		tables["introduction"].Rows = append(tables["introduction"].Rows,
+			[]string{row.t.Label(), fmt.Sprint(row.n.Received), fmt.Sprint(row.n.Resolved), fmt.Sprint(row.n.Pending)})
+	}
+
+	defaults["advisory_opinions"] = fmt.Sprintf("The Commission received %s and issued %s. %s closed without an opinion, and %s pending %s.",
+		plural(ao.Received, "request for an advisory opinion", "requests for advisory opinions"),
+		plural(ao.Published, "opinion", "opinions"),
+		plural(ao.Resolved-ao.Published, "request was", "requests were"),
+		plural(ao.Pending, "request remained", "requests remained"), yearEnd)
+	tables["advisory_opinions"] = counted(
+		LabelCount{"Requests received", ao.Received},
+		LabelCount{"Opinions issued", ao.Published},
+		LabelCount{"Closed without an opinion", ao.Resolved - ao.Published},
+		LabelCount{pendingLabel, ao.Pending},
+	)
+
+	defaults["complaints"] = fmt.Sprintf("The Commission received %s and resolved %s; %s pending %s. Resolved complaints are listed below by disposition.",
+		plural(ec.Received, "ethics complaint", "ethics complaints"),
+		plural(ec.Resolved, "complaint", "complaints"),
+		plural(ec.Pending, "complaint remained", "complaints remained"), yearEnd)
+	tables["complaints"] = &docgen.Table{Header: []string{"Disposition", "Complaints"}}
+	for _, d := range r.Dispositions {
+		tables["complaints"].Rows = append(tables["complaints"].Rows, []string{d.Label, fmt.Sprint(d.Count)})
+	}
+	tables["complaints"].Rows = append(tables["complaints"].Rows, []string{"Total resolved", fmt.Sprint(ec.Resolved)})
+
+	defaults["acknowledgments"] = fmt.Sprintf("Public officers filed %s of the statutory ethical standards during the year.",
+		plural(r.Filed, "acknowledgment", "acknowledgments"))
+	tables["acknowledgments"] = &docgen.Table{Header: []string{"Agency type", "Filed"}}
+	for _, a := range r.Acknowledgments {
+		tables["acknowledgments"].Rows = append(tables["acknowledgments"].Rows, []string{a.Label, fmt.Sprint(a.Count)})
+	}
+	tables["acknowledgments"].Rows = append(tables["acknowledgments"].Rows, []string{"Total", fmt.Sprint(r.Filed)})
+
+	records := fmt.Sprintf("The Commission received %s and completed %s.",
+		plural(prr.Received, "public records request", "public records requests"),
+		plural(prr.Resolved, "request", "requests"))
+	if prr.Deadlines.Total() > 0 {
+		records += fmt.Sprintf(" %d%% of requests with a response deadline were completed on time.", prr.Deadlines.Rate())
+	}
+	defaults["records_requests"] = records
+	tables["records_requests"] = counted(
+		LabelCount{"Requests received", prr.Received},
+		LabelCount{"Requests completed", prr.Resolved},
+		LabelCount{"Completed by the deadline", prr.Deadlines.Met},
+		LabelCount{"Completed after the deadline", prr.Deadlines.Missed},
+		LabelCount{pendingLabel, prr.Pending},
+	)
+
+	defaults["publications"] = fmt.Sprintf("The Commission published %s during the year.",
+		plural(len(r.Opinions), "opinion or order", "opinions and orders"))
+	if len(r.Opinions) > 0 {
+		t := &docgen.Table{Header: []string{"Case", "Title", "Published"}}
+		for _, o := range r.Opinions {
+			t.Rows = append(t.Rows, []string{o.CaseNumber, o.Title, o.PublishedAt.In(domain.Location).Format("Jan 2, 2006")})
+		}
+		tables["publications"] = t
+	}
+
+	sections := make([]AnnualReportSection, 0, len(annualSections))
+	for _, s := range annualSections {
+		sections = append(sections, AnnualReportSection{
+			Key:      s.key,
+			Title:    s.title,
+			Default:  defaults[s.key],
+			Override: overrides[s.key],
+			Table:    tables[s.key],
+		})
+	}
+	return sections
+}
+
+// Document lays the report out for PDF or DOCX output. Sections with
+// neither narrative nor figures are left out.
+func (r *AnnualReport) Document(lh ReportLetterhead) *docgen.Document {
+	doc := &docgen.Document{
+		Title:       fmt.Sprintf("Annual Report, Fiscal Year %d", r.FiscalYear),
+		Subtitle:    r.Period(),
+		Author:      lh.AgencyName,
+		AccentColor: lh.Color,
+	}
+	if !r.Complete() {
+		doc.Subtitle += " (interim)"
+	}
+	for _, line := range []string{lh.AgencyName, lh.Address, joinNonEmpty(" · ", lh.Website, lh.Email, lh.Phone)} {
+		if line != "" {
+			doc.CoverLines = append(doc.CoverLines, line)
+		}
+	}
+	for _, s := range r.Sections {
+		body := s.Body()
+		if body == "" && s.Table == nil {
+			continue
+		}
+		doc.Blocks = append(doc.Blocks, docgen.Heading{Text: s.Title})
+		if body != "" {
+			doc.Blocks = append(doc.Blocks, docgen.Paragraph{Text: body})
+		}
+		if s.Table != nil {
+			doc.Blocks = append(doc.Blocks, *s.Table)
+		}
+	}
+	return doc
+}
+
+// plural formats a count with the singular or plural noun: "1 opinion", "2 opinions"
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
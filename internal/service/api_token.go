@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+)
+
+type APITokenRepository interface {
+	Create(t *domain.APIToken) error
+	Update(t *domain.APIToken) error
+	GetByID(id string) *domain.APIToken
+	GetByHash(hash string) *domain.APIToken
+	ListByUser(userID string) []domain.APIToken
+}
+
+// TokenPrefix marks secrets as NCOE personal access tokens, so they are easy
+// to recognize in logs and by secret scanners
+const TokenPrefix = "ncoe_pat_"
+
+// TokenLifetimes are the expiry choices offered when minting a token, in days
+var TokenLifetimes = []int{7, 30, 90, 365}
+
+var (
+	// ErrInvalidToken is returned for secrets that do not match any token
+	ErrInvalidToken = errors.New("invalid API token")
+	// ErrTokenExpired is returned for tokens past their expiry
+	ErrTokenExpired = errors.New("API token has expired")
+	// ErrTokenRevoked is returned for revoked tokens
+	ErrTokenRevoked = errors.New("API token has been revoked")
+)
+
+// APITokenService mints, authenticates and revokes personal API tokens.
+// Secrets are returned once at creation and only their SHA-256 hash is stored.
+type APITokenService struct {
+	tokenRepo APITokenRepository
+	userRepo  UserRepository
+}
+
+func NewAPITokenService(tokenRepo APITokenRepository, userRepo UserRepository) *APITokenService {
+	return &APITokenService{
+		tokenRepo: tokenRepo,
+		userRepo:  userRepo,
+	}
+}
+
+// Create mints a token for user with the given scopes and lifetime in days.
+// It returns the stored token and the secret, which cannot be recovered later.
+func (s *APITokenService) Create(ctx context.Context, user *domain.User, name string, scopes []domain.TokenScope, days int) (*domain.APIToken, string, error) {
+	if user == nil {
+		return nil, "", errors.New("sign in to create API tokens")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("token name is required")
+	}
+	if len(name) > 100 {
+		return nil, "", errors.New("token name must be 100 characters or fewer")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("select at least one scope")
+	}
+	for _, scope := range scopes {
+		if !scope.IsValid() {
+			return nil, "", fmt.Errorf("unknown scope: %q", scope)
+		}
+		if !scope.PermittedFor(user) {
+			return nil, "", fmt.Errorf("your role does not allow the %s scope", scope)
+		}
+	}
+	if !validLifetime(days) {
+		return nil, "", fmt.Errorf("token lifetime must be one of %v days", TokenLifetimes)
+	}
+
+	secret := generateSecret()
+	now := time.Now()
+	token := &domain.APIToken{
+		ID:        fmt.Sprintf("tok_%d", now.UnixNano()),
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    secret[:len(TokenPrefix)+6],
+		Hash:      hashSecret(secret),
+		Scopes:    dedupeScopes(scopes),
+		ExpiresAt: now.AddDate(0, 0, days),
+		CreatedAt: now,
+	}
+	if err := s.tokenRepo.Create(token); err != nil {
+		return nil, "", err
+	}
+
+	logging.FromContext(ctx).Info("API token created", "token_id", token.ID, "user_id", user.ID, "name", token.Name,
+		"scopes", token.Scopes, "expires", token.ExpiresAt.Format("2006-01-02"))
+	return token, secret, nil
+}
+
+// List returns the user's tokens, newest first
+func (s *APITokenService) List(user *domain.User) []domain.APIToken {
+	if user == nil {
+		return nil
+	}
+	return s.tokenRepo.ListByUser(user.ID)
+}
+
+// Revoke permanently disables one of the user's tokens
+func (s *APITokenService) Revoke(ctx context.Context, user *domain.User, tokenID string) error {
+	token := s.tokenRepo.GetByID(tokenID)
+	if user == nil || token == nil || token.UserID != user.ID {
+		return fmt.Errorf("api token not found: %s", tokenID)
+	}
+	if token.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	if err := s.tokenRepo.Update(token); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("API token revoked", "token_id", token.ID, "user_id", user.ID, "name", token.Name)
+	return nil
+}
+
+// Authenticate resolves a bearer secret to its token and owner, recording
+// when and from where the token was last used
+func (s *APITokenService) Authenticate(ctx context.Context, secret, remoteIP string) (*domain.User, *domain.APIToken, error) {
+	if !strings.HasPrefix(secret, TokenPrefix) {
+		return nil, nil, ErrInvalidToken
+	}
+	token := s.tokenRepo.GetByHash(hashSecret(secret))
+	if token == nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if token.RevokedAt != nil {
+		return nil, nil, ErrTokenRevoked
+	}
+	if token.IsExpired() {
+		return nil, nil, ErrTokenExpired
+	}
+	user := s.userRepo.GetByID(token.UserID)
+	if user == nil || !user.IsActive {
+		return nil, nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	token.LastUsedAt = &now
+	token.LastUsedIP = remoteIP
+	if err := s.tokenRepo.Update(token); err != nil {
+		logging.FromContext(ctx).Error("recording API token use", "token_id", token.ID, "error", err)
+	}
+	return user, token, nil
+}
+
+func validLifetime(days int) bool {
+	for _, d := range TokenLifetimes {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupeScopes(scopes []domain.TokenScope) []domain.TokenScope {
+	var result []domain.TokenScope
+	for _, candidate := range domain.TokenScopes {
+		for _, scope := range scopes {
+			if scope == candidate.Scope {
+				result = append(result, scope)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// generateSecret returns a new token secret: the prefix and 32 random bytes
+func generateSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashSecret(secret string) string {
+	return sha256Hex([]byte(secret))
+}
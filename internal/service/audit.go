@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+)
+
+type AuditRepository interface {
+	Create(e *domain.AuditEntry) error
+	List(targetType, targetID string) []domain.AuditEntry
+}
+
+// ErrAuditForbidden is returned when a user without audit access reads the log
+var ErrAuditForbidden = errors.New("you do not have access to the audit log")
+
+// AuditService records administrative changes and lists them for admins
+// and auditors
+type AuditService struct {
+	repo AuditRepository
+}
+
+func NewAuditService(repo AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record appends an entry. A failure to record is logged rather than
+// returned, since the change it describes has already been made.
+func (s *AuditService) Record(ctx context.Context, actor *domain.User, action domain.AuditAction, target *domain.User, details string) {
+	now := time.Now()
+	e := &domain.AuditEntry{
+		ID:         fmt.Sprintf("audit_%d", now.UnixNano()),
+		Action:     action,
+		ActorID:    actor.ID,
+		ActorName:  actor.FullName(),
+		TargetType: "user",
+		TargetID:   target.ID,
+		TargetName: target.FullName(),
+		Details:    details,
+		CreatedAt:  now,
+	}
+	if err := s.repo.Create(e); err != nil {
+		logging.FromContext(ctx).Error("recording audit entry", "action", action, "target_id", target.ID, "actor_id", actor.ID, "error", err)
+		return
+	}
+	logging.FromContext(ctx).Info("audit", "action", action, "target_id", target.ID, "actor_id", actor.ID, "details", details)
+}
+
+// List returns the whole log, newest first
+func (s *AuditService) List(viewer *domain.User) ([]domain.AuditEntry, error) {
+	if viewer == nil || !viewer.CanViewAuditLogs() {
+		return nil, ErrAuditForbidden
+	}
+	return s.repo.List("", ""), nil
+}
+
+// ForUser returns the entries about one user, newest first
+func (s *AuditService) ForUser(viewer *domain.User, userID string) ([]domain.AuditEntry, error) {
+	if viewer == nil || !viewer.CanViewAuditLogs() {
+		return nil, ErrAuditForbidden
+	}
+	return s.repo.List("user", userID), nil
+}
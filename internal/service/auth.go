@@ -1,95 +1,462 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
 	"time"
 
 	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/metrics"
+	"ncoe/internal/ratelimit"
 )
 
 type UserRepository interface {
 	GetByEmail(email string) *domain.User
 	GetByID(id string) *domain.User
+	GetByExternalID(externalID string) *domain.User
+	GetByPasswordToken(hash string) *domain.User
+	List() []*domain.User
+	Create(u *domain.User) error
+	Update(u *domain.User) error
 }
 
 type SessionRepository interface {
 	Create(s *domain.Session) error
 	GetByToken(token string) *domain.Session
+	Update(s *domain.Session) error
 	Delete(token string) error
+	List() []*domain.Session
+	ListByUser(userID string) []*domain.Session
+	DeleteExpired(now time.Time) int
+}
+
+// SessionPolicy bounds how long a staff session lasts
+type SessionPolicy struct {
+	IdleTimeout     time.Duration // Signed out after this long without a request
+	AbsoluteTimeout time.Duration // Signed out this long after login, however active
+}
+
+// DefaultSessionPolicy is used when no timeouts are configured
+var DefaultSessionPolicy = SessionPolicy{IdleTimeout: 30 * time.Minute, AbsoluteTimeout: 12 * time.Hour}
+
+// ClientInfo identifies the browser a session was started from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// ActiveUser is a user with live sessions, as listed for admins
+type ActiveUser struct {
+	User       *domain.User
+	Sessions   int
+	LastSeenAt time.Time
+}
+
+var (
+	// ErrInvalidCredentials is returned for unknown users and wrong passwords
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrSessionNotFound is returned when revoking a session that does not
+	// exist or belongs to someone else
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionForbidden is returned when a non-admin signs out another user
+	ErrSessionForbidden = errors.New("only administrators can sign out other users")
+)
+
+var (
+	logins        = metrics.NewCounter("ncoe_logins_total", "Staff sessions started, by password, second factor or single sign-on.")
+	loginFailures = metrics.NewCounter("ncoe_login_failures_total", "Refused staff sign-ins, by reason: password, inactive, mfa or sso.", "reason")
+)
+
+const (
+	// mfaChallengeTTL bounds the time between the password and the code
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaMaxAttempts is how many wrong codes end a login attempt
+	mfaMaxAttempts = 5
+)
+
+// mfaFailureRate bounds the wrong codes one user can enter across logins,
+// so someone with the password cannot keep starting new logins to guess
+// codes: after ten, one more every six minutes
+var mfaFailureRate = ratelimit.Rate{Count: 10, Per: time.Hour}
+
+var (
+	// ErrMFAChallengeExpired is returned when a second login step is too
+	// late, unknown, or has used up its attempts
+	ErrMFAChallengeExpired = errors.New("sign-in expired; enter your password again")
+	// ErrMFAThrottled is returned, without checking the code, while a user
+	// has entered too many wrong codes
+	ErrMFAThrottled = errors.New("too many wrong codes for this account")
+)
+
+// MFAChallenge is a login that has passed the password check and is waiting
+// for a second factor. Enroll is set when the user's role requires a second
+// factor they have not set up yet; they enroll before their session starts.
+type MFAChallenge struct {
+	Token     string
+	User      *domain.User
+	Enroll    bool
+	ExpiresAt time.Time
+	client    ClientInfo
+	attempts  int
 }
 
 type AuthService struct {
 	userRepo    UserRepository
 	sessionRepo SessionRepository
+	mfa         *MFAService
+	policy      SessionPolicy
+	demo        bool // Accept unknown emails and accounts without a password
+	now         func() time.Time
+
+	mu          sync.Mutex
+	challenges  map[string]*MFAChallenge // token -> pending login
+	mfaFailures *ratelimit.Limiter       // Wrong second-factor codes, by user ID
 }
 
-func NewAuthService(userRepo UserRepository, sessionRepo SessionRepository) *AuthService {
+// NewAuthService creates the staff sign-in service. demo is for the mock
+// repositories only: unknown emails sign in as new admins, and accounts with
+// no password accept any password.
+func NewAuthService(userRepo UserRepository, sessionRepo SessionRepository, mfa *MFAService, policy SessionPolicy, demo bool) *AuthService {
+	return NewAuthServiceWithClock(userRepo, sessionRepo, mfa, policy, demo, time.Now)
+}
+
+// NewAuthServiceWithClock creates an auth service that reads the time from
+// now when starting, renewing and expiring sessions and logins, for tests
+func NewAuthServiceWithClock(userRepo UserRepository, sessionRepo SessionRepository, mfa *MFAService, policy SessionPolicy, demo bool, now func() time.Time) *AuthService {
 	return &AuthService{
 		userRepo:    userRepo,
 		sessionRepo: sessionRepo,
+		mfa:         mfa,
+		policy:      policy,
+		demo:        demo,
+		now:         now,
+		challenges:  make(map[string]*MFAChallenge),
+		mfaFailures: ratelimit.NewWithClock(mfaFailureRate, now),
 	}
 }
 
-// LoginStaff authenticates a staff user
-func (s *AuthService) LoginStaff(email, password string) (*domain.Session, error) {
-	// In demo mode, accept any credentials
+// LoginStaff authenticates a staff user by password. When the user has a
+// second factor, or their role requires one, no session is created: the
+// returned challenge must be completed with CompleteLogin.
+func (s *AuthService) LoginStaff(ctx context.Context, email, password string, client ClientInfo) (*domain.Session, *MFAChallenge, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
 	user := s.userRepo.GetByEmail(email)
-	if user == nil {
-		// Demo mode: create a mock user
-		user = &domain.User{
-			ID:        "demo_user",
-			Email:     email,
-			FirstName: "Demo",
-			LastName:  "User",
-			Role:      domain.RoleAdmin,
-			IsActive:  true,
+	switch {
+	case user == nil && s.demo:
+		// Demo mode: accept any credentials for unknown emails, as an admin
+		var err error
+		if user, err = s.createDemoUser(email); err != nil {
+			return nil, nil, err
+		}
+	case user == nil, user.PasswordTokenHash != "", user.ExternalID != "":
+		// Invited or reset accounts must use the emailed link first, and
+		// accounts from the identity provider sign in there
+		loginFailures.Inc("password")
+		return nil, nil, ErrInvalidCredentials
+	case user.PasswordHash == "" && !s.demo, user.PasswordHash != "" && !checkPassword(user.PasswordHash, password):
+		// Only demo mode lets accounts without a password (the seeded demo
+		// accounts) in with any password
+		loginFailures.Inc("password")
+		return nil, nil, ErrInvalidCredentials
+	case !user.IsActive:
+		// Checked after the password, so it does not reveal which accounts exist
+		logging.FromContext(ctx).Warn("login refused: account deactivated", "user_id", user.ID)
+		loginFailures.Inc("inactive")
+		return nil, nil, ErrUserInactive
+	}
+
+	return s.beginSession(ctx, user, client)
+}
+
+// LoginSSO signs in a user the identity provider has authenticated. The
+// second-factor policy applies as it does to passwords: a user who has
+// enrolled, or whose role requires it, gets a challenge instead of a session.
+func (s *AuthService) LoginSSO(ctx context.Context, user *domain.User, client ClientInfo) (*domain.Session, *MFAChallenge, error) {
+	return s.beginSession(ctx, user, client)
+}
+
+// beginSession starts a session for user, or returns the challenge for the
+// second factor the user must give first
+func (s *AuthService) beginSession(ctx context.Context, user *domain.User, client ClientInfo) (*domain.Session, *MFAChallenge, error) {
+	if s.mfa.Enrollment(user.ID) != nil || s.mfa.Required(user) {
+		return nil, s.newChallenge(user, client), nil
+	}
+	session, err := s.StartSession(ctx, user, client)
+	return session, nil, err
+}
+
+// createDemoUser stores an admin for an unknown email in demo mode
+func (s *AuthService) createDemoUser(email string) (*domain.User, error) {
+	now := time.Now()
+	id := "demo_user"
+	if s.userRepo.GetByID(id) != nil {
+		id = fmt.Sprintf("demo_user_%d", now.UnixNano())
+	}
+	user := &domain.User{
+		ID:        id,
+		Email:     email,
+		FirstName: "Demo",
+		LastName:  "User",
+		Role:      domain.RoleAdmin,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *AuthService) newChallenge(user *domain.User, client ClientInfo) *MFAChallenge {
+	now := s.now()
+	c := &MFAChallenge{
+		Token:     generateToken(),
+		User:      user,
+		Enroll:    s.mfa.Enrollment(user.ID) == nil,
+		ExpiresAt: now.Add(mfaChallengeTTL),
+		client:    client,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, pending := range s.challenges {
+		if now.After(pending.ExpiresAt) {
+			delete(s.challenges, token)
+		}
+	}
+	s.challenges[c.Token] = c
+	return c
+}
+
+// Challenge returns the pending login for token
+func (s *AuthService) Challenge(token string) (*MFAChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.challenges[token]
+	if !ok || s.now().After(c.ExpiresAt) {
+		delete(s.challenges, token)
+		return nil, ErrMFAChallengeExpired
+	}
+	return c, nil
+}
+
+// CompleteLogin checks the second factor for a pending login and starts the
+// session. For a login that enrolls the user, the code confirms their new
+// authenticator and their recovery codes are returned.
+func (s *AuthService) CompleteLogin(ctx context.Context, token, code string) (*domain.Session, []string, error) {
+	c, err := s.Challenge(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if blocked, wait := s.mfaFailures.Blocked(c.User.ID); blocked {
+		loginFailures.Inc("mfa")
+		logging.FromContext(ctx).Warn("MFA throttled", "user_id", c.User.ID)
+		return nil, nil, fmt.Errorf("%w; try again in %d minutes", ErrMFAThrottled, int(wait.Minutes())+1)
+	}
+
+	var recoveryCodes []string
+	if c.Enroll {
+		recoveryCodes, err = s.mfa.ConfirmEnrollment(ctx, c.User, code)
+	} else {
+		err = s.mfa.Verify(ctx, c.User.ID, code)
+	}
+	if err != nil {
+		loginFailures.Inc("mfa")
+		s.mfaFailures.Allow(c.User.ID)
+		s.mu.Lock()
+		c.attempts++
+		if c.attempts >= mfaMaxAttempts {
+			delete(s.challenges, token)
+			logging.FromContext(ctx).Warn("MFA lockout", "user_id", c.User.ID, "attempts", c.attempts)
+			err = ErrMFAChallengeExpired
 		}
+		s.mu.Unlock()
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	_, pending := s.challenges[token]
+	delete(s.challenges, token)
+	s.mu.Unlock()
+	if !pending {
+		// Another request completed or ended this login first
+		return nil, nil, ErrMFAChallengeExpired
 	}
 
-	// Create session
-	token := generateToken()
+	session, err := s.StartSession(ctx, c.User, c.client)
+	return session, recoveryCodes, err
+}
+
+// StartSession creates a session for a user who has already been
+// authenticated, by password or single sign-on and then any second factor
+// the policy asks for (see beginSession)
+func (s *AuthService) StartSession(ctx context.Context, user *domain.User, client ClientInfo) (*domain.Session, error) {
+	now := s.now()
 	session := &domain.Session{
-		ID:        generateToken(),
-		UserID:    user.ID,
-		Token:     token,
-		ExpiresAt: time.Now().Add(30 * time.Minute),
-		CreatedAt: time.Now(),
+		ID:         generateToken(),
+		UserID:     user.ID,
+		Token:      generateToken(),
+		ExpiresAt:  now.Add(s.policy.AbsoluteTimeout),
+		LastSeenAt: now,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
 	}
+	session.IdleExpiresAt = s.idleExpiry(session, now)
 
 	if err := s.sessionRepo.Create(session); err != nil {
 		return nil, err
 	}
 
+	if stored := s.userRepo.GetByID(user.ID); stored != nil {
+		stored.LastLoginAt = &now
+		if err := s.userRepo.Update(stored); err != nil {
+			logging.FromContext(ctx).Error("recording login", "user_id", user.ID, "error", err)
+		}
+	}
+	logins.Inc()
 	return session, nil
 }
 
-// ValidateSession checks if a session is valid
+// ValidateSession checks if a session is valid. Activity pushes the idle
+// limit back (sliding expiry); the absolute limit never moves.
 func (s *AuthService) ValidateSession(token string) (*domain.User, error) {
 	session := s.sessionRepo.GetByToken(token)
 	if session == nil {
 		return nil, errors.New("invalid session")
 	}
 
-	if session.IsExpired() {
+	now := s.now()
+	if session.ExpiredAt(now) {
 		s.sessionRepo.Delete(token)
 		return nil, errors.New("session expired")
 	}
 
+	// Activity is written back at most once per touch interval, which keeps
+	// the idle limit accurate to a tenth of the idle timeout
+	if now.Sub(session.LastSeenAt) >= min(time.Minute, s.policy.IdleTimeout/10) {
+		session.LastSeenAt = now
+		session.IdleExpiresAt = s.idleExpiry(session, now)
+		s.sessionRepo.Update(session)
+	}
+
 	user := s.userRepo.GetByID(session.UserID)
-	if user == nil {
+	if user == nil || !user.IsActive {
+		s.sessionRepo.Delete(token)
 		return nil, errors.New("user not found")
 	}
 
 	return user, nil
 }
 
+func (s *AuthService) idleExpiry(session *domain.Session, now time.Time) time.Time {
+	idle := now.Add(s.policy.IdleTimeout)
+	if idle.After(session.ExpiresAt) {
+		return session.ExpiresAt
+	}
+	return idle
+}
+
 // Logout invalidates a session
 func (s *AuthService) Logout(token string) error {
 	return s.sessionRepo.Delete(token)
 }
 
+// Sessions returns the user's live sessions, most recently active first
+func (s *AuthService) Sessions(user *domain.User) []*domain.Session {
+	var live []*domain.Session
+	for _, session := range s.sessionRepo.ListByUser(user.ID) {
+		if !session.ExpiredAt(s.now()) {
+			live = append(live, session)
+		}
+	}
+	return live
+}
+
+// RevokeSession signs out one of the user's own sessions, e.g. a lost laptop
+func (s *AuthService) RevokeSession(ctx context.Context, user *domain.User, sessionID string) error {
+	for _, session := range s.sessionRepo.ListByUser(user.ID) {
+		if session.ID == sessionID {
+			logging.FromContext(ctx).Info("session revoked", "user_id", user.ID, "session_id", sessionID)
+			return s.sessionRepo.Delete(session.Token)
+		}
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeOtherSessions signs the user out everywhere except the session with
+// currentToken, and returns how many sessions were ended
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, user *domain.User, currentToken string) int {
+	revoked := 0
+	for _, session := range s.sessionRepo.ListByUser(user.ID) {
+		if session.Token != currentToken {
+			s.sessionRepo.Delete(session.Token)
+			revoked++
+		}
+	}
+	logging.FromContext(ctx).Info("other sessions revoked", "user_id", user.ID, "sessions", revoked)
+	return revoked
+}
+
+// ForceLogout ends every session of another user and returns how many were ended
+func (s *AuthService) ForceLogout(ctx context.Context, admin *domain.User, userID string) (int, error) {
+	if admin == nil || !admin.CanManageUsers() {
+		return 0, ErrSessionForbidden
+	}
+	sessions := s.sessionRepo.ListByUser(userID)
+	for _, session := range sessions {
+		s.sessionRepo.Delete(session.Token)
+	}
+	logging.FromContext(ctx).Info("user signed out everywhere", "user_id", userID, "actor_id", admin.ID, "sessions", len(sessions))
+	return len(sessions), nil
+}
+
+// ActiveUsers lists users with live sessions, most recently active first
+func (s *AuthService) ActiveUsers(admin *domain.User) ([]ActiveUser, error) {
+	if admin == nil || !admin.CanManageUsers() {
+		return nil, ErrSessionForbidden
+	}
+	var result []ActiveUser
+	index := make(map[string]int)
+	for _, session := range s.sessionRepo.List() {
+		if session.ExpiredAt(s.now()) {
+			continue
+		}
+		i, seen := index[session.UserID]
+		if !seen {
+			user := s.userRepo.GetByID(session.UserID)
+			if user == nil {
+				continue
+			}
+			// Sessions are listed most recent first, so the first is the latest
+			i = len(result)
+			index[session.UserID] = i
+			result = append(result, ActiveUser{User: user, LastSeenAt: session.LastSeenAt})
+		}
+		result[i].Sessions++
+	}
+	return result, nil
+}
+
+// SweepExpiredSessions deletes sessions past their idle or absolute limit.
+// Expired sessions are also refused when presented, so sweeping only keeps
+// the store and the signed-in lists tidy.
+func (s *AuthService) SweepExpiredSessions() int {
+	removed := s.sessionRepo.DeleteExpired(s.now())
+	if removed > 0 {
+		slog.Debug("expired sessions swept", "removed", removed)
+	}
+	return removed
+}
+
 func generateToken() string {
 	b := make([]byte, 32)
 	rand.Read(b)
@@ -1,11 +1,18 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/statute"
+	"ncoe/internal/tracing"
 )
 
 type CaseRepository interface {
@@ -16,106 +23,508 @@ type CaseRepository interface {
 	List(typeFilter, statusFilter, query string) []*domain.Case
 	GetRecent(limit int) []*domain.Case
 	GetDocuments(caseID string) []*domain.Document
+	GetDocument(id string) *domain.Document
+	AddDocument(d *domain.Document) error
+	UpdateDocument(d *domain.Document) error
 	GetNotes(caseID string) []*domain.CaseNote
 	GetActivity(caseID string) []*domain.CaseActivity
+	AddActivity(a *domain.CaseActivity) error
+	RecentActivity(limit int) []*domain.CaseActivity
+	CountCases() domain.CaseCounts
+	CountSubmitted(from, to time.Time) int
+	CountResolved(from, to time.Time) int
 	GetDeadlines(limit int) []*domain.Deadline
 	GetAllDeadlines() []*domain.Deadline
-	SearchPublished(query, docType, year, topic string) []domain.PublishedOpinion
-	GetPublishedOpinion(caseNumber string) *domain.PublishedOpinion
-	NextCaseNumber(caseType domain.CaseType) string
+	NextCaseNumber(caseType domain.CaseType, prefix string) string
+}
+
+// ErrCaseForbidden is returned when a user without CanManageCases modifies case records
+var ErrCaseForbidden = errors.New("user is not permitted to manage cases")
+
+var (
+	// ErrCaseNotFound is returned when a case ID does not exist
+	ErrCaseNotFound = errors.New("case not found")
+	// ErrInvalidStatus is returned when a status is not a known CaseStatus
+	ErrInvalidStatus = errors.New("invalid case status")
+	// ErrPublishRequired is returned when a case is moved to published outside OpinionService.Publish
+	ErrPublishRequired = errors.New("cases must be published through the publish workflow")
+	// ErrCasePublished is returned when a published case is moved to another status
+	ErrCasePublished = errors.New("published cases keep their status while the opinion is public")
+	// ErrInvalidAssignee is returned when a case is assigned to someone who cannot work it
+	ErrInvalidAssignee = errors.New("assignee must be an active staff member who can manage cases")
+	// ErrInvalidDisposition is returned when a disposition is unknown or the case is not a complaint
+	ErrInvalidDisposition = errors.New("invalid complaint disposition")
+)
+
+// CasePolicy is the agency's rules for numbering cases and setting their
+// deadlines
+type CasePolicy struct {
+	Prefixes     map[domain.CaseType]string // Case number prefix, e.g. "AO" in AO-2024-042
+	ResponseDays map[domain.CaseType]int    // Business days allowed to respond; types without one get no deadline
+	ReminderDays []int                      // Days before a deadline that reminders go out
+}
+
+// DefaultCasePolicy is used when no agency policy is configured
+var DefaultCasePolicy = CasePolicy{
+	Prefixes: map[domain.CaseType]string{
+		domain.CaseTypeAdvisoryOpinion:      "AO",
+		domain.CaseTypeEthicsComplaint:      "EC",
+		domain.CaseTypeEthicsAcknowledgment: "EA",
+		domain.CaseTypePublicRecordsRequest: "PRR",
+	},
+	ResponseDays: map[domain.CaseType]int{
+		domain.CaseTypeAdvisoryOpinion:      45,
+		domain.CaseTypePublicRecordsRequest: 5,
+	},
+	ReminderDays: []int{30, 14, 7, 3, 1},
+}
+
+// prefix returns the case number prefix for a type, falling back to the
+// type itself
+func (p CasePolicy) prefix(t domain.CaseType) string {
+	if prefix := p.Prefixes[t]; prefix != "" {
+		return prefix
+	}
+	return string(t)
 }
 
 type CaseService struct {
-	repo CaseRepository
+	repo     CaseRepository
+	userRepo UserRepository
+	events   EventPublisher
+	policy   CasePolicy
+
+	mu              sync.Mutex
+	notifiedOverdue map[string]bool // Deadline ID and due date, so an extended deadline can go overdue again
+	reminded        map[string]bool // Deadline ID, due date and reminder day already announced
 }
 
-func NewCaseService(repo CaseRepository) *CaseService {
-	return &CaseService{repo: repo}
+func NewCaseService(repo CaseRepository, userRepo UserRepository, events EventPublisher, policy CasePolicy) *CaseService {
+	// Reminders are checked from the shortest notice up
+	policy.ReminderDays = append([]int(nil), policy.ReminderDays...)
+	sort.Ints(policy.ReminderDays)
+	return &CaseService{
+		repo:            repo,
+		userRepo:        userRepo,
+		events:          events,
+		policy:          policy,
+		notifiedOverdue: make(map[string]bool),
+		reminded:        make(map[string]bool),
+	}
 }
 
 // Create creates a new case and returns the case number
-func (s *CaseService) Create(c *domain.Case) (string, error) {
+func (s *CaseService) Create(ctx context.Context, c *domain.Case) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "CaseService.Create")
+	defer endSpan(span, &err)
+	repo := s.repoFor(ctx)
 	// Generate case number
-	c.CaseNumber = s.repo.NextCaseNumber(c.Type)
+	c.CaseNumber = repo.NextCaseNumber(c.Type, s.policy.prefix(c.Type))
 	c.ID = fmt.Sprintf("case_%d", time.Now().UnixNano())
 	c.CreatedAt = time.Now()
 	c.UpdatedAt = time.Now()
+	c.Citations = statute.ParseAll(c.StatuteCitations, c.Summary, c.Description)
 
 	// Calculate deadline based on case type
-	switch c.Type {
-	case domain.CaseTypeAdvisoryOpinion:
-		c.DueDate = calculateBusinessDays(c.SubmittedAt, 45)
-	case domain.CaseTypePublicRecordsRequest:
-		c.DueDate = calculateBusinessDays(c.SubmittedAt, 5)
+	if days := s.policy.ResponseDays[c.Type]; days > 0 {
+		c.DueDate = calculateBusinessDays(c.SubmittedAt, days)
 	}
 
-	if err := s.repo.Create(c); err != nil {
+	if err := repo.Create(c); err != nil {
 		return "", err
 	}
 
-	log.Printf("[CASE CREATED] ID=%s Number=%s Type=%s Submitter=%s", c.ID, c.CaseNumber, c.Type, c.SubmitterName)
+	logging.FromContext(ctx).Info("case created", "case_id", c.ID, "case_number", c.CaseNumber, "type", c.Type, "submitter_name", c.SubmitterName)
+	recordActivity(ctx, repo, c, nil, "created", "Case submitted", "", "")
+	s.events.Publish(ctx, domain.EventCaseCreated, newCaseEventData(c))
 	return c.CaseNumber, nil
 }
 
 // GetByID retrieves a case by ID
-func (s *CaseService) GetByID(id string) *domain.Case {
-	return s.repo.GetByID(id)
+func (s *CaseService) GetByID(ctx context.Context, id string) *domain.Case {
+	ctx, span := tracing.Start(ctx, "CaseService.GetByID")
+	span.SetAttributes("case_id", id)
+	defer span.End()
+	repo := s.repoFor(ctx)
+	return repo.GetByID(id)
 }
 
-// List returns cases with optional filters
-func (s *CaseService) List(typeFilter, statusFilter, query string) []*domain.Case {
-	return s.repo.List(typeFilter, statusFilter, query)
+// List returns cases with optional filters. The statute filter accepts a
+// section such as "281A.400" and matches any subsection of it.
+func (s *CaseService) List(ctx context.Context, typeFilter, statusFilter, query, statuteFilter string) []*domain.Case {
+	ctx, span := tracing.Start(ctx, "CaseService.List")
+	defer span.End()
+	repo := s.repoFor(ctx)
+	cases := repo.List(typeFilter, statusFilter, query)
+	if statuteFilter == "" {
+		return cases
+	}
+	section, ok := statute.ParseSection(statuteFilter)
+	if !ok {
+		return nil
+	}
+	var result []*domain.Case
+	for _, c := range cases {
+		if domain.CitesSection(c.Citations, section) {
+			result = append(result, c)
+		}
+	}
+	return result
 }
 
 // GetRecent returns the most recent cases
-func (s *CaseService) GetRecent(limit int) []*domain.Case {
-	return s.repo.GetRecent(limit)
+func (s *CaseService) GetRecent(ctx context.Context, limit int) []*domain.Case {
+	ctx, span := tracing.Start(ctx, "CaseService.GetRecent")
+	defer span.End()
+	repo := s.repoFor(ctx)
+	return repo.GetRecent(limit)
 }
 
 // GetDocuments returns documents for a case
-func (s *CaseService) GetDocuments(caseID string) []*domain.Document {
-	return s.repo.GetDocuments(caseID)
+func (s *CaseService) GetDocuments(ctx context.Context, caseID string) []*domain.Document {
+	ctx, span := tracing.Start(ctx, "CaseService.GetDocuments")
+	span.SetAttributes("case_id", caseID)
+	defer span.End()
+	repo := s.repoFor(ctx)
+	return repo.GetDocuments(caseID)
+}
+
+// GetDocument returns a single document by ID
+func (s *CaseService) GetDocument(ctx context.Context, id string) *domain.Document {
+	ctx, span := tracing.Start(ctx, "CaseService.GetDocument")
+	span.SetAttributes("document_id", id)
+	defer span.End()
+	repo := s.repoFor(ctx)
+	return repo.GetDocument(id)
+}
+
+// GetPublicDocument returns a released redacted document, or nil
+func (s *CaseService) GetPublicDocument(ctx context.Context, id string) *domain.Document {
+	ctx, span := tracing.Start(ctx, "CaseService.GetPublicDocument")
+	span.SetAttributes("document_id", id)
+	defer span.End()
+	repo := s.repoFor(ctx)
+	d := repo.GetDocument(id)
+	if d == nil || !d.IsPublic || !d.CanBePublic() {
+		return nil
+	}
+	return d
+}
+
+// AddDocument attaches an uploaded document to a case
+func (s *CaseService) AddDocument(ctx context.Context, user *domain.User, caseID string, d *domain.Document) (err error) {
+	ctx, span := tracing.Start(ctx, "CaseService.AddDocument")
+	span.SetAttributes("case_id", caseID)
+	defer endSpan(span, &err)
+	repo := s.repoFor(ctx)
+	if user == nil || !user.CanManageCases() {
+		return ErrCaseForbidden
+	}
+	c := repo.GetByID(caseID)
+	if c == nil {
+		return fmt.Errorf("case not found: %s", caseID)
+	}
+	if len(d.Content) == 0 {
+		return errors.New("document is empty")
+	}
+
+	now := time.Now()
+	d.ID = fmt.Sprintf("doc_%d", now.UnixNano())
+	d.CaseID = caseID
+	d.Size = int64(len(d.Content))
+	d.IsPublic = false
+	d.UploadedBy = user.ID
+	d.UploadedAt = now
+	if d.Category == "" {
+		d.Category = "correspondence"
+	}
+	if err := repo.AddDocument(d); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("document uploaded", "case_id", caseID, "document_id", d.ID, "file", d.Filename)
+	recordActivity(ctx, repo, c, user, "document_added", "Document added: "+d.Filename, "", d.ID)
+	return nil
 }
 
 // GetNotes returns notes for a case
-func (s *CaseService) GetNotes(caseID string) []*domain.CaseNote {
-	return s.repo.GetNotes(caseID)
+func (s *CaseService) GetNotes(ctx context.Context, caseID string) []*domain.CaseNote {
+	ctx, span := tracing.Start(ctx, "CaseService.GetNotes")
+	span.SetAttributes("case_id", caseID)
+	defer span.End()
+	repo := s.repoFor(ctx)
+	return repo.GetNotes(caseID)
 }
 
 // GetActivity returns activity log for a case
-func (s *CaseService) GetActivity(caseID string) []*domain.CaseActivity {
-	return s.repo.GetActivity(caseID)
+func (s *CaseService) GetActivity(ctx context.Context, caseID string) []*domain.CaseActivity {
+	ctx, span := tracing.Start(ctx, "CaseService.GetActivity")
+	span.SetAttributes("case_id", caseID)
+	defer span.End()
+	repo := s.repoFor(ctx)
+	return repo.GetActivity(caseID)
 }
 
 // GetUpcomingDeadlines returns upcoming deadlines
-func (s *CaseService) GetUpcomingDeadlines(limit int) []*domain.Deadline {
-	return s.repo.GetDeadlines(limit)
+func (s *CaseService) GetUpcomingDeadlines(ctx context.Context, limit int) []*domain.Deadline {
+	ctx, span := tracing.Start(ctx, "CaseService.GetUpcomingDeadlines")
+	defer span.End()
+	repo := s.repoFor(ctx)
+	return repo.GetDeadlines(limit)
 }
 
 // GetAllDeadlines returns all deadlines
-func (s *CaseService) GetAllDeadlines() []*domain.Deadline {
-	return s.repo.GetAllDeadlines()
+func (s *CaseService) GetAllDeadlines(ctx context.Context) []*domain.Deadline {
+	ctx, span := tracing.Start(ctx, "CaseService.GetAllDeadlines")
+	defer span.End()
+	repo := s.repoFor(ctx)
+	return repo.GetAllDeadlines()
 }
 
-// SearchPublished searches published opinions
-func (s *CaseService) SearchPublished(query, docType, year, topic string) []domain.PublishedOpinion {
-	return s.repo.SearchPublished(query, docType, year, topic)
+// UpdateStatus updates the status of a case
+func (s *CaseService) UpdateStatus(ctx context.Context, user *domain.User, caseID string, status domain.CaseStatus) (err error) {
+	ctx, span := tracing.Start(ctx, "CaseService.UpdateStatus")
+	span.SetAttributes("case_id", caseID, "status", string(status))
+	defer endSpan(span, &err)
+	repo := s.repoFor(ctx)
+	if user == nil || !user.CanManageCases() {
+		return ErrCaseForbidden
+	}
+	if !status.IsValid() {
+		return fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+	}
+	c := repo.GetByID(caseID)
+	if c == nil {
+		return fmt.Errorf("%w: %s", ErrCaseNotFound, caseID)
+	}
+	// Publication goes through OpinionService.Publish so the opinion record exists
+	if status == domain.StatusPublished && c.Status != domain.StatusPublished {
+		return fmt.Errorf("%w: %s", ErrPublishRequired, c.CaseNumber)
+	}
+	// Moving it on would leave its opinion and document public
+	if c.Status == domain.StatusPublished && status != domain.StatusPublished {
+		return fmt.Errorf("%w: %s", ErrCasePublished, c.CaseNumber)
+	}
+	if c.Status == status {
+		return nil
+	}
+	now := time.Now()
+	previous := c.Status
+	c.Status = status
+	c.UpdatedAt = now
+	switch {
+	case status != domain.StatusClosed && status != domain.StatusWithdrawn:
+		c.ClosedAt = nil // Reopened
+	case c.ClosedAt == nil:
+		c.ClosedAt = &now
+	}
+	if err := repo.Update(c); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("case status changed", "case_number", c.CaseNumber, "from", previous, "to", status, "actor_id", user.ID)
+	recordActivity(ctx, repo, c, user, "status_changed", "Status changed to "+strings.ReplaceAll(string(status), "_", " "), string(previous), string(status))
+	data := newCaseEventData(c)
+	data.PreviousStatus = string(previous)
+	s.events.Publish(ctx, domain.EventCaseStatusChanged, data)
+	return nil
 }
 
-// GetPublishedOpinion retrieves a published opinion
-func (s *CaseService) GetPublishedOpinion(caseNumber string) *domain.PublishedOpinion {
-	return s.repo.GetPublishedOpinion(caseNumber)
+// Assign makes a staff member responsible for a case
+func (s *CaseService) Assign(ctx context.Context, user *domain.User, caseID, assigneeID string) (err error) {
+	ctx, span := tracing.Start(ctx, "CaseService.Assign")
+	span.SetAttributes("case_id", caseID)
+	defer endSpan(span, &err)
+	repo := s.repoFor(ctx)
+	if user == nil || !user.CanManageCases() {
+		return ErrCaseForbidden
+	}
+	c := repo.GetByID(caseID)
+	if c == nil {
+		return fmt.Errorf("%w: %s", ErrCaseNotFound, caseID)
+	}
+	var assignee *domain.User
+	if assigneeID != "" {
+		assignee = s.usersFor(ctx).GetByID(assigneeID)
+	}
+	if assignee == nil || !assignee.IsActive || !assignee.CanManageCases() {
+		return fmt.Errorf("%w: %q", ErrInvalidAssignee, assigneeID)
+	}
+	if c.AssignedTo == assignee.ID {
+		return nil
+	}
+
+	previous := c.AssignedTo
+	c.AssignedTo = assignee.ID
+	c.AssignedToName = assignee.FullName()
+	c.UpdatedAt = time.Now()
+	if err := repo.Update(c); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("case assigned", "case_number", c.CaseNumber, "assignee_id", assignee.ID, "actor_id", user.ID)
+	recordActivity(ctx, repo, c, user, "assigned", "Assigned to "+assignee.FullName(), previous, assignee.ID)
+	data := newCaseEventData(c)
+	data.PreviousAssignedTo = previous
+	s.events.Publish(ctx, domain.EventCaseAssigned, data)
+	return nil
 }
 
-// UpdateStatus updates the status of a case
-func (s *CaseService) UpdateStatus(caseID string, status domain.CaseStatus) error {
-	c := s.repo.GetByID(caseID)
+// SetDisposition records how an ethics complaint was resolved. An empty
+// disposition clears it.
+func (s *CaseService) SetDisposition(ctx context.Context, user *domain.User, caseID string, disposition domain.Disposition) (err error) {
+	ctx, span := tracing.Start(ctx, "CaseService.SetDisposition")
+	span.SetAttributes("case_id", caseID)
+	defer endSpan(span, &err)
+	repo := s.repoFor(ctx)
+	if user == nil || !user.CanManageCases() {
+		return ErrCaseForbidden
+	}
+	c := repo.GetByID(caseID)
 	if c == nil {
-		return fmt.Errorf("case not found: %s", caseID)
+		return fmt.Errorf("%w: %s", ErrCaseNotFound, caseID)
 	}
-	c.Status = status
+	if c.Type != domain.CaseTypeEthicsComplaint {
+		return fmt.Errorf("%w: %s is not an ethics complaint", ErrInvalidDisposition, c.CaseNumber)
+	}
+	if disposition != "" && !disposition.IsValid() {
+		return fmt.Errorf("%w: %q", ErrInvalidDisposition, disposition)
+	}
+	if c.Disposition == disposition {
+		return nil
+	}
+
+	previous := c.Disposition
+	c.Disposition = disposition
 	c.UpdatedAt = time.Now()
-	return s.repo.Update(c)
+	if err := repo.Update(c); err != nil {
+		return err
+	}
+
+	description := "Disposition cleared"
+	if disposition != "" {
+		description = "Disposition: " + disposition.Label()
+	}
+	logging.FromContext(ctx).Info("case disposition set", "case_number", c.CaseNumber, "disposition", disposition, "actor_id", user.ID)
+	recordActivity(ctx, repo, c, user, "disposition", description, string(previous), string(disposition))
+	return nil
+}
+
+// NotifyOverdueDeadlines publishes a deadline.overdue event for each deadline
+// that has passed since the last check and returns how many were published.
+// Each deadline is announced once for the life of the process.
+func (s *CaseService) NotifyOverdueDeadlines(ctx context.Context) int {
+	ctx, span := tracing.Start(ctx, "CaseService.NotifyOverdueDeadlines")
+	defer span.End()
+	repo := s.repoFor(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, d := range repo.GetAllDeadlines() {
+		if !d.IsOverdue() {
+			continue
+		}
+		key := d.ID + "@" + d.DueDate.Format(time.RFC3339)
+		if s.notifiedOverdue[key] {
+			continue
+		}
+		s.notifiedOverdue[key] = true
+		s.events.Publish(ctx, domain.EventDeadlineOverdue, deadlineEventData{
+			ID:          d.ID,
+			CaseID:      d.CaseID,
+			CaseNumber:  d.CaseNumber,
+			CaseType:    string(d.CaseType),
+			Type:        d.Type,
+			DueDate:     d.DueDate.UTC(),
+			DaysOverdue: -d.DaysUntilDue(),
+		})
+		count++
+	}
+	if count > 0 {
+		logging.FromContext(ctx).Info("overdue deadlines notified", "count", count)
+	}
+	return count
+}
+
+// NotifyDeadlineReminders publishes a deadline.reminder event for each open
+// deadline that has reached a day on the reminder schedule since the last
+// check, and returns how many were published. A deadline already inside
+// several reminder days is announced once, for the nearest.
+func (s *CaseService) NotifyDeadlineReminders(ctx context.Context) int {
+	ctx, span := tracing.Start(ctx, "CaseService.NotifyDeadlineReminders")
+	defer span.End()
+	repo := s.repoFor(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, d := range repo.GetAllDeadlines() {
+		if d.IsOverdue() || d.CompletedAt != nil {
+			continue
+		}
+		days := d.DaysUntilDue()
+		i := sort.SearchInts(s.policy.ReminderDays, days)
+		if i == len(s.policy.ReminderDays) {
+			continue
+		}
+		reminder := s.policy.ReminderDays[i]
+		key := fmt.Sprintf("%s@%s@%d", d.ID, d.DueDate.Format(time.RFC3339), reminder)
+		if s.reminded[key] {
+			continue
+		}
+		s.reminded[key] = true
+		s.events.Publish(ctx, domain.EventDeadlineReminder, deadlineReminderData{
+			ID:           d.ID,
+			CaseID:       d.CaseID,
+			CaseNumber:   d.CaseNumber,
+			CaseType:     string(d.CaseType),
+			Type:         d.Type,
+			DueDate:      d.DueDate.UTC(),
+			DaysUntilDue: days,
+			ReminderDays: reminder,
+		})
+		count++
+	}
+	if count > 0 {
+		logging.FromContext(ctx).Info("deadline reminders notified", "count", count)
+	}
+	return count
+}
+
+// repoFor returns the case repository for a CaseService method, tracing
+// each call as a child of the method's span in ctx
+func (s *CaseService) repoFor(ctx context.Context) CaseRepository {
+	return tracedCaseRepository{ctx: ctx, repo: s.repo}
+}
+
+// usersFor returns the user repository traced like repoFor's
+func (s *CaseService) usersFor(ctx context.Context) UserRepository {
+	return tracedUserRepository{ctx: ctx, repo: s.userRepo}
+}
+
+// recordActivity adds an entry to the case's timeline. user is nil for
+// changes without a signed-in actor. A failure is logged rather than
+// returned, since the change itself has already been saved.
+func recordActivity(ctx context.Context, repo CaseRepository, c *domain.Case, user *domain.User, action, description, oldValue, newValue string) {
+	now := time.Now()
+	a := &domain.CaseActivity{
+		ID:          fmt.Sprintf("act_%d", now.UnixNano()),
+		CaseID:      c.ID,
+		CaseNumber:  c.CaseNumber,
+		Action:      action,
+		Description: description,
+		OldValue:    oldValue,
+		NewValue:    newValue,
+		CreatedAt:   now,
+	}
+	if user != nil {
+		a.UserID = user.ID
+		a.UserName = user.FullName()
+	}
+	if err := repo.AddActivity(a); err != nil {
+		logging.FromContext(ctx).Error("recording case activity", "case_number", c.CaseNumber, "action", action, "error", err)
+	}
 }
 
 // calculateBusinessDays adds business days to a date
@@ -1,9 +1,11 @@
 package service
 
 import (
-	"ncoe/internal/domain"
 	"sort"
 	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/metrics"
 )
 
 type DashboardService struct {
@@ -14,86 +16,78 @@ func NewDashboardService(caseRepo CaseRepository) *DashboardService {
 	return &DashboardService{caseRepo: caseRepo}
 }
 
-// GetStats returns dashboard statistics pulled from the actual repository
+// GetStats returns dashboard statistics from the repository's aggregate
+// counts. Year-to-date counts start on January 1 and fiscal-year counts on
+// July 1, at midnight in the agency's time zone.
 func (s *DashboardService) GetStats() *domain.CaseStats {
-	// Get all cases from repository
-	allCases := s.caseRepo.List("", "", "")
-
-	// Calculate stats from actual data
-	totalOpen := 0
-	totalPending := 0
-	totalOverdue := 0
-	totalClosed := 0
-	byType := map[string]int{}
-	byStatus := map[string]int{}
-
-	for _, c := range allCases {
-		// Count by type
-		byType[string(c.Type)]++
-
-		// Count by status
-		byStatus[string(c.Status)]++
+	now := time.Now().In(domain.Location)
+	counts := s.caseRepo.CountCases()
 
-		// Count categories
-		switch c.Status {
-		case domain.StatusClosed:
-			totalClosed++
-		case domain.StatusSubmitted:
-			totalPending++
-			totalOpen++
+	stats := &domain.CaseStats{
+		TotalOverdue: counts.Overdue,
+		TotalPending: counts.ByStatus[domain.StatusSubmitted],
+		FiscalYear:   domain.FiscalYear(now),
+		ByType:       make(map[string]int, len(counts.ByType)),
+		ByStatus:     make(map[string]int, len(counts.ByStatus)),
+	}
+	for t, n := range counts.ByType {
+		stats.ByType[string(t)] = n
+	}
+	for status, n := range counts.ByStatus {
+		stats.ByStatus[string(status)] = n
+		switch status {
+		case domain.StatusPublished, domain.StatusClosed, domain.StatusWithdrawn:
+			stats.TotalClosed += n
 		default:
-			totalOpen++
-		}
-
-		// Check overdue
-		if c.IsOverdue() {
-			totalOverdue++
+			stats.TotalOpen += n
 		}
 	}
 
-	// Get recent cases (sorted by submission date, newest first)
-	recentCases := make([]domain.Case, 0, 5)
-	sortedCases := make([]*domain.Case, len(allCases))
-	copy(sortedCases, allCases)
-	sort.Slice(sortedCases, func(i, j int) bool {
-		return sortedCases[i].SubmittedAt.After(sortedCases[j].SubmittedAt)
-	})
-	for i, c := range sortedCases {
-		if i >= 5 {
-			break
-		}
-		recentCases = append(recentCases, *c)
-	}
+	// Windows end just after now, so a case resolved this instant counts
+	end := now.Add(time.Nanosecond)
+	yearStart := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, domain.Location)
+	fiscalStart := domain.FiscalYearStart(stats.FiscalYear, domain.Location)
+	stats.OpenedYTD = s.caseRepo.CountSubmitted(yearStart, end)
+	stats.ClosedYTD = s.caseRepo.CountResolved(yearStart, end)
+	stats.OpenedFiscalYear = s.caseRepo.CountSubmitted(fiscalStart, end)
+	stats.ClosedFiscalYear = s.caseRepo.CountResolved(fiscalStart, end)
 
-	// Get upcoming deadlines from repository
-	deadlines := s.caseRepo.GetDeadlines(5)
-	upcomingDeadlines := make([]domain.Deadline, 0, len(deadlines))
-	for _, d := range deadlines {
-		upcomingDeadlines = append(upcomingDeadlines, *d)
+	for _, c := range s.caseRepo.GetRecent(5) {
+		stats.RecentCases = append(stats.RecentCases, *c)
 	}
-
-	// Sort deadlines by due date
-	sort.Slice(upcomingDeadlines, func(i, j int) bool {
-		return upcomingDeadlines[i].DueDate.Before(upcomingDeadlines[j].DueDate)
-	})
-
-	// Add base counts to make dashboard look realistic (seeded + dynamic)
-	// These represent "historical" cases not in the current demo data
-	baseStats := map[string]int{
-		"totalOpen":   32,
-		"totalClosed": 150,
+	for _, a := range s.caseRepo.RecentActivity(10) {
+		stats.RecentActivity = append(stats.RecentActivity, *a)
+	}
+	for _, d := range s.caseRepo.GetDeadlines(5) {
+		stats.UpcomingDeadlines = append(stats.UpcomingDeadlines, *d)
 	}
+	return stats
+}
 
-	return &domain.CaseStats{
-		TotalOpen:         totalOpen + baseStats["totalOpen"],
-		TotalPending:      totalPending,
-		TotalOverdue:      totalOverdue,
-		TotalClosed:       totalClosed + baseStats["totalClosed"],
-		ByType:            byType,
-		ByStatus:          byStatus,
-		RecentCases:       recentCases,
-		UpcomingDeadlines: upcomingDeadlines,
+// Gauges returns case counts for the /metrics endpoint: cases by type and
+// status, and overdue deadlines (open cases past their due date)
+func (s *DashboardService) Gauges() []metrics.Gauge {
+	counts := s.caseRepo.CountCases()
+	cases := metrics.Gauge{
+		Name:   "ncoe_cases",
+		Help:   "Cases by type and status.",
+		Labels: []string{"type", "status"},
+	}
+	for t, byStatus := range counts.ByTypeStatus {
+		for status, n := range byStatus {
+			cases.Samples = append(cases.Samples, metrics.Sample{Values: []string{string(t), string(status)}, Value: float64(n)})
+		}
+	}
+	sort.Slice(cases.Samples, func(i, j int) bool {
+		a, b := cases.Samples[i].Values, cases.Samples[j].Values
+		return a[0] < b[0] || a[0] == b[0] && a[1] < b[1]
+	})
+	overdue := metrics.Gauge{
+		Name:    "ncoe_deadlines_overdue",
+		Help:    "Open cases past their response deadline.",
+		Samples: []metrics.Sample{{Value: float64(counts.Overdue)}},
 	}
+	return []metrics.Gauge{cases, overdue}
 }
 
 // GetDeadlineStatus returns the status string for a deadline
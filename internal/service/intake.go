@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/ratelimit"
+)
+
+// HeldSubmissionRepository stores public submissions held for staff triage
+type HeldSubmissionRepository interface {
+	Create(s *domain.HeldSubmission) error
+	GetByID(id string) *domain.HeldSubmission
+	Update(s *domain.HeldSubmission) error
+	List(status domain.TriageStatus) []*domain.HeldSubmission
+}
+
+// Form fields the intake checks read. The honeypot is hidden from people,
+// so only a bot filling in every field completes it.
+const (
+	FormTokenField = "form_token"
+	HoneypotField  = "website"
+	ChallengeField = "challenge_response"
+)
+
+// Challenge is a human-verification check, such as a CAPTCHA service,
+// added to the public forms. Widget is the HTML placed in each form; the
+// browser posts its answer in ChallengeField for Verify.
+type Challenge interface {
+	Widget() template.HTML
+	Verify(response, remoteIP string) error
+}
+
+// IntakePolicy sets the limits on anonymous public submissions
+type IntakePolicy struct {
+	PerIP    ratelimit.Rate // Submissions from one address; the zero Rate is unlimited
+	PerEmail ratelimit.Rate // Submissions giving one email address
+	// MinFillTime holds submissions sent sooner than this after the form
+	// loaded, as no person fills one in that fast; zero skips the check
+	MinFillTime time.Duration
+}
+
+// DefaultIntakePolicy allows a handful of submissions an hour from one
+// address, enough for an office behind a shared address
+var DefaultIntakePolicy = IntakePolicy{
+	PerIP:       ratelimit.Rate{Count: 10, Per: time.Hour},
+	PerEmail:    ratelimit.Rate{Count: 5, Per: time.Hour},
+	MinFillTime: 3 * time.Second,
+}
+
+var (
+	// ErrRateLimited is returned when a submitter has sent too many submissions
+	ErrRateLimited = errors.New("too many submissions; please wait and try again")
+	// ErrAlreadyReviewed is returned when triaging a submission twice
+	ErrAlreadyReviewed = errors.New("this submission has already been reviewed")
+	// ErrHeldNotFound is returned for an unknown held submission
+	ErrHeldNotFound = errors.New("held submission not found")
+)
+
+// Submission is a public form submission and what the intake checks need
+// to know about it
+type Submission struct {
+	Case              *domain.Case
+	IP                string
+	FormToken         string
+	Honeypot          string
+	ChallengeResponse string
+}
+
+// IntakeResult says what became of a submission
+type IntakeResult struct {
+	CaseNumber string        // Set when a case was opened
+	Held       bool          // Held for triage instead
+	RetryAfter time.Duration // With ErrRateLimited, when to try again
+}
+
+// IntakeService screens anonymous public submissions before they become
+// cases. Submitters over the rate limits are turned away; submissions that
+// look automated are held for staff triage rather than dropped, and get a
+// case number only if staff accept them.
+type IntakeService struct {
+	cases     *CaseService
+	repo      HeldSubmissionRepository
+	challenge Challenge
+	policy    IntakePolicy
+	perIP     *ratelimit.Limiter
+	perEmail  *ratelimit.Limiter
+	key       []byte // Signs form tokens
+
+	mu sync.Mutex // Serializes triage, so a submission becomes one case at most
+}
+
+// NewIntakeService creates an intake service; challenge may be nil
+func NewIntakeService(cases *CaseService, repo HeldSubmissionRepository, challenge Challenge, policy IntakePolicy) *IntakeService {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &IntakeService{
+		cases:     cases,
+		repo:      repo,
+		challenge: challenge,
+		policy:    policy,
+		perIP:     ratelimit.New(policy.PerIP),
+		perEmail:  ratelimit.New(policy.PerEmail),
+		key:       key,
+	}
+}
+
+// FormToken returns a signed token recording when a form was served, for
+// the FormTokenField hidden input
+func (s *IntakeService) FormToken() string {
+	issued := strconv.FormatInt(time.Now().UnixNano(), 36)
+	return issued + "." + s.sign(issued)
+}
+
+// ChallengeWidget returns the challenge HTML for the forms, if any
+func (s *IntakeService) ChallengeWidget() template.HTML {
+	if s.challenge == nil {
+		return ""
+	}
+	return s.challenge.Widget()
+}
+
+// Submit screens a submission and opens a case for it, holds it for
+// triage, or returns ErrRateLimited
+func (s *IntakeService) Submit(ctx context.Context, sub Submission) (IntakeResult, error) {
+	if ok, wait := s.perIP.Allow(sub.IP); !ok {
+		logging.FromContext(ctx).Warn("submission rate limited", "limit", "ip", "submitter_ip", sub.IP, "type", sub.Case.Type)
+		return IntakeResult{RetryAfter: wait}, ErrRateLimited
+	}
+	if email := strings.ToLower(strings.TrimSpace(sub.Case.SubmitterEmail)); email != "" {
+		if ok, wait := s.perEmail.Allow(email); !ok {
+			logging.FromContext(ctx).Warn("submission rate limited", "limit", "email", "submitter_email", email, "submitter_ip", sub.IP, "type", sub.Case.Type)
+			return IntakeResult{RetryAfter: wait}, ErrRateLimited
+		}
+	}
+
+	if reasons := s.screen(sub); len(reasons) > 0 {
+		held := &domain.HeldSubmission{
+			Case:       *sub.Case,
+			Reasons:    reasons,
+			IP:         sub.IP,
+			ReceivedAt: time.Now(),
+			Status:     domain.TriagePending,
+		}
+		if err := s.repo.Create(held); err != nil {
+			return IntakeResult{}, err
+		}
+		logging.FromContext(ctx).Warn("submission held for triage", "held_id", held.ID, "type", sub.Case.Type, "submitter_ip", sub.IP, "reasons", reasons)
+		return IntakeResult{Held: true}, nil
+	}
+
+	number, err := s.cases.Create(ctx, sub.Case)
+	if err != nil {
+		return IntakeResult{}, err
+	}
+	return IntakeResult{CaseNumber: number}, nil
+}
+
+// screen returns the reasons a submission looks automated, if any
+func (s *IntakeService) screen(sub Submission) []string {
+	var reasons []string
+	if strings.TrimSpace(sub.Honeypot) != "" {
+		reasons = append(reasons, "hidden honeypot field was filled in")
+	}
+	if s.policy.MinFillTime > 0 {
+		issued, ok := s.verifyToken(sub.FormToken)
+		switch elapsed := time.Since(issued); {
+		case !ok:
+			reasons = append(reasons, "form token missing or invalid")
+		case elapsed < s.policy.MinFillTime:
+			reasons = append(reasons, fmt.Sprintf("submitted %s after the form loaded", elapsed.Round(time.Millisecond)))
+		}
+	}
+	if s.challenge != nil {
+		if err := s.challenge.Verify(sub.ChallengeResponse, sub.IP); err != nil {
+			reasons = append(reasons, "challenge failed: "+err.Error())
+		}
+	}
+	return reasons
+}
+
+// verifyToken returns when a form token was issued, if it is genuine
+func (s *IntakeService) verifyToken(token string) (time.Time, bool) {
+	issued, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(issued))) {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(issued, 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+func (s *IntakeService) sign(value string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Held returns held submissions with the status, newest first; an empty
+// status returns them all
+func (s *IntakeService) Held(status domain.TriageStatus) []*domain.HeldSubmission {
+	return s.repo.List(status)
+}
+
+// GetHeld returns a held submission, or nil
+func (s *IntakeService) GetHeld(id string) *domain.HeldSubmission {
+	return s.repo.GetByID(id)
+}
+
+// PendingCount returns the number of submissions awaiting triage
+func (s *IntakeService) PendingCount() int {
+	return len(s.repo.List(domain.TriagePending))
+}
+
+// Accept opens a case for a held submission, dated when it was received,
+// and returns the case number
+func (s *IntakeService) Accept(ctx context.Context, user *domain.User, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held, err := s.reviewable(user, id)
+	if err != nil {
+		return "", err
+	}
+	c := held.Case
+	c.SubmittedAt = held.ReceivedAt
+	number, err := s.cases.Create(ctx, &c)
+	if err != nil {
+		return "", err
+	}
+	held.CaseID, held.CaseNumber = c.ID, number
+	if err := s.review(ctx, user, held, domain.TriageAccepted); err != nil {
+		return "", err
+	}
+	return number, nil
+}
+
+// Reject marks a held submission as spam. It is kept, not deleted.
+func (s *IntakeService) Reject(ctx context.Context, user *domain.User, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held, err := s.reviewable(user, id)
+	if err != nil {
+		return err
+	}
+	return s.review(ctx, user, held, domain.TriageRejected)
+}
+
+func (s *IntakeService) reviewable(user *domain.User, id string) (*domain.HeldSubmission, error) {
+	if user == nil || !user.CanManageCases() {
+		return nil, ErrCaseForbidden
+	}
+	held := s.repo.GetByID(id)
+	if held == nil {
+		return nil, ErrHeldNotFound
+	}
+	if held.Status != domain.TriagePending {
+		return nil, ErrAlreadyReviewed
+	}
+	return held, nil
+}
+
+func (s *IntakeService) review(ctx context.Context, user *domain.User, held *domain.HeldSubmission, status domain.TriageStatus) error {
+	held.Status = status
+	held.ReviewedBy = user.ID
+	held.ReviewedByName = user.FullName()
+	held.ReviewedAt = time.Now()
+	if err := s.repo.Update(held); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("held submission reviewed", "held_id", held.ID, "status", status, "actor_id", user.ID, "case_number", held.CaseNumber)
+	return nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/mail"
+	"ncoe/internal/metrics"
+	"ncoe/internal/tracing"
+)
+
+// Email templates, the template label on the email counters
+const (
+	EmailInvitation    = "invitation"
+	EmailPasswordReset = "password_reset"
+)
+
+var (
+	emailsSent   = metrics.NewCounter("ncoe_emails_sent_total", "Emails handed to the SMTP relay, by template.", "template")
+	emailsFailed = metrics.NewCounter("ncoe_emails_failed_total", "Emails the SMTP relay refused or could not be reached for, by template.", "template")
+)
+
+// MailSender delivers a message, such as *mail.SMTP
+type MailSender interface {
+	Send(ctx context.Context, msg mail.Message) error
+}
+
+// MailService emails staff their invitation and password reset links
+type MailService struct {
+	sender MailSender
+	from   string
+}
+
+func NewMailService(sender MailSender, from string) *MailService {
+	return &MailService{sender: sender, from: from}
+}
+
+// SendPasswordLink emails user the link to choose a password. template is
+// EmailInvitation or EmailPasswordReset.
+func (s *MailService) SendPasswordLink(ctx context.Context, template string, user *domain.User, subject, link string) error {
+	ctx, span := tracing.Start(ctx, "MailService.SendPasswordLink")
+	defer span.End()
+	span.SetAttributes("template", template)
+
+	err := s.sender.Send(ctx, mail.Message{
+		From:    s.from,
+		To:      user.Email,
+		Subject: subject,
+		Body:    PasswordLinkBody(user, link),
+	})
+	if err != nil {
+		emailsFailed.Inc(template)
+		span.RecordError(err)
+		logging.FromContext(ctx).Error("sending email", "template", template, "to_user_id", user.ID, "error", err)
+		return err
+	}
+	emailsSent.Inc(template)
+	logging.FromContext(ctx).Info("email sent", "template", template, "to_user_id", user.ID)
+	return nil
+}
+
+// PasswordLinkBody is the text of an invitation or reset email
+func PasswordLinkBody(user *domain.User, link string) string {
+	return "Hello " + user.FirstName + ",\n\nUse this link to choose your password:\n\n" + link + "\n"
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/totp"
+)
+
+type MFARepository interface {
+	Get(userID string) *domain.MFAEnrollment
+	Save(e *domain.MFAEnrollment) error
+	Delete(userID string) error
+	List() []*domain.MFAEnrollment
+	GetPolicy() *domain.MFAPolicy
+	SavePolicy(p *domain.MFAPolicy) error
+}
+
+// RecoveryCodeCount is how many single-use recovery codes a user is issued
+const RecoveryCodeCount = 10
+
+var (
+	// ErrMFAInvalidCode is returned for wrong, expired or reused codes
+	ErrMFAInvalidCode = errors.New("invalid authentication code")
+	// ErrMFANotEnrolled is returned when the user has no confirmed authenticator
+	ErrMFANotEnrolled = errors.New("two-factor authentication is not set up")
+	// ErrMFAAlreadyEnrolled is returned when enrolling a second authenticator
+	ErrMFAAlreadyEnrolled = errors.New("two-factor authentication is already set up")
+	// ErrMFARequired is returned when turning off a second factor the user's role requires
+	ErrMFARequired = errors.New("two-factor authentication is required for your role")
+	// ErrMFAForbidden is returned when a non-admin changes another user's enrollment or the policy
+	ErrMFAForbidden = errors.New("only administrators can manage two-factor authentication")
+)
+
+// MFAAccount is an enrolled user, as listed for admins
+type MFAAccount struct {
+	User              *domain.User
+	Enrollment        *domain.MFAEnrollment
+	RecoveryCodesLeft int
+}
+
+// MFAService manages TOTP enrollment, second-factor checks and the policy
+// of which roles must use a second factor
+type MFAService struct {
+	repo          MFARepository
+	userRepo      UserRepository
+	sessionRepo   SessionRepository
+	audit         *AuditService
+	issuer        string // Account label shown in authenticator apps
+	defaultPolicy domain.MFAPolicy
+
+	mu sync.Mutex // Serializes code checks, so a code cannot be used twice concurrently
+}
+
+// NewMFAService returns the service. requiredRoles is the policy in force
+// until an admin changes it; unknown role names are an error.
+func NewMFAService(repo MFARepository, userRepo UserRepository, sessionRepo SessionRepository, audit *AuditService, issuer string, requiredRoles []string) (*MFAService, error) {
+	var roles []domain.Role
+	for _, name := range requiredRoles {
+		role := domain.Role(name)
+		if !role.IsValid() {
+			return nil, fmt.Errorf("mfa: unknown role %q", name)
+		}
+		roles = append(roles, role)
+	}
+	return &MFAService{
+		repo:          repo,
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+		audit:         audit,
+		issuer:        issuer,
+		defaultPolicy: domain.MFAPolicy{RequiredRoles: roles},
+	}, nil
+}
+
+// Policy returns the roles that must use a second factor
+func (s *MFAService) Policy() domain.MFAPolicy {
+	if p := s.repo.GetPolicy(); p != nil {
+		return *p
+	}
+	return s.defaultPolicy
+}
+
+// SetRequiredRoles replaces the policy. Users in a newly covered role who have
+// not enrolled are asked to enroll at their next login.
+func (s *MFAService) SetRequiredRoles(ctx context.Context, admin *domain.User, roles []domain.Role) error {
+	if admin == nil || !admin.CanManageUsers() {
+		return ErrMFAForbidden
+	}
+	for _, r := range roles {
+		if !r.IsValid() {
+			return fmt.Errorf("unknown role: %q", r)
+		}
+	}
+	// Stored in privilege order, without duplicates
+	var required []domain.Role
+	for _, role := range domain.Roles {
+		for _, r := range roles {
+			if r == role {
+				required = append(required, role)
+				break
+			}
+		}
+	}
+	if err := s.repo.SavePolicy(&domain.MFAPolicy{RequiredRoles: required, UpdatedBy: admin.ID, UpdatedAt: time.Now()}); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("MFA policy changed", "required_roles", required, "actor_id", admin.ID)
+	return nil
+}
+
+// Required returns true if the user's role must use a second factor
+func (s *MFAService) Required(user *domain.User) bool {
+	return user != nil && s.Policy().Requires(user.Role)
+}
+
+// Enrollment returns the user's confirmed enrollment, or nil
+func (s *MFAService) Enrollment(userID string) *domain.MFAEnrollment {
+	if e := s.repo.Get(userID); e != nil && e.IsConfirmed() {
+		return e
+	}
+	return nil
+}
+
+// BeginEnrollment returns a secret for the user's authenticator and its
+// provisioning URI. An unconfirmed enrollment is reused, so reloading the
+// page does not invalidate a QR code the user already scanned.
+func (s *MFAService) BeginEnrollment(user *domain.User) (secret, uri string, err error) {
+	e := s.repo.Get(user.ID)
+	if e != nil && e.IsConfirmed() {
+		return "", "", ErrMFAAlreadyEnrolled
+	}
+	if e == nil {
+		e = &domain.MFAEnrollment{
+			UserID:    user.ID,
+			Secret:    totp.GenerateSecret(),
+			CreatedAt: time.Now(),
+		}
+		if err := s.repo.Save(e); err != nil {
+			return "", "", err
+		}
+	}
+	return e.Secret, totp.ProvisioningURI(s.issuer, user.Email, e.Secret), nil
+}
+
+// ConfirmEnrollment turns the second factor on once the user enters a code
+// from their authenticator, and returns their recovery codes. The codes are
+// only ever returned here and by RegenerateRecoveryCodes.
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, user *domain.User, code string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.repo.Get(user.ID)
+	if e == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if e.IsConfirmed() {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+	if !s.checkTOTP(e, code) {
+		return nil, ErrMFAInvalidCode
+	}
+
+	codes, hashes := newRecoveryCodes()
+	now := time.Now()
+	e.RecoveryCodes = hashes
+	e.ConfirmedAt = &now
+	e.LastUsedAt = &now
+	if err := s.repo.Save(e); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("MFA enrolled", "user_id", user.ID)
+	return codes, nil
+}
+
+// Verify checks a second-factor code for the user: a current TOTP code, or
+// one of their recovery codes, which is then used up
+func (s *MFAService) Verify(ctx context.Context, userID, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.Enrollment(userID)
+	if e == nil {
+		return ErrMFANotEnrolled
+	}
+	code = normalizeCode(code)
+	if s.checkTOTP(e, code) {
+		now := time.Now()
+		e.LastUsedAt = &now
+		return s.repo.Save(e)
+	}
+
+	hash := sha256Hex([]byte(code))
+	for i, h := range e.RecoveryCodes {
+		if h == hash {
+			now := time.Now()
+			e.RecoveryCodes = append(e.RecoveryCodes[:i], e.RecoveryCodes[i+1:]...)
+			e.LastUsedAt = &now
+			if err := s.repo.Save(e); err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("MFA recovery code used", "user_id", userID, "remaining", len(e.RecoveryCodes))
+			return nil
+		}
+	}
+	return ErrMFAInvalidCode
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a current code
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, user *domain.User, code string) ([]string, error) {
+	if err := s.Verify(ctx, user.ID, code); err != nil {
+		return nil, err
+	}
+	e := s.repo.Get(user.ID)
+	codes, hashes := newRecoveryCodes()
+	e.RecoveryCodes = hashes
+	if err := s.repo.Save(e); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("MFA recovery codes regenerated", "user_id", user.ID)
+	return codes, nil
+}
+
+// Disable removes the user's own enrollment after checking a current code.
+// Users whose role requires a second factor cannot turn it off.
+func (s *MFAService) Disable(ctx context.Context, user *domain.User, code string) error {
+	if s.Required(user) {
+		return ErrMFARequired
+	}
+	if err := s.Verify(ctx, user.ID, code); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(user.ID); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("MFA disabled", "user_id", user.ID)
+	return nil
+}
+
+// Reset removes another user's enrollment, for a lost authenticator, and
+// signs them out everywhere, as whoever holds their sessions may be the one
+// who took it. If their role requires a second factor they enroll again at
+// their next login.
+func (s *MFAService) Reset(ctx context.Context, admin *domain.User, userID string) error {
+	if admin == nil || !admin.CanManageUsers() {
+		return ErrMFAForbidden
+	}
+	if s.repo.Get(userID) == nil {
+		return ErrMFANotEnrolled
+	}
+	if err := s.repo.Delete(userID); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("MFA reset", "user_id", userID, "actor_id", admin.ID)
+	signedOut := revokeSessions(ctx, s.sessionRepo, userID)
+	if user := s.userRepo.GetByID(userID); user != nil {
+		s.audit.Record(ctx, admin, domain.AuditUserMFAReset, user, fmt.Sprintf("%d sessions ended", signedOut))
+	}
+	return nil
+}
+
+// Accounts lists users with a confirmed enrollment, for admins
+func (s *MFAService) Accounts(admin *domain.User) ([]MFAAccount, error) {
+	if admin == nil || !admin.CanManageUsers() {
+		return nil, ErrMFAForbidden
+	}
+	var accounts []MFAAccount
+	for _, e := range s.repo.List() {
+		if !e.IsConfirmed() {
+			continue
+		}
+		accounts = append(accounts, MFAAccount{
+			User:              s.userRepo.GetByID(e.UserID),
+			Enrollment:        e,
+			RecoveryCodesLeft: len(e.RecoveryCodes),
+		})
+	}
+	return accounts, nil
+}
+
+// checkTOTP validates a TOTP code, allowing one step of clock drift, and
+// records the step so the same code cannot be used twice
+func (s *MFAService) checkTOTP(e *domain.MFAEnrollment, code string) bool {
+	step, ok := totp.Validate(e.Secret, normalizeCode(code), time.Now(), 1)
+	if !ok || step <= e.LastUsedStep {
+		return false
+	}
+	e.LastUsedStep = step
+	return true
+}
+
+// newRecoveryCodes returns recovery codes formatted for display, and their hashes
+func newRecoveryCodes() (codes, hashes []string) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 6)
+		rand.Read(b)
+		code := strings.ToLower(enc.EncodeToString(b)) // 10 characters
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, sha256Hex([]byte(code)))
+	}
+	return codes, hashes
+}
+
+// normalizeCode strips the spaces and dashes people type in codes
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
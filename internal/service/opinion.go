@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/statute"
+)
+
+type OpinionRepository interface {
+	Create(o *domain.PublishedOpinion) error
+	Delete(caseNumber string) error
+	GetByCaseNumber(caseNumber string) *domain.PublishedOpinion
+	Search(query, docType, year, topic string) []domain.PublishedOpinion
+	ListByStatute(sectionKey string) []domain.PublishedOpinion
+	StatuteCounts() map[string]int
+}
+
+// StatuteEntry is one section in the NRS cross-reference index
+type StatuteEntry struct {
+	Section  string // "281A.400"
+	Citation string // "NRS 281A.400"
+	Count    int    // Published opinions citing the section
+}
+
+// ErrPublishForbidden is returned when a user without CanPublish tries to publish
+var ErrPublishForbidden = errors.New("user is not permitted to publish opinions")
+
+// PublishRequest collects everything needed to turn a case into a published opinion
+type PublishRequest struct {
+	Title             string
+	Summary           string
+	Topics            []string
+	Statutes          []string
+	DocumentID        string // Redacted derivative of the final document
+	RedactionReviewed bool   // Reviewer attests the redacted copy has been checked for confidential information
+}
+
+type OpinionService struct {
+	caseRepo    CaseRepository
+	opinionRepo OpinionRepository
+	events      EventPublisher
+}
+
+func NewOpinionService(caseRepo CaseRepository, opinionRepo OpinionRepository, events EventPublisher) *OpinionService {
+	return &OpinionService{
+		caseRepo:    caseRepo,
+		opinionRepo: opinionRepo,
+		events:      events,
+	}
+}
+
+// Search searches published opinions
+func (s *OpinionService) Search(query, docType, year, topic string) []domain.PublishedOpinion {
+	return s.opinionRepo.Search(query, docType, year, topic)
+}
+
+// Get retrieves a published opinion by case number, or nil if none is published
+func (s *OpinionService) Get(caseNumber string) *domain.PublishedOpinion {
+	return s.opinionRepo.GetByCaseNumber(caseNumber)
+}
+
+// BySection normalizes a section reference (e.g. "281a.400") and returns its key
+// with the published opinions interpreting it, newest first. ok is false if the
+// reference is not an NRS 281A section.
+func (s *OpinionService) BySection(section string) (key string, opinions []domain.PublishedOpinion, ok bool) {
+	key, ok = statute.ParseSection(section)
+	if !ok {
+		return "", nil, false
+	}
+	return key, s.opinionRepo.ListByStatute(key), true
+}
+
+// StatuteIndex returns every section cited by a published opinion, in statute order
+func (s *OpinionService) StatuteIndex() []StatuteEntry {
+	var entries []StatuteEntry
+	for section, count := range s.opinionRepo.StatuteCounts() {
+		entries = append(entries, StatuteEntry{Section: section, Citation: "NRS " + section, Count: count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Section < entries[j].Section
+	})
+	return entries
+}
+
+// GetDocument returns the public document for a published opinion, or nil
+func (s *OpinionService) GetDocument(caseNumber string) *domain.Document {
+	o := s.opinionRepo.GetByCaseNumber(caseNumber)
+	if o == nil || o.DocumentID == "" {
+		return nil
+	}
+	d := s.caseRepo.GetDocument(o.DocumentID)
+	if d == nil || !d.IsPublic || !d.CanBePublic() {
+		return nil
+	}
+	return d
+}
+
+// PublishableDocuments returns the redacted copies on a case that can be published
+func (s *OpinionService) PublishableDocuments(caseID string) []*domain.Document {
+	var result []*domain.Document
+	for _, d := range s.caseRepo.GetDocuments(caseID) {
+		if d.CanBePublic() {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// CanPublish reports whether the case is eligible for publication
+func CanPublish(c *domain.Case) bool {
+	if c == nil {
+		return false
+	}
+	isOpinionType := c.Type == domain.CaseTypeAdvisoryOpinion || c.Type == domain.CaseTypeEthicsComplaint
+	return isOpinionType && c.Status == domain.StatusDraftPrepared
+}
+
+// Publish turns a draft-prepared AO or EC case into a public opinion
+func (s *OpinionService) Publish(ctx context.Context, user *domain.User, caseID string, req PublishRequest) (*domain.PublishedOpinion, error) {
+	if user == nil || !user.CanPublish() {
+		return nil, ErrPublishForbidden
+	}
+
+	c := s.caseRepo.GetByID(caseID)
+	if c == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCaseNotFound, caseID)
+	}
+	if !CanPublish(c) {
+		return nil, fmt.Errorf("case %s cannot be published: only AO and EC cases in draft_prepared can be published", c.CaseNumber)
+	}
+	if s.opinionRepo.GetByCaseNumber(c.CaseNumber) != nil {
+		return nil, fmt.Errorf("case %s is already published", c.CaseNumber)
+	}
+
+	title := strings.TrimSpace(req.Title)
+	summary := strings.TrimSpace(req.Summary)
+	if title == "" {
+		return nil, errors.New("title is required")
+	}
+	if summary == "" {
+		return nil, errors.New("summary is required")
+	}
+	var citations []domain.StatuteCitation
+	for _, entry := range req.Statutes {
+		parsed := statute.Parse(entry)
+		if len(parsed) == 0 {
+			return nil, fmt.Errorf("unrecognized statute citation: %q (expected e.g. NRS 281A.400(2))", entry)
+		}
+		citations = append(citations, parsed...)
+	}
+	citations = statute.ParseAll(statute.Strings(citations)...) // drop duplicates
+
+	if req.DocumentID == "" {
+		return nil, errors.New("a redacted final document is required")
+	}
+	doc := s.caseRepo.GetDocument(req.DocumentID)
+	if doc == nil || doc.CaseID != c.ID {
+		return nil, fmt.Errorf("document not found: %s", req.DocumentID)
+	}
+	if !doc.CanBePublic() {
+		return nil, ErrNotReleasable
+	}
+	if !req.RedactionReviewed {
+		return nil, errors.New("redaction review must be completed before publishing")
+	}
+
+	now := time.Now()
+
+	// The stores have no transactions, so a failed step undoes the ones
+	// before it rather than leave a document or opinion public on an
+	// unpublished case. Records are changed as copies, so the originals
+	// are what the undo puts back.
+	public := *doc
+	public.IsPublic = true
+	if err := s.caseRepo.UpdateDocument(&public); err != nil {
+		return nil, err
+	}
+	undoDocument := func() {
+		if err := s.caseRepo.UpdateDocument(doc); err != nil {
+			logging.FromContext(ctx).Error("withdrawing document after failed publish", "document_id", doc.ID, "error", err)
+		}
+	}
+
+	opinion := &domain.PublishedOpinion{
+		ID:                  fmt.Sprintf("op_%d", now.UnixNano()),
+		CaseID:              c.ID,
+		CaseNumber:          c.CaseNumber,
+		Type:                c.Type,
+		Title:               title,
+		Summary:             summary,
+		Topics:              req.Topics,
+		Statutes:            statute.Strings(citations),
+		Citations:           citations,
+		DocumentID:          doc.ID,
+		DocumentURL:         "/opinions/" + c.CaseNumber + "/document",
+		PublishedAt:         now,
+		PublishedBy:         user.ID,
+		Year:                now.Year(),
+		RedactionReviewedBy: user.ID,
+		RedactionReviewedAt: now,
+	}
+	if err := s.opinionRepo.Create(opinion); err != nil {
+		undoDocument()
+		return nil, err
+	}
+
+	previous := c.Status
+	published := *c
+	published.Status = domain.StatusPublished
+	published.PublishedAt = &now
+	published.IsPublic = true
+	published.UpdatedAt = now
+	if err := s.caseRepo.Update(&published); err != nil {
+		if err := s.opinionRepo.Delete(c.CaseNumber); err != nil {
+			logging.FromContext(ctx).Error("withdrawing opinion after failed publish", "case_number", c.CaseNumber, "error", err)
+		}
+		undoDocument()
+		return nil, err
+	}
+	c = &published
+
+	logging.FromContext(ctx).Info("opinion published", "case_number", c.CaseNumber, "actor_id", user.ID)
+	recordActivity(ctx, s.caseRepo, c, user, "published", "Opinion published", string(previous), string(domain.StatusPublished))
+	data := newCaseEventData(c)
+	data.PreviousStatus = string(previous)
+	s.events.Publish(ctx, domain.EventCaseStatusChanged, data)
+	s.events.Publish(ctx, domain.EventOpinionPublished, opinionEventData{
+		CaseID:      c.ID,
+		CaseNumber:  opinion.CaseNumber,
+		Type:        string(opinion.Type),
+		Title:       opinion.Title,
+		Summary:     opinion.Summary,
+		Statutes:    opinion.Statutes,
+		Path:        "/opinions/" + opinion.CaseNumber,
+		PublishedAt: opinion.PublishedAt.UTC(),
+	})
+	return opinion, nil
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/redact"
+)
+
+type RedactionRepository interface {
+	ListByDocument(documentID string) []domain.Redaction
+	Get(id string) *domain.Redaction
+	Create(r *domain.Redaction) error
+	Delete(id string) error
+	CreateLog(l *domain.RedactionLog) error
+	ListLogs(caseID string) []domain.RedactionLog
+	GetLogByDerived(documentID string) *domain.RedactionLog
+}
+
+// ErrNotReleasable is returned when releasing a document that is not a redacted derivative
+var ErrNotReleasable = errors.New("only redacted copies can be released publicly")
+
+// RedactionService manages redactions on case documents. Redactions are
+// defined on an original document and applied to produce a new redacted
+// derivative; the original is never modified.
+type RedactionService struct {
+	caseRepo      CaseRepository
+	redactionRepo RedactionRepository
+}
+
+func NewRedactionService(caseRepo CaseRepository, redactionRepo RedactionRepository) *RedactionService {
+	return &RedactionService{
+		caseRepo:      caseRepo,
+		redactionRepo: redactionRepo,
+	}
+}
+
+// ListRedactions returns the pending redactions on a document
+func (s *RedactionService) ListRedactions(documentID string) []domain.Redaction {
+	return s.redactionRepo.ListByDocument(documentID)
+}
+
+// ListLogs returns the redaction log for a case, newest first
+func (s *RedactionService) ListLogs(caseID string) []domain.RedactionLog {
+	return s.redactionRepo.ListLogs(caseID)
+}
+
+// GetLog returns the log entry that produced a redacted derivative, or nil
+func (s *RedactionService) GetLog(derivedDocumentID string) *domain.RedactionLog {
+	return s.redactionRepo.GetLogByDerived(derivedDocumentID)
+}
+
+// Kind returns the kind of redaction a document takes, or "" if it cannot be redacted
+func (s *RedactionService) Kind(d *domain.Document) domain.RedactionKind {
+	return redact.KindFor(d.ContentType)
+}
+
+// Check returns why a document cannot be redacted here, or nil if it can
+func (s *RedactionService) Check(d *domain.Document) error {
+	return redact.Check(d.ContentType, d.Content)
+}
+
+// AddTextRedaction redacts the byte span [start, end) of a text or HTML document
+func (s *RedactionService) AddTextRedaction(user *domain.User, documentID string, start, end int, reason domain.RedactionReason) (*domain.Redaction, error) {
+	return s.add(user, documentID, domain.Redaction{
+		Kind:   domain.RedactionText,
+		Reason: reason,
+		Start:  start,
+		End:    end,
+	})
+}
+
+// AddPhraseRedactions redacts every occurrence of phrase in a text or HTML
+// document and returns the redactions created
+func (s *RedactionService) AddPhraseRedactions(user *domain.User, documentID, phrase string, reason domain.RedactionReason) ([]domain.Redaction, error) {
+	doc, err := s.original(user, documentID)
+	if err != nil {
+		return nil, err
+	}
+	phrase = strings.TrimSpace(phrase)
+	if phrase == "" {
+		return nil, errors.New("phrase is required")
+	}
+
+	spans := redact.FindText(doc.Content, phrase)
+	if len(spans) == 0 {
+		return nil, fmt.Errorf("%q does not appear in %s", phrase, doc.Filename)
+	}
+
+	// Every occurrence is checked before any is stored, so a phrase that
+	// also appears where it cannot be redacted, such as an HTML attribute,
+	// is refused outright rather than left partly redacted
+	redactions := make([]domain.Redaction, len(spans))
+	for i, span := range spans {
+		redactions[i] = domain.Redaction{Kind: domain.RedactionText, Reason: reason, Start: span[0], End: span[1]}
+	}
+	if err := s.validate(doc, redactions...); err != nil {
+		return nil, err
+	}
+
+	created := make([]domain.Redaction, 0, len(redactions))
+	for _, r := range redactions {
+		stored, err := s.store(user, doc, r)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, *stored)
+	}
+	return created, nil
+}
+
+// AddRegionRedaction redacts a rectangle on a PDF page
+func (s *RedactionService) AddRegionRedaction(user *domain.User, documentID string, page int, x, y, width, height float64, reason domain.RedactionReason) (*domain.Redaction, error) {
+	return s.add(user, documentID, domain.Redaction{
+		Kind:   domain.RedactionRegion,
+		Reason: reason,
+		Page:   page,
+		X:      x,
+		Y:      y,
+		Width:  width,
+		Height: height,
+	})
+}
+
+// RemoveRedaction deletes a pending redaction from a document
+func (s *RedactionService) RemoveRedaction(user *domain.User, documentID, redactionID string) error {
+	if _, err := s.original(user, documentID); err != nil {
+		return err
+	}
+	r := s.redactionRepo.Get(redactionID)
+	if r == nil || r.DocumentID != documentID {
+		return fmt.Errorf("redaction not found: %s", redactionID)
+	}
+	return s.redactionRepo.Delete(redactionID)
+}
+
+// Apply produces a redacted derivative of a document and records it in the
+// redaction log. Applying with no redactions is allowed and records that the
+// document was reviewed and found to need none.
+func (s *RedactionService) Apply(ctx context.Context, user *domain.User, documentID string) (*domain.Document, error) {
+	doc, err := s.original(user, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	redactions := s.redactionRepo.ListByDocument(documentID)
+	content, err := redact.Apply(doc.ContentType, doc.Content, redactions)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ext := filepath.Ext(doc.Filename)
+	derived := &domain.Document{
+		ID:            fmt.Sprintf("doc_%d", now.UnixNano()),
+		CaseID:        doc.CaseID,
+		Filename:      strings.TrimSuffix(doc.Filename, ext) + "-redacted" + ext,
+		ContentType:   doc.ContentType,
+		Size:          int64(len(content)),
+		Category:      doc.Category,
+		UploadedBy:    user.ID,
+		UploadedAt:    now,
+		Content:       content,
+		DerivedFromID: doc.ID,
+		IsRedacted:    true,
+	}
+	if err := s.caseRepo.AddDocument(derived); err != nil {
+		return nil, err
+	}
+
+	entry := &domain.RedactionLog{
+		ID:                fmt.Sprintf("rlog_%d", now.UnixNano()),
+		CaseID:            doc.CaseID,
+		SourceDocumentID:  doc.ID,
+		DerivedDocumentID: derived.ID,
+		SourceSHA256:      sha256Hex(doc.Content),
+		DerivedSHA256:     sha256Hex(content),
+		Redactions:        redactions,
+		AppliedBy:         user.ID,
+		AppliedByName:     user.FullName(),
+		AppliedAt:         now,
+	}
+	if err := s.redactionRepo.CreateLog(entry); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("redaction applied", "case_id", doc.CaseID, "document_id", doc.ID,
+		"derived_id", derived.ID, "redactions", len(redactions), "actor_id", user.ID)
+	return derived, nil
+}
+
+// Release makes a redacted derivative publicly downloadable
+func (s *RedactionService) Release(ctx context.Context, user *domain.User, documentID string) error {
+	if user == nil || !user.CanManageCases() {
+		return ErrCaseForbidden
+	}
+	doc := s.caseRepo.GetDocument(documentID)
+	if doc == nil {
+		return fmt.Errorf("document not found: %s", documentID)
+	}
+	if !doc.CanBePublic() {
+		return ErrNotReleasable
+	}
+	doc.IsPublic = true
+	if err := s.caseRepo.UpdateDocument(doc); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("document released", "case_id", doc.CaseID, "document_id", doc.ID, "actor_id", user.ID)
+	return nil
+}
+
+// add validates a redaction against the document content before storing it,
+// so errors surface when the redaction is drawn rather than when it is applied
+func (s *RedactionService) add(user *domain.User, documentID string, r domain.Redaction) (*domain.Redaction, error) {
+	doc, err := s.original(user, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(doc, r); err != nil {
+		return nil, err
+	}
+	return s.store(user, doc, r)
+}
+
+// validate checks that redactions suit the document and can all be applied to it
+func (s *RedactionService) validate(doc *domain.Document, redactions ...domain.Redaction) error {
+	kind := redact.KindFor(doc.ContentType)
+	if kind == "" {
+		return fmt.Errorf("%w: %s", redact.ErrUnsupportedType, doc.ContentType)
+	}
+	for _, r := range redactions {
+		if !r.Reason.IsValid() {
+			return fmt.Errorf("invalid redaction reason: %q", r.Reason)
+		}
+		if kind != r.Kind {
+			return fmt.Errorf("%s documents take %s redactions", doc.ContentType, kind)
+		}
+	}
+	_, err := redact.Apply(doc.ContentType, doc.Content, redactions)
+	return err
+}
+
+func (s *RedactionService) store(user *domain.User, doc *domain.Document, r domain.Redaction) (*domain.Redaction, error) {
+	now := time.Now()
+	r.ID = fmt.Sprintf("red_%d", now.UnixNano())
+	r.DocumentID = doc.ID
+	r.CreatedBy = user.ID
+	r.CreatedAt = now
+	if err := s.redactionRepo.Create(&r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// original returns a document that redactions may be defined on
+func (s *RedactionService) original(user *domain.User, documentID string) (*domain.Document, error) {
+	if user == nil || !user.CanManageCases() {
+		return nil, ErrCaseForbidden
+	}
+	doc := s.caseRepo.GetDocument(documentID)
+	if doc == nil {
+		return nil, fmt.Errorf("document not found: %s", documentID)
+	}
+	if doc.IsRedacted {
+		return nil, errors.New("redactions are defined on the original document, not a redacted copy")
+	}
+	return doc, nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
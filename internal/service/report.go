@@ -0,0 +1,385 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"ncoe/internal/domain"
+)
+
+// maxReportMonths caps the date range of a report
+const maxReportMonths = 120
+
+var (
+	// ErrReportRange is returned when a report's dates are missing, reversed or too far apart
+	ErrReportRange = fmt.Errorf("choose a start date on or before the end date, at most %d years apart", maxReportMonths/12)
+	// ErrReportType is returned for an unknown case type filter
+	ErrReportType = errors.New("unknown case type")
+	// ErrReportAssignee is returned for an unknown assignee filter
+	ErrReportAssignee = errors.New("unknown assignee")
+)
+
+// ReportFilter selects the cases a report covers
+type ReportFilter struct {
+	From       time.Time       // First day, inclusive
+	To         time.Time       // Last day, inclusive
+	Type       domain.CaseType // Empty for every type
+	AssigneeID string          // Empty for every assignee
+}
+
+// DefaultReportFilter covers the twelve calendar months ending with the one containing now
+func DefaultReportFilter(now time.Time) ReportFilter {
+	y, m, d := now.Date()
+	return ReportFilter{
+		From: time.Date(y, m-11, 1, 0, 0, 0, 0, now.Location()),
+		To:   time.Date(y, m, d, 0, 0, 0, 0, now.Location()),
+	}
+}
+
+// end returns the instant just after the last day of the range
+func (f ReportFilter) end() time.Time {
+	return f.To.AddDate(0, 0, 1)
+}
+
+// contains reports whether t falls within the filter's dates
+func (f ReportFilter) contains(t time.Time) bool {
+	return !t.Before(f.From) && t.Before(f.end())
+}
+
+// MonthlyCount is the number of cases opened and closed in one month
+type MonthlyCount struct {
+	Month  time.Time // First day of the month
+	Opened int
+	Closed int
+}
+
+// CloseTime summarizes how long cases took to close, in days
+type CloseTime struct {
+	Label      string // Case type, or "All types"
+	Closed     int
+	MedianDays float64
+	P90Days    float64
+}
+
+// DeadlineRate counts case deadlines met and missed
+type DeadlineRate struct {
+	Label  string // Case type, or "All types"
+	Met    int
+	Missed int
+}
+
+// Total returns the number of deadlines decided
+func (d DeadlineRate) Total() int {
+	return d.Met + d.Missed
+}
+
+// Rate returns the percentage of deadlines met, rounded to a whole number.
+// It is zero when no deadline has been decided.
+func (d DeadlineRate) Rate() int {
+	if d.Total() == 0 {
+		return 0
+	}
+	return int(math.Round(float64(d.Met) * 100 / float64(d.Total())))
+}
+
+// AgingBucket counts open cases by how long ago they were submitted
+type AgingBucket struct {
+	Label   string
+	MinDays int
+	MaxDays int // Zero for no upper limit
+	Count   int
+}
+
+// agingBuckets are the backlog age ranges, in days
+var agingBuckets = []AgingBucket{
+	{Label: "0–30 days", MinDays: 0, MaxDays: 30},
+	{Label: "31–60 days", MinDays: 31, MaxDays: 60},
+	{Label: "61–90 days", MinDays: 61, MaxDays: 90},
+	{Label: "91–180 days", MinDays: 91, MaxDays: 180},
+	{Label: "Over 180 days", MinDays: 181},
+}
+
+// Report holds case metrics for a date range
+type Report struct {
+	Filter       ReportFilter
+	AssigneeName string
+	GeneratedAt  time.Time
+	AsOf         time.Time // When the backlog was measured: the end of the range, or now if sooner
+
+	Opened     int // Cases submitted in the range
+	Closed     int // Cases resolved in the range
+	Monthly    []MonthlyCount
+	MaxMonthly int            // Largest monthly count, for scaling charts
+	CloseTimes []CloseTime    // One row per case type, then all types together
+	Deadlines  []DeadlineRate // Deadlines due in the range, rows as for CloseTimes
+	Aging      []AgingBucket  // Cases open at AsOf
+	Backlog    int            // Cases open at AsOf
+	MaxAging   int            // Largest aging bucket, for scaling charts
+}
+
+// ReportTable is one table of a report, for export
+type ReportTable struct {
+	Name   string
+	Header []string
+	Rows   [][]interface{}
+}
+
+// ReportService computes case metrics for the reports page and its exports
+type ReportService struct {
+	caseRepo CaseRepository
+	userRepo UserRepository
+}
+
+func NewReportService(caseRepo CaseRepository, userRepo UserRepository) *ReportService {
+	return &ReportService{caseRepo: caseRepo, userRepo: userRepo}
+}
+
+// Assignees returns the staff cases can be assigned to, for the assignee filter
+func (s *ReportService) Assignees() []*domain.User {
+	var result []*domain.User
+	for _, u := range s.userRepo.List() {
+		if u.CanManageCases() {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// Generate computes the report for the filter.
+//
+// A case counts as opened when it is submitted and as closed when it is
+// published, closed or withdrawn. Time to close covers cases closed in the
+// range. A deadline is met when the case is resolved by its due date and
+// missed when it is resolved later or still open after it; deadlines that
+// are due in the range but still running are left out.
+func (s *ReportService) Generate(f ReportFilter) (*Report, error) {
+	if f.From.IsZero() || f.To.IsZero() || f.To.Before(f.From) || f.From.AddDate(0, maxReportMonths, 0).Before(f.To) {
+		return nil, ErrReportRange
+	}
+	report := &Report{Filter: f, GeneratedAt: time.Now()}
+	if f.Type != "" && !isCaseType(f.Type) {
+		return nil, fmt.Errorf("%w: %q", ErrReportType, f.Type)
+	}
+	if f.AssigneeID != "" {
+		assignee := s.userRepo.GetByID(f.AssigneeID)
+		if assignee == nil {
+			return nil, fmt.Errorf("%w: %q", ErrReportAssignee, f.AssigneeID)
+		}
+		report.AssigneeName = assignee.FullName()
+	}
+	report.AsOf = f.end()
+	if report.GeneratedAt.Before(report.AsOf) {
+		report.AsOf = report.GeneratedAt
+	}
+
+	var cases []*domain.Case
+	for _, c := range s.caseRepo.List(string(f.Type), "", "") {
+		if f.AssigneeID == "" || c.AssignedTo == f.AssigneeID {
+			cases = append(cases, c)
+		}
+	}
+
+	report.Monthly = monthlyCounts(f, cases)
+	for _, m := range report.Monthly {
+		report.Opened += m.Opened
+		report.Closed += m.Closed
+		report.MaxMonthly = max(report.MaxMonthly, m.Opened, m.Closed)
+	}
+
+	types := domain.CaseTypes
+	if f.Type != "" {
+		types = []domain.CaseType{f.Type}
+	}
+	durations := map[domain.CaseType][]float64{}
+	deadlines := map[domain.CaseType]*DeadlineRate{}
+	for _, t := range types {
+		deadlines[t] = &DeadlineRate{Label: t.Label()}
+	}
+	for _, c := range cases {
+		resolved := c.ResolvedAt()
+		if resolved != nil && f.contains(*resolved) {
+			durations[c.Type] = append(durations[c.Type], resolved.Sub(c.SubmittedAt).Hours()/24)
+		}
+		if d := deadlines[c.Type]; d != nil && !c.DueDate.IsZero() && f.contains(c.DueDate) {
+			switch {
+			case resolved != nil && !resolved.After(c.DueDate):
+				d.Met++
+			case resolved != nil || report.GeneratedAt.After(c.DueDate):
+				d.Missed++
+			}
+		}
+	}
+
+	var all []float64
+	allDeadlines := DeadlineRate{Label: "All types"}
+	for _, t := range types {
+		report.CloseTimes = append(report.CloseTimes, closeTime(t.Label(), durations[t]))
+		report.Deadlines = append(report.Deadlines, *deadlines[t])
+		all = append(all, durations[t]...)
+		allDeadlines.Met += deadlines[t].Met
+		allDeadlines.Missed += deadlines[t].Missed
+	}
+	if len(types) > 1 {
+		report.CloseTimes = append(report.CloseTimes, closeTime("All types", all))
+		report.Deadlines = append(report.Deadlines, allDeadlines)
+	}
+
+	report.Aging = agingCounts(report.AsOf, cases)
+	for _, b := range report.Aging {
+		report.Backlog += b.Count
+		report.MaxAging = max(report.MaxAging, b.Count)
+	}
+	return report, nil
+}
+
+// OnTime returns the deadline rate across every type in the report
+func (r *Report) OnTime() DeadlineRate {
+	return r.Deadlines[len(r.Deadlines)-1]
+}
+
+// Tables returns the report as tables for CSV and spreadsheet export
+func (r *Report) Tables() []ReportTable {
+	typeName, assignee := "All types", "Everyone"
+	if r.Filter.Type != "" {
+		typeName = r.Filter.Type.Label()
+	}
+	if r.AssigneeName != "" {
+		assignee = r.AssigneeName
+	}
+	onTime := r.OnTime()
+	summary := ReportTable{
+		Name:   "Summary",
+		Header: []string{"Metric", "Value"},
+		Rows: [][]interface{}{
+			{"From", r.Filter.From.Format("2006-01-02")},
+			{"To", r.Filter.To.Format("2006-01-02")},
+			{"Case type", typeName},
+			{"Assignee", assignee},
+			{"Generated", r.GeneratedAt.Format("2006-01-02 15:04 MST")},
+			{"Cases opened", r.Opened},
+			{"Cases closed", r.Closed},
+			{"Deadlines met", onTime.Met},
+			{"Deadlines missed", onTime.Missed},
+			{"On-time rate (%)", optionalRate(onTime)},
+			{"Open cases at " + r.AsOf.Format("2006-01-02"), r.Backlog},
+		},
+	}
+
+	monthly := ReportTable{Name: "Monthly", Header: []string{"Month", "Opened", "Closed"}}
+	for _, m := range r.Monthly {
+		monthly.Rows = append(monthly.Rows, []interface{}{m.Month.Format("2006-01"), m.Opened, m.Closed})
+	}
+
+	closeTimes := ReportTable{Name: "Time to Close", Header: []string{"Case type", "Closed", "Median days", "90th percentile days"}}
+	for _, c := range r.CloseTimes {
+		row := []interface{}{c.Label, c.Closed, nil, nil}
+		if c.Closed > 0 {
+			row[2], row[3] = c.MedianDays, c.P90Days
+		}
+		closeTimes.Rows = append(closeTimes.Rows, row)
+	}
+
+	deadlines := ReportTable{Name: "Deadlines", Header: []string{"Case type", "Met", "Missed", "On-time rate (%)"}}
+	for _, d := range r.Deadlines {
+		deadlines.Rows = append(deadlines.Rows, []interface{}{d.Label, d.Met, d.Missed, optionalRate(d)})
+	}
+
+	aging := ReportTable{Name: "Backlog Aging", Header: []string{"Age", "Open cases"}}
+	for _, b := range r.Aging {
+		aging.Rows = append(aging.Rows, []interface{}{b.Label, b.Count})
+	}
+
+	return []ReportTable{summary, monthly, closeTimes, deadlines, aging}
+}
+
+// optionalRate returns the on-time percentage, or nil when no deadline was decided
+func optionalRate(d DeadlineRate) interface{} {
+	if d.Total() == 0 {
+		return nil
+	}
+	return d.Rate()
+}
+
+// monthlyCounts buckets the cases opened and closed in the range by calendar month
+func monthlyCounts(f ReportFilter, cases []*domain.Case) []MonthlyCount {
+	loc := f.From.Location()
+	var months []MonthlyCount
+	index := map[string]int{}
+	for m := time.Date(f.From.Year(), f.From.Month(), 1, 0, 0, 0, 0, loc); m.Before(f.end()); m = m.AddDate(0, 1, 0) {
+		index[m.Format("2006-01")] = len(months)
+		months = append(months, MonthlyCount{Month: m})
+	}
+	for _, c := range cases {
+		if f.contains(c.SubmittedAt) {
+			months[index[c.SubmittedAt.In(loc).Format("2006-01")]].Opened++
+		}
+		if resolved := c.ResolvedAt(); resolved != nil && f.contains(*resolved) {
+			months[index[resolved.In(loc).Format("2006-01")]].Closed++
+		}
+	}
+	return months
+}
+
+// agingCounts buckets the cases open at asOf by days since submission
+func agingCounts(asOf time.Time, cases []*domain.Case) []AgingBucket {
+	buckets := make([]AgingBucket, len(agingBuckets))
+	copy(buckets, agingBuckets)
+	for _, c := range cases {
+		if c.SubmittedAt.IsZero() || !c.SubmittedAt.Before(asOf) {
+			continue
+		}
+		if resolved := c.ResolvedAt(); resolved != nil && !resolved.After(asOf) {
+			continue
+		}
+		days := int(asOf.Sub(c.SubmittedAt).Hours() / 24)
+		for i := range buckets {
+			if days >= buckets[i].MinDays && (buckets[i].MaxDays == 0 || days <= buckets[i].MaxDays) {
+				buckets[i].Count++
+				break
+			}
+		}
+	}
+	return buckets
+}
+
+// closeTime summarizes durations in days, rounded to one decimal place
+func closeTime(label string, days []float64) CloseTime {
+	sorted := append([]float64(nil), days...)
+	sort.Float64s(sorted)
+	return CloseTime{
+		Label:      label,
+		Closed:     len(sorted),
+		MedianDays: roundTenth(percentile(sorted, 0.5)),
+		P90Days:    roundTenth(percentile(sorted, 0.9)),
+	}
+}
+
+// percentile interpolates linearly between the closest ranks of sorted
+// values, as spreadsheets' PERCENTILE.INC does. It is zero for no values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (rank-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+func roundTenth(f float64) float64 {
+	return math.Round(f*10) / 10
+}
+
+func isCaseType(t domain.CaseType) bool {
+	for _, ct := range domain.CaseTypes {
+		if t == ct {
+			return true
+		}
+	}
+	return false
+}
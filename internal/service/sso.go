@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/oidc"
+)
+
+// ssoLoginTTL bounds how long a user may spend at the identity provider
+const ssoLoginTTL = 10 * time.Minute
+
+var (
+	ErrSSOState      = errors.New("sign-in request expired or was not started here")
+	ErrNoRoleMapping = errors.New("none of your identity provider groups grant access")
+	ErrUserInactive  = errors.New("user account is deactivated")
+)
+
+// SSOConfig controls how identity provider accounts become staff users
+type SSOConfig struct {
+	ProviderName string            // Shown on the login button
+	RedirectURL  string            // Callback URL; the caller's URL is used when empty
+	GroupsClaim  string            // ID token claim listing the user's groups
+	RoleMap      map[string]string // Provider group -> role
+	Exclusive    bool              // Password login is disabled
+}
+
+// pendingLogin is what a login started by Begin needs to be completed
+type pendingLogin struct {
+	nonce       string
+	verifier    string
+	redirectURI string
+	expiresAt   time.Time
+}
+
+// SSOService signs staff in through an OpenID Connect provider. Users are
+// provisioned on first login and their role follows their provider groups.
+type SSOService struct {
+	provider *oidc.Provider
+	userRepo UserRepository
+	cfg      SSOConfig
+	roles    map[string]domain.Role
+
+	mu      sync.Mutex
+	pending map[string]pendingLogin // state -> login
+}
+
+// NewSSOService validates the group to role mapping and returns the service
+func NewSSOService(provider *oidc.Provider, userRepo UserRepository, cfg SSOConfig) (*SSOService, error) {
+	if len(cfg.RoleMap) == 0 {
+		return nil, errors.New("sso: no groups are mapped to roles")
+	}
+	roles := make(map[string]domain.Role, len(cfg.RoleMap))
+	for group, name := range cfg.RoleMap {
+		role := domain.Role(name)
+		if !role.IsValid() {
+			return nil, fmt.Errorf("sso: group %q maps to unknown role %q", group, name)
+		}
+		roles[group] = role
+	}
+	if cfg.ProviderName == "" {
+		cfg.ProviderName = "Single Sign-On"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	return &SSOService{
+		provider: provider,
+		userRepo: userRepo,
+		cfg:      cfg,
+		roles:    roles,
+		pending:  make(map[string]pendingLogin),
+	}, nil
+}
+
+// ProviderName returns the name shown on the login button
+func (s *SSOService) ProviderName() string {
+	return s.cfg.ProviderName
+}
+
+// Exclusive returns true if staff may only sign in through the provider
+func (s *SSOService) Exclusive() bool {
+	return s.cfg.Exclusive
+}
+
+// Begin starts a login and returns the provider URL to send the user to,
+// along with the state value the callback must present
+func (s *SSOService) Begin(ctx context.Context, redirectURI string) (authURL, state string, err error) {
+	if s.cfg.RedirectURL != "" {
+		redirectURI = s.cfg.RedirectURL
+	}
+	state = oidc.RandomString()
+	nonce := oidc.RandomString()
+	verifier, challenge := oidc.NewPKCE()
+
+	authURL, err = s.provider.AuthCodeURL(ctx, redirectURI, state, nonce, challenge)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for k, p := range s.pending {
+		if now.After(p.expiresAt) {
+			delete(s.pending, k)
+		}
+	}
+	s.pending[state] = pendingLogin{
+		nonce:       nonce,
+		verifier:    verifier,
+		redirectURI: redirectURI,
+		expiresAt:   now.Add(ssoLoginTTL),
+	}
+	s.mu.Unlock()
+
+	return authURL, state, nil
+}
+
+// Complete redeems the authorization code returned to the callback and
+// returns the signed-in user, provisioning them on first login
+func (s *SSOService) Complete(ctx context.Context, state, code string) (*domain.User, error) {
+	user, err := s.complete(ctx, state, code)
+	if err != nil {
+		loginFailures.Inc("sso")
+	}
+	return user, err
+}
+
+func (s *SSOService) complete(ctx context.Context, state, code string) (*domain.User, error) {
+	s.mu.Lock()
+	login, ok := s.pending[state]
+	delete(s.pending, state) // A state is good for one attempt
+	s.mu.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		return nil, ErrSSOState
+	}
+
+	rawToken, err := s.provider.Exchange(ctx, code, login.verifier, login.redirectURI)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.provider.Verify(ctx, rawToken, login.nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	role, ok := s.mapRole(claims.Strings(s.cfg.GroupsClaim))
+	if !ok {
+		logging.FromContext(ctx).Warn("single sign-on refused: no mapped group", "subject", claims.Subject, "groups", claims.Strings(s.cfg.GroupsClaim))
+		return nil, ErrNoRoleMapping
+	}
+	return s.provision(ctx, claims, role)
+}
+
+// mapRole returns the most privileged role granted by any of the groups
+func (s *SSOService) mapRole(groups []string) (domain.Role, bool) {
+	granted := make(map[domain.Role]bool)
+	for _, g := range groups {
+		if role, ok := s.roles[g]; ok {
+			granted[role] = true
+		}
+	}
+	for _, role := range domain.Roles {
+		if granted[role] {
+			return role, true
+		}
+	}
+	return "", false
+}
+
+// provision finds or creates the user for the token's subject and brings
+// their role and name in line with the provider
+func (s *SSOService) provision(ctx context.Context, claims *oidc.Claims, role domain.Role) (*domain.User, error) {
+	externalID := claims.Issuer + "|" + claims.Subject
+	first, last := claimNames(claims)
+	// Stored emails are lower case, as LoginStaff looks them up
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	now := time.Now()
+
+	user := s.userRepo.GetByExternalID(externalID)
+	if user == nil && email != "" && claims.EmailVerified {
+		// Link an account created before single sign-on was enabled
+		if existing := s.userRepo.GetByEmail(email); existing != nil && existing.ExternalID == "" {
+			user = existing
+			user.ExternalID = externalID
+		}
+	}
+
+	if user == nil {
+		if email == "" {
+			return nil, fmt.Errorf("%w: no email claim", oidc.ErrInvalidToken)
+		}
+		user = &domain.User{
+			ID:          fmt.Sprintf("user_%d", now.UnixNano()),
+			Email:       email,
+			FirstName:   first,
+			LastName:    last,
+			Role:        role,
+			IsActive:    true,
+			ExternalID:  externalID,
+			LastLoginAt: &now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := s.userRepo.Create(user); err != nil {
+			return nil, err
+		}
+		logging.FromContext(ctx).Info("user provisioned", "user_id", user.ID, "email", user.Email, "role", user.Role, "issuer", claims.Issuer)
+		return user, nil
+	}
+
+	if !user.IsActive {
+		logging.FromContext(ctx).Warn("single sign-on refused: account deactivated", "user_id", user.ID)
+		return nil, ErrUserInactive
+	}
+	if user.Role != role {
+		logging.FromContext(ctx).Info("role changed from provider groups", "user_id", user.ID, "from", user.Role, "to", role)
+		user.Role = role
+	}
+	if first != "" || last != "" {
+		user.FirstName, user.LastName = first, last
+	}
+	user.LastLoginAt = &now
+	user.UpdatedAt = now
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("single sign-on login", "user_id", user.ID, "email", user.Email, "role", user.Role)
+	return user, nil
+}
+
+// claimNames returns the user's first and last name from the given_name and
+// family_name claims, falling back to splitting name
+func claimNames(c *oidc.Claims) (first, last string) {
+	if c.GivenName != "" || c.FamilyName != "" {
+		return c.GivenName, c.FamilyName
+	}
+	first, last, _ = strings.Cut(strings.TrimSpace(c.Name), " ")
+	return first, strings.TrimSpace(last)
+}
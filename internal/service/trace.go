@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/tracing"
+)
+
+// traced runs a repository call in a span named for it, e.g.
+// "CaseRepository.GetByID", as a child of the span in ctx
+func traced[T any](ctx context.Context, name string, call func() T) T {
+	_, span := tracing.Start(ctx, name)
+	defer span.End()
+	return call()
+}
+
+// tracedErr is traced for calls that return only an error, which the span
+// records
+func tracedErr(ctx context.Context, name string, call func() error) error {
+	_, span := tracing.Start(ctx, name)
+	defer span.End()
+	err := call()
+	span.RecordError(err)
+	return err
+}
+
+// endSpan ends a span, recording *err if the traced function failed; defer
+// it in functions with a named error result
+func endSpan(span *tracing.Span, err *error) {
+	span.RecordError(*err)
+	span.End()
+}
+
+// tracedCaseRepository traces each call to a CaseRepository made while
+// serving a CaseService method
+type tracedCaseRepository struct {
+	ctx  context.Context
+	repo CaseRepository
+}
+
+func (r tracedCaseRepository) Create(c *domain.Case) error {
+	return tracedErr(r.ctx, "CaseRepository.Create", func() error { return r.repo.Create(c) })
+}
+
+func (r tracedCaseRepository) Update(c *domain.Case) error {
+	return tracedErr(r.ctx, "CaseRepository.Update", func() error { return r.repo.Update(c) })
+}
+
+func (r tracedCaseRepository) GetByID(id string) *domain.Case {
+	return traced(r.ctx, "CaseRepository.GetByID", func() *domain.Case { return r.repo.GetByID(id) })
+}
+
+func (r tracedCaseRepository) GetByCaseNumber(num string) *domain.Case {
+	return traced(r.ctx, "CaseRepository.GetByCaseNumber", func() *domain.Case { return r.repo.GetByCaseNumber(num) })
+}
+
+func (r tracedCaseRepository) List(typeFilter, statusFilter, query string) []*domain.Case {
+	return traced(r.ctx, "CaseRepository.List", func() []*domain.Case { return r.repo.List(typeFilter, statusFilter, query) })
+}
+
+func (r tracedCaseRepository) GetRecent(limit int) []*domain.Case {
+	return traced(r.ctx, "CaseRepository.GetRecent", func() []*domain.Case { return r.repo.GetRecent(limit) })
+}
+
+func (r tracedCaseRepository) GetDocuments(caseID string) []*domain.Document {
+	return traced(r.ctx, "CaseRepository.GetDocuments", func() []*domain.Document { return r.repo.GetDocuments(caseID) })
+}
+
+func (r tracedCaseRepository) GetDocument(id string) *domain.Document {
+	return traced(r.ctx, "CaseRepository.GetDocument", func() *domain.Document { return r.repo.GetDocument(id) })
+}
+
+func (r tracedCaseRepository) AddDocument(d *domain.Document) error {
+	return tracedErr(r.ctx, "CaseRepository.AddDocument", func() error { return r.repo.AddDocument(d) })
+}
+
+func (r tracedCaseRepository) UpdateDocument(d *domain.Document) error {
+	return tracedErr(r.ctx, "CaseRepository.UpdateDocument", func() error { return r.repo.UpdateDocument(d) })
+}
+
+func (r tracedCaseRepository) GetNotes(caseID string) []*domain.CaseNote {
+	return traced(r.ctx, "CaseRepository.GetNotes", func() []*domain.CaseNote { return r.repo.GetNotes(caseID) })
+}
+
+func (r tracedCaseRepository) GetActivity(caseID string) []*domain.CaseActivity {
+	return traced(r.ctx, "CaseRepository.GetActivity", func() []*domain.CaseActivity { return r.repo.GetActivity(caseID) })
+}
+
+func (r tracedCaseRepository) AddActivity(a *domain.CaseActivity) error {
+	return tracedErr(r.ctx, "CaseRepository.AddActivity", func() error { return r.repo.AddActivity(a) })
+}
+
+func (r tracedCaseRepository) RecentActivity(limit int) []*domain.CaseActivity {
+	return traced(r.ctx, "CaseRepository.RecentActivity", func() []*domain.CaseActivity { return r.repo.RecentActivity(limit) })
+}
+
+func (r tracedCaseRepository) CountCases() domain.CaseCounts {
+	return traced(r.ctx, "CaseRepository.CountCases", r.repo.CountCases)
+}
+
+func (r tracedCaseRepository) CountSubmitted(from, to time.Time) int {
+	return traced(r.ctx, "CaseRepository.CountSubmitted", func() int { return r.repo.CountSubmitted(from, to) })
+}
+
+func (r tracedCaseRepository) CountResolved(from, to time.Time) int {
+	return traced(r.ctx, "CaseRepository.CountResolved", func() int { return r.repo.CountResolved(from, to) })
+}
+
+func (r tracedCaseRepository) GetDeadlines(limit int) []*domain.Deadline {
+	return traced(r.ctx, "CaseRepository.GetDeadlines", func() []*domain.Deadline { return r.repo.GetDeadlines(limit) })
+}
+
+func (r tracedCaseRepository) GetAllDeadlines() []*domain.Deadline {
+	return traced(r.ctx, "CaseRepository.GetAllDeadlines", r.repo.GetAllDeadlines)
+}
+
+func (r tracedCaseRepository) NextCaseNumber(caseType domain.CaseType, prefix string) string {
+	return traced(r.ctx, "CaseRepository.NextCaseNumber", func() string { return r.repo.NextCaseNumber(caseType, prefix) })
+}
+
+// tracedUserRepository traces each call to a UserRepository made while
+// serving a CaseService method
+type tracedUserRepository struct {
+	ctx  context.Context
+	repo UserRepository
+}
+
+func (r tracedUserRepository) GetByEmail(email string) *domain.User {
+	return traced(r.ctx, "UserRepository.GetByEmail", func() *domain.User { return r.repo.GetByEmail(email) })
+}
+
+func (r tracedUserRepository) GetByID(id string) *domain.User {
+	return traced(r.ctx, "UserRepository.GetByID", func() *domain.User { return r.repo.GetByID(id) })
+}
+
+func (r tracedUserRepository) GetByExternalID(externalID string) *domain.User {
+	return traced(r.ctx, "UserRepository.GetByExternalID", func() *domain.User { return r.repo.GetByExternalID(externalID) })
+}
+
+func (r tracedUserRepository) GetByPasswordToken(hash string) *domain.User {
+	return traced(r.ctx, "UserRepository.GetByPasswordToken", func() *domain.User { return r.repo.GetByPasswordToken(hash) })
+}
+
+func (r tracedUserRepository) List() []*domain.User {
+	return traced(r.ctx, "UserRepository.List", r.repo.List)
+}
+
+func (r tracedUserRepository) Create(u *domain.User) error {
+	return tracedErr(r.ctx, "UserRepository.Create", func() error { return r.repo.Create(u) })
+}
+
+func (r tracedUserRepository) Update(u *domain.User) error {
+	return tracedErr(r.ctx, "UserRepository.Update", func() error { return r.repo.Update(u) })
+}
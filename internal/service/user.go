@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+)
+
+const (
+	// inviteTTL is how long an invitation link can be used
+	inviteTTL = 7 * 24 * time.Hour
+	// passwordResetTTL is how long a password reset link can be used
+	passwordResetTTL = 24 * time.Hour
+)
+
+var (
+	// ErrUserForbidden is returned when a non-admin manages users
+	ErrUserForbidden = errors.New("only administrators can manage users")
+	// ErrUserNotFound is returned for unknown user IDs
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when inviting an email that already has an account
+	ErrUserExists = errors.New("a user with that email already exists")
+	// ErrOwnAccount is returned when admins deactivate or demote themselves
+	ErrOwnAccount = errors.New("you cannot deactivate your own account or change your own role")
+	// ErrPasswordLink is returned for unknown, used or expired invitation and reset links
+	ErrPasswordLink = errors.New("this link has expired or has already been used")
+	// ErrWeakPassword is returned for passwords that are too short or do not match
+	ErrWeakPassword = fmt.Errorf("passwords must match and be at least %d characters", MinPasswordLength)
+)
+
+// UserInput is the editable part of a user account
+type UserInput struct {
+	Email     string // Only used when inviting; email addresses cannot be changed
+	FirstName string
+	LastName  string
+	Role      domain.Role
+	Title     string
+	Phone     string
+}
+
+// UserService lets admins invite, edit, deactivate and reactivate staff
+// and send them password reset links. Every change is audited.
+type UserService struct {
+	userRepo    UserRepository
+	sessionRepo SessionRepository
+	audit       *AuditService
+}
+
+func NewUserService(userRepo UserRepository, sessionRepo SessionRepository, audit *AuditService) *UserService {
+	return &UserService{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		audit:       audit,
+	}
+}
+
+// List returns every user, by name
+func (s *UserService) List(admin *domain.User) ([]*domain.User, error) {
+	if admin == nil || !admin.CanManageUsers() {
+		return nil, ErrUserForbidden
+	}
+	return s.userRepo.List(), nil
+}
+
+// Get returns one user
+func (s *UserService) Get(admin *domain.User, id string) (*domain.User, error) {
+	if admin == nil || !admin.CanManageUsers() {
+		return nil, ErrUserForbidden
+	}
+	user := s.userRepo.GetByID(id)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// Invite creates an account and returns it with the token for the link that
+// lets the new user choose a password. The token is only returned here.
+func (s *UserService) Invite(ctx context.Context, admin *domain.User, in UserInput) (*domain.User, string, error) {
+	if admin == nil || !admin.CanManageUsers() {
+		return nil, "", ErrUserForbidden
+	}
+	in, err := cleanUserInput(in)
+	if err != nil {
+		return nil, "", err
+	}
+	addr, err := mail.ParseAddress(in.Email)
+	if err != nil || addr.Address != in.Email {
+		return nil, "", fmt.Errorf("invalid email address: %q", in.Email)
+	}
+	email := strings.ToLower(addr.Address)
+	if s.userRepo.GetByEmail(email) != nil {
+		return nil, "", ErrUserExists
+	}
+
+	now := time.Now()
+	token := generateToken()
+	user := &domain.User{
+		ID:                     fmt.Sprintf("user_%d", now.UnixNano()),
+		Email:                  email,
+		FirstName:              in.FirstName,
+		LastName:               in.LastName,
+		Role:                   in.Role,
+		Title:                  in.Title,
+		Phone:                  in.Phone,
+		IsActive:               true,
+		PasswordTokenHash:      sha256Hex([]byte(token)),
+		PasswordTokenExpiresAt: now.Add(inviteTTL),
+		InvitedAt:              &now,
+		CreatedAt:              now,
+		UpdatedAt:              now,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, "", err
+	}
+	s.audit.Record(ctx, admin, domain.AuditUserInvited, user, fmt.Sprintf("email: %s, role: %s", user.Email, user.Role))
+	return user, token, nil
+}
+
+// Update changes a user's name, role, title and phone
+func (s *UserService) Update(ctx context.Context, admin *domain.User, id string, in UserInput) (*domain.User, error) {
+	user, err := s.Get(admin, id)
+	if err != nil {
+		return nil, err
+	}
+	in, err = cleanUserInput(in)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == admin.ID && in.Role != user.Role {
+		return nil, ErrOwnAccount
+	}
+
+	var changes []string
+	change := func(field string, old *string, value string) {
+		if *old != value {
+			changes = append(changes, fmt.Sprintf("%s: %q → %q", field, *old, value))
+			*old = value
+		}
+	}
+	change("first name", &user.FirstName, in.FirstName)
+	change("last name", &user.LastName, in.LastName)
+	change("title", &user.Title, in.Title)
+	change("phone", &user.Phone, in.Phone)
+	if user.Role != in.Role {
+		changes = append(changes, fmt.Sprintf("role: %s → %s", user.Role, in.Role))
+		user.Role = in.Role
+	}
+	if len(changes) == 0 {
+		return user, nil
+	}
+
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, admin, domain.AuditUserUpdated, user, strings.Join(changes, ", "))
+	return user, nil
+}
+
+// SetActive deactivates or reactivates a user. Deactivated users cannot sign
+// in by any method and are signed out at once; their API tokens stop working.
+func (s *UserService) SetActive(ctx context.Context, admin *domain.User, id string, active bool) error {
+	user, err := s.Get(admin, id)
+	if err != nil {
+		return err
+	}
+	if user.ID == admin.ID {
+		return ErrOwnAccount
+	}
+	if user.IsActive == active {
+		return nil
+	}
+
+	user.IsActive = active
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	if active {
+		s.audit.Record(ctx, admin, domain.AuditUserReactivated, user, "")
+		return nil
+	}
+	signedOut := s.signOut(ctx, user.ID)
+	s.audit.Record(ctx, admin, domain.AuditUserDeactivated, user, fmt.Sprintf("%d sessions ended", signedOut))
+	return nil
+}
+
+// ResetPassword invalidates a user's password, signs them out and returns
+// the token for a link that lets them choose a new one
+func (s *UserService) ResetPassword(ctx context.Context, admin *domain.User, id string) (*domain.User, string, error) {
+	user, err := s.Get(admin, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if !user.IsActive {
+		return nil, "", errors.New("reactivate the user before resetting their password")
+	}
+
+	now := time.Now()
+	token := generateToken()
+	user.PasswordHash = ""
+	user.PasswordTokenHash = sha256Hex([]byte(token))
+	if user.InvitedAt != nil {
+		// A new invitation link; the old one stops working
+		user.PasswordTokenExpiresAt = now.Add(inviteTTL)
+	} else {
+		user.PasswordTokenExpiresAt = now.Add(passwordResetTTL)
+	}
+	user.UpdatedAt = now
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, "", err
+	}
+	signedOut := s.signOut(ctx, user.ID)
+	s.audit.Record(ctx, admin, domain.AuditUserPasswordReset, user, fmt.Sprintf("reset link issued, %d sessions ended", signedOut))
+	return user, token, nil
+}
+
+// PasswordLinkUser returns the user an invitation or reset link is for
+func (s *UserService) PasswordLinkUser(token string) (*domain.User, error) {
+	if token == "" {
+		return nil, ErrPasswordLink
+	}
+	user := s.userRepo.GetByPasswordToken(sha256Hex([]byte(token)))
+	if user == nil || !user.IsActive || time.Now().After(user.PasswordTokenExpiresAt) {
+		return nil, ErrPasswordLink
+	}
+	return user, nil
+}
+
+// SetPassword uses an invitation or reset link to set the user's password.
+// The link works once.
+func (s *UserService) SetPassword(ctx context.Context, token, password, confirm string) (*domain.User, error) {
+	user, err := s.PasswordLinkUser(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(password) < MinPasswordLength || password != confirm {
+		return nil, ErrWeakPassword
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	details := "password reset"
+	if user.InvitedAt != nil {
+		details = "invitation accepted"
+	}
+	user.PasswordHash = hash
+	user.PasswordTokenHash = ""
+	user.PasswordTokenExpiresAt = time.Time{}
+	user.InvitedAt = nil
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, user, domain.AuditUserPasswordSet, user, details)
+	return user, nil
+}
+
+// signOut ends every session of the user and returns how many were ended
+func (s *UserService) signOut(ctx context.Context, userID string) int {
+	return revokeSessions(ctx, s.sessionRepo, userID)
+}
+
+// revokeSessions ends every session of the user and returns how many were
+// ended. Admin actions that lock a user out, or make them prove who they
+// are again, sign them out this way.
+func revokeSessions(ctx context.Context, sessionRepo SessionRepository, userID string) int {
+	sessions := sessionRepo.ListByUser(userID)
+	for _, session := range sessions {
+		sessionRepo.Delete(session.Token)
+	}
+	if len(sessions) > 0 {
+		logging.FromContext(ctx).Info("sessions revoked", "user_id", userID, "sessions", len(sessions))
+	}
+	return len(sessions)
+}
+
+// cleanUserInput trims the input and checks the required fields
+func cleanUserInput(in UserInput) (UserInput, error) {
+	in.Email = strings.TrimSpace(in.Email)
+	in.FirstName = strings.TrimSpace(in.FirstName)
+	in.LastName = strings.TrimSpace(in.LastName)
+	in.Title = strings.TrimSpace(in.Title)
+	in.Phone = strings.TrimSpace(in.Phone)
+	if in.FirstName == "" || in.LastName == "" {
+		return in, errors.New("first and last name are required")
+	}
+	if !in.Role.IsValid() {
+		return in, fmt.Errorf("unknown role: %q", in.Role)
+	}
+	return in, nil
+}
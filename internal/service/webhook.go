@@ -0,0 +1,562 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/metrics"
+)
+
+type WebhookRepository interface {
+	Create(w *domain.Webhook) error
+	Update(w *domain.Webhook) error
+	Delete(id string) error
+	GetByID(id string) *domain.Webhook
+	List() []domain.Webhook
+	CreateDelivery(d *domain.WebhookDelivery) error
+	UpdateDelivery(d *domain.WebhookDelivery) error
+	GetDelivery(id string) *domain.WebhookDelivery
+	ListDeliveries(webhookID string) []domain.WebhookDelivery
+}
+
+// EventPublisher receives case lifecycle events as they happen. WebhookService
+// implements it; data is marshaled as the "data" member of the event payload.
+type EventPublisher interface {
+	Publish(ctx context.Context, event domain.WebhookEvent, data interface{})
+}
+
+var (
+	// ErrWebhookForbidden is returned when a non-admin manages webhooks
+	ErrWebhookForbidden = errors.New("user is not permitted to manage webhooks")
+	// ErrWebhookNotFound is returned for unknown webhook or delivery IDs
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrInvalidSignature is returned by VerifySignature for bad or stale signatures
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// webhookDeliveries counts finished deliveries
+var webhookDeliveries = metrics.NewCounter("ncoe_webhook_deliveries_total",
+	"Webhook deliveries finished, by status: succeeded, or failed after retries.", "status")
+
+// Webhook request headers
+const (
+	HeaderWebhookEvent     = "X-NCOE-Event"
+	HeaderWebhookDelivery  = "X-NCOE-Delivery"
+	HeaderWebhookSignature = "X-NCOE-Signature"
+)
+
+const (
+	webhookTimeout      = 10 * time.Second
+	maxWebhookResponse  = 1024 // Bytes of the receiver's response kept in the delivery log
+	webhookSecretPrefix = "whsec_"
+)
+
+// RetryPolicy controls how failed deliveries are retried. The delay after the
+// nth failed attempt is BaseDelay * 2^(n-1), capped at MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy retries over roughly half an hour: 30s, 1m, 2m, 4m, 8m, 16m
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 7, BaseDelay: 30 * time.Second, MaxDelay: time.Hour}
+
+// Backoff returns the delay before retrying after the given number of failed attempts
+func (p RetryPolicy) Backoff(failures int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// WebhookService registers webhook endpoints and delivers signed event
+// notifications to them. Deliveries run in the background and are retried
+// with exponential backoff; every attempt is kept in the delivery log.
+// Shutdown stops them.
+type WebhookService struct {
+	repo   WebhookRepository
+	client *http.Client
+	policy RetryPolicy
+
+	mu     sync.Mutex // Held to start a delivery, so none starts after Shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewWebhookService(repo WebhookRepository, policy RetryPolicy) *WebhookService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookService{
+		repo:   repo,
+		ctx:    ctx,
+		cancel: cancel,
+		client: &http.Client{
+			Timeout: webhookTimeout,
+			// A redirect is treated as a failed delivery rather than followed
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		policy: policy,
+	}
+}
+
+// List returns all registered webhooks
+func (s *WebhookService) List() []domain.Webhook {
+	return s.repo.List()
+}
+
+// Get returns a webhook by ID, or nil
+func (s *WebhookService) Get(id string) *domain.Webhook {
+	return s.repo.GetByID(id)
+}
+
+// Deliveries returns the delivery log for a webhook, newest first
+func (s *WebhookService) Deliveries(webhookID string) []domain.WebhookDelivery {
+	return s.repo.ListDeliveries(webhookID)
+}
+
+// GetDelivery returns a logged delivery by ID, or nil
+func (s *WebhookService) GetDelivery(id string) *domain.WebhookDelivery {
+	return s.repo.GetDelivery(id)
+}
+
+// Create registers an endpoint for the given events and generates its signing secret
+func (s *WebhookService) Create(ctx context.Context, user *domain.User, rawURL, description string, events []domain.WebhookEvent) (*domain.Webhook, error) {
+	if user == nil || !user.CanManageWebhooks() {
+		return nil, ErrWebhookForbidden
+	}
+	endpoint, err := validateWebhookURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, errors.New("select at least one event")
+	}
+	for _, e := range events {
+		if !e.IsValid() {
+			return nil, fmt.Errorf("unknown event: %q", e)
+		}
+	}
+
+	now := time.Now()
+	hook := &domain.Webhook{
+		ID:          fmt.Sprintf("wh_%d", now.UnixNano()),
+		URL:         endpoint,
+		Description: strings.TrimSpace(description),
+		Events:      dedupeEvents(events),
+		Secret:      generateWebhookSecret(),
+		IsActive:    true,
+		CreatedBy:   user.ID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.Create(hook); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("webhook created", "webhook_id", hook.ID, "url", hook.URL, "events", hook.Events, "actor_id", user.ID)
+	return hook, nil
+}
+
+// SetActive enables or disables a webhook. Disabled webhooks receive no new
+// events and their pending retries are abandoned.
+func (s *WebhookService) SetActive(ctx context.Context, user *domain.User, id string, active bool) error {
+	if user == nil || !user.CanManageWebhooks() {
+		return ErrWebhookForbidden
+	}
+	hook := s.repo.GetByID(id)
+	if hook == nil {
+		return fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+	}
+	hook.IsActive = active
+	hook.UpdatedAt = time.Now()
+	if err := s.repo.Update(hook); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("webhook updated", "webhook_id", hook.ID, "active", active, "actor_id", user.ID)
+	return nil
+}
+
+// Delete removes a webhook and its delivery log
+func (s *WebhookService) Delete(ctx context.Context, user *domain.User, id string) error {
+	if user == nil || !user.CanManageWebhooks() {
+		return ErrWebhookForbidden
+	}
+	if s.repo.GetByID(id) == nil {
+		return fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("webhook deleted", "webhook_id", id, "actor_id", user.ID)
+	return nil
+}
+
+// Redeliver sends a logged delivery's payload again as a new delivery with a
+// fresh signature. The event ID is unchanged so receivers can deduplicate.
+func (s *WebhookService) Redeliver(ctx context.Context, user *domain.User, deliveryID string) (*domain.WebhookDelivery, error) {
+	if user == nil || !user.CanManageWebhooks() {
+		return nil, ErrWebhookForbidden
+	}
+	original := s.repo.GetDelivery(deliveryID)
+	if original == nil {
+		return nil, fmt.Errorf("%w: delivery %s", ErrWebhookNotFound, deliveryID)
+	}
+	hook := s.repo.GetByID(original.WebhookID)
+	if hook == nil {
+		return nil, fmt.Errorf("%w: %s", ErrWebhookNotFound, original.WebhookID)
+	}
+	if !hook.IsActive {
+		return nil, errors.New("enable the webhook before redelivering")
+	}
+
+	d, err := s.enqueue(hook, original.Event, original.EventID, original.Payload, original.ID)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("webhook redelivery queued", "delivery_id", d.ID, "original_id", original.ID, "actor_id", user.ID)
+	s.start(d)
+	return d, nil
+}
+
+// Publish queues a delivery of the event to every active webhook subscribed
+// to it. It returns immediately; delivery happens in the background.
+func (s *WebhookService) Publish(ctx context.Context, event domain.WebhookEvent, data interface{}) {
+	now := time.Now()
+	eventID := fmt.Sprintf("evt_%d", now.UnixNano())
+	payload, err := json.Marshal(webhookPayload{ID: eventID, Type: event, CreatedAt: now.UTC(), Data: data})
+	if err != nil {
+		logging.FromContext(ctx).Error("encoding webhook event", "event", event, "error", err)
+		return
+	}
+
+	for _, hook := range s.repo.List() {
+		if !hook.Subscribes(event) {
+			continue
+		}
+		d, err := s.enqueue(&hook, event, eventID, payload, "")
+		if err != nil {
+			logging.FromContext(ctx).Error("queueing webhook delivery", "event", event, "webhook_id", hook.ID, "error", err)
+			continue
+		}
+		s.start(d)
+	}
+}
+
+// Wait blocks until every in-flight delivery, including its retries, has finished
+func (s *WebhookService) Wait() {
+	s.wg.Wait()
+}
+
+// Shutdown stops retrying and waits for attempts under way to finish, or
+// for ctx to be done. Deliveries waiting to retry, or queued after
+// Shutdown, are left pending.
+func (s *WebhookService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.cancel()
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// webhookPayload is the JSON body of every webhook request
+type webhookPayload struct {
+	ID        string              `json:"id"`
+	Type      domain.WebhookEvent `json:"type"`
+	CreatedAt time.Time           `json:"created_at"`
+	Data      interface{}         `json:"data"`
+}
+
+func (s *WebhookService) enqueue(hook *domain.Webhook, event domain.WebhookEvent, eventID string, payload []byte, redeliveryOf string) (*domain.WebhookDelivery, error) {
+	d := &domain.WebhookDelivery{
+		ID:           fmt.Sprintf("whd_%d", time.Now().UnixNano()),
+		WebhookID:    hook.ID,
+		EventID:      eventID,
+		Event:        event,
+		Payload:      payload,
+		Status:       domain.DeliveryPending,
+		RedeliveryOf: redeliveryOf,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.repo.CreateDelivery(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *WebhookService) start(d *domain.WebhookDelivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return // Shutting down
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.deliver(d)
+	}()
+}
+
+// deliver makes attempts until the receiver answers 2xx, the retry policy is
+// exhausted, the webhook is disabled or deleted, or the service shuts down
+func (s *WebhookService) deliver(d *domain.WebhookDelivery) {
+	for {
+		hook := s.repo.GetByID(d.WebhookID)
+		if hook == nil {
+			return // Deleted along with its delivery log
+		}
+		if !hook.IsActive {
+			s.finish(d, domain.DeliveryFailed)
+			return
+		}
+
+		attempt := s.attempt(hook, d)
+		d.Attempts = append(d.Attempts, attempt)
+		if attempt.Succeeded() {
+			s.finish(d, domain.DeliverySucceeded)
+			return
+		}
+		if len(d.Attempts) >= s.policy.MaxAttempts {
+			s.finish(d, domain.DeliveryFailed)
+			return
+		}
+
+		next := time.Now().Add(s.policy.Backoff(len(d.Attempts)))
+		d.NextAttemptAt = &next
+		if err := s.repo.UpdateDelivery(d); err != nil {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return // Left pending, with the time of its next attempt
+		}
+	}
+}
+
+func (s *WebhookService) finish(d *domain.WebhookDelivery, status domain.DeliveryStatus) {
+	now := time.Now()
+	d.Status = status
+	d.NextAttemptAt = nil
+	d.CompletedAt = &now
+	s.repo.UpdateDelivery(d)
+	webhookDeliveries.Inc(string(status))
+
+	slog.Info("webhook delivery", "delivery_id", d.ID, "webhook_id", d.WebhookID,
+		"event", d.Event, "status", status, "attempts", len(d.Attempts))
+}
+
+// attempt POSTs the payload once, signed with the webhook's current secret
+func (s *WebhookService) attempt(hook *domain.Webhook, d *domain.WebhookDelivery) domain.DeliveryAttempt {
+	start := time.Now()
+	result := domain.DeliveryAttempt{Number: len(d.Attempts) + 1, At: start}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NCOE-Webhooks/1.0")
+	req.Header.Set(HeaderWebhookEvent, string(d.Event))
+	req.Header.Set(HeaderWebhookDelivery, d.ID)
+	req.Header.Set(HeaderWebhookSignature, SignPayload(hook.Secret, start, d.Payload))
+
+	resp, err := s.client.Do(req)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+	result.StatusCode = resp.StatusCode
+	result.ResponseBody = string(body)
+	if !result.Succeeded() {
+		result.Error = "receiver responded " + resp.Status
+	}
+	return result
+}
+
+// SignPayload returns the signature header value for a webhook body:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Including the
+// timestamp in the signed content lets receivers reject replayed requests.
+func SignPayload(secret string, at time.Time, body []byte) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+// VerifySignature checks a signature header produced by SignPayload and
+// rejects timestamps more than tolerance away from now
+func VerifySignature(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateWebhookURL accepts absolute http and https URLs without credentials
+func validateWebhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("webhook URL must be an absolute http or https URL: %q", raw)
+	}
+	if u.User != nil {
+		return "", errors.New("webhook URL must not contain credentials")
+	}
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+func dedupeEvents(events []domain.WebhookEvent) []domain.WebhookEvent {
+	var result []domain.WebhookEvent
+	for _, candidate := range domain.WebhookEvents {
+		for _, e := range events {
+			if e == candidate.Event {
+				result = append(result, e)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func generateWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(b)
+}
+
+// caseEventData is the "data" of case.* events. Submitter and subject details
+// are left out: complaints are confidential until the Commission acts on them.
+type caseEventData struct {
+	ID                 string     `json:"id"`
+	CaseNumber         string     `json:"case_number"`
+	Type               string     `json:"type"`
+	Status             string     `json:"status"`
+	Priority           string     `json:"priority,omitempty"`
+	SubmittedAt        time.Time  `json:"submitted_at"`
+	DueDate            *time.Time `json:"due_date"`
+	AssignedTo         string     `json:"assigned_to"`
+	AssignedToName     string     `json:"assigned_to_name"`
+	PreviousStatus     string     `json:"previous_status,omitempty"`
+	PreviousAssignedTo string     `json:"previous_assigned_to,omitempty"`
+}
+
+func newCaseEventData(c *domain.Case) caseEventData {
+	data := caseEventData{
+		ID:             c.ID,
+		CaseNumber:     c.CaseNumber,
+		Type:           string(c.Type),
+		Status:         string(c.Status),
+		Priority:       c.Priority,
+		SubmittedAt:    c.SubmittedAt.UTC(),
+		AssignedTo:     c.AssignedTo,
+		AssignedToName: c.AssignedToName,
+	}
+	if !c.DueDate.IsZero() {
+		due := c.DueDate.UTC()
+		data.DueDate = &due
+	}
+	return data
+}
+
+// opinionEventData is the "data" of opinion.published events
+type opinionEventData struct {
+	CaseID      string    `json:"case_id"`
+	CaseNumber  string    `json:"case_number"`
+	Type        string    `json:"type"`
+	Title       string    `json:"title"`
+	Summary     string    `json:"summary"`
+	Statutes    []string  `json:"statutes"`
+	Path        string    `json:"path"` // Public opinion page, relative to the site
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// deadlineReminderData is the "data" of deadline.reminder events
+type deadlineReminderData struct {
+	ID           string    `json:"id"`
+	CaseID       string    `json:"case_id"`
+	CaseNumber   string    `json:"case_number"`
+	CaseType     string    `json:"case_type"`
+	Type         string    `json:"type"`
+	DueDate      time.Time `json:"due_date"`
+	DaysUntilDue int       `json:"days_until_due"`
+	ReminderDays int       `json:"reminder_days"` // The scheduled reminder reached, e.g. 7 for the one-week reminder
+}
+
+// deadlineEventData is the "data" of deadline.overdue events
+type deadlineEventData struct {
+	ID          string    `json:"id"`
+	CaseID      string    `json:"case_id"`
+	CaseNumber  string    `json:"case_number"`
+	CaseType    string    `json:"case_type"`
+	Type        string    `json:"type"`
+	DueDate     time.Time `json:"due_date"`
+	DaysOverdue int       `json:"days_overdue"`
+}
@@ -0,0 +1,70 @@
+// Package statute extracts NRS 281A (Ethics in Government Law) citations
+// from free text and normalizes them.
+package statute
+
+import (
+	"regexp"
+	"strings"
+
+	"ncoe/internal/domain"
+)
+
+// Chapter is the NRS chapter covered by the Ethics in Government Law
+const Chapter = "281A"
+
+var (
+	// "NRS 281A.400(2)(a)", "281a.400 (2)", "N.R.S. 281A.420"
+	citationPattern   = regexp.MustCompile(`(?i)\b(?:N\.?R\.?S\.?\s*)?281A\.(\d{3,4})\b((?:\s*\(\s*(?:\d{1,3}|[a-z]{1,2}|[ivx]{1,5})\s*\))*)`)
+	subsectionPattern = regexp.MustCompile(`\(\s*([0-9a-zA-Z]+)\s*\)`)
+	sectionPattern    = regexp.MustCompile(`(?i)^\s*(?:N\.?R\.?S\.?\s*)?281A\.(\d{3,4})\s*$`)
+)
+
+// Parse returns the distinct NRS 281A citations in text, in order of first appearance
+func Parse(text string) []domain.StatuteCitation {
+	var result []domain.StatuteCitation
+	seen := map[string]bool{}
+	for _, m := range citationPattern.FindAllStringSubmatch(text, -1) {
+		c := domain.StatuteCitation{Chapter: Chapter, Section: m[1]}
+		for _, sub := range subsectionPattern.FindAllStringSubmatch(m[2], -1) {
+			c.Subsections = append(c.Subsections, normalizeSubsection(sub[1]))
+		}
+		if key := c.String(); !seen[key] {
+			seen[key] = true
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// ParseAll parses each entry and returns the combined distinct citations
+func ParseAll(texts ...string) []domain.StatuteCitation {
+	return Parse(strings.Join(texts, "\n"))
+}
+
+// ParseSection normalizes a section reference such as "281a.400" or
+// "NRS 281A.400" to its key ("281A.400"). Subsections are not accepted.
+func ParseSection(s string) (string, bool) {
+	m := sectionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return "", false
+	}
+	return Chapter + "." + m[1], true
+}
+
+// Strings returns the display form of each citation
+func Strings(citations []domain.StatuteCitation) []string {
+	result := make([]string, len(citations))
+	for i, c := range citations {
+		result[i] = c.String()
+	}
+	return result
+}
+
+// normalizeSubsection lowercases paragraph letters and strips leading zeros from numbers
+func normalizeSubsection(s string) string {
+	s = strings.ToLower(s)
+	if trimmed := strings.TrimLeft(s, "0"); trimmed != "" && trimmed[0] >= '1' && trimmed[0] <= '9' {
+		return trimmed
+	}
+	return s
+}
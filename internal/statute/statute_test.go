@@ -0,0 +1,67 @@
+package statute
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"Plain", "NRS 281A.400", []string{"NRS 281A.400"}},
+		{"Subsections", "NRS 281A.400(2)(a)", []string{"NRS 281A.400(2)(a)"}},
+		{"SpacedSubsections", "nrs 281a.400 (2) ( A )", []string{"NRS 281A.400(2)(a)"}},
+		{"LeadingZero", "NRS 281A.400(02)", []string{"NRS 281A.400(2)"}},
+		{"WithoutPrefix", "violated 281A.420 by voting", []string{"NRS 281A.420"}},
+		{"DottedPrefix", "N.R.S. 281A.480", []string{"NRS 281A.480"}},
+		{"Multiple", "NRS 281A.400(1), NRS 281A.420; and 281A.400(1) again", []string{"NRS 281A.400(1)", "NRS 281A.420"}},
+		{"ParentheticalProse", "NRS 281A.400 (see attached)", []string{"NRS 281A.400"}},
+		{"OtherChapter", "NRS 239.010 and NRS 281.230", nil},
+		{"ChapterOnly", "the provisions of NRS 281A", nil},
+		{"EmbeddedDigits", "ref 12281A.400", nil},
+		{"Empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.text)
+			var strs []string
+			if len(got) > 0 {
+				strs = Strings(got)
+			}
+			if !reflect.DeepEqual(strs, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.text, strs, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSectionKey(t *testing.T) {
+	got := Parse("NRS 281A.400(2)(a)")
+	if len(got) != 1 || got[0].SectionKey() != "281A.400" || got[0].SectionString() != "NRS 281A.400" {
+		t.Errorf("unexpected citation: %+v", got)
+	}
+}
+
+func TestParseSection(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"281A.400", "281A.400", true},
+		{"281a.400", "281A.400", true},
+		{"NRS 281A.420", "281A.420", true},
+		{"281A.400(2)", "", false},
+		{"239.010", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseSection(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseSection(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package templates
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+	"time"
+
+	"ncoe/internal/domain"
+)
+
+// Location is the agency's time zone. Templates show every time in it,
+// whatever zone the server runs in.
+var Location = domain.Location
+
+// Funcs returns the helper functions available to every template. now is
+// the clock for relative times, so tests can pin it.
+func Funcs(now func() time.Time) template.FuncMap {
+	return template.FuncMap{
+		// Dates and times in the agency's zone; zero times format as ""
+		"formatDate":      formatLayout("Jan 2, 2006"),
+		"formatDateTime":  formatLayout("Jan 2, 2006 3:04 PM"),
+		"formatDateLong":  formatLayout("January 2, 2006"),
+		"formatShortDate": formatLayout("Jan 2"),
+		"formatTime":      formatTime,
+		"timeAgo": func(t interface{}) (string, error) {
+			tm, err := toTime(t)
+			if err != nil || tm.IsZero() {
+				return "", err
+			}
+			return timeAgo(tm, now()), nil
+		},
+		"dueIn": func(t interface{}) (string, error) {
+			tm, err := toTime(t)
+			if err != nil || tm.IsZero() {
+				return "", err
+			}
+			return dueIn(tm, now()), nil
+		},
+		"formatDuration": formatDuration,
+		"plural":         plural,
+
+		"statusBadge": statusBadge,
+		"statusLabel": statusLabel,
+		"typeBadge":   typeBadge,
+		"typeLabel":   typeLabel,
+		"byteSize":    byteSize,
+		"nrsURL":      nrsURL,
+
+		"sub": func(a, b int) int {
+			return a - b
+		},
+		"add": func(a, b int) int {
+			return a + b
+		},
+		// percent scales n against total for chart bars
+		"percent": func(n, total int) int {
+			if total <= 0 {
+				return 0
+			}
+			return n * 100 / total
+		},
+	}
+}
+
+// toTime accepts a time.Time or *time.Time; nil is the zero time
+func toTime(t interface{}) (time.Time, error) {
+	switch t := t.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, nil
+		}
+		return *t, nil
+	case nil:
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("expected a time, got %T", t)
+}
+
+func formatLayout(layout string) func(interface{}) (string, error) {
+	return func(t interface{}) (string, error) {
+		return formatTime(t, layout)
+	}
+}
+
+// formatTime formats t in the agency's zone with a Go layout, for the rare
+// format the named helpers don't cover
+func formatTime(t interface{}, layout string) (string, error) {
+	tm, err := toTime(t)
+	if err != nil || tm.IsZero() {
+		return "", err
+	}
+	return tm.In(Location).Format(layout), nil
+}
+
+// calendarDays counts the days from one date to another in the agency's
+// zone, so 11 PM to 1 AM the next morning is one day
+func calendarDays(from, to time.Time) int {
+	date := func(t time.Time) time.Time {
+		y, m, d := t.In(Location).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	return int(date(to).Sub(date(from)).Hours() / 24)
+}
+
+// timeAgo describes t relative to now: "just now", "5 minutes ago",
+// "yesterday", "in 3 days". Beyond a month it gives the date.
+func timeAgo(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	relative := func(s string) string {
+		if future {
+			return "in " + s
+		}
+		return s + " ago"
+	}
+
+	switch days := calendarDays(t, now); {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return relative(plural(int(d/time.Minute), "minute"))
+	case d < 24*time.Hour || days == 0:
+		return relative(plural(int(d/time.Hour), "hour"))
+	case days == 1:
+		return "yesterday"
+	case days == -1:
+		return "tomorrow"
+	case days > -30 && days < 30:
+		return relative(plural(abs(days), "day"))
+	}
+	return t.In(Location).Format("Jan 2, 2006")
+}
+
+// dueIn describes a deadline in calendar days: "due in 3 days", "due today",
+// "3 days overdue"
+func dueIn(due, now time.Time) string {
+	switch days := calendarDays(now, due); {
+	case days == 0 && due.Before(now):
+		return "overdue today"
+	case days == 0:
+		return "due today"
+	case days == 1:
+		return "due tomorrow"
+	case days > 1:
+		return "due in " + plural(days, "day")
+	default:
+		return plural(-days, "day") + " overdue"
+	}
+}
+
+// formatDuration gives a duration in its two largest units: "45 minutes",
+// "2 hours 5 minutes", "3 days 4 hours"
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	days, hours, minutes := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	join := func(n int, unit string, m int, next string) string {
+		if m == 0 {
+			return plural(n, unit)
+		}
+		return plural(n, unit) + " " + plural(m, next)
+	}
+	switch {
+	case days > 0:
+		return join(days, "day", hours, "hour")
+	case hours > 0:
+		return join(hours, "hour", minutes, "minute")
+	case minutes > 0:
+		return plural(minutes, "minute")
+	}
+	return "less than a minute"
+}
+
+// plural gives a count with the word in the right number: "1 case",
+// "3 cases". Words that don't take an "s" pass their plural form:
+// {{plural .Count "inquiry" "inquiries"}}.
+func plural(n int, singular string, pluralForm ...string) string {
+	if n == 1 || n == -1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	if len(pluralForm) > 0 {
+		return fmt.Sprintf("%d %s", n, pluralForm[0])
+	}
+	return fmt.Sprintf("%d %ss", n, singular)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// statusBadges are the Bootstrap badge classes for case and deadline statuses
+var statusBadges = map[string]string{
+	string(domain.StatusSubmitted):      "bg-info",
+	string(domain.StatusUnderReview):    "bg-primary",
+	string(domain.StatusInvestigation):  "bg-warning text-dark",
+	string(domain.StatusPendingHearing): "bg-warning text-dark",
+	string(domain.StatusDraftPrepared):  "bg-secondary",
+	string(domain.StatusPublished):      "bg-success",
+	string(domain.StatusClosed):         "bg-dark",
+	string(domain.StatusWithdrawn):      "bg-light text-dark border",
+	"upcoming":                          "bg-secondary",
+	"due_soon":                          "bg-warning text-dark",
+	"overdue":                           "bg-danger",
+	"completed":                         "bg-success",
+}
+
+// typeBadges are the Bootstrap badge classes for case types
+var typeBadges = map[domain.CaseType]string{
+	domain.CaseTypeAdvisoryOpinion:      "bg-primary",
+	domain.CaseTypeEthicsComplaint:      "bg-danger",
+	domain.CaseTypeEthicsAcknowledgment: "bg-success",
+	domain.CaseTypePublicRecordsRequest: "bg-info",
+}
+
+// statusBadge returns the badge class for a case or deadline status
+func statusBadge(status interface{}) string {
+	if class, ok := statusBadges[fmt.Sprint(status)]; ok {
+		return class
+	}
+	return "bg-secondary"
+}
+
+// statusLabel returns the display name of a case or deadline status
+func statusLabel(status interface{}) string {
+	s := fmt.Sprint(status)
+	if cs := domain.CaseStatus(s); cs.IsValid() {
+		return cs.Label()
+	}
+	words := strings.Fields(strings.ReplaceAll(s, "_", " "))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// typeBadge returns the badge class for a case type
+func typeBadge(caseType interface{}) string {
+	if class, ok := typeBadges[domain.CaseType(fmt.Sprint(caseType))]; ok {
+		return class
+	}
+	return "bg-secondary"
+}
+
+// typeLabel returns the display name of a case type
+func typeLabel(caseType interface{}) string {
+	return domain.CaseType(fmt.Sprint(caseType)).Label()
+}
+
+// byteSize gives a size in binary units: "512 B", "1.5 KB", "24 MB"
+func byteSize(size interface{}) (string, error) {
+	var n int64
+	switch size := size.(type) {
+	case int64:
+		n = size
+	case int:
+		n = int64(size)
+	default:
+		return "", fmt.Errorf("expected a byte count, got %T", size)
+	}
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n), nil
+	}
+	value, unit := float64(n)/1024, "KB"
+	for _, next := range []string{"MB", "GB", "TB"} {
+		if value < 1024 {
+			break
+		}
+		value, unit = value/1024, next
+	}
+	if value < 10 {
+		return strings.Replace(fmt.Sprintf("%.1f %s", value, unit), ".0 ", " ", 1), nil
+	}
+	return fmt.Sprintf("%.0f %s", value, unit), nil
+}
+
+// nrsPattern matches "NRS 281A.400(2)", "281A.400", "NRS Chapter 239"
+var nrsPattern = regexp.MustCompile(`(?i)^\s*(?:N\.?R\.?S\.?\s*)?(?:chapter\s+)?(\d{1,3}[A-Z]?)(?:\.(\d{3,4}))?\s*(?:\(|$)`)
+
+// nrsURL links a citation, given as text or a domain.StatuteCitation, to
+// its section of the Nevada Revised Statutes on the Legislature's site.
+// Subsections link to their section. Anything else gives "", so a
+// citation typed by the public can never produce an arbitrary link.
+func nrsURL(citation interface{}) template.URL {
+	m := nrsPattern.FindStringSubmatch(fmt.Sprint(citation))
+	if m == nil {
+		return ""
+	}
+	chapter := strings.ToUpper(m[1])
+	u := "https://www.leg.state.nv.us/NRS/NRS-" + chapter + ".html"
+	if m[2] != "" {
+		u += "#NRS" + chapter + "Sec" + m[2]
+	}
+	return template.URL(u)
+}
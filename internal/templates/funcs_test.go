@@ -0,0 +1,234 @@
+package templates
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+)
+
+// now is 9:30 AM on Tuesday, March 10, 2026 in Carson City, two days after
+// daylight saving time began
+var now = time.Date(2026, time.March, 10, 9, 30, 0, 0, Location)
+
+// execute renders a one-line template with the helpers and a pinned clock
+func execute(t *testing.T, text string, data interface{}) string {
+	t.Helper()
+	tmpl, err := template.New("test").Funcs(Funcs(func() time.Time { return now })).Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestFormatDates(t *testing.T) {
+	// 1:15 AM UTC on March 11 is still the evening of March 10 in Nevada
+	utc := time.Date(2026, time.March, 11, 1, 15, 0, 0, time.UTC)
+	var unset *time.Time
+	data := map[string]interface{}{"T": utc, "P": &utc, "Nil": unset, "Zero": time.Time{}}
+
+	tests := []struct{ text, want string }{
+		{`{{formatDate .T}}`, "Mar 10, 2026"},
+		{`{{formatDateTime .T}}`, "Mar 10, 2026 6:15 PM"},
+		{`{{formatDateLong .T}}`, "March 10, 2026"},
+		{`{{formatShortDate .T}}`, "Mar 10"},
+		{`{{formatTime .T "2006-01-02 15:04 MST"}}`, "2026-03-10 18:15 PDT"},
+		{`{{formatDate .P}}`, "Mar 10, 2026"},
+		{`{{formatDate .Nil}}`, ""},
+		{`{{formatDateTime .Zero}}`, ""},
+	}
+	for _, tt := range tests {
+		if got := execute(t, tt.text, data); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDateRejectsNonTimes(t *testing.T) {
+	tmpl := template.Must(template.New("test").Funcs(Funcs(time.Now)).Parse(`{{formatDate .}}`))
+	if err := tmpl.Execute(&strings.Builder{}, "2026-03-10"); err == nil {
+		t.Error("formatDate of a string succeeded")
+	}
+}
+
+func TestTimeAgo(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{now.Add(-20 * time.Second), "just now"},
+		{now.Add(-1 * time.Minute), "1 minute ago"},
+		{now.Add(-45 * time.Minute), "45 minutes ago"},
+		{now.Add(-5 * time.Hour), "5 hours ago"},
+		{now.Add(-20 * time.Hour), "20 hours ago"},
+		{now.AddDate(0, 0, -1).Add(-2 * time.Hour), "yesterday"},
+		{now.AddDate(0, 0, -3), "3 days ago"},
+		{now.AddDate(0, 0, -29), "29 days ago"},
+		{now.AddDate(0, 0, -45), "Jan 24, 2026"},
+		{now.Add(10 * time.Minute), "in 10 minutes"},
+		{now.Add(26 * time.Hour), "tomorrow"},
+		{now.AddDate(0, 0, 4), "in 4 days"},
+	}
+	for _, tt := range tests {
+		if got := timeAgo(tt.t, now); got != tt.want {
+			t.Errorf("timeAgo(%s) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+	if got := execute(t, `{{timeAgo .}}`, now.Add(-3*time.Hour)); got != "3 hours ago" {
+		t.Errorf("timeAgo template = %q", got)
+	}
+}
+
+func TestDueIn(t *testing.T) {
+	tests := []struct {
+		due  time.Time
+		want string
+	}{
+		{now.Add(6 * time.Hour), "due today"},
+		{now.Add(-2 * time.Hour), "overdue today"},
+		{now.AddDate(0, 0, 1), "due tomorrow"},
+		{now.AddDate(0, 0, 5), "due in 5 days"},
+		{now.AddDate(0, 0, -1), "1 day overdue"},
+		{now.AddDate(0, 0, -3), "3 days overdue"},
+		// 11 PM tonight in Nevada is already tomorrow in UTC
+		{time.Date(2026, time.March, 11, 6, 0, 0, 0, time.UTC), "due today"},
+	}
+	for _, tt := range tests {
+		if got := dueIn(tt.due, now); got != tt.want {
+			t.Errorf("dueIn(%s) = %q, want %q", tt.due, got, tt.want)
+		}
+	}
+	if got := execute(t, `{{dueIn .}}`, time.Time{}); got != "" {
+		t.Errorf("dueIn of no deadline = %q, want empty", got)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "less than a minute"},
+		{time.Minute, "1 minute"},
+		{45 * time.Minute, "45 minutes"},
+		{2 * time.Hour, "2 hours"},
+		{2*time.Hour + 5*time.Minute, "2 hours 5 minutes"},
+		{24 * time.Hour, "1 day"},
+		{3*24*time.Hour + 4*time.Hour + 59*time.Minute, "3 days 4 hours"},
+		{-90 * time.Minute, "1 hour 30 minutes"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{`{{plural 0 "case"}}`, "0 cases"},
+		{`{{plural 1 "case"}}`, "1 case"},
+		{`{{plural 2 "case"}}`, "2 cases"},
+		{`{{plural 1 "inquiry" "inquiries"}}`, "1 inquiry"},
+		{`{{plural 3 "inquiry" "inquiries"}}`, "3 inquiries"},
+		{`{{plural (len .) "opinion"}}`, "2 opinions"},
+	}
+	for _, tt := range tests {
+		if got := execute(t, tt.text, []string{"AO-2024-042", "EC-2024-015"}); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBadges(t *testing.T) {
+	for _, s := range domain.CaseStatuses {
+		if statusLabel(s) == string(s) {
+			t.Errorf("status %s has no label", s)
+		}
+		if _, ok := statusBadges[string(s)]; !ok {
+			t.Errorf("status %s has no badge", s)
+		}
+	}
+	for _, ct := range domain.CaseTypes {
+		if _, ok := typeBadges[ct]; !ok {
+			t.Errorf("case type %s has no badge", ct)
+		}
+	}
+
+	tests := []struct {
+		text string
+		data interface{}
+		want string
+	}{
+		{`{{statusBadge .}} {{statusLabel .}}`, domain.StatusUnderReview, "bg-primary Under Review"},
+		{`{{statusBadge .}} {{statusLabel .}}`, domain.StatusSubmitted, "bg-info New"},
+		{`{{statusBadge .}} {{statusLabel .}}`, "overdue", "bg-danger Overdue"},
+		{`{{statusBadge .}} {{statusLabel .}}`, "due_soon", "bg-warning text-dark Due Soon"},
+		{`{{statusBadge .}} {{statusLabel .}}`, "on_hold", "bg-secondary On Hold"},
+		{`{{typeBadge .}} {{typeLabel .}}`, domain.CaseTypeEthicsComplaint, "bg-danger Ethics Complaint"},
+		{`{{typeBadge .}} {{typeLabel .}}`, "PRR", "bg-info Public Records Request"},
+		{`{{typeBadge .}} {{typeLabel .}}`, "XX", "bg-secondary XX"},
+	}
+	for _, tt := range tests {
+		if got := execute(t, tt.text, tt.data); got != tt.want {
+			t.Errorf("%s with %v = %q, want %q", tt.text, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1023, "1023 B"},
+		{1024, "1 KB"},
+		{1536, "1.5 KB"},
+		{245760, "240 KB"},
+		{5 << 20, "5 MB"},
+		{3435973837, "3.2 GB"},
+	}
+	for _, tt := range tests {
+		if got := execute(t, `{{byteSize .}}`, tt.size); got != tt.want {
+			t.Errorf("byteSize(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+	if got := execute(t, `{{byteSize .}}`, 2048); got != "2 KB" {
+		t.Errorf("byteSize(int 2048) = %q", got)
+	}
+}
+
+func TestNRSURL(t *testing.T) {
+	tests := []struct {
+		citation interface{}
+		want     string
+	}{
+		{"NRS 281A.400", "https://www.leg.state.nv.us/NRS/NRS-281A.html#NRS281ASec400"},
+		{"NRS 281A.400(2)(a)", "https://www.leg.state.nv.us/NRS/NRS-281A.html#NRS281ASec400"},
+		{"281a.420", "https://www.leg.state.nv.us/NRS/NRS-281A.html#NRS281ASec420"},
+		{"N.R.S. 239.010", "https://www.leg.state.nv.us/NRS/NRS-239.html#NRS239Sec010"},
+		{"NRS Chapter 281A", "https://www.leg.state.nv.us/NRS/NRS-281A.html"},
+		{domain.StatuteCitation{Chapter: "281A", Section: "440", Subsections: []string{"1"}}, "https://www.leg.state.nv.us/NRS/NRS-281A.html#NRS281ASec440"},
+		{"javascript:alert(1)", ""},
+		{"NRS 281A.400 and https://example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := string(nrsURL(tt.citation)); got != tt.want {
+			t.Errorf("nrsURL(%v) = %q, want %q", tt.citation, got, tt.want)
+		}
+	}
+
+	// The URL is trusted in an href, so the template leaves it as is
+	if got := execute(t, `<a href="{{nrsURL .}}">`, "NRS 281A.400"); got != `<a href="https://www.leg.state.nv.us/NRS/NRS-281A.html#NRS281ASec400">` {
+		t.Errorf("href = %s", got)
+	}
+}
@@ -1,144 +1,464 @@
 package templates
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"html/template"
-	"log"
+	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
-	"path/filepath"
+	"path"
+	"sort"
 	"strings"
+	"sync"
+	"text/template/parse"
+	"time"
+
+	"ncoe/internal/assets"
+	"ncoe/internal/csp"
+	"ncoe/internal/metrics"
+	"ncoe/internal/tracing"
 )
 
+var renderDuration = metrics.NewHistogram("ncoe_template_render_seconds",
+	"Time to execute templates, by page or fragment.", metrics.DefaultBuckets, "page")
+
+// Staff templates follow two conventions, checked when the renderer is built:
+//
+//   - A page (staff/name.html) defines the blocks of staffBase and nothing at
+//     the top level. Page renders it by executing staffBase.
+//   - A fragment (staff/_name.html) defines "staff/_name" and never uses the
+//     layout. Fragment renders that define on its own, for HTMX swaps.
+const staffBase = "staff_base"
+
+// pageBlocks are the blocks every staff page must define
+var pageBlocks = []string{"title", "content"}
+
 // Renderer handles template parsing and rendering
 type Renderer struct {
-	pages   map[string]*template.Template
-	funcMap template.FuncMap
-	quiet   bool
+	fsys  fs.FS
+	funcs template.FuncMap
+	opts  Options
+	nonce []byte // Placeholder {{nonce}} writes, replaced by the response's CSP nonce
+
+	mu    sync.RWMutex
+	set   *templateSet
+	stamp string // Fingerprint of the files set was parsed from, in reload mode
 }
 
-// NewRenderer creates a new template renderer loading templates from templateDir
-func NewRenderer(templateDir string) *Renderer {
-	return newRenderer(templateDir, false)
+// Options configure a Renderer
+type Options struct {
+	// Static resolves {{asset "css/custom.css"}} to a content-hashed URL
+	// and gives {{stylesheet}} and {{script}} tags their integrity hash;
+	// without it URLs are plain and tags have no hash
+	Static *assets.Static
+	// Reload re-parses the templates whenever a file changes, for
+	// development against templates on disk
+	Reload bool
+	// Quiet suppresses logging (for tests)
+	Quiet bool
 }
 
-// NewQuietRenderer creates a renderer that suppresses logging (for tests)
-func NewQuietRenderer(templateDir string) *Renderer {
-	return newRenderer(templateDir, true)
+// templateSet is one parse of every template
+type templateSet struct {
+	pages     map[string]*template.Template // Public and auth pages, by "folder/name"
+	staff     map[string]*template.Template // Staff pages, by name
+	fragments *template.Template            // Staff layout and fragments
 }
 
-func newRenderer(templateDir string, quiet bool) *Renderer {
-	funcMap := template.FuncMap{
-		"formatDate": func(t interface{}) string {
-			return ""
-		},
-		"statusBadge": func(status string) string {
-			return status
-		},
-		"sub": func(a, b int) int {
-			return a - b
-		},
-		"add": func(a, b int) int {
-			return a + b
-		},
+// NewRenderer creates a template renderer loading templates from fsys,
+// usually the templates built into the binary. It fails if any template
+// does not parse or breaks the page and fragment conventions.
+func NewRenderer(fsys fs.FS, opts Options) (*Renderer, error) {
+	funcs := Funcs(time.Now)
+	funcs["asset"] = opts.Static.Path
+	funcs["stylesheet"] = opts.Static.Stylesheet
+	funcs["script"] = opts.Static.Script
+	nonce := noncePlaceholder()
+	funcs["nonce"] = func() string { return nonce }
+	r := &Renderer{fsys: fsys, funcs: funcs, opts: opts, nonce: []byte(nonce)}
+
+	if opts.Reload {
+		stamp, err := r.fingerprint()
+		if err != nil {
+			return nil, err
+		}
+		r.stamp = stamp
 	}
+	set, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.set = set
+	return r, nil
+}
 
-	renderer := &Renderer{
-		pages:   make(map[string]*template.Template),
-		funcMap: funcMap,
-		quiet:   quiet,
+// load parses every template
+func (r *Renderer) load() (*templateSet, error) {
+	set := &templateSet{
+		pages: make(map[string]*template.Template),
+		staff: make(map[string]*template.Template),
 	}
 
-	// Folders that have templates
-	folders := []string{"auth", "public", "staff", "errors"}
+	// Public and auth pages are standalone: each defines "folder/name.html"
+	for _, folder := range []string{"auth", "public"} {
+		files, err := fs.Glob(r.fsys, folder+"/*.html")
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(file, ".html")
+			src, err := readTemplate(r.fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := src.defines[name+".html"]; !ok {
+				return nil, fmt.Errorf("templates: %s does not define %q", src.path, name+".html")
+			}
+			tmpl, err := template.New(name).Funcs(r.funcs).ParseFS(r.fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			set.pages[name] = tmpl
+			r.log(slog.LevelDebug, "template loaded", "page", name)
+		}
+	}
+
+	if err := r.loadStaff(set, "staff"); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
 
-	for _, folder := range folders {
-		folderPath := filepath.Join(templateDir, folder)
+// current returns the parsed templates. In reload mode it first re-parses
+// them if any file has changed; a broken edit fails each render until it is
+// fixed, rather than stopping the server.
+func (r *Renderer) current() (*templateSet, error) {
+	if !r.opts.Reload {
+		return r.set, nil
+	}
+	stamp, err := r.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	set, fresh := r.set, r.stamp == stamp
+	r.mu.RUnlock()
+	if fresh {
+		return set, nil
+	}
 
-		// Get base template for this folder (if exists)
-		baseFile := filepath.Join(folderPath, "base.html")
-		var baseFiles []string
-		if matches, _ := filepath.Glob(baseFile); len(matches) > 0 {
-			baseFiles = matches
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stamp == stamp {
+		return r.set, nil
+	}
+	set, err = r.load()
+	if err != nil {
+		slog.Error("template reload failed", "error", err)
+		return nil, err
+	}
+	r.set, r.stamp = set, stamp
+	r.log(slog.LevelInfo, "templates reloaded")
+	return set, nil
+}
+
+// fingerprint summarizes the name, size and modification time of every
+// template file, so a change to any of them is noticed
+func (r *Renderer) fingerprint() (string, error) {
+	var b strings.Builder
+	err := fs.WalkDir(r.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
 		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return b.String(), err
+}
 
-		// Get all partials for this folder (_*.html)
-		partialPattern := filepath.Join(folderPath, "_*.html")
-		partialFiles, _ := filepath.Glob(partialPattern)
+// loadStaff parses the staff layout and fragments into one set, then each
+// page into its own copy of that set, since every page defines the same
+// block names
+func (r *Renderer) loadStaff(set *templateSet, dir string) error {
+	base, err := readTemplate(r.fsys, path.Join(dir, "base.html"))
+	if err != nil {
+		return err
+	}
+	if _, ok := base.defines[staffBase]; !ok {
+		return fmt.Errorf("templates: %s does not define %q", base.path, staffBase)
+	}
 
-		// Get all page templates (non-partial *.html)
-		allPattern := filepath.Join(folderPath, "*.html")
-		allFiles, _ := filepath.Glob(allPattern)
+	files, err := fs.Glob(r.fsys, path.Join(dir, "*.html"))
+	if err != nil {
+		return err
+	}
+	var partials, pages []*source
+	for _, file := range files {
+		if path.Base(file) == "base.html" {
+			continue
+		}
+		src, err := readTemplate(r.fsys, file)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(path.Base(file), "_") {
+			partials = append(partials, src)
+		} else {
+			pages = append(pages, src)
+		}
+	}
 
-		var pageFiles []string
-		for _, f := range allFiles {
-			base := filepath.Base(f)
-			if !strings.HasPrefix(base, "_") && base != "base.html" {
-				pageFiles = append(pageFiles, f)
+	// Layout and fragments share one namespace, so no name may be defined
+	// twice across them
+	definedIn := make(map[string]string)
+	for _, src := range append([]*source{base}, partials...) {
+		for name := range src.defines {
+			if other, ok := definedIn[name]; ok {
+				return fmt.Errorf("templates: %q is defined in both %s and %s", name, other, src.path)
 			}
+			definedIn[name] = src.path
 		}
+	}
+	for _, src := range partials {
+		name := "staff/" + strings.TrimSuffix(path.Base(src.path), ".html")
+		if _, ok := src.defines[name]; !ok {
+			return fmt.Errorf("templates: fragment %s does not define %q", src.path, name)
+		}
+		if src.body {
+			return fmt.Errorf("templates: fragment %s has content outside {{define}}", src.path)
+		}
+		if ref := src.references(staffBase); ref != "" {
+			return fmt.Errorf("templates: fragment %s uses the %s layout in %q", src.path, staffBase, ref)
+		}
+	}
 
-		// Parse each page template with base + partials
-		for _, pageFile := range pageFiles {
-			relPath, _ := filepath.Rel(templateDir, pageFile)
-			name := strings.TrimSuffix(relPath, ".html")
-			name = filepath.ToSlash(name)
-
-			// Parse page with base + partials
-			var files []string
-			files = append(files, baseFiles...)
-			files = append(files, partialFiles...)
-			files = append(files, pageFile)
+	fragments, err := template.New(staffBase).Funcs(r.funcs).ParseFS(r.fsys, base.path)
+	if err != nil {
+		return err
+	}
+	for _, src := range partials {
+		if _, err := fragments.ParseFS(r.fsys, src.path); err != nil {
+			return err
+		}
+	}
+	set.fragments = fragments
 
-			tmpl, err := template.New(filepath.Base(pageFile)).Funcs(funcMap).ParseFiles(files...)
-			if err != nil {
-				if !quiet {
-					log.Printf("Failed to parse page %s: %v", name, err)
-				}
-				continue
+	for _, src := range pages {
+		if src.body {
+			return fmt.Errorf("templates: page %s has content outside {{define}}", src.path)
+		}
+		for _, block := range pageBlocks {
+			if _, ok := src.defines[block]; !ok {
+				return fmt.Errorf("templates: page %s does not define the %q block", src.path, block)
 			}
-
-			renderer.pages[name] = tmpl
-			if !quiet {
-				log.Printf("Loaded page: %s", name)
+		}
+		// A page may fill in the layout's blocks but not replace anything
+		// else the layout or a fragment defines
+		for name := range src.defines {
+			if other, ok := definedIn[name]; ok && (other != base.path || name == staffBase) {
+				return fmt.Errorf("templates: %q is defined in both %s and %s", name, other, src.path)
 			}
 		}
+		if ref := src.references(staffBase); ref != "" {
+			return fmt.Errorf("templates: page %s calls %s itself in %q", src.path, staffBase, ref)
+		}
+
+		tmpl, err := fragments.Clone()
+		if err != nil {
+			return err
+		}
+		if _, err := tmpl.ParseFS(r.fsys, src.path); err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(path.Base(src.path), ".html")
+		set.staff[name] = tmpl
+		r.log(slog.LevelDebug, "template loaded", "page", "staff/"+name)
 	}
+	return nil
+}
 
-	return renderer
+// Page renders a staff page inside the staff layout
+func (r *Renderer) Page(w io.Writer, name string, data interface{}) error {
+	set, err := r.current()
+	if err != nil {
+		return err
+	}
+	tmpl, ok := set.staff[name]
+	if !ok {
+		return fmt.Errorf("templates: no staff page %q", name)
+	}
+	return r.execute(w, tmpl, staffBase, "staff/"+name, data)
+}
+
+// Fragment renders a staff fragment on its own, e.g. "case_panel" renders
+// the "staff/_case_panel" define
+func (r *Renderer) Fragment(w io.Writer, name string, data interface{}) error {
+	set, err := r.current()
+	if err != nil {
+		return err
+	}
+	define := "staff/_" + name
+	if set.fragments.Lookup(define) == nil {
+		return fmt.Errorf("templates: no staff fragment %q", define)
+	}
+	return r.execute(w, set.fragments, define, define, data)
+}
+
+// execute renders the named template of tmpl into a buffer first, so a
+// template that fails part way through writes nothing and the caller can
+// still send an error. Render times are recorded by page, and traced as
+// part of the request being answered.
+func (r *Renderer) execute(w io.Writer, tmpl *template.Template, name, page string, data interface{}) error {
+	ctx := context.Background()
+	rw, isResponse := w.(http.ResponseWriter)
+	if isResponse {
+		ctx = writerContext(rw)
+	}
+	_, span := tracing.Start(ctx, "render "+page)
+	span.SetAttributes("template.page", page)
+	defer span.End()
+
+	var buf bytes.Buffer
+	start := time.Now()
+	err := tmpl.ExecuteTemplate(&buf, name, data)
+	renderDuration.Observe(time.Since(start).Seconds(), page)
+	if err != nil {
+		span.RecordError(err)
+		r.log(slog.LevelError, "template execution failed", "page", page, "error", err)
+		return err
+	}
+	out := buf.Bytes()
+	if isResponse && bytes.Contains(out, r.nonce) {
+		out = bytes.ReplaceAll(out, r.nonce, []byte(csp.Nonce(rw.Header())))
+	}
+	_, err = w.Write(out)
+	return err
 }
 
-// ExecuteTemplate renders a page template
+// writerContext returns the context of the request w answers, found on a
+// writer wrapped around it that offers one, such as the one the tracing
+// middleware adds. Otherwise it is context.Background().
+func writerContext(w http.ResponseWriter) context.Context {
+	for {
+		if c, ok := w.(interface{ Context() context.Context }); ok && c.Context() != nil {
+			return c.Context()
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return context.Background()
+		}
+		w = u.Unwrap()
+	}
+}
+
+// noncePlaceholder returns a random stand-in for the CSP nonce. Templates
+// are parsed once, but the nonce changes with every response, so {{nonce}}
+// writes this and execute swaps in the nonce of the policy on the response.
+// Being random, it cannot be smuggled in through user content.
+func noncePlaceholder() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "csp-nonce-" + hex.EncodeToString(b)
+}
+
+// ExecuteTemplate renders a public or auth page, e.g. "public/home"
 func (r *Renderer) ExecuteTemplate(w http.ResponseWriter, name string, data interface{}) error {
-	tmpl, ok := r.pages[name]
+	set, err := r.current()
+	if err != nil {
+		return err
+	}
+	tmpl, ok := set.pages[name]
 	if !ok {
-		if !r.quiet {
-			log.Printf("Page template not found: %s", name)
-		}
+		r.log(slog.LevelError, "template not found", "page", name)
 		return http.ErrMissingFile
 	}
+	return r.execute(w, tmpl, name+".html", name, data)
+}
+
+func (r *Renderer) log(level slog.Level, msg string, args ...any) {
+	if !r.opts.Quiet {
+		slog.Log(context.Background(), level, msg, args...)
+	}
+}
 
-	// Try different execution strategies:
-	// 1. If page defines its own "folder/name.html", execute that (standalone pages like dashboard)
-	// 2. Try just "name.html" (for templates like case_panel.html)
-	// 3. If page uses base template pattern (staff_base), execute that (pages with content block)
+// source is one template file, parsed only far enough to see what it
+// defines and which templates it calls
+type source struct {
+	path    string
+	defines map[string]*parse.Tree
+	body    bool // Content outside any {{define}}
+}
 
-	strategies := []string{
-		name + ".html",                // Standalone page pattern (e.g., "staff/dashboard.html")
-		filepath.Base(name) + ".html", // Just filename (e.g., "case_panel.html")
-		"staff_base",                  // Base template pattern (most staff pages)
+func readTemplate(fsys fs.FS, file string) (*source, error) {
+	text, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	// Functions are checked when html/template parses the file; here only
+	// the structure matters
+	t := parse.New(file)
+	t.Mode = parse.SkipFuncCheck
+	trees := make(map[string]*parse.Tree)
+	top, err := t.Parse(string(text), "", "", trees)
+	if err != nil {
+		return nil, err
+	}
+	// Compare trees rather than names: a public page's define has the same
+	// name as its file
+	src := &source{path: file, defines: make(map[string]*parse.Tree)}
+	src.body = top.Root != nil && !parse.IsEmptyTree(top.Root)
+	for name, tree := range trees {
+		if tree != top {
+			src.defines[name] = tree
+		}
 	}
+	return src, nil
+}
 
-	for _, strategy := range strategies {
-		if t := tmpl.Lookup(strategy); t != nil {
-			err := tmpl.ExecuteTemplate(w, strategy, data)
-			if err != nil && !r.quiet {
-				log.Printf("Page execution error (%s via %s): %v", name, strategy, err)
-			}
-			return err
+// references returns the name of the first define that calls the named
+// template, or "" if none does
+func (s *source) references(name string) string {
+	names := make([]string, 0, len(s.defines))
+	for define := range s.defines {
+		names = append(names, define)
+	}
+	sort.Strings(names)
+	for _, define := range names {
+		if calls(s.defines[define].Root, name) {
+			return define
 		}
 	}
+	return ""
+}
 
-	if !r.quiet {
-		log.Printf("No valid template found for %s, tried: %v", name, strategies)
+func calls(node parse.Node, name string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if calls(child, name) {
+				return true
+			}
+		}
+	case *parse.TemplateNode:
+		return n.Name == name
+	case *parse.IfNode:
+		return calls(n.List, name) || calls(n.ElseList, name)
+	case *parse.RangeNode:
+		return calls(n.List, name) || calls(n.ElseList, name)
+	case *parse.WithNode:
+		return calls(n.List, name) || calls(n.ElseList, name)
 	}
-	return http.ErrMissingFile
+	return false
 }
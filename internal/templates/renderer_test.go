@@ -0,0 +1,244 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe"
+)
+
+const testBase = `{{define "staff_base"}}<html><title>{{block "title" .}}Staff{{end}}</title><main>{{block "content" .}}{{end}}</main></html>{{end}}`
+
+// writeTemplates lays out a template directory from file names to contents,
+// starting from a valid staff layout with one page and one fragment
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	all := map[string]string{
+		"staff/base.html":       testBase,
+		"staff/cases.html":      `{{define "title"}}Cases{{end}}{{define "content"}}<h1>{{.Name}}</h1>{{template "staff/_row" .}}{{end}}`,
+		"staff/_row.html":       `{{define "staff/_row"}}<tr><td>{{.Name}}</td></tr>{{end}}`,
+		"public/home.html":      `{{define "public/home.html"}}<html>{{.Name}}</html>{{end}}`,
+		"auth/staff_login.html": `{{define "auth/staff_login.html"}}<form></form>{{end}}`,
+	}
+	for name, body := range files {
+		all[name] = body
+	}
+	for name, body := range all {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRender(t *testing.T) {
+	r, err := NewRenderer(os.DirFS(writeTemplates(t, nil)), Options{Quiet: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]string{"Name": "AO-2024-042"}
+
+	var page strings.Builder
+	if err := r.Page(&page, "cases", data); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<html><title>Cases</title><main><h1>AO-2024-042</h1><tr><td>AO-2024-042</td></tr></main></html>"; page.String() != want {
+		t.Errorf("Page = %q, want %q", page.String(), want)
+	}
+
+	var fragment strings.Builder
+	if err := r.Fragment(&fragment, "row", data); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<tr><td>AO-2024-042</td></tr>"; fragment.String() != want {
+		t.Errorf("Fragment = %q, want %q", fragment.String(), want)
+	}
+
+	if err := r.Page(&page, "missing", data); err == nil {
+		t.Error("Page of an unknown page succeeded")
+	}
+	if err := r.Fragment(&fragment, "cases", data); err == nil {
+		t.Error("Fragment of a page succeeded")
+	}
+}
+
+func TestRenderFailureWritesNothing(t *testing.T) {
+	r, err := NewRenderer(os.DirFS(writeTemplates(t, map[string]string{
+		"staff/cases.html": `{{define "title"}}Cases{{end}}{{define "content"}}{{.Name.Missing}}{{end}}`,
+	})), Options{Quiet: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page strings.Builder
+	if err := r.Page(&page, "cases", map[string]string{"Name": "x"}); err == nil {
+		t.Fatal("Page succeeded")
+	}
+	if page.Len() != 0 {
+		t.Errorf("failed Page wrote %q", page.String())
+	}
+}
+
+func TestNewRendererRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "missing layout",
+			files: map[string]string{"staff/base.html": `{{define "layout"}}{{end}}`},
+			want:  `does not define "staff_base"`,
+		},
+		{
+			name:  "page without content",
+			files: map[string]string{"staff/cases.html": `{{define "title"}}Cases{{end}}{{define "contents"}}x{{end}}`},
+			want:  `does not define the "content" block`,
+		},
+		{
+			name:  "page without title",
+			files: map[string]string{"staff/cases.html": `{{define "content"}}x{{end}}`},
+			want:  `does not define the "title" block`,
+		},
+		{
+			name:  "page calling the layout",
+			files: map[string]string{"staff/cases.html": `{{define "title"}}Cases{{end}}{{define "content"}}x{{end}}{{template "staff_base" .}}`},
+			want:  "content outside {{define}}",
+		},
+		{
+			name:  "page nesting the layout",
+			files: map[string]string{"staff/cases.html": `{{define "title"}}Cases{{end}}{{define "content"}}{{if .}}{{template "staff_base" .}}{{end}}{{end}}`},
+			want:  `calls staff_base itself in "content"`,
+		},
+		{
+			name:  "page redefining a fragment",
+			files: map[string]string{"staff/cases.html": `{{define "title"}}Cases{{end}}{{define "content"}}x{{end}}{{define "staff/_row"}}y{{end}}`},
+			want:  `"staff/_row" is defined in both`,
+		},
+		{
+			name:  "duplicate define in one file",
+			files: map[string]string{"staff/_row.html": `{{define "staff/_row"}}a{{end}}{{define "staff/_row"}}b{{end}}`},
+			want:  "multiple definition",
+		},
+		{
+			name: "duplicate define across fragments",
+			files: map[string]string{
+				"staff/_cell.html": `{{define "staff/_cell"}}<td></td>{{end}}{{define "cell"}}{{end}}`,
+				"staff/_row.html":  `{{define "staff/_row"}}<tr></tr>{{end}}{{define "cell"}}{{end}}`,
+			},
+			want: `"cell" is defined in both`,
+		},
+		{
+			name:  "fragment defining a layout block",
+			files: map[string]string{"staff/_row.html": `{{define "staff/_row"}}<tr></tr>{{end}}{{define "content"}}{{end}}`},
+			want:  `"content" is defined in both`,
+		},
+		{
+			name:  "fragment without its define",
+			files: map[string]string{"staff/_row.html": `{{define "row"}}<tr></tr>{{end}}`},
+			want:  `does not define "staff/_row"`,
+		},
+		{
+			name:  "fragment using the layout",
+			files: map[string]string{"staff/_row.html": `{{define "staff/_row"}}{{template "staff_base" .}}{{end}}`},
+			want:  "uses the staff_base layout",
+		},
+		{
+			name:  "public page without its define",
+			files: map[string]string{"public/home.html": `<html></html>`},
+			want:  `does not define "public/home.html"`,
+		},
+		{
+			name:  "unknown function",
+			files: map[string]string{"staff/_row.html": `{{define "staff/_row"}}{{shout .}}{{end}}`},
+			want:  `"shout" not defined`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRenderer(os.DirFS(writeTemplates(t, tt.files)), Options{Quiet: true})
+			if err == nil {
+				t.Fatal("NewRenderer succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// The templates built into the binary must all follow the conventions
+func TestShippedTemplates(t *testing.T) {
+	r, err := NewRenderer(ncoe.Templates(), Options{Quiet: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fragments start with an underscore, which embed skips by default
+	var out strings.Builder
+	if err := r.Fragment(&out, "cases_table", map[string]interface{}{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := writeTemplates(t, nil)
+	r, err := NewRenderer(os.DirFS(dir), Options{Reload: true, Quiet: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]string{"Name": "AO-2024-042"}
+	render := func() (string, error) {
+		var out strings.Builder
+		err := r.Fragment(&out, "row", data)
+		return out.String(), err
+	}
+	edit := func(body string) {
+		t.Helper()
+		file := filepath.Join(dir, "staff", "_row.html")
+		if err := os.WriteFile(file, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// Make the change visible even on file systems with coarse timestamps
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	edit(`{{define "staff/_row"}}<tr><th>{{.Name}}</th></tr>{{end}}`)
+	if got, err := render(); err != nil || got != "<tr><th>AO-2024-042</th></tr>" {
+		t.Errorf("after edit: %q, %v", got, err)
+	}
+
+	// A broken edit fails renders until it is fixed
+	edit(`{{define "staff/_row"}}<tr>{{.Name}</tr>{{end}}`)
+	if _, err := render(); err == nil {
+		t.Error("render with a broken template succeeded")
+	}
+	edit(`{{define "staff/_row"}}<li>{{.Name}}</li>{{end}}`)
+	if got, err := render(); err != nil || got != "<li>AO-2024-042</li>" {
+		t.Errorf("after fix: %q, %v", got, err)
+	}
+}
+
+func TestNoReloadWithoutOption(t *testing.T) {
+	dir := writeTemplates(t, nil)
+	r, err := NewRenderer(os.DirFS(dir), Options{Quiet: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "staff", "_row.html"), []byte(`{{define "staff/_row"}}changed{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := r.Fragment(&out, "row", map[string]string{"Name": "x"}); err != nil || out.String() != "<tr><td>x</td></tr>" {
+		t.Errorf("Fragment = %q, %v; want the templates parsed at startup", out.String(), err)
+	}
+}
@@ -0,0 +1,40 @@
+package testutil
+
+import (
+	"errors"
+	"html/template"
+	"sync"
+)
+
+// FakeChallengeAnswer is the response FakeChallenge accepts
+const FakeChallengeAnswer = "human"
+
+// FakeChallenge is a stand-in human-verification service for tests. Its
+// widget is a text input, and it passes a response of FakeChallengeAnswer.
+type FakeChallenge struct {
+	mu  sync.Mutex
+	ips []string
+}
+
+// Widget returns the input the public forms carry
+func (c *FakeChallenge) Widget() template.HTML {
+	return `<input type="text" name="challenge_response" id="fake-challenge" aria-label="Type human">`
+}
+
+// Verify passes FakeChallengeAnswer and records the address it came from
+func (c *FakeChallenge) Verify(response, remoteIP string) error {
+	c.mu.Lock()
+	c.ips = append(c.ips, remoteIP)
+	c.mu.Unlock()
+	if response != FakeChallengeAnswer {
+		return errors.New("wrong answer")
+	}
+	return nil
+}
+
+// Verified returns the addresses of the responses checked so far
+func (c *FakeChallenge) Verified() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.ips...)
+}
@@ -164,6 +164,76 @@ func (d *DOM) FindByID(id string) *html.Node {
 	})
 }
 
+// TextByID returns the trimmed text content of the element with the given ID,
+// or "" if there is none.
+func (d *DOM) TextByID(id string) string {
+	n := d.FindByID(id)
+	if n == nil {
+		return ""
+	}
+	return nodeText(n)
+}
+
+// HasAttrByID reports whether the element with the given ID has an attribute,
+// e.g. "checked" or "disabled".
+func (d *DOM) HasAttrByID(id, attr string) bool {
+	n := d.FindByID(id)
+	return n != nil && hasAttr(n, attr)
+}
+
+// ListItemsByID returns the trimmed text of each <li> inside the element with
+// the given ID.
+func (d *DOM) ListItemsByID(id string) []string {
+	n := d.FindByID(id)
+	if n == nil {
+		return nil
+	}
+	var items []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "li" {
+			items = append(items, nodeText(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return items
+}
+
+// TableRowsByID returns the trimmed text of each cell, row by row, of the
+// body rows of the table with the given ID.
+func (d *DOM) TableRowsByID(id string) [][]string {
+	n := d.FindByID(id)
+	if n == nil {
+		return nil
+	}
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "thead" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "tr" {
+			var cells []string
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && (c.Data == "td" || c.Data == "th") {
+					cells = append(cells, nodeText(c))
+				}
+			}
+			rows = append(rows, cells)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return rows
+}
+
 // FindByClass finds the first element with a given class.
 func (d *DOM) FindByClass(class string) *html.Node {
 	return d.findNode(func(n *html.Node) bool {
@@ -197,6 +267,13 @@ func (d *DOM) FindForm(action string) *html.Node {
 	})
 }
 
+// FindByHref finds a link by href.
+func (d *DOM) FindByHref(href string) *html.Node {
+	return d.findNode(func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "a" && getAttr(n, "href") == href
+	})
+}
+
 // FindAllByTag finds all elements with the given tag.
 func (d *DOM) FindAllByTag(tag string) []*html.Node {
 	return d.findAllNodes(func(n *html.Node) bool {
@@ -204,8 +281,50 @@ func (d *DOM) FindAllByTag(tag string) []*html.Node {
 	})
 }
 
+// AttrsByTag returns the value of attr on every element with the given tag
+// that has it, e.g. the src of each <script>.
+func (d *DOM) AttrsByTag(tag, attr string) []string {
+	var values []string
+	for _, n := range d.FindAllByTag(tag) {
+		if v := getAttr(n, attr); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// EventHandlerAttrs returns every inline event handler attribute, such as
+// onclick, as "tag attr", e.g. "button onclick"
+func (d *DOM) EventHandlerAttrs() []string {
+	var handlers []string
+	for _, n := range d.findAllNodes(func(n *html.Node) bool { return n.Type == html.ElementNode }) {
+		for _, a := range n.Attr {
+			if strings.HasPrefix(strings.ToLower(a.Key), "on") {
+				handlers = append(handlers, n.Data+" "+a.Key)
+			}
+		}
+	}
+	return handlers
+}
+
 // --- Internal Helpers ---
 
+// nodeText returns the trimmed text content of n and its descendants
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(b.String())
+}
+
 func (d *DOM) hasElement(tag string) bool {
 	return d.findNode(func(n *html.Node) bool {
 		return n.Type == html.ElementNode && n.Data == tag
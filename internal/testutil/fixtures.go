@@ -26,7 +26,7 @@ func EthicsComplaintForm() url.Values {
 		"subject_agency":     {"Test County"},
 		"allegation_summary": {"Gift violation allegation"},
 		"allegation_detail":  {"The subject accepted tickets to a show from a vendor seeking county contracts."},
-		"statutes":           {"NRS 281A.400"},
+		"provisions":         {"nrs 281a.400 (1) and NRS 281A.420"},
 	}
 }
 
@@ -52,6 +52,17 @@ func RecordsRequestForm() url.Values {
 	}
 }
 
+// PublishForm returns valid form fields for publishing an opinion (document_id set by the caller).
+func PublishForm() url.Values {
+	return url.Values{
+		"title":              {"Final Order: Misuse of City Staff"},
+		"summary":            {"The Commission finds that directing city staff to perform personal tasks violated the Ethics in Government Law."},
+		"topics":             {"Misuse of Government Resources, Employment"},
+		"statutes":           {"nrs 281a.400 (2), NRS 281A.400(7)"},
+		"redaction_reviewed": {"on"},
+	}
+}
+
 // LoginForm returns login form data.
 func LoginForm(email, password string) url.Values {
 	return url.Values{
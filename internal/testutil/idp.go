@@ -0,0 +1,233 @@
+package testutil
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// IdPUser is the identity the fake identity provider signs in
+type IdPUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Groups        []string
+}
+
+// FakeIdP is a stand-in OpenID Connect provider for tests. Its authorize
+// endpoint signs the current User in without a login page and redirects
+// straight back with a code; its token endpoint enforces PKCE and client
+// authentication and returns an RS256 ID token.
+type FakeIdP struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	user  IdPUser
+	codes map[string]idpGrant
+
+	// ModifyClaims, when set, edits each ID token's claims before signing
+	ModifyClaims func(claims map[string]interface{})
+	// SigningKey, when set, signs ID tokens with a key the JWKS does not publish
+	SigningKey *rsa.PrivateKey
+}
+
+type idpGrant struct {
+	user        IdPUser
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// NewFakeIdP starts a fake provider that is closed when the test ends
+func NewFakeIdP(t *testing.T) *FakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("FakeIdP: generating key: %v", err)
+	}
+	p := &FakeIdP{
+		ClientID:     "ncoe-staff",
+		ClientSecret: "fake-idp-secret",
+		key:          key,
+		codes:        make(map[string]idpGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// SignIn sets the identity used by the next authorization request
+func (p *FakeIdP) SignIn(u IdPUser) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = u
+}
+
+func (p *FakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/jwks",
+	})
+}
+
+func (p *FakeIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "fake-idp-1",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   b64url(p.key.N.Bytes()),
+			"e":   b64url(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *FakeIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI := q.Get("redirect_uri")
+	switch {
+	case q.Get("client_id") != p.ClientID:
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	case redirectURI == "":
+		http.Error(w, "missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	back, _ := url.Parse(redirectURI)
+	params := url.Values{"state": {q.Get("state")}}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		params.Set("error", "invalid_request")
+		params.Set("error_description", "authorization code flow with S256 PKCE required")
+	} else {
+		code := randomToken()
+		p.mu.Lock()
+		p.codes[code] = idpGrant{
+			user:        p.user,
+			nonce:       q.Get("nonce"),
+			challenge:   q.Get("code_challenge"),
+			redirectURI: redirectURI,
+		}
+		p.mu.Unlock()
+		params.Set("code", code)
+	}
+	back.RawQuery = params.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+func (p *FakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != p.ClientID || secret != p.ClientSecret {
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	grant, ok := p.codes[code]
+	delete(p.codes, code) // Codes are single use
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code" || !ok:
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "unknown or used code")
+		return
+	case r.PostForm.Get("redirect_uri") != grant.redirectURI:
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri mismatch")
+		return
+	case b64url(sum[:]) != grant.challenge:
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":            p.URL,
+		"sub":            grant.user.Subject,
+		"aud":            p.ClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.user.Email,
+		"email_verified": grant.user.EmailVerified,
+		"given_name":     grant.user.GivenName,
+		"family_name":    grant.user.FamilyName,
+		"groups":         grant.user.Groups,
+	}
+	if p.ModifyClaims != nil {
+		p.ModifyClaims(claims)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": randomToken(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     p.sign(claims),
+	})
+}
+
+// sign returns the claims as a compact RS256 JWS
+func (p *FakeIdP) sign(claims map[string]interface{}) string {
+	key := p.key
+	if p.SigningKey != nil {
+		key = p.SigningKey
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "fake-idp-1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64url(header) + "." + b64url(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(fmt.Sprintf("FakeIdP: signing: %v", err))
+	}
+	return signed + "." + b64url(sig)
+}
+
+func tokenError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+}
+
+func b64url(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return b64url(b)
+}
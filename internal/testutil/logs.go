@@ -0,0 +1,70 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"ncoe/internal/logging"
+)
+
+// Logs captures what the server logs, as the JSON records production
+// writes. Servers started after CaptureLogs log into it.
+type Logs struct {
+	t   *testing.T
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// CaptureLogs makes the default logger a JSON logger writing to the
+// returned Logs until the test ends
+func CaptureLogs(t *testing.T) *Logs {
+	t.Helper()
+	logs := &Logs{t: t}
+	previous := slog.Default()
+	slog.SetDefault(logging.New(logs, logging.Options{Level: slog.LevelDebug}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return logs
+}
+
+func (l *Logs) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+// String returns the raw log output
+func (l *Logs) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+// Reset discards the records captured so far
+func (l *Logs) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf.Reset()
+}
+
+// Records returns the captured records with the message, decoded
+func (l *Logs) Records(msg string) []map[string]interface{} {
+	l.t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(l.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			l.t.Fatalf("log line is not JSON: %q", line)
+		}
+		if record["msg"] == msg {
+			records = append(records, record)
+		}
+	}
+	return records
+}
@@ -0,0 +1,240 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// APISpec validates API responses against the OpenAPI document served by the
+// application, so contract tests check the handlers against the published spec
+// rather than against expectations restated in the tests.
+type APISpec struct {
+	t       *testing.T
+	doc     map[string]interface{}
+	base    string
+	covered map[string]bool
+}
+
+// APISpec fetches and parses the OpenAPI document at /api/v1/openapi.json.
+func (ts *TestServer) APISpec() *APISpec {
+	ts.t.Helper()
+	resp := ts.GET("/api/v1/openapi.json")
+	if resp.StatusCode != http.StatusOK {
+		ts.t.Fatalf("GET /api/v1/openapi.json: expected 200, got %d", resp.StatusCode)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &doc); err != nil {
+		ts.t.Fatalf("OpenAPI document is not valid JSON: %v", err)
+	}
+	if v, _ := doc["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		ts.t.Fatalf("expected an OpenAPI 3 document, got version %q", v)
+	}
+
+	base := ""
+	if servers, ok := doc["servers"].([]interface{}); ok && len(servers) > 0 {
+		base, _ = servers[0].(map[string]interface{})["url"].(string)
+	}
+	return &APISpec{t: ts.t, doc: doc, base: base, covered: map[string]bool{}}
+}
+
+// Operations returns every operation in the spec as "METHOD /path".
+func (s *APISpec) Operations() []string {
+	var ops []string
+	for path, item := range object(s.doc["paths"]) {
+		for method := range object(item) {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// Uncovered returns the operations no response has been validated against.
+func (s *APISpec) Uncovered() []string {
+	var missing []string
+	for _, op := range s.Operations() {
+		if !s.covered[op] {
+			missing = append(missing, op)
+		}
+	}
+	return missing
+}
+
+// Validate checks that the response to method requestPath is documented in the
+// spec: the path and method exist, the status code is listed, and the JSON
+// body matches the documented schema (including no undocumented fields).
+func (s *APISpec) Validate(method, requestPath string, resp *Response) {
+	s.t.Helper()
+	path, _, _ := strings.Cut(strings.TrimPrefix(requestPath, s.base), "?")
+	name, op := s.operation(method, path)
+	if op == nil {
+		s.t.Errorf("%s %s: no matching operation in the OpenAPI document", method, requestPath)
+		return
+	}
+	s.covered[name] = true
+
+	responses := object(op["responses"])
+	response := object(responses[strconv.Itoa(resp.StatusCode)])
+	if response == nil {
+		s.t.Errorf("%s %s: status %d is not documented for %s", method, requestPath, resp.StatusCode, name)
+		return
+	}
+	media := object(object(response["content"])["application/json"])
+	if media == nil {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "application/json" {
+		s.t.Errorf("%s %s: expected application/json, got %q", method, requestPath, resp.Header.Get("Content-Type"))
+		return
+	}
+
+	var body interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		s.t.Errorf("%s %s: response is not valid JSON: %v", method, requestPath, err)
+		return
+	}
+	for _, problem := range s.check(object(media["schema"]), body, "$") {
+		s.t.Errorf("%s %s (%d): %s", method, requestPath, resp.StatusCode, problem)
+	}
+}
+
+// operation finds the spec operation whose path template matches path
+func (s *APISpec) operation(method, path string) (string, map[string]interface{}) {
+	got := strings.Split(path, "/")
+	for template, item := range object(s.doc["paths"]) {
+		want := strings.Split(template, "/")
+		if len(want) != len(got) {
+			continue
+		}
+		match := true
+		for i := range want {
+			if !(strings.HasPrefix(want[i], "{") && got[i] != "") && want[i] != got[i] {
+				match = false
+				break
+			}
+		}
+		if op := object(object(item)[strings.ToLower(method)]); match && op != nil {
+			return method + " " + template, op
+		}
+	}
+	return "", nil
+}
+
+// check validates value against schema and returns a description of each mismatch
+func (s *APISpec) check(schema map[string]interface{}, value interface{}, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved := s.resolve(ref)
+		if resolved == nil {
+			return []string{fmt.Sprintf("%s: unresolvable $ref %s", at, ref)}
+		}
+		return s.check(resolved, value, at)
+	}
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+		return []string{at + ": null is not allowed"}
+	}
+
+	var problems []string
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %T", at, value)}
+		}
+		props := object(schema["properties"])
+		for _, name := range list(schema["required"]) {
+			if _, ok := obj[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required field %q", at, name))
+			}
+		}
+		for key, v := range obj {
+			if prop := object(props[key]); prop != nil {
+				problems = append(problems, s.check(prop, v, at+"."+key)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					problems = append(problems, fmt.Sprintf("%s: undocumented field %q", at, key))
+				}
+			case map[string]interface{}:
+				problems = append(problems, s.check(extra, v, at+"."+key)...)
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %T", at, value)}
+		}
+		for i, item := range arr {
+			problems = append(problems, s.check(object(schema["items"]), item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected string, got %T", at, value)}
+		}
+		if enum := list(schema["enum"]); len(enum) > 0 && !containsValue(enum, str) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %v", at, str, enum))
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not an RFC 3339 date-time", at, str))
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s: expected integer, got %v", at, value)}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s: expected number, got %T", at, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected boolean, got %T", at, value)}
+		}
+	}
+	return problems
+}
+
+// resolve looks up a local reference such as "#/components/schemas/Case"
+func (s *APISpec) resolve(ref string) map[string]interface{} {
+	node := s.doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		node = object(node[part])
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+func object(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func list(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func containsValue(values []interface{}, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
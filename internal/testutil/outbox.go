@@ -0,0 +1,34 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"ncoe/internal/mail"
+)
+
+// Outbox stands in for the SMTP relay, keeping the messages sent to it.
+// While Fail is set every send returns it instead.
+type Outbox struct {
+	mu       sync.Mutex
+	messages []mail.Message
+	Fail     error
+}
+
+// Send keeps msg, or returns Fail
+func (o *Outbox) Send(ctx context.Context, msg mail.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.Fail != nil {
+		return o.Fail
+	}
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (o *Outbox) Messages() []mail.Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]mail.Message(nil), o.messages...)
+}
@@ -2,19 +2,27 @@
 package testutil
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/netip"
+	"net/textproto"
 	"net/url"
-	"path/filepath"
-	"runtime"
 	"strings"
 	"testing"
+	"time"
 
+	"ncoe"
+	"ncoe/internal/assets"
 	"ncoe/internal/config"
 	"ncoe/internal/handler"
+	"ncoe/internal/metrics"
 	"ncoe/internal/middleware"
+	"ncoe/internal/oidc"
 	"ncoe/internal/repository/mock"
 	"ncoe/internal/service"
 	"ncoe/internal/templates"
@@ -26,38 +34,200 @@ type TestServer struct {
 	*httptest.Server
 	Repos  *mock.Repositories
 	Client *http.Client
-	t      *testing.T
+
+	// Clock is the time sessions and logins see, so tests can move it on
+	// instead of waiting
+	Clock *Clock
+
+	// Services that do background work, so tests can drive and await it
+	Auth     *service.AuthService
+	Cases    *service.CaseService
+	Webhooks *service.WebhookService
+
+	t *testing.T
+}
+
+// TestRetryPolicy retries webhook deliveries quickly so tests can exercise backoff
+var TestRetryPolicy = service.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond}
+
+// Option configures optional parts of the test server
+type Option func(*serverOptions)
+
+type serverOptions struct {
+	idp              *FakeIdP
+	sso              service.SSOConfig
+	mfaRequiredRoles []string
+	sessionPolicy    service.SessionPolicy
+	casePolicy       service.CasePolicy
+	cspReportOnly    bool
+	intakePolicy     service.IntakePolicy
+	challenge        service.Challenge
+	accessLog        bool
+	metricsToken     string
+	outbox           *Outbox
+	noDemoLogins     bool
+	trustedProxies   []netip.Prefix
 }
 
-// projectRoot returns the absolute path to the ncoe project root.
-// It uses runtime.Caller to find the path relative to this source file.
-func projectRoot() string {
-	_, filename, _, ok := runtime.Caller(0)
-	if !ok {
-		panic("testutil: unable to determine project root")
+// WithSSO enables single sign-on against a fake identity provider
+func WithSSO(idp *FakeIdP, cfg service.SSOConfig) Option {
+	return func(o *serverOptions) {
+		o.idp = idp
+		o.sso = cfg
+	}
+}
+
+// WithMFARequired starts the server with a policy requiring a second factor
+// for the given roles, as MFA_REQUIRED_ROLES does
+func WithMFARequired(roles ...string) Option {
+	return func(o *serverOptions) {
+		o.mfaRequiredRoles = roles
+	}
+}
+
+// WithSessionPolicy replaces the default session timeouts, so tests can
+// watch sessions expire
+func WithSessionPolicy(policy service.SessionPolicy) Option {
+	return func(o *serverOptions) {
+		o.sessionPolicy = policy
+	}
+}
+
+// WithoutDemoLogins turns off the demo logins the mock repositories
+// otherwise allow, so unknown emails and accounts without a password are
+// refused as they are with a database
+func WithoutDemoLogins() Option {
+	return func(o *serverOptions) {
+		o.noDemoLogins = true
+	}
+}
+
+// WithTrustedProxies believes X-Forwarded-For and X-Real-IP from the given
+// ranges, as TRUSTED_PROXIES does; test requests come from 127.0.0.1
+func WithTrustedProxies(prefixes ...string) Option {
+	return func(o *serverOptions) {
+		for _, p := range prefixes {
+			o.trustedProxies = append(o.trustedProxies, netip.MustParsePrefix(p))
+		}
+	}
+}
+
+// WithCasePolicy replaces the default case numbering and deadline policy,
+// as the branding file's case_prefixes and deadlines sections do
+func WithCasePolicy(policy service.CasePolicy) Option {
+	return func(o *serverOptions) {
+		o.casePolicy = policy
+	}
+}
+
+// WithCSPReportOnly sends the Content-Security-Policy report-only, as
+// CSP_REPORT_ONLY does
+func WithCSPReportOnly() Option {
+	return func(o *serverOptions) {
+		o.cspReportOnly = true
+	}
+}
+
+// WithIntakePolicy sets limits on public submissions. By default there are
+// none, so tests can post forms without a form token.
+func WithIntakePolicy(policy service.IntakePolicy) Option {
+	return func(o *serverOptions) {
+		o.intakePolicy = policy
+	}
+}
+
+// WithChallenge adds a human-verification challenge to the public forms,
+// such as a FakeChallenge
+func WithChallenge(c service.Challenge) Option {
+	return func(o *serverOptions) {
+		o.challenge = c
+	}
+}
+
+// WithAccessLog adds the access log and panic recovery middleware that
+// production runs, for tests that read the logs (see CaptureLogs)
+func WithAccessLog() Option {
+	return func(o *serverOptions) {
+		o.accessLog = true
+	}
+}
+
+// WithMetricsToken serves /metrics to requests presenting token as a bearer
+// token, as METRICS_TOKEN does. Metrics are process-wide, so tests compare
+// values before and after what they measure.
+func WithMetricsToken(token string) Option {
+	return func(o *serverOptions) {
+		o.metricsToken = token
+	}
+}
+
+// WithOutbox emails invitation and password reset links into outbox, as
+// SMTP_ADDRESS does through a relay
+func WithOutbox(outbox *Outbox) Option {
+	return func(o *serverOptions) {
+		o.outbox = outbox
 	}
-	// This file is at internal/testutil/server.go, so go up 2 levels
-	return filepath.Dir(filepath.Dir(filepath.Dir(filename)))
 }
 
 // NewTestServer creates a fully configured test server with mock repositories.
-// Templates are loaded using an absolute path, so tests work from any directory.
-func NewTestServer(t *testing.T) *TestServer {
+// Templates and static files are the copies built into the binary, as in
+// production, so tests work from any directory.
+func NewTestServer(t *testing.T, opts ...Option) *TestServer {
 	t.Helper()
 
-	root := projectRoot()
+	options := serverOptions{sessionPolicy: service.DefaultSessionPolicy, casePolicy: service.DefaultCasePolicy}
+	for _, opt := range opts {
+		opt(&options)
+	}
 
 	// Initialize mock repositories
 	repos := mock.NewRepositories()
 
 	// Initialize services
-	authService := service.NewAuthService(repos.User, repos.Session)
-	caseService := service.NewCaseService(repos.Case)
+	webhookService := service.NewWebhookService(repos.Webhook, TestRetryPolicy)
+	auditService := service.NewAuditService(repos.Audit)
+	mfaService, err := service.NewMFAService(repos.MFA, repos.User, repos.Session, auditService, "TEC Staff Portal", options.mfaRequiredRoles)
+	if err != nil {
+		t.Fatalf("NewTestServer: %v", err)
+	}
+	clock := &Clock{}
+	authService := service.NewAuthServiceWithClock(repos.User, repos.Session, mfaService, options.sessionPolicy, !options.noDemoLogins, clock.Now)
+	userService := service.NewUserService(repos.User, repos.Session, auditService)
+	caseService := service.NewCaseService(repos.Case, repos.User, webhookService, options.casePolicy)
 	dashboardService := service.NewDashboardService(repos.Case)
+	reportService := service.NewReportService(repos.Case, repos.User)
+	ackService := service.NewAcknowledgmentService(repos.Acknowledgment)
+	annualReportService := service.NewAnnualReportService(repos.Case, repos.Opinion, repos.Acknowledgment, repos.AnnualReport)
+	intakeService := service.NewIntakeService(caseService, repos.HeldSubmission, options.challenge, options.intakePolicy)
+	opinionService := service.NewOpinionService(repos.Case, repos.Opinion, webhookService)
+	redactionService := service.NewRedactionService(repos.Case, repos.Redaction)
+	tokenService := service.NewAPITokenService(repos.APIToken, repos.User)
+	var mailService *service.MailService
+	if options.outbox != nil {
+		mailService = service.NewMailService(options.outbox, "test@test.gov")
+	}
 
-	// Load templates from absolute path (quiet mode for tests)
-	templateDir := filepath.Join(root, "templates")
-	tmpl := templates.NewQuietRenderer(templateDir)
+	var ssoService *service.SSOService
+	if options.idp != nil {
+		provider := oidc.NewProvider(oidc.Config{
+			Issuer:       options.idp.URL,
+			ClientID:     options.idp.ClientID,
+			ClientSecret: options.idp.ClientSecret,
+			Scopes:       []string{"email", "profile"},
+		})
+		if ssoService, err = service.NewSSOService(provider, repos.User, options.sso); err != nil {
+			t.Fatalf("NewTestServer: %v", err)
+		}
+	}
+
+	static, err := assets.New(ncoe.Static(), false)
+	if err != nil {
+		t.Fatalf("NewTestServer: %v", err)
+	}
+	tmpl, err := templates.NewRenderer(ncoe.Templates(), templates.Options{Static: static, Quiet: true})
+	if err != nil {
+		t.Fatalf("NewTestServer: %v", err)
+	}
 
 	// Default test branding
 	branding := config.Branding{
@@ -69,18 +239,31 @@ func NewTestServer(t *testing.T) *TestServer {
 		ContactPhone: "(555) 555-5555",
 	}
 
+	// Links are built from the server's own address, as production builds
+	// them from PUBLIC_URL, so the handlers need it before the server starts
+	server := httptest.NewUnstartedServer(nil)
+	baseURL := "http://" + server.Listener.Addr().String()
+
 	// Initialize handlers
-	authHandler := handler.NewAuthHandler(authService, tmpl, branding)
-	staffHandler := handler.NewStaffHandler(caseService, dashboardService, tmpl, branding)
-	publicHandler := handler.NewPublicHandler(caseService, tmpl, branding)
+	authHandler := handler.NewAuthHandler(authService, mfaService, ssoService, userService, tmpl, branding, baseURL)
+	staffHandler := handler.NewStaffHandler(caseService, dashboardService, opinionService, redactionService, tokenService, webhookService, mfaService, authService, userService, auditService, reportService, ackService, annualReportService, intakeService, mailService, tmpl, branding, baseURL)
+	publicHandler := handler.NewPublicHandler(caseService, opinionService, intakeService, tmpl, branding, baseURL)
+	apiHandler := handler.NewAPIHandler(caseService, dashboardService, opinionService)
 
 	// Setup routes (mirrors cmd/server/main.go)
 	mux := http.NewServeMux()
 
+	mux.Handle(assets.Prefix, static)
+	mux.Handle(middleware.CSPReportPath, middleware.CSPReport(middleware.CSPReportRate))
+
 	// Public routes
 	mux.HandleFunc("/", publicHandler.Home)
 	mux.HandleFunc("/staff/login", authHandler.StaffLogin)
+	mux.HandleFunc("/staff/login/mfa", authHandler.StaffLoginMFA)
+	mux.HandleFunc("/staff/login/sso", authHandler.SSOLogin)
+	mux.HandleFunc("/staff/login/sso/callback", authHandler.SSOCallback)
 	mux.HandleFunc("/staff/logout", authHandler.Logout)
+	mux.HandleFunc("/staff/password", authHandler.SetPassword) // Invitation and password reset links
 	mux.HandleFunc("/submit/advisory-opinion", publicHandler.SubmitAdvisoryOpinion)
 	mux.HandleFunc("/submit/ethics-complaint", publicHandler.SubmitEthicsComplaint)
 	mux.HandleFunc("/submit/acknowledgment", publicHandler.SubmitAcknowledgment)
@@ -88,23 +271,59 @@ func NewTestServer(t *testing.T) *TestServer {
 	mux.HandleFunc("/submit/confirmation", publicHandler.Confirmation)
 	mux.HandleFunc("/search", publicHandler.Search)
 	mux.HandleFunc("/opinions/", publicHandler.ViewOpinion)
+	mux.HandleFunc("/documents/", publicHandler.PublicDocument)
+	mux.HandleFunc("/statutes", publicHandler.Statutes)
+	mux.HandleFunc("/statutes/", publicHandler.Statutes)
+	mux.HandleFunc("/feeds/", publicHandler.OpinionFeed)
+	mux.HandleFunc("/sitemap.xml", publicHandler.Sitemap)
+	mux.HandleFunc("/robots.txt", publicHandler.Robots)
+
+	authMiddleware := middleware.NewAuthMiddleware(authService, tokenService)
+	mux.Handle("/api/v1/", authMiddleware.Authenticate(apiHandler))
 
 	// Staff routes (protected)
 	staffMux := http.NewServeMux()
 	staffMux.HandleFunc("/staff/dashboard", staffHandler.Dashboard)
 	staffMux.HandleFunc("/staff/cases", staffHandler.CaseList)
 	staffMux.HandleFunc("/staff/cases/", staffHandler.CaseDetail)
+	staffMux.HandleFunc("/staff/documents/", staffHandler.DocumentDetail)
 	staffMux.HandleFunc("/staff/acknowledgments", staffHandler.Acknowledgments)
 	staffMux.HandleFunc("/staff/acknowledgments/", staffHandler.AcknowledgmentsDetail)
 	staffMux.HandleFunc("/staff/deadlines", staffHandler.Deadlines)
 	staffMux.HandleFunc("/staff/reports", staffHandler.Reports)
+	staffMux.HandleFunc("/staff/reports/export", staffHandler.ReportExport) // ?format=csv or xlsx
+	staffMux.HandleFunc("/staff/reports/annual", staffHandler.AnnualReport)
+	staffMux.HandleFunc("/staff/reports/annual/", staffHandler.AnnualReport) // Handles /narrative and /download
 	staffMux.HandleFunc("/staff/users", staffHandler.Users)
+	staffMux.HandleFunc("/staff/users/", staffHandler.UserDetail)
+	staffMux.HandleFunc("/staff/audit", staffHandler.Audit)
 	staffMux.HandleFunc("/staff/settings", staffHandler.Settings)
+	staffMux.HandleFunc("/staff/settings/tokens", staffHandler.APITokens)
+	staffMux.HandleFunc("/staff/settings/tokens/", staffHandler.APITokens) // Handles /{id}/revoke
+	staffMux.HandleFunc("/staff/settings/mfa/", staffHandler.MFA)
+	staffMux.HandleFunc("/staff/settings/sessions/", staffHandler.Sessions) // Handles /{id}/revoke and /revoke-others
+	staffMux.HandleFunc("/staff/webhooks", staffHandler.Webhooks)
+	staffMux.HandleFunc("/staff/webhooks/", staffHandler.WebhookDetail) // Handles /{id}, actions and redelivery
+	staffMux.HandleFunc("/staff/triage", staffHandler.Triage)
+	staffMux.HandleFunc("/staff/triage/", staffHandler.TriageAction) // Handles /{id}/accept and /{id}/reject
 
-	authMiddleware := middleware.NewAuthMiddleware(authService)
-	mux.Handle("/staff/", authMiddleware.RequireAuth(staffMux))
+	mux.Handle("/staff/", authMiddleware.RequireAuth(middleware.Route(staffMux)))
 
-	server := httptest.NewServer(mux)
+	if options.metricsToken != "" {
+		mux.Handle("/metrics", middleware.RequireBearerToken(options.metricsToken, metrics.Handler(metrics.Default, dashboardService.Gauges)))
+	}
+
+	// The access log is left out unless asked for; it would only add noise
+	// to test output
+	var h http.Handler = middleware.Route(mux)
+	if options.accessLog {
+		h = middleware.Logging(middleware.Metrics(middleware.Recovery(h)))
+	} else {
+		h = middleware.Metrics(h)
+	}
+	secure := middleware.SecurityHeaders(middleware.SecurityOptions{ReportOnly: options.cspReportOnly, HSTS: true})
+	server.Config.Handler = middleware.RequestID(middleware.ClientIP(options.trustedProxies)(middleware.Tracing(secure(h))))
+	server.Start()
 
 	// Create cookie jar for session management
 	jar, _ := cookiejar.New(nil)
@@ -116,10 +335,14 @@ func NewTestServer(t *testing.T) *TestServer {
 	}
 
 	return &TestServer{
-		Server: server,
-		Repos:  repos,
-		Client: client,
-		t:      t,
+		Server:   server,
+		Repos:    repos,
+		Client:   client,
+		Clock:    clock,
+		Auth:     authService,
+		Cases:    caseService,
+		Webhooks: webhookService,
+		t:        t,
 	}
 }
 
@@ -139,6 +362,19 @@ func (ts *TestServer) GET(path string) *Response {
 	return ts.do(req)
 }
 
+// GETWithHeaders performs a GET request with extra headers (e.g. conditional request headers).
+func (ts *TestServer) GETWithHeaders(path string, headers map[string]string) *Response {
+	ts.t.Helper()
+	req, err := http.NewRequest("GET", ts.URL+path, nil)
+	if err != nil {
+		ts.t.Fatalf("GET %s: failed to create request: %v", path, err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return ts.do(req)
+}
+
 // POST performs a POST request with form data and returns the response with body.
 func (ts *TestServer) POST(path string, data url.Values) *Response {
 	ts.t.Helper()
@@ -150,6 +386,87 @@ func (ts *TestServer) POST(path string, data url.Values) *Response {
 	return ts.do(req)
 }
 
+// Request performs a request with a raw body of the given content type (e.g. JSON API calls).
+func (ts *TestServer) Request(method, path, contentType, body string) *Response {
+	ts.t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		ts.t.Fatalf("%s %s: failed to create request: %v", method, path, err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return ts.do(req)
+}
+
+// RequestWithToken performs a request authenticated by an API token sent as a
+// Bearer credential. The session cookie is ignored when a token is sent.
+func (ts *TestServer) RequestWithToken(method, path, token, contentType, body string) *Response {
+	ts.t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		ts.t.Fatalf("%s %s: failed to create request: %v", method, path, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return ts.do(req)
+}
+
+// UploadFile describes a file part for POSTMultipart.
+type UploadFile struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// POSTMultipart performs a multipart/form-data POST with form fields and files.
+func (ts *TestServer) POSTMultipart(path string, data url.Values, files ...UploadFile) *Response {
+	ts.t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for key, values := range data {
+		for _, v := range values {
+			mw.WriteField(key, v)
+		}
+	}
+	for _, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, f.Field, f.Filename))
+		h.Set("Content-Type", f.ContentType)
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			ts.t.Fatalf("POST %s: failed to create file part: %v", path, err)
+		}
+		part.Write(f.Content)
+	}
+	mw.Close()
+
+	req, err := http.NewRequest("POST", ts.URL+path, &buf)
+	if err != nil {
+		ts.t.Fatalf("POST %s: failed to create request: %v", path, err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return ts.do(req)
+}
+
+// Follow performs a GET request to a redirect response's Location, which may
+// be on another server (such as a fake identity provider).
+func (ts *TestServer) Follow(resp *Response) *Response {
+	ts.t.Helper()
+	location, err := resp.Location()
+	if err != nil {
+		ts.t.Fatalf("Follow: %d response has no Location: %v", resp.StatusCode, err)
+	}
+	req, err := http.NewRequest("GET", location.String(), nil)
+	if err != nil {
+		ts.t.Fatalf("Follow %s: failed to create request: %v", location, err)
+	}
+	return ts.do(req)
+}
+
 // HTMX performs a GET request with HX-Request header for HTMX fragment requests.
 func (ts *TestServer) HTMX(path string) *Response {
 	ts.t.Helper()
@@ -203,6 +520,13 @@ func (ts *TestServer) SessionToken() string {
 	return ""
 }
 
+// SetSessionToken replaces the session cookie, e.g. to switch between
+// browsers signed in as different users
+func (ts *TestServer) SetSessionToken(token string) {
+	u, _ := url.Parse(ts.URL)
+	ts.Client.Jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: token, Path: "/"}})
+}
+
 // ClearCookies removes all cookies from the cookie jar.
 func (ts *TestServer) ClearCookies() {
 	jar, _ := cookiejar.New(nil)
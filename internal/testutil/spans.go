@@ -0,0 +1,69 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"ncoe/internal/tracing"
+)
+
+// Spans captures the spans the server records. Servers started after
+// CaptureSpans trace into it.
+type Spans struct {
+	t      *testing.T
+	tracer *tracing.Tracer
+	mu     sync.Mutex
+	spans  []tracing.SpanData
+}
+
+// CaptureSpans makes the default tracer export to the returned Spans until
+// the test ends
+func CaptureSpans(t *testing.T) *Spans {
+	t.Helper()
+	spans := &Spans{t: t}
+	spans.tracer = tracing.New(tracing.Options{Exporter: spans})
+	previous := tracing.Default()
+	tracing.SetDefault(spans.tracer)
+	t.Cleanup(func() {
+		tracing.SetDefault(previous)
+		spans.tracer.Shutdown(context.Background())
+	})
+	return spans
+}
+
+func (s *Spans) Export(ctx context.Context, spans []tracing.SpanData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spans = append(s.spans, spans...)
+	return nil
+}
+
+// Trace returns the ended spans of the trace with the given ID, in the
+// order they ended
+func (s *Spans) Trace(traceID string) []tracing.SpanData {
+	s.t.Helper()
+	if err := s.tracer.Flush(context.Background()); err != nil {
+		s.t.Fatalf("flushing spans: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var trace []tracing.SpanData
+	for _, span := range s.spans {
+		if span.TraceID.String() == traceID {
+			trace = append(trace, span)
+		}
+	}
+	return trace
+}
+
+// All returns every span ended so far, in the order they ended
+func (s *Spans) All() []tracing.SpanData {
+	s.t.Helper()
+	if err := s.tracer.Flush(context.Background()); err != nil {
+		s.t.Fatalf("flushing spans: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]tracing.SpanData(nil), s.spans...)
+}
@@ -0,0 +1,126 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcKey is the shared secret used by the RFC 4226 and RFC 6238 test vectors
+const rfcKey = "12345678901234567890"
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 4226 Appendix D
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := hotp([]byte(rfcKey), uint64(counter), 6); got != code {
+			t.Errorf("hotp(counter=%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTPVectors(t *testing.T) {
+	// RFC 6238 Appendix B, SHA-1 rows (eight digits)
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		step := Step(time.Unix(v.unix, 0))
+		if got := hotp([]byte(rfcKey), uint64(step), 8); got != v.code {
+			t.Errorf("T=%d: got %s, want %s", v.unix, got, v.code)
+		}
+	}
+
+	// The six-digit code is the low six digits of the same value
+	secret := encoding.EncodeToString([]byte(rfcKey))
+	code, err := Code(secret, time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "287082" {
+		t.Errorf("Code(T=59) = %s, want 287082", code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := GenerateSecret()
+	now := time.Unix(1700000000, 0)
+	code, _ := Code(secret, now)
+
+	step, ok := Validate(secret, code, now, 1)
+	if !ok || step != Step(now) {
+		t.Fatalf("Validate(current code) = %d, %v", step, ok)
+	}
+
+	// One step of clock drift either way is tolerated, two is not
+	if _, ok := Validate(secret, code, now.Add(Period), 1); !ok {
+		t.Error("code from the previous step was rejected")
+	}
+	if _, ok := Validate(secret, code, now.Add(-Period), 1); !ok {
+		t.Error("code from the next step was rejected")
+	}
+	if _, ok := Validate(secret, code, now.Add(2*Period), 1); ok {
+		t.Error("code from two steps ago was accepted")
+	}
+	if _, ok := Validate(secret, code, now.Add(Period), 0); ok {
+		t.Error("code from the previous step was accepted with no skew")
+	}
+
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := Validate(secret, bad, now, 1); ok {
+			t.Errorf("Validate(%q) accepted", bad)
+		}
+	}
+	if _, ok := Validate("not base32!", code, now, 1); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestSecretFormats(t *testing.T) {
+	secret := GenerateSecret()
+	if len(secret) != 32 || strings.Contains(secret, "=") {
+		t.Fatalf("GenerateSecret() = %q, want 32 unpadded base32 characters", secret)
+	}
+	if GenerateSecret() == secret {
+		t.Fatal("GenerateSecret returned the same secret twice")
+	}
+
+	now := time.Now()
+	want, _ := Code(secret, now)
+	// Users copy secrets in groups of four, in lower case
+	var groups []string
+	for i := 0; i < len(secret); i += 4 {
+		groups = append(groups, strings.ToLower(secret[i:i+4]))
+	}
+	got, err := Code(strings.Join(groups, " "), now)
+	if err != nil || got != want {
+		t.Errorf("Code(grouped lower-case secret) = %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("NCOE Staff", "jdoe@ethics.nv.gov", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("URI = %s, want otpauth://totp/...", uri)
+	}
+	if u.Path != "/NCOE Staff:jdoe@ethics.nv.gov" {
+		t.Errorf("label = %q", u.Path)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "NCOE Staff" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("query = %v", q)
+	}
+}
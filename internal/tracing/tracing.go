@@ -0,0 +1,383 @@
+// Package tracing records spans for requests and the work done to serve
+// them, propagates W3C trace context, and exports spans over OTLP/HTTP or
+// to a writer such as stdout.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a trace: a request and everything done to serve it,
+// across services
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeros
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeros
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is what is propagated to and from other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool // Whether the trace is being recorded
+}
+
+// ParseTraceparent parses a W3C traceparent header such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func ParseTraceparent(header string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	// Later versions may add fields, but keep these four
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	version, err1 := hex.DecodeString(parts[0])
+	flags, err2 := hex.DecodeString(parts[3])
+	if err1 != nil || err2 != nil || len(version) != 1 || len(flags) != 1 ||
+		!decodeID(sc.TraceID[:], parts[1]) || !decodeID(sc.SpanID[:], parts[2]) {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// decodeID decodes lowercase hex of exactly len(dst) bytes
+func decodeID(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Traceparent formats sc as a W3C traceparent header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// SpanKind says what a span represents, as in OTLP
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1 // Work within the server
+	KindServer   SpanKind = 2 // Serving a request
+)
+
+// Attribute is a key-value pair describing a span
+type Attribute struct {
+	Key   string
+	Value any // string, bool, int, int64 or float64; others are formatted with %v
+}
+
+// SpanData is a finished span, as exported
+type SpanData struct {
+	Name       string
+	Kind       SpanKind
+	TraceID    TraceID
+	SpanID     SpanID
+	Parent     SpanID // Zero for the root span of a trace
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Error      string // Why the operation failed; empty when it succeeded
+}
+
+// Exporter sends finished spans somewhere, such as an OTLP collector
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Span is an operation being timed. Its methods may be called on a nil
+// span, and on a span whose trace is not sampled; neither records anything.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the span's trace and span IDs
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName renames the span, e.g. once the route serving a request is known
+func (s *Span) SetName(name string) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+// SetAttributes adds attributes given as alternating keys and values, as
+// with slog. An attribute replaces one with the same key.
+func (s *Span) SetAttributes(kv ...any) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		replaced := false
+		for j := range s.data.Attributes {
+			if s.data.Attributes[j].Key == key {
+				s.data.Attributes[j].Value, replaced = kv[i+1], true
+			}
+		}
+		if !replaced {
+			s.data.Attributes = append(s.data.Attributes, Attribute{Key: key, Value: kv[i+1]})
+		}
+	}
+}
+
+// RecordError marks the span as failed with err; a nil err does nothing
+func (s *Span) RecordError(err error) {
+	if !s.recording() || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End finishes the span and queues it for export. Later calls do nothing,
+// and spans in traces the caller did not sample are never queued.
+func (s *Span) End() {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.tracer.queue(data)
+}
+
+// recording reports whether the span's trace is sampled, as the caller's
+// traceparent flags decide
+func (s *Span) recording() bool {
+	return s != nil && s.sc.Sampled
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span in ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Options configures New
+type Options struct {
+	Exporter Exporter // Where finished spans go; nil discards them
+	// Interval between exports; spans are also exported once maxBatch
+	// are waiting. Defaults to five seconds.
+	Interval time.Duration
+}
+
+// maxBatch is how many finished spans are exported at once
+const maxBatch = 512
+
+// maxQueued bounds the spans waiting for export while the exporter is
+// failing; newer spans are dropped
+const maxQueued = 8 * maxBatch
+
+// Tracer starts spans and exports them in batches in the background
+type Tracer struct {
+	opts Options
+
+	mu      sync.Mutex
+	pending []SpanData
+	dropped int
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// New returns a tracer. With an exporter it exports in the background
+// until Shutdown.
+func New(opts Options) *Tracer {
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Second
+	}
+	t := &Tracer{opts: opts, flush: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	if opts.Exporter == nil {
+		close(t.done)
+		return t
+	}
+	go t.run()
+	return t
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.flush:
+		case <-t.stop:
+			return
+		}
+		t.Flush(context.Background())
+	}
+}
+
+// Start starts a span as a child of the span in ctx, or as the root of a
+// new trace, and returns a context carrying it
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx).SpanContext()
+	if !parent.TraceID.IsValid() {
+		parent = SpanContext{Sampled: true}
+	}
+	return t.start(ctx, name, KindInternal, parent)
+}
+
+// StartRequest starts the span for serving a request. The trace continues
+// the caller's when traceparent, the request's header, is valid.
+func (t *Tracer) StartRequest(ctx context.Context, name, traceparent string) (context.Context, *Span) {
+	parent, ok := ParseTraceparent(traceparent)
+	if !ok {
+		parent = SpanContext{Sampled: true}
+	}
+	return t.start(ctx, name, KindServer, parent)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	span := &Span{tracer: t, sc: SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}}
+	if !span.sc.TraceID.IsValid() {
+		rand.Read(span.sc.TraceID[:])
+	}
+	rand.Read(span.sc.SpanID[:])
+	span.data = SpanData{
+		Name:    name,
+		Kind:    kind,
+		TraceID: span.sc.TraceID,
+		SpanID:  span.sc.SpanID,
+		Parent:  parent.SpanID,
+		Start:   time.Now(),
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (t *Tracer) queue(data SpanData) {
+	if t.opts.Exporter == nil {
+		return
+	}
+	t.mu.Lock()
+	if len(t.pending) >= maxQueued {
+		t.dropped++
+	} else {
+		t.pending = append(t.pending, data)
+	}
+	full := len(t.pending) >= maxBatch
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush exports the spans finished so far
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t.opts.Exporter == nil {
+		return nil
+	}
+	t.mu.Lock()
+	pending, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		slog.Warn("spans dropped while export was failing", "dropped", dropped)
+	}
+
+	var errs []error
+	for len(pending) > 0 {
+		n := min(len(pending), maxBatch)
+		if err := t.opts.Exporter.Export(ctx, pending[:n]); err != nil {
+			errs = append(errs, err)
+		}
+		pending = pending[n:]
+	}
+	if err := errors.Join(errs...); err != nil {
+		slog.Error("exporting spans", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops background export and exports the spans still waiting
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.Flush(ctx)
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(New(Options{}))
+}
+
+// Default returns the tracer Start uses. Until SetDefault is called it
+// assigns IDs but exports nothing.
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// SetDefault makes t the tracer Start uses
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Start starts a span with the default tracer; see Tracer.Start
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return Default().Start(ctx, name)
+}
@@ -0,0 +1,185 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recorder keeps exported spans
+type recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (r *recorder) Export(ctx context.Context, spans []SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(header)
+	if !ok || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Fatalf("ParseTraceparent(%q) = %+v, %v", header, sc, ok)
+	}
+	if sc.Traceparent() != header {
+		t.Errorf("Traceparent() = %q, want %q", sc.Traceparent(), header)
+	}
+	if sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"); !ok || sc.Sampled {
+		t.Errorf("unsampled traceparent parsed as %+v, %v", sc, ok)
+	}
+	// Later versions may append fields
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); !ok {
+		t.Error("version 01 with an extra field refused")
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		if sc, ok := ParseTraceparent(bad); ok {
+			t.Errorf("ParseTraceparent(%q) = %+v, want refused", bad, sc)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	rec := &recorder{}
+	tracer := New(Options{Exporter: rec})
+	defer tracer.Shutdown(context.Background())
+
+	ctx, server := tracer.StartRequest(context.Background(), "GET", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	server.SetAttributes("http.route", "/search", "request_id", "r1")
+	_, child := tracer.Start(ctx, "CaseService.List")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	server.SetName("GET /search")
+	server.SetAttributes("http.route", "/search/")
+	server.End()
+	server.End()
+
+	// A caller that is not recording is not recorded either
+	_, unsampled := tracer.StartRequest(context.Background(), "GET", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	unsampled.End()
+	// Nil spans are safe to use
+	var none *Span
+	none.SetAttributes("k", "v")
+	none.End()
+
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(rec.spans))
+	}
+	c, s := rec.spans[0], rec.spans[1]
+	if s.Name != "GET /search" || s.Kind != KindServer || s.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || s.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("server span %+v", s)
+	}
+	if len(s.Attributes) != 2 || s.Attributes[0] != (Attribute{"http.route", "/search/"}) {
+		t.Errorf("server attributes %v", s.Attributes)
+	}
+	if c.TraceID != s.TraceID || c.Parent != s.SpanID || c.Kind != KindInternal || c.Error != "boom" {
+		t.Errorf("child span %+v of %s", c, s.SpanID)
+	}
+
+	// Without a parent a new trace starts
+	_, root := tracer.Start(context.Background(), "NotifyOverdueDeadlines")
+	if sc := root.SpanContext(); !sc.TraceID.IsValid() || sc.TraceID == s.TraceID || !sc.Sampled {
+		t.Errorf("root span context %+v", sc)
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := New(Options{Exporter: NewWriterExporter(&buf)})
+	ctx, span := tracer.Start(context.Background(), "render public/search")
+	span.SetAttributes("page", "public/search")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %q, want a line per span", buf.String())
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["name"] != "render public/search" || got["kind"] != "internal" || got["attributes"].(map[string]any)["page"] != "public/search" {
+		t.Errorf("wrote %v", got)
+	}
+	if _, ok := got["parent_span_id"]; ok {
+		t.Errorf("root span written with a parent: %v", got)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	var header http.Header
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header.Clone()
+	}))
+	defer collector.Close()
+
+	tracer := New(Options{Exporter: NewOTLPExporter(collector.URL, map[string]string{"X-Api-Key": "k1"}, "ncoe-test")})
+	_, span := tracer.StartRequest(context.Background(), "GET /search", "")
+	span.SetAttributes("http.response.status_code", 500, "request_id", "r1")
+	span.RecordError(errors.New("Internal Server Error"))
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Get("Content-Type") != "application/json" || header.Get("X-Api-Key") != "k1" {
+		t.Errorf("headers %v", header)
+	}
+	var req otlpRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+	rs := req.ResourceSpans[0]
+	if *rs.Resource.Attributes[0].Value.StringValue != "ncoe-test" {
+		t.Errorf("resource %+v", rs.Resource)
+	}
+	got := rs.ScopeSpans[0].Spans[0]
+	if got.Name != "GET /search" || got.Kind != KindServer || len(got.TraceID) != 32 || got.ParentSpanID != "" || got.Status.Code != 2 {
+		t.Errorf("span %+v", got)
+	}
+	if a := got.Attributes[0]; a.Key != "http.response.status_code" || a.Value.IntValue == nil || *a.Value.IntValue != "500" {
+		t.Errorf("attribute %+v", a)
+	}
+
+	// A collector refusing spans is reported
+	failing := New(Options{Exporter: NewOTLPExporter(collector.URL+"/wrong", nil, "ncoe-test")})
+	_, span = failing.Start(context.Background(), "x")
+	span.End()
+	if err := failing.Shutdown(context.Background()); err == nil {
+		t.Error("export to a missing endpoint succeeded")
+	}
+}
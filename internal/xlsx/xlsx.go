@@ -0,0 +1,200 @@
+// Package xlsx writes simple Office Open XML spreadsheets: one table per
+// sheet, with a bold, frozen header row and text or number cells. The output
+// is byte-for-byte reproducible for the same input.
+package xlsx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"ncoe/internal/ooxml"
+)
+
+// ContentType is the media type of .xlsx files
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// maxSheetName is Excel's limit on sheet name length
+const maxSheetName = 31
+
+// Sheet is one worksheet. Cells may be strings, integers or floats; nil
+// leaves the cell empty.
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]interface{}
+}
+
+// Write writes the sheets as a workbook
+func Write(w io.Writer, sheets []Sheet) error {
+	if len(sheets) == 0 {
+		return fmt.Errorf("xlsx: a workbook needs at least one sheet")
+	}
+	seen := map[string]bool{}
+	for _, s := range sheets {
+		if err := checkSheetName(s.Name); err != nil {
+			return err
+		}
+		// Excel compares sheet names without regard to case
+		key := strings.ToLower(s.Name)
+		if seen[key] {
+			return fmt.Errorf("xlsx: duplicate sheet name %q", s.Name)
+		}
+		seen[key] = true
+	}
+
+	parts := []ooxml.Part{
+		{Name: "[Content_Types].xml", Body: contentTypes(len(sheets))},
+		{Name: "_rels/.rels", Body: []byte(rootRels)},
+		{Name: "xl/workbook.xml", Body: workbook(sheets)},
+		{Name: "xl/_rels/workbook.xml.rels", Body: workbookRels(len(sheets))},
+		{Name: "xl/styles.xml", Body: []byte(styles)},
+	}
+	for i, s := range sheets {
+		body, err := worksheet(s)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, ooxml.Part{Name: fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), Body: body})
+	}
+
+	return ooxml.Write(w, parts)
+}
+
+// checkSheetName applies Excel's rules for sheet names
+func checkSheetName(name string) error {
+	if name == "" || len([]rune(name)) > maxSheetName {
+		return fmt.Errorf("xlsx: sheet name %q must be 1 to %d characters", name, maxSheetName)
+	}
+	if strings.ContainsAny(name, `[]:*?/\`) || strings.HasPrefix(name, "'") || strings.HasSuffix(name, "'") {
+		return fmt.Errorf("xlsx: sheet name %q contains a character Excel does not allow", name)
+	}
+	return nil
+}
+
+// ColumnName returns the letters of a zero-based column index: A, B, ... Z, AA
+func ColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func worksheet(s Sheet) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(s.Header) > 0 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	b.WriteString(`<sheetData>`)
+
+	row := 0
+	if len(s.Header) > 0 {
+		row++
+		fmt.Fprintf(&b, `<row r="%d">`, row)
+		for col, h := range s.Header {
+			writeString(&b, ColumnName(col)+strconv.Itoa(row), h, 1)
+		}
+		b.WriteString(`</row>`)
+	}
+	for _, cells := range s.Rows {
+		row++
+		fmt.Fprintf(&b, `<row r="%d">`, row)
+		for col, v := range cells {
+			ref := ColumnName(col) + strconv.Itoa(row)
+			switch v := v.(type) {
+			case nil:
+			case string:
+				writeString(&b, ref, v, 0)
+			case int:
+				writeNumber(&b, ref, float64(v))
+			case int64:
+				writeNumber(&b, ref, float64(v))
+			case float64:
+				writeNumber(&b, ref, v)
+			default:
+				return nil, fmt.Errorf("xlsx: sheet %q cell %s: unsupported value of type %T", s.Name, ref, v)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes(), nil
+}
+
+func writeString(b *bytes.Buffer, ref, value string, style int) {
+	fmt.Fprintf(b, `<c r="%s" t="inlineStr"`, ref)
+	if style != 0 {
+		fmt.Fprintf(b, ` s="%d"`, style)
+	}
+	b.WriteString(`><is><t xml:space="preserve">`)
+	xml.EscapeText(b, []byte(value))
+	b.WriteString(`</t></is></c>`)
+}
+
+func writeNumber(b *bytes.Buffer, ref string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return // Spreadsheets have no representation for these; leave the cell empty
+	}
+	fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func contentTypes(sheets int) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.Bytes()
+}
+
+func workbook(sheets []Sheet) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, s := range sheets {
+		b.WriteString(`<sheet name="`)
+		xml.EscapeText(&b, []byte(s.Name))
+		fmt.Fprintf(&b, `" sheetId="%d" r:id="rId%d"/>`, i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.Bytes()
+}
+
+func workbookRels(sheets int) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	return b.Bytes()
+}
+
+const rootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// styles defines two cell formats: 0 is plain, 1 is bold (header rows)
+const styles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
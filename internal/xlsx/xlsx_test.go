@@ -0,0 +1,130 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	cases := map[int]string{0: "A", 1: "B", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for i, want := range cases {
+		if got := ColumnName(i); got != want {
+			t.Errorf("ColumnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+// readParts unzips a workbook into part name → contents
+func readParts(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip archive: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(body)
+	}
+	return parts
+}
+
+func TestWrite(t *testing.T) {
+	sheets := []Sheet{
+		{
+			Name:   "Monthly",
+			Header: []string{"Month", "Opened", "Closed"},
+			Rows: [][]interface{}{
+				{"2024-07", 3, int64(2)},
+				{"R&D <draft>", nil, 1.5},
+			},
+		},
+		{Name: "Aging"},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, sheets); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	parts := readParts(t, buf.Bytes())
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		body, ok := parts[name]
+		if !ok {
+			t.Errorf("missing part %s", name)
+			continue
+		}
+		// Every part must be well-formed XML
+		dec := xml.NewDecoder(strings.NewReader(body))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("%s is not well-formed: %v", name, err)
+				break
+			}
+		}
+	}
+
+	if wb := parts["xl/workbook.xml"]; !strings.Contains(wb, `<sheet name="Monthly" sheetId="1" r:id="rId1"/>`) || !strings.Contains(wb, `<sheet name="Aging" sheetId="2" r:id="rId2"/>`) {
+		t.Errorf("workbook.xml does not list the sheets in order:\n%s", wb)
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">Month</t></is></c>`,
+		`<c r="B2"><v>3</v></c>`,
+		`<c r="C2"><v>2</v></c>`,
+		`<t xml:space="preserve">R&amp;D &lt;draft&gt;</t>`,
+		`<c r="C3"><v>1.5</v></c>`,
+		`state="frozen"`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet1.xml missing %s", want)
+		}
+	}
+	if strings.Contains(sheet, `r="B3"`) {
+		t.Error("nil value wrote a cell")
+	}
+	if strings.Contains(parts["xl/worksheets/sheet2.xml"], "frozen") {
+		t.Error("sheet without a header has a frozen row")
+	}
+}
+
+func TestWriteIsReproducible(t *testing.T) {
+	sheets := []Sheet{{Name: "Data", Header: []string{"A"}, Rows: [][]interface{}{{"x"}, {42}}}}
+	var a, b bytes.Buffer
+	if err := Write(&a, sheets); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&b, sheets); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("the same workbook produced different bytes")
+	}
+}
+
+func TestWriteRejectsBadInput(t *testing.T) {
+	bad := map[string][]Sheet{
+		"no sheets":         nil,
+		"empty name":        {{Name: ""}},
+		"long name":         {{Name: strings.Repeat("x", 32)}},
+		"slash in name":     {{Name: "2024/25"}},
+		"duplicate names":   {{Name: "Data"}, {Name: "data"}},
+		"unsupported value": {{Name: "Data", Rows: [][]interface{}{{true}}}},
+	}
+	for name, sheets := range bad {
+		if err := Write(io.Discard, sheets); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -164,6 +164,14 @@
         }
     }
 
+    // Print buttons: <button data-action="print">. The Content-Security-Policy
+    // blocks inline handlers such as onclick, so they are wired here.
+    document.addEventListener('click', function(evt) {
+        if (evt.target.closest('[data-action="print"]')) {
+            window.print();
+        }
+    });
+
     // Initialize on DOM ready
     document.addEventListener('DOMContentLoaded', function() {
         console.log('[NCOE] DOMContentLoaded');
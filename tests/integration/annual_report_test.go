@@ -0,0 +1,301 @@
+package integration
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/testutil"
+)
+
+// seedFiscalYear2021 adds cases, acknowledgments and an opinion around
+// fiscal year 2021 (July 1, 2020 to June 30, 2021), long before the demo data
+func seedFiscalYear2021(t *testing.T, ts *testutil.TestServer) {
+	t.Helper()
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 12, 0, 0, 0, domain.Location)
+	}
+	at := func(year int, month time.Month, d int) *time.Time {
+		t := day(year, month, d)
+		return &t
+	}
+
+	cases := []*domain.Case{
+		// Advisory opinions: one published on time, one closed without an
+		// opinion, one still pending, and one received the year before
+		{ID: "fy1", CaseNumber: "AO-2020-101", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusPublished, SubmittedAt: day(2020, 8, 1), PublishedAt: at(2020, 9, 15), DueDate: day(2020, 10, 1)},
+		{ID: "fy2", CaseNumber: "AO-2020-102", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusClosed, SubmittedAt: day(2020, 10, 1), ClosedAt: at(2021, 1, 10)},
+		{ID: "fy3", CaseNumber: "AO-2021-103", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusUnderReview, SubmittedAt: day(2021, 5, 1)},
+		{ID: "fy4", CaseNumber: "AO-2020-099", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusClosed, SubmittedAt: day(2020, 3, 1), ClosedAt: at(2020, 8, 1)},
+		// Complaints: stipulated, withdrawn, closed without a recorded
+		// disposition, and one resolved after the year ended
+		{ID: "fy5", CaseNumber: "EC-2020-201", Type: domain.CaseTypeEthicsComplaint, Status: domain.StatusClosed, SubmittedAt: day(2020, 9, 1), ClosedAt: at(2021, 2, 1), Disposition: domain.DispositionStipulated},
+		{ID: "fy6", CaseNumber: "EC-2020-202", Type: domain.CaseTypeEthicsComplaint, Status: domain.StatusWithdrawn, SubmittedAt: day(2020, 11, 1), ClosedAt: at(2021, 3, 1)},
+		{ID: "fy7", CaseNumber: "EC-2021-203", Type: domain.CaseTypeEthicsComplaint, Status: domain.StatusClosed, SubmittedAt: day(2021, 1, 5), ClosedAt: at(2021, 4, 1)},
+		{ID: "fy8", CaseNumber: "EC-2021-204", Type: domain.CaseTypeEthicsComplaint, Status: domain.StatusClosed, SubmittedAt: day(2021, 6, 1), ClosedAt: at(2021, 8, 1), Disposition: domain.DispositionViolation},
+		// Records requests: one on time, one late
+		{ID: "fy9", CaseNumber: "PRR-2021-301", Type: domain.CaseTypePublicRecordsRequest, Status: domain.StatusClosed, SubmittedAt: day(2021, 2, 1), DueDate: day(2021, 2, 15), ClosedAt: at(2021, 2, 10)},
+		{ID: "fy10", CaseNumber: "PRR-2021-302", Type: domain.CaseTypePublicRecordsRequest, Status: domain.StatusClosed, SubmittedAt: day(2021, 3, 1), DueDate: day(2021, 3, 15), ClosedAt: at(2021, 4, 1)},
+	}
+	for _, c := range cases {
+		ts.Repos.Case.Create(c)
+	}
+
+	for _, a := range []*domain.EthicsAcknowledgment{
+		{ID: "fy_ack1", CaseNumber: "EA-2020-401", OfficialName: "Ana Lopez", Agency: "Washoe County", AgencyType: "county", AcknowledgedAt: day(2020, 12, 1)},
+		{ID: "fy_ack2", CaseNumber: "EA-2021-402", OfficialName: "Ben Ortiz", Agency: "Nevada Gaming Control Board", AgencyType: "state", AcknowledgedAt: day(2021, 6, 29)},
+		{ID: "fy_ack3", CaseNumber: "EA-2021-403", OfficialName: "Cy Park", Agency: "Nevada Gaming Control Board", AgencyType: "state", AcknowledgedAt: day(2021, 7, 2)},
+	} {
+		ts.Repos.Acknowledgment.Create(a)
+	}
+
+	ts.Repos.Opinion.Create(&domain.PublishedOpinion{
+		ID:          "fy_op1",
+		CaseNumber:  "AO-2020-101",
+		Type:        domain.CaseTypeAdvisoryOpinion,
+		Title:       "Advisory Opinion: Outside Employment",
+		PublishedAt: day(2020, 9, 15),
+		Year:        2020,
+	})
+}
+
+// TestAnnualReportAgencyZone checks that fiscal years begin at midnight in
+// the agency's zone when the server runs on UTC, so the evening of June 30
+// still belongs to the year ending that day
+func TestAnnualReportAgencyZone(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	evening := func(year int) time.Time {
+		return time.Date(year, time.June, 30, 21, 0, 0, 0, domain.Location)
+	}
+	ts.Repos.Case.Create(&domain.Case{ID: "tz1", CaseNumber: "AO-2021-901", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusUnderReview, SubmittedAt: evening(2021)})
+	ts.Repos.Case.Create(&domain.Case{ID: "tz2", CaseNumber: "EC-2020-902", Type: domain.CaseTypeEthicsComplaint, Status: domain.StatusUnderReview, SubmittedAt: evening(2020)})
+	ts.Login("demo@ncoe.nv.gov", "password")
+
+	dom := testutil.ParseDOM(t, ts.GET("/staff/reports/annual?fy=2021").Body)
+	for id, want := range map[string]string{
+		"annual-ao-received": "1", // June 30, 2021: FY2021
+		"annual-ec-received": "0", // June 30, 2020: FY2020
+	} {
+		if got := dom.TextByID(id); got != want {
+			t.Errorf("#%s = %q, want %q", id, got, want)
+		}
+	}
+}
+
+// tableValues maps the first cell of each row to the rest
+func tableValues(dom *testutil.DOM, id string) map[string][]string {
+	values := map[string][]string{}
+	for _, row := range dom.TableRowsByID(id) {
+		if len(row) > 0 {
+			values[row[0]] = row[1:]
+		}
+	}
+	return values
+}
+
+func TestAnnualReport(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	seedFiscalYear2021(t, ts)
+	ts.Login("demo@ncoe.nv.gov", "password")
+
+	download := func(format string) *testutil.Response {
+		t.Helper()
+		resp := ts.GET("/staff/reports/annual/download?fy=2021&format=" + format)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s download: expected 200, got %d: %s", format, resp.StatusCode, resp.Body)
+		}
+		return resp
+	}
+
+	t.Run("Figures", func(t *testing.T) {
+		resp := ts.GET("/staff/reports/annual?fy=2021")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		dom := testutil.ParseDOM(t, resp.Body)
+		for id, want := range map[string]string{
+			"annual-ao-received":     "3",
+			"annual-ec-received":     "4",
+			"annual-acknowledgments": "2",
+			"annual-prr-received":    "2",
+		} {
+			if got := dom.TextByID(id); got != want {
+				t.Errorf("#%s = %q, want %q", id, got, want)
+			}
+		}
+
+		want := map[string]map[string][]string{
+			"table-introduction":      {"Advisory Opinion": {"3", "3", "1"}, "Ethics Complaint": {"4", "3", "1"}, "Public Records Request": {"2", "2", "0"}},
+			"table-advisory_opinions": {"Opinions issued": {"1"}, "Closed without an opinion": {"2"}, "Pending on June 30, 2021": {"1"}},
+			"table-complaints":        {"Stipulated agreement": {"1"}, "Withdrawn": {"1"}, "Not recorded": {"1"}, "Violation found": {"0"}, "Total resolved": {"3"}},
+			"table-acknowledgments":   {"State": {"1"}, "County": {"1"}, "Total": {"2"}},
+			"table-records_requests":  {"Completed by the deadline": {"1"}, "Completed after the deadline": {"1"}},
+			"table-publications":      {"AO-2020-101": {"Advisory Opinion: Outside Employment", "Sep 15, 2020"}},
+		}
+		for table, rows := range want {
+			got := tableValues(dom, table)
+			for label, values := range rows {
+				if strings.Join(got[label], ",") != strings.Join(values, ",") {
+					t.Errorf("%s / %s = %v, want %v", table, label, got[label], values)
+				}
+			}
+		}
+		if text := dom.TextByID("narrative-introduction"); !strings.Contains(text, "fiscal year 2021, from July 1, 2020 to June 30, 2021") {
+			t.Errorf("introduction = %q", text)
+		}
+		if text := dom.TextByID("narrative-records_requests"); !strings.Contains(text, "50% of requests") {
+			t.Errorf("records narrative = %q, want the on-time rate", text)
+		}
+	})
+
+	t.Run("Documents", func(t *testing.T) {
+		pdf := download("pdf")
+		if ct := pdf.Header.Get("Content-Type"); ct != "application/pdf" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if cd := pdf.Header.Get("Content-Disposition"); !strings.Contains(cd, "annual-report-fy2021.pdf") {
+			t.Errorf("Content-Disposition = %q", cd)
+		}
+		if !strings.HasPrefix(pdf.Body, "%PDF-") {
+			t.Fatal("download is not a PDF")
+		}
+		for _, want := range []string{"(Annual Report, Fiscal Year 2021) Tj", "(Stipulated agreement) Tj", "(Advisory Opinion: Outside Employment) Tj"} {
+			if !strings.Contains(pdf.Body, want) {
+				t.Errorf("PDF does not contain %q", want)
+			}
+		}
+
+		docx := download("docx")
+		if ct := docx.Header.Get("Content-Type"); !strings.Contains(ct, "wordprocessingml") {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		zr, err := zip.NewReader(bytes.NewReader([]byte(docx.Body)), int64(len(docx.Body)))
+		if err != nil {
+			t.Fatalf("DOCX is not a zip archive: %v", err)
+		}
+		var document string
+		for _, f := range zr.File {
+			if f.Name == "word/document.xml" {
+				rc, _ := f.Open()
+				body, _ := io.ReadAll(rc)
+				rc.Close()
+				document = string(body)
+			}
+		}
+		// The cover carries the agency's branding from the test config
+		if !strings.Contains(document, "Annual Report, Fiscal Year 2021") || !strings.Contains(document, "Test Ethics Commission") {
+			t.Error("document.xml is missing the title or agency name")
+		}
+
+		if resp := ts.GET("/staff/reports/annual/download?fy=2021&format=rtf"); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("unknown format: expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("NarrativeOverride", func(t *testing.T) {
+		original := download("pdf").Body
+
+		text := "The Commission moved to a new case management system this year.\n\nStaff thank the Legislature for its support."
+		resp := ts.POST("/staff/reports/annual/narrative", url.Values{"fy": {"2021"}, "section": {"introduction"}, "body": {text}})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected 303, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		dom := testutil.ParseDOM(t, ts.Follow(resp).Body)
+		if got := dom.TextByID("narrative-introduction"); !strings.Contains(got, "new case management system") {
+			t.Errorf("introduction = %q, want the override", got)
+		}
+		edited := download("pdf").Body
+		if !strings.Contains(edited, "(Staff thank the Legislature for its support.) Tj") {
+			t.Error("PDF does not use the override")
+		}
+		// Other years keep their own text
+		if got := testutil.ParseDOM(t, ts.GET("/staff/reports/annual?fy=2022").Body).TextByID("narrative-introduction"); strings.Contains(got, "new case management system") {
+			t.Error("override leaked into another fiscal year")
+		}
+
+		// Restoring the generated text gives back the original document
+		resp = ts.POST("/staff/reports/annual/narrative", url.Values{"fy": {"2021"}, "section": {"introduction"}, "reset": {"1"}})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("restore: expected 303, got %d", resp.StatusCode)
+		}
+		if download("pdf").Body != original {
+			t.Error("restoring the generated narrative did not reproduce the original PDF")
+		}
+
+		resp = ts.POST("/staff/reports/annual/narrative", url.Values{"fy": {"2021"}, "section": {"budget"}, "body": {"x"}})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("unknown section: expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Reproducible", func(t *testing.T) {
+		for _, format := range []string{"pdf", "docx"} {
+			first := download(format).Body
+			// Activity after the year ended must not change it
+			ts.Repos.Case.Create(&domain.Case{ID: "fy_late", CaseNumber: "AO-2021-999", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusSubmitted, SubmittedAt: time.Date(2021, 7, 1, 0, 0, 0, 0, domain.Location).Add(time.Minute)})
+			if download(format).Body != first {
+				t.Errorf("%s for a past year changed between downloads", format)
+			}
+		}
+	})
+
+	t.Run("Disposition", func(t *testing.T) {
+		resp := ts.POST("/staff/cases/fy7/_disposition", url.Values{"disposition": {string(domain.DispositionDismissedInsufficient)}})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		if c := ts.Repos.Case.GetByID("fy7"); c.Disposition != domain.DispositionDismissedInsufficient {
+			t.Fatalf("disposition = %q", c.Disposition)
+		}
+		got := tableValues(testutil.ParseDOM(t, ts.GET("/staff/reports/annual?fy=2021").Body), "table-complaints")
+		if got["Dismissed: insufficient evidence"][0] != "1" || got["Not recorded"] != nil {
+			t.Errorf("complaints by disposition = %v", got)
+		}
+
+		// Only complaints have a disposition
+		if resp := ts.POST("/staff/cases/fy1/_disposition", url.Values{"disposition": {string(domain.DispositionStipulated)}}); resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("advisory opinion: expected 422, got %d", resp.StatusCode)
+		}
+		if resp := ts.POST("/staff/cases/fy7/_disposition", url.Values{"disposition": {"settled"}}); resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("unknown disposition: expected 422, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("InvalidYear", func(t *testing.T) {
+		next := domain.FiscalYear(time.Now()) + 1
+		for _, path := range []string{"/staff/reports/annual?fy=next", "/staff/reports/annual?fy=1999", "/staff/reports/annual?fy=" + strconv.Itoa(next)} {
+			resp := ts.GET(path)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", path, resp.StatusCode)
+				continue
+			}
+			testutil.ParseDOM(t, resp.Body).AssertHasElementByID("annual-report-error")
+		}
+	})
+}
+
+func TestAcknowledgmentYearFilter(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	seedFiscalYear2021(t, ts)
+	ts.Login("demo@ncoe.nv.gov", "password")
+
+	dom := testutil.ParseDOM(t, ts.GET("/staff/acknowledgments?year=2021").Body)
+	dom.AssertContainsText("Ben Ortiz")
+	dom.AssertContainsText("Cy Park")
+	dom.AssertNotContainsText("Ana Lopez")
+	dom.AssertNotContainsText("Maria Garcia")
+}
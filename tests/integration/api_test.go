@@ -0,0 +1,436 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/testutil"
+)
+
+// apiCall is one request in the API contract table.
+type apiCall struct {
+	Method      string
+	Path        string
+	ContentType string
+	Body        string
+	WantStatus  int
+}
+
+// TestAPIContract checks every API response against the served OpenAPI document
+// and fails if any documented operation goes unexercised.
+func TestAPIContract(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	spec := ts.APISpec()
+
+	run := func(calls []apiCall) {
+		t.Helper()
+		for _, c := range calls {
+			resp := ts.Request(c.Method, c.Path, c.ContentType, c.Body)
+			if resp.StatusCode != c.WantStatus {
+				t.Errorf("%s %s: expected %d, got %d: %s", c.Method, c.Path, c.WantStatus, resp.StatusCode, resp.Body)
+			}
+			spec.Validate(c.Method, c.Path, resp)
+		}
+	}
+
+	// Anonymous
+	run([]apiCall{
+		{Method: "GET", Path: "/api/v1/cases", WantStatus: http.StatusUnauthorized},
+		{Method: "GET", Path: "/api/v1/stats", WantStatus: http.StatusUnauthorized},
+		{Method: "GET", Path: "/api/v1/opinions", WantStatus: http.StatusOK},
+		{Method: "GET", Path: "/api/v1/opinions?type=AO&limit=1", WantStatus: http.StatusOK},
+		{Method: "GET", Path: "/api/v1/opinions?year=recent", WantStatus: http.StatusBadRequest},
+		{Method: "GET", Path: "/api/v1/opinions/AO-2024-010", WantStatus: http.StatusOK},
+		{Method: "GET", Path: "/api/v1/opinions/EC-2024-017", WantStatus: http.StatusNotFound},
+	})
+
+	ts.Login("test@test.gov", "password")
+	const jsonType = "application/json"
+	run([]apiCall{
+		{Method: "GET", Path: "/api/v1/cases", WantStatus: http.StatusOK},
+		{Method: "GET", Path: "/api/v1/cases?type=AO&status=submitted&limit=1", WantStatus: http.StatusOK},
+		{Method: "GET", Path: "/api/v1/cases?statute=281A.400", WantStatus: http.StatusOK},
+		{Method: "GET", Path: "/api/v1/cases?type=XX", WantStatus: http.StatusBadRequest},
+		{Method: "GET", Path: "/api/v1/cases?limit=0", WantStatus: http.StatusBadRequest},
+		{Method: "GET", Path: "/api/v1/cases?cursor=not-a-cursor", WantStatus: http.StatusBadRequest},
+		{Method: "GET", Path: "/api/v1/cases/1", WantStatus: http.StatusOK},
+		{Method: "GET", Path: "/api/v1/cases/missing", WantStatus: http.StatusNotFound},
+		{Method: "PUT", Path: "/api/v1/cases/1/status", ContentType: jsonType, Body: `{"status":"under_review"}`, WantStatus: http.StatusOK},
+		{Method: "PUT", Path: "/api/v1/cases/1/status", ContentType: jsonType, Body: `{"status":"archived"}`, WantStatus: http.StatusUnprocessableEntity},
+		{Method: "PUT", Path: "/api/v1/cases/1/status", ContentType: jsonType, Body: `{"status":"published"}`, WantStatus: http.StatusConflict},
+		{Method: "PUT", Path: "/api/v1/cases/1/status", ContentType: jsonType, Body: `{"state":"closed"}`, WantStatus: http.StatusBadRequest},
+		{Method: "PUT", Path: "/api/v1/cases/1/status", ContentType: "application/x-www-form-urlencoded", Body: "status=closed", WantStatus: http.StatusUnsupportedMediaType},
+		{Method: "PUT", Path: "/api/v1/cases/missing/status", ContentType: jsonType, Body: `{"status":"closed"}`, WantStatus: http.StatusNotFound},
+		{Method: "PUT", Path: "/api/v1/cases/2/assignee", ContentType: jsonType, Body: `{"user_id":"user_1"}`, WantStatus: http.StatusOK},
+		{Method: "PUT", Path: "/api/v1/cases/2/assignee", ContentType: jsonType, Body: `{"user_id":""}`, WantStatus: http.StatusUnprocessableEntity},
+		{Method: "PUT", Path: "/api/v1/cases/missing/assignee", ContentType: jsonType, Body: `{"user_id":"user_1"}`, WantStatus: http.StatusNotFound},
+		{Method: "GET", Path: "/api/v1/deadlines", WantStatus: http.StatusOK},
+		{Method: "GET", Path: "/api/v1/deadlines?status=overdue&limit=2", WantStatus: http.StatusOK},
+		{Method: "GET", Path: "/api/v1/deadlines?status=late", WantStatus: http.StatusBadRequest},
+		{Method: "GET", Path: "/api/v1/stats", WantStatus: http.StatusOK},
+	})
+
+	// Publishing needs a redacted final document on the draft-prepared case
+	original := uploadDocument(t, ts, "8", testutil.UploadFile{
+		Field:       "document",
+		Filename:    "EC-2024-017-order.txt",
+		ContentType: "text/plain",
+		Content:     []byte("FINAL ORDER\nComplainant Jane Roe alleged a violation of NRS 281A.400."),
+	})
+	redacted := redactDocument(t, ts, original.ID, "Jane Roe")
+	publish := `{"title":"Final Order: Misuse of City Staff","summary":"Violation found.","statutes":["NRS 281A.400(7)"],"document_id":"` + redacted.ID + `","redaction_reviewed":true}`
+	run([]apiCall{
+		{Method: "POST", Path: "/api/v1/cases/8/publish", ContentType: jsonType, Body: `{"title":"Untitled","document_id":"` + original.ID + `"}`, WantStatus: http.StatusUnprocessableEntity},
+		{Method: "POST", Path: "/api/v1/cases/missing/publish", ContentType: jsonType, Body: publish, WantStatus: http.StatusNotFound},
+		{Method: "POST", Path: "/api/v1/cases/8/publish", ContentType: jsonType, Body: publish, WantStatus: http.StatusOK},
+	})
+
+	// API tokens without the scope are refused
+	token := mintToken(t, ts, "contract", domain.ScopeCasesRead)
+	resp := ts.RequestWithToken("PUT", "/api/v1/cases/1/status", token, jsonType, `{"status":"closed"}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("token without cases:write: expected 403, got %d", resp.StatusCode)
+	}
+	spec.Validate("PUT", "/api/v1/cases/1/status", resp)
+
+	if missing := spec.Uncovered(); len(missing) > 0 {
+		t.Errorf("operations not exercised by the contract test: %v", missing)
+	}
+}
+
+// apiPage is the list envelope returned by paginated endpoints.
+type apiPage struct {
+	Data []struct {
+		ID          string `json:"id"`
+		CaseNumber  string `json:"case_number"`
+		SubmittedAt string `json:"submitted_at"`
+	} `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// apiErrorBody is the error envelope returned by every API error.
+type apiErrorBody struct {
+	Error struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	} `json:"error"`
+}
+
+func decodeJSON(t *testing.T, resp *testutil.Response, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(resp.Body), v); err != nil {
+		t.Fatalf("invalid JSON (%d): %v\n%s", resp.StatusCode, err, resp.Body)
+	}
+}
+
+// TestAPICursorPagination walks the case list page by page.
+func TestAPICursorPagination(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	ts.Login("test@test.gov", "password")
+
+	want := len(ts.Repos.Case.List("", "", ""))
+	seen := map[string]bool{}
+	var previous string
+	path := "/api/v1/cases?limit=3"
+	for pages := 0; ; pages++ {
+		if pages > want {
+			t.Fatal("pagination did not terminate")
+		}
+		resp := ts.GET(path)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d: %s", path, resp.StatusCode, resp.Body)
+		}
+		var page apiPage
+		decodeJSON(t, resp, &page)
+		if len(page.Data) > 3 {
+			t.Errorf("page has %d items, limit was 3", len(page.Data))
+		}
+		for _, c := range page.Data {
+			if seen[c.ID] {
+				t.Errorf("case %s returned twice", c.ID)
+			}
+			seen[c.ID] = true
+			if previous != "" && c.SubmittedAt > previous {
+				t.Errorf("cases not newest first: %s after %s", c.SubmittedAt, previous)
+			}
+			previous = c.SubmittedAt
+		}
+
+		if pages == 0 {
+			// A case submitted mid-walk sorts before the cursor and must not shift later pages
+			ts.Repos.Case.Create(&domain.Case{ID: "api_new", CaseNumber: "AO-2099-001", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusSubmitted, SubmittedAt: time.Now()})
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		path = "/api/v1/cases?limit=3&cursor=" + url.QueryEscape(*page.NextCursor)
+	}
+
+	if len(seen) != want {
+		t.Errorf("walked %d cases, expected %d", len(seen), want)
+	}
+	if seen["api_new"] {
+		t.Error("case added after the first page should not appear on later pages")
+	}
+}
+
+// TestAPIErrorEnvelope verifies errors carry a code, a message and the request ID.
+func TestAPIErrorEnvelope(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	t.Run("GeneratedRequestID", func(t *testing.T) {
+		resp := ts.GET("/api/v1/cases")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", resp.StatusCode)
+		}
+		var body apiErrorBody
+		decodeJSON(t, resp, &body)
+		if body.Error.Code != "unauthorized" || body.Error.Message == "" {
+			t.Errorf("unexpected error: %+v", body.Error)
+		}
+		if id := resp.Header.Get("X-Request-Id"); id == "" || body.Error.RequestID != id {
+			t.Errorf("request_id %q should match X-Request-Id %q", body.Error.RequestID, id)
+		}
+	})
+
+	t.Run("PropagatedRequestID", func(t *testing.T) {
+		resp := ts.GETWithHeaders("/api/v1/opinions/XX-0000-000", map[string]string{"X-Request-Id": "caller-trace-42"})
+		var body apiErrorBody
+		decodeJSON(t, resp, &body)
+		if resp.StatusCode != http.StatusNotFound || body.Error.Code != "not_found" {
+			t.Errorf("expected not_found 404, got %d %+v", resp.StatusCode, body.Error)
+		}
+		if body.Error.RequestID != "caller-trace-42" {
+			t.Errorf("request_id: expected caller-trace-42, got %q", body.Error.RequestID)
+		}
+	})
+
+	t.Run("UnknownEndpoint", func(t *testing.T) {
+		resp := ts.GET("/api/v1/widgets")
+		var body apiErrorBody
+		decodeJSON(t, resp, &body)
+		if resp.StatusCode != http.StatusNotFound || body.Error.Code != "not_found" {
+			t.Errorf("expected not_found 404, got %d %+v", resp.StatusCode, body.Error)
+		}
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		resp := ts.Request("DELETE", "/api/v1/opinions/AO-2024-010", "", "")
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET" {
+			t.Errorf("expected 405 with Allow: GET, got %d %q", resp.StatusCode, resp.Header.Get("Allow"))
+		}
+	})
+}
+
+// TestAPIUpdateStatus verifies status changes through the API reach the repository.
+func TestAPIUpdateStatus(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	ts.Login("test@test.gov", "password")
+
+	resp := ts.Request("PUT", "/api/v1/cases/2/status", "application/json", `{"status":"investigation"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	var body struct {
+		Data struct {
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	decodeJSON(t, resp, &body)
+	if body.Data.Status != "investigation" {
+		t.Errorf("response status: %s", body.Data.Status)
+	}
+	if got := ts.Repos.Case.GetByID("2").Status; got != domain.StatusInvestigation {
+		t.Errorf("repository status: expected investigation, got %s", got)
+	}
+
+	// Cases only reach published through the publish workflow
+	resp = ts.Request("PUT", "/api/v1/cases/8/status", "application/json", `{"status":"published"}`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409, got %d", resp.StatusCode)
+	}
+	if got := ts.Repos.Case.GetByID("8").Status; got != domain.StatusDraftPrepared {
+		t.Errorf("case 8 should be unchanged, got %s", got)
+	}
+}
+
+// mintToken creates an API token from the Settings page and returns its secret.
+// The caller must be logged in.
+func mintToken(t *testing.T, ts *testutil.TestServer, name string, scopes ...domain.TokenScope) string {
+	t.Helper()
+	form := url.Values{"name": {name}, "expires_days": {"30"}}
+	for _, s := range scopes {
+		form.Add("scopes", string(s))
+	}
+	resp := ts.POST("/staff/settings/tokens", form)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create token: expected 200, got %d", resp.StatusCode)
+	}
+	secret := testutil.ParseDOM(t, resp.Body).TextByID("new-token-secret")
+	if secret == "" {
+		t.Fatal("create token: secret not shown")
+	}
+	return secret
+}
+
+// TestAPITokens covers minting, using, scoping and revoking personal API tokens.
+func TestAPITokens(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	ts.Login("test@test.gov", "password")
+
+	readToken := mintToken(t, ts, "Reporting script", domain.ScopeCasesRead)
+	writeToken := mintToken(t, ts, "Intake sync", domain.ScopeCasesRead, domain.ScopeCasesWrite)
+
+	t.Run("StoredHashed", func(t *testing.T) {
+		if !strings.HasPrefix(readToken, "ncoe_pat_") {
+			t.Errorf("unexpected token format: %s", readToken)
+		}
+		tokens := ts.Repos.APIToken.ListByUser("demo_user")
+		if len(tokens) != 2 {
+			t.Fatalf("expected 2 tokens, got %d", len(tokens))
+		}
+		for _, tok := range tokens {
+			if tok.Hash == readToken || tok.Hash == writeToken {
+				t.Error("token secret stored in plain text")
+			}
+			if len(tok.Hash) != 64 {
+				t.Errorf("expected a hex SHA-256 hash, got %q", tok.Hash)
+			}
+		}
+
+		// The settings page lists tokens by prefix but never shows the secret again
+		dom := testutil.ParseDOM(t, ts.GET("/staff/settings").Body)
+		dom.AssertHasElementByID("token-list")
+		dom.AssertContainsText("Reporting script")
+		dom.AssertNotContainsText(readToken)
+		dom.AssertContainsText(readToken[:15])
+	})
+
+	t.Run("AuthenticatesWithoutSession", func(t *testing.T) {
+		ts.ClearCookies()
+		defer ts.Login("test@test.gov", "password")
+
+		resp := ts.RequestWithToken("GET", "/api/v1/cases?limit=1", readToken, "", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		var used *domain.APIToken
+		for _, tok := range ts.Repos.APIToken.ListByUser("demo_user") {
+			if tok.Name == "Reporting script" {
+				used = &tok
+			}
+		}
+		if used == nil || used.LastUsedAt == nil || used.LastUsedIP == "" {
+			t.Errorf("last use not recorded: %+v", used)
+		}
+
+		// Tokens are for the API only
+		if resp := ts.RequestWithToken("GET", "/staff/dashboard", readToken, "", ""); resp.StatusCode != http.StatusSeeOther {
+			t.Errorf("staff pages should not accept tokens, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("EnforcesScopes", func(t *testing.T) {
+		body := `{"status":"under_review"}`
+		resp := ts.RequestWithToken("PUT", "/api/v1/cases/3/status", readToken, "application/json", body)
+		var apiErr apiErrorBody
+		decodeJSON(t, resp, &apiErr)
+		if resp.StatusCode != http.StatusForbidden || apiErr.Error.Code != "insufficient_scope" {
+			t.Errorf("read token: expected 403 insufficient_scope, got %d %+v", resp.StatusCode, apiErr.Error)
+		}
+		if !strings.Contains(resp.Header.Get("WWW-Authenticate"), `scope="cases:write"`) {
+			t.Errorf("WWW-Authenticate should name the missing scope: %q", resp.Header.Get("WWW-Authenticate"))
+		}
+
+		resp = ts.RequestWithToken("PUT", "/api/v1/cases/3/status", writeToken, "application/json", body)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("write token: expected 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+	})
+
+	t.Run("RejectsInvalidTokens", func(t *testing.T) {
+		for _, secret := range []string{"ncoe_pat_not-a-real-token", "abc", readToken + "x"} {
+			resp := ts.RequestWithToken("GET", "/api/v1/cases", secret, "", "")
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("%q: expected 401, got %d", secret, resp.StatusCode)
+			}
+			if !strings.Contains(resp.Header.Get("WWW-Authenticate"), "invalid_token") {
+				t.Errorf("%q: WWW-Authenticate should report invalid_token", secret)
+			}
+		}
+	})
+
+	t.Run("ValidatesCreation", func(t *testing.T) {
+		cases := map[string]url.Values{
+			"NoScopes":     {"name": {"x"}, "expires_days": {"30"}},
+			"NoName":       {"scopes": {"cases:read"}, "expires_days": {"30"}},
+			"BadExpiry":    {"name": {"x"}, "scopes": {"cases:read"}, "expires_days": {"9999"}},
+			"UnknownScope": {"name": {"x"}, "scopes": {"cases:delete"}, "expires_days": {"30"}},
+		}
+		for name, form := range cases {
+			resp := ts.POST("/staff/settings/tokens", form)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", name, resp.StatusCode)
+			}
+			testutil.ParseDOM(t, resp.Body).AssertHasElementByID("token-error")
+		}
+		if n := len(ts.Repos.APIToken.ListByUser("demo_user")); n != 2 {
+			t.Errorf("invalid requests should not create tokens, have %d", n)
+		}
+	})
+
+	t.Run("ExpiredTokenRejected", func(t *testing.T) {
+		secret := mintToken(t, ts, "Short lived", domain.ScopeCasesRead)
+		for _, tok := range ts.Repos.APIToken.ListByUser("demo_user") {
+			if tok.Name == "Short lived" {
+				tok.ExpiresAt = time.Now().Add(-time.Minute)
+				ts.Repos.APIToken.Update(&tok)
+			}
+		}
+		resp := ts.RequestWithToken("GET", "/api/v1/cases", secret, "", "")
+		var apiErr apiErrorBody
+		decodeJSON(t, resp, &apiErr)
+		if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(apiErr.Error.Message, "expired") {
+			t.Errorf("expected 401 expired, got %d %+v", resp.StatusCode, apiErr.Error)
+		}
+	})
+
+	t.Run("Revocation", func(t *testing.T) {
+		var id string
+		for _, tok := range ts.Repos.APIToken.ListByUser("demo_user") {
+			if tok.Name == "Reporting script" {
+				id = tok.ID
+			}
+		}
+		resp := ts.POST("/staff/settings/tokens/"+id+"/revoke", url.Values{})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("revoke: expected 303, got %d", resp.StatusCode)
+		}
+		if tok := ts.Repos.APIToken.GetByID(id); tok.RevokedAt == nil {
+			t.Error("token not marked revoked")
+		}
+
+		resp = ts.RequestWithToken("GET", "/api/v1/cases", readToken, "", "")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("revoked token: expected 401, got %d", resp.StatusCode)
+		}
+		if resp := ts.RequestWithToken("GET", "/api/v1/cases", writeToken, "", ""); resp.StatusCode != http.StatusOK {
+			t.Errorf("other tokens should keep working, got %d", resp.StatusCode)
+		}
+
+		if resp := ts.POST("/staff/settings/tokens/tok_missing/revoke", url.Values{}); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("unknown token: expected 404, got %d", resp.StatusCode)
+		}
+	})
+}
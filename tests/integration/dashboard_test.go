@@ -0,0 +1,161 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/testutil"
+)
+
+func TestFiscalYear(t *testing.T) {
+	cases := []struct {
+		date time.Time
+		want int
+	}{
+		{time.Date(2025, time.June, 30, 23, 59, 0, 0, domain.Location), 2025},
+		{time.Date(2025, time.July, 1, 0, 0, 0, 0, domain.Location), 2026},
+		{time.Date(2026, time.January, 1, 0, 0, 0, 0, domain.Location), 2026},
+		// Still June 30 in Nevada
+		{time.Date(2025, time.July, 1, 5, 0, 0, 0, time.UTC), 2025},
+	}
+	for _, c := range cases {
+		if got := domain.FiscalYear(c.date); got != c.want {
+			t.Errorf("FiscalYear(%s) = %d, want %d", c.date.Format("2006-01-02"), got, c.want)
+		}
+	}
+	if got := domain.FiscalYearStart(2026, time.UTC); !got.Equal(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("FiscalYearStart(2026) = %s", got)
+	}
+}
+
+func TestDashboardStats(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	ts.Login("demo@ncoe.nv.gov", "password")
+
+	// dashboardCount reads a number from a dashboard tile
+	dashboardCount := func(id string) int {
+		t.Helper()
+		dom := testutil.ParseDOM(t, ts.GET("/staff/dashboard").Body)
+		text := strings.Fields(dom.TextByID(id))
+		if len(text) == 0 {
+			t.Fatalf("dashboard has no #%s", id)
+		}
+		n, err := strconv.Atoi(text[0])
+		if err != nil {
+			t.Fatalf("#%s = %q, want a number", id, dom.TextByID(id))
+		}
+		return n
+	}
+
+	t.Run("CountsComeFromCases", func(t *testing.T) {
+		open := 0
+		for _, c := range ts.Repos.Case.List("", "", "") {
+			if c.ResolvedAt() == nil {
+				open++
+			}
+		}
+		if got := dashboardCount("total-open"); got != open {
+			t.Errorf("open cases = %d, want %d (the cases in the repository)", got, open)
+		}
+	})
+
+	t.Run("YearToDateAndFiscalYear", func(t *testing.T) {
+		now := time.Now().In(domain.Location)
+		yearStart := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, domain.Location)
+		fiscalStart := domain.FiscalYearStart(domain.FiscalYear(now), domain.Location)
+		beforeYTD, beforeFY := dashboardCount("closed-ytd"), dashboardCount("closed-fiscal-year")
+
+		at := func(t time.Time) *time.Time { return &t }
+		resolved := []*domain.Case{
+			// Published just after New Year: this calendar year
+			{ID: "ytd1", CaseNumber: "AO-YTD-1", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusPublished, SubmittedAt: yearStart.AddDate(-1, 0, 0), PublishedAt: at(yearStart.Add(time.Hour))},
+			// Withdrawn the day before New Year: last calendar year
+			{ID: "ytd2", CaseNumber: "EC-YTD-2", Type: domain.CaseTypeEthicsComplaint, Status: domain.StatusWithdrawn, SubmittedAt: yearStart.AddDate(-1, 0, 0), ClosedAt: at(yearStart.Add(-24 * time.Hour))},
+			// Closed just before the fiscal year began
+			{ID: "ytd3", CaseNumber: "PRR-YTD-3", Type: domain.CaseTypePublicRecordsRequest, Status: domain.StatusClosed, SubmittedAt: fiscalStart.AddDate(0, -1, 0), ClosedAt: at(fiscalStart.Add(-time.Hour))},
+		}
+		wantYTD, wantFY := beforeYTD, beforeFY
+		for _, c := range resolved {
+			ts.Repos.Case.Create(c)
+			at := *c.ResolvedAt()
+			if !at.Before(yearStart) {
+				wantYTD++
+			}
+			if !at.Before(fiscalStart) {
+				wantFY++
+			}
+		}
+
+		if got := dashboardCount("closed-ytd"); got != wantYTD {
+			t.Errorf("closed YTD = %d, want %d", got, wantYTD)
+		}
+		if got := dashboardCount("closed-fiscal-year"); got != wantFY {
+			t.Errorf("closed this fiscal year = %d, want %d", got, wantFY)
+		}
+		fy := "FY" + strconv.Itoa(domain.FiscalYear(now))
+		if text := testutil.ParseDOM(t, ts.GET("/staff/dashboard").Body).TextByID("closed-fiscal-year"); !strings.Contains(text, fy) {
+			t.Errorf("fiscal year tile = %q, want %s", text, fy)
+		}
+	})
+
+	t.Run("AgencyZoneOnUTCHost", func(t *testing.T) {
+		local := time.Local
+		time.Local = time.UTC
+		defer func() { time.Local = local }()
+
+		now := time.Now().In(domain.Location)
+		yearStart := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, domain.Location)
+		fiscalStart := domain.FiscalYearStart(domain.FiscalYear(now), domain.Location)
+		beforeYTD, beforeFY := dashboardCount("closed-ytd"), dashboardCount("closed-fiscal-year")
+
+		// New Year's Eve and June 30 evenings in Nevada, already the next
+		// day in UTC
+		at := func(t time.Time) *time.Time { return &t }
+		ts.Repos.Case.Create(&domain.Case{ID: "tz1", CaseNumber: "AO-TZ-1", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusClosed, SubmittedAt: yearStart.AddDate(-1, 0, 0), ClosedAt: at(yearStart.Add(-3 * time.Hour))})
+		ts.Repos.Case.Create(&domain.Case{ID: "tz2", CaseNumber: "AO-TZ-2", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusClosed, SubmittedAt: fiscalStart.AddDate(-1, 0, 0), ClosedAt: at(fiscalStart.Add(-3 * time.Hour))})
+
+		wantYTD, wantFY := beforeYTD, beforeFY
+		if fiscalStart.Add(-3 * time.Hour).After(yearStart) {
+			wantYTD++ // June 30 of this calendar year
+		}
+		if got := dashboardCount("closed-ytd"); got != wantYTD {
+			t.Errorf("closed YTD = %d, want %d", got, wantYTD)
+		}
+		if got := dashboardCount("closed-fiscal-year"); got != wantFY {
+			t.Errorf("closed this fiscal year = %d, want %d", got, wantFY)
+		}
+	})
+
+	t.Run("RecentActivity", func(t *testing.T) {
+		if err := ts.Cases.UpdateStatus(context.Background(), ts.Repos.User.GetByID("user_1"), "3", domain.StatusUnderReview); err != nil {
+			t.Fatal(err)
+		}
+		resp := ts.GET("/staff/dashboard")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		items := testutil.ParseDOM(t, resp.Body).ListItemsByID("recent-activity")
+		if len(items) == 0 || !strings.Contains(items[0], "PRR-2024-089") || !strings.Contains(items[0], "Status changed to under review") {
+			t.Fatalf("latest activity = %q, want the status change on PRR-2024-089", items)
+		}
+		if !strings.Contains(items[0], "by Demo Admin") {
+			t.Errorf("latest activity = %q, want who changed the status", items[0])
+		}
+
+		// The case's own timeline shows it too, newest first
+		activity := ts.Repos.Case.GetActivity("3")
+		if len(activity) < 2 || activity[0].Action != "status_changed" || activity[len(activity)-1].Action != "created" {
+			t.Errorf("case timeline = %+v, want the status change after creation", activity)
+		}
+		if len(activity) > 0 && activity[0].UserID != "user_1" {
+			t.Errorf("status change recorded for user %q, want user_1", activity[0].UserID)
+		}
+		testutil.ParseDOM(t, ts.GET("/staff/cases/3").Body).AssertContainsText("Status changed to under review")
+	})
+}
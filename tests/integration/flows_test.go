@@ -1,6 +1,8 @@
 package integration
 
 import (
+	"encoding/xml"
+	"io"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -82,6 +84,15 @@ func TestPublicSubmissionFlows(t *testing.T) {
 		if newCase.SubjectAgency != "Test County" {
 			t.Errorf("subject agency: expected Test County, got %s", newCase.SubjectAgency)
 		}
+
+		// Provisions are parsed into normalized citations
+		var citations []string
+		for _, c := range newCase.Citations {
+			citations = append(citations, c.String())
+		}
+		if strings.Join(citations, ", ") != "NRS 281A.400(1), NRS 281A.420" {
+			t.Errorf("citations: expected NRS 281A.400(1), NRS 281A.420, got %v", citations)
+		}
 	})
 
 	t.Run("Acknowledgment", func(t *testing.T) {
@@ -269,6 +280,29 @@ func TestStaffCaseWorkflow(t *testing.T) {
 			t.Errorf("expected HX-Trigger=caseUpdated, got %s", trigger)
 		}
 	})
+
+	t.Run("StatusUpdateRejectsUnknownStatus", func(t *testing.T) {
+		resp := ts.POST("/staff/cases/1/_status", url.Values{"status": {"archived"}})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("StatusUpdateRequiresCaseRole", func(t *testing.T) {
+		for _, role := range []domain.Role{domain.RoleReadOnly, domain.RoleAuditor} {
+			user := &domain.User{ID: "user_" + string(role), Email: string(role) + "@ncoe.nv.gov", FirstName: "Rita", LastName: "Reader", Role: role, IsActive: true}
+			ts.Repos.User.Create(user)
+			ts.ClearCookies()
+			ts.Login(user.Email, "password")
+			before := ts.Repos.Case.GetByID("2").Status
+			if resp := ts.POST("/staff/cases/2/_status", url.Values{"status": {"closed"}}); resp.StatusCode != http.StatusForbidden {
+				t.Errorf("%s: expected 403, got %d", role, resp.StatusCode)
+			}
+			if got := ts.Repos.Case.GetByID("2").Status; got != before {
+				t.Errorf("%s changed the status to %s", role, got)
+			}
+		}
+	})
 }
 
 // TestCaseNumberFormat verifies case numbers follow the correct format.
@@ -336,6 +370,578 @@ func TestPublicSearchFlow(t *testing.T) {
 		dom := testutil.ParseDOM(t, resp.Body)
 		dom.AssertContainsText("AO-2024-010")
 	})
+
+	t.Run("UnknownOpinionReturns404", func(t *testing.T) {
+		for _, path := range []string{"/opinions/anything", "/opinions/EC-2024-017", "/opinions/anything/document"} {
+			if resp := ts.GET(path); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("%s: expected 404, got %d", path, resp.StatusCode)
+			}
+		}
+	})
+}
+
+// TestOpinionPublishFlow verifies a draft-prepared case can be published and becomes public.
+func TestOpinionPublishFlow(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	ts.Login("test@test.gov", "password")
+
+	// Case 8 (EC-2024-017) is seeded in draft_prepared
+	original := uploadDocument(t, ts, "8", testutil.UploadFile{
+		Field:       "document",
+		Filename:    "EC-2024-017-order.txt",
+		ContentType: "text/plain",
+		Content:     []byte("FINAL ORDER\nComplainant Jane Roe alleged a violation of NRS 281A.400."),
+	})
+	redacted := redactDocument(t, ts, original.ID, "Jane Roe")
+
+	publishForm := func(documentID string) url.Values {
+		form := testutil.PublishForm()
+		form.Set("document_id", documentID)
+		return form
+	}
+
+	t.Run("PublishFormRenders", func(t *testing.T) {
+		resp := ts.GET("/staff/cases/8/publish")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		dom := testutil.ParseDOM(t, resp.Body)
+		dom.AssertFullPage()
+		dom.AssertFormHasInputs("/staff/cases/8/publish", "title", "summary", "topics", "statutes", "document_id", "redaction_reviewed")
+		if !dom.ContainsText(redacted.Filename) {
+			t.Errorf("publish form should offer redacted copy %s", redacted.Filename)
+		}
+	})
+
+	t.Run("RequiresRedactionReview", func(t *testing.T) {
+		form := publishForm(redacted.ID)
+		form.Del("redaction_reviewed")
+		resp := ts.POST("/staff/cases/8/publish", form)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+		if ts.Repos.Opinion.GetByCaseNumber("EC-2024-017") != nil {
+			t.Error("opinion should not be published without redaction review")
+		}
+	})
+
+	t.Run("RequiresFinalDocument", func(t *testing.T) {
+		resp := ts.POST("/staff/cases/8/publish", testutil.PublishForm())
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("RejectsUnredactedOriginal", func(t *testing.T) {
+		resp := ts.POST("/staff/cases/8/publish", publishForm(original.ID))
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+		if ts.Repos.Case.GetDocument(original.ID).IsPublic {
+			t.Error("original document must never become public")
+		}
+	})
+
+	t.Run("RejectsUnrecognizedStatute", func(t *testing.T) {
+		form := publishForm(redacted.ID)
+		form.Set("statutes", "NRS 281A.400, the ethics law")
+		resp := ts.POST("/staff/cases/8/publish", form)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertContainsText("unrecognized statute citation")
+	})
+
+	t.Run("RejectsCaseNotInDraftPrepared", func(t *testing.T) {
+		// Case 1 (AO-2024-042) is still submitted
+		resp := ts.POST("/staff/cases/1/publish", publishForm(redacted.ID))
+		if resp.StatusCode != http.StatusConflict {
+			t.Fatalf("expected 409, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("StatusUpdateCannotPublish", func(t *testing.T) {
+		resp := ts.POST("/staff/cases/8/_status", url.Values{"status": {"published"}})
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("status update to published: expected 409, got %d", resp.StatusCode)
+		}
+		if c := ts.Repos.Case.GetByID("8"); c.Status != domain.StatusDraftPrepared {
+			t.Errorf("status changed to %s", c.Status)
+		}
+	})
+
+	t.Run("PublishMakesOpinionPublic", func(t *testing.T) {
+		resp := ts.POST("/staff/cases/8/publish", publishForm(redacted.ID))
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected 303, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		if loc := resp.Header.Get("Location"); loc != "/opinions/EC-2024-017" {
+			t.Errorf("expected redirect to public opinion, got %s", loc)
+		}
+
+		c := ts.Repos.Case.GetByID("8")
+		if c.Status != domain.StatusPublished || !c.IsPublic || c.PublishedAt == nil {
+			t.Errorf("case not marked published: status=%s public=%v publishedAt=%v", c.Status, c.IsPublic, c.PublishedAt)
+		}
+
+		opinion := ts.Repos.Opinion.GetByCaseNumber("EC-2024-017")
+		if opinion == nil {
+			t.Fatal("opinion not stored")
+		}
+		if opinion.RedactionReviewedBy == "" {
+			t.Error("redaction reviewer not recorded")
+		}
+		if opinion.DocumentID != redacted.ID {
+			t.Errorf("opinion document: expected redacted copy %s, got %s", redacted.ID, opinion.DocumentID)
+		}
+
+		view := ts.GET("/opinions/EC-2024-017")
+		if view.StatusCode != http.StatusOK {
+			t.Fatalf("public opinion: expected 200, got %d", view.StatusCode)
+		}
+		dom := testutil.ParseDOM(t, view.Body)
+		dom.AssertContainsText("Final Order: Misuse of City Staff")
+
+		download := ts.GET(opinion.DocumentURL)
+		if download.StatusCode != http.StatusOK {
+			t.Fatalf("document: expected 200, got %d", download.StatusCode)
+		}
+		if download.Body != string(redacted.Content) {
+			t.Errorf("document content mismatch: %q", download.Body)
+		}
+		if strings.Contains(download.Body, "Jane Roe") {
+			t.Error("published document contains redacted name")
+		}
+
+		search := ts.GET("/search?q=city+staff")
+		testutil.ParseDOM(t, search.Body).AssertContainsText("EC-2024-017")
+
+		if got := strings.Join(opinion.Statutes, ", "); got != "NRS 281A.400(2), NRS 281A.400(7)" {
+			t.Errorf("statutes not normalized: %s", got)
+		}
+		statutePage := ts.GET("/statutes/281A.400")
+		testutil.ParseDOM(t, statutePage.Body).AssertContainsText("EC-2024-017")
+	})
+
+	t.Run("CannotPublishTwice", func(t *testing.T) {
+		resp := ts.POST("/staff/cases/8/publish", publishForm(redacted.ID))
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("expected 409, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("StatusUpdateCannotUnpublish", func(t *testing.T) {
+		resp := ts.POST("/staff/cases/8/_status", url.Values{"status": {"investigation"}})
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("status update from published: expected 409, got %d", resp.StatusCode)
+		}
+		if c := ts.Repos.Case.GetByID("8"); c.Status != domain.StatusPublished || !c.IsPublic {
+			t.Errorf("published case changed: status=%s public=%v", c.Status, c.IsPublic)
+		}
+		if ts.Repos.Opinion.GetByCaseNumber("EC-2024-017") == nil {
+			t.Error("opinion withdrawn by a status update")
+		}
+	})
+}
+
+// TestDocumentRedactionFlow verifies that redaction produces a new derivative,
+// leaves the original untouched, and records the redaction log.
+func TestDocumentRedactionFlow(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	ts.Login("test@test.gov", "password")
+
+	content := []byte("Records request from Jane Roe, 12 Elm St, Reno.\nContact JANE ROE at 775-555-0100.")
+	original := uploadDocument(t, ts, "4", testutil.UploadFile{
+		Field:       "document",
+		Filename:    "response.txt",
+		ContentType: "text/plain",
+		Content:     content,
+	})
+
+	t.Run("UploadShowsOnCase", func(t *testing.T) {
+		resp := ts.GET("/staff/cases/4")
+		dom := testutil.ParseDOM(t, resp.Body)
+		dom.AssertContainsText("response.txt")
+	})
+
+	t.Run("DocumentPageRenders", func(t *testing.T) {
+		resp := ts.GET("/staff/documents/" + original.ID)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		dom := testutil.ParseDOM(t, resp.Body)
+		dom.AssertFullPage()
+		dom.AssertFormHasInputs("/staff/documents/"+original.ID+"/redactions", "phrase", "reason")
+	})
+
+	t.Run("RejectsUnknownPhrase", func(t *testing.T) {
+		resp := ts.POST("/staff/documents/"+original.ID+"/redactions", url.Values{
+			"phrase": {"John Doe"},
+			"reason": {string(domain.ReasonRequesterIdentity)},
+		})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertHasElementByID("document-error")
+	})
+
+	t.Run("RejectsInvalidReason", func(t *testing.T) {
+		resp := ts.POST("/staff/documents/"+original.ID+"/redactions", url.Values{
+			"phrase": {"Jane Roe"},
+			"reason": {"because"},
+		})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("OriginalCannotBeReleased", func(t *testing.T) {
+		resp := ts.POST("/staff/documents/"+original.ID+"/release", url.Values{})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+		if ts.GET("/documents/"+original.ID).StatusCode != http.StatusNotFound {
+			t.Error("original document must not be publicly served")
+		}
+	})
+
+	var derived *domain.Document
+	t.Run("ApplyCreatesRedactedCopy", func(t *testing.T) {
+		derived = redactDocument(t, ts, original.ID, "Jane Roe")
+
+		if strings.Contains(strings.ToLower(string(derived.Content)), "jane roe") {
+			t.Errorf("redacted copy still contains the name: %q", derived.Content)
+		}
+		if !strings.Contains(string(derived.Content), "12 Elm St") {
+			t.Errorf("redacted copy lost unredacted text: %q", derived.Content)
+		}
+		if derived.DerivedFromID != original.ID || derived.Filename != "response-redacted.txt" {
+			t.Errorf("unexpected derivative: from=%s name=%s", derived.DerivedFromID, derived.Filename)
+		}
+		if derived.IsPublic {
+			t.Error("redacted copy should not be public until released")
+		}
+
+		if got := ts.Repos.Case.GetDocument(original.ID).Content; string(got) != string(content) {
+			t.Errorf("original modified: %q", got)
+		}
+
+		entry := ts.Repos.Redaction.GetLogByDerived(derived.ID)
+		if entry == nil {
+			t.Fatal("redaction log not recorded")
+		}
+		if entry.SourceDocumentID != original.ID || len(entry.Redactions) != 2 || entry.AppliedBy == "" {
+			t.Errorf("unexpected log entry: %+v", entry)
+		}
+		if entry.SourceSHA256 == entry.DerivedSHA256 || len(entry.DerivedSHA256) != 64 {
+			t.Errorf("log hashes not recorded: %s / %s", entry.SourceSHA256, entry.DerivedSHA256)
+		}
+
+		page := testutil.ParseDOM(t, ts.GET("/staff/documents/"+original.ID).Body)
+		page.AssertHasElementByID("redaction-log")
+	})
+
+	t.Run("CannotRedactRedactedCopy", func(t *testing.T) {
+		resp := ts.POST("/staff/documents/"+derived.ID+"/redactions", url.Values{
+			"phrase": {"Elm"},
+			"reason": {string(domain.ReasonPersonalInformation)},
+		})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("ReleaseServesRedactedCopy", func(t *testing.T) {
+		if ts.GET("/documents/"+derived.ID).StatusCode != http.StatusNotFound {
+			t.Error("unreleased copy should not be public")
+		}
+
+		resp := ts.POST("/staff/documents/"+derived.ID+"/release", url.Values{})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected 303, got %d: %s", resp.StatusCode, resp.Body)
+		}
+
+		public := ts.GET("/documents/" + derived.ID)
+		if public.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", public.StatusCode)
+		}
+		if public.Body != string(derived.Content) {
+			t.Errorf("public content mismatch: %q", public.Body)
+		}
+		// Only PDFs open in the browser; other uploads download, sandboxed
+		if cd := public.Header.Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") {
+			t.Errorf("Content-Disposition = %q, want attachment", cd)
+		}
+		if csp := public.Header.Get("Content-Security-Policy"); csp != "sandbox" {
+			t.Errorf("Content-Security-Policy = %q, want sandbox", csp)
+		}
+	})
+
+	t.Run("PhraseInMarkupRedactsNothing", func(t *testing.T) {
+		page := uploadDocument(t, ts, "4", testutil.UploadFile{
+			Field:       "document",
+			Filename:    "letter.html",
+			ContentType: "text/html",
+			Content:     []byte(`<p>Dear Jane Roe,</p><img alt="Signature of Jane Roe" src="sig.png">`),
+		})
+		resp := ts.POST("/staff/documents/"+page.ID+"/redactions", url.Values{
+			"phrase": {"Jane Roe"},
+			"reason": {string(domain.ReasonRequesterIdentity)},
+		})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+		if got := ts.Repos.Redaction.ListByDocument(page.ID); len(got) != 0 {
+			t.Errorf("phrase partly redacted: %+v", got)
+		}
+	})
+
+	t.Run("UnredactablePDFOffersNoRegions", func(t *testing.T) {
+		scan := uploadDocument(t, ts, "4", testutil.UploadFile{
+			Field:       "document",
+			Filename:    "scan.pdf",
+			ContentType: "application/pdf",
+			Content:     []byte("%PDF-1.5\n1 0 obj\n<< /Type /ObjStm /N 1 /First 4 /Length 0 >>\nstream\n\nendstream\nendobj\ntrailer\n<< /Root 2 0 R >>\n"),
+		})
+		dom := testutil.ParseDOM(t, ts.GET("/staff/documents/"+scan.ID).Body)
+		dom.AssertHasElementByID("unredactable")
+		if dom.FindByID("add-redaction") != nil || dom.FindByID("apply-button") != nil {
+			t.Error("redaction offered for a PDF that cannot be redacted")
+		}
+	})
+
+	t.Run("UploadedHTMLDoesNotRunAsSite", func(t *testing.T) {
+		page := uploadDocument(t, ts, "4", testutil.UploadFile{
+			Field:       "document",
+			Filename:    "notice.html",
+			ContentType: "text/html",
+			Content:     []byte("<script>fetch('/staff/users')</script>Notice to Jane Roe"),
+		})
+		released := redactDocument(t, ts, page.ID, "Jane Roe")
+		ts.POST("/staff/documents/"+released.ID+"/release", url.Values{})
+		resp := ts.GET("/documents/" + released.ID)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		if cd, csp := resp.Header.Get("Content-Disposition"), resp.Header.Get("Content-Security-Policy"); !strings.HasPrefix(cd, "attachment") || csp != "sandbox" {
+			t.Errorf("HTML served with Content-Disposition %q and Content-Security-Policy %q", cd, csp)
+		}
+	})
+}
+
+// TestStatuteCrossReference verifies the public NRS index and the staff statute filter.
+func TestStatuteCrossReference(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	t.Run("SectionListsInterpretingOpinions", func(t *testing.T) {
+		resp := ts.GET("/statutes/281A.420")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		dom := testutil.ParseDOM(t, resp.Body)
+		dom.AssertFullPage()
+		dom.AssertContainsText("AO-2024-010")
+		dom.AssertNotContainsText("EC-2024-005")
+	})
+
+	t.Run("UncitedSectionIsEmpty", func(t *testing.T) {
+		resp := ts.GET("/statutes/281A.550")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertContainsText("No published opinions interpret NRS 281A.550")
+	})
+
+	t.Run("NonCanonicalSectionRedirects", func(t *testing.T) {
+		resp := ts.GET("/statutes/281a.400")
+		if resp.StatusCode != http.StatusMovedPermanently {
+			t.Fatalf("expected 301, got %d", resp.StatusCode)
+		}
+		if loc := resp.Header.Get("Location"); loc != "/statutes/281A.400" {
+			t.Errorf("expected redirect to canonical section, got %s", loc)
+		}
+	})
+
+	t.Run("InvalidSectionReturns404", func(t *testing.T) {
+		for _, path := range []string{"/statutes/239.010", "/statutes/281A.400(2)", "/statutes/anything"} {
+			if resp := ts.GET(path); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("%s: expected 404, got %d", path, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("OpinionLinksToSections", func(t *testing.T) {
+		dom := testutil.ParseDOM(t, ts.GET("/opinions/EC-2024-005").Body)
+		if dom.FindByHref("/statutes/281A.480") == nil {
+			t.Error("opinion page should link to /statutes/281A.480")
+		}
+	})
+
+	t.Run("StaffCaseListFiltersByStatute", func(t *testing.T) {
+		ts.Login("test@test.gov", "password")
+		dom := testutil.ParseDOM(t, ts.GET("/staff/cases?statute=281A.420").Body)
+		dom.AssertContainsText("AO-2024-042") // NRS 281A.420(1)
+		dom.AssertContainsText("AO-2024-039") // NRS 281A.420
+		dom.AssertNotContainsText("EC-2024-018")
+		dom.AssertNotContainsText("PRR-2024-089")
+	})
+}
+
+// TestFeedsAndSitemap verifies the Atom feed filters, the sitemap, and conditional caching.
+func TestFeedsAndSitemap(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	type atomFeed struct {
+		Title   string `xml:"title"`
+		Entries []struct {
+			ID string `xml:"id"`
+		} `xml:"entry"`
+	}
+	parseFeed := func(t *testing.T, resp *testutil.Response) (atomFeed, []string) {
+		t.Helper()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+			t.Errorf("content type: %s", ct)
+		}
+		var f atomFeed
+		if err := xml.Unmarshal([]byte(resp.Body), &f); err != nil {
+			t.Fatalf("invalid feed XML: %v", err)
+		}
+		var cases []string
+		for _, e := range f.Entries {
+			cases = append(cases, e.ID[strings.LastIndex(e.ID, "/")+1:])
+		}
+		return f, cases
+	}
+
+	t.Run("FeedListsAllOpinions", func(t *testing.T) {
+		_, cases := parseFeed(t, ts.GET("/feeds/opinions.atom"))
+		if strings.Join(cases, ",") != "AO-2024-010,EC-2024-005" {
+			t.Errorf("unexpected entries: %v", cases)
+		}
+	})
+
+	t.Run("FeedFiltersByType", func(t *testing.T) {
+		f, cases := parseFeed(t, ts.GET("/feeds/opinions.atom?type=AO"))
+		if strings.Join(cases, ",") != "AO-2024-010" {
+			t.Errorf("unexpected entries: %v", cases)
+		}
+		if !strings.Contains(f.Title, "Advisory Opinions") {
+			t.Errorf("feed title should name the type: %s", f.Title)
+		}
+	})
+
+	t.Run("FeedFiltersByTopic", func(t *testing.T) {
+		_, cases := parseFeed(t, ts.GET("/feeds/opinions.atom?topic=Gifts"))
+		if strings.Join(cases, ",") != "EC-2024-005" {
+			t.Errorf("unexpected entries: %v", cases)
+		}
+	})
+
+	t.Run("SearchLinksToMatchingFeed", func(t *testing.T) {
+		dom := testutil.ParseDOM(t, ts.GET("/search?topic=Gifts").Body)
+		if dom.FindByHref("/feeds/opinions.atom?topic=Gifts") == nil {
+			t.Error("search page should link to the filtered feed")
+		}
+	})
+
+	t.Run("ConditionalRequests", func(t *testing.T) {
+		resp := ts.GET("/feeds/opinions.atom")
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag == "" || lastModified == "" {
+			t.Fatalf("missing caching headers: etag=%q last-modified=%q", etag, lastModified)
+		}
+
+		if r := ts.GETWithHeaders("/feeds/opinions.atom", map[string]string{"If-None-Match": etag}); r.StatusCode != http.StatusNotModified {
+			t.Errorf("If-None-Match: expected 304, got %d", r.StatusCode)
+		}
+		if r := ts.GETWithHeaders("/feeds/opinions.atom", map[string]string{"If-Modified-Since": lastModified}); r.StatusCode != http.StatusNotModified {
+			t.Errorf("If-Modified-Since: expected 304, got %d", r.StatusCode)
+		}
+
+		// A newly published opinion changes the validators
+		ts.Repos.Opinion.Create(&domain.PublishedOpinion{
+			ID:          "op_new",
+			CaseNumber:  "AO-2024-099",
+			Type:        domain.CaseTypeAdvisoryOpinion,
+			Title:       "New Opinion",
+			PublishedAt: time.Now(),
+			Year:        time.Now().Year(),
+		})
+		r := ts.GETWithHeaders("/feeds/opinions.atom", map[string]string{"If-None-Match": etag})
+		if r.StatusCode != http.StatusOK {
+			t.Fatalf("after publishing: expected 200, got %d", r.StatusCode)
+		}
+		if r.Header.Get("ETag") == etag {
+			t.Error("ETag should change when an opinion is published")
+		}
+		if _, cases := parseFeed(t, r); len(cases) == 0 || cases[0] != "AO-2024-099" {
+			t.Errorf("newest opinion should come first: %v", cases)
+		}
+	})
+
+	t.Run("SitemapCoversPublishedOpinions", func(t *testing.T) {
+		resp := ts.GET("/sitemap.xml")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("ETag") == "" || resp.Header.Get("Last-Modified") == "" {
+			t.Error("sitemap missing caching headers")
+		}
+		var set struct {
+			URLs []struct {
+				Loc string `xml:"loc"`
+			} `xml:"url"`
+		}
+		if err := xml.Unmarshal([]byte(resp.Body), &set); err != nil {
+			t.Fatalf("invalid sitemap XML: %v", err)
+		}
+		paths := map[string]bool{}
+		for _, u := range set.URLs {
+			paths[strings.TrimPrefix(u.Loc, ts.URL)] = true
+		}
+		for _, want := range []string{"/", "/opinions/AO-2024-010", "/opinions/EC-2024-005", "/statutes/281A.400"} {
+			if !paths[want] {
+				t.Errorf("sitemap missing %s", want)
+			}
+		}
+		if paths["/opinions/EC-2024-017"] {
+			t.Error("sitemap lists an unpublished case")
+		}
+	})
+
+	t.Run("RobotsPointsToSitemap", func(t *testing.T) {
+		resp := ts.GET("/robots.txt")
+		if !strings.Contains(resp.Body, "Sitemap: "+ts.URL+"/sitemap.xml") || !strings.Contains(resp.Body, "Disallow: /staff/") {
+			t.Errorf("unexpected robots.txt:\n%s", resp.Body)
+		}
+	})
+
+	t.Run("LinksIgnoreForgedHost", func(t *testing.T) {
+		for _, path := range []string{"/robots.txt", "/sitemap.xml", "/feeds/opinions.atom"} {
+			req, err := http.NewRequest("GET", ts.URL+path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Host = "evil.example"
+			req.Header.Set("X-Forwarded-Proto", "https")
+			resp, err := ts.Client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if strings.Contains(string(body), "evil.example") || !strings.Contains(string(body), ts.URL+"/") {
+				t.Errorf("GET %s with a forged Host links to:\n%s", path, body)
+			}
+		}
+	})
 }
 
 // TestEndToEndCaseWorkflow verifies the complete case lifecycle:
@@ -483,6 +1089,44 @@ func TestNavigationIntegrity(t *testing.T) {
 
 // --- Helper Functions ---
 
+// uploadDocument uploads a file to a case and returns the stored document.
+func uploadDocument(t *testing.T, ts *testutil.TestServer, caseID string, file testutil.UploadFile) *domain.Document {
+	t.Helper()
+	resp := ts.POSTMultipart("/staff/cases/"+caseID+"/documents", url.Values{"category": {"final"}}, file)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("upload: expected 303, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	for _, d := range ts.Repos.Case.GetDocuments(caseID) {
+		if d.Filename == file.Filename && !d.IsRedacted {
+			return d
+		}
+	}
+	t.Fatalf("uploaded document %s not found on case %s", file.Filename, caseID)
+	return nil
+}
+
+// redactDocument redacts every occurrence of phrase and returns the redacted copy.
+func redactDocument(t *testing.T, ts *testutil.TestServer, documentID, phrase string) *domain.Document {
+	t.Helper()
+	resp := ts.POST("/staff/documents/"+documentID+"/redactions", url.Values{
+		"phrase": {phrase},
+		"reason": {string(domain.ReasonComplainantIdentity)},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("add redaction: expected 303, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	resp = ts.POST("/staff/documents/"+documentID+"/apply", url.Values{})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("apply: expected 303, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	derived := ts.Repos.Case.GetDocument(strings.TrimPrefix(resp.Header.Get("Location"), "/staff/documents/"))
+	if derived == nil || !derived.IsRedacted {
+		t.Fatalf("apply did not redirect to a redacted copy: %s", resp.Header.Get("Location"))
+	}
+	return derived
+}
+
 func findLatestCase(t *testing.T, ts *testutil.TestServer, typePrefix string) *domain.Case {
 	t.Helper()
 	cases := ts.Repos.Case.List(typePrefix, "", "")
@@ -0,0 +1,289 @@
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/ratelimit"
+	"ncoe/internal/service"
+	"ncoe/internal/testutil"
+)
+
+// formToken loads a submission form and returns its form token
+func formToken(t *testing.T, ts *testutil.TestServer, path string) string {
+	t.Helper()
+	if input := testutil.ParseDOM(t, ts.GET(path).Body).FindInput(service.FormTokenField); input != nil {
+		for _, a := range input.Attr {
+			if a.Key == "value" {
+				return a.Val
+			}
+		}
+	}
+	t.Fatalf("GET %s: no %s input", path, service.FormTokenField)
+	return ""
+}
+
+func withField(form url.Values, key, value string) url.Values {
+	form.Set(key, value)
+	return form
+}
+
+func TestIntakeRateLimits(t *testing.T) {
+	t.Run("PerIP", func(t *testing.T) {
+		ts := testutil.NewTestServer(t, testutil.WithIntakePolicy(service.IntakePolicy{PerIP: ratelimit.Rate{Count: 2, Per: time.Hour}}))
+		defer ts.Close()
+
+		// The limit is shared across the forms
+		for _, path := range []string{"/submit/advisory-opinion", "/submit/records-request"} {
+			form := testutil.AdvisoryOpinionForm()
+			if path == "/submit/records-request" {
+				form = testutil.RecordsRequestForm()
+			}
+			if resp := ts.POST(path, form); resp.StatusCode != http.StatusSeeOther {
+				t.Fatalf("POST %s within the limit: %d", path, resp.StatusCode)
+			}
+		}
+		before := len(ts.Repos.Case.List("EC", "", ""))
+		resp := ts.POST("/submit/ethics-complaint", testutil.EthicsComplaintForm())
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("third submission: expected 429, got %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("Retry-After"); got != "1800" {
+			t.Errorf("Retry-After = %q, want 1800 seconds at 2/h", got)
+		}
+		dom := testutil.ParseDOM(t, resp.Body)
+		dom.AssertFullPage()
+		dom.AssertHasElementByID("submit-error")
+		if after := len(ts.Repos.Case.List("EC", "", "")); after != before {
+			t.Errorf("a refused submission was given a case number")
+		}
+	})
+
+	// submitFrom posts a submission with the given forwarding headers
+	submitFrom := func(t *testing.T, ts *testutil.TestServer, headers map[string]string) int {
+		t.Helper()
+		req, err := http.NewRequest("POST", ts.URL+"/submit/advisory-opinion", strings.NewReader(testutil.AdvisoryOpinionForm().Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := ts.Client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	perIP := testutil.WithIntakePolicy(service.IntakePolicy{PerIP: ratelimit.Rate{Count: 1, Per: time.Hour}})
+
+	t.Run("DirectClientsCannotForgeAddresses", func(t *testing.T) {
+		ts := testutil.NewTestServer(t, perIP)
+		defer ts.Close()
+
+		if status := submitFrom(t, ts, map[string]string{"X-Forwarded-For": "203.0.113.5"}); status != http.StatusSeeOther {
+			t.Fatalf("first submission: %d", status)
+		}
+		for _, headers := range []map[string]string{{"X-Forwarded-For": "203.0.113.6"}, {"X-Real-IP": "203.0.113.7"}} {
+			if status := submitFrom(t, ts, headers); status != http.StatusTooManyRequests {
+				t.Errorf("%v from an untrusted peer: expected 429, got %d", headers, status)
+			}
+		}
+	})
+
+	t.Run("ProxiedClients", func(t *testing.T) {
+		ts := testutil.NewTestServer(t, perIP, testutil.WithTrustedProxies("127.0.0.1/32"))
+		defer ts.Close()
+
+		for _, tt := range []struct {
+			headers map[string]string
+			want    int
+		}{
+			{map[string]string{"X-Forwarded-For": "203.0.113.5"}, http.StatusSeeOther},
+			{map[string]string{"X-Forwarded-For": "203.0.113.5"}, http.StatusTooManyRequests},
+			{map[string]string{"X-Forwarded-For": "203.0.113.6"}, http.StatusSeeOther},
+			// The client can prepend anything; only the proxy's own entry counts
+			{map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.6"}, http.StatusTooManyRequests},
+			{map[string]string{"X-Real-IP": "203.0.113.7"}, http.StatusSeeOther},
+			// Without a forwarding header the proxy itself is the client
+			{nil, http.StatusSeeOther},
+			{nil, http.StatusTooManyRequests},
+		} {
+			if status := submitFrom(t, ts, tt.headers); status != tt.want {
+				t.Errorf("%v: expected %d, got %d", tt.headers, tt.want, status)
+			}
+		}
+	})
+
+	t.Run("PerEmail", func(t *testing.T) {
+		ts := testutil.NewTestServer(t, testutil.WithIntakePolicy(service.IntakePolicy{PerEmail: ratelimit.Rate{Count: 1, Per: time.Hour}}))
+		defer ts.Close()
+
+		if resp := ts.POST("/submit/advisory-opinion", testutil.AdvisoryOpinionForm()); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("first submission: %d", resp.StatusCode)
+		}
+		// Case and spacing do not make a new address
+		form := withField(testutil.AdvisoryOpinionForm(), "email", " John@Test.gov ")
+		if resp := ts.POST("/submit/advisory-opinion", form); resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("second submission from the address: expected 429, got %d", resp.StatusCode)
+		}
+		form = withField(testutil.AdvisoryOpinionForm(), "email", "someone.else@test.gov")
+		if resp := ts.POST("/submit/advisory-opinion", form); resp.StatusCode != http.StatusSeeOther {
+			t.Errorf("submission from another address: %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestIntakeHoldsSuspiciousSubmissions(t *testing.T) {
+	// held posts a submission and checks it was confirmed without a case
+	// number and held for the reason
+	held := func(t *testing.T, ts *testutil.TestServer, form url.Values, reason string) *domain.HeldSubmission {
+		t.Helper()
+		before := len(ts.Repos.Case.List("AO", "", ""))
+		resp := ts.POST("/submit/advisory-opinion", form)
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected 303, got %d", resp.StatusCode)
+		}
+		location := resp.Header.Get("Location")
+		if strings.Contains(location, "case=") {
+			t.Errorf("held submission redirected to %s, with a case number", location)
+		}
+		testutil.ParseDOM(t, ts.GET(location).Body).AssertHasElementByID("under-review")
+		if after := len(ts.Repos.Case.List("AO", "", "")); after != before {
+			t.Error("held submission was given a case number")
+		}
+
+		pending := ts.Repos.HeldSubmission.List(domain.TriagePending)
+		if len(pending) == 0 {
+			t.Fatal("nothing held for triage")
+		}
+		got := pending[0]
+		if got.Case.SubmitterEmail != "john@test.gov" || !strings.Contains(strings.Join(got.Reasons, "; "), reason) {
+			t.Errorf("held %q with reasons %v, want %q", got.Case.SubmitterEmail, got.Reasons, reason)
+		}
+		return got
+	}
+
+	t.Run("Honeypot", func(t *testing.T) {
+		ts := testutil.NewTestServer(t)
+		defer ts.Close()
+		held(t, ts, withField(testutil.AdvisoryOpinionForm(), service.HoneypotField, "https://spam.example"), "honeypot")
+	})
+
+	t.Run("TooFast", func(t *testing.T) {
+		ts := testutil.NewTestServer(t, testutil.WithIntakePolicy(service.IntakePolicy{MinFillTime: time.Hour}))
+		defer ts.Close()
+
+		token := formToken(t, ts, "/submit/advisory-opinion")
+		held(t, ts, withField(testutil.AdvisoryOpinionForm(), service.FormTokenField, token), "after the form loaded")
+		held(t, ts, testutil.AdvisoryOpinionForm(), "form token missing")
+		held(t, ts, withField(testutil.AdvisoryOpinionForm(), service.FormTokenField, token+"0"), "form token missing or invalid")
+	})
+
+	t.Run("FormTokenAccepted", func(t *testing.T) {
+		ts := testutil.NewTestServer(t, testutil.WithIntakePolicy(service.IntakePolicy{MinFillTime: time.Nanosecond}))
+		defer ts.Close()
+
+		token := formToken(t, ts, "/submit/advisory-opinion")
+		resp := ts.POST("/submit/advisory-opinion", withField(testutil.AdvisoryOpinionForm(), service.FormTokenField, token))
+		if !strings.Contains(resp.Header.Get("Location"), "case=AO-") {
+			t.Errorf("submission with a valid token redirected to %q", resp.Header.Get("Location"))
+		}
+	})
+
+	t.Run("Challenge", func(t *testing.T) {
+		challenge := &testutil.FakeChallenge{}
+		ts := testutil.NewTestServer(t, testutil.WithChallenge(challenge))
+		defer ts.Close()
+
+		dom := testutil.ParseDOM(t, ts.GET("/submit/ethics-complaint").Body)
+		dom.AssertHasElementByID("fake-challenge")
+
+		held(t, ts, withField(testutil.AdvisoryOpinionForm(), service.ChallengeField, "robot"), "challenge failed")
+		resp := ts.POST("/submit/advisory-opinion", withField(testutil.AdvisoryOpinionForm(), service.ChallengeField, testutil.FakeChallengeAnswer))
+		if !strings.Contains(resp.Header.Get("Location"), "case=AO-") {
+			t.Errorf("submission passing the challenge redirected to %q", resp.Header.Get("Location"))
+		}
+		if ips := challenge.Verified(); len(ips) != 2 || ips[1] != "127.0.0.1" {
+			t.Errorf("challenge verified from %v, want the client address twice", ips)
+		}
+	})
+}
+
+func TestTriageQueue(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	spam := withField(testutil.EthicsComplaintForm(), service.HoneypotField, "x")
+	for i := 0; i < 2; i++ {
+		ts.POST("/submit/ethics-complaint", spam)
+	}
+	pending := ts.Repos.HeldSubmission.List(domain.TriagePending)
+	if len(pending) != 2 {
+		t.Fatalf("%d held submissions, want 2", len(pending))
+	}
+	keep, drop := pending[0], pending[1]
+
+	ts.Login("test@test.gov", "password")
+	dom := testutil.ParseDOM(t, ts.GET("/staff/triage").Body)
+	dom.AssertFullPage()
+	dom.AssertHasElementByID("held-" + keep.ID)
+	dom.AssertHasElementByID("held-" + drop.ID)
+	dom.AssertContainsText("honeypot")
+
+	// Accepting opens the case, dated when it was received
+	resp := ts.POST("/staff/triage/"+keep.ID+"/accept", nil)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("accept: expected 303, got %d", resp.StatusCode)
+	}
+	keep = ts.Repos.HeldSubmission.GetByID(keep.ID)
+	c := ts.Repos.Case.GetByCaseNumber(keep.CaseNumber)
+	if keep.Status != domain.TriageAccepted || c == nil {
+		t.Fatalf("accepted submission: status %s, case %q", keep.Status, keep.CaseNumber)
+	}
+	if resp.Header.Get("Location") != "/staff/cases/"+c.ID {
+		t.Errorf("accept redirected to %s, want the new case", resp.Header.Get("Location"))
+	}
+	if !c.SubmittedAt.Equal(keep.ReceivedAt) || c.SubjectName != "Bob Official" {
+		t.Errorf("case submitted %v for %q, want %v for Bob Official", c.SubmittedAt, c.SubjectName, keep.ReceivedAt)
+	}
+
+	// Each submission is reviewed once
+	if resp := ts.POST("/staff/triage/"+keep.ID+"/reject", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("rejecting an accepted submission: expected 400, got %d", resp.StatusCode)
+	}
+	if resp := ts.POST("/staff/triage/"+drop.ID+"/reject", nil); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("reject: expected 303, got %d", resp.StatusCode)
+	}
+	if drop = ts.Repos.HeldSubmission.GetByID(drop.ID); drop.Status != domain.TriageRejected || drop.CaseNumber != "" {
+		t.Errorf("rejected submission: status %s, case %q", drop.Status, drop.CaseNumber)
+	}
+
+	dom = testutil.ParseDOM(t, ts.GET("/staff/triage").Body)
+	if dom.FindByID("held-"+keep.ID) != nil || dom.FindByID("held-"+drop.ID) != nil {
+		t.Error("reviewed submissions still listed as pending")
+	}
+	testutil.ParseDOM(t, ts.GET("/staff/triage?status=accepted").Body).AssertContainsText(keep.CaseNumber)
+	testutil.ParseDOM(t, ts.GET("/staff/triage?status=rejected").Body).AssertHasElementByID("held-" + drop.ID)
+
+	if resp := ts.POST("/staff/triage/held_999/accept", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown submission: expected 404, got %d", resp.StatusCode)
+	}
+
+	// Only case handlers triage
+	auditor := &domain.User{ID: "user_auditor", Email: "audit@ncoe.nv.gov", FirstName: "Avery", LastName: "Auditor", Role: domain.RoleAuditor, IsActive: true}
+	ts.Repos.User.Create(auditor)
+	ts.Login(auditor.Email, "password")
+	if resp := ts.GET("/staff/triage"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("triage as auditor: expected 403, got %d", resp.StatusCode)
+	}
+	if resp := ts.POST("/staff/triage/"+pending[0].ID+"/accept", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("accept as auditor: expected 403, got %d", resp.StatusCode)
+	}
+}
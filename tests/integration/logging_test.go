@@ -0,0 +1,110 @@
+package integration
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/logging"
+	"ncoe/internal/service"
+	"ncoe/internal/testutil"
+)
+
+// TestAccessLog checks each request is logged as JSON with its request ID,
+// the route that matched and, once signed in, the user
+func TestAccessLog(t *testing.T) {
+	logs := testutil.CaptureLogs(t)
+	ts := testutil.NewTestServer(t, testutil.WithAccessLog())
+	defer ts.Close()
+
+	// request returns the access log record of the response
+	request := func(resp *testutil.Response) map[string]interface{} {
+		t.Helper()
+		for _, record := range logs.Records("request") {
+			if record["request_id"] == resp.Header.Get("X-Request-Id") {
+				return record
+			}
+		}
+		t.Fatalf("no access log record for request %s in %q", resp.Header.Get("X-Request-Id"), logs.String())
+		return nil
+	}
+	check := func(record map[string]interface{}, want map[string]interface{}) {
+		t.Helper()
+		for key, value := range want {
+			if record[key] != value {
+				t.Errorf("%s = %v, want %v in %v", key, record[key], value, record)
+			}
+		}
+	}
+
+	record := request(ts.GET("/search?q=gift"))
+	check(record, map[string]interface{}{"level": "INFO", "method": "GET", "path": "/search", "route": "/search", "status": 200.0})
+	if _, ok := record["user_id"]; ok {
+		t.Errorf("anonymous request logged with user_id %v", record["user_id"])
+	}
+	if _, ok := record["duration_ms"].(float64); !ok {
+		t.Errorf("duration_ms = %v", record["duration_ms"])
+	}
+
+	ts.Login("test@test.gov", "password")
+	check(request(ts.GET("/staff/cases/1/_panel")), map[string]interface{}{"route": "/staff/cases/", "user_id": "demo_user", "status": 200.0})
+	check(request(ts.GET("/api/v1/cases")), map[string]interface{}{"route": "GET /api/v1/cases", "user_id": "demo_user"})
+	check(request(ts.GET("/staff/nowhere")), map[string]interface{}{"status": 404.0})
+}
+
+// TestLogsRedactSubmitters checks details of the public never reach the log
+func TestLogsRedactSubmitters(t *testing.T) {
+	logs := testutil.CaptureLogs(t)
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	form := testutil.EthicsComplaintForm()
+	if resp := ts.POST("/submit/ethics-complaint", form); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("submission: %d", resp.StatusCode)
+	}
+	created := logs.Records("case created")
+	if len(created) != 1 {
+		t.Fatalf("logged %d case creations: %q", len(created), logs.String())
+	}
+	if created[0]["submitter_name"] != logging.Redacted || created[0]["case_number"] == "" {
+		t.Errorf("case creation logged as %v", created[0])
+	}
+	for _, field := range []string{"complainant_name", "complainant_email", "subject_name"} {
+		if value := form.Get(field); value != "" && strings.Contains(logs.String(), value) {
+			t.Errorf("log contains %s %q", field, value)
+		}
+	}
+}
+
+// TestServiceLogsCarryRequest checks what services log about a request is
+// logged with the request's ID, route and user
+func TestServiceLogsCarryRequest(t *testing.T) {
+	logs := testutil.CaptureLogs(t)
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	spam := withField(testutil.EthicsComplaintForm(), service.HoneypotField, "x")
+	resp := ts.POST("/submit/ethics-complaint", spam)
+	held := logs.Records("submission held for triage")
+	if len(held) != 1 {
+		t.Fatalf("logged %d held submissions: %q", len(held), logs.String())
+	}
+	if held[0]["request_id"] != resp.Header.Get("X-Request-Id") || held[0]["route"] != "/submit/ethics-complaint" {
+		t.Errorf("held submission logged as %v", held[0])
+	}
+	if held[0]["submitter_ip"] != logging.Redacted {
+		t.Errorf("submitter_ip = %v, want redacted", held[0]["submitter_ip"])
+	}
+
+	ts.Login("demo@ncoe.nv.gov", "password")
+	ts.Repos.User.Create(&domain.User{ID: "user_inv", Email: "inv@ncoe.nv.gov", FirstName: "Ida", LastName: "Investigator", Role: domain.RoleInvestigator, IsActive: true})
+	resp = ts.POST("/staff/users/user_inv/deactivate", nil)
+	audit := logs.Records("audit")
+	if len(audit) != 1 {
+		t.Fatalf("logged %d audit entries: %q", len(audit), logs.String())
+	}
+	if audit[0]["request_id"] != resp.Header.Get("X-Request-Id") || audit[0]["user_id"] != "user_1" || audit[0]["route"] == nil {
+		t.Errorf("audit entry logged as %v", audit[0])
+	}
+}
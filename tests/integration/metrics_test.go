@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/testutil"
+)
+
+const metricsToken = "scraper-token-0123456789"
+
+// scrape fetches /metrics and returns each series' value, keyed by the
+// series as written, e.g. `ncoe_logins_total` or `ncoe_cases{type="AO",status="submitted"}`
+func scrape(t *testing.T, ts *testutil.TestServer) map[string]float64 {
+	t.Helper()
+	resp := ts.RequestWithToken("GET", "/metrics", metricsToken, "", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /metrics: %d", resp.StatusCode)
+	}
+	series := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSpace(resp.Body), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("malformed line %q", line)
+		}
+		series[line[:i]] = value
+	}
+	return series
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithMetricsToken(metricsToken))
+	defer ts.Close()
+
+	if resp := ts.GET("/metrics"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without a token: expected 401, got %d", resp.StatusCode)
+	}
+	if resp := ts.RequestWithToken("GET", "/metrics", "guess", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("with the wrong token: expected 401, got %d", resp.StatusCode)
+	}
+	resp := ts.RequestWithToken("GET", "/metrics", metricsToken, "", "")
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+
+	// Metrics are process-wide, so compare before and after
+	before := scrape(t, ts)
+	ts.GET("/search?q=gift")
+	ts.GET("/search")
+	ts.GET("/no/such/page")
+
+	ts.Login("test@test.gov", "password")
+	ts.GET("/api/v1/cases")
+	inactive := &domain.User{ID: "user_gone", Email: "gone@ncoe.nv.gov", FirstName: "Gone", LastName: "User", Role: domain.RoleInvestigator}
+	ts.Repos.User.Create(inactive)
+	if status := loginStatus(ts, inactive.Email, "password"); status == http.StatusSeeOther {
+		t.Fatal("deactivated user signed in")
+	}
+	ts.Login("test@test.gov", "password")
+
+	rcv := newWebhookReceiver(0)
+	defer rcv.Close()
+	registerWebhook(t, ts, rcv.URL, domain.EventCaseCreated)
+	ts.POST("/submit/records-request", testutil.RecordsRequestForm())
+	ts.Webhooks.Wait()
+	after := scrape(t, ts)
+
+	increases := map[string]float64{
+		`ncoe_http_requests_total{route="/search",method="GET",status="200"}`:                 2,
+		`ncoe_http_request_duration_seconds_count{route="/search",method="GET",status="200"}`: 2,
+		`ncoe_http_requests_total{route="GET /api/v1/cases",method="GET",status="200"}`:       1,
+		`ncoe_template_render_seconds_count{page="public/search"}`:                            2,
+		`ncoe_logins_total`:                                 2,
+		`ncoe_login_failures_total{reason="inactive"}`:      1,
+		`ncoe_webhook_deliveries_total{status="succeeded"}`: 1,
+		`ncoe_cases{type="PRR",status="submitted"}`:         1,
+	}
+	for series, want := range increases {
+		if got := after[series] - before[series]; got != want {
+			t.Errorf("%s rose by %v, want %v", series, got, want)
+		}
+	}
+	if _, ok := after[`ncoe_http_request_duration_seconds_bucket{route="/search",method="GET",status="200",le="+Inf"}`]; !ok {
+		t.Error("no latency buckets for /search")
+	}
+
+	// Gauges reflect the cases in the repository
+	counts := ts.Repos.Case.CountCases()
+	if got := after["ncoe_deadlines_overdue"]; got != float64(counts.Overdue) || got == 0 {
+		t.Errorf("ncoe_deadlines_overdue = %v, want %d", got, counts.Overdue)
+	}
+	// Paths no route handles do not each become a series
+	for series := range after {
+		if strings.Contains(series, "/no/such/page") {
+			t.Errorf("series %s labelled with a path", series)
+		}
+	}
+}
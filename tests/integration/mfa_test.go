@@ -0,0 +1,349 @@
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/service"
+	"ncoe/internal/testutil"
+	"ncoe/internal/totp"
+)
+
+// totpCode returns the code for secret, steps periods from now
+func totpCode(t *testing.T, secret string, steps int) string {
+	t.Helper()
+	code, err := totp.Code(secret, time.Now().Add(time.Duration(steps)*totp.Period))
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+	return code
+}
+
+// enrollMFA turns on two-factor sign-in from Settings for the logged-in
+// user and returns the secret and recovery codes
+func enrollMFA(t *testing.T, ts *testutil.TestServer) (string, []string) {
+	t.Helper()
+	resp := ts.POST("/staff/settings/mfa/enroll", url.Values{})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("enroll: expected 200, got %d", resp.StatusCode)
+	}
+	secret := testutil.ParseDOM(t, resp.Body).TextByID("mfa-secret")
+	if secret == "" {
+		t.Fatal("enroll: no secret shown")
+	}
+
+	resp = ts.POST("/staff/settings/mfa/confirm", url.Values{"code": {totpCode(t, secret, 0)}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("confirm: expected 200, got %d", resp.StatusCode)
+	}
+	codes := testutil.ParseDOM(t, resp.Body).ListItemsByID("recovery-codes")
+	if len(codes) != service.RecoveryCodeCount {
+		t.Fatalf("confirm: expected %d recovery codes, got %v", service.RecoveryCodeCount, codes)
+	}
+	return secret, codes
+}
+
+// passwordStep submits the password form and expects to be sent to the
+// second login step without a session
+func passwordStep(t *testing.T, ts *testutil.TestServer, email string) *testutil.Response {
+	t.Helper()
+	ts.ClearCookies()
+	resp := ts.POST("/staff/login", testutil.LoginForm(email, "password"))
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/staff/login/mfa" {
+		t.Fatalf("password step: expected 303 to /staff/login/mfa, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if ts.SessionToken() != "" {
+		t.Fatal("password step set a session cookie before the second factor")
+	}
+	return resp
+}
+
+func TestMFAEnrollmentAndLogin(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	const email = "demo@ncoe.nv.gov"
+
+	ts.Login(email, "password")
+	settings := testutil.ParseDOM(t, ts.GET("/staff/settings").Body)
+	settings.AssertHasElementByID("enroll-mfa-form")
+
+	// A wrong code leaves two-factor sign-in off
+	resp := ts.POST("/staff/settings/mfa/enroll", url.Values{})
+	secret := testutil.ParseDOM(t, resp.Body).TextByID("mfa-secret")
+	testutil.ParseDOM(t, resp.Body).AssertHasElementByID("mfa-provisioning-uri")
+	resp = ts.POST("/staff/settings/mfa/confirm", url.Values{"code": {"000000"}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("wrong confirm code: expected 400, got %d", resp.StatusCode)
+	}
+	if ts.Repos.MFA.Get("user_1").IsConfirmed() {
+		t.Fatal("enrollment confirmed by a wrong code")
+	}
+
+	// Enrolling again before confirming shows the same secret
+	again, codes := enrollMFA(t, ts)
+	if again != secret {
+		t.Errorf("unconfirmed enrollment was replaced: %s then %s", secret, again)
+	}
+	testutil.ParseDOM(t, ts.GET("/staff/settings").Body).AssertHasElementByID("mfa-status")
+
+	t.Run("password alone does not sign in", func(t *testing.T) {
+		passwordStep(t, ts, email)
+		if resp := ts.GET("/staff/dashboard"); resp.StatusCode != http.StatusSeeOther {
+			t.Errorf("dashboard before second factor: expected redirect, got %d", resp.StatusCode)
+		}
+		resp := ts.GET("/staff/login/mfa")
+		dom := testutil.ParseDOM(t, resp.Body)
+		dom.AssertHasElementByID("mfa-form")
+		if dom.FindByID("mfa-secret") != nil {
+			t.Error("enrolled user was shown an enrollment secret")
+		}
+	})
+
+	t.Run("wrong code is refused", func(t *testing.T) {
+		resp := ts.POST("/staff/login/mfa", url.Values{"code": {"123456"}})
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertHasElementByID("mfa-error")
+		if ts.SessionToken() != "" {
+			t.Error("wrong code set a session cookie")
+		}
+	})
+
+	t.Run("current code signs in", func(t *testing.T) {
+		resp := ts.POST("/staff/login/mfa", url.Values{"code": {totpCode(t, secret, 1)}})
+		if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/staff/dashboard" {
+			t.Fatalf("expected 303 to dashboard, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		if ts.GET("/staff/dashboard").StatusCode != http.StatusOK {
+			t.Error("dashboard not reachable after second factor")
+		}
+	})
+
+	t.Run("used code cannot be replayed", func(t *testing.T) {
+		passwordStep(t, ts, email)
+		for _, steps := range []int{1, 0} {
+			resp := ts.POST("/staff/login/mfa", url.Values{"code": {totpCode(t, secret, steps)}})
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("code from step %+d: expected 401, got %d", steps, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("recovery code signs in once", func(t *testing.T) {
+		passwordStep(t, ts, email)
+		resp := ts.POST("/staff/login/mfa", url.Values{"code": {codes[0]}})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("recovery code: expected 303, got %d", resp.StatusCode)
+		}
+		if left := len(ts.Repos.MFA.Get("user_1").RecoveryCodes); left != service.RecoveryCodeCount-1 {
+			t.Errorf("recovery codes left = %d", left)
+		}
+
+		passwordStep(t, ts, email)
+		resp = ts.POST("/staff/login/mfa", url.Values{"code": {codes[0]}})
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("reused recovery code: expected 401, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("too many wrong codes end the login", func(t *testing.T) {
+		start := passwordStep(t, ts, email)
+		var resp *testutil.Response
+		for i := 0; i < 5; i++ {
+			resp = ts.POST("/staff/login/mfa", url.Values{"code": {"999999"}})
+		}
+		testutil.ParseDOM(t, resp.Body).AssertHasElementByID("password-login-form")
+
+		// Presenting the ended login again does not help
+		u, _ := url.Parse(ts.URL + "/staff/login/mfa")
+		ts.Client.Jar.SetCookies(u, start.Cookies())
+		resp = ts.POST("/staff/login/mfa", url.Values{"code": {codes[1]}})
+		if resp.StatusCode != http.StatusUnauthorized || ts.SessionToken() != "" {
+			t.Errorf("login continued after the attempt limit: %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("turning off requires a code", func(t *testing.T) {
+		passwordStep(t, ts, email)
+		if resp := ts.POST("/staff/login/mfa", url.Values{"code": {codes[2]}}); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("login: expected 303, got %d", resp.StatusCode)
+		}
+		if resp := ts.POST("/staff/settings/mfa/disable", url.Values{"code": {"111111"}}); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("disable with wrong code: expected 400, got %d", resp.StatusCode)
+		}
+		if resp := ts.POST("/staff/settings/mfa/disable", url.Values{"code": {codes[3]}}); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("disable: expected 303, got %d", resp.StatusCode)
+		}
+		ts.ClearCookies()
+		ts.Login(email, "password")
+		if ts.SessionToken() == "" {
+			t.Error("password login did not sign in after turning two-factor off")
+		}
+	})
+}
+
+func TestMFAThrottledAcrossLogins(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	const email = "demo@ncoe.nv.gov"
+
+	ts.Login(email, "password")
+	secret, _ := enrollMFA(t, ts)
+
+	// Each login allows five guesses; starting new ones does not reset the
+	// account's allowance
+	for login := 0; login < 2; login++ {
+		passwordStep(t, ts, email)
+		for i := 0; i < 5; i++ {
+			ts.POST("/staff/login/mfa", url.Values{"code": {"999999"}})
+		}
+	}
+
+	passwordStep(t, ts, email)
+	resp := ts.POST("/staff/login/mfa", url.Values{"code": {totpCode(t, secret, 1)}})
+	if resp.StatusCode != http.StatusUnauthorized || ts.SessionToken() != "" {
+		t.Fatalf("correct code while throttled: expected 401 without a session, got %d", resp.StatusCode)
+	}
+	testutil.ParseDOM(t, resp.Body).AssertHasElementByID("mfa-error")
+
+	ts.Clock.Advance(10 * time.Minute)
+	passwordStep(t, ts, email)
+	resp = ts.POST("/staff/login/mfa", url.Values{"code": {totpCode(t, secret, 1)}})
+	if resp.StatusCode != http.StatusSeeOther || ts.SessionToken() == "" {
+		t.Errorf("correct code after waiting: expected 303 with a session, got %d", resp.StatusCode)
+	}
+}
+
+func TestMFARequiredByRole(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithMFARequired("commission_counsel"))
+	defer ts.Close()
+
+	counsel := &domain.User{ID: "user_counsel", Email: "counsel@ncoe.nv.gov", FirstName: "Casey", LastName: "Counsel", Role: domain.RoleCommissionCounsel, IsActive: true}
+	if err := ts.Repos.User.Create(counsel); err != nil {
+		t.Fatal(err)
+	}
+
+	// Users without an authenticator enroll during login, before any session
+	passwordStep(t, ts, counsel.Email)
+	dom := testutil.ParseDOM(t, ts.GET("/staff/login/mfa").Body)
+	secret := dom.TextByID("mfa-secret")
+	if secret == "" {
+		t.Fatal("enrollment secret not shown at login")
+	}
+	if uri := dom.FindByID("mfa-provisioning-uri"); uri == nil {
+		t.Fatal("provisioning URI not shown at login")
+	}
+
+	resp := ts.POST("/staff/login/mfa", url.Values{"code": {totpCode(t, secret, 0)}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("enrollment at login: expected 200, got %d", resp.StatusCode)
+	}
+	if codes := testutil.ParseDOM(t, resp.Body).ListItemsByID("recovery-codes"); len(codes) != service.RecoveryCodeCount {
+		t.Errorf("expected recovery codes after enrolling, got %v", codes)
+	}
+	if ts.SessionToken() == "" {
+		t.Fatal("no session after enrolling at login")
+	}
+
+	// The role cannot turn its second factor off
+	settings := testutil.ParseDOM(t, ts.GET("/staff/settings").Body)
+	if settings.FindByID("disable-mfa-form") != nil {
+		t.Error("disable form shown to a role that requires two-factor")
+	}
+	resp = ts.POST("/staff/settings/mfa/disable", url.Values{"code": {totpCode(t, secret, 1)}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("disable for required role: expected 400, got %d", resp.StatusCode)
+	}
+
+	// Other roles are not asked
+	ts.ClearCookies()
+	ts.Login("test@test.gov", "password")
+	if ts.SessionToken() == "" {
+		t.Error("admin without a required second factor could not sign in")
+	}
+}
+
+func TestMFAAdministration(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	investigator := &domain.User{ID: "user_inv", Email: "inv@ncoe.nv.gov", FirstName: "Ida", LastName: "Investigator", Role: domain.RoleInvestigator, IsActive: true}
+	if err := ts.Repos.User.Create(investigator); err != nil {
+		t.Fatal(err)
+	}
+	ts.Login(investigator.Email, "password")
+	enrollMFA(t, ts)
+
+	t.Run("non-admins cannot manage two-factor", func(t *testing.T) {
+		if testutil.ParseDOM(t, ts.GET("/staff/users").Body).FindByID("mfa-policy-form") != nil {
+			t.Error("policy form shown to a non-admin")
+		}
+		if resp := ts.POST("/staff/users/mfa/policy", url.Values{"roles": {"investigator"}}); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("policy: expected 403, got %d", resp.StatusCode)
+		}
+		if resp := ts.POST("/staff/users/user_inv/mfa/reset", url.Values{}); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("reset: expected 403, got %d", resp.StatusCode)
+		}
+	})
+
+	ts.ClearCookies()
+	ts.Login("test@test.gov", "password")
+
+	t.Run("admin sees enrolled accounts", func(t *testing.T) {
+		dom := testutil.ParseDOM(t, ts.GET("/staff/users").Body)
+		dom.AssertHasElementByID("mfa-policy-form")
+		if text := dom.TextByID("mfa-accounts"); text == "" {
+			t.Fatal("enrolled accounts not listed")
+		}
+		dom.AssertContainsText("inv@ncoe.nv.gov")
+	})
+
+	t.Run("admin sets the policy", func(t *testing.T) {
+		resp := ts.POST("/staff/users/mfa/policy", url.Values{"roles": {"commission_counsel", "admin"}})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected 303, got %d", resp.StatusCode)
+		}
+		dom := testutil.ParseDOM(t, ts.GET("/staff/users").Body)
+		for _, role := range []string{"admin", "commission_counsel"} {
+			if !dom.HasAttrByID("mfa-role-"+role, "checked") {
+				t.Errorf("role %s not shown as required", role)
+			}
+		}
+		if resp := ts.POST("/staff/users/mfa/policy", url.Values{"roles": {"superuser"}}); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("unknown role: expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("admin resets a lost authenticator", func(t *testing.T) {
+		if len(ts.Repos.Session.ListByUser("user_inv")) == 0 {
+			t.Fatal("investigator has no session to end")
+		}
+		resp := ts.POST("/staff/users/user_inv/mfa/reset", url.Values{})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected 303, got %d", resp.StatusCode)
+		}
+		if ts.Repos.MFA.Get("user_inv") != nil {
+			t.Fatal("enrollment not removed")
+		}
+
+		// Whoever holds the lost authenticator may hold a session too
+		if sessions := ts.Repos.Session.ListByUser("user_inv"); len(sessions) != 0 {
+			t.Errorf("%d sessions survive the reset", len(sessions))
+		}
+		entries := ts.Repos.Audit.List("user", "user_inv")
+		if len(entries) == 0 || entries[0].Action != domain.AuditUserMFAReset || entries[0].ActorID == "" {
+			t.Errorf("reset not audited: %+v", entries)
+		}
+
+		// The investigator role is not required, so password login works again
+		ts.ClearCookies()
+		ts.Login(investigator.Email, "password")
+		if ts.SessionToken() == "" {
+			t.Error("user could not sign in after reset")
+		}
+	})
+}
@@ -88,6 +88,20 @@ var PublicPages = []PageSpec{
 		WantStatus: http.StatusOK,
 		WantTexts:  []string{"AO-2024-010"},
 	},
+	{
+		Path:       "/statutes",
+		Kind:       KindPage,
+		WantStatus: http.StatusOK,
+		WantTexts:  []string{"NRS 281A.400", "NRS 281A.480"},
+		WantIDs:    []string{"statute-index"},
+	},
+	{
+		Path:       "/statutes/281A.400",
+		Kind:       KindPage,
+		WantStatus: http.StatusOK,
+		WantTexts:  []string{"NRS 281A.400", "AO-2024-010", "EC-2024-005"},
+		WantIDs:    []string{"statute-heading"},
+	},
 }
 
 // ProtectedPages defines all protected (auth required) page routes.
@@ -127,6 +141,14 @@ var ProtectedPages = []PageSpec{
 		WantStatus:   http.StatusOK,
 		WantTexts:    []string{"Report"},
 	},
+	{
+		Path:         "/staff/reports/annual",
+		RequiresAuth: true,
+		Kind:         KindPage,
+		WantStatus:   http.StatusOK,
+		WantTexts:    []string{"Annual Report", "Fiscal Year"},
+		WantIDs:      []string{"annual-report-year-form", "download-pdf", "download-docx"},
+	},
 	{
 		Path:         "/staff/acknowledgments",
 		RequiresAuth: true,
@@ -147,19 +169,39 @@ var ProtectedPages = []PageSpec{
 		WantStatus:   http.StatusOK,
 		WantTexts:    []string{"Setting"},
 	},
+	{
+		Path:         "/staff/triage",
+		RequiresAuth: true,
+		Kind:         KindPage,
+		WantStatus:   http.StatusOK,
+		WantTexts:    []string{"Triage"},
+		WantIDs:      []string{"triage-filter"},
+	},
 }
 
 // FragmentSpecs defines HTMX fragment endpoints.
-// Note: Current template system wraps fragments with base template.
-// These are tested as pages for now until template rendering is fixed.
 var FragmentSpecs = []PageSpec{
 	{
 		Path:         "/staff/cases/1/_panel",
 		RequiresAuth: true,
-		Kind:         KindPage, // Should be KindFragment but templates wrap with base
+		Kind:         KindFragment,
 		WantStatus:   http.StatusOK,
 		WantTexts:    []string{"AO-2024-042"},
 	},
+	{
+		Path:         "/staff/cases/_table?type=AO",
+		RequiresAuth: true,
+		Kind:         KindFragment,
+		WantStatus:   http.StatusOK,
+		WantTexts:    []string{"AO-2024-042"},
+	},
+	{
+		Path:         "/staff/acknowledgments/ack_1/_panel",
+		RequiresAuth: true,
+		Kind:         KindFragment,
+		WantStatus:   http.StatusOK,
+		WantTexts:    []string{"EA-2024-089"},
+	},
 }
 
 func TestPublicPages(t *testing.T) {
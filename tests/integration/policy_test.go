@@ -0,0 +1,128 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/service"
+	"ncoe/internal/testutil"
+)
+
+// TestCasePolicy runs the portal for a commission with its own case number
+// prefixes, response times and reminder schedule.
+func TestCasePolicy(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithCasePolicy(service.CasePolicy{
+		Prefixes: map[domain.CaseType]string{
+			domain.CaseTypeAdvisoryOpinion:      "RFO",
+			domain.CaseTypeEthicsComplaint:      "CMP",
+			domain.CaseTypeEthicsAcknowledgment: "ACK",
+			domain.CaseTypePublicRecordsRequest: "PRA",
+		},
+		ResponseDays: map[domain.CaseType]int{
+			domain.CaseTypeAdvisoryOpinion: 30,
+			domain.CaseTypeEthicsComplaint: 10,
+		},
+		ReminderDays: []int{60, 3},
+	}))
+	defer ts.Close()
+
+	submit := func(t *testing.T, path string, form url.Values) *domain.Case {
+		t.Helper()
+		resp := ts.POST(path, form)
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("submit %s: expected 303, got %d", path, resp.StatusCode)
+		}
+		loc, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := ts.Repos.Case.GetByCaseNumber(loc.Query().Get("case"))
+		if c == nil {
+			t.Fatalf("submit %s: no case %q", path, loc.Query().Get("case"))
+		}
+		return c
+	}
+
+	tests := []struct {
+		path   string
+		form   url.Values
+		prefix string
+		days   int // Business days to respond, 0 for no deadline
+	}{
+		{"/submit/advisory-opinion", testutil.AdvisoryOpinionForm(), "RFO-", 30},
+		{"/submit/ethics-complaint", testutil.EthicsComplaintForm(), "CMP-", 10},
+		{"/submit/acknowledgment", testutil.AcknowledgmentForm(), "ACK-", 0},
+		{"/submit/records-request", testutil.RecordsRequestForm(), "PRA-", 0},
+	}
+	for _, tt := range tests {
+		t.Run(strings.TrimPrefix(tt.path, "/submit/"), func(t *testing.T) {
+			c := submit(t, tt.path, tt.form)
+			if !strings.HasPrefix(c.CaseNumber, tt.prefix) {
+				t.Errorf("case number %s, want prefix %s", c.CaseNumber, tt.prefix)
+			}
+			if tt.days == 0 {
+				if !c.DueDate.IsZero() {
+					t.Errorf("%s has a deadline of %s, want none", c.CaseNumber, c.DueDate)
+				}
+				return
+			}
+			if got := businessDaysBetween(c.SubmittedAt, c.DueDate); got != tt.days {
+				t.Errorf("%s is due %d business days after submission, want %d", c.CaseNumber, got, tt.days)
+			}
+		})
+	}
+
+	t.Run("Reminders", func(t *testing.T) {
+		ts.Login("test@test.gov", "password")
+		rcv := newWebhookReceiver(0)
+		defer rcv.Close()
+		hook := registerWebhook(t, ts, rcv.URL, domain.EventDeadlineReminder)
+
+		n := ts.Cases.NotifyDeadlineReminders(context.Background())
+		if n == 0 {
+			t.Fatal("no reminders for open deadlines")
+		}
+		ts.Webhooks.Wait()
+		reminders := rcv.events(t, hook.Secret, domain.EventDeadlineReminder)
+		if len(reminders) != n {
+			t.Fatalf("expected %d deadline.reminder events, got %d", n, len(reminders))
+		}
+		sawNewAdvisory := false
+		for _, e := range reminders {
+			reminder, _ := e.Data["reminder_days"].(float64)
+			days, _ := e.Data["days_until_due"].(float64)
+			if reminder != 60 && reminder != 3 {
+				t.Errorf("reminder for %v days is not on the schedule: %v", reminder, e.Data)
+			}
+			if days < 0 || days > reminder || (reminder == 60 && days <= 3) {
+				t.Errorf("%v days before the deadline is not the nearest reminder to %v: %v", days, reminder, e.Data)
+			}
+			if strings.HasPrefix(e.Data["case_number"].(string), "RFO-") && reminder == 60 {
+				sawNewAdvisory = true
+			}
+		}
+		if !sawNewAdvisory {
+			t.Error("the new advisory opinion request, due in about six weeks, got no 60-day reminder")
+		}
+
+		if again := ts.Cases.NotifyDeadlineReminders(context.Background()); again != 0 {
+			t.Errorf("reminders should be sent once per scheduled day, got %d more", again)
+		}
+	})
+}
+
+// businessDaysBetween counts the weekdays after from up to and including to
+func businessDaysBetween(from, to time.Time) int {
+	n := 0
+	for d := from.AddDate(0, 0, 1); !d.After(to); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,225 @@
+package integration
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/testutil"
+)
+
+// seedReportCases adds cases in the first half of 2023, well away from the
+// demo data, and returns the query string for that range
+func seedReportCases(t *testing.T, ts *testutil.TestServer) string {
+	t.Helper()
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2023, month, d, 12, 0, 0, 0, time.Local)
+	}
+	at := func(month time.Month, d int) *time.Time {
+		t := day(month, d)
+		return &t
+	}
+	ts.Repos.User.Create(&domain.User{ID: "user_inv", Email: "inv@ncoe.nv.gov", FirstName: "Ida", LastName: "Investigator", Role: domain.RoleInvestigator, IsActive: true})
+
+	cases := []*domain.Case{
+		// Closed in 10 days, before its deadline
+		{ID: "r1", CaseNumber: "AO-2023-001", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusClosed, SubmittedAt: day(1, 10), ClosedAt: at(1, 20), DueDate: day(2, 1)},
+		// Published in 30 days, after its deadline
+		{ID: "r2", CaseNumber: "AO-2023-002", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusPublished, SubmittedAt: day(2, 1), PublishedAt: at(3, 3), DueDate: day(2, 20)},
+		// Still open past its deadline
+		{ID: "r3", CaseNumber: "AO-2023-003", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusUnderReview, SubmittedAt: day(3, 1), DueDate: day(4, 1)},
+		// Closed in 151 days; the only case assigned to Ida
+		{ID: "r4", CaseNumber: "EC-2023-001", Type: domain.CaseTypeEthicsComplaint, Status: domain.StatusClosed, SubmittedAt: day(1, 15), ClosedAt: at(6, 15), AssignedTo: "user_inv"},
+		// Submitted before the range and still open
+		{ID: "r5", CaseNumber: "PRR-2022-001", Type: domain.CaseTypePublicRecordsRequest, Status: domain.StatusUnderReview, SubmittedAt: time.Date(2022, 12, 1, 12, 0, 0, 0, time.Local)},
+		// Submitted late in the range
+		{ID: "r6", CaseNumber: "AO-2023-004", Type: domain.CaseTypeAdvisoryOpinion, Status: domain.StatusSubmitted, SubmittedAt: day(6, 20)},
+	}
+	for _, c := range cases {
+		ts.Repos.Case.Create(c)
+	}
+	return "from=2023-01-01&to=2023-06-30"
+}
+
+// csvRows returns the rows of the named table in a report CSV export
+func csvRows(t *testing.T, body, table string) [][]string {
+	t.Helper()
+	r := csv.NewReader(strings.NewReader(body))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	var rows [][]string
+	in := false
+	for _, rec := range records {
+		switch {
+		case len(rec) == 1 && rec[0] == "":
+			in = false
+		case len(rec) == 1:
+			in = rec[0] == table
+		case in:
+			rows = append(rows, rec)
+		}
+	}
+	if len(rows) == 0 {
+		t.Fatalf("CSV has no %q table:\n%s", table, body)
+	}
+	return rows[1:] // Without the header
+}
+
+func TestReports(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	query := seedReportCases(t, ts)
+	ts.Login("demo@ncoe.nv.gov", "password")
+
+	t.Run("Page", func(t *testing.T) {
+		resp := ts.GET("/staff/reports?" + query)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		dom := testutil.ParseDOM(t, resp.Body)
+		dom.AssertHasForm("/staff/reports")
+		for id, want := range map[string]string{
+			"report-opened":  "5",
+			"report-closed":  "3",
+			"report-on-time": "33%",
+			"report-backlog": "3",
+		} {
+			if got := dom.TextByID(id); got != want {
+				t.Errorf("#%s = %q, want %q", id, got, want)
+			}
+		}
+		dom.AssertHasElementByID("monthly-chart")
+		// One bar pair per month in the range
+		for _, month := range []string{"2023-01", "2023-06"} {
+			dom.AssertHasElementByID("month-" + month)
+		}
+		if dom.FindByID("month-2023-07") != nil {
+			t.Error("chart runs past the end of the range")
+		}
+		if !strings.Contains(resp.Body, `title="January 2023: 2 opened, 1 closed"`) {
+			t.Error("January bar does not show 2 opened and 1 closed")
+		}
+		if text := dom.TextByID("close-times"); !strings.Contains(text, "20.0 days") || !strings.Contains(text, "28.0 days") {
+			t.Errorf("time to close = %q, want a median of 20 and p90 of 28 days for advisory opinions", text)
+		}
+		if !dom.HasAttrByID("export-csv", "href") || !strings.Contains(resp.Body, "from=2023-01-01") {
+			t.Error("export links do not carry the filters")
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		dom := testutil.ParseDOM(t, ts.GET("/staff/reports?"+query+"&assignee=user_inv").Body)
+		if got := dom.TextByID("report-opened"); got != "1" {
+			t.Errorf("opened for Ida = %q, want 1", got)
+		}
+		dom = testutil.ParseDOM(t, ts.GET("/staff/reports?"+query+"&type=AO").Body)
+		if got := dom.TextByID("report-opened"); got != "4" {
+			t.Errorf("advisory opinions opened = %q, want 4", got)
+		}
+		if strings.Contains(dom.TextByID("close-times"), "Ethics Complaint") {
+			t.Error("type filter still lists other types")
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		resp := ts.GET("/staff/reports/export?format=csv&" + query)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, "case-report-2023-01-01-to-2023-06-30.csv") {
+			t.Errorf("Content-Disposition = %q", cd)
+		}
+
+		want := map[string]map[string][]string{
+			"Summary":       {"Cases opened": {"5"}, "Cases closed": {"3"}, "On-time rate (%)": {"33"}},
+			"Monthly":       {"2023-01": {"2", "1"}, "2023-02": {"1", "0"}, "2023-03": {"1", "1"}, "2023-06": {"1", "1"}},
+			"Time to Close": {"Advisory Opinion": {"2", "20", "28"}, "Ethics Complaint": {"1", "151", "151"}, "Public Records Request": {"0", "", ""}},
+			"Deadlines":     {"Advisory Opinion": {"1", "2", "33"}, "All types": {"1", "2", "33"}},
+			"Backlog Aging": {"0–30 days": {"1"}, "91–180 days": {"1"}, "Over 180 days": {"1"}, "31–60 days": {"0"}},
+		}
+		for table, rows := range want {
+			got := map[string][]string{}
+			for _, row := range csvRows(t, resp.Body, table) {
+				got[row[0]] = row[1:]
+			}
+			for key, values := range rows {
+				if strings.Join(got[key], ",") != strings.Join(values, ",") {
+					t.Errorf("%s / %s = %v, want %v", table, key, got[key], values)
+				}
+			}
+		}
+	})
+
+	t.Run("XLSX", func(t *testing.T) {
+		resp := ts.GET("/staff/reports/export?format=xlsx&" + query)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		zr, err := zip.NewReader(bytes.NewReader([]byte(resp.Body)), int64(len(resp.Body)))
+		if err != nil {
+			t.Fatalf("export is not a zip archive: %v", err)
+		}
+		parts := map[string]string{}
+		for _, f := range zr.File {
+			rc, _ := f.Open()
+			body, _ := io.ReadAll(rc)
+			rc.Close()
+			parts[f.Name] = string(body)
+		}
+		for _, name := range []string{"Summary", "Monthly", "Time to Close", "Deadlines", "Backlog Aging"} {
+			if !strings.Contains(parts["xl/workbook.xml"], `name="`+name+`"`) {
+				t.Errorf("workbook has no %q sheet", name)
+			}
+		}
+		if !strings.Contains(parts["xl/worksheets/sheet3.xml"], "<v>151</v>") {
+			t.Error("time to close sheet is missing the complaint's 151 days")
+		}
+	})
+
+	t.Run("InvalidFilters", func(t *testing.T) {
+		for _, path := range []string{
+			"/staff/reports?from=2023-06-30&to=2023-01-01",
+			"/staff/reports?from=June",
+			"/staff/reports?type=XX",
+			"/staff/reports?assignee=nobody",
+		} {
+			resp := ts.GET(path)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", path, resp.StatusCode)
+				continue
+			}
+			testutil.ParseDOM(t, resp.Body).AssertHasElementByID("report-error")
+		}
+		if resp := ts.GET("/staff/reports/export?format=pdf&" + query); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("unknown format: expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("ClosingRecordsDate", func(t *testing.T) {
+		if err := ts.Cases.UpdateStatus(context.Background(), ts.Repos.User.GetByID("user_1"), "r3", domain.StatusClosed); err != nil {
+			t.Fatal(err)
+		}
+		if c := ts.Repos.Case.GetByID("r3"); c.ClosedAt == nil || time.Since(*c.ClosedAt) > time.Minute {
+			t.Fatalf("closing did not record the date: %v", c.ClosedAt)
+		}
+		if err := ts.Cases.UpdateStatus(context.Background(), ts.Repos.User.GetByID("user_1"), "r3", domain.StatusUnderReview); err != nil {
+			t.Fatal(err)
+		}
+		if c := ts.Repos.Case.GetByID("r3"); c.ClosedAt != nil {
+			t.Error("reopening kept the closing date")
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package integration
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"ncoe/internal/middleware"
+	"ncoe/internal/testutil"
+)
+
+var policyNonce = regexp.MustCompile(`'nonce-([^']+)'`)
+
+// TestSecurityHeaders checks every page carries the security headers and a
+// fresh CSP nonce, and that each inline script and style carries it too
+func TestSecurityHeaders(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	seen := make(map[string]string)
+	check := func(path string) {
+		t.Helper()
+		resp := ts.GET(path)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: %d", path, resp.StatusCode)
+		}
+		for header, want := range map[string]string{
+			"X-Frame-Options":           "DENY",
+			"X-Content-Type-Options":    "nosniff",
+			"Referrer-Policy":           "strict-origin-when-cross-origin",
+			"Strict-Transport-Security": "max-age=31536000",
+			"Permissions-Policy":        "camera=()",
+		} {
+			if got := resp.Header.Get(header); !strings.Contains(got, want) {
+				t.Errorf("GET %s: %s = %q, want %q", path, header, got, want)
+			}
+		}
+
+		policy := resp.Header.Get("Content-Security-Policy")
+		m := policyNonce.FindStringSubmatch(policy)
+		if m == nil {
+			t.Fatalf("GET %s: Content-Security-Policy %q has no nonce", path, policy)
+		}
+		nonce := m[1]
+		for _, directive := range []string{"default-src 'self'", "object-src 'none'", "frame-ancestors 'none'", "report-uri /csp-report"} {
+			if !strings.Contains(policy, directive) {
+				t.Errorf("GET %s: policy lacks %q", path, directive)
+			}
+		}
+		if other, ok := seen[nonce]; ok {
+			t.Errorf("GET %s reused the nonce of %s", path, other)
+		}
+		seen[nonce] = path
+
+		dom := testutil.ParseDOM(t, resp.Body)
+		inline := len(dom.FindAllByTag("script")) - len(dom.AttrsByTag("script", "src")) + len(dom.FindAllByTag("style"))
+		nonces := append(dom.AttrsByTag("script", "nonce"), dom.AttrsByTag("style", "nonce")...)
+		if inline == 0 || len(nonces) != inline {
+			t.Errorf("GET %s: %d inline scripts and styles, %d with a nonce", path, inline, len(nonces))
+		}
+		for _, n := range nonces {
+			if n != nonce {
+				t.Errorf("GET %s: element nonce %q, want %q", path, n, nonce)
+			}
+		}
+		if handlers := dom.EventHandlerAttrs(); len(handlers) > 0 {
+			t.Errorf("GET %s: inline event handlers %v, which the policy blocks", path, handlers)
+		}
+	}
+
+	check("/")
+	check("/")
+	check("/search")
+	check("/staff/login")
+	ts.Login("test@test.gov", "password")
+	check("/staff/dashboard")
+	check("/staff/cases")
+}
+
+func TestCSPReportOnly(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithCSPReportOnly())
+	defer ts.Close()
+
+	resp := ts.GET("/")
+	if got := resp.Header.Get("Content-Security-Policy"); got != "" {
+		t.Errorf("report-only mode enforces a policy: %q", got)
+	}
+	policy := resp.Header.Get("Content-Security-Policy-Report-Only")
+	m := policyNonce.FindStringSubmatch(policy)
+	if m == nil {
+		t.Fatalf("Content-Security-Policy-Report-Only = %q, want a policy with a nonce", policy)
+	}
+	// Pages still carry the nonce, so switching to enforcement changes nothing
+	if !strings.Contains(resp.Body, `nonce="`+m[1]+`"`) {
+		t.Error("page does not carry the report-only policy's nonce")
+	}
+}
+
+func TestCSPReportEndpoint(t *testing.T) {
+	logs := testutil.CaptureLogs(t)
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	legacy := `{"csp-report": {"document-uri": "https://ethics.nv.gov/", "blocked-uri": "inline", "effective-directive": "script-src-elem", "source-file": "https://ethics.nv.gov/", "line-number": 12, "disposition": "enforce"}}`
+	resp := ts.Request("POST", "/csp-report", "application/csp-report", legacy)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("legacy report: %d", resp.StatusCode)
+	}
+	requestID := resp.Header.Get("X-Request-Id")
+	records := logs.Records("CSP violation")
+	if len(records) != 1 {
+		t.Fatalf("logged %d violations, want 1: %q", len(records), logs.String())
+	}
+	if v := records[0]; v["request_id"] != requestID || v["directive"] != "script-src-elem" || v["blocked_uri"] != "inline" || v["line"] != 12.0 {
+		t.Errorf("violation logged as %v, want it with request ID %s", v, requestID)
+	}
+
+	logs.Reset()
+	batch := `[{"type": "csp-violation", "body": {"documentURL": "https://ethics.nv.gov/staff/cases", "blockedURL": "https://evil.example/x.js", "effectiveDirective": "script-src-elem", "disposition": "report"}},
+		{"type": "deprecation", "body": {"id": "x"}}]`
+	resp = ts.Request("POST", "/csp-report", "application/reports+json", batch)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Reporting API batch: %d", resp.StatusCode)
+	}
+	records = logs.Records("CSP violation")
+	if len(records) != 1 {
+		t.Fatalf("logged %d violations, want 1: %q", len(records), logs.String())
+	}
+	if records[0]["blocked_uri"] != "https://evil.example/x.js" {
+		t.Errorf("violation logged as %v, want the blocked URL", records[0])
+	}
+
+	// A forged report cannot add lines to the log
+	logs.Reset()
+	forged := `{"csp-report": {"blocked-uri": "x\nREQ=forged GET /staff 200"}}`
+	ts.Request("POST", "/csp-report", "application/csp-report", forged)
+	if strings.Count(logs.String(), "\n") != 1 {
+		t.Errorf("forged report logged %q", logs.String())
+	}
+
+	for _, tt := range []struct {
+		method, body string
+		want         int
+	}{
+		{"GET", "", http.StatusMethodNotAllowed},
+		{"POST", "not json", http.StatusBadRequest},
+		{"POST", `{"csp-report": "` + strings.Repeat("x", 70<<10) + `"}`, http.StatusRequestEntityTooLarge},
+	} {
+		if resp := ts.Request(tt.method, "/csp-report", "application/csp-report", tt.body); resp.StatusCode != tt.want {
+			t.Errorf("%s /csp-report with %d bytes: %d, want %d", tt.method, len(tt.body), resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestCSPReportLimits(t *testing.T) {
+	logs := testutil.CaptureLogs(t)
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	// One report logs at most ten violations, and says how many it dropped
+	violation := `{"type": "csp-violation", "body": {"blockedURL": "inline", "effectiveDirective": "script-src-elem"}}`
+	batch := "[" + strings.TrimSuffix(strings.Repeat(violation+",", 50), ",") + "]"
+	if resp := ts.Request("POST", "/csp-report", "application/reports+json", batch); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("batch: %d", resp.StatusCode)
+	}
+	if n := len(logs.Records("CSP violation")); n != 10 {
+		t.Errorf("logged %d violations from one report, want 10", n)
+	}
+	if dropped := logs.Records("CSP violations dropped"); len(dropped) != 1 || dropped[0]["count"] != 40.0 {
+		t.Errorf("dropped violations logged as %v", dropped)
+	}
+
+	// One address cannot keep sending reports
+	var resp *testutil.Response
+	for i := 1; i <= middleware.CSPReportRate.Count; i++ {
+		resp = ts.Request("POST", "/csp-report", "application/reports+json", "[]")
+		if resp.StatusCode == http.StatusTooManyRequests {
+			break
+		}
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("report past the rate: %d, Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
@@ -0,0 +1,220 @@
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/service"
+	"ncoe/internal/testutil"
+)
+
+// assertSignedIn checks whether the current cookie reaches the dashboard
+func assertSignedIn(t *testing.T, ts *testutil.TestServer, want bool) {
+	t.Helper()
+	resp := ts.GET("/staff/dashboard")
+	if got := resp.StatusCode == http.StatusOK; got != want {
+		t.Fatalf("signed in = %v (status %d), want %v", got, resp.StatusCode, want)
+	}
+}
+
+func TestLogoutEndsServerSession(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	ts.Login("demo@ncoe.nv.gov", "password")
+	token := ts.SessionToken()
+	if ts.Repos.Session.GetByToken(token) == nil {
+		t.Fatal("login did not store a session")
+	}
+
+	// The Sign Out button posts to the logout route
+	testutil.ParseDOM(t, ts.GET("/staff/settings").Body).AssertHasForm("/staff/logout")
+	ts.POST("/staff/logout", url.Values{})
+	if ts.Repos.Session.GetByToken(token) != nil {
+		t.Fatal("logout left the session on the server")
+	}
+
+	// A copy of the cookie taken before logout no longer works
+	ts.SetSessionToken(token)
+	assertSignedIn(t, ts, false)
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	policy := service.SessionPolicy{IdleTimeout: 30 * time.Minute, AbsoluteTimeout: time.Hour}
+
+	t.Run("IdleTimeoutSlidesWithActivity", func(t *testing.T) {
+		ts := testutil.NewTestServer(t, testutil.WithSessionPolicy(service.DefaultSessionPolicy))
+		defer ts.Close()
+
+		ts.Login("demo@ncoe.nv.gov", "password")
+		// Activity more often than the idle timeout keeps the session alive
+		// well past it
+		for i := 0; i < 4; i++ {
+			ts.Clock.Advance(20 * time.Minute)
+			assertSignedIn(t, ts, true)
+		}
+
+		ts.Clock.Advance(31 * time.Minute)
+		assertSignedIn(t, ts, false)
+		if ts.Repos.Session.GetByToken(ts.SessionToken()) != nil {
+			t.Error("expired session was not removed when presented")
+		}
+	})
+
+	t.Run("AbsoluteTimeoutIgnoresActivity", func(t *testing.T) {
+		ts := testutil.NewTestServer(t, testutil.WithSessionPolicy(policy))
+		defer ts.Close()
+
+		ts.Login("demo@ncoe.nv.gov", "password")
+		session := ts.Repos.Session.GetByToken(ts.SessionToken())
+		if want := session.CreatedAt.Add(time.Hour); !session.ExpiresAt.Equal(want) {
+			t.Errorf("ExpiresAt = %v, want %v", session.ExpiresAt, want)
+		}
+
+		for i := 0; i < 2; i++ {
+			ts.Clock.Advance(20 * time.Minute)
+			assertSignedIn(t, ts, true)
+			// Renewal never pushes the idle limit past the absolute limit
+			if s := ts.Repos.Session.GetByToken(ts.SessionToken()); s.IdleExpiresAt.After(s.ExpiresAt) {
+				t.Fatalf("idle limit %v is past the absolute limit %v", s.IdleExpiresAt, s.ExpiresAt)
+			}
+		}
+
+		ts.Clock.Advance(25 * time.Minute)
+		assertSignedIn(t, ts, false)
+	})
+
+	t.Run("SweeperRemovesExpiredSessions", func(t *testing.T) {
+		ts := testutil.NewTestServer(t, testutil.WithSessionPolicy(policy))
+		defer ts.Close()
+
+		ts.Login("demo@ncoe.nv.gov", "password")
+		idle := ts.SessionToken()
+		ts.Clock.Advance(31 * time.Minute)
+		ts.Login("demo@ncoe.nv.gov", "password")
+		active := ts.SessionToken()
+
+		if removed := ts.Auth.SweepExpiredSessions(); removed != 1 {
+			t.Errorf("SweepExpiredSessions() = %d, want 1", removed)
+		}
+		if ts.Repos.Session.GetByToken(idle) != nil {
+			t.Error("idle session survived the sweep")
+		}
+		if ts.Repos.Session.GetByToken(active) == nil {
+			t.Error("active session was swept")
+		}
+	})
+}
+
+func TestSignedInSessions(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	const email = "demo@ncoe.nv.gov"
+
+	// Sign in from a "laptop", then from this browser
+	ts.Login(email, "password")
+	laptop := ts.SessionToken()
+	ts.ClearCookies()
+	ts.Login(email, "password")
+	current := ts.SessionToken()
+
+	dom := testutil.ParseDOM(t, ts.GET("/staff/settings").Body)
+	dom.AssertHasElementByID("sessions")
+	dom.AssertHasElementByID("current-session")
+	dom.AssertHasElementByID("revoke-other-sessions-form")
+	laptopSession := ts.Repos.Session.GetByToken(laptop)
+	dom.AssertHasElementByID("session-" + laptopSession.ID)
+	if text := dom.TextByID("session-" + laptopSession.ID); !strings.Contains(text, "127.0.0.1") {
+		t.Errorf("laptop session row = %q, want its IP address", text)
+	}
+
+	// Another user's session cannot be revoked from here
+	other := &domain.User{ID: "user_inv", Email: "inv@ncoe.nv.gov", FirstName: "Ida", LastName: "Investigator", Role: domain.RoleInvestigator, IsActive: true}
+	ts.Repos.User.Create(other)
+	ts.ClearCookies()
+	ts.Login(other.Email, "password")
+	otherToken := ts.SessionToken()
+	ts.SetSessionToken(current)
+	otherSession := ts.Repos.Session.GetByToken(otherToken)
+	if resp := ts.POST("/staff/settings/sessions/"+otherSession.ID+"/revoke", url.Values{}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("revoking another user's session: expected 404, got %d", resp.StatusCode)
+	}
+	if ts.Repos.Session.GetByToken(otherToken) == nil {
+		t.Fatal("another user's session was revoked")
+	}
+
+	// Revoking the laptop signs it out and leaves this browser signed in
+	resp := ts.POST("/staff/settings/sessions/"+laptopSession.ID+"/revoke", url.Values{})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("revoke: expected 303, got %d", resp.StatusCode)
+	}
+	assertSignedIn(t, ts, true)
+	ts.SetSessionToken(laptop)
+	assertSignedIn(t, ts, false)
+
+	// Signing out other sessions keeps only the current one
+	ts.ClearCookies()
+	ts.Login(email, "password")
+	second := ts.SessionToken()
+	ts.SetSessionToken(current)
+	if resp := ts.POST("/staff/settings/sessions/revoke-others", url.Values{}); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("revoke others: expected 303, got %d", resp.StatusCode)
+	}
+	assertSignedIn(t, ts, true)
+	if ts.Repos.Session.GetByToken(second) != nil {
+		t.Error("revoke others left another session signed in")
+	}
+	if ts.Repos.Session.GetByToken(otherToken) == nil {
+		t.Error("revoke others signed out a different user")
+	}
+	dom = testutil.ParseDOM(t, ts.GET("/staff/settings").Body)
+	if dom.FindByID("revoke-other-sessions-form") != nil {
+		t.Error("sign out other sessions offered with only one session")
+	}
+}
+
+func TestForceLogout(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	investigator := &domain.User{ID: "user_inv", Email: "inv@ncoe.nv.gov", FirstName: "Ida", LastName: "Investigator", Role: domain.RoleInvestigator, IsActive: true}
+	ts.Repos.User.Create(investigator)
+
+	ts.Login(investigator.Email, "password")
+	first := ts.SessionToken()
+	ts.ClearCookies()
+	ts.Login(investigator.Email, "password")
+	second := ts.SessionToken()
+
+	// Non-admins cannot sign anyone out
+	if resp := ts.POST("/staff/users/user_1/sessions/revoke", url.Values{}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin force logout: expected 403, got %d", resp.StatusCode)
+	}
+
+	ts.ClearCookies()
+	ts.Login("demo@ncoe.nv.gov", "password")
+	dom := testutil.ParseDOM(t, ts.GET("/staff/users").Body)
+	dom.AssertHasElementByID("active-users")
+	if text := dom.TextByID("active-user-user_inv"); !strings.Contains(text, "Ida Investigator") || !strings.Contains(text, "2") {
+		t.Errorf("active user row = %q, want name and session count", text)
+	}
+
+	resp := ts.POST("/staff/users/user_inv/sessions/revoke", url.Values{})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("force logout: expected 303, got %d", resp.StatusCode)
+	}
+	for _, token := range []string{first, second} {
+		if ts.Repos.Session.GetByToken(token) != nil {
+			t.Error("force logout left a session signed in")
+		}
+	}
+	assertSignedIn(t, ts, true)
+	testutil.ParseDOM(t, ts.GET("/staff/users").Body).AssertHasElementByID("active-user-user_1")
+	if testutil.ParseDOM(t, ts.GET("/staff/users").Body).FindByID("active-user-user_inv") != nil {
+		t.Error("signed-out user still listed")
+	}
+}
@@ -0,0 +1,407 @@
+package integration
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/service"
+	"ncoe/internal/testutil"
+)
+
+// ssoRoles maps the fake provider's groups to staff roles
+var ssoRoles = map[string]string{
+	"NCOE-Counsel":       "commission_counsel",
+	"NCOE-Investigators": "investigator",
+	"NCOE-Admins":        "admin",
+}
+
+var janeDoe = testutil.IdPUser{
+	Subject:       "248289761001",
+	Email:         "jdoe@ethics.nv.gov",
+	EmailVerified: true,
+	GivenName:     "Jane",
+	FamilyName:    "Doe",
+	Groups:        []string{"All-Employees", "NCOE-Investigators"},
+}
+
+func newSSOServer(t *testing.T, exclusive bool) (*testutil.TestServer, *testutil.FakeIdP) {
+	t.Helper()
+	idp := testutil.NewFakeIdP(t)
+	ts := testutil.NewTestServer(t, testutil.WithSSO(idp, service.SSOConfig{
+		ProviderName: "Nevada State SSO",
+		RoleMap:      ssoRoles,
+		Exclusive:    exclusive,
+	}))
+	t.Cleanup(ts.Close)
+	return ts, idp
+}
+
+// ssoLogin starts a login, lets the fake provider sign the user in and
+// returns the response to the callback
+func ssoLogin(t *testing.T, ts *testutil.TestServer) *testutil.Response {
+	t.Helper()
+	start := ts.GET("/staff/login/sso")
+	if start.StatusCode != http.StatusFound {
+		t.Fatalf("GET /staff/login/sso: expected 302, got %d: %s", start.StatusCode, start.Body)
+	}
+	atIdP := ts.Follow(start)
+	if atIdP.StatusCode != http.StatusFound {
+		t.Fatalf("provider authorize: expected 302, got %d: %s", atIdP.StatusCode, atIdP.Body)
+	}
+	return ts.Follow(atIdP)
+}
+
+func TestSSOLogin(t *testing.T) {
+	ts, idp := newSSOServer(t, false)
+
+	t.Run("login page offers the provider", func(t *testing.T) {
+		resp := ts.GET("/staff/login")
+		dom := testutil.ParseDOM(t, resp.Body)
+		dom.AssertHasElementByID("sso-login")
+		dom.AssertContainsText("Sign in with Nevada State SSO")
+		dom.AssertHasElementByID("password-login-form")
+	})
+
+	t.Run("callback address ignores a forged Host", func(t *testing.T) {
+		req, err := http.NewRequest("GET", ts.URL+"/staff/login/sso", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = "attacker.example"
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		authorize, err := resp.Location()
+		if err != nil {
+			t.Fatalf("expected a redirect to the provider, got %d", resp.StatusCode)
+		}
+		if got, want := authorize.Query().Get("redirect_uri"), ts.URL+"/staff/login/sso/callback"; got != want {
+			t.Errorf("redirect_uri = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("first login provisions the user", func(t *testing.T) {
+		idp.SignIn(janeDoe)
+		resp := ssoLogin(t, ts)
+		if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/staff/dashboard" {
+			t.Fatalf("callback: expected 303 to dashboard, got %d %q: %s", resp.StatusCode, resp.Header.Get("Location"), resp.Body)
+		}
+
+		user := ts.Repos.User.GetByEmail("jdoe@ethics.nv.gov")
+		if user == nil {
+			t.Fatal("user was not provisioned")
+		}
+		if user.Role != domain.RoleInvestigator {
+			t.Errorf("role = %s, want investigator", user.Role)
+		}
+		if user.FullName() != "Jane Doe" || !user.IsActive || user.LastLoginAt == nil {
+			t.Errorf("provisioned user = %+v", user)
+		}
+		if user.ExternalID != idp.URL+"|248289761001" {
+			t.Errorf("external ID = %q", user.ExternalID)
+		}
+
+		dash := ts.GET("/staff/dashboard")
+		if dash.StatusCode != http.StatusOK {
+			t.Fatalf("dashboard after SSO: expected 200, got %d", dash.StatusCode)
+		}
+		testutil.ParseDOM(t, dash.Body).AssertContainsText("Jane Doe")
+	})
+
+	t.Run("provisioned user cannot sign in with a password", func(t *testing.T) {
+		for _, password := range []string{"wrong password", ""} {
+			if status := loginStatus(ts, "jdoe@ethics.nv.gov", password); status == http.StatusSeeOther || ts.SessionToken() != "" {
+				t.Errorf("signed in with password %q", password)
+			}
+		}
+	})
+
+	t.Run("later login follows group changes", func(t *testing.T) {
+		ts.ClearCookies()
+		promoted := janeDoe
+		promoted.Groups = []string{"NCOE-Investigators", "NCOE-Counsel"}
+		idp.SignIn(promoted)
+		if resp := ssoLogin(t, ts); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("callback: expected 303, got %d: %s", resp.StatusCode, resp.Body)
+		}
+
+		user := ts.Repos.User.GetByEmail("jdoe@ethics.nv.gov")
+		if user.Role != domain.RoleCommissionCounsel {
+			t.Errorf("role = %s, want the most privileged mapped role commission_counsel", user.Role)
+		}
+		if user.ExternalID != idp.URL+"|248289761001" {
+			t.Errorf("second login created a new account: %+v", user)
+		}
+	})
+
+	t.Run("existing account is linked by verified email", func(t *testing.T) {
+		ts.ClearCookies()
+		idp.SignIn(testutil.IdPUser{
+			Subject:       "demo-subject",
+			Email:         "demo@ncoe.nv.gov",
+			EmailVerified: true,
+			Groups:        []string{"NCOE-Admins"},
+		})
+		if resp := ssoLogin(t, ts); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("callback: expected 303, got %d: %s", resp.StatusCode, resp.Body)
+		}
+		user := ts.Repos.User.GetByEmail("demo@ncoe.nv.gov")
+		if user.ID != "user_1" || user.ExternalID != idp.URL+"|demo-subject" {
+			t.Errorf("expected user_1 linked to the provider, got %+v", user)
+		}
+	})
+}
+
+// TestSSOEmailCase checks that the provider's spelling of an email address
+// neither misses the account it belongs to nor stores a duplicate that
+// password sign-in cannot find
+func TestSSOEmailCase(t *testing.T) {
+	ts, idp := newSSOServer(t, false)
+	users := len(ts.Repos.User.List())
+
+	idp.SignIn(testutil.IdPUser{
+		Subject:       "demo-subject",
+		Email:         " Demo@NCOE.nv.gov ",
+		EmailVerified: true,
+		Groups:        []string{"NCOE-Admins"},
+	})
+	if resp := ssoLogin(t, ts); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("callback: expected 303, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if user := ts.Repos.User.GetByEmail("demo@ncoe.nv.gov"); user.ID != "user_1" || user.ExternalID != idp.URL+"|demo-subject" {
+		t.Errorf("expected user_1 linked to the provider, got %+v", user)
+	}
+	if n := len(ts.Repos.User.List()); n != users {
+		t.Errorf("%d users after linking, want %d", n, users)
+	}
+
+	ts.ClearCookies()
+	mixed := janeDoe
+	mixed.Email = "Jane.Doe@Ethics.NV.gov"
+	idp.SignIn(mixed)
+	if resp := ssoLogin(t, ts); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("callback: expected 303, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if ts.Repos.User.GetByEmail("jane.doe@ethics.nv.gov") == nil {
+		t.Error("provisioned user's email not stored in lower case")
+	}
+}
+
+func TestSSORejections(t *testing.T) {
+	ts, idp := newSSOServer(t, false)
+
+	t.Run("unmapped groups are refused", func(t *testing.T) {
+		ts.ClearCookies()
+		idp.SignIn(testutil.IdPUser{Subject: "outsider", Email: "outsider@nv.gov", EmailVerified: true, Groups: []string{"DMV-Staff"}})
+		resp := ssoLogin(t, ts)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertContainsText("not authorized for the staff portal")
+		if ts.Repos.User.GetByEmail("outsider@nv.gov") != nil {
+			t.Error("unmapped user was provisioned")
+		}
+		if ts.SessionToken() != "" {
+			t.Error("refused login set a session cookie")
+		}
+	})
+
+	t.Run("state must match the browser that started the login", func(t *testing.T) {
+		ts.ClearCookies()
+		idp.SignIn(janeDoe)
+		atIdP := ts.Follow(ts.GET("/staff/login/sso"))
+		callback, _ := atIdP.Location()
+
+		// A different browser presenting the callback has no state cookie
+		ts.ClearCookies()
+		resp := ts.GET(callback.RequestURI())
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+		if ts.SessionToken() != "" {
+			t.Error("callback without state cookie set a session cookie")
+		}
+
+		// A forged state is rejected even with a cookie for it
+		ts.ClearCookies()
+		ts.GET("/staff/login/sso")
+		q := callback.Query()
+		q.Set("state", "forged")
+		resp = ts.GET("/staff/login/sso/callback?" + q.Encode())
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("forged state: expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("callback cannot be replayed", func(t *testing.T) {
+		ts.ClearCookies()
+		idp.SignIn(janeDoe)
+		start := ts.GET("/staff/login/sso")
+		callback, _ := ts.Follow(start).Location()
+		if resp := ts.GET(callback.RequestURI()); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("first callback: expected 303, got %d: %s", resp.StatusCode, resp.Body)
+		}
+
+		// Put the state cookie back and present the same code again
+		ts.ClearCookies()
+		for _, c := range start.Cookies() {
+			ts.Client.Jar.SetCookies(callback, []*http.Cookie{c})
+		}
+		if resp := ts.GET(callback.RequestURI()); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("replayed callback: expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("provider errors are shown", func(t *testing.T) {
+		ts.ClearCookies()
+		ts.GET("/staff/login/sso")
+		resp := ts.GET("/staff/login/sso/callback?error=access_denied&state=x")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertContainsText("cancelled or refused by Nevada State SSO")
+	})
+
+	invalidTokens := []struct {
+		name   string
+		modify func(claims map[string]interface{})
+		forge  bool
+	}{
+		{name: "wrong audience", modify: func(c map[string]interface{}) { c["aud"] = "another-app" }},
+		{name: "wrong issuer", modify: func(c map[string]interface{}) { c["iss"] = "https://evil.example" }},
+		{name: "wrong nonce", modify: func(c map[string]interface{}) { c["nonce"] = "replayed" }},
+		{name: "expired", modify: func(c map[string]interface{}) { c["exp"] = 1000000000 }},
+		{name: "signed by an unpublished key", forge: true},
+	}
+	for _, tc := range invalidTokens {
+		t.Run("invalid ID token: "+tc.name, func(t *testing.T) {
+			ts.ClearCookies()
+			idp.ModifyClaims = tc.modify
+			idp.SigningKey = nil
+			if tc.forge {
+				key, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					t.Fatal(err)
+				}
+				idp.SigningKey = key
+			}
+			defer func() { idp.ModifyClaims, idp.SigningKey = nil, nil }()
+
+			idp.SignIn(testutil.IdPUser{Subject: "mallory", Email: "mallory@nv.gov", EmailVerified: true, Groups: []string{"NCOE-Admins"}})
+			resp := ssoLogin(t, ts)
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("expected 401, got %d", resp.StatusCode)
+			}
+			if ts.Repos.User.GetByEmail("mallory@nv.gov") != nil {
+				t.Error("user was provisioned from an invalid token")
+			}
+			if ts.SessionToken() != "" {
+				t.Error("invalid token set a session cookie")
+			}
+		})
+	}
+
+	t.Run("deactivated users are refused", func(t *testing.T) {
+		ts.ClearCookies()
+		idp.SignIn(janeDoe)
+		if resp := ssoLogin(t, ts); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("expected 303, got %d", resp.StatusCode)
+		}
+		user := ts.Repos.User.GetByEmail(janeDoe.Email)
+		user.IsActive = false
+		ts.Repos.User.Update(user)
+
+		ts.ClearCookies()
+		resp := ssoLogin(t, ts)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertContainsText("deactivated")
+	})
+}
+
+func TestSSOExclusive(t *testing.T) {
+	ts, idp := newSSOServer(t, true)
+
+	resp := ts.GET("/staff/login")
+	dom := testutil.ParseDOM(t, resp.Body)
+	dom.AssertHasElementByID("sso-login")
+	if dom.FindByID("password-login-form") != nil {
+		t.Error("password form shown when single sign-on is required")
+	}
+
+	resp = ts.POST("/staff/login", testutil.LoginForm("test@test.gov", "password"))
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("password login: expected 403, got %d", resp.StatusCode)
+	}
+	if ts.SessionToken() != "" {
+		t.Error("password login set a session cookie")
+	}
+
+	idp.SignIn(janeDoe)
+	if resp := ssoLogin(t, ts); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("SSO login: expected 303, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if ts.SessionToken() == "" {
+		t.Error("SSO login did not set a session cookie")
+	}
+}
+
+// TestSSOSecondFactor checks single sign-on logins answer to the same
+// second-factor policy as passwords
+func TestSSOSecondFactor(t *testing.T) {
+	idp := testutil.NewFakeIdP(t)
+	ts := testutil.NewTestServer(t, testutil.WithSSO(idp, service.SSOConfig{RoleMap: ssoRoles}), testutil.WithMFARequired("investigator"))
+	defer ts.Close()
+
+	idp.SignIn(janeDoe)
+	resp := ssoLogin(t, ts)
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/staff/login/mfa" {
+		t.Fatalf("callback: expected 303 to the second step, got %d %q: %s", resp.StatusCode, resp.Header.Get("Location"), resp.Body)
+	}
+	if ts.SessionToken() != "" {
+		t.Fatal("session started before the second factor")
+	}
+
+	// A required role without an authenticator enrolls before any session
+	secret := testutil.ParseDOM(t, ts.GET("/staff/login/mfa").Body).TextByID("mfa-secret")
+	if secret == "" {
+		t.Fatal("enrollment secret not shown after single sign-on")
+	}
+	if resp := ts.POST("/staff/login/mfa", url.Values{"code": {totpCode(t, secret, 0)}}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("enrollment: expected 200, got %d", resp.StatusCode)
+	}
+	if ts.SessionToken() == "" {
+		t.Fatal("no session after the second factor")
+	}
+
+	// Once enrolled, every later login asks for a code
+	ts.ClearCookies()
+	if resp := ssoLogin(t, ts); resp.Header.Get("Location") != "/staff/login/mfa" || ts.SessionToken() != "" {
+		t.Errorf("second login: %d to %q, want the code asked for first", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp := ts.POST("/staff/login/mfa", url.Values{"code": {totpCode(t, secret, 1)}}); resp.StatusCode != http.StatusSeeOther || ts.SessionToken() == "" {
+		t.Errorf("code after single sign-on: %d, want 303 and a session", resp.StatusCode)
+	}
+}
+
+func TestSSODisabled(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	dom := testutil.ParseDOM(t, ts.GET("/staff/login").Body)
+	if dom.FindByID("sso-login") != nil {
+		t.Error("SSO button shown without a configured provider")
+	}
+	dom.AssertHasElementByID("password-login-form")
+	if resp := ts.GET("/staff/login/sso"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /staff/login/sso: expected 404, got %d", resp.StatusCode)
+	}
+	dom.AssertContainsText("Demo Mode")
+}
@@ -0,0 +1,71 @@
+package integration
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"ncoe/internal/testutil"
+)
+
+// TestStaticAssets follows the asset links on public and staff pages to the
+// embedded files, checking they are content-hashed and cached for good.
+func TestStaticAssets(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	hashed := regexp.MustCompile(`^/static/.+\.[0-9a-f]{10}\.[a-z]+$`)
+	pages := []string{"/", "/staff/dashboard"}
+	ts.Login("test@test.gov", "password")
+
+	for _, path := range pages {
+		dom := testutil.ParseDOM(t, ts.GET(path).Body)
+		var urls []string
+		for _, url := range append(dom.AttrsByTag("link", "href"), dom.AttrsByTag("script", "src")...) {
+			if strings.HasPrefix(url, "/static/css/") || strings.HasPrefix(url, "/static/js/") {
+				urls = append(urls, url)
+			}
+		}
+		if len(urls) < 2 {
+			t.Fatalf("%s links %v, want the stylesheet and script", path, urls)
+		}
+		for _, url := range urls {
+			if !hashed.MatchString(url) {
+				t.Errorf("%s links %s without a content hash", path, url)
+				continue
+			}
+			resp := ts.GET(url)
+			if resp.StatusCode != http.StatusOK || resp.Body == "" {
+				t.Errorf("GET %s: %d", url, resp.StatusCode)
+			}
+			if cc := resp.Header.Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+				t.Errorf("GET %s: Cache-Control %q, want immutable", url, cc)
+			}
+		}
+	}
+}
+
+// TestNoThirdPartyAssets checks pages load their scripts and stylesheets
+// from the server itself, since some agency networks block CDNs
+func TestNoThirdPartyAssets(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+
+	pages := []string{"/", "/search", "/submit/ethics-complaint", "/staff/login", "/staff/dashboard"}
+	for _, path := range pages {
+		if path == "/staff/dashboard" {
+			ts.Login("test@test.gov", "password")
+		}
+		dom := testutil.ParseDOM(t, ts.GET(path).Body)
+		urls := append(dom.AttrsByTag("link", "href"), dom.AttrsByTag("script", "src")...)
+		if len(urls) < 4 {
+			t.Fatalf("%s links %v, want Bootstrap, icons, HTMX or app assets", path, urls)
+		}
+		for _, url := range urls {
+			if strings.Contains(url, "://") || strings.HasPrefix(url, "//") {
+				t.Errorf("%s loads %s from another host", path, url)
+			}
+		}
+	}
+}
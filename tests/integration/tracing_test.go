@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"ncoe/internal/testutil"
+	"ncoe/internal/tracing"
+)
+
+// TestTracing checks a request continues the caller's trace, and its
+// service calls, repository calls and template render are spans within it
+func TestTracing(t *testing.T) {
+	spans := testutil.CaptureSpans(t)
+	logs := testutil.CaptureLogs(t)
+	ts := testutil.NewTestServer(t, testutil.WithAccessLog())
+	defer ts.Close()
+	ts.Login("test@test.gov", "password")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	resp := ts.GETWithHeaders("/staff/cases/1", map[string]string{
+		"traceparent": "00-" + traceID + "-00f067aa0ba902b7-01",
+	})
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Trace-Id"); got != traceID {
+		t.Errorf("X-Trace-Id = %q, want %q", got, traceID)
+	}
+
+	trace := spans.Trace(traceID)
+	byName := map[string]tracing.SpanData{}
+	for _, span := range trace {
+		byName[span.Name] = span
+	}
+	server, ok := byName["GET /staff/cases/"]
+	if !ok {
+		t.Fatalf("no server span in %v", names(trace))
+	}
+	if server.Kind != tracing.KindServer || server.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("server span %+v", server)
+	}
+	attrs := map[string]any{}
+	for _, a := range server.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["request_id"] != resp.Header.Get("X-Request-Id") || attrs["http.route"] != "/staff/cases/" || attrs["http.response.status_code"] != 200 {
+		t.Errorf("server span attributes %v", attrs)
+	}
+
+	get, ok := byName["CaseService.GetByID"]
+	if !ok || get.Parent != server.SpanID {
+		t.Fatalf("CaseService.GetByID not a child of the server span in %v", names(trace))
+	}
+	if repo, ok := byName["CaseRepository.GetByID"]; !ok || repo.Parent != get.SpanID {
+		t.Errorf("CaseRepository.GetByID not a child of CaseService.GetByID in %v", names(trace))
+	}
+	rendered := false
+	for _, span := range trace {
+		if strings.HasPrefix(span.Name, "render staff/") && span.Parent == server.SpanID {
+			rendered = true
+		}
+	}
+	if !rendered {
+		t.Errorf("no render span in %v", names(trace))
+	}
+
+	// The access log names the trace
+	logged := false
+	for _, record := range logs.Records("request") {
+		if record["request_id"] == resp.Header.Get("X-Request-Id") {
+			logged = record["trace_id"] == traceID
+		}
+	}
+	if !logged {
+		t.Errorf("no access log record with trace_id %s in %q", traceID, logs.String())
+	}
+
+	// What the case service logs is linked to the request's trace
+	resp = ts.POST("/staff/cases/1/_status", url.Values{"status": {"under_review"}})
+	changed := logs.Records("case status changed")
+	if len(changed) != 1 || changed[0]["trace_id"] != resp.Header.Get("X-Trace-Id") || changed[0]["request_id"] != resp.Header.Get("X-Request-Id") {
+		t.Errorf("status change logged as %v for trace %s", changed, resp.Header.Get("X-Trace-Id"))
+	}
+
+	// Without a traceparent each request starts a new trace
+	other := ts.GET("/search?q=gift").Header.Get("X-Trace-Id")
+	if len(other) != 32 || other == traceID {
+		t.Errorf("X-Trace-Id = %q for a new trace", other)
+	}
+	if trace := spans.Trace(other); len(trace) == 0 || trace[len(trace)-1].Name != "GET /search" {
+		t.Errorf("new trace %v", names(trace))
+	}
+}
+
+// TestTracingUnsampled checks that a caller's decision not to sample a
+// trace holds for the request's own spans and those within it
+func TestTracingUnsampled(t *testing.T) {
+	spans := testutil.CaptureSpans(t)
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	ts.Login("test@test.gov", "password")
+	before := len(spans.All())
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	resp := ts.GETWithHeaders("/staff/cases/1", map[string]string{
+		"traceparent": "00-" + traceID + "-00f067aa0ba902b7-00",
+	})
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Trace-Id"); got != traceID {
+		t.Errorf("X-Trace-Id = %q, want %q", got, traceID)
+	}
+	if all := spans.All(); len(all) != before {
+		t.Errorf("unsampled request exported %v", names(all[before:]))
+	}
+}
+
+func names(spans []tracing.SpanData) []string {
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
@@ -0,0 +1,344 @@
+package integration
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ncoe/internal/domain"
+	"ncoe/internal/testutil"
+)
+
+// passwordLinkPath returns the path and query of the invitation or reset
+// link shown in the element with the given ID
+func passwordLinkPath(t *testing.T, body, id string) string {
+	t.Helper()
+	link := testutil.ParseDOM(t, body).TextByID(id)
+	u, err := url.Parse(link)
+	if err != nil || u.Path != "/staff/password" || u.Query().Get("token") == "" {
+		t.Fatalf("%s = %q, want a /staff/password link with a token", id, link)
+	}
+	return u.RequestURI()
+}
+
+// loginStatus submits the password form and returns the status code, without
+// failing on a refused login
+func loginStatus(ts *testutil.TestServer, email, password string) int {
+	ts.ClearCookies()
+	return ts.POST("/staff/login", testutil.LoginForm(email, password)).StatusCode
+}
+
+func TestUserAdministration(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Close()
+	const email = "ivy@ncoe.nv.gov"
+
+	ts.Login("demo@ncoe.nv.gov", "password")
+	admin := ts.SessionToken()
+	dom := testutil.ParseDOM(t, ts.GET("/staff/users").Body)
+	dom.AssertHasElementByID("user-user_1")
+	dom.AssertHasElementByID("invite-user-form")
+
+	if ts.Repos.User.GetByID("user_missing") != nil {
+		t.Fatal("GetByID returned a user for an unknown ID")
+	}
+	if resp := ts.GET("/staff/users/user_missing"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown user: expected 404, got %d", resp.StatusCode)
+	}
+
+	var link string
+	t.Run("Invite", func(t *testing.T) {
+		form := url.Values{"email": {"not an email"}, "first_name": {"Ivy"}, "last_name": {"Investigator"}, "role": {"investigator"}}
+		resp := ts.POST("/staff/users", form)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("bad email: expected 400, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertHasElementByID("invite-error")
+
+		form.Set("email", email)
+		form.Set("title", "Investigator II")
+		resp = ts.POST("/staff/users", form)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("invite: expected 200, got %d", resp.StatusCode)
+		}
+		link = passwordLinkPath(t, resp.Body, "invite-link")
+		if !testutil.ParseDOM(t, resp.Body).HasAttrByID("invite-mail", "href") {
+			t.Error("invite: no email link offered")
+		}
+
+		user := ts.Repos.User.GetByEmail(email)
+		if user == nil || user.Role != domain.RoleInvestigator || user.Title != "Investigator II" || user.Status() != "invited" {
+			t.Fatalf("invited user = %+v", user)
+		}
+		if token := strings.TrimPrefix(link, "/staff/password?token="); user.PasswordTokenHash == "" || user.PasswordTokenHash == token {
+			t.Error("invitation token is not stored hashed")
+		}
+
+		if resp := ts.POST("/staff/users", form); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("duplicate invite: expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("InviteLinkIgnoresForgedHost", func(t *testing.T) {
+		form := url.Values{"email": {"hal@ncoe.nv.gov"}, "first_name": {"Hal"}, "last_name": {"Hearing"}, "role": {"investigator"}}
+		req, err := http.NewRequest("POST", ts.URL+"/staff/users", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		// The cookie jar does not send the session with a forged Host
+		req.AddCookie(&http.Cookie{Name: "session", Value: ts.SessionToken()})
+		req.Host = "attacker.example"
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("invite: expected 200, got %d", resp.StatusCode)
+		}
+		if link := testutil.ParseDOM(t, string(body)).TextByID("invite-link"); !strings.HasPrefix(link, ts.URL+"/staff/password?") {
+			t.Errorf("invite link = %q, want it on %s", link, ts.URL)
+		}
+	})
+
+	t.Run("AcceptInvitation", func(t *testing.T) {
+		// Until the link is used the account cannot sign in with any password
+		if status := loginStatus(ts, email, "password"); status == http.StatusSeeOther {
+			t.Fatal("invited user signed in before choosing a password")
+		}
+
+		resp := ts.GET(link)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("invitation link: expected 200, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertHasElementByID("set-password-form")
+		token := strings.TrimPrefix(link, "/staff/password?token=")
+
+		resp = ts.POST("/staff/password", url.Values{"token": {token}, "password": {"short"}, "confirm": {"short"}})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("short password: expected 400, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertHasElementByID("password-error")
+
+		resp = ts.POST("/staff/password", url.Values{"token": {token}, "password": {"correct horse battery"}, "confirm": {"correct horse battery"}})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("set password: expected 303, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, ts.Follow(resp).Body).AssertHasElementByID("login-notice")
+
+		if resp := ts.GET(link); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("used link: expected 404, got %d", resp.StatusCode)
+		}
+		if status := loginStatus(ts, email, "wrong password"); status == http.StatusSeeOther {
+			t.Error("signed in with the wrong password")
+		}
+		ts.Login(email, "correct horse battery")
+		if user := ts.Repos.User.GetByEmail(email); user.Status() != "active" || user.LastLoginAt == nil {
+			t.Errorf("after accepting: status %q, last login %v", user.Status(), user.LastLoginAt)
+		}
+	})
+
+	user := ts.Repos.User.GetByEmail(email)
+
+	t.Run("NonAdminsForbidden", func(t *testing.T) {
+		ts.Login(email, "correct horse battery")
+		for _, path := range []string{"/staff/users", "/staff/users/user_1", "/staff/audit"} {
+			if resp := ts.GET(path); resp.StatusCode != http.StatusForbidden {
+				t.Errorf("GET %s as investigator: expected 403, got %d", path, resp.StatusCode)
+			}
+		}
+		if resp := ts.POST("/staff/users/user_1/deactivate", url.Values{}); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("deactivate as investigator: expected 403, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		ts.SetSessionToken(admin)
+		dom := testutil.ParseDOM(t, ts.GET("/staff/users/"+user.ID).Body)
+		dom.AssertHasElementByID("edit-user-form")
+		dom.AssertHasElementByID("deactivate-user-form")
+
+		resp := ts.POST("/staff/users/"+user.ID, url.Values{"first_name": {"Ivy"}, "last_name": {"Investigator"}, "role": {"bogus"}})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("unknown role: expected 400, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertHasElementByID("user-error")
+
+		resp = ts.POST("/staff/users/"+user.ID, url.Values{"first_name": {"Ivy"}, "last_name": {"Investigator"}, "role": {"staff_attorney"}, "title": {"Staff Attorney"}, "phone": {"(775) 555-0100"}})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("edit: expected 303, got %d", resp.StatusCode)
+		}
+		updated := ts.Repos.User.GetByID(user.ID)
+		if updated.Role != domain.RoleStaffAttorney || updated.Title != "Staff Attorney" || updated.Phone != "(775) 555-0100" {
+			t.Errorf("after edit: %+v", updated)
+		}
+
+		// Admins cannot lock themselves out
+		resp = ts.POST("/staff/users/user_1", url.Values{"first_name": {"Demo"}, "last_name": {"Admin"}, "role": {"readonly"}})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("demoting self: expected 400, got %d", resp.StatusCode)
+		}
+		if resp := ts.POST("/staff/users/user_1/deactivate", url.Values{}); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("deactivating self: expected 400, got %d", resp.StatusCode)
+		}
+		if ts.Repos.User.GetByID("user_1").Role != domain.RoleAdmin {
+			t.Error("admin demoted themselves")
+		}
+	})
+
+	t.Run("DeactivateBlocksLogin", func(t *testing.T) {
+		ts.Login(email, "correct horse battery")
+		session := ts.SessionToken()
+
+		ts.SetSessionToken(admin)
+		if resp := ts.POST("/staff/users/"+user.ID+"/deactivate", url.Values{}); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("deactivate: expected 303, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, ts.GET("/staff/users/"+user.ID).Body).AssertHasElementByID("reactivate-user-form")
+
+		ts.SetSessionToken(session)
+		assertSignedIn(t, ts, false)
+		resp := ts.POST("/staff/login", testutil.LoginForm(email, "correct horse battery"))
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("login while deactivated: expected 403, got %d", resp.StatusCode)
+		}
+		testutil.ParseDOM(t, resp.Body).AssertContainsText("deactivated")
+
+		ts.SetSessionToken(admin)
+		if resp := ts.POST("/staff/users/"+user.ID+"/reactivate", url.Values{}); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("reactivate: expected 303, got %d", resp.StatusCode)
+		}
+		ts.Login(email, "correct horse battery")
+	})
+
+	t.Run("PasswordReset", func(t *testing.T) {
+		session := ts.SessionToken()
+		ts.SetSessionToken(admin)
+		resp := ts.POST("/staff/users/"+user.ID+"/password-reset", url.Values{})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("reset: expected 200, got %d", resp.StatusCode)
+		}
+		reset := passwordLinkPath(t, resp.Body, "reset-link")
+
+		// The old password and sessions stop working
+		ts.SetSessionToken(session)
+		assertSignedIn(t, ts, false)
+		if status := loginStatus(ts, email, "correct horse battery"); status == http.StatusSeeOther {
+			t.Fatal("old password still works after a reset")
+		}
+
+		token := strings.TrimPrefix(reset, "/staff/password?token=")
+		resp = ts.POST("/staff/password", url.Values{"token": {token}, "password": {"a brand new passphrase"}, "confirm": {"a brand new passphrase"}})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("set new password: expected 303, got %d", resp.StatusCode)
+		}
+		ts.Login(email, "a brand new passphrase")
+	})
+
+	t.Run("AuditLog", func(t *testing.T) {
+		ts.SetSessionToken(admin)
+		dom := testutil.ParseDOM(t, ts.GET("/staff/users/"+user.ID).Body)
+		dom.AssertHasElementByID("user-audit")
+
+		entries := ts.Repos.Audit.List("user", user.ID)
+		var actions []string
+		for i := len(entries) - 1; i >= 0; i-- {
+			actions = append(actions, string(entries[i].Action))
+		}
+		want := []string{"user.invited", "user.password_set", "user.updated", "user.deactivated", "user.reactivated", "user.password_reset", "user.password_set"}
+		if strings.Join(actions, ",") != strings.Join(want, ",") {
+			t.Errorf("audit actions = %v, want %v", actions, want)
+		}
+		for _, e := range entries {
+			if e.Action == domain.AuditUserUpdated && (!strings.Contains(e.Details, "investigator → staff_attorney") || e.ActorID != "user_1") {
+				t.Errorf("update entry = %+v, want the role change by user_1", e)
+			}
+			if e.Action == domain.AuditUserPasswordSet && e.ActorID != user.ID {
+				t.Errorf("password set entry actor = %s, want the user", e.ActorID)
+			}
+		}
+		// Refused changes are not recorded
+		if n := len(ts.Repos.Audit.List("user", "user_1")); n != 0 {
+			t.Errorf("%d audit entries for refused changes to user_1", n)
+		}
+
+		testutil.ParseDOM(t, ts.GET("/staff/audit").Body).AssertHasElementByID("audit-log")
+
+		// Auditors can read the log but not manage users
+		auditor := &domain.User{ID: "user_auditor", Email: "audit@ncoe.nv.gov", FirstName: "Avery", LastName: "Auditor", Role: domain.RoleAuditor, IsActive: true}
+		ts.Repos.User.Create(auditor)
+		ts.Login(auditor.Email, "password")
+		if resp := ts.GET("/staff/audit"); resp.StatusCode != http.StatusOK {
+			t.Errorf("audit log as auditor: expected 200, got %d", resp.StatusCode)
+		}
+		if resp := ts.GET("/staff/users"); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("users as auditor: expected 403, got %d", resp.StatusCode)
+		}
+	})
+}
+
+// TestLoginWithoutDemoMode checks that outside demo mode unknown emails and
+// accounts without a password are refused
+func TestLoginWithoutDemoMode(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithoutDemoLogins())
+	defer ts.Close()
+
+	if status := loginStatus(ts, "test@test.gov", "password"); status == http.StatusSeeOther {
+		t.Error("unknown email signed in")
+	}
+	if ts.Repos.User.GetByEmail("test@test.gov") != nil {
+		t.Error("unknown email created a user")
+	}
+	if status := loginStatus(ts, "demo@ncoe.nv.gov", "password"); status == http.StatusSeeOther {
+		t.Error("account without a password signed in")
+	}
+}
+
+// TestPasswordLinkEmails checks invitation and reset links are emailed when a
+// relay is configured, and counted by template
+func TestPasswordLinkEmails(t *testing.T) {
+	outbox := &testutil.Outbox{}
+	ts := testutil.NewTestServer(t, testutil.WithOutbox(outbox), testutil.WithMetricsToken(metricsToken))
+	defer ts.Close()
+	ts.Login("demo@ncoe.nv.gov", "password")
+	before := scrape(t, ts)
+
+	form := url.Values{"email": {"ivy@ncoe.nv.gov"}, "first_name": {"Ivy"}, "last_name": {"Investigator"}, "role": {"investigator"}}
+	resp := ts.POST("/staff/users", form)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("invite: expected 200, got %d", resp.StatusCode)
+	}
+	link := passwordLinkPath(t, resp.Body, "invite-link")
+	sent := outbox.Messages()
+	if len(sent) != 1 || sent[0].To != "ivy@ncoe.nv.gov" || !strings.Contains(sent[0].Body, ts.URL+link) {
+		t.Fatalf("emailed %+v, want the invitation link sent to Ivy", sent)
+	}
+	testutil.ParseDOM(t, resp.Body).AssertContainsText("emailed to ivy@ncoe.nv.gov")
+
+	// A relay that is down leaves the admin the link to send themselves
+	outbox.Fail = errors.New("connection refused")
+	user := ts.Repos.User.GetByEmail("ivy@ncoe.nv.gov")
+	resp = ts.POST("/staff/users/"+user.ID+"/password-reset", url.Values{})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("reset: expected 200, got %d", resp.StatusCode)
+	}
+	passwordLinkPath(t, resp.Body, "reset-link")
+	testutil.ParseDOM(t, resp.Body).AssertHasElementByID("email-failed")
+
+	after := scrape(t, ts)
+	for series, want := range map[string]float64{
+		`ncoe_emails_sent_total{template="invitation"}`:       1,
+		`ncoe_emails_failed_total{template="password_reset"}`: 1,
+		`ncoe_emails_sent_total{template="password_reset"}`:   0,
+	} {
+		if got := after[series] - before[series]; got != want {
+			t.Errorf("%s rose by %v, want %v", series, got, want)
+		}
+	}
+}